@@ -6,6 +6,31 @@ import AuditTable from './components/AuditTable'
 import { generateEvidencePack } from './utils/pdfGenerator'
 import './App.css'
 
+// The proxy appends every report to ghost_reports.jsonl; ghost_report.json
+// only holds the latest one and is the fallback for older data folders.
+async function fetchLatestReport() {
+  const logRes = await fetch('/data/ghost_reports.jsonl')
+  if (logRes.ok) {
+    const reports = (await logRes.text())
+      .split('\n')
+      .filter(line => line.trim().startsWith('{'))
+      .map(line => {
+        try {
+          return JSON.parse(line)
+        } catch {
+          return null
+        }
+      })
+      .filter(Boolean)
+    if (reports.length > 0) {
+      return reports[reports.length - 1]
+    }
+  }
+
+  const snapshotRes = await fetch('/data/ghost_report.json')
+  return snapshotRes.ok ? snapshotRes.json() : null
+}
+
 function App() {
   const [ghostData, setGhostData] = useState(null)
   const [cbomData, setCbomData] = useState([])
@@ -15,10 +40,9 @@ function App() {
   useEffect(() => {
     const fetchData = async () => {
       try {
-        // Fetch Ghost Report
-        const ghostRes = await fetch('/data/ghost_report.json')
-        if (ghostRes.ok) {
-          const ghost = await ghostRes.json()
+        // Fetch Ghost Report: latest line of the JSONL log, else the snapshot
+        const ghost = await fetchLatestReport()
+        if (ghost) {
           setGhostData(ghost)
         }
 
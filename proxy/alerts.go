@@ -0,0 +1,379 @@
+/*
+Sentinel-PQC Proxy - Alert Rules
+================================
+By default each notifier has a fixed trigger: -webhook fires for
+-webhook-on, Slack and Teams for fragmentation trouble, email for every
+detection. Alert rules replace those triggers with a policy that can be
+changed through the API while the proxy runs:
+
+  curl -X POST localhost:9090/api/alerts/rules -H 'Authorization: Bearer ...' --json '{
+    "name": "branch offices fragmenting",
+    "statuses": ["CRITICAL_RISK", "SUSPECTED_BLACKHOLE"],
+    "subnets": ["10.20.0.0/16"],
+    "min_size": 1300,
+    "rate": {"count": 10, "window": "5m"},
+    "notify": ["slack", "email"]
+  }'
+
+  GET    /api/alerts/rules          list the rules
+  POST   /api/alerts/rules          add one (admin)
+  GET    /api/alerts/rules/{id}     one rule
+  PUT    /api/alerts/rules/{id}     replace it (admin)
+  DELETE /api/alerts/rules/{id}     remove it (admin)
+
+A rule matches a report when every condition it sets holds: the status is
+one of statuses, the handshake size is within min_size and max_size, and
+the client is in one of subnets. With rate it only fires once count matching
+reports arrived within window, and then stays quiet for a window. notify
+names the notifiers it drives (webhook, slack, teams, email; empty means
+all), and "disabled": true keeps a rule without using it.
+
+As long as no rule is enabled the notifiers keep their fixed triggers. Once
+one is, a notifier only alerts for reports that fire a rule naming it; the
+alert carries the rule's name. Rules are kept in -alert-rules
+(alert_rules.json) and survive restarts. Silences (silences.go) hold back
+a rule's alerts, or a network's, while a known issue is being fixed.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const ALERT_RULES_MAX_BODY = 64 << 10
+
+// ALERT_NOTIFIERS are the sinks a rule can drive.
+var ALERT_NOTIFIERS = []string{"webhook", "slack", "teams", "email"}
+
+// AlertRule is one rule as stored and served by the API.
+type AlertRule struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Disabled bool       `json:"disabled,omitempty"`
+	Statuses []string   `json:"statuses,omitempty"` // any of; empty matches every status
+	MinSize  int        `json:"min_size,omitempty"` // handshake bytes
+	MaxSize  int        `json:"max_size,omitempty"`
+	Subnets  []string   `json:"subnets,omitempty"` // client CIDR prefixes, any of
+	Rate     *AlertRate `json:"rate,omitempty"`
+	Notify   []string   `json:"notify,omitempty"` // empty drives every notifier
+	Created  string     `json:"created"`
+	Updated  string     `json:"updated"`
+}
+
+// AlertRate makes a rule fire only for a burst of matching reports.
+type AlertRate struct {
+	Count  int    `json:"count"`
+	Window string `json:"window"` // duration, e.g. 5m
+}
+
+// alertRule is a rule ready for matching, with its rate state.
+type alertRule struct {
+	AlertRule
+	subnets []netip.Prefix
+	window  time.Duration
+	hits    []time.Time // matching reports within the window
+	quiet   time.Time   // fired; no more until then
+}
+
+var alertRules struct {
+	mu    sync.Mutex
+	path  string
+	rules []*alertRule
+}
+
+// alertNotifier is a sink that alerts people, so alert rules can decide
+// which reports it gets.
+type alertNotifier interface {
+	reportSink
+	notifier() string
+	// alert sends a report without checking the sink's own trigger.
+	alert(r GhostReport) error
+}
+
+// ============================================================================
+// RULES
+// ============================================================================
+
+// compile checks a rule and prepares it for matching.
+func (rule AlertRule) compile() (*alertRule, error) {
+	c := &alertRule{AlertRule: rule}
+	if rule.Name == "" {
+		return nil, errors.New("name: required")
+	}
+	if rule.MinSize < 0 || rule.MaxSize < 0 || rule.MaxSize > 0 && rule.MaxSize < rule.MinSize {
+		return nil, errors.New("min_size/max_size: not a valid range")
+	}
+	for _, s := range rule.Subnets {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("subnets: %q is not a CIDR prefix like 10.0.0.0/24", s)
+		}
+		c.subnets = append(c.subnets, prefix.Masked())
+	}
+	if r := rule.Rate; r != nil {
+		var err error
+		if c.window, err = time.ParseDuration(r.Window); err != nil || c.window <= 0 || r.Count < 1 {
+			return nil, errors.New(`rate: needs a count of at least 1 and a window like "5m"`)
+		}
+	}
+	for _, n := range rule.Notify {
+		if !slices.Contains(ALERT_NOTIFIERS, n) {
+			return nil, fmt.Errorf("notify: unknown notifier %q (%v)", n, ALERT_NOTIFIERS)
+		}
+	}
+	return c, nil
+}
+
+// matches applies the rule's conditions, without the rate, to a report.
+func (c *alertRule) matches(r GhostReport) bool {
+	if len(c.Statuses) > 0 && !slices.Contains(c.Statuses, r.Status) {
+		return false
+	}
+	if r.HandshakeSize < c.MinSize || c.MaxSize > 0 && r.HandshakeSize > c.MaxSize {
+		return false
+	}
+	if len(c.subnets) > 0 && !slices.ContainsFunc(c.subnets, func(p netip.Prefix) bool { return inSubnet(r.ClientIP, p) }) {
+		return false
+	}
+	return true
+}
+
+// fire reports whether a matching report fires the rule, counting it
+// towards the rate.
+func (c *alertRule) fire(now time.Time) bool {
+	if c.Rate == nil {
+		return true
+	}
+	cut := 0
+	for cut < len(c.hits) && now.Sub(c.hits[cut]) > c.window {
+		cut++
+	}
+	c.hits = append(c.hits[cut:], now)
+	if now.Before(c.quiet) || len(c.hits) < c.Rate.Count {
+		return false
+	}
+	c.hits = nil
+	c.quiet = now.Add(c.window)
+	return true
+}
+
+func (rule AlertRule) drives(notifier string) bool {
+	return len(rule.Notify) == 0 || slices.Contains(rule.Notify, notifier)
+}
+
+// firedAlertRules evaluates the enabled rules for one report; active is
+// false when there are none and the notifiers use their own triggers.
+func firedAlertRules(r GhostReport) (fired []AlertRule, active bool) {
+	now := time.Now()
+	alertRules.mu.Lock()
+	defer alertRules.mu.Unlock()
+	for _, c := range alertRules.rules {
+		if c.Disabled {
+			continue
+		}
+		active = true
+		if c.matches(r) && c.fire(now) {
+			fired = append(fired, c.AlertRule)
+		}
+	}
+	return fired, active
+}
+
+// writeAlert hands a report to a notifier if a fired rule drives it.
+func writeAlert(s alertNotifier, r GhostReport, fired []AlertRule) error {
+	for _, rule := range fired {
+		if rule.drives(s.notifier()) {
+			r.alertRule = rule.Name
+			return s.alert(r)
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// STORAGE
+// ============================================================================
+
+// loadAlertRules reads -alert-rules; a missing file holds no rules.
+func loadAlertRules(path string) error {
+	alertRules.mu.Lock()
+	defer alertRules.mu.Unlock()
+	alertRules.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var rules []AlertRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, rule := range rules {
+		c, err := rule.compile()
+		if err != nil {
+			return fmt.Errorf("%s: rule %s: %v", path, rule.ID, err)
+		}
+		alertRules.rules = append(alertRules.rules, c)
+	}
+	if len(rules) > 0 {
+		slog.Info("alert rules loaded", "file", path, "rules", len(rules))
+	}
+	return nil
+}
+
+// saveAlertRules writes the rules through a temporary file, so a crash never
+// leaves half a file; alertRules.mu must be held.
+func saveAlertRules() error {
+	rules := []AlertRule{}
+	for _, c := range alertRules.rules {
+		rules = append(rules, c.AlertRule)
+	}
+	data, _ := json.MarshalIndent(rules, "", "  ")
+	tmp := alertRules.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, alertRules.path)
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func registerAlertRules(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/alerts/rules", serveAlertRules)
+	mux.HandleFunc("POST /api/alerts/rules", serveCreateAlertRule)
+	mux.HandleFunc("GET /api/alerts/rules/{id}", serveAlertRule)
+	mux.HandleFunc("PUT /api/alerts/rules/{id}", serveUpdateAlertRule)
+	mux.HandleFunc("DELETE /api/alerts/rules/{id}", serveDeleteAlertRule)
+}
+
+func serveAlertRules(w http.ResponseWriter, r *http.Request) {
+	alertRules.mu.Lock()
+	rules := []AlertRule{}
+	for _, c := range alertRules.rules {
+		rules = append(rules, c.AlertRule)
+	}
+	alertRules.mu.Unlock()
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func serveAlertRule(w http.ResponseWriter, r *http.Request) {
+	alertRules.mu.Lock()
+	defer alertRules.mu.Unlock()
+	i := alertRuleIndex(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "no such rule")
+		return
+	}
+	writeJSON(w, http.StatusOK, alertRules.rules[i].AlertRule)
+}
+
+// decodeAlertRule reads and checks a rule from a request body.
+func decodeAlertRule(w http.ResponseWriter, r *http.Request) (*alertRule, bool) {
+	var rule AlertRule
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, ALERT_RULES_MAX_BODY))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rule); err != nil {
+		writeError(w, http.StatusBadRequest, "body: "+err.Error())
+		return nil, false
+	}
+	c, err := rule.compile()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return c, true
+}
+
+func serveCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	c, ok := decodeAlertRule(w, r)
+	if !ok {
+		return
+	}
+	id := make([]byte, 6)
+	rand.Read(id)
+	c.ID = hex.EncodeToString(id)
+	c.Created = time.Now().UTC().Format(time.RFC3339)
+	c.Updated = c.Created
+
+	alertRules.mu.Lock()
+	defer alertRules.mu.Unlock()
+	alertRules.rules = append(alertRules.rules, c)
+	if err := saveAlertRules(); err != nil {
+		alertRules.rules = alertRules.rules[:len(alertRules.rules)-1]
+		slog.Error("cannot save alert rules", "file", alertRules.path, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the rules")
+		return
+	}
+	slog.Info("alert rule added", "id", c.ID, "name", c.Name)
+	recordAudit(r, "alert_rule.create", c.ID, nil, c.AlertRule)
+	writeJSON(w, http.StatusCreated, c.AlertRule)
+}
+
+func serveUpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	c, ok := decodeAlertRule(w, r)
+	if !ok {
+		return
+	}
+	alertRules.mu.Lock()
+	defer alertRules.mu.Unlock()
+	i := alertRuleIndex(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "no such rule")
+		return
+	}
+	old := alertRules.rules[i]
+	c.ID, c.Created = old.ID, old.Created
+	c.Updated = time.Now().UTC().Format(time.RFC3339)
+	alertRules.rules[i] = c
+	if err := saveAlertRules(); err != nil {
+		alertRules.rules[i] = old
+		slog.Error("cannot save alert rules", "file", alertRules.path, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the rules")
+		return
+	}
+	slog.Info("alert rule updated", "id", c.ID, "name", c.Name)
+	recordAudit(r, "alert_rule.update", c.ID, old.AlertRule, c.AlertRule)
+	writeJSON(w, http.StatusOK, c.AlertRule)
+}
+
+func serveDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	alertRules.mu.Lock()
+	defer alertRules.mu.Unlock()
+	i := alertRuleIndex(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "no such rule")
+		return
+	}
+	old := alertRules.rules
+	alertRules.rules = slices.Delete(slices.Clone(old), i, i+1)
+	if err := saveAlertRules(); err != nil {
+		alertRules.rules = old
+		slog.Error("cannot save alert rules", "file", alertRules.path, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the rules")
+		return
+	}
+	slog.Info("alert rule deleted", "id", old[i].ID, "name", old[i].Name)
+	recordAudit(r, "alert_rule.delete", old[i].ID, old[i].AlertRule, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// alertRuleIndex finds a rule by ID; alertRules.mu must be held.
+func alertRuleIndex(id string) int {
+	return slices.IndexFunc(alertRules.rules, func(c *alertRule) bool { return c.ID == id })
+}
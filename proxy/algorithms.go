@@ -0,0 +1,308 @@
+/*
+Sentinel-PQC Proxy - Per-Algorithm Breakdown
+============================================
+With -api, GET /api/algorithms compares the negotiated key exchanges in the
+stored reports, so ML-KEM-768 on its own can be held against the hybrids and
+against classical traffic before anyone switches a default:
+
+  curl -s localhost:9090/api/algorithms | jq '.families'
+  curl -s 'localhost:9090/api/algorithms?since=168h&site=fra1' | jq '.algorithms[].latency'
+
+Every algorithm gets its detections, status counts, size percentiles and a
+size histogram (bins shared by all algorithms, so they line up), and the
+handshake latency from accept to the end of the server flight, with the KEM
+encapsulation on its own where the scenario measured it. The algorithms are
+also summed up per family:
+
+  pqc        post-quantum only (Kyber768, MLKEM768, IKEv2 ML-KEM-768)
+  hybrid     post-quantum and classical together (X25519MLKEM768,
+             mlkem768x25519-sha256, X-Wing)
+  classical  no post-quantum part (x25519, secp256r1)
+  unknown    anything else, e.g. unassigned TLS groups seen by a tunnel
+
+It takes the filters of /api/reports and looks at the last 24h unless since,
+from or to say otherwise, up to ALGORITHMS_MAX_REPORTS reports.
+*/
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ALGORITHMS_MAX_REPORTS   = 100000 // per request
+	ALGORITHMS_DEFAULT_SINCE = 24 * time.Hour
+)
+
+// ALGORITHM_FAMILIES is the order families are listed in.
+var ALGORITHM_FAMILIES = []string{"pqc", "hybrid", "classical", "unknown"}
+
+// LatencyStats are percentiles of a duration in milliseconds.
+type LatencyStats struct {
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean_ms"`
+	P50     float64 `json:"p50_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+	Max     float64 `json:"max_ms"`
+}
+
+// SizeBin is one bar of a size histogram.
+type SizeBin struct {
+	From       int `json:"from"` // bytes
+	To         int `json:"to"`   // exclusive
+	Safe       int `json:"safe"`
+	Detections int `json:"detections"`
+}
+
+// AlgorithmBreakdown is one negotiated algorithm.
+type AlgorithmBreakdown struct {
+	Algorithm     string         `json:"algorithm"`
+	Family        string         `json:"family"`
+	Reports       int            `json:"reports"`
+	Detections    int            `json:"detections"`
+	DetectionRate float64        `json:"detection_rate"`
+	OverBudget    int            `json:"over_budget"` // handshakes larger than their MTU budget
+	IPv6Risk      int            `json:"ipv6_risk"`
+	Statuses      map[string]int `json:"statuses"`
+	Size          SizeStats      `json:"size"`
+	Sizes         []SizeBin      `json:"size_histogram"`
+	Latency       LatencyStats   `json:"latency"`
+	Encapsulate   *LatencyStats  `json:"encapsulate,omitempty"`
+}
+
+// FamilyBreakdown sums up the algorithms of one family.
+type FamilyBreakdown struct {
+	Family        string       `json:"family"`
+	Algorithms    []string     `json:"algorithms"`
+	Reports       int          `json:"reports"`
+	Detections    int          `json:"detections"`
+	DetectionRate float64      `json:"detection_rate"`
+	Size          SizeStats    `json:"size"`
+	Latency       LatencyStats `json:"latency"`
+}
+
+// AlgorithmsResponse is the answer of GET /api/algorithms.
+type AlgorithmsResponse struct {
+	Generated  string               `json:"generated"`
+	Store      string               `json:"store"`
+	Filters    string               `json:"filters,omitempty"`
+	Reports    int                  `json:"reports"`
+	Truncated  bool                 `json:"truncated"` // more reports matched than were read
+	Families   []FamilyBreakdown    `json:"families"`
+	Algorithms []AlgorithmBreakdown `json:"algorithms"`
+}
+
+// algorithmFamily sorts a negotiated algorithm into pqc, hybrid, classical
+// or unknown by its name.
+func algorithmFamily(name string) string {
+	n := strings.ToLower(name)
+	has := func(parts ...string) bool {
+		for _, p := range parts {
+			if strings.Contains(n, p) {
+				return true
+			}
+		}
+		return false
+	}
+	pq := has("mlkem", "ml-kem", "kyber", "sntrup", "frodo", "hqc", "mceliece")
+	classical := has("x25519", "x448", "secp", "p256", "p384", "p-256", "p-384", "ecdh", "ffdhe")
+	switch {
+	case has("x-wing", "xwing"), pq && classical:
+		return "hybrid"
+	case pq:
+		return "pqc"
+	case classical:
+		return "classical"
+	}
+	return "unknown"
+}
+
+// algorithmBreakdown groups reports by algorithm and family.
+func algorithmBreakdown(reports []GhostReport) ([]FamilyBreakdown, []AlgorithmBreakdown) {
+	type group struct {
+		AlgorithmBreakdown
+		reports     []GhostReport
+		sizes       []int
+		latency     []float64
+		encapsulate []float64
+	}
+	algs := make(map[string]*group)
+	fams := make(map[string]*group)
+	lo, hi := 0, 0
+	for i, r := range reports {
+		if i == 0 || r.HandshakeSize < lo {
+			lo = r.HandshakeSize
+		}
+		hi = max(hi, r.HandshakeSize)
+
+		family := algorithmFamily(r.Algorithm)
+		g := algs[r.Algorithm]
+		if g == nil {
+			g = &group{AlgorithmBreakdown: AlgorithmBreakdown{
+				Algorithm: r.Algorithm,
+				Family:    family,
+				Statuses:  make(map[string]int),
+			}}
+			algs[r.Algorithm] = g
+		}
+		f := fams[family]
+		if f == nil {
+			f = &group{}
+			fams[family] = f
+		}
+		for _, x := range []*group{g, f} {
+			x.Reports++
+			if r.Status != "SAFE" {
+				x.Detections++
+			}
+			x.sizes = append(x.sizes, r.HandshakeSize)
+			if r.HandshakeMs > 0 {
+				x.latency = append(x.latency, r.HandshakeMs)
+			}
+		}
+		g.reports = append(g.reports, r)
+		g.Statuses[r.Status]++
+		if r.MTUBudget > 0 && r.HandshakeSize > r.MTUBudget {
+			g.OverBudget++
+		}
+		if r.IPv6Risk {
+			g.IPv6Risk++
+		}
+		if r.Timings != nil && r.Timings.EncapsulateMs > 0 {
+			g.encapsulate = append(g.encapsulate, r.Timings.EncapsulateMs)
+		}
+	}
+
+	width := sizeBinWidth(lo, hi)
+	first := lo / width * width
+	list := []AlgorithmBreakdown{}
+	names := make(map[string][]string)
+	for _, g := range algs {
+		a := g.AlgorithmBreakdown
+		a.DetectionRate = detectionRate(a.Detections, a.Reports)
+		a.Size = sizeStats(g.sizes)
+		a.Latency = latencyStats(g.latency)
+		if len(g.encapsulate) > 0 {
+			encap := latencyStats(g.encapsulate)
+			a.Encapsulate = &encap
+		}
+		a.Sizes = make([]SizeBin, hi/width-lo/width+1)
+		for i := range a.Sizes {
+			a.Sizes[i].From, a.Sizes[i].To = first+i*width, first+(i+1)*width
+		}
+		for _, r := range g.reports {
+			b := &a.Sizes[(r.HandshakeSize-first)/width]
+			if r.Status == "SAFE" {
+				b.Safe++
+			} else {
+				b.Detections++
+			}
+		}
+		list = append(list, a)
+		names[a.Family] = append(names[a.Family], a.Algorithm)
+	}
+	familyRank := func(f string) int {
+		for i, name := range ALGORITHM_FAMILIES {
+			if name == f {
+				return i
+			}
+		}
+		return len(ALGORITHM_FAMILIES)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if fi, fj := familyRank(list[i].Family), familyRank(list[j].Family); fi != fj {
+			return fi < fj
+		}
+		if list[i].Reports != list[j].Reports {
+			return list[i].Reports > list[j].Reports
+		}
+		return list[i].Algorithm < list[j].Algorithm
+	})
+
+	families := []FamilyBreakdown{}
+	for _, name := range ALGORITHM_FAMILIES {
+		f := fams[name]
+		if f == nil {
+			continue
+		}
+		sort.Strings(names[name])
+		families = append(families, FamilyBreakdown{
+			Family:        name,
+			Algorithms:    names[name],
+			Reports:       f.Reports,
+			Detections:    f.Detections,
+			DetectionRate: detectionRate(f.Detections, f.Reports),
+			Size:          sizeStats(f.sizes),
+			Latency:       latencyStats(f.latency),
+		})
+	}
+	return families, list
+}
+
+// latencyStats computes percentiles of durations in milliseconds.
+func latencyStats(ms []float64) LatencyStats {
+	if len(ms) == 0 {
+		return LatencyStats{}
+	}
+	sort.Float64s(ms)
+	round := func(v float64) float64 { return float64(int64(v*1000+0.5)) / 1000 }
+	rank := func(p int) float64 { return round(ms[(p*len(ms)+99)/100-1]) }
+	var sum float64
+	for _, v := range ms {
+		sum += v
+	}
+	return LatencyStats{
+		Samples: len(ms),
+		Mean:    round(sum / float64(len(ms))),
+		P50:     rank(50),
+		P95:     rank(95),
+		P99:     rank(99),
+		Max:     round(ms[len(ms)-1]),
+	}
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func serveAlgorithms(w http.ResponseWriter, r *http.Request) {
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("limit") == "" {
+		f.Limit = ALGORITHMS_MAX_REPORTS
+	}
+	if f.Since == 0 && f.From.IsZero() && f.To.IsZero() {
+		f.Since = ALGORITHMS_DEFAULT_SINCE
+	}
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+	reports, err := queryReports(store, f)
+	if err != nil {
+		slog.Error("algorithm breakdown query failed", "store", name, "err", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	families, algorithms := algorithmBreakdown(reports)
+	writeJSON(w, http.StatusOK, AlgorithmsResponse{
+		Generated:  time.Now().UTC().Format(time.RFC3339),
+		Store:      name,
+		Filters:    f.describe(),
+		Reports:    len(reports),
+		Truncated:  len(reports) == f.Limit,
+		Families:   families,
+		Algorithms: algorithms,
+	})
+}
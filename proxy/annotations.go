@@ -0,0 +1,319 @@
+/*
+Sentinel-PQC Proxy - Annotations
+================================
+A detection rate that drops at 14:00 means little without the change made
+at 14:00. Annotations are notes on a point in time, a time range or a single
+report, kept in -annotations (annotations.json) next to the alert rules and
+shown wherever the data is: the dashboard's live chart and Timeline tab and
+the assessment documents (assessment.go).
+
+  curl -X POST localhost:9090/api/annotations -H 'Authorization: Bearer ...' --json '{
+    "text": "enabled hybrid key shares on the CDN",
+    "time": "2025-03-04T14:00:00Z",
+    "subnet": "10.20.0.0/16",
+    "tags": ["change"]
+  }'
+
+  GET    /api/annotations        the annotations overlapping since or from/to
+  POST   /api/annotations        add one (operator)
+  PUT    /api/annotations/{id}   replace it (operator)
+  DELETE /api/annotations/{id}   remove it (operator)
+
+time defaults to now; end makes it a range and conn_id ties it to one
+report. subnet narrows it to some clients: a query with subnet= returns the
+annotations on overlapping networks and those without one. The author is
+the credential that wrote it, and every change is in the audit trail
+(control.go).
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ANNOTATIONS_MAX_BODY = 16 << 10
+	ANNOTATION_MAX_TEXT  = 2000 // characters
+)
+
+// Annotation is one note as stored and served by the API.
+type Annotation struct {
+	ID      string   `json:"id"`
+	Text    string   `json:"text"`
+	Time    string   `json:"time"`              // RFC 3339
+	End     string   `json:"end,omitempty"`     // a range ends here
+	ConnID  string   `json:"conn_id,omitempty"` // the report it is about
+	Subnet  string   `json:"subnet,omitempty"`  // clients it is about
+	Tags    []string `json:"tags,omitempty"`
+	Author  string   `json:"author"`
+	Created string   `json:"created"`
+	Updated string   `json:"updated"`
+
+	start, end time.Time // end is start for a point in time
+	subnet     netip.Prefix
+}
+
+var annotations struct {
+	mu   sync.Mutex
+	path string
+	list []Annotation // by time
+}
+
+// check validates an annotation and prepares it for matching.
+func (a *Annotation) check() error {
+	a.Text = strings.TrimSpace(a.Text)
+	if a.Text == "" || len(a.Text) > ANNOTATION_MAX_TEXT {
+		return fmt.Errorf("text: required, at most %d characters", ANNOTATION_MAX_TEXT)
+	}
+	var err error
+	if a.start, err = time.Parse(time.RFC3339, a.Time); err != nil {
+		return fmt.Errorf("time: %q is not an RFC 3339 time", a.Time)
+	}
+	a.end = a.start
+	if a.End != "" {
+		if a.end, err = time.Parse(time.RFC3339, a.End); err != nil || a.end.Before(a.start) {
+			return fmt.Errorf("end: %q is not an RFC 3339 time after time", a.End)
+		}
+	}
+	a.subnet = netip.Prefix{}
+	if a.Subnet != "" {
+		prefix, err := netip.ParsePrefix(a.Subnet)
+		if err != nil {
+			return fmt.Errorf("subnet: %q is not a CIDR prefix like 10.0.0.0/24", a.Subnet)
+		}
+		a.subnet = prefix.Masked()
+		a.Subnet = a.subnet.String()
+	}
+	return nil
+}
+
+// overlaps reports whether the annotation falls into [from, to) and concerns
+// subnet; zero values are unbounded.
+func (a Annotation) overlaps(from, to time.Time, subnet netip.Prefix) bool {
+	if !from.IsZero() && a.end.Before(from) || !to.IsZero() && !a.start.Before(to) {
+		return false
+	}
+	return !a.subnet.IsValid() || !subnet.IsValid() || a.subnet.Overlaps(subnet)
+}
+
+// annotationsIn are the annotations overlapping a window, oldest first.
+func annotationsIn(from, to time.Time, subnet netip.Prefix) []Annotation {
+	annotations.mu.Lock()
+	defer annotations.mu.Unlock()
+	return filterAnnotations(annotations.list, from, to, subnet)
+}
+
+func filterAnnotations(list []Annotation, from, to time.Time, subnet netip.Prefix) []Annotation {
+	found := []Annotation{}
+	for _, a := range list {
+		if a.overlaps(from, to, subnet) {
+			found = append(found, a)
+		}
+	}
+	return found
+}
+
+// ============================================================================
+// STORAGE
+// ============================================================================
+
+// readAnnotations reads an annotations file; a missing file holds none.
+func readAnnotations(path string) ([]Annotation, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Annotation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i := range list {
+		if err := list[i].check(); err != nil {
+			return nil, fmt.Errorf("%s: annotation %s: %v", path, list[i].ID, err)
+		}
+	}
+	sortAnnotations(list)
+	return list, nil
+}
+
+// loadAnnotations reads -annotations.
+func loadAnnotations(path string) error {
+	list, err := readAnnotations(path)
+	if err != nil {
+		return err
+	}
+	annotations.mu.Lock()
+	defer annotations.mu.Unlock()
+	annotations.path = path
+	annotations.list = list
+	if len(list) > 0 {
+		slog.Info("annotations loaded", "file", path, "annotations", len(list))
+	}
+	return nil
+}
+
+func sortAnnotations(list []Annotation) {
+	slices.SortStableFunc(list, func(a, b Annotation) int { return a.start.Compare(b.start) })
+}
+
+// saveAnnotations writes list through a temporary file; annotations.mu must
+// be held.
+func saveAnnotations(list []Annotation) error {
+	if list == nil {
+		list = []Annotation{}
+	}
+	data, _ := json.MarshalIndent(list, "", "  ")
+	tmp := annotations.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, annotations.path)
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func registerAnnotations(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/annotations", serveAnnotations)
+	mux.HandleFunc("POST /api/annotations", serveCreateAnnotation)
+	mux.HandleFunc("PUT /api/annotations/{id}", serveUpdateAnnotation)
+	mux.HandleFunc("DELETE /api/annotations/{id}", serveDeleteAnnotation)
+}
+
+func serveAnnotations(w http.ResponseWriter, r *http.Request) {
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	from, to := f.window()
+	list := annotationsIn(from, to, f.Subnet)
+	if id := r.URL.Query().Get("conn_id"); id != "" {
+		list = slices.DeleteFunc(list, func(a Annotation) bool { return a.ConnID != id })
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"annotations": list})
+}
+
+// decodeAnnotation reads and checks an annotation from a request body.
+func decodeAnnotation(w http.ResponseWriter, r *http.Request) (Annotation, bool) {
+	var a Annotation
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, ANNOTATIONS_MAX_BODY))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&a); err != nil {
+		writeError(w, http.StatusBadRequest, "body: "+err.Error())
+		return a, false
+	}
+	if a.Time == "" {
+		a.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	if err := a.check(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return a, false
+	}
+	a.Author = "anonymous"
+	if id, ok := requestIdentity(r); ok {
+		a.Author = id.Name
+	}
+	return a, true
+}
+
+// changeAnnotations saves the list edit makes of a copy of the annotations
+// and answers with the error, if any.
+func changeAnnotations(w http.ResponseWriter, edit func([]Annotation) ([]Annotation, bool)) bool {
+	annotations.mu.Lock()
+	defer annotations.mu.Unlock()
+	list, ok := edit(slices.Clone(annotations.list))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such annotation")
+		return false
+	}
+	sortAnnotations(list)
+	if err := saveAnnotations(list); err != nil {
+		slog.Error("cannot save annotations", "file", annotations.path, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the annotations")
+		return false
+	}
+	annotations.list = list
+	return true
+}
+
+func annotationIndex(list []Annotation, id string) int {
+	return slices.IndexFunc(list, func(a Annotation) bool { return a.ID == id })
+}
+
+func serveCreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	a, ok := decodeAnnotation(w, r)
+	if !ok {
+		return
+	}
+	id := make([]byte, 6)
+	rand.Read(id)
+	a.ID = hex.EncodeToString(id)
+	a.Created = time.Now().UTC().Format(time.RFC3339)
+	a.Updated = a.Created
+	if !changeAnnotations(w, func(list []Annotation) ([]Annotation, bool) { return append(list, a), true }) {
+		return
+	}
+	recordAudit(r, "annotation.create", a.ID, nil, a)
+	writeJSON(w, http.StatusCreated, a)
+}
+
+func serveUpdateAnnotation(w http.ResponseWriter, r *http.Request) {
+	a, ok := decodeAnnotation(w, r)
+	if !ok {
+		return
+	}
+	var before Annotation
+	a.ID = r.PathValue("id")
+	a.Updated = time.Now().UTC().Format(time.RFC3339)
+	ok = changeAnnotations(w, func(list []Annotation) ([]Annotation, bool) {
+		i := annotationIndex(list, a.ID)
+		if i < 0 {
+			return nil, false
+		}
+		before = list[i]
+		a.Created = before.Created
+		list[i] = a
+		return list, true
+	})
+	if !ok {
+		return
+	}
+	recordAudit(r, "annotation.update", a.ID, before, a)
+	writeJSON(w, http.StatusOK, a)
+}
+
+func serveDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var before Annotation
+	ok := changeAnnotations(w, func(list []Annotation) ([]Annotation, bool) {
+		i := annotationIndex(list, id)
+		if i < 0 {
+			return nil, false
+		}
+		before = list[i]
+		return slices.Delete(list, i, i+1), true
+	})
+	if !ok {
+		return
+	}
+	recordAudit(r, "annotation.delete", id, before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
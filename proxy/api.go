@@ -0,0 +1,200 @@
+/*
+Sentinel-PQC Proxy - HTTP API
+=============================
+-api serves JSON endpoints next to the proxy, for dashboards and scripts
+that would rather ask the proxy than parse its files:
+
+  go run . -api :9090
+  curl -s localhost:9090/api/stats | jq .size
+
+  GET /api/stats    rolling handshake statistics (stats.go)
+  GET /api/reports  stored reports, newest first (query.go)
+  GET /api/live     WebSocket feed of new reports (live.go)
+  GET /api/events   the same feed and status changes as SSE (events.go)
+  GET /api/clients  per-client risk scores and trends (clients.go)
+  GET /api/algorithms  detections, sizes and latency per algorithm (algorithms.go)
+  GET /api/subnets  client networks ranked by risk, for heatmaps (subnets.go)
+  GET /api/compare  two time windows and their deltas (delta.go)
+  GET /api/export/sarif  findings for compliance tooling (export.go)
+  GET /api/export/assessment  readiness document, HTML or PDF (assessment.go)
+  /grafana/...      JSON datasource for Grafana panels (grafana.go)
+  GET /api/config   running configuration (dashboard.go)
+  GET /status       uptime, connections, schemes, sink health (status.go)
+  GET /             the embedded dashboard (dashboard.go)
+  /api/alerts/rules alert rules driving the notifiers (alerts.go)
+  /api/alerts/silences  silenced and acknowledged alerts (silences.go)
+  /api/control      runtime settings and their audit trail (control.go)
+  /api/users        API users and their roles, admin only (users.go)
+  /api/annotations  notes on reports and time ranges (annotations.go)
+  GET /api/probes   probes reporting to a collector (collector.go)
+  POST /api/ingest  reports pushed by probes, collector only (collector.go)
+  GET /api/openapi.json  OpenAPI 3 document of all of the above (openapi.go)
+
+/api/reports filters with client, subnet (CIDR), status, algorithm, probe,
+site, since (24h) or from/to (RFC 3339) and pages with limit (default 100, at
+most 1000) and offset:
+
+  curl 'localhost:9090/api/reports?status=CRITICAL_RISK&since=24h&limit=50&offset=50'
+
+Only alert rules and silences, the control plane, the users, annotations
+and a collector's ingest change anything. The API is open unless -api-tokens or -api-users ask for
+credentials (auth.go); otherwise bind it to localhost or an internal
+interface.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const API_READ_TIMEOUT = 10 * time.Second
+
+// startAPI serves the API on addr in the background.
+func startAPI(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/stats", serveStats)
+	mux.HandleFunc("GET /api/reports", serveReports)
+	mux.HandleFunc("GET /api/live", serveLive)
+	mux.HandleFunc("GET /api/events", serveEvents)
+	mux.HandleFunc("GET /api/clients", serveClients)
+	mux.HandleFunc("GET /api/clients/{ip}", serveClient)
+	mux.HandleFunc("GET /api/algorithms", serveAlgorithms)
+	mux.HandleFunc("GET /api/subnets", serveSubnets)
+	mux.HandleFunc("GET /api/compare", serveCompare)
+	mux.HandleFunc("GET /api/export/sarif", serveSARIF)
+	mux.HandleFunc("GET /api/export/assessment", serveAssessment)
+	mux.HandleFunc("GET "+OPENAPI_PATH, serveOpenAPI)
+	registerGrafana(mux)
+	registerDashboard(mux)
+	registerAlertRules(mux)
+	registerSilences(mux)
+	registerStatus(mux)
+	registerControl(mux)
+	registerUsers(mux)
+	registerAnnotations(mux)
+	if collectorMode {
+		registerCollector(mux)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: requireAuth(mux), ReadHeaderTimeout: API_READ_TIMEOUT}
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			slog.Error("API server stopped", "err", err)
+		}
+	}()
+	slog.Info("API listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// writeJSON sends v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError sends {"error": msg}.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func serveStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statsSummary())
+}
+
+// reportPage is one page of /api/reports.
+type reportPage struct {
+	Store   string        `json:"store"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+	Count   int           `json:"count"`
+	More    bool          `json:"more"` // another page follows at offset+count
+	Reports []GhostReport `json:"reports"`
+}
+
+func serveReports(w http.ResponseWriter, r *http.Request) {
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+	limit := f.Limit
+	f.Limit++ // one extra to tell whether another page follows
+	reports, err := queryReports(store, f)
+	if err != nil {
+		slog.Error("report query failed", "store", name, "err", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	page := reportPage{Store: name, Offset: f.Offset, Limit: limit, Reports: reports}
+	if len(reports) > limit {
+		page.More = true
+		page.Reports = reports[:limit]
+	}
+	if page.Reports == nil {
+		page.Reports = []GhostReport{}
+	}
+	page.Count = len(page.Reports)
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseReportQuery(r *http.Request) (historyFilter, error) {
+	q := r.URL.Query()
+	f := historyFilter{
+		Client:    q.Get("client"),
+		Status:    q.Get("status"),
+		Algorithm: q.Get("algorithm"),
+		Probe:     q.Get("probe"),
+		Site:      q.Get("site"),
+		Limit:     QUERY_DEFAULT_LIMIT,
+	}
+	var err error
+	if v := q.Get("subnet"); v != "" {
+		if f.Subnet, err = netip.ParsePrefix(v); err != nil {
+			return f, fmt.Errorf("subnet: %q is not a CIDR prefix like 10.0.0.0/24", v)
+		}
+		f.Subnet = f.Subnet.Masked()
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.ParseDuration(v); err != nil || f.Since <= 0 {
+			return f, fmt.Errorf("since: %q is not a positive duration like 24h", v)
+		}
+	}
+	for key, t := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(key); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return f, fmt.Errorf("%s: %q is not an RFC 3339 time", key, v)
+			}
+		}
+	}
+	for key, n := range map[string]*int{"limit": &f.Limit, "offset": &f.Offset} {
+		if v := q.Get(key); v != "" {
+			if *n, err = strconv.Atoi(v); err != nil || *n < 0 {
+				return f, fmt.Errorf("%s: %q is not a non-negative number", key, v)
+			}
+		}
+	}
+	if f.Limit == 0 || f.Limit > QUERY_MAX_LIMIT {
+		return f, fmt.Errorf("limit: must be between 1 and %d", QUERY_MAX_LIMIT)
+	}
+	return f, nil
+}
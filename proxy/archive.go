@@ -0,0 +1,332 @@
+/*
+Sentinel-PQC Proxy - Object Storage Archive
+===========================================
+-archive uploads the reports as gzipped JSONL batches to S3 or Google Cloud
+Storage, for teams that keep security telemetry in a central bucket:
+
+  AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=... AWS_REGION=eu-central-1 \
+      go run . -archive s3://telemetry/sentinel-pqc -archive-interval 1h
+  SENTINEL_ARCHIVE_ACCESS_KEY=GOOG1E... SENTINEL_ARCHIVE_SECRET_KEY=... \
+      go run . -archive gs://telemetry/sentinel-pqc
+
+  sentinel-pqc/dt=2026-10-16/sentinel-edge1-20261016T170000Z-0001.jsonl.gz
+
+Objects are keyed by UTC day under the prefix, so lifecycle rules can expire
+or transition them by prefix or age, and query engines (Athena, BigQuery)
+read dt= as a partition. The host name keeps several proxies apart in one
+bucket. Uploads are signed with AWS Signature V4; Cloud Storage takes the
+same requests with HMAC keys. -archive-endpoint points at an S3-compatible
+store such as MinIO (path-style requests).
+
+A batch that fails to upload is retried with the next one, keeping at most
+ARCHIVE_MAX_PENDING batches; whatever is buffered is uploaded on shutdown.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ARCHIVE_MAX_PENDING = 24 // batches kept while uploads fail
+	ARCHIVE_TIMEOUT     = 60 * time.Second
+	ARCHIVE_ACCESS_ENV  = "SENTINEL_ARCHIVE_ACCESS_KEY" // else AWS_ACCESS_KEY_ID
+	ARCHIVE_SECRET_ENV  = "SENTINEL_ARCHIVE_SECRET_KEY" // else AWS_SECRET_ACCESS_KEY
+)
+
+// archiveBatch is one gzipped JSONL object waiting for upload.
+type archiveBatch struct {
+	key     string
+	data    []byte
+	reports int
+}
+
+type archiveSink struct {
+	target   string // s3://bucket/prefix
+	bucket   string
+	prefix   string
+	endpoint *url.URL // nil = AWS virtual-hosted style
+	region   string
+	access   string
+	secret   string
+	token    string
+	host     string // this proxy, in object names
+	client   *http.Client
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	zw      *gzip.Writer
+	count   int
+	started time.Time
+	seq     int
+	pending []archiveBatch
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ============================================================================
+// SETUP
+// ============================================================================
+
+func openArchiveSink(target, endpoint string, interval time.Duration) (*archiveSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return nil, fmt.Errorf("archive: %q is not an s3://bucket/prefix or gs://bucket/prefix URL", target)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("archive: -archive-interval must be positive")
+	}
+	s := &archiveSink{
+		target: target,
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+		region: os.Getenv("AWS_REGION"),
+		access: os.Getenv(ARCHIVE_ACCESS_ENV),
+		secret: os.Getenv(ARCHIVE_SECRET_ENV),
+		client: &http.Client{Timeout: ARCHIVE_TIMEOUT},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if s.access == "" {
+		s.access, s.secret, s.token = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if s.access == "" || s.secret == "" {
+		return nil, fmt.Errorf("archive: set %s/%s or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY", ARCHIVE_ACCESS_ENV, ARCHIVE_SECRET_ENV)
+	}
+	if u.Scheme == "gs" && endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+		s.region = "auto"
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if endpoint != "" {
+		if s.endpoint, err = url.Parse(endpoint); err != nil || s.endpoint.Host == "" {
+			return nil, fmt.Errorf("archive: -archive-endpoint %q is not a URL", endpoint)
+		}
+	}
+	s.host, _ = os.Hostname()
+	if s.host == "" {
+		s.host = "proxy"
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+// ============================================================================
+// BATCHING
+// ============================================================================
+
+func (s *archiveSink) Name() string { return "archive:" + s.target }
+
+func (s *archiveSink) Write(r GhostReport) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.zw == nil {
+		s.buf.Reset()
+		s.zw = gzip.NewWriter(&s.buf)
+		s.started = time.Now().UTC()
+	}
+	s.zw.Write(append(line, '\n'))
+	s.count++
+	return nil
+}
+
+// cut closes the current batch and queues it for upload; s.mu must be held.
+func (s *archiveSink) cut() {
+	if s.zw == nil {
+		return
+	}
+	s.zw.Close()
+	s.seq++
+	s.pending = append(s.pending, archiveBatch{
+		key:     s.objectKey(s.started, s.seq),
+		data:    bytes.Clone(s.buf.Bytes()),
+		reports: s.count,
+	})
+	if drop := len(s.pending) - ARCHIVE_MAX_PENDING; drop > 0 {
+		slog.Error("archive: uploads keep failing, dropping the oldest batches", "batches", drop)
+		s.pending = s.pending[drop:]
+	}
+	s.zw, s.count = nil, 0
+}
+
+// objectKey is prefix/dt=YYYY-MM-DD/sentinel-HOST-START-SEQ.jsonl.gz.
+func (s *archiveSink) objectKey(start time.Time, seq int) string {
+	name := fmt.Sprintf("dt=%s/sentinel-%s-%s-%04d.jsonl.gz", start.Format("2006-01-02"), s.host, start.Format("20060102T150405Z"), seq)
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *archiveSink) run(interval time.Duration) {
+	defer close(s.done)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush cuts the current batch and uploads every pending one.
+func (s *archiveSink) flush() {
+	s.mu.Lock()
+	s.cut()
+	batches := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	for i, b := range batches {
+		if err := s.put(b.key, b.data); err != nil {
+			slog.Error("archive: upload failed, retrying with the next batch", "object", b.key, "err", err)
+			s.mu.Lock()
+			s.pending = append(batches[i:len(batches):len(batches)], s.pending...)
+			s.mu.Unlock()
+			return
+		}
+		slog.Info("archive: uploaded batch", "object", b.key, "reports", b.reports, "bytes", len(b.data))
+	}
+}
+
+// Close uploads what is buffered.
+func (s *archiveSink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.pending); n > 0 {
+		return fmt.Errorf("%d batch(es) not uploaded", n)
+	}
+	return nil
+}
+
+// ============================================================================
+// UPLOAD (AWS SIGNATURE V4)
+// ============================================================================
+
+func (s *archiveSink) put(key string, data []byte) error {
+	var u url.URL
+	if s.endpoint != nil {
+		u = url.URL{Scheme: s.endpoint.Scheme, Host: s.endpoint.Host, Path: strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket + "/" + key}
+	} else {
+		u = url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + key}
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	s.sign(req, data, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds the SigV4 Authorization header for the s3 service.
+func (s *archiveSink) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.secret), day)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.access, scope, signedHeaders, signature))
+}
+
+// s3EscapePath percent-encodes everything but unreserved characters and
+// slashes, as SigV4 canonical requests expect ("dt=" becomes "dt%3D").
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,558 @@
+/*
+Sentinel-PQC Proxy - Assessment Reports
+=======================================
+PQC-readiness findings usually end up attached to a change ticket or an
+audit, so the assess command and /api/export/assessment render stored
+reports as one standalone document, HTML or PDF:
+
+  go run . assess -since 168h -o readiness.html             # report log
+  go run . assess -db reports.db -subnet 10.20.0.0/16 -o readiness.pdf
+  curl -o readiness.pdf 'localhost:9090/api/export/assessment?format=pdf&since=24h'
+
+It is a point-in-time snapshot of the selected reports: a verdict, the
+summary figures, the verdicts and handshake sizes as charts (sizes against
+the most common MTU budget and the IPv6 minimum), the annotations made in
+the period (annotations.go), a table per algorithm, the compliance findings
+of the export command (export.go) and the subnets with the most detections. The HTML carries its styles and SVG charts inline and
+the PDF is written here, so neither needs anything else to open.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ASSESSMENT_MAX_REPORTS = 100000 // per API request
+	ASSESSMENT_BIN         = 100    // bytes per size histogram bar
+	ASSESSMENT_MAX_BINS    = 24
+	ASSESSMENT_TOP         = 10 // subnets listed
+)
+
+// Assessment is the content of one assessment document.
+type Assessment struct {
+	Generated     string
+	Source        string
+	Filters       string
+	From, To      string // oldest and newest report
+	Reports       int
+	Detections    int
+	DetectionRate float64
+	IPv6Risk      int // handshakes over the IPv6 minimum budget
+	Verdict       string
+	Summary       string
+	Size          SizeStats
+	Budget        int // most common MTU budget
+	Statuses      []AssessmentCount
+	Sizes         []AssessmentBin
+	Algorithms    []AssessmentAlgorithm
+	Findings      []AssessmentFinding
+	Subnets       []SubnetStats
+	Annotations   []Annotation // within the selection (annotations.go)
+}
+
+// AssessmentCount is the number of reports with one status.
+type AssessmentCount struct {
+	Status string
+	Count  int
+}
+
+// AssessmentBin is one bar of the size histogram.
+type AssessmentBin struct {
+	From, To         int // bytes, To exclusive
+	Safe, Detections int
+}
+
+// AssessmentAlgorithm is one row of the algorithm table.
+type AssessmentAlgorithm struct {
+	AlgorithmStats
+	OverBudget int
+	IPv6Risk   int
+}
+
+// AssessmentFinding is one compliance rule with the reports violating it.
+type AssessmentFinding struct {
+	complianceRule
+	Count   int
+	Clients int
+}
+
+// buildAssessment summarizes reports, in any order.
+func buildAssessment(reports []GhostReport, source string, f historyFilter) Assessment {
+	a := Assessment{
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		Source:    source,
+		Filters:   f.describe(),
+		Reports:   len(reports),
+	}
+
+	statuses := make(map[string]int)
+	budgets := make(map[int]int)
+	algs := make(map[string]*AssessmentAlgorithm)
+	algSizes := make(map[string][]int)
+	subnets := make(map[string]*GroupStats)
+	findings := make(map[string]*AssessmentFinding)
+	findingClients := make(map[string]map[string]bool)
+	var sizes []int
+	for _, r := range reports {
+		detection := r.Status != "SAFE"
+		statuses[r.Status]++
+		if a.From == "" || r.Timestamp < a.From {
+			a.From = r.Timestamp
+		}
+		if r.Timestamp > a.To {
+			a.To = r.Timestamp
+		}
+		sizes = append(sizes, r.HandshakeSize)
+		if r.MTUBudget > 0 {
+			budgets[r.MTUBudget]++
+		}
+		alg := algs[r.Algorithm]
+		if alg == nil {
+			alg = &AssessmentAlgorithm{AlgorithmStats: AlgorithmStats{Algorithm: r.Algorithm}}
+			algs[r.Algorithm] = alg
+		}
+		alg.Reports++
+		algSizes[r.Algorithm] = append(algSizes[r.Algorithm], r.HandshakeSize)
+		if detection {
+			a.Detections++
+			alg.Detections++
+		}
+		if r.MTUBudget > 0 && r.HandshakeSize > r.MTUBudget {
+			alg.OverBudget++
+		}
+		if r.IPv6Risk {
+			a.IPv6Risk++
+			alg.IPv6Risk++
+		}
+		countGroup(subnets, clientSubnet(r.ClientIP), detection)
+		for _, id := range complianceFindings(r) {
+			fd := findings[id]
+			if fd == nil {
+				fd = &AssessmentFinding{complianceRule: complianceRules[complianceRuleIndex(id)]}
+				findings[id] = fd
+				findingClients[id] = make(map[string]bool)
+			}
+			fd.Count++
+			findingClients[id][clientHost(r.ClientIP)] = true
+		}
+	}
+	a.DetectionRate = detectionRate(a.Detections, a.Reports)
+	a.Size = sizeStats(append([]int(nil), sizes...))
+	for budget, n := range budgets {
+		if n > budgets[a.Budget] || n == budgets[a.Budget] && budget < a.Budget {
+			a.Budget = budget
+		}
+	}
+
+	for s, n := range statuses {
+		a.Statuses = append(a.Statuses, AssessmentCount{s, n})
+	}
+	sort.Slice(a.Statuses, func(i, j int) bool {
+		if a.Statuses[i].Count != a.Statuses[j].Count {
+			return a.Statuses[i].Count > a.Statuses[j].Count
+		}
+		return a.Statuses[i].Status < a.Statuses[j].Status
+	})
+	for name, alg := range algs {
+		alg.DetectionRate = detectionRate(alg.Detections, alg.Reports)
+		alg.Size = sizeStats(algSizes[name])
+		a.Algorithms = append(a.Algorithms, *alg)
+	}
+	sort.Slice(a.Algorithms, func(i, j int) bool { return a.Algorithms[i].Algorithm < a.Algorithms[j].Algorithm })
+	for id, fd := range findings {
+		fd.Clients = len(findingClients[id])
+		a.Findings = append(a.Findings, *fd)
+	}
+	sort.Slice(a.Findings, func(i, j int) bool {
+		return complianceRuleIndex(a.Findings[i].ID) < complianceRuleIndex(a.Findings[j].ID)
+	})
+	groups := topGroups(subnets)
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Detections > groups[j].Detections })
+	for _, g := range groups[:min(len(groups), ASSESSMENT_TOP)] {
+		a.Subnets = append(a.Subnets, SubnetStats{Subnet: g.Name, Reports: g.Reports, Detections: g.Detections})
+	}
+	a.Sizes = sizeHistogram(reports)
+	a.Verdict, a.Summary = a.verdict()
+	return a
+}
+
+// verdict sums the assessment up in a word and a sentence.
+func (a Assessment) verdict() (string, string) {
+	switch {
+	case a.Reports == 0:
+		return "NO DATA", "No reports match the selection."
+	case a.Detections == 0 && a.IPv6Risk == 0:
+		return "READY", fmt.Sprintf("All %d post-quantum handshakes completed within their MTU budget.", a.Reports)
+	case a.Detections == 0:
+		return "READY ON IPv4", fmt.Sprintf("All %d handshakes completed, but %d exceed the %d-byte IPv6 minimum budget and may fragment on IPv6 paths.", a.Reports, a.IPv6Risk, IPV6_MIN_BUDGET)
+	case a.DetectionRate < 0.05:
+		return "AT RISK", fmt.Sprintf("%d of %d handshakes (%.1f%%) ran into fragmentation or path trouble.", a.Detections, a.Reports, a.DetectionRate*100)
+	}
+	return "NOT READY", fmt.Sprintf("%d of %d handshakes (%.1f%%) ran into fragmentation or path trouble.", a.Detections, a.Reports, a.DetectionRate*100)
+}
+
+// sizeHistogram bins handshake sizes into ASSESSMENT_BIN-byte bars, wider
+// ones when the sizes spread over more than ASSESSMENT_MAX_BINS of them.
+func sizeHistogram(reports []GhostReport) []AssessmentBin {
+	if len(reports) == 0 {
+		return nil
+	}
+	lo, hi := reports[0].HandshakeSize, reports[0].HandshakeSize
+	for _, r := range reports {
+		lo, hi = min(lo, r.HandshakeSize), max(hi, r.HandshakeSize)
+	}
+	width := sizeBinWidth(lo, hi)
+	first := lo / width * width
+	bins := make([]AssessmentBin, hi/width-lo/width+1)
+	for i := range bins {
+		bins[i].From, bins[i].To = first+i*width, first+(i+1)*width
+	}
+	for _, r := range reports {
+		b := &bins[(r.HandshakeSize-first)/width]
+		if r.Status == "SAFE" {
+			b.Safe++
+		} else {
+			b.Detections++
+		}
+	}
+	return bins
+}
+
+// sizeBinWidth is the bar width for sizes from lo to hi.
+func sizeBinWidth(lo, hi int) int {
+	width := ASSESSMENT_BIN
+	for (hi/width - lo/width + 1) > ASSESSMENT_MAX_BINS {
+		width *= 2
+	}
+	return width
+}
+
+// complianceRuleIndex finds a rule of complianceRules by ID.
+func complianceRuleIndex(id string) int {
+	for i, rule := range complianceRules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return len(complianceRules) - 1 // SENTINEL-GEN-001
+}
+
+// describe renders the filter for the document's header.
+func (f historyFilter) describe() string {
+	var parts []string
+	add := func(k, v string) {
+		if v != "" {
+			parts = append(parts, k+"="+v)
+		}
+	}
+	add("client", f.Client)
+	add("status", f.Status)
+	add("algorithm", f.Algorithm)
+	if f.Subnet.IsValid() {
+		add("subnet", f.Subnet.String())
+	}
+	add("probe", f.Probe)
+	add("site", f.Site)
+	if f.From.IsZero() && f.Since > 0 {
+		add("since", f.Since.String())
+	}
+	if !f.From.IsZero() {
+		add("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		add("to", f.To.Format(time.RFC3339))
+	}
+	if len(parts) == 0 {
+		return "all reports"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ============================================================================
+// HTML
+// ============================================================================
+
+var assessmentTemplate = template.Must(template.New("assessment").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"verdictClass": func(v string) string {
+		switch v {
+		case "READY":
+			return "safe"
+		case "READY ON IPv4", "AT RISK", "NO DATA":
+			return "warn"
+		}
+		return "risk"
+	},
+	"statusClass": func(s string) string {
+		if s == "SAFE" {
+			return "safe"
+		}
+		return "risk"
+	},
+	"levelClass": func(l string) string {
+		return map[string]string{"error": "risk", "warning": "warn"}[l]
+	},
+	"statusChart": statusChartSVG,
+	"sizeChart":   sizeChartSVG,
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Sentinel-PQC readiness assessment</title>
+<style>
+body { font: 14px/1.5 system-ui, sans-serif; color: #18181b; max-width: 960px; margin: 32px auto; padding: 0 24px; }
+h1 { margin-bottom: 0; } h2 { margin-top: 32px; border-bottom: 1px solid #e4e4e7; padding-bottom: 4px; }
+.meta { color: #71717a; margin-top: 4px; }
+.verdict { display: inline-block; padding: 4px 12px; border-radius: 6px; font-weight: 600; color: #fff; }
+.verdict.safe { background: #059669; } .verdict.warn { background: #d97706; } .verdict.risk { background: #dc2626; }
+.cards { display: grid; grid-template-columns: repeat(auto-fit, minmax(140px, 1fr)); gap: 12px; margin-top: 16px; }
+.card { border: 1px solid #e4e4e7; border-radius: 8px; padding: 10px 14px; }
+.card div:first-child { color: #71717a; font-size: 12px; } .card div:last-child { font-size: 20px; font-weight: 600; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; }
+th, td { text-align: left; padding: 5px 8px; border-bottom: 1px solid #f4f4f5; } th { color: #71717a; font-weight: 500; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.safe { color: #059669; } .warn { color: #d97706; } .risk { color: #dc2626; }
+svg text { font: 11px system-ui, sans-serif; fill: #52525b; }
+@media print { body { margin: 0; } h2 { break-after: avoid; } }
+</style>
+</head>
+<body>
+<h1>Post-quantum readiness assessment</h1>
+<p class="meta">Generated {{.Generated}} from {{.Source}} · {{.Filters}}{{if .From}} · reports from {{.From}} to {{.To}}{{end}}</p>
+<p><span class="verdict {{verdictClass .Verdict}}">{{.Verdict}}</span> {{.Summary}}</p>
+
+<div class="cards">
+  <div class="card"><div>Handshakes</div><div>{{.Reports}}</div></div>
+  <div class="card"><div>Detections</div><div>{{.Detections}}</div></div>
+  <div class="card"><div>Detection rate</div><div>{{pct .DetectionRate}}</div></div>
+  <div class="card"><div>Over IPv6 minimum</div><div>{{.IPv6Risk}}</div></div>
+  <div class="card"><div>Size p50 / p95</div><div>{{.Size.P50}} / {{.Size.P95}} B</div></div>
+  <div class="card"><div>Size max</div><div>{{.Size.Max}} B</div></div>
+</div>
+{{if .Reports}}
+<h2>Verdicts</h2>
+{{statusChart .Statuses}}
+
+<h2>Handshake sizes</h2>
+{{sizeChart .Sizes .Budget}}
+{{if .Annotations}}
+<h2>Annotations</h2>
+<table>
+<tr><th>Time</th><th>Until</th><th>Note</th><th>Subnet</th><th>By</th></tr>
+{{range .Annotations}}<tr><td>{{.Time}}</td><td>{{.End}}</td><td>{{.Text}}</td><td>{{.Subnet}}</td><td>{{.Author}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Algorithms</h2>
+<table>
+<tr><th>Algorithm</th><th class="n">Handshakes</th><th class="n">Detections</th><th class="n">Rate</th><th class="n">Over budget</th><th class="n">Over IPv6 min</th><th class="n">p50</th><th class="n">p95</th><th class="n">Max</th></tr>
+{{range .Algorithms}}<tr><td>{{.Algorithm}}</td><td class="n">{{.Reports}}</td><td class="n">{{.Detections}}</td><td class="n">{{pct .DetectionRate}}</td><td class="n">{{.OverBudget}}</td><td class="n">{{.IPv6Risk}}</td><td class="n">{{.Size.P50}}</td><td class="n">{{.Size.P95}}</td><td class="n">{{.Size.Max}}</td></tr>
+{{end}}</table>
+
+<h2>Findings</h2>
+{{if .Findings}}<table>
+<tr><th>Rule</th><th>Level</th><th>Finding</th><th class="n">Reports</th><th class="n">Clients</th></tr>
+{{range .Findings}}<tr><td>{{.ID}}</td><td class="{{levelClass .Level}}">{{.Level}}</td><td>{{.Short}}</td><td class="n">{{.Count}}</td><td class="n">{{.Clients}}</td></tr>
+{{end}}</table>{{else}}<p>No compliance findings.</p>{{end}}
+
+<h2>Subnets with the most detections</h2>
+<table>
+<tr><th>Subnet</th><th class="n">Handshakes</th><th class="n">Detections</th></tr>
+{{range .Subnets}}<tr><td>{{.Subnet}}</td><td class="n">{{.Reports}}</td><td class="n">{{.Detections}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// statusChartSVG draws one horizontal bar per status.
+func statusChartSVG(counts []AssessmentCount) template.HTML {
+	const width, label, row = 640, 190, 22
+	top := 1
+	for _, c := range counts {
+		top = max(top, c.Count)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" role="img" aria-label="reports per status">`, width, len(counts)*row)
+	for i, c := range counts {
+		w := max(1, c.Count*(width-label-60)/top)
+		color := "#dc2626"
+		if c.Status == "SAFE" {
+			color = "#059669"
+		}
+		y := i * row
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s</text><rect x="%d" y="%d" width="%d" height="14" fill="%s"/><text x="%d" y="%d">%d</text>`,
+			y+12, template.HTMLEscapeString(c.Status), label, y+2, w, color, label+w+6, y+12, c.Count)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// sizeChartSVG draws the size histogram, SAFE stacked under detections,
+// with the MTU budget and the IPv6 minimum budget as lines.
+func sizeChartSVG(bins []AssessmentBin, budget int) template.HTML {
+	const width, height, axis = 720, 180, 20
+	if len(bins) == 0 {
+		return ""
+	}
+	top := 1
+	for _, bin := range bins {
+		top = max(top, bin.Safe+bin.Detections)
+	}
+	lo, hi := bins[0].From, bins[len(bins)-1].To
+	x := func(bytes int) int { return (bytes - lo) * width / (hi - lo) }
+	barW := width / len(bins)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg width="%d" height="%d" role="img" aria-label="handshake size histogram">`, width, height+axis)
+	for i, bin := range bins {
+		safeH := bin.Safe * height / top
+		detH := bin.Detections * height / top
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#059669"/>`, i*barW+1, height-safeH, barW-2, safeH)
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#dc2626"/>`, i*barW+1, height-safeH-detH, barW-2, detH)
+		if i%max(1, len(bins)/8) == 0 {
+			fmt.Fprintf(&b, `<text x="%d" y="%d">%d</text>`, i*barW+1, height+14, bin.From)
+		}
+	}
+	for _, line := range []struct {
+		bytes int
+		color string
+		label string
+	}{{budget, "#dc2626", "MTU budget"}, {IPV6_MIN_BUDGET, "#d97706", "IPv6 minimum"}} {
+		if line.bytes > lo && line.bytes < hi {
+			fmt.Fprintf(&b, `<line x1="%[1]d" x2="%[1]d" y1="0" y2="%[2]d" stroke="%[3]s" stroke-dasharray="4 3"/><text x="%[4]d" y="10">%[5]s %[6]d B</text>`,
+				x(line.bytes), height, line.color, x(line.bytes)+4, line.label, line.bytes)
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// ============================================================================
+// COMMAND AND API
+// ============================================================================
+
+func runAssess(args []string) {
+	fs := flag.NewFlagSet("assess", flag.ExitOnError)
+	db := fs.String("db", "", "Read the SQLite store written with -sqlite instead of the report log")
+	logFile := fs.String("log", "ghost_reports.jsonl", "Report log to read (rotated .N and .N.gz files are not included)")
+	out := fs.String("o", "", "Write the document to this file instead of stdout")
+	format := fs.String("format", "", "html or pdf (default from the -o extension, else html)")
+	var f historyFilter
+	fs.StringVar(&f.Client, "client", "", "Only reports from this client IP")
+	fs.StringVar(&f.Status, "status", "", "Only reports with this status (e.g. CRITICAL_RISK)")
+	fs.StringVar(&f.Algorithm, "algorithm", "", "Only reports for this algorithm (e.g. Kyber768)")
+	fs.Func("subnet", "Only reports from clients in this CIDR prefix (e.g. 10.0.0.0/24)", func(v string) error {
+		prefix, err := netip.ParsePrefix(v)
+		f.Subnet = prefix.Masked()
+		return err
+	})
+	fs.DurationVar(&f.Since, "since", 0, "Only reports from the last D (e.g. 168h)")
+	notes := fs.String("annotations", "annotations.json", "Annotations to include (written through /api/annotations)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: assess [-db FILE | -log FILE] [-annotations FILE] [-client IP] [-status S] [-algorithm A] [-subnet CIDR] [-since D] [-format html|pdf] [-o FILE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *format == "" {
+		*format = "html"
+		if strings.EqualFold(filepath.Ext(*out), ".pdf") {
+			*format = "pdf"
+		}
+	}
+	if *format != "html" && *format != "pdf" {
+		log.Fatalf("[ASSESS] unknown -format %q (html or pdf)", *format)
+	}
+	reports, source, err := readStoredReports(*db, *logFile, f)
+	if err != nil {
+		log.Fatalf("[ASSESS] %v", err)
+	}
+
+	list, err := readAnnotations(*notes)
+	if err != nil {
+		log.Fatalf("[ASSESS] %v", err)
+	}
+	a := buildAssessment(reports, source, f)
+	from, to := f.window()
+	a.Annotations = filterAnnotations(list, from, to, f.Subnet)
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("[ASSESS] %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeAssessment(w, a, *format); err != nil {
+		log.Fatalf("[ASSESS] %v", err)
+	}
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "%d report(s) assessed: %s, written to %s\n", a.Reports, a.Verdict, *out)
+	}
+}
+
+func writeAssessment(w io.Writer, a Assessment, format string) error {
+	if format == "pdf" {
+		_, err := w.Write(assessmentPDF(a))
+		return err
+	}
+	return assessmentTemplate.Execute(w, a)
+}
+
+// serveAssessment renders the reports /api/reports would select, all of
+// them up to ASSESSMENT_MAX_REPORTS unless limit is given.
+func serveAssessment(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" {
+		writeError(w, http.StatusBadRequest, "format: html or pdf")
+		return
+	}
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		f.Limit = ASSESSMENT_MAX_REPORTS
+	}
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+	reports, err := queryReports(store, f)
+	if err != nil {
+		slog.Error("assessment query failed", "store", name, "err", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	a := buildAssessment(reports, name, f)
+	from, to := f.window()
+	a.Annotations = annotationsIn(from, to, f.Subnet)
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="sentinel-assessment.pdf"`)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if err := writeAssessment(w, a, format); err != nil {
+		slog.Error("assessment rendering failed", "format", format, "err", err)
+	}
+}
@@ -0,0 +1,431 @@
+/*
+Sentinel-PQC Proxy - API Authentication
+=======================================
+Reports name internal client addresses, so -api-tokens and -api-users put
+the dashboard, the REST and WebSocket endpoints and the gRPC service behind
+credentials:
+
+  go run . -api :9090 -api-tokens tokens.txt -api-users users.txt
+  curl -H 'Authorization: Bearer 6f1c...' localhost:9090/api/stats
+  curl -u noc:secret localhost:9090/api/reports
+
+Both files hold one credential per line, "#" starts a comment:
+
+  # tokens.txt: name scope token
+  grafana   read   6f1c2b0e9d8a47c3b5e1f0a2d4c6e8b1
+  ci        admin  sha256:0b2c...       the SHA-256 of the token, in hex
+
+  # users.txt: user scope password
+  noc       viewer    $2a$10$N9qo8uLOickgx2ZMRZoMye...   a bcrypt hash
+  oncall    operator  correct-horse                     hashed when loaded
+
+The read scope (viewer role) covers every endpoint that only looks at data,
+including the Grafana queries; operator adds running the proxy: the runtime
+control plane (control.go), annotations (annotations.go) and alert
+silences (silences.go); ingest only lets probes push reports to a
+collector (collector.go); admin may do everything, including alert rules and
+the users (users.go). $SENTINEL_API_TOKEN adds one admin token. Tokens go in an
+"Authorization: Bearer" header, or ?token= where a header cannot be set
+(browser WebSockets); gRPC takes the header as "authorization" metadata.
+The dashboard's own files and the OpenAPI document (openapi.go) carry no
+data and load without credentials; the page asks for a token when the API
+turns it away.
+
+Tokens are random, so their SHA-256 is enough; passwords are kept as bcrypt
+hashes. Password entries written as sha256: by older versions still work
+but log a warning until the password is set again.
+
+Without either flag the API stays open, as before; bind it to localhost or
+an internal interface. The open API cannot create users: add the first
+admin with the user subcommand (users.go) or set $SENTINEL_API_TOKEN.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"sentinel-pqc-proxy/reportpb"
+)
+
+const (
+	API_TOKEN_ENV = "SENTINEL_API_TOKEN"
+	AUTH_REALM    = "sentinel-pqc"
+	PASSWORD_COST = bcrypt.DefaultCost
+)
+
+// OPERATOR_PATHS are the API paths, and the paths below them, an operator
+// may change.
+var OPERATOR_PATHS = []string{"/api/control", "/api/annotations", "/api/alerts/silences", "/api/alerts/fired"}
+
+// authScope is what a credential may do.
+type authScope int
+
+const (
+	scopePublic authScope = iota
+	scopeRead
+	scopeIngest
+	scopeOperator
+	scopeAdmin
+)
+
+func (s authScope) String() string {
+	switch s {
+	case scopeRead:
+		return "read"
+	case scopeIngest:
+		return "ingest"
+	case scopeOperator:
+		return "operator"
+	case scopeAdmin:
+		return "admin"
+	}
+	return "public"
+}
+
+// allows reports whether a credential of scope s may do what need requires;
+// an operator may also read and admin may do everything.
+func (s authScope) allows(need authScope) bool {
+	return s == need || s == scopeAdmin || need == scopePublic || (s == scopeOperator && need == scopeRead)
+}
+
+func parseAuthScope(s string) (authScope, error) {
+	switch s {
+	case "read", "viewer":
+		return scopeRead, nil
+	case "ingest":
+		return scopeIngest, nil
+	case "operator":
+		return scopeOperator, nil
+	case "admin":
+		return scopeAdmin, nil
+	}
+	return scopePublic, fmt.Errorf("unknown scope %q (viewer, operator, admin or ingest)", s)
+}
+
+// apiIdentity is the credential a request was let in with.
+type apiIdentity struct {
+	Name  string
+	Scope authScope
+}
+
+// apiCredential is a token or user; digest is the SHA-256 of a token (or of
+// a legacy sha256: password), hash the bcrypt hash of a password.
+type apiCredential struct {
+	apiIdentity
+	digest []byte
+	hash   []byte
+}
+
+var apiAuth struct {
+	mu       sync.RWMutex
+	tokens   map[string]apiCredential // by hex digest
+	users    map[string]apiCredential // by user name
+	userFile string                   // -api-users, rewritten by /api/users
+}
+
+// authEnabled reports whether the API asks for credentials.
+func authEnabled() bool {
+	apiAuth.mu.RLock()
+	defer apiAuth.mu.RUnlock()
+	return len(apiAuth.tokens) > 0 || len(apiAuth.users) > 0
+}
+
+// ============================================================================
+// SETUP
+// ============================================================================
+
+// setupAPIAuth loads -api-tokens and -api-users.
+func setupAPIAuth(tokenFile, userFile string) error {
+	apiAuth.tokens = make(map[string]apiCredential)
+	apiAuth.users = make(map[string]apiCredential)
+	apiAuth.userFile = userFile
+	if tokenFile != "" {
+		err := readCredentials(tokenFile, false, func(c apiCredential) error {
+			apiAuth.tokens[hex.EncodeToString(c.digest)] = c
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if token := os.Getenv(API_TOKEN_ENV); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		apiAuth.tokens[hex.EncodeToString(sum[:])] = apiCredential{apiIdentity: apiIdentity{"$" + API_TOKEN_ENV, scopeAdmin}, digest: sum[:]}
+	}
+	if userFile != "" {
+		err := readCredentials(userFile, true, func(c apiCredential) error {
+			if _, dup := apiAuth.users[c.Name]; dup {
+				return fmt.Errorf("user %q is listed twice", c.Name)
+			}
+			apiAuth.users[c.Name] = c
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("no users yet, PUT /api/users/{name} creates the file", "file", userFile)
+		} else if err != nil {
+			return err
+		}
+	}
+	if authEnabled() {
+		slog.Info("API authentication enabled", "tokens", len(apiAuth.tokens), "users", len(apiAuth.users))
+	}
+	return nil
+}
+
+// readCredentials parses "name scope secret" lines; a secret written as
+// sha256:HEX, or for passwords as a bcrypt hash, is already hashed. Plain
+// passwords are hashed with bcrypt, plain tokens with SHA-256.
+func readCredentials(path string, passwords bool, add func(apiCredential) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return fmt.Errorf("%s:%d: want \"name scope secret\"", path, n)
+		}
+		scope, err := parseAuthScope(fields[1])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		c := apiCredential{apiIdentity: apiIdentity{Name: fields[0], Scope: scope}}
+		secret := fields[2]
+		h, legacy := strings.CutPrefix(secret, "sha256:")
+		switch {
+		case legacy:
+			if c.digest, err = hex.DecodeString(h); err != nil || len(c.digest) != sha256.Size {
+				return fmt.Errorf("%s:%d: sha256: needs 64 hex digits", path, n)
+			}
+			if passwords {
+				slog.Warn("password stored as unsalted SHA-256, set it again to store it with bcrypt", "file", path, "user", c.Name)
+			}
+		case strings.HasPrefix(secret, "$2"):
+			if !passwords {
+				return fmt.Errorf("%s:%d: bcrypt hashes are for passwords, store tokens as sha256:", path, n)
+			}
+			if _, err := bcrypt.Cost([]byte(secret)); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			c.hash = []byte(secret)
+		case passwords:
+			if c.hash, err = hashPassword(secret); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+		default:
+			sum := sha256.Sum256([]byte(secret))
+			c.digest = sum[:]
+		}
+		if err := add(c); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// ============================================================================
+// CHECKS
+// ============================================================================
+
+// bearerToken is the token of an "Authorization: Bearer" header.
+func bearerToken(header string) string {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// authenticate checks a token, or else basic auth credentials; presented is
+// false if there were neither.
+func authenticate(token, user, pass string, hasBasic bool) (id apiIdentity, presented, valid bool) {
+	if token != "" {
+		sum := sha256.Sum256([]byte(token))
+		apiAuth.mu.RLock()
+		c, found := apiAuth.tokens[hex.EncodeToString(sum[:])]
+		apiAuth.mu.RUnlock()
+		return c.apiIdentity, true, found
+	}
+	if hasBasic {
+		// bcrypt is slow on purpose; compare outside the lock
+		apiAuth.mu.RLock()
+		c, found := apiAuth.users[user]
+		apiAuth.mu.RUnlock()
+		return c.apiIdentity, true, c.checkPassword(pass) && found
+	}
+	return apiIdentity{}, false, false
+}
+
+// dummyPasswordHash stands in for the hash of an unknown user, so that
+// guessing user names takes as long as guessing passwords.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	h, _ := hashPassword("sentinel-pqc")
+	return h
+})
+
+// checkPassword reports whether pass is the password of c.
+func (c apiCredential) checkPassword(pass string) bool {
+	switch {
+	case c.hash != nil:
+		return bcrypt.CompareHashAndPassword(c.hash, []byte(pass)) == nil
+	case c.digest != nil:
+		sum := sha256.Sum256([]byte(pass))
+		return subtle.ConstantTimeCompare(sum[:], c.digest) == 1
+	}
+	bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(pass))
+	return false
+}
+
+// requiredScope is what a request needs: the dashboard's files and the
+// OpenAPI document are public, reads of the API and /status need read,
+// pushed reports ingest, changes of the runtime settings operator and
+// everything else admin.
+func requiredScope(r *http.Request) authScope {
+	api := strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/grafana/") || r.URL.Path == "/status"
+	switch {
+	case r.URL.Path == COLLECTOR_INGEST_PATH:
+		return scopeIngest
+	case r.URL.Path == OPENAPI_PATH:
+		return scopePublic
+	case strings.HasPrefix(r.URL.Path, "/api/users"):
+		return scopeAdmin
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if !api {
+			return scopePublic
+		}
+		return scopeRead
+	case strings.HasPrefix(r.URL.Path, "/grafana/"):
+		return scopeRead // Grafana POSTs its queries
+	case slices.ContainsFunc(OPERATOR_PATHS, func(p string) bool { return r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") }):
+		return scopeOperator
+	}
+	return scopeAdmin
+}
+
+// jsonBody reports whether r is a read or carries a JSON body. Browsers
+// send form and text bodies cross-site without asking first, so insisting
+// on application/json keeps other sites from changing the proxy through a
+// logged-in dashboard, with or without credentials configured.
+func jsonBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return err == nil && mt == "application/json"
+	}
+	return true
+}
+
+// requireAuth wraps the API mux; it turns away mutating requests that are
+// not JSON and passes everything else through while no credentials are
+// configured, which /api/users can change.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !jsonBody(r) {
+			writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+			return
+		}
+		need := requiredScope(r)
+		if need == scopePublic || !authEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := bearerToken(r.Header.Get("Authorization"))
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		user, pass, hasBasic := r.BasicAuth()
+		id, presented, valid := authenticate(token, user, pass, hasBasic)
+		switch {
+		case !presented || !valid:
+			if presented {
+				slog.Warn("API credentials rejected", "remote", r.RemoteAddr, "path", r.URL.Path)
+			}
+			w.Header().Add("WWW-Authenticate", `Bearer realm="`+AUTH_REALM+`"`)
+			if hasUsers() {
+				w.Header().Add("WWW-Authenticate", `Basic realm="`+AUTH_REALM+`", charset="UTF-8"`)
+			}
+			writeError(w, http.StatusUnauthorized, "authentication required")
+		case !id.Scope.allows(need):
+			slog.Warn("API request needs a wider scope", "name", id.Name, "scope", id.Scope.String(), "need", need.String(), "path", r.URL.Path)
+			writeError(w, http.StatusForbidden, fmt.Sprintf("%s scope required", need))
+		default:
+			next.ServeHTTP(w, withIdentity(r, id))
+		}
+	})
+}
+
+// ============================================================================
+// GRPC
+// ============================================================================
+
+// grpcAuthOptions check the "authorization" metadata of every call:
+// PushReports needs the ingest scope, the other methods read, and the
+// credential goes with the call's context. Like requireAuth they are always
+// installed and let calls through while no credentials are configured, so a
+// user created later locks gRPC too.
+func grpcAuthOptions() []grpc.ServerOption {
+	check := func(ctx context.Context, method string) (context.Context, error) {
+		if !authEnabled() {
+			return ctx, nil
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		var header string
+		if v := md.Get("authorization"); len(v) > 0 {
+			header = v[0]
+		}
+		id, _, valid := authenticate(bearerToken(header), "", "", false)
+		if !valid {
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		}
+		need := scopeRead
+		if method == reportpb.ReportService_PushReports_FullMethodName {
+			need = scopeIngest
+		}
+		if !id.Scope.allows(need) {
+			return nil, status.Errorf(codes.PermissionDenied, "%s scope required", need)
+		}
+		return context.WithValue(ctx, identityKey{}, id), nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			ctx, err := check(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if _, err := check(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Sentinel-PQC Proxy - Wire Capture Observer
+==========================================
+The MTU checks are size heuristics. With -capture IFACE the proxy also
+watches the interface itself (AF_PACKET, decoded with gopacket) and counts
+what each handshake really occupied on the wire:
+
+  - IP packets and bytes in each direction
+  - TCP/UDP packets that carried payload (data segments)
+  - the largest packet seen, and any IP fragments
+
+The report's "wire" section is ground truth for the predicted segment
+counts in "flights". Needs root / CAP_NET_RAW; Linux only. Offloads (GRO,
+TSO) mean the kernel may show coalesced packets larger than the link MTU,
+exactly as tcpdump would.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	CAPTURE_GRACE  = 100 * time.Millisecond // let the last packets land
+	CAPTURE_MAXAGE = time.Minute            // forget peers nobody asked for
+)
+
+// WireStats is what one peer's traffic looked like on the interface.
+type WireStats struct {
+	Interface       string `json:"interface"`
+	PacketsIn       int    `json:"packets_in"`
+	PacketsOut      int    `json:"packets_out"`
+	BytesIn         int    `json:"ip_bytes_in"`
+	BytesOut        int    `json:"ip_bytes_out"`
+	DataSegmentsIn  int    `json:"data_segments_in"`
+	DataSegmentsOut int    `json:"data_segments_out"`
+	LargestIn       int    `json:"largest_packet_in"`
+	LargestOut      int    `json:"largest_packet_out"`
+	Fragments       int    `json:"ip_fragments"`
+	SYNDataIn       int    `json:"syn_data_bytes_in,omitempty"` // TCP Fast Open
+	DSCPIn          []int  `json:"dscp_in,omitempty"`           // marks seen on the client's packets
+
+	lastSeen time.Time
+}
+
+// wireObserver aggregates captured packets per peer address.
+type wireObserver struct {
+	iface string
+	ports map[int]bool // listener ports
+
+	mu    sync.Mutex
+	peers map[string]*WireStats
+	frags map[string]fragOwner // fragment ID -> peer, for non-first fragments
+}
+
+type fragOwner struct {
+	peer    string
+	inbound bool
+}
+
+var wire *wireObserver
+
+// listenerPorts returns the ports of all configured listeners.
+func listenerPorts() map[int]bool {
+	ports := make(map[int]bool)
+	for _, p := range configuredListeners() {
+		if _, port, err := net.SplitHostPort(p.Addr); err == nil {
+			if n, err := strconv.Atoi(port); err == nil {
+				ports[n] = true
+			}
+		}
+	}
+	return ports
+}
+
+// observe attributes one decoded packet to the peer talking to a listener.
+func (w *wireObserver) observe(packet gopacket.Packet) {
+	var src, dst net.IP
+	var size, dscp int
+	var fragID string
+	var fragPayload []byte // first fragment: starts with the transport header
+	fragment, firstFragment := false, true
+
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, dst, size, dscp = ip.SrcIP, ip.DstIP, int(ip.Length), int(ip.TOS>>2)
+		fragment = ip.Flags&layers.IPv4MoreFragments != 0 || ip.FragOffset > 0
+		firstFragment = ip.FragOffset == 0
+		fragID = src.String() + ">" + dst.String() + "#" + strconv.Itoa(int(ip.Id))
+		fragPayload = ip.Payload
+	case *layers.IPv6:
+		src, dst, size, dscp = ip.SrcIP, ip.DstIP, 40+int(ip.Length), int(ip.TrafficClass>>2)
+		if f, ok := packet.Layer(layers.LayerTypeIPv6Fragment).(*layers.IPv6Fragment); ok {
+			fragment, firstFragment = true, f.FragmentOffset == 0
+			fragID = src.String() + ">" + dst.String() + "#" + strconv.Itoa(int(f.Identification))
+			fragPayload = f.Payload
+		}
+	default:
+		return
+	}
+
+	var srcPort, dstPort, payload int
+	syn := false
+	switch t := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		srcPort, dstPort, payload = int(t.SrcPort), int(t.DstPort), len(t.Payload)
+		syn = t.SYN && !t.ACK
+	case *layers.UDP:
+		srcPort, dstPort, payload = int(t.SrcPort), int(t.DstPort), len(t.Payload)
+	case nil:
+		// gopacket does not decode transport headers inside fragments
+		if fragment && firstFragment && len(fragPayload) >= 4 {
+			srcPort = int(binary.BigEndian.Uint16(fragPayload[0:]))
+			dstPort = int(binary.BigEndian.Uint16(fragPayload[2:]))
+			payload = len(fragPayload)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var peer string
+	inbound := false
+	switch {
+	case w.ports[dstPort]:
+		peer, inbound = net.JoinHostPort(src.String(), strconv.Itoa(srcPort)), true
+	case w.ports[srcPort]:
+		peer = net.JoinHostPort(dst.String(), strconv.Itoa(dstPort))
+	case fragment && !firstFragment:
+		// No transport header: attribute via the first fragment
+		owner, ok := w.frags[fragID]
+		if !ok {
+			return
+		}
+		peer, inbound = owner.peer, owner.inbound
+	default:
+		return
+	}
+	if fragment && firstFragment {
+		w.frags[fragID] = fragOwner{peer, inbound}
+	}
+
+	s := w.peers[peer]
+	if s == nil {
+		s = &WireStats{Interface: w.iface}
+		w.peers[peer] = s
+	}
+	s.lastSeen = time.Now()
+	if fragment {
+		s.Fragments++
+	}
+	if inbound {
+		s.PacketsIn++
+		s.BytesIn += size
+		s.LargestIn = max(s.LargestIn, size)
+		if payload > 0 {
+			s.DataSegmentsIn++
+		}
+		if syn {
+			s.SYNDataIn = payload
+		}
+		if !slices.Contains(s.DSCPIn, dscp) {
+			s.DSCPIn = append(s.DSCPIn, dscp)
+		}
+	} else {
+		s.PacketsOut++
+		s.BytesOut += size
+		s.LargestOut = max(s.LargestOut, size)
+		if payload > 0 {
+			s.DataSegmentsOut++
+		}
+	}
+}
+
+// prune forgets peers whose reports were never collected.
+func (w *wireObserver) prune() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for peer, s := range w.peers {
+		if time.Since(s.lastSeen) > CAPTURE_MAXAGE {
+			delete(w.peers, peer)
+		}
+	}
+	if len(w.frags) > 10000 {
+		w.frags = make(map[string]fragOwner)
+	}
+}
+
+// collect returns and forgets the stats captured for peer.
+func (w *wireObserver) collect(peer string) *WireStats {
+	time.Sleep(CAPTURE_GRACE)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.peers[peer]
+	delete(w.peers, peer)
+	return s
+}
+
+// attachWireStats adds the captured packets to a report and compares them
+// with the predicted client flight.
+func attachWireStats(report *GhostReport) {
+	if wire == nil {
+		return
+	}
+	s := wire.collect(report.ClientIP)
+	if s == nil {
+		report.logger().Info("no packets captured", "iface", wire.iface)
+		return
+	}
+	report.Wire = s
+	lg := report.logger()
+	lg.Info("wire capture", "iface", s.Interface,
+		"packets_in", s.PacketsIn, "data_in", s.DataSegmentsIn, "largest_in", s.LargestIn,
+		"packets_out", s.PacketsOut, "data_out", s.DataSegmentsOut, "largest_out", s.LargestOut,
+		"ip_fragments", s.Fragments)
+	if slices.ContainsFunc(s.DSCPIn, func(d int) bool { return d != 0 }) {
+		lg.Info("DSCP marks on the client's packets", "dscp", s.DSCPIn)
+	}
+	if len(report.Flights) > 0 && report.Flights[0].Segments != s.DataSegmentsIn {
+		lg.Warn("client flight segments differ from the wire",
+			"predicted", report.Flights[0].Segments, "observed", s.DataSegmentsIn)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// startCapture opens an AF_PACKET socket on iface and feeds the observer.
+func startCapture(iface string) error {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+	proto := htons(syscall.ETH_P_ALL)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return fmt.Errorf("AF_PACKET socket (needs CAP_NET_RAW): %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: ifi.Index}); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	wire = &wireObserver{
+		iface: iface,
+		ports: listenerPorts(),
+		peers: make(map[string]*WireStats),
+		frags: make(map[string]fragOwner),
+	}
+	loopback := ifi.Flags&net.FlagLoopback != 0
+
+	go func() {
+		buffer := make([]byte, 65536+14)
+		lastPrune := time.Now()
+		for {
+			n, from, err := syscall.Recvfrom(fd, buffer, 0)
+			if err != nil {
+				slog.Error("wire capture stopped", "err", err)
+				return
+			}
+			// Loopback shows every packet twice; keep the receive copy
+			if ll, ok := from.(*syscall.SockaddrLinklayer); ok && loopback && ll.Pkttype == syscall.PACKET_OUTGOING {
+				continue
+			}
+			packet := gopacket.NewPacket(buffer[:n], layers.LayerTypeEthernet, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+			wire.observe(packet)
+
+			if time.Since(lastPrune) > CAPTURE_MAXAGE {
+				wire.prune()
+				lastPrune = time.Now()
+			}
+		}
+	}()
+	slog.Info("wire capture started", "iface", iface, "mtu", ifi.MTU)
+	return nil
+}
+
+func htons(v uint16) uint16 { return v<<8 | v>>8 }
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func startCapture(iface string) error {
+	return errors.New("wire capture is only supported on Linux")
+}
@@ -0,0 +1,353 @@
+/*
+Sentinel-PQC Proxy - Certificate Chain & Compression Model
+==========================================================
+The key share is only half of the PQC bloat. A TLS 1.3 server also sends its
+certificate chain, and with ML-DSA every certificate carries a ~2 KB public
+key and a ~3.3 KB signature.
+
+With -cert-chain the TLS scenarios build a real leaf + intermediate chain
+(DER, genuinely signed by its issuer) and encode the Certificate message
+with each RFC 8879 algorithm:
+
+  none | zlib (1) | brotli (2) | zstd (3)
+
+Compression only removes redundancy in names and extensions; keys and
+signatures are high-entropy, so it helps ECDSA chains proportionally far
+more than ML-DSA ones. The chain is modelled in the report, not sent, so the
+client's flight parsing is unchanged.
+
+Chains: ecdsa (P-256) and mldsa65 (Dilithium3, ML-DSA-65 sizes). SCTs and
+OCSP staples are not included.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/cloudflare/circl/sign/dilithium/mode3"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	TLS_HANDSHAKE_CERTIFICATE    = 11
+	TLS_HANDSHAKE_COMPRESSED_CRT = 25
+
+	CERT_LEAF_NAME = "sentinel-pqc.example"
+)
+
+var (
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidMLDSA65         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}
+
+	oidExtBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtExtKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtSubjectAltName   = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtSubjectKeyID     = asn1.ObjectIdentifier{2, 5, 29, 14}
+	oidExtAuthorityKeyID   = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidServerAuth          = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}
+)
+
+// certCompressors are the RFC 8879 CertificateCompressionAlgorithm codepoints.
+var certCompressors = []struct {
+	Name      string
+	Codepoint uint16
+	Compress  func([]byte) []byte
+}{
+	{"zlib", 1, compressZlib},
+	{"brotli", 2, compressBrotli},
+	{"zstd", 3, compressZstd},
+}
+
+// ============================================================================
+// CERTIFICATE FLIGHT
+// ============================================================================
+
+// modelCertificateFlight prices the configured chain (and the ECDSA baseline)
+// uncompressed and with every RFC 8879 algorithm.
+func modelCertificateFlight(chain string, report *GhostReport) error {
+	chains := []string{chain}
+	if chain != "ecdsa" {
+		chains = append(chains, "ecdsa")
+	}
+
+	sizes := make(map[string]int)
+	for _, name := range chains {
+		certs, err := buildCertChain(name)
+		if err != nil {
+			return err
+		}
+		msg := certificateMessage(certs)
+		report.CertCompression = append(report.CertCompression, CertCompression{
+			Chain: name, Algorithm: "none", Size: len(msg),
+		})
+		sizes[name+"/none"] = len(msg)
+
+		for _, c := range certCompressors {
+			compressed := compressedCertificateMessage(c.Codepoint, msg, c.Compress(msg))
+			report.CertCompression = append(report.CertCompression, CertCompression{
+				Chain: name, Algorithm: c.Name, Size: len(compressed), Saved: len(msg) - len(compressed),
+			})
+			sizes[name+"/"+c.Name] = len(compressed)
+		}
+	}
+
+	for _, c := range report.CertCompression {
+		report.logger().Debug("certificate compression", "chain", c.Chain, "algorithm", c.Algorithm, "bytes", c.Size, "saved", c.Saved)
+	}
+
+	best := report.CertCompression[1]
+	for _, c := range report.CertCompression[1 : len(certCompressors)+1] {
+		if c.Size < best.Size {
+			best = c
+		}
+	}
+	note := fmt.Sprintf("Certificate compression (%s) saves %d of %d bytes on the %s chain",
+		best.Algorithm, best.Saved, sizes[chain+"/none"], chain)
+	if chain != "ecdsa" {
+		note += fmt.Sprintf("; still %.1fx the compressed ECDSA chain", float64(best.Size)/float64(sizes["ecdsa/"+best.Algorithm]))
+	}
+	report.addNote(note)
+	return nil
+}
+
+// certificateMessage encodes a TLS 1.3 Certificate handshake message.
+func certificateMessage(certs [][]byte) []byte {
+	var list []byte
+	for _, c := range certs {
+		list = appendUint24(list, len(c))
+		list = append(list, c...)
+		list = append(list, 0, 0) // no per-certificate extensions
+	}
+	body := []byte{0} // empty certificate_request_context
+	body = appendUint24(body, len(list))
+	body = append(body, list...)
+
+	msg := appendUint24([]byte{TLS_HANDSHAKE_CERTIFICATE}, len(body))
+	return append(msg, body...)
+}
+
+// compressedCertificateMessage wraps a compressed Certificate (RFC 8879 4).
+func compressedCertificateMessage(algorithm uint16, plain, compressed []byte) []byte {
+	body := []byte{byte(algorithm >> 8), byte(algorithm)}
+	body = appendUint24(body, len(plain))
+	body = appendUint24(body, len(compressed))
+	body = append(body, compressed...)
+
+	msg := appendUint24([]byte{TLS_HANDSHAKE_COMPRESSED_CRT}, len(body))
+	return append(msg, body...)
+}
+
+func appendUint24(b []byte, n int) []byte {
+	return append(b, byte(n>>16), byte(n>>8), byte(n))
+}
+
+func compressZlib(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func compressBrotli(data []byte) []byte {
+	var buf bytes.Buffer
+	w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func compressZstd(data []byte) []byte {
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	defer enc.Close()
+	return enc.EncodeAll(data, nil)
+}
+
+// ============================================================================
+// CHAIN CONSTRUCTION
+// ============================================================================
+
+// certKey is a key pair that can appear in, and sign, a certificate.
+type certKey interface {
+	algorithm() pkix.AlgorithmIdentifier
+	publicKeyInfo() []byte
+	sign(tbs []byte) []byte
+}
+
+type ecdsaCertKey struct{ key *ecdsa.PrivateKey }
+
+func (k ecdsaCertKey) algorithm() pkix.AlgorithmIdentifier {
+	return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+}
+
+func (k ecdsaCertKey) publicKeyInfo() []byte {
+	spki, _ := x509.MarshalPKIXPublicKey(&k.key.PublicKey)
+	return spki
+}
+
+func (k ecdsaCertKey) sign(tbs []byte) []byte {
+	digest := sha256.Sum256(tbs)
+	sig, _ := ecdsa.SignASN1(rand.Reader, k.key, digest[:])
+	return sig
+}
+
+type mldsaCertKey struct {
+	pk *mode3.PublicKey
+	sk *mode3.PrivateKey
+}
+
+func (k mldsaCertKey) algorithm() pkix.AlgorithmIdentifier {
+	return pkix.AlgorithmIdentifier{Algorithm: oidMLDSA65}
+}
+
+func (k mldsaCertKey) publicKeyInfo() []byte {
+	pk := k.pk.Bytes()
+	spki, _ := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{k.algorithm(), asn1.BitString{Bytes: pk, BitLength: len(pk) * 8}})
+	return spki
+}
+
+func (k mldsaCertKey) sign(tbs []byte) []byte {
+	sig := make([]byte, mode3.SignatureSize)
+	mode3.SignTo(k.sk, tbs, sig)
+	return sig
+}
+
+func newCertKey(chain string) (certKey, error) {
+	switch chain {
+	case "ecdsa":
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		return ecdsaCertKey{key}, err
+	case "mldsa65":
+		pk, sk, err := mode3.GenerateKey(rand.Reader)
+		return mldsaCertKey{pk, sk}, err
+	}
+	return nil, fmt.Errorf("unknown certificate chain %q (available: ecdsa, mldsa65)", chain)
+}
+
+type tbsCertificate struct {
+	Version      int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber *big.Int
+	Signature    pkix.AlgorithmIdentifier
+	Issuer       pkix.RDNSequence
+	Validity     struct{ NotBefore, NotAfter time.Time }
+	Subject      pkix.RDNSequence
+	PublicKey    asn1.RawValue
+	Extensions   []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+// buildCertChain returns the leaf and intermediate certificates (DER) a
+// server would send; the root stays in the client's trust store.
+func buildCertChain(chain string) ([][]byte, error) {
+	var keys [3]certKey // root, intermediate, leaf
+	for i := range keys {
+		k, err := newCertKey(chain)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	names := []pkix.Name{
+		{Country: []string{"US"}, Organization: []string{"Sentinel-PQC"}, CommonName: "Sentinel-PQC Root CA"},
+		{Country: []string{"US"}, Organization: []string{"Sentinel-PQC"}, CommonName: "Sentinel-PQC Issuing CA 1"},
+		{CommonName: CERT_LEAF_NAME},
+	}
+
+	leaf, err := issueCertificate(keys[1], names[1], keys[2], names[2], false)
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := issueCertificate(keys[0], names[0], keys[1], names[1], true)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{leaf, intermediate}, nil
+}
+
+// issueCertificate signs a certificate for subject with the issuer's key.
+func issueCertificate(issuer certKey, issuerName pkix.Name, subject certKey, subjectName pkix.Name, isCA bool) ([]byte, error) {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tbs := tbsCertificate{
+		Version:      2,
+		SerialNumber: serial,
+		Signature:    issuer.algorithm(),
+		Issuer:       issuerName.ToRDNSequence(),
+		Subject:      subjectName.ToRDNSequence(),
+		PublicKey:    asn1.RawValue{FullBytes: subject.publicKeyInfo()},
+		Extensions:   certExtensions(issuer, subject, isCA),
+	}
+	tbs.Validity.NotBefore = now
+	tbs.Validity.NotAfter = now.AddDate(0, 0, 90)
+
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificate: %w", err)
+	}
+	sig := issuer.sign(tbsDER)
+	return asn1.Marshal(struct {
+		TBS       asn1.RawValue
+		Algorithm pkix.AlgorithmIdentifier
+		Signature asn1.BitString
+	}{asn1.RawValue{FullBytes: tbsDER}, issuer.algorithm(), asn1.BitString{Bytes: sig, BitLength: len(sig) * 8}})
+}
+
+// certExtensions mirrors a typical WebPKI profile (minus SCTs).
+func certExtensions(issuer, subject certKey, isCA bool) []pkix.Extension {
+	keyID := func(k certKey) []byte {
+		sum := sha1.Sum(k.publicKeyInfo())
+		return sum[:]
+	}
+	must := func(v any) []byte {
+		der, _ := asn1.Marshal(v)
+		return der
+	}
+
+	ski := must(keyID(subject))
+	aki := must(struct {
+		ID []byte `asn1:"optional,tag:0"`
+	}{keyID(issuer)})
+
+	if isCA {
+		return []pkix.Extension{
+			{Id: oidExtBasicConstraints, Critical: true, Value: must(struct {
+				IsCA bool `asn1:"optional"`
+				Len  int  `asn1:"optional,default:-1"`
+			}{true, 0})},
+			{Id: oidExtKeyUsage, Critical: true, Value: must(asn1.BitString{Bytes: []byte{0x06}, BitLength: 7})}, // certSign, cRLSign
+			{Id: oidExtSubjectKeyID, Value: ski},
+			{Id: oidExtAuthorityKeyID, Value: aki},
+		}
+	}
+	san := must([]asn1.RawValue{{Tag: 2, Class: asn1.ClassContextSpecific, Bytes: []byte(CERT_LEAF_NAME)}})
+	return []pkix.Extension{
+		{Id: oidExtKeyUsage, Critical: true, Value: must(asn1.BitString{Bytes: []byte{0x80}, BitLength: 1})}, // digitalSignature
+		{Id: oidExtExtKeyUsage, Value: must([]asn1.ObjectIdentifier{oidServerAuth})},
+		{Id: oidExtBasicConstraints, Critical: true, Value: must(struct{}{})},
+		{Id: oidExtSubjectKeyID, Value: ski},
+		{Id: oidExtAuthorityKeyID, Value: aki},
+		{Id: oidExtSubjectAltName, Value: san},
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Sentinel-PQC Proxy - Slack / Teams Notifications
+================================================
+-slack and -teams post a short summary to a channel whenever a handshake is
+at risk of fragmenting (fragmentation_risk, CRITICAL_RISK or a black hole):
+
+  go run . -slack https://hooks.slack.com/services/T000/B000/XXXX
+  go run . -teams https://example.webhook.office.com/webhookb2/...
+
+  :warning: GHOST DETECTED  CRITICAL_RISK
+  Algorithm  Kyber768        Size    1484 B (budget 1400 B)
+  Client     10.0.0.7        Listener :4433
+
+Slack gets an incoming-webhook message with blocks, Teams an Adaptive Card
+(incoming webhooks and Workflows accept both). To keep a flapping path from
+flooding the channel, each channel gets at most one post per
+-notify-interval; detections in between are counted and summarised in one
+follow-up post when the interval is over.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const CHAT_TIMEOUT = 10 * time.Second
+
+// chatFormatter renders the JSON body for one platform; note mentions the
+// detections held back since the previous post.
+type chatFormatter func(r GhostReport, note string) any
+
+type chatSink struct {
+	platform string
+	url      string
+	host     string
+	format   chatFormatter
+	interval time.Duration
+	client   *http.Client
+
+	mu         sync.Mutex
+	last       time.Time    // time of the last post
+	suppressed int          // detections held back since then
+	latest     *GhostReport // newest of them
+	timer      *time.Timer
+	closed     bool
+}
+
+func openChatSink(platform, target string, interval time.Duration) (*chatSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%s: %q is not an https webhook URL", platform, target)
+	}
+	s := &chatSink{
+		platform: platform,
+		url:      target,
+		host:     u.Host,
+		interval: interval,
+		client:   &http.Client{Timeout: CHAT_TIMEOUT},
+	}
+	switch platform {
+	case "slack":
+		s.format = slackMessage
+	case "teams":
+		s.format = teamsMessage
+	default:
+		return nil, fmt.Errorf("unknown chat platform %q", platform)
+	}
+	return s, nil
+}
+
+func (s *chatSink) Name() string { return s.platform + ":" + s.host }
+
+func (s *chatSink) notifier() string { return s.platform }
+
+// Write posts the report right away, or holds it back if the channel had a
+// post within the interval.
+func (s *chatSink) Write(r GhostReport) error {
+	if !r.fragmentationTrouble() {
+		return nil
+	}
+	return s.alert(r)
+}
+
+func (s *chatSink) alert(r GhostReport) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	if wait := s.interval - time.Since(s.last); wait > 0 {
+		s.suppressed++
+		s.latest = &r
+		if s.timer == nil {
+			s.timer = time.AfterFunc(wait, s.flush)
+		}
+		s.mu.Unlock()
+		return nil
+	}
+	s.last = time.Now()
+	s.mu.Unlock()
+	go s.post(r, 0)
+	return nil
+}
+
+// flush posts the newest held-back detection with the count of the others.
+func (s *chatSink) flush() {
+	s.mu.Lock()
+	s.timer = nil
+	latest, suppressed := s.latest, s.suppressed
+	s.latest, s.suppressed = nil, 0
+	if latest != nil {
+		s.last = time.Now()
+	}
+	s.mu.Unlock()
+	if latest != nil {
+		s.post(*latest, suppressed-1)
+	}
+}
+
+func (s *chatSink) post(r GhostReport, suppressed int) {
+	lg := r.logger()
+	note := ""
+	if suppressed > 0 {
+		note = fmt.Sprintf("+%d more detection(s) in the last %s", suppressed, s.interval)
+	}
+	body, err := json.Marshal(s.format(r, note))
+	if err != nil {
+		lg.Error(s.platform+": cannot encode message", "err", err)
+		return
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		lg.Error(s.platform+": post failed", "err", err)
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		lg.Error(s.platform+": post rejected", "status", resp.Status, "retry_after", resp.Header.Get("Retry-After"))
+		return
+	}
+	lg.Info(s.platform+": posted detection", "suppressed", suppressed)
+}
+
+// Close posts what is still held back.
+func (s *chatSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	pending := s.timer != nil && s.timer.Stop()
+	s.mu.Unlock()
+	if pending {
+		s.flush()
+	}
+	return nil
+}
+
+// ============================================================================
+// MESSAGES
+// ============================================================================
+
+// chatFacts are the summary lines both platforms show.
+func chatFacts(r GhostReport) [][2]string {
+	size := fmt.Sprintf("%d B", r.HandshakeSize)
+	if r.MTUBudget > 0 {
+		size += fmt.Sprintf(" (budget %d B)", r.MTUBudget)
+	}
+	facts := [][2]string{
+		{"Verdict", r.Status},
+		{"Algorithm", r.Algorithm},
+		{"Size", size},
+		{"Client", clientHost(r.ClientIP)},
+	}
+	if r.ServerHelloSize > 0 {
+		facts = append(facts, [2]string{"Server flight", fmt.Sprintf("%d B", r.ServerHelloSize)})
+	}
+	if r.Listener != "" {
+		facts = append(facts, [2]string{"Listener", r.Listener})
+	}
+	if r.alertRule != "" {
+		facts = append(facts, [2]string{"Rule", r.alertRule})
+	}
+	return facts
+}
+
+func slackMessage(r GhostReport, note string) any {
+	var fields []map[string]string
+	for _, f := range chatFacts(r) {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", f[0], f[1])})
+	}
+	blocks := []map[string]any{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": "⚠️ GHOST DETECTED: " + r.Status}},
+		{"type": "section", "fields": fields},
+		{"type": "context", "elements": []map[string]string{{"type": "mrkdwn", "text": r.Message}}},
+	}
+	if note != "" {
+		blocks = append(blocks, map[string]any{"type": "context", "elements": []map[string]string{{"type": "mrkdwn", "text": "_" + note + "_"}}})
+	}
+	return map[string]any{
+		"text":   fmt.Sprintf("GHOST DETECTED: %s %s, %d bytes from %s", r.Status, r.Algorithm, r.HandshakeSize, clientHost(r.ClientIP)),
+		"blocks": blocks,
+	}
+}
+
+func teamsMessage(r GhostReport, note string) any {
+	var facts []map[string]string
+	for _, f := range chatFacts(r) {
+		facts = append(facts, map[string]string{"title": f[0], "value": f[1]})
+	}
+	body := []map[string]any{
+		{"type": "TextBlock", "size": "Medium", "weight": "Bolder", "color": "Attention", "text": "⚠️ GHOST DETECTED: " + r.Status},
+		{"type": "FactSet", "facts": facts},
+		{"type": "TextBlock", "wrap": true, "isSubtle": true, "text": r.Message},
+	}
+	if note != "" {
+		body = append(body, map[string]any{"type": "TextBlock", "isSubtle": true, "text": note})
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Batch Mode
+==========
+-batch N turns the one-shot demo into a measurement run: N handshakes,
+-concurrency of them at a time, each with a fresh keypair and connection.
+The per-handshake output is left out; at the end the client reports how
+many completed and how the others ended, the ClientHello and ServerHello
+sizes, and the handshake latency from connecting to the shared secret as
+percentiles:
+
+  go run ./client -batch 500 -concurrency 20 -padding 300
+
+Batch and load mode (load.go) measure the ClientHello simulation; the modes that run an
+exchange of their own (SSH, Noise, IKEv2, sweep, middlebox) are not batched.
+*/
+
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const BATCH_TOP_ERRORS = 3 // distinct errors listed in the summary
+
+// BATCH_OUTCOMES label the outcomes other than completed in the summary.
+var BATCH_OUTCOMES = []struct{ outcome, label string }{
+	{OUTCOME_NO_REPLY, "No reply:"},
+	{OUTCOME_DOWNGRADED, "Downgraded:"},
+	{OUTCOME_DECAPSULATION, "Decap failed:"},
+	{OUTCOME_UNCONFIRMED, "Unconfirmed:"},
+	{OUTCOME_FAILED, "Failed:"},
+	{OUTCOME_ABORTED, "Aborted:"},
+}
+
+// runBatch runs total handshakes, concurrency at a time, and logs and
+// returns their summary.
+func runBatch(scheme kem.Scheme, total, concurrency int) batchSummary {
+	concurrency = min(concurrency, total)
+	log.Println()
+	log.Printf("[BATCH] %d handshakes, %d at a time...", total, concurrency)
+
+	jobs := make(chan struct{})
+	results := make([]handshakeResult, 0, total)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// The handshakes' own output would interleave; only the summary is shown
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				res := runHandshake(scheme)
+				mu.Lock()
+				results = append(results, res)
+				mu.Unlock()
+			}
+		}()
+	}
+	for range total {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+	log.SetOutput(out)
+
+	s := summarize(results, elapsed)
+	s.log("BATCH", fmt.Sprintf("%d at a time", concurrency))
+	return s
+}
+
+// batchSummary is how the handshakes of a batch or load run went.
+type batchSummary struct {
+	Handshakes  int                     `json:"handshakes"`
+	DurationMs  float64                 `json:"duration_ms"`
+	PerSecond   float64                 `json:"per_second"`
+	Outcomes    map[string]int          `json:"outcomes"`
+	Fragmented  int                     `json:"fragmented"`                 // completed, with a ClientHello over the budget
+	Families    map[string]int          `json:"address_families,omitempty"` // connections per address family
+	LatencyMs   *percentiles            `json:"latency_ms,omitempty"`
+	ClientHello *percentiles            `json:"client_hello_bytes,omitempty"`
+	ServerHello *percentiles            `json:"server_hello_bytes,omitempty"`
+	PhasesMs    map[string]*percentiles `json:"phases_ms,omitempty"` // per phase, phases.go
+	Errors      []errorCount            `json:"errors,omitempty"`    // the most frequent first
+
+	elapsed time.Duration
+}
+
+type percentiles struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+type errorCount struct {
+	Error string `json:"error"`
+	Count int    `json:"count"`
+}
+
+// percentilesOf is nil for no values.
+func percentilesOf(values []float64) *percentiles {
+	if len(values) == 0 {
+		return nil
+	}
+	slices.Sort(values)
+	return &percentiles{values[0], rank(values, 50), rank(values, 90), rank(values, 95), rank(values, 99), values[len(values)-1]}
+}
+
+// summarize sums up the results of a run that took elapsed.
+func summarize(results []handshakeResult, elapsed time.Duration) batchSummary {
+	s := batchSummary{
+		Handshakes: len(results),
+		DurationMs: durationMs(elapsed),
+		PerSecond:  float64(len(results)) / elapsed.Seconds(),
+		Outcomes:   make(map[string]int),
+		elapsed:    elapsed,
+	}
+	errs := make(map[string]int)
+	var sent, received, latency []float64
+	for _, r := range results {
+		s.Outcomes[r.Outcome]++
+		if f := familyName(addrFamily(r.Remote)); f != "" {
+			if s.Families == nil {
+				s.Families = make(map[string]int)
+			}
+			s.Families[f]++
+		}
+		if r.Outcome == OUTCOME_COMPLETED && r.fragmented() {
+			s.Fragmented++
+		}
+		if r.Err != nil {
+			errs[errorKind(r.Err)]++
+		}
+		if r.Sent > 0 {
+			sent = append(sent, float64(r.Sent))
+		}
+		if r.Received > 0 {
+			received = append(received, float64(r.Received))
+		}
+		if r.Latency > 0 {
+			latency = append(latency, durationMs(r.Latency))
+		}
+	}
+	s.LatencyMs, s.ClientHello, s.ServerHello = percentilesOf(latency), percentilesOf(sent), percentilesOf(received)
+	s.PhasesMs = phasePercentiles(results)
+	for msg, n := range errs {
+		s.Errors = append(s.Errors, errorCount{msg, n})
+	}
+	slices.SortFunc(s.Errors, func(a, b errorCount) int { return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Error, b.Error)) })
+	return s
+}
+
+// allCompleted reports whether every handshake of the run completed.
+func (s batchSummary) allCompleted() bool {
+	return s.Outcomes[OUTCOME_COMPLETED] == s.Handshakes
+}
+
+// log logs the summary of a batch or load run (tag); shape says how the
+// handshakes were started.
+func (s batchSummary) log(tag, shape string) {
+	pct := func(n int) string { return fmt.Sprintf("%d (%.1f%%)", n, float64(n)/float64(s.Handshakes)*100) }
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	title := tag + " RESULTS"
+	log.Printf("│%-45s│\n", strings.Repeat(" ", (45-len(title))/2)+title)
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Handshakes:     %-27s │\n", fmt.Sprintf("%d, %s", s.Handshakes, shape))
+	log.Printf("│ Duration:       %-27s │\n", fmt.Sprintf("%v (%.1f/s)", s.elapsed.Round(time.Millisecond), s.PerSecond))
+	log.Printf("│ Completed:      %-27s │\n", pct(s.Outcomes[OUTCOME_COMPLETED]))
+	if s.Fragmented > 0 {
+		log.Printf("│ Over Budget:    %-27s │\n", pct(s.Fragmented))
+	}
+	for _, o := range BATCH_OUTCOMES {
+		if s.Outcomes[o.outcome] > 0 {
+			log.Printf("│ %-15s %-27s │\n", o.label, pct(s.Outcomes[o.outcome]))
+		}
+	}
+	log.Println("└─────────────────────────────────────────────┘")
+
+	if len(s.Families) > 0 {
+		var families []string
+		for _, f := range []string{"4", "6"} {
+			if n := s.Families[familyName(f)]; n > 0 {
+				families = append(families, fmt.Sprintf("%s %d", familyLabel(f), n))
+			}
+		}
+		log.Printf("[%s] Address families:  %s", tag, strings.Join(families, ", "))
+	}
+	if l := s.LatencyMs; l != nil {
+		log.Printf("[%s] Latency ms:        min %.2f  p50 %.2f  p90 %.2f  p95 %.2f  p99 %.2f  max %.2f",
+			tag, l.Min, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+	for _, ph := range PHASES {
+		if p := s.PhasesMs[ph.name]; p != nil {
+			log.Printf("[%s] %-18s p50 %.3f  p95 %.3f  max %.3f", tag, ph.name+" ms:", p.P50, p.P95, p.Max)
+		}
+	}
+	for _, sizes := range []struct {
+		name string
+		p    *percentiles
+	}{{"ClientHello", s.ClientHello}, {"ServerHello", s.ServerHello}} {
+		if sizes.p != nil {
+			log.Printf("[%s] %s bytes: min %.0f  p50 %.0f  p95 %.0f  max %.0f", tag, sizes.name, sizes.p.Min, sizes.p.P50, sizes.p.P95, sizes.p.Max)
+		}
+	}
+	for _, e := range s.Errors[:min(len(s.Errors), BATCH_TOP_ERRORS)] {
+		log.Printf("[%s] ❌ %d× %s", tag, e.Count, e.Error)
+	}
+}
+
+// errorKind is an error without the addresses of the network error in it,
+// so the same failure on different connections is counted once.
+func errorKind(err error) string {
+	var op *net.OpError
+	if !errors.As(err, &op) {
+		return err.Error()
+	}
+	return strings.Replace(err.Error(), op.Error(), fmt.Sprintf("%s %s: %v", op.Op, op.Net, op.Err), 1)
+}
+
+// rank is the nearest-rank percentile p of sorted values.
+func rank[T cmp.Ordered](sorted []T, p int) T {
+	return sorted[(p*len(sorted)+99)/100-1]
+}
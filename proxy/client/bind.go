@@ -0,0 +1,157 @@
+/*
+Address Family, Interface & Source Address Selection
+====================================================
+On a multi-homed host the uplink a handshake takes decides which MTU it runs
+into. -source binds every connection to one local address and -interface
+pins it to an interface or VRF (SO_BINDTODEVICE, Linux), so each uplink can
+be tested on its own. On a dual-stack host -4 and -6 force the address
+family, so the IPv4 and IPv6 paths to the same proxy name can be compared
+from one host (IPv6 has a 20 bytes larger IP header and no fragmentation by
+routers). Every handshake reports the family and addresses it used.
+-dscp sets the QoS class of every packet the client sends.
+
+  go run ./client -target proxy.example:4433 -4 -json | jq .handshakes[0].address_family
+  go run ./client -target proxy.example:4433 -6 -source 2001:db8::10
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// checkAddressing validates -4, -6 and -source against each other and
+// against a -target address, and the -dscp value.
+func checkAddressing() error {
+	if *ipv4Only && *ipv6Only {
+		return errors.New("-4 and -6 exclude each other")
+	}
+	if *sourceAddr != "" {
+		ip := net.ParseIP(*sourceAddr)
+		if ip == nil {
+			return fmt.Errorf("-source %q is not an IP address", *sourceAddr)
+		}
+		if *ipv4Only && ip.To4() == nil || *ipv6Only && ip.To4() != nil {
+			return fmt.Errorf("-source %s is not an %s address", *sourceAddr, familyLabel(addressFamily()))
+		}
+	}
+	host, _, err := net.SplitHostPort(*targetAddr)
+	if err != nil {
+		return fmt.Errorf("-target: %w", err)
+	}
+	if ip := net.ParseIP(host); ip != nil && addressFamily() != "" && ipFamily(ip) != addressFamily() {
+		return fmt.Errorf("-target %s is not an %s address", *targetAddr, familyLabel(addressFamily()))
+	}
+	if *dscpMark < 0 || *dscpMark > 63 {
+		return errors.New("-dscp must be between 0 and 63")
+	}
+	return nil
+}
+
+// addressFamily is the family every connection must use, "4" or "6" from -4,
+// -6 or -source, or "" to let the resolver and routing table decide.
+func addressFamily() string {
+	switch {
+	case *ipv4Only:
+		return "4"
+	case *ipv6Only:
+		return "6"
+	case *sourceAddr != "":
+		return ipFamily(net.ParseIP(*sourceAddr))
+	}
+	return ""
+}
+
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "4"
+	}
+	return "6"
+}
+
+// familyLabel names a family for logs and results.
+func familyLabel(family string) string {
+	switch family {
+	case "4":
+		return "IPv4"
+	case "6":
+		return "IPv6"
+	}
+	return "any"
+}
+
+// sourceLabel says where connections leave from.
+func sourceLabel() string {
+	switch {
+	case *sourceAddr != "" && *bindInterface != "":
+		return *sourceAddr + " on " + *bindInterface
+	case *sourceAddr != "":
+		return *sourceAddr
+	case *bindInterface != "":
+		return *bindInterface
+	}
+	return "routing table"
+}
+
+// addrFamily is the family of a connection's address, "" when unknown.
+func addrFamily(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if ip := net.ParseIP(host); err == nil && ip != nil {
+		return ipFamily(ip)
+	}
+	return ""
+}
+
+// dialProxy connects to address over network ("tcp" or "udp") in the
+// selected address family.
+func dialProxy(network, address string) (net.Conn, error) {
+	return newDialer(network).Dial(network+addressFamily(), address)
+}
+
+// newDialer returns a dialer for the proxy honoring -timeout, -source,
+// -interface, -dscp, -df and, for TCP, -mss and -tfo.
+func newDialer(network string) *net.Dialer {
+	d := &net.Dialer{Timeout: *ioTimeout}
+	if *sourceAddr != "" {
+		ip := net.ParseIP(*sourceAddr)
+		if network == "udp" {
+			d.LocalAddr = &net.UDPAddr{IP: ip}
+		} else {
+			d.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if *bindInterface != "" {
+			if err := bindToDevice(c, *bindInterface); err != nil {
+				return fmt.Errorf("bind to %s: %w", *bindInterface, err)
+			}
+		}
+		if *dscpMark > 0 {
+			if err := setDSCP(c, network, *dscpMark); err != nil {
+				return fmt.Errorf("DSCP %d: %w", *dscpMark, err)
+			}
+		}
+		if *dfPolicy != "" {
+			if err := setDF(c, network, *dfPolicy); err != nil {
+				return fmt.Errorf("DF %s: %w", *dfPolicy, err)
+			}
+		}
+		if *tcpMSS != 0 && strings.HasPrefix(network, "tcp") {
+			if err := setMSS(c, *tcpMSS); err != nil {
+				return fmt.Errorf("MSS %d: %w", *tcpMSS, err)
+			}
+		}
+		if *tfoMode && strings.HasPrefix(network, "tcp") {
+			return fastOpenConnect(network, address, c)
+		}
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToDevice pins a socket to an interface or VRF (SO_BINDTODEVICE).
+func bindToDevice(c syscall.RawConn, dev string) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE, dev)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,686 @@
+/*
+Sentinel-PQC Test Client
+========================
+Simulates a browser/client performing a PQC (Kyber-768) key exchange.
+
+In TLS 1.3 with PQC:
+  1. Client generates a Kyber-768 keypair
+  2. Client sends Public Key in ClientHello (KeyShare extension)
+  3. Server encapsulates and sends Ciphertext in ServerHello
+  4. Both derive the same shared secret
+
+This client sends:
+  - Kyber-768 Public Key: 1184 bytes
+  - Simulated TLS Headers: configurable padding
+
+Flags drive a scenario from a script without recompiling:
+
+  go run ./client -padding 150             Total 1334 → SAFE (< 1400)
+  go run ./client -padding 300             Total 1484 → GHOST DETECTED (> 1400)
+  go run ./client -target 10.0.0.5:4433 -alg Kyber1024 -timeout 10s -repeat 20
+
+-repeat runs the handshakes one after another, each with a fresh keypair;
+-batch runs many of them concurrently and reports their statistics
+(batch.go); -rate holds a sustained load for a -duration (load.go);
+-sweep-padding finds the padding at which the proxy or the network give
+out (padsweep.go). -json prints the result of any of them as one JSON
+object instead of the logs (jsonout.go), and the exit code tells a safe
+handshake from fragmentation, a failed handshake and a network error
+(exitcode.go). -fallback retries a stalled handshake with smaller schemes
+down to classical X25519 (fallback.go). -suite runs the tests of a YAML
+file and reports which passed (suite.go), -fuzz sends malformed key shares
+to exercise the proxy's error paths (fuzz.go). The protocol modes (-ssh,
+-noise, -quic, ...) run against the proxy -scenario of the same name
+instead of the ClientHello simulation, one at a time.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/schemes"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+var (
+	targetAddr   = flag.String("target", "127.0.0.1:4433", "Proxy address, host:port")
+	paddingSize  = flag.Int("padding", 300, "Simulated TLS header bytes after the key share: 150 = SAFE, 300 = GHOST DETECTED at a 1400-byte budget")
+	helloProfile = flag.String("profile", "", "Fill the ClientHello like a browser: chrome, firefox or safari; without -padding its size is the browser's (hello.go)")
+	kemName      = flag.String("alg", "Kyber768", "KEM for the key share: Kyber512, Kyber768 or Kyber1024 (the proxy listener must accept it, see its alg= option)")
+	ioTimeout    = flag.Duration("timeout", 5*time.Second, "Connect timeout, and how long to wait for each answer of the proxy")
+	repeatCount  = flag.Int("repeat", 1, "Run the handshake this many times, one after another")
+	batchSize    = flag.Int("batch", 0, "Run this many handshakes and report their success rate, sizes and latency percentiles (batch.go)")
+	concurrency  = flag.Int("concurrency", 10, "Handshakes of a -batch run at a time")
+	loadRate     = flag.Float64("rate", 0, "Sustained load: start this many handshakes per second and report throughput and errors (load.go)")
+	loadFor      = flag.Duration("duration", 30*time.Second, "How long a -rate run lasts, ramp-up included")
+	loadRamp     = flag.Duration("ramp", 0, "Raise the rate linearly from zero to -rate over this first part of -duration")
+	maxInFlight  = flag.Int("max-inflight", 512, "Handshakes of a -rate run in flight at most; the ones due beyond are counted as missed")
+	statusURL    = flag.String("proxy-status", "", "The proxy's /status URL, e.g. http://127.0.0.1:9090/status: a -rate run reports the proxy's CPU, heap and goroutines, -sweep-padding its verdicts, a handshake its budget and verdict")
+	sweepRange   = flag.String("sweep-padding", "", "Find the padding at which the proxy flags the handshake, it segments and the network fails: MIN:MAX binary-searches, MIN:MAX:STEP tries every STEP bytes (padsweep.go)")
+	suitePath    = flag.String("suite", "", "Run the tests of a YAML file (algorithms, padding, impairments, expected verdicts) and report which passed (suite.go)")
+	fuzzCount    = flag.Int("fuzz", 0, "Send this many truncated, oversized, bit-flipped and garbage key shares each and report hangs, crashes and inconsistent verdicts of the proxy (fuzz.go)")
+	fallback     = flag.Bool("fallback", false, "When a handshake stalls, retry with the next smaller scheme down to classical X25519 and report which succeeded (fallback.go)")
+	jsonOut      = flag.Bool("json", false, "Print one JSON result object on stdout instead of the banner and logs (jsonout.go)")
+
+	// Multi-homed and dual-stack hosts (bind.go)
+	ipv4Only      = flag.Bool("4", false, "Connect to the proxy over IPv4 only")
+	ipv6Only      = flag.Bool("6", false, "Connect to the proxy over IPv6 only")
+	sourceAddr    = flag.String("source", "", "Local address every connection leaves from (default: the routing table decides)")
+	bindInterface = flag.String("interface", "", "Interface or VRF every connection leaves through (SO_BINDTODEVICE, Linux)")
+	dscpMark      = flag.Int("dscp", 0, "DSCP value (0-63) to mark every connection with, e.g. 46 for EF; compare with the proxy report's wire.dscp_in")
+
+	// OS fragmentation policy (sockopt.go)
+	dfPolicy = flag.String("df", "", "Path MTU discovery policy of every socket: do, probe, dont or want (default: the kernel's)")
+	tcpMSS   = flag.Int("mss", 0, "Clamp the TCP MSS of every connection to this many bytes (default: the route's)")
+
+	// ClientHello options
+	keyShare    = flag.String("key-share", "full", "Key-share strategy: full = ML-KEM share in the first ClientHello (1 RTT), predict = X25519 first, full share after a HelloRetryRequest (2 RTT, proxy -scenario hrr)")
+	echMode     = flag.Bool("ech", false, "Wrap an HPKE-encrypted inner hello in the outer hello (ech.go)")
+	echCompress = flag.Bool("ech-compress", false, "With -ech, reference the outer key share from the inner hello (ech_outer_extensions) instead of repeating it")
+	probeMTU    = flag.Bool("probe-mtu", false, "Measure the path to the proxy (DF probes and TCP MSS) before sending the key share and tell the proxy what was found (pathmtu.go)")
+	tfoMode     = flag.Bool("tfo", false, "Connect with TCP Fast Open so the ClientHello rides in the SYN once a cookie is cached, i.e. from the second run on (proxy -tfo, tfo.go)")
+
+	// Protocol modes, each for the proxy -scenario of the same name
+	sshMode       = flag.Bool("ssh", false, "Perform an OpenSSH-style hybrid key exchange instead of the ClientHello simulation (ssh.go)")
+	sshKex        = flag.String("ssh-kex", "mlkem768x25519-sha256", "Key exchange method of -ssh: mlkem768x25519-sha256 or sntrup761x25519-sha512@openssh.com")
+	noiseMode     = flag.Bool("noise", false, "Send a PQ-WireGuard handshake initiation over UDP instead of a TCP ClientHello (noise.go)")
+	ikev2Mode     = flag.Bool("ikev2", false, "Send an IKE_SA_INIT with an ML-KEM-768 Key Exchange payload over UDP (ikev2.go)")
+	quicMode      = flag.Bool("quic", false, "Send the ClientHello in padded QUIC Initial datagrams and report the datagrams sent and lost (quic.go)")
+	mqttMode      = flag.Bool("mqtt", false, "After the key exchange, send an MQTT CONNECT as a constrained device would (mqtt.go)")
+	smtpMode      = flag.Bool("smtp", false, "Negotiate STARTTLS before sending the ClientHello, as an MTA would (smtp.go)")
+	middleboxMode = flag.Bool("middlebox", false, "Send a probe ClientHello that detects TCP option stripping, TLS version intolerance and payload modification on the path (middlebox.go)")
+	natMode       = flag.Bool("nat", false, "Wait out the proxy's stalls for the whole ciphertext, then send a Finished so the proxy knows the connection survived (proxy -scenario stall, stall.go)")
+	sweepMTU      = flag.Bool("sweep-mtu", false, "Binary-search the largest hello that crosses the path in one segment without loss instead of one handshake; runs against the default kyber scenario (sweep.go)")
+)
+
+// ============================================================================
+// MAIN
+// ============================================================================
+
+func main() {
+	flag.Parse()
+	if *paddingSize < 0 || *ioTimeout <= 0 || *repeatCount < 1 || *batchSize < 0 || *fuzzCount < 0 || *concurrency < 1 {
+		log.Fatalf("[CLIENT] -padding, -batch and -fuzz must not be negative, -timeout must be positive and -repeat and -concurrency at least 1")
+	}
+	if *loadRate < 0 || *loadFor <= 0 || *loadRamp < 0 || *loadRamp > *loadFor || *maxInFlight < 1 {
+		log.Fatalf("[CLIENT] -rate must not be negative, -duration must be positive, -ramp at most -duration and -max-inflight at least 1")
+	}
+	if err := checkAddressing(); err != nil {
+		log.Fatalf("[CLIENT] %v", err)
+	}
+	if err := checkSocketOptions(); err != nil {
+		log.Fatalf("[CLIENT] %v", err)
+	}
+	if err := checkModes(); err != nil {
+		log.Fatalf("[CLIENT] %v", err)
+	}
+	if *helloProfile != "" {
+		p, ok := HELLO_PROFILES[*helloProfile]
+		if !ok {
+			log.Fatalf("[CLIENT] unknown -profile %q (want %s)", *helloProfile, strings.Join(profileNames(), ", "))
+		}
+		padding := false
+		flag.Visit(func(f *flag.Flag) { padding = padding || f.Name == "padding" })
+		if !padding {
+			*paddingSize = p.size(helloServerName())
+		}
+	}
+	var sweep paddingRange
+	if *sweepRange != "" {
+		var err error
+		if sweep, err = parsePaddingRange(*sweepRange); err != nil {
+			log.Fatalf("[CLIENT] %v", err)
+		}
+	}
+	modes := 0
+	for _, on := range []bool{*batchSize > 0, *loadRate > 0, *sweepRange != "", *repeatCount > 1, *suitePath != "", *fuzzCount > 0} {
+		if on {
+			modes++
+		}
+	}
+	if modes > 1 {
+		log.Fatalf("[CLIENT] -batch, -rate, -sweep-padding, -repeat, -suite and -fuzz exclude each other")
+	}
+	if mode := exchangeModes(); (*batchSize > 0 || *loadRate > 0 || *sweepRange != "" || *suitePath != "" || *fuzzCount > 0 || *fallback) && len(mode) > 0 {
+		log.Fatalf("[CLIENT] -batch, -rate, -sweep-padding, -suite, -fuzz and -fallback measure the ClientHello simulation; drop %s", mode[0])
+	}
+	if *fallback && (*batchSize > 0 || *loadRate > 0 || *sweepRange != "" || *suitePath != "" || *fuzzCount > 0) {
+		log.Fatalf("[CLIENT] -fallback retries single handshakes; it does not go with -batch, -rate, -sweep-padding, -suite or -fuzz")
+	}
+	if *jsonOut {
+		log.SetOutput(io.Discard)
+	} else {
+		printBanner()
+	}
+	result := clientResult{Target: *targetAddr, Algorithm: *kemName, Profile: *helloProfile, Padding: *paddingSize, Mode: "handshake",
+		Family: familyName(addressFamily()), Source: *sourceAddr, Interface: *bindInterface, DF: *dfPolicy, MSS: *tcpMSS}
+
+	// 1. Initialize the KEM scheme (Kyber-768 by default)
+	scheme := schemes.ByName(*kemName)
+	if scheme == nil {
+		result.fail("%v", fmt.Errorf("Failed to load %s scheme", *kemName))
+	}
+	result.Algorithm = scheme.Name()
+
+	log.Printf("[CLIENT] Algorithm: %s", scheme.Name())
+	log.Printf("[CLIENT] Target: %s", *targetAddr)
+	if addressFamily() != "" || *bindInterface != "" {
+		log.Printf("[CLIENT] Address family: %s, source: %s", familyLabel(addressFamily()), sourceLabel())
+	}
+	if socketOptionsSet() {
+		log.Printf("[CLIENT] Socket: %s", socketLabel())
+	}
+
+	if *batchSize > 0 {
+		s := runBatch(scheme, *batchSize, *concurrency)
+		result.Mode, result.Batch, result.OK = "batch", &s, s.allCompleted()
+		result.finish(s.exitCode())
+	}
+	if *sweepRange != "" {
+		result.Mode = "padding_sweep"
+		s, err := runPaddingSweep(scheme, sweep, *statusURL)
+		if err != nil {
+			result.fail("❌ Padding sweep failed: %v", err)
+		}
+		result.Sweep, result.OK = &s, true
+		result.finish(EXIT_OK)
+	}
+	if *suitePath != "" {
+		result.Mode = "suite"
+		s, err := runSuite(*suitePath)
+		if err != nil {
+			result.fail("❌ Suite failed: %v", err)
+		}
+		result.Suite, result.OK = &s, s.Failed == 0
+		result.finish(s.exitCode())
+	}
+	if *fuzzCount > 0 {
+		result.Mode = "fuzz"
+		s, err := runFuzz(scheme, *fuzzCount, *statusURL)
+		if err != nil {
+			result.fail("❌ Fuzzing failed: %v", err)
+		}
+		result.Fuzz, result.OK = &s, s.found() == 0
+		result.finish(s.exitCode())
+	}
+	if *loadRate > 0 {
+		s := runLoad(scheme, *loadRate, *loadFor, *loadRamp, *maxInFlight, *statusURL)
+		result.Mode, result.Load, result.OK = "load", &s, s.allCompleted() && s.Missed == 0
+		result.finish(s.exitCode())
+	}
+
+	if *repeatCount > 1 {
+		result.Mode = "repeat"
+	}
+	aborted, code := 0, EXIT_OK
+	result.OK = true
+	for i := 1; i <= *repeatCount; i++ {
+		if *repeatCount > 1 {
+			log.Println()
+			log.Printf("[CLIENT] Handshake %d of %d", i, *repeatCount)
+		}
+		log.Println()
+		var res handshakeResult
+		if *fallback {
+			res = runWithFallback(scheme)
+		} else {
+			res = runHandshake(scheme)
+		}
+		if *statusURL != "" {
+			res.takeVerdict(*statusURL)
+		}
+		if res.Outcome == OUTCOME_ABORTED {
+			log.Printf("❌ %v", res.Err)
+			aborted++
+		}
+		code = max(code, res.exitCode())
+		result.Handshakes = append(result.Handshakes, res)
+		result.OK = result.OK && res.Outcome == OUTCOME_COMPLETED
+	}
+	if *repeatCount > 1 {
+		log.Println()
+		log.Printf("[CLIENT] %d handshakes, %d aborted", *repeatCount, aborted)
+	}
+	result.finish(code)
+}
+
+// exchangeModes names the flags set that replace the ClientHello simulation
+// with an exchange of their own.
+func exchangeModes() []string {
+	var names []string
+	for _, m := range []struct {
+		name string
+		on   bool
+	}{{"-ssh", *sshMode}, {"-noise", *noiseMode}, {"-ikev2", *ikev2Mode}, {"-quic", *quicMode}, {"-sweep-mtu", *sweepMTU}, {"-middlebox", *middleboxMode}} {
+		if m.on {
+			names = append(names, m.name)
+		}
+	}
+	return names
+}
+
+// checkModes validates the protocol modes and ClientHello options against
+// each other.
+func checkModes() error {
+	if mode := exchangeModes(); len(mode) > 1 {
+		return fmt.Errorf("%s exclude each other", strings.Join(mode, " and "))
+	}
+	if *keyShare != "full" && *keyShare != "predict" {
+		return fmt.Errorf("unknown -key-share %q (want full or predict)", *keyShare)
+	}
+	if *sshKex != "mlkem768x25519-sha256" && *sshKex != "sntrup761x25519-sha512@openssh.com" {
+		return fmt.Errorf("unknown -ssh-kex %q (want mlkem768x25519-sha256 or sntrup761x25519-sha512@openssh.com)", *sshKex)
+	}
+	if *echCompress && !*echMode {
+		return errors.New("-ech-compress needs -ech")
+	}
+	return nil
+}
+
+// Outcomes of a handshake.
+const (
+	OUTCOME_COMPLETED     = "completed"
+	OUTCOME_ABORTED       = "aborted" // could not be attempted: connect, send, ...
+	OUTCOME_NO_REPLY      = "no_server_hello"
+	OUTCOME_DOWNGRADED    = "downgraded"
+	OUTCOME_DECAPSULATION = "decapsulation_failed"
+	OUTCOME_UNCONFIRMED   = "key_unconfirmed" // the proxy did not prove the same secret
+	OUTCOME_FAILED        = "failed"          // the exchange after the shared secret, or a mode's
+)
+
+// handshakeResult is how one handshake went.
+type handshakeResult struct {
+	Outcome     string
+	Sent        int           // ClientHello bytes
+	Received    int           // ServerHello bytes
+	Latency     time.Duration // from connecting to the shared secret
+	Budget      int           // payload bytes that fit in one packet, 0 = not a ClientHello
+	ProxyStatus string        // the proxy's verdict with -proxy-status, "" = unknown
+	Confirmed   bool          // both sides proved the same shared secret
+	Err         error         // what stopped it
+
+	Local  net.Addr // the connection's ends, nil for the modes that dial their own
+	Remote net.Addr
+
+	Phases *handshakePhases // time per phase (phases.go), nil for the modes
+
+	MSS      int // the connection's send MSS, 0 = unknown
+	Segments int // TCP data segments the ClientHello took, 0 = unknown
+
+	Algorithm string            // with -fallback: the level that ran last
+	Attempts  []fallbackAttempt // with -fallback: every level tried
+}
+
+func abortHandshake(err error) handshakeResult {
+	return handshakeResult{Outcome: OUTCOME_ABORTED, Err: err}
+}
+
+// modeResult is the result of a mode that runs its own exchange.
+func modeResult(err error) handshakeResult {
+	if err != nil {
+		return handshakeResult{Outcome: OUTCOME_FAILED, Err: err}
+	}
+	return handshakeResult{Outcome: OUTCOME_COMPLETED}
+}
+
+// runHandshake performs one handshake with a fresh keypair. A handshake
+// that could not be attempted is OUTCOME_ABORTED; one the proxy or the path
+// broke is logged and has the outcome of where it stopped.
+func runHandshake(scheme kem.Scheme) handshakeResult {
+	// 2. Generate Keypair (simulating browser's ephemeral key)
+	log.Printf("[CRYPTO] Generating %s keypair...", scheme.Name())
+	var phases handshakePhases
+	phaseStart := time.Now()
+	pk, sk, err := scheme.GenerateKeyPair()
+	if err != nil {
+		return abortHandshake(fmt.Errorf("KeyGen failed: %w", err))
+	}
+	phases.Keygen = time.Since(phaseStart)
+
+	// Marshal public key to bytes
+	pkBytes, err := pk.MarshalBinary()
+	if err != nil {
+		return abortHandshake(fmt.Errorf("Failed to marshal public key: %w", err))
+	}
+
+	log.Printf("[CRYPTO] Public Key generated: %d bytes", len(pkBytes))
+	log.Printf("[CRYPTO] Secret Key stored locally for decapsulation")
+
+	if *noiseMode {
+		err := runNoiseHandshake(scheme, *targetAddr)
+		if err != nil {
+			log.Printf("❌ Noise handshake failed: %v", err)
+		}
+		return modeResult(err)
+	}
+
+	if *ikev2Mode {
+		err := runIKEv2Handshake(scheme, *targetAddr)
+		if err != nil {
+			log.Printf("❌ IKEv2 handshake failed: %v", err)
+		}
+		return modeResult(err)
+	}
+
+	if *quicMode {
+		err := runQUICHandshake(scheme, *targetAddr)
+		if err != nil {
+			log.Printf("❌ QUIC handshake failed: %v", err)
+		}
+		return modeResult(err)
+	}
+
+	if *sweepMTU {
+		err := runSweep(scheme, pkBytes, sk, *targetAddr)
+		if err != nil {
+			log.Printf("❌ MTU sweep failed: %v", err)
+		}
+		return modeResult(err)
+	}
+
+	if *middleboxMode {
+		err := runMiddleboxProbe(pkBytes, *targetAddr)
+		if err != nil {
+			log.Printf("❌ Middlebox probe failed: %v", err)
+		}
+		return modeResult(err)
+	}
+
+	// 3. Connect to Proxy
+	log.Println()
+	log.Printf("[NETWORK] Connecting to %s...", *targetAddr)
+
+	start := time.Now()
+	conn, err := dialProxy("tcp", *targetAddr)
+	if err != nil {
+		return abortHandshake(fmt.Errorf("Connection failed: %w", err))
+	}
+	defer conn.Close()
+	phases.Connect = time.Since(start)
+
+	log.Printf("[NETWORK] ✅ Connected! (%s, %s → %s)", familyLabel(addrFamily(conn.RemoteAddr())), conn.LocalAddr(), conn.RemoteAddr())
+
+	if *smtpMode {
+		if err := smtpStartTLS(conn); err != nil {
+			return abortHandshake(fmt.Errorf("SMTP STARTTLS failed: %w", err))
+		}
+	}
+
+	if *sshMode {
+		err := runSSHHandshake(conn, scheme, *sshKex)
+		if err != nil {
+			log.Printf("❌ SSH handshake failed: %v", err)
+		}
+		return modeResult(err)
+	}
+
+	// 4. Build ClientHello simulation
+	// Real TLS ClientHello contains:
+	//   - Protocol version, random bytes
+	//   - Cipher suites, extensions
+	//   - Key Share extension with PQC public key
+	// We simulate with: PK + the other extensions of a browser (hello.go)
+
+	padding := helloPadding(*paddingSize)
+
+	var probe *pathProbe
+	if *probeMTU {
+		p := probePath(conn)
+		probe = &p
+		ext := p.extension()
+		padding = append(ext, helloPadding(max(*paddingSize-len(ext), 0))...)[:*paddingSize]
+	}
+
+	payload := append(pkBytes, padding...)
+
+	var ech echBreakdown
+	if *echMode {
+		var echExt []byte
+		echExt, ech, err = buildECHExtension(pkBytes, *paddingSize, *echCompress)
+		if err != nil {
+			return abortHandshake(fmt.Errorf("ECH construction failed: %w", err))
+		}
+		payload = append(payload, echExt...)
+	}
+	totalSize := len(payload)
+	res := handshakeResult{Sent: totalSize, Budget: STATIC_BUDGET, Local: conn.LocalAddr(), Remote: conn.RemoteAddr(), Phases: &phases}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│          CLIENTHELLO SIMULATION             │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Public Key:     %-27s │\n", fmt.Sprintf("%d bytes", len(pkBytes)))
+	log.Printf("│ TLS Headers:    %-27s │\n", fmt.Sprintf("%d bytes (%s)", *paddingSize, profileLabel()))
+	if *echMode {
+		log.Printf("│ ECH Inner:      %-27s │\n", fmt.Sprintf("%d bytes (padded %d)", ech.InnerSize, ech.PaddedInner))
+		log.Printf("│ ECH HPKE enc:   %-27s │\n", fmt.Sprintf("%d bytes", ech.EncSize))
+		log.Printf("│ ECH Payload:    %-27s │\n", fmt.Sprintf("%d bytes (sealed)", ech.CiphertextLen))
+		log.Printf("│ ECH Extension:  %-27s │\n", fmt.Sprintf("%d bytes", ech.ExtensionSize))
+	}
+	log.Printf("│ Total Payload:  %-27s │\n", fmt.Sprintf("%d bytes", totalSize))
+	log.Println("└─────────────────────────────────────────────┘")
+
+	if probe != nil {
+		logPathProbe(*probe, totalSize)
+		res.Budget = probe.budget()
+	} else if totalSize > STATIC_BUDGET {
+		log.Println()
+		log.Println("⚠️  WARNING: Payload exceeds 1400 bytes - fragmentation expected!")
+	}
+
+	var predictSent, predictRecv int
+	if *keyShare == "predict" {
+		predictSent, predictRecv, err = sendPredictedHello(conn, *paddingSize)
+		if err != nil {
+			return abortHandshake(fmt.Errorf("Key-share prediction failed: %w", err))
+		}
+	}
+
+	// 5. Send ClientHello
+	log.Println()
+	log.Printf("[SEND] Sending ClientHello (%d bytes)...", totalSize)
+
+	res.MSS, _ = connMSS(conn)
+	segmentsBefore, _ := segmentsSent(conn)
+	phaseStart = time.Now()
+	_, err = conn.Write(payload)
+	if err != nil {
+		return abortHandshake(fmt.Errorf("Send failed: %w", err))
+	}
+	phases.Send = time.Since(phaseStart)
+	phaseStart = time.Now()
+	log.Printf("[SEND] ✅ ClientHello sent successfully")
+
+	// 6. Wait for ServerHello (Ciphertext)
+	log.Println()
+	log.Println("[RECV] Waiting for ServerHello (ciphertext)...")
+
+	buffer := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(*ioTimeout))
+
+	var n int
+	if *natMode {
+		n, err = readStalledFlight(conn, buffer[:scheme.CiphertextSize()])
+	} else {
+		n, err = conn.Read(buffer)
+	}
+	phases.Wait = time.Since(phaseStart)
+	if segments, _ := segmentsSent(conn); segments > segmentsBefore {
+		res.Segments = segments - segmentsBefore
+	}
+	if socketOptionsSet() && res.Segments > 0 {
+		log.Printf("[SEND] ClientHello took %d TCP segment(s) at an MSS of %d bytes", res.Segments, res.MSS)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to receive ServerHello: %v", err)
+		log.Println("   This could indicate:")
+		log.Println("   - Proxy rejected the connection")
+		log.Println("   - Network dropped fragmented packets")
+		log.Println("   - Firewall/NAT interference")
+		res.Outcome, res.Err = OUTCOME_NO_REPLY, err
+		return res
+	}
+
+	ciphertext := buffer[:n]
+	res.Received = n
+	log.Printf("[RECV] ✅ Received ServerHello: %d bytes", len(ciphertext))
+	if *tfoMode {
+		logFastOpen(conn, totalSize)
+	}
+
+	// A classical TLS 1.2 ServerHello instead of a KEM ciphertext means the
+	// server (or a middlebox) downgraded us: no PQC key exchange is possible.
+	if isTLS12ServerHello(ciphertext) {
+		reportDowngrade()
+		res.Outcome, res.Err = OUTCOME_DOWNGRADED, errors.New("TLS 1.2 ServerHello")
+		return res
+	}
+
+	// 7. Decapsulate (derive shared secret)
+	log.Println()
+	log.Println("[CRYPTO] Decapsulating to derive shared secret...")
+
+	phaseStart = time.Now()
+	ss, err := scheme.Decapsulate(sk, ciphertext)
+	phases.Decapsulate = time.Since(phaseStart)
+	if err != nil {
+		log.Printf("❌ Decapsulation failed: %v", err)
+		res.Outcome, res.Err = OUTCOME_DECAPSULATION, err
+		return res
+	}
+	res.Latency = time.Since(start)
+
+	log.Printf("[CRYPTO] ✅ Shared secret derived: %d bytes", len(ss))
+	log.Printf("[CRYPTO] First 8 bytes: %x", ss[:8])
+
+	if *keyShare == "predict" {
+		logStrategyComparison(predictSent, predictRecv, totalSize, len(ciphertext))
+	}
+
+	// 8. Key confirmation (the Finished of NAT mode, after the proxy's stalls)
+	wait := *ioTimeout
+	if *natMode {
+		wait = NAT_IDLE_WAIT
+	}
+	phaseStart = time.Now()
+	err = confirmKey(conn, ss, pkBytes, ciphertext, wait)
+	phases.Confirm = time.Since(phaseStart)
+	if err != nil {
+		log.Printf("❌ Key confirmation failed: %v", err)
+		res.Outcome, res.Err = OUTCOME_UNCONFIRMED, err
+		return res
+	}
+	res.Confirmed = true
+	phases.log()
+
+	if *mqttMode {
+		if err := mqttConnect(conn); err != nil {
+			log.Printf("❌ MQTT CONNECT failed: %v", err)
+			res.Outcome, res.Err = OUTCOME_FAILED, err
+			return res
+		}
+	}
+
+	// 9. Success summary
+	log.Println()
+	log.Println("╔═══════════════════════════════════════════════════════════════════╗")
+	log.Println("║              🎉 PQC HANDSHAKE SIMULATION COMPLETE                 ║")
+	log.Println("╠═══════════════════════════════════════════════════════════════════╣")
+	log.Println("║  Both sides proved they hold the same secret key.                 ║")
+	log.Println("║  In a real TLS session, this would be used for AES encryption.    ║")
+	log.Println("╚═══════════════════════════════════════════════════════════════════╝")
+	res.Outcome = OUTCOME_COMPLETED
+	return res
+}
+
+// ============================================================================
+// DOWNGRADE DETECTION
+// ============================================================================
+
+// isTLS12ServerHello reports whether the server flight is a TLS 1.2 ServerHello
+// record. TLS 1.3 servers also send legacy_version 0x0303 but always include the
+// supported_versions extension, so a ServerHello without it is TLS 1.2.
+func isTLS12ServerHello(data []byte) bool {
+	// Record header: type(1) version(2) length(2)
+	if len(data) < 5 || data[0] != 0x16 || data[1] != 0x03 {
+		return false
+	}
+	recordLen := int(binary.BigEndian.Uint16(data[3:5]))
+	if len(data) != 5+recordLen {
+		return false
+	}
+
+	// Handshake header: type(1) length(3), then ServerHello body
+	hs := data[5:]
+	if len(hs) < 4+38 || hs[0] != 0x02 {
+		return false
+	}
+	body := hs[4:]
+	if binary.BigEndian.Uint16(body[0:2]) != 0x0303 {
+		return false
+	}
+
+	// Skip random(32), session_id, cipher_suite(2), compression(1)
+	pos := 34
+	pos += 1 + int(body[pos])
+	pos += 3
+	if pos > len(body) {
+		return false
+	}
+	if pos == len(body) {
+		return true // No extensions at all
+	}
+
+	if pos+2 > len(body) {
+		return false
+	}
+	extEnd := pos + 2 + int(binary.BigEndian.Uint16(body[pos:pos+2]))
+	pos += 2
+	for pos+4 <= extEnd && extEnd <= len(body) {
+		extType := binary.BigEndian.Uint16(body[pos : pos+2])
+		extLen := int(binary.BigEndian.Uint16(body[pos+2 : pos+4]))
+		if extType == 0x002b { // supported_versions
+			return false
+		}
+		pos += 4 + extLen
+	}
+	return true
+}
+
+func reportDowngrade() {
+	log.Println()
+	log.Println("╔═══════════════════════════════════════════════════════════════════╗")
+	log.Println("║              🚫 PQC_IMPOSSIBLE: TLS 1.2 NEGOTIATED                ║")
+	log.Println("╠═══════════════════════════════════════════════════════════════════╣")
+	log.Println("║  The server answered with a TLS 1.2 ServerHello and ignored the   ║")
+	log.Println("║  Kyber-768 key share. TLS 1.2 has no post-quantum key exchange.   ║")
+	log.Println("║  If the server supports TLS 1.3, a middlebox is stripping it.     ║")
+	log.Println("╚═══════════════════════════════════════════════════════════════════╝")
+}
+
+// ============================================================================
+// UI HELPERS
+// ============================================================================
+
+func printBanner() {
+	banner := `
+╔═══════════════════════════════════════════════════════════════════╗
+║                  SENTINEL-PQC TEST CLIENT                         ║
+║           Kyber-768 Handshake Simulation Tool                     ║
+╚═══════════════════════════════════════════════════════════════════╝
+`
+	fmt.Println(banner)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// serverHello builds a ServerHello record with the given version and
+// extensions, each extension a type followed by its data.
+func serverHello(version uint16, exts ...[]byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, version)
+	body = append(body, make([]byte, 32)...) // random
+	body = append(body, 0)                   // session_id
+	body = append(body, 0x13, 0x01, 0)       // cipher_suite, compression
+	if exts != nil {
+		var list []byte
+		for _, e := range exts {
+			list = append(list, e[:2]...)
+			list = binary.BigEndian.AppendUint16(list, uint16(len(e)-2))
+			list = append(list, e[2:]...)
+		}
+		body = binary.BigEndian.AppendUint16(body, uint16(len(list)))
+		body = append(body, list...)
+	}
+	hs := []byte{0x02, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	hs = append(hs, body...)
+	rec := []byte{0x16, 0x03, 0x03}
+	rec = binary.BigEndian.AppendUint16(rec, uint16(len(hs)))
+	return append(rec, hs...)
+}
+
+func TestIsTLS12ServerHello(t *testing.T) {
+	supportedVersions := []byte{0x00, 0x2b, 0x03, 0x04}
+	renegotiationInfo := []byte{0xff, 0x01, 0x00}
+	tls12 := serverHello(0x0303)
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"no extensions", tls12, true},
+		{"TLS 1.2 extensions", serverHello(0x0303, renegotiationInfo), true},
+		{"TLS 1.3 supported_versions", serverHello(0x0303, supportedVersions), false},
+		{"supported_versions after others", serverHello(0x0303, renegotiationInfo, supportedVersions), false},
+		{"TLS 1.1 body", serverHello(0x0302), false},
+		{"alert record", append([]byte{0x15}, tls12[1:]...), false},
+		{"not a ServerHello", append(append([]byte{}, tls12[:5]...), append([]byte{0x01}, tls12[6:]...)...), false},
+		{"truncated", tls12[:len(tls12)-1], false},
+		{"trailing bytes", append(append([]byte{}, tls12...), 0), false},
+		{"record header only", tls12[:5], false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTLS12ServerHello(tt.data); got != tt.want {
+				t.Errorf("isTLS12ServerHello() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
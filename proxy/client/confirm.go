@@ -0,0 +1,62 @@
+/*
+Key Confirmation
+================
+A shared secret on the client's side alone proves nothing: after
+decapsulating, the client proves to the proxy that it holds the secret and
+checks the proxy's proof in return (keyconfirm.go of the proxy), as the two
+Finished messages of TLS do:
+
+  client -> proxy  HMAC-SHA256(ss, "sentinel-pqc client confirm" || pk || ct)
+  proxy -> client  HMAC-SHA256(ss, "sentinel-pqc server confirm" || pk || ct)
+
+Only a confirmed handshake counts as complete. The proxy answers a matching
+MAC only, so a proxy that closes instead derived another secret (or
+predates key confirmation); its report says which.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"syscall"
+	"time"
+)
+
+// keyConfirmation is the MAC one side sends over the exchange.
+func keyConfirmation(ss, pk, ct []byte, label string) []byte {
+	mac := hmac.New(sha256.New, ss)
+	mac.Write([]byte(label))
+	mac.Write(pk)
+	mac.Write(ct)
+	return mac.Sum(nil)
+}
+
+// confirmKey sends the client's key confirmation and waits up to wait for
+// the proxy's.
+func confirmKey(conn net.Conn, ss, pk, ct []byte, wait time.Duration) error {
+	log.Println()
+	log.Println("[CONFIRM] Sending key confirmation...")
+	if _, err := conn.Write(keyConfirmation(ss, pk, ct, "sentinel-pqc client confirm")); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+
+	got := make([]byte, sha256.Size)
+	conn.SetReadDeadline(time.Now().Add(wait))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+			return errors.New("the proxy closed without confirming: the shared secrets differ, or the proxy predates key confirmation")
+		}
+		return fmt.Errorf("no confirmation from the proxy: %w", err)
+	}
+	if !hmac.Equal(got, keyConfirmation(ss, pk, ct, "sentinel-pqc server confirm")) {
+		return errors.New("the proxy's confirmation does not match: the shared secrets differ")
+	}
+	log.Println("[CONFIRM] ✅ Key confirmed: the proxy holds the same shared secret")
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+Datagram Retransmission
+=======================
+UDP handshakes (-noise, -ikev2) retransmit their first message the
+way IKEv2 does (RFC 7296 section 2.1): the whole datagram again, with the
+wait doubling each time. Against a proxy running -frag-loss this produces
+the retransmit pattern of a real path that drops IP fragments. -quic
+retransmits only the unacknowledged datagrams, with the same waits.
+*/
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	DATAGRAM_RETRANSMITS    = 4               // retransmissions after the first send
+	DATAGRAM_INITIAL_WAIT   = 1 * time.Second // doubled after every retransmission
+	DATAGRAM_RESPONSE_LIMIT = 65535
+)
+
+// exchangeDatagram sends msg and waits for the response, retransmitting on
+// silence. It returns the response and how many sends it took.
+func exchangeDatagram(conn net.Conn, msg []byte) ([]byte, int, error) {
+	buffer := make([]byte, DATAGRAM_RESPONSE_LIMIT)
+	wait := DATAGRAM_INITIAL_WAIT
+	for attempt := 1; ; attempt++ {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, attempt, err
+		}
+		conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(buffer)
+		if err == nil {
+			return buffer[:n], attempt, nil
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) || attempt > DATAGRAM_RETRANSMITS {
+			return nil, attempt, err
+		}
+		log.Printf("[RETRY] No response after %s, retransmitting (%d bytes, attempt %d)", wait, len(msg), attempt+1)
+		wait *= 2
+	}
+}
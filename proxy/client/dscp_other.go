@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setDSCP(c syscall.RawConn, network string, dscp int) error {
+	return errors.New("DSCP marking is only supported on Linux")
+}
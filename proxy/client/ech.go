@@ -0,0 +1,107 @@
+/*
+Encrypted ClientHello (ECH) Size Model
+======================================
+With ECH the real (inner) ClientHello is HPKE-encrypted and carried inside the
+encrypted_client_hello extension of a public (outer) ClientHello. Both hellos
+travel in the same flight, so ECH stacks on top of the ML-KEM key share and
+frequently pushes the first flight over the MTU.
+
+Wire layout modelled here (draft-ietf-tls-esni):
+
+  ECHClientHello (outer, extension type 0xfe0d):
+    type(1) = 0 (outer)
+    cipher_suite(4) = HKDF-SHA256 / AES-128-GCM
+    config_id(1)
+    enc<2>       = HPKE encapsulated key (32 bytes for X25519)
+    payload<2>   = HPKE.Seal(EncodedClientHelloInner) + 16-byte tag
+
+The inner hello either repeats the full ML-KEM key share or, with
+ech_outer_extensions, references the outer one (-ech-compress).
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cloudflare/circl/hpke"
+)
+
+const (
+	ECH_EXTENSION_TYPE     = 0xfe0d
+	ECH_OUTER_EXTENSIONS   = 0xfd00
+	ECH_INNER_PADDING_STEP = 32 // Inner hello is padded to a multiple of this
+)
+
+// echBreakdown records how many bytes each part of the ECH extension adds.
+type echBreakdown struct {
+	InnerSize     int // EncodedClientHelloInner before padding
+	PaddedInner   int // After padding to ECH_INNER_PADDING_STEP
+	EncSize       int // HPKE encapsulated key
+	CiphertextLen int // Sealed inner hello (padded inner + AEAD tag)
+	ExtensionSize int // Whole extension including its 4-byte header
+}
+
+// buildECHExtension encrypts a simulated inner ClientHello under a freshly
+// generated ECH config key and returns the outer extension bytes.
+// The client plays both roles here: the proxy never decrypts the payload, it
+// only needs the real on-the-wire size.
+func buildECHExtension(pkBytes []byte, headerSize int, compressInner bool) ([]byte, echBreakdown, error) {
+	var b echBreakdown
+
+	suite := hpke.NewSuite(hpke.KEM_X25519_HKDF_SHA256, hpke.KDF_HKDF_SHA256, hpke.AEAD_AES128GCM)
+	echPub, _, err := hpke.KEM_X25519_HKDF_SHA256.Scheme().GenerateKeyPair()
+	if err != nil {
+		return nil, b, fmt.Errorf("ECH config keygen failed: %w", err)
+	}
+
+	// Inner ClientHello: private headers plus the key share (or a reference)
+	inner := helloPadding(headerSize)
+	if compressInner {
+		// ech_outer_extensions listing key_share (0x0033)
+		inner = binary.BigEndian.AppendUint16(inner, ECH_OUTER_EXTENSIONS)
+		inner = binary.BigEndian.AppendUint16(inner, 3)
+		inner = append(inner, 2, 0x00, 0x33)
+	} else {
+		inner = append(inner, pkBytes...)
+	}
+	b.InnerSize = len(inner)
+
+	if rem := len(inner) % ECH_INNER_PADDING_STEP; rem != 0 {
+		inner = append(inner, make([]byte, ECH_INNER_PADDING_STEP-rem)...)
+	}
+	b.PaddedInner = len(inner)
+
+	sender, err := suite.NewSender(echPub, []byte("tls ech"))
+	if err != nil {
+		return nil, b, fmt.Errorf("HPKE sender setup failed: %w", err)
+	}
+	enc, sealer, err := sender.Setup(rand.Reader)
+	if err != nil {
+		return nil, b, fmt.Errorf("HPKE setup failed: %w", err)
+	}
+	ct, err := sealer.Seal(inner, nil)
+	if err != nil {
+		return nil, b, fmt.Errorf("HPKE seal failed: %w", err)
+	}
+	b.EncSize = len(enc)
+	b.CiphertextLen = len(ct)
+
+	body := []byte{0} // ECHClientHelloType: outer
+	body = binary.BigEndian.AppendUint16(body, uint16(hpke.KDF_HKDF_SHA256))
+	body = binary.BigEndian.AppendUint16(body, uint16(hpke.AEAD_AES128GCM))
+	body = append(body, 0x42) // config_id
+	body = binary.BigEndian.AppendUint16(body, uint16(len(enc)))
+	body = append(body, enc...)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ct)))
+	body = append(body, ct...)
+
+	ext := binary.BigEndian.AppendUint16(nil, ECH_EXTENSION_TYPE)
+	ext = binary.BigEndian.AppendUint16(ext, uint16(len(body)))
+	ext = append(ext, body...)
+	b.ExtensionSize = len(ext)
+
+	return ext, b, nil
+}
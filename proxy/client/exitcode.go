@@ -0,0 +1,139 @@
+/*
+Exit Codes
+==========
+The exit status says how the handshakes went, so scripts can branch on it
+without reading the logs:
+
+  0  every handshake completed within the payload budget (SAFE)
+  1  invalid flags, or the run could not start (unknown -alg, ...)
+  2  fragmentation detected: a handshake completed, but its ClientHello
+     exceeded the payload budget, or the proxy rated it CRITICAL_RISK.
+     With -proxy-status the budget is the one the proxy judged the
+     handshake by (its -mtu or the listener's), else the measured one
+     with -probe-mtu, else 1400 bytes
+  3  handshake failed: downgraded to TLS 1.2, decapsulation failed, the
+     key was not confirmed, or a mode's exchange failed
+  4  network error: could not connect or send, or no ServerHello came back;
+     also a run stopped by the network, e.g. a sweep that never reached
+     the target or a suite that could not reach the proxy's API
+  5  a test of the -suite failed (suite.go)
+  6  -fuzz found a hang, a crash or an inconsistent verdict (fuzz.go)
+
+With several handshakes (-repeat, -batch, -rate) the worst one decides, in
+the order 4, 3, 2. A padding sweep exits 0 once it reached the target: its
+thresholds are the result. A suite exits 0 or 5 whatever its handshakes'
+codes: a test may expect a failure; fuzzing exits 0 or 6, its cases are
+meant to fail.
+*/
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+const (
+	STATIC_BUDGET = 1400 // payload budget without -proxy-status or -probe-mtu
+)
+
+const (
+	EXIT_OK               = 0
+	EXIT_USAGE            = 1
+	EXIT_FRAGMENTED       = 2
+	EXIT_HANDSHAKE_FAILED = 3
+	EXIT_NETWORK          = 4
+	EXIT_SUITE_FAILED     = 5
+	EXIT_FUZZ_FINDINGS    = 6
+)
+
+// fragmented reports whether the ClientHello exceeded the payload budget,
+// or the proxy said so.
+func (r handshakeResult) fragmented() bool {
+	return r.Budget > 0 && r.Sent > r.Budget || r.ProxyStatus == "CRITICAL_RISK"
+}
+
+// takeVerdict judges res by the proxy's report on it, read from the API
+// behind statusURL (-proxy-status): its budget replaces the client's guess.
+func (r *handshakeResult) takeVerdict(statusURL string) {
+	if r.Local == nil || r.Sent == 0 {
+		return
+	}
+	u, err := url.Parse(statusURL)
+	if err != nil {
+		log.Printf("[CLIENT] ⚠️  -proxy-status: %v", err)
+		return
+	}
+	u.Path, u.RawQuery = "/api/reports", ""
+	v, err := fetchVerdict(&http.Client{Timeout: *ioTimeout}, u.String(), r.Local.String())
+	switch {
+	case err != nil:
+		log.Printf("[CLIENT] ⚠️  No proxy verdict: %v", err)
+		return
+	case v == nil:
+		log.Printf("[CLIENT] ⚠️  No proxy verdict: the proxy stored no report on %s", r.Local)
+		return
+	}
+	r.ProxyStatus = v.Status
+	if v.MTUBudget > 0 {
+		r.Budget = v.MTUBudget
+	}
+	log.Printf("[CLIENT] Proxy verdict: %s (%d of %d bytes)", v.Status, v.HandshakeSize, r.Budget)
+}
+
+// failCode is the exit status of a run an error stopped: the network's
+// failures are told apart from everything else.
+func failCode(err error) int {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return EXIT_NETWORK
+	}
+	return EXIT_USAGE
+}
+
+// exitCode is the exit status the handshake alone would give.
+func (r handshakeResult) exitCode() int {
+	switch r.Outcome {
+	case OUTCOME_COMPLETED:
+		if r.fragmented() {
+			return EXIT_FRAGMENTED
+		}
+		return EXIT_OK
+	case OUTCOME_ABORTED, OUTCOME_NO_REPLY:
+		return EXIT_NETWORK
+	default:
+		return EXIT_HANDSHAKE_FAILED
+	}
+}
+
+// exitCode is the exit status of the worst handshake of a batch or load run.
+func (s batchSummary) exitCode() int {
+	switch {
+	case s.Outcomes[OUTCOME_ABORTED]+s.Outcomes[OUTCOME_NO_REPLY] > 0:
+		return EXIT_NETWORK
+	case s.Outcomes[OUTCOME_COMPLETED] < s.Handshakes:
+		return EXIT_HANDSHAKE_FAILED
+	case s.Fragmented > 0:
+		return EXIT_FRAGMENTED
+	}
+	return EXIT_OK
+}
+
+// exitCode is the exit status of a suite: whether every test passed.
+func (s suiteResult) exitCode() int {
+	if s.Failed > 0 {
+		return EXIT_SUITE_FAILED
+	}
+	return EXIT_OK
+}
+
+// exitCode is the exit status of a -fuzz run: whether it found anything.
+func (r fuzzResult) exitCode() int {
+	if r.found() > 0 {
+		return EXIT_FUZZ_FINDINGS
+	}
+	return EXIT_OK
+}
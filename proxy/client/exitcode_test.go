@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		res  handshakeResult
+		want int
+	}{
+		{"within the static budget", handshakeResult{Outcome: OUTCOME_COMPLETED, Sent: 1334, Budget: STATIC_BUDGET}, EXIT_OK},
+		{"over the static budget", handshakeResult{Outcome: OUTCOME_COMPLETED, Sent: 1484, Budget: STATIC_BUDGET}, EXIT_FRAGMENTED},
+		{"over the proxy's budget", handshakeResult{Outcome: OUTCOME_COMPLETED, Sent: 1334, Budget: 1280, ProxyStatus: "CRITICAL_RISK"}, EXIT_FRAGMENTED},
+		{"proxy flags it within budget", handshakeResult{Outcome: OUTCOME_COMPLETED, Sent: 1334, Budget: STATIC_BUDGET, ProxyStatus: "CRITICAL_RISK"}, EXIT_FRAGMENTED},
+		{"proxy rates it safe", handshakeResult{Outcome: OUTCOME_COMPLETED, Sent: 1334, Budget: 1400, ProxyStatus: "SAFE"}, EXIT_OK},
+		{"no budget", handshakeResult{Outcome: OUTCOME_COMPLETED, Sent: 9000}, EXIT_OK},
+		{"no ServerHello", handshakeResult{Outcome: OUTCOME_NO_REPLY, Sent: 1484, Budget: STATIC_BUDGET}, EXIT_NETWORK},
+		{"aborted", handshakeResult{Outcome: OUTCOME_ABORTED}, EXIT_NETWORK},
+		{"downgraded", handshakeResult{Outcome: OUTCOME_DOWNGRADED, Sent: 1334, Budget: STATIC_BUDGET}, EXIT_HANDSHAKE_FAILED},
+		{"unconfirmed", handshakeResult{Outcome: OUTCOME_UNCONFIRMED, Sent: 1334, Budget: STATIC_BUDGET}, EXIT_HANDSHAKE_FAILED},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.res.exitCode(); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailCode(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"dial refused", refused, EXIT_NETWORK},
+		{"wrapped dial", fmt.Errorf("127.0.0.1:4433: %w", refused), EXIT_NETWORK},
+		{"API request", &url.Error{Op: "Get", URL: "http://127.0.0.1:9090/api/reports", Err: refused}, EXIT_NETWORK},
+		{"bad flag", errors.New("-proxy-status: missing scheme"), EXIT_USAGE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failCode(tt.err); got != tt.want {
+				t.Errorf("failCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Algorithm Fallback
+==================
+A real client behind a path that drops large hellos will not give up: it
+retries with a smaller key share. -fallback does the same. When a
+handshake stalls (no ServerHello before -timeout, or the connection is
+reset or closed without one), the client retries with the next smaller
+scheme of the ladder, down to a classical X25519 key share:
+
+  Kyber1024 → Kyber768 → Kyber512 → X25519 (classical)
+
+The ladder starts below -alg, so -alg Kyber1024 -fallback walks all of it
+and the default Kyber768 starts one step down. The proxy listener has to
+accept the smaller schemes, e.g.
+
+  go run . -listen :4433,alg=Kyber1024+Kyber768+Kyber512+HPKE_KEM_X25519_HKDF_SHA256
+  go run ./client -alg Kyber1024 -fallback -padding 100
+
+Failures a smaller key share does not fix (no connection, a downgrade, a
+failed decapsulation or key confirmation) end the ladder. The client
+reports every level tried and the one that finally succeeded; the
+handshake's exit code and -json entry are those of the last level.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/schemes"
+)
+
+// FALLBACK_LADDER is the order the client falls back in, largest key share
+// first.
+var FALLBACK_LADDER = []struct{ label, scheme string }{
+	{"Kyber1024", "Kyber1024"},
+	{"Kyber768", "Kyber768"},
+	{"Kyber512", "Kyber512"},
+	{"X25519", "HPKE_KEM_X25519_HKDF_SHA256"}, // classical, DHKEM(X25519)
+}
+
+// fallbackAttempt is one level of the ladder and how it went.
+type fallbackAttempt struct {
+	Algorithm string `json:"algorithm"`
+	Sent      int    `json:"client_hello_bytes"`
+	Outcome   string `json:"outcome"`
+	Error     string `json:"error,omitempty"`
+}
+
+// fallbackLadder is scheme followed by the schemes of the ladder with a
+// smaller public key.
+func fallbackLadder(scheme kem.Scheme) []kem.Scheme {
+	ladder := []kem.Scheme{scheme}
+	for _, l := range FALLBACK_LADDER {
+		if s := schemes.ByName(l.scheme); s.PublicKeySize() < scheme.PublicKeySize() {
+			ladder = append(ladder, s)
+		}
+	}
+	return ladder
+}
+
+// schemeLabel is the ladder's name for a scheme.
+func schemeLabel(s kem.Scheme) string {
+	for _, l := range FALLBACK_LADDER {
+		if l.scheme == s.Name() {
+			return l.label
+		}
+	}
+	return s.Name()
+}
+
+// outcomeLabel is the short name of an outcome, as the batch summary has it.
+func outcomeLabel(outcome string) string {
+	for _, o := range BATCH_OUTCOMES {
+		if o.outcome == outcome {
+			return strings.ToLower(strings.TrimSuffix(o.label, ":"))
+		}
+	}
+	return outcome
+}
+
+// runWithFallback runs the handshake with scheme and, as long as it stalls,
+// with the next smaller scheme of the ladder; the result is the last
+// level's, with every level tried.
+func runWithFallback(scheme kem.Scheme) handshakeResult {
+	ladder := fallbackLadder(scheme)
+	var attempts []fallbackAttempt
+	var res handshakeResult
+	for i, s := range ladder {
+		if i > 0 {
+			log.Println()
+			log.Printf("[FALLBACK] %s stalled (%v): retrying with %s", schemeLabel(ladder[i-1]), res.Err, schemeLabel(s))
+		}
+		res = runHandshake(s)
+		a := fallbackAttempt{Algorithm: schemeLabel(s), Sent: res.Sent, Outcome: res.Outcome}
+		if res.Err != nil {
+			a.Error = res.Err.Error()
+		}
+		attempts = append(attempts, a)
+		if res.Outcome != OUTCOME_NO_REPLY {
+			break
+		}
+	}
+	res.Algorithm, res.Attempts = attempts[len(attempts)-1].Algorithm, attempts
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│             ALGORITHM FALLBACK              │")
+	log.Println("├─────────────────────────────────────────────┤")
+	for _, a := range attempts {
+		log.Printf("│ %-15s %-27s │\n", a.Algorithm+":", fmt.Sprintf("%d bytes, %s", a.Sent, outcomeLabel(a.Outcome)))
+	}
+	verdict := fmt.Sprintf("none of %d levels", len(ladder))
+	if res.Outcome == OUTCOME_COMPLETED {
+		verdict = fmt.Sprintf("%s (level %d of %d)", res.Algorithm, len(attempts), len(ladder))
+	}
+	log.Printf("│ Succeeded at:   %-27s │\n", verdict)
+	log.Println("└─────────────────────────────────────────────┘")
+	switch {
+	case res.Outcome == OUTCOME_COMPLETED && len(attempts) > 1 && res.Algorithm == FALLBACK_LADDER[len(FALLBACK_LADDER)-1].label:
+		log.Println("⚠️  Only the classical key share got through: no post-quantum key exchange on this path")
+	case res.Outcome == OUTCOME_COMPLETED && len(attempts) > 1:
+		log.Printf("⚠️  The path only carries %s and smaller: %d bytes of ClientHello got through", res.Algorithm, res.Sent)
+	}
+	return res
+}
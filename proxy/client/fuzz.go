@@ -0,0 +1,361 @@
+/*
+Malformed-Input Fuzzing
+=======================
+-fuzz N sends the proxy key shares no browser would, to exercise its
+parser and error paths instead of its happy path. Each case is a fresh
+connection with one malformed flight, N of each class:
+
+  truncated  the key share cut short (0 bytes, 1 byte, half, all but one
+             byte, then random lengths); the client half-closes after it
+  oversized  a key share followed by far more than any hello (past a TLS
+             record, 64 KiB, then random sizes)
+  bitflip    the hello with bits of the key share flipped (1, 8, 64, then
+             a random number)
+  garbage    the hello's length of zeros, of 0xff, then of random bytes
+
+  go run ./client -fuzz 8 -proxy-status http://127.0.0.1:9090/status
+
+A normal handshake runs before the cases, so a proxy that does not even
+complete that (a different -alg, ...) is not blamed on them. The client
+logs how the proxy reacted to each case (answered, closed, reset) and
+reports as findings:
+
+  hang          the proxy neither answered nor closed the connection
+                within -timeout
+  crash         the proxy stopped accepting connections after a case, or
+                no longer completes a normal handshake after all of them
+  inconsistent  the proxy answered a truncated key share, or its report
+                (read from /api/reports with -proxy-status) judged other
+                than the bytes sent: a different size, or a status that
+                does not follow from the size and its budget
+
+Kyber takes any bytes of the right length as a public key, so the proxy
+answering a bit-flipped or garbage key share is not a finding; the key
+confirmation of such a connection fails on the client's side only. The
+proxy's -alg must match the client's, as for any handshake. -fuzz exits 6
+when a case turned up a finding (exitcode.go).
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// Reactions of the proxy to a case.
+const (
+	FUZZ_ANSWERED = "answered" // it sent something back
+	FUZZ_CLOSED   = "closed"
+	FUZZ_RESET    = "reset"
+	FUZZ_HANG     = "hang"    // nothing within -timeout
+	FUZZ_REFUSED  = "refused" // could not even connect
+)
+
+// fuzzCase is one malformed flight.
+type fuzzCase struct {
+	Class     string `json:"class"`
+	Detail    string `json:"detail"`
+	Sent      int    `json:"bytes"`
+	payload   []byte
+	halfClose bool // end the flight with a FIN
+}
+
+// fuzzOutcome is how the proxy took a case.
+type fuzzOutcome struct {
+	fuzzCase
+	Reaction string        `json:"reaction"`
+	Received int           `json:"received_bytes,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Proxy    *proxyVerdict `json:"proxy,omitempty"`
+	Findings []string      `json:"findings,omitempty"`
+	down     bool          // the proxy stopped accepting connections
+}
+
+// fuzzResult is what a -fuzz run found.
+type fuzzResult struct {
+	Cases        int            `json:"cases"`
+	Reactions    map[string]int `json:"reactions"`
+	Hangs        int            `json:"hangs"`
+	Crashes      int            `json:"crashes"`
+	Inconsistent int            `json:"inconsistent"`
+	Findings     []string       `json:"findings,omitempty"` // not tied to a case
+	Results      []fuzzOutcome  `json:"results"`
+}
+
+func (r fuzzResult) found() int {
+	return r.Hangs + r.Crashes + r.Inconsistent
+}
+
+// FUZZ_CLASSES build the i-th case of each class from a public key and the
+// hello it is normally sent in; the first cases are fixed boundaries, the
+// rest random.
+var FUZZ_CLASSES = []struct {
+	name  string
+	build func(i int, pk, hello []byte) fuzzCase
+}{
+	{"truncated", func(i int, pk, hello []byte) fuzzCase {
+		n := rand.IntN(len(pk))
+		if fixed := []int{0, 1, len(pk) / 2, len(pk) - 1}; i < len(fixed) {
+			n = fixed[i]
+		}
+		return fuzzCase{Detail: fmt.Sprintf("%d of %d key share bytes", n, len(pk)), payload: pk[:n], halfClose: true}
+	}},
+	{"oversized", func(i int, pk, hello []byte) fuzzCase {
+		n := len(hello) + rand.IntN(64<<10)
+		// past a whole TLS record (header and 16 KiB), 64 KiB
+		if fixed := []int{5 + 16384 + 1, 64 << 10}; i < len(fixed) {
+			n = fixed[i]
+		}
+		return fuzzCase{Detail: fmt.Sprintf("key share + %d bytes", n-len(pk)), payload: append(pk[:len(pk):len(pk)], randomBytes(n-len(pk))...)}
+	}},
+	{"bitflip", func(i int, pk, hello []byte) fuzzCase {
+		bits := 1 + rand.IntN(256)
+		if fixed := []int{1, 8, 64}; i < len(fixed) {
+			bits = fixed[i]
+		}
+		payload := append([]byte(nil), hello...)
+		for range bits {
+			bit := rand.IntN(len(pk) * 8)
+			payload[bit/8] ^= 1 << (bit % 8)
+		}
+		return fuzzCase{Detail: fmt.Sprintf("%d bit(s) of the key share flipped", bits), payload: payload}
+	}},
+	{"garbage", func(i int, pk, hello []byte) fuzzCase {
+		switch i {
+		case 0:
+			return fuzzCase{Detail: "zeros", payload: make([]byte, len(hello))}
+		case 1:
+			payload := make([]byte, len(hello))
+			for j := range payload {
+				payload[j] = 0xff
+			}
+			return fuzzCase{Detail: "0xff bytes", payload: payload}
+		}
+		return fuzzCase{Detail: "random bytes", payload: randomBytes(len(hello))}
+	}},
+}
+
+// fuzzer runs the cases of a -fuzz run.
+type fuzzer struct {
+	reports string // the proxy's /api/reports, "" for no verdicts
+	client  *http.Client
+}
+
+// runFuzz runs n cases of each class and logs and returns what they found.
+func runFuzz(scheme kem.Scheme, n int, statusURL string) (fuzzResult, error) {
+	f := fuzzer{client: &http.Client{Timeout: *ioTimeout}}
+	if statusURL != "" {
+		u, err := url.Parse(statusURL)
+		if err != nil {
+			return fuzzResult{}, fmt.Errorf("-proxy-status: %w", err)
+		}
+		u.Path, u.RawQuery = "/api/reports", ""
+		f.reports = u.String()
+	}
+	if res := f.baseline(scheme); res.Outcome != OUTCOME_COMPLETED {
+		return fuzzResult{}, fmt.Errorf("a normal handshake does not complete (%s: %v), so the cases could not be told apart; check -target and the proxy's -alg", res.Outcome, res.Err)
+	}
+	pk, _, err := scheme.GenerateKeyPair()
+	if err != nil {
+		return fuzzResult{}, fmt.Errorf("KeyGen failed: %w", err)
+	}
+	pkBytes, err := pk.MarshalBinary()
+	if err != nil {
+		return fuzzResult{}, fmt.Errorf("Failed to marshal public key: %w", err)
+	}
+	hello := append(pkBytes[:len(pkBytes):len(pkBytes)], helloPadding(*paddingSize)...)
+
+	log.Println()
+	log.Printf("[FUZZ] %d malformed flights of each of %d classes against %s (%s, %d-byte key share)...", n, len(FUZZ_CLASSES), *targetAddr, scheme.Name(), len(pkBytes))
+	if f.reports == "" {
+		log.Printf("[FUZZ] No -proxy-status: the proxy's verdicts are not checked")
+	}
+	res := fuzzResult{Reactions: make(map[string]int)}
+	crashed := false
+	for _, class := range FUZZ_CLASSES {
+		for i := 0; i < n && !crashed; i++ {
+			c := class.build(i, pkBytes, hello)
+			c.Class, c.Sent = class.name, len(c.payload)
+			o := f.run(c, len(pkBytes))
+			res.Cases++
+			res.Reactions[o.Reaction]++
+			mark := "✅"
+			if len(o.Findings) > 0 {
+				mark = "❌"
+			}
+			verdict := ""
+			if o.Proxy != nil {
+				verdict = ", " + o.Proxy.Status
+			}
+			log.Printf("[FUZZ] %s %-9s %6d bytes (%s): %s%s", mark, c.Class, c.Sent, c.Detail, o.reactionLabel(), verdict)
+			for _, finding := range o.Findings {
+				log.Printf("[FUZZ]      %s", finding)
+			}
+			switch {
+			case o.down:
+				res.Crashes++
+				crashed = true
+			case o.Reaction == FUZZ_HANG:
+				res.Hangs++
+			case len(o.Findings) > 0:
+				res.Inconsistent++
+			}
+			res.Results = append(res.Results, o)
+		}
+	}
+	if !crashed {
+		if after := f.baseline(scheme); after.Outcome != OUTCOME_COMPLETED {
+			finding := fmt.Sprintf("a normal handshake no longer completes after the cases: %s (%v)", after.Outcome, after.Err)
+			log.Printf("[FUZZ] ❌ %s", finding)
+			res.Findings = append(res.Findings, finding)
+			res.Crashes++
+		}
+	}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│                 FUZZ RESULT                 │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Cases:          %-27d │\n", res.Cases)
+	for _, r := range []string{FUZZ_ANSWERED, FUZZ_CLOSED, FUZZ_RESET} {
+		if res.Reactions[r] > 0 {
+			log.Printf("│ %-15s %-27d │\n", "Proxy "+r+":", res.Reactions[r])
+		}
+	}
+	log.Printf("│ Hangs:          %-27d │\n", res.Hangs)
+	log.Printf("│ Crashes:        %-27d │\n", res.Crashes)
+	log.Printf("│ Inconsistent:   %-27d │\n", res.Inconsistent)
+	log.Println("└─────────────────────────────────────────────┘")
+	if crashed {
+		log.Printf("❌ The proxy stopped accepting connections: the remaining cases were not sent")
+	} else if res.found() > 0 {
+		log.Printf("❌ %d finding(s) in %d cases", res.found(), res.Cases)
+	} else {
+		log.Printf("✅ The proxy took all %d cases without a hang, crash or inconsistent verdict", res.Cases)
+	}
+	return res, nil
+}
+
+// baseline runs a normal handshake without its output.
+func (f fuzzer) baseline(scheme kem.Scheme) handshakeResult {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+	return runHandshake(scheme)
+}
+
+// run sends one case on a connection of its own and checks how the proxy
+// took it.
+func (f fuzzer) run(c fuzzCase, keySize int) fuzzOutcome {
+	o := fuzzOutcome{fuzzCase: c}
+	conn, err := dialProxy("tcp", *targetAddr)
+	if err != nil {
+		o.Reaction, o.Error, o.down = FUZZ_REFUSED, err.Error(), true
+		o.Findings = append(o.Findings, fmt.Sprintf("the proxy refused the connection: %v", err))
+		return o
+	}
+	local := conn.LocalAddr().String()
+	conn.SetDeadline(time.Now().Add(*ioTimeout))
+	_, err = conn.Write(c.payload)
+	if err == nil && c.halfClose {
+		if tc, ok := conn.(*net.TCPConn); ok {
+			err = tc.CloseWrite()
+		}
+	}
+	if err == nil {
+		buffer := make([]byte, 4096)
+		o.Received, err = conn.Read(buffer)
+	}
+	conn.Close()
+	o.Reaction = fuzzReaction(o.Received, err)
+	if err != nil && o.Reaction != FUZZ_CLOSED {
+		o.Error = err.Error()
+	}
+
+	switch {
+	case o.Reaction == FUZZ_HANG:
+		o.Findings = append(o.Findings, fmt.Sprintf("the proxy neither answered nor closed the connection within %v", *ioTimeout))
+	case c.Class == "truncated" && o.Reaction == FUZZ_ANSWERED:
+		o.Findings = append(o.Findings, fmt.Sprintf("the proxy answered a truncated key share (%d of %d bytes) with %d bytes", c.Sent, keySize, o.Received))
+	}
+	if err := alive(); err != nil {
+		o.down = true
+		o.Findings = append(o.Findings, fmt.Sprintf("the proxy stopped accepting connections after this case: %v", err))
+		return o
+	}
+	if f.reports != "" {
+		v, err := fetchVerdict(f.client, f.reports, local)
+		if err != nil {
+			log.Printf("[FUZZ] ⚠️  %v", err)
+		}
+		o.Proxy = v
+		o.Findings = append(o.Findings, o.inconsistencies()...)
+	}
+	return o
+}
+
+// alive reports whether the proxy still accepts connections.
+func alive() error {
+	conn, err := dialProxy("tcp", *targetAddr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// inconsistencies lists where the proxy's report disagrees with what was
+// sent.
+func (o fuzzOutcome) inconsistencies() []string {
+	v := o.Proxy
+	if v == nil {
+		return nil
+	}
+	var out []string
+	if v.HandshakeSize != o.Sent {
+		out = append(out, fmt.Sprintf("the proxy judged %d of the %d bytes sent", v.HandshakeSize, o.Sent))
+	}
+	if v.MTUBudget > 0 {
+		switch {
+		case v.Status == "SAFE" && o.Sent > v.MTUBudget:
+			out = append(out, fmt.Sprintf("SAFE for %d bytes over the %d-byte budget", o.Sent, v.MTUBudget))
+		case v.Status == "CRITICAL_RISK" && o.Sent <= v.MTUBudget:
+			out = append(out, fmt.Sprintf("CRITICAL_RISK for %d bytes within the %d-byte budget", o.Sent, v.MTUBudget))
+		}
+	}
+	return out
+}
+
+// fuzzReaction names how a connection ended after n bytes and err.
+func fuzzReaction(n int, err error) string {
+	switch {
+	case n > 0:
+		return FUZZ_ANSWERED
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return FUZZ_HANG
+	case errors.Is(err, io.EOF):
+		return FUZZ_CLOSED
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE):
+		return FUZZ_RESET
+	}
+	return FUZZ_CLOSED
+}
+
+// reactionLabel is the reaction as the case's line has it.
+func (o fuzzOutcome) reactionLabel() string {
+	if o.Reaction == FUZZ_ANSWERED {
+		return fmt.Sprintf("answered %d bytes", o.Received)
+	}
+	return o.Reaction
+}
@@ -0,0 +1,264 @@
+/*
+ClientHello Profiles
+====================
+The bytes after the key share stand for the rest of a browser's ClientHello.
+Instead of a counting pattern they are structurally valid TLS extensions,
+the ones a browser sends besides key_share and in its order:
+
+  chrome   GREASE, server_name, extended_master_secret, renegotiation_info,
+           supported_groups, ec_point_formats, session_ticket, ALPN,
+           status_request, signature_algorithms, SCT, psk_key_exchange_modes,
+           supported_versions, compress_certificate, application_settings,
+           an ECH GREASE extension and a trailing GREASE
+  firefox  the same without GREASE and ALPS, plus delegated_credentials and
+           record_size_limit, ECH GREASE
+  safari   GREASE, the classic set with TLS 1.0-1.3 in supported_versions,
+           no ECH
+
+The hello's fixed fields (record and handshake headers, random, session ID,
+cipher suites) and the classical key shares next to the ML-KEM one are not
+extensions; they are carried as a padding extension (RFC 7685) of their size,
+so -profile chrome sends as many bytes as Chrome does. server_name is the
+-target host, left out for an IP address as browsers do.
+
+-profile NAME without -padding uses the browser's size; with -padding, or
+without -profile (chrome's extensions), the extensions are fitted to exactly
+-padding bytes: the ones that do not fit are left out and the padding
+extension makes up the rest.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+)
+
+const (
+	EXT_PADDING = 0x0015 // RFC 7685
+
+	// record(5) + handshake(4) headers, legacy_version(2), random(32),
+	// session ID(1+32), compression methods(2), extensions length(2)
+	HELLO_FIXED_SIZE = 5 + 4 + 2 + 32 + 1 + 32 + 2 + 2
+	// key_share header(4), list length(2), the ML-KEM entry's header(4) and
+	// the X25519 half of X25519MLKEM768
+	HELLO_KEY_SHARE_FRAMING = 4 + 2 + 4 + 32
+)
+
+// browserProfile is a browser's ClientHello apart from its ML-KEM key share.
+type browserProfile struct {
+	Suites     int   // cipher suites, GREASE included
+	Shares     []int // classical key shares sent next to the ML-KEM one
+	extensions func(sni string) [][]byte
+}
+
+// HELLO_PROFILES are the -profile presets, after current browser releases.
+var HELLO_PROFILES = map[string]browserProfile{
+	"chrome": {Suites: 16, Shares: []int{1, 32}, extensions: func(sni string) [][]byte {
+		first, last := greasePair()
+		return [][]byte{
+			ext(first, nil),
+			serverName(sni),
+			ext(0x0017, nil), // extended_master_secret
+			ext(0xff01, []byte{0}),
+			ext(0x000a, u16list(u16s(grease(), 0x11ec, 0x001d, 0x0017, 0x0018))),
+			ext(0x000b, u8list([]byte{0})),
+			ext(0x0023, nil), // session_ticket
+			alpn("h2", "http/1.1"),
+			ext(0x0005, []byte{1, 0, 0, 0, 0}),
+			ext(0x000d, u16list(u16s(0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601))),
+			ext(0x0012, nil), // signed_certificate_timestamp
+			ext(0x002d, u8list([]byte{1})),
+			ext(0x002b, u8list(u16s(grease(), 0x0304, 0x0303))),
+			ext(0x001b, u8list(u16s(0x0002))),
+			ext(0x44cd, u16list(u8list([]byte("h2")))), // application_settings
+			echGrease(176),
+			ext(last, []byte{0}),
+		}
+	}},
+	"firefox": {Suites: 17, Shares: []int{32, 65}, extensions: func(sni string) [][]byte {
+		return [][]byte{
+			serverName(sni),
+			ext(0x0017, nil),
+			ext(0xff01, []byte{0}),
+			ext(0x000a, u16list(u16s(0x11ec, 0x001d, 0x0017, 0x0018, 0x0019, 0x0100, 0x0101))),
+			ext(0x000b, u8list([]byte{0})),
+			ext(0x0023, nil),
+			alpn("h2", "http/1.1"),
+			ext(0x0005, []byte{1, 0, 0, 0, 0}),
+			ext(0x0022, u16list(u16s(0x0403, 0x0503, 0x0603, 0x0203))), // delegated_credentials
+			ext(0x002b, u8list(u16s(0x0304, 0x0303))),
+			ext(0x000d, u16list(u16s(0x0403, 0x0503, 0x0603, 0x0804, 0x0805, 0x0806, 0x0401, 0x0501, 0x0601, 0x0203, 0x0201))),
+			ext(0x002d, u8list([]byte{1})),
+			ext(0x001c, u16s(0x4001)), // record_size_limit
+			ext(0x001b, u8list(u16s(0x0001, 0x0002, 0x0003))),
+			echGrease(208),
+		}
+	}},
+	"safari": {Suites: 21, Shares: []int{1, 32}, extensions: func(sni string) [][]byte {
+		first, last := greasePair()
+		return [][]byte{
+			ext(first, nil),
+			serverName(sni),
+			ext(0x0017, nil),
+			ext(0xff01, []byte{0}),
+			ext(0x000a, u16list(u16s(grease(), 0x11ec, 0x001d, 0x0017, 0x0018, 0x0019))),
+			ext(0x000b, u8list([]byte{0})),
+			alpn("h2", "http/1.1"),
+			ext(0x0005, []byte{1, 0, 0, 0, 0}),
+			ext(0x000d, u16list(u16s(0x0403, 0x0804, 0x0401, 0x0503, 0x0203, 0x0805, 0x0501, 0x0806, 0x0601, 0x0201))),
+			ext(0x0012, nil),
+			ext(0x002d, u8list([]byte{1})),
+			ext(0x002b, u8list(u16s(grease(), 0x0304, 0x0303, 0x0302, 0x0301))),
+			ext(0x001b, u8list(u16s(0x0001))),
+			ext(last, []byte{0}),
+		}
+	}},
+}
+
+// profileNames lists the presets for messages.
+func profileNames() []string {
+	var names []string
+	for name := range HELLO_PROFILES {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// activeProfile is -profile, chrome without one.
+func activeProfile() browserProfile {
+	if p, ok := HELLO_PROFILES[*helloProfile]; ok {
+		return p
+	}
+	return HELLO_PROFILES["chrome"]
+}
+
+// size is what the browser sends besides the ML-KEM public key.
+func (p browserProfile) size(sni string) int {
+	n := HELLO_FIXED_SIZE + 2 + 2*p.Suites + HELLO_KEY_SHARE_FRAMING
+	for _, s := range p.Shares {
+		n += 4 + s
+	}
+	for _, e := range p.extensions(sni) {
+		n += len(e)
+	}
+	return n
+}
+
+// helloPadding is n bytes of the active profile's extensions, fitted with a
+// padding extension.
+func helloPadding(n int) []byte {
+	var out []byte
+	for _, e := range activeProfile().extensions(helloServerName()) {
+		// What is left must be nothing or room for the padding extension:
+		// no extension is smaller than its 4-byte header
+		if rest := n - len(out) - len(e); rest == 0 || rest >= 4 {
+			out = append(out, e...)
+		}
+	}
+	switch rest := n - len(out); {
+	case rest == 0:
+		return out
+	case rest < 4:
+		return make([]byte, n) // n < 4: too short for any extension
+	}
+	return append(out, ext(EXT_PADDING, make([]byte, n-len(out)-4))...)
+}
+
+// helloServerName is the -target host for server_name, "" for an address.
+func helloServerName() string {
+	host, _, err := net.SplitHostPort(*targetAddr)
+	if err != nil || net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
+}
+
+// ============================================================================
+// EXTENSION ENCODING
+// ============================================================================
+
+func ext(typ uint16, body []byte) []byte {
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(body)))
+	return append(b, body...)
+}
+
+func u8list(b []byte) []byte {
+	return append([]byte{byte(len(b))}, b...)
+}
+
+func u16list(b []byte) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...)
+}
+
+func u16s(vs ...uint16) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+// grease is a random GREASE value (RFC 8701): 0x0a0a, 0x1a1a, ... 0xfafa.
+func grease() uint16 {
+	return uint16(rand.IntN(16)<<4|0x0a) * 0x0101
+}
+
+// greasePair is two different GREASE values, for the leading and trailing
+// GREASE extensions: a hello may not carry an extension type twice. Like
+// BoringSSL, a repeated value is moved to the next one.
+func greasePair() (uint16, uint16) {
+	first, last := grease(), grease()
+	if last == first {
+		last ^= 0x1010
+	}
+	return first, last
+}
+
+// serverName is the server_name extension, nothing without a name.
+func serverName(sni string) []byte {
+	if sni == "" {
+		return nil
+	}
+	return ext(0x0000, u16list(append([]byte{0}, u16list([]byte(sni))...)))
+}
+
+func alpn(protocols ...string) []byte {
+	var list []byte
+	for _, p := range protocols {
+		list = append(list, u8list([]byte(p))...)
+	}
+	return ext(0x0010, u16list(list))
+}
+
+// echGrease is a GREASE encrypted_client_hello: an outer ECH with random
+// config ID, enc and a payload of size bytes.
+func echGrease(size int) []byte {
+	// outer, HKDF-SHA256, AES-128-GCM
+	body := append([]byte{0}, u16s(0x0001, 0x0001)...)
+	body = append(body, byte(rand.IntN(256)))
+	body = append(body, u16list(randomBytes(32))...)
+	body = append(body, u16list(randomBytes(size))...)
+	return ext(ECH_EXTENSION_TYPE, body)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rand.IntN(256))
+	}
+	return b
+}
+
+// profileLabel names the padding in the ClientHello box.
+func profileLabel() string {
+	if *helloProfile == "" {
+		return "padding"
+	}
+	return fmt.Sprintf("%s profile", *helloProfile)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// extensionTypes splits b into extensions and returns their types, or an
+// error if b is not a whole number of well-formed extensions.
+func extensionTypes(b []byte) ([]uint16, error) {
+	var types []uint16
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("%d stray bytes", len(b))
+		}
+		n := 4 + int(binary.BigEndian.Uint16(b[2:4]))
+		if n > len(b) {
+			return nil, fmt.Errorf("extension %#04x runs %d bytes past the end", binary.BigEndian.Uint16(b), n-len(b))
+		}
+		types = append(types, binary.BigEndian.Uint16(b))
+		b = b[n:]
+	}
+	return types, nil
+}
+
+func TestHelloPadding(t *testing.T) {
+	defer func(profile, target string) { *helloProfile, *targetAddr = profile, target }(*helloProfile, *targetAddr)
+	*targetAddr = "pqc.example.com:443"
+	for _, profile := range profileNames() {
+		*helloProfile = profile
+		for n := 4; n <= 1500; n++ {
+			b := helloPadding(n)
+			if len(b) != n {
+				t.Fatalf("%s: helloPadding(%d) is %d bytes", profile, n, len(b))
+			}
+			types, err := extensionTypes(b)
+			if err != nil {
+				t.Fatalf("%s: helloPadding(%d): %v", profile, n, err)
+			}
+			seen := make(map[uint16]bool)
+			for _, typ := range types {
+				if seen[typ] {
+					t.Fatalf("%s: helloPadding(%d) carries extension %#04x twice", profile, n, typ)
+				}
+				seen[typ] = true
+			}
+		}
+	}
+}
+
+func TestGreasePair(t *testing.T) {
+	for range 2000 {
+		first, last := greasePair()
+		if first == last {
+			t.Fatalf("greasePair() = %#04x twice", first)
+		}
+		for _, v := range []uint16{first, last} {
+			if v&0x0f0f != 0x0a0a || v>>8 != v&0xff {
+				t.Fatalf("greasePair() gave %#04x, not a GREASE value", v)
+			}
+		}
+	}
+}
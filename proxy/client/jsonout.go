@@ -0,0 +1,147 @@
+/*
+JSON Output
+===========
+-json replaces the banner and logs with one JSON object on stdout, for CI
+jobs and wrappers that act on the outcome:
+
+  go run ./client -json -padding 300 | jq .ok
+
+The object names the target, algorithm, -profile and padding, the address
+family (of -4, -6 or -source, else of the first connection) and
+-source/-interface (bind.go), -df and -mss (sockopt.go), the mode that ran
+and whether it went well ("ok": every handshake completed, the sweep ran,
+every test of the suite passed or fuzzing found nothing) with the exit
+code (exitcode.go), and holds that mode's results:
+
+  handshake, repeat  "handshakes": outcome, ClientHello and ServerHello
+                     bytes, latency, the payload budget and whether the
+                     hello exceeded it, the proxy's verdict with
+                     -proxy-status, key confirmation, the error, and
+                     the connection's address family and addresses, its
+                     MSS and the ClientHello's TCP segments, the time of
+                     each phase (phases.go); with -fallback the algorithm
+                     that ran last and every level tried
+  batch              "batch": the batch summary with percentiles per phase
+                     (batch.go)
+  load               "load": the batch summary plus the rate, missed
+                     handshakes and the proxy's costs (load.go)
+  padding_sweep      "padding_sweep": the thresholds and every trial
+                     (padsweep.go)
+  suite              "suite": the passed and failed counts and every
+                     test with its expectation, failures, handshakes and
+                     the proxy's verdicts (suite.go)
+  fuzz               "fuzz": the proxy's reactions, the hangs, crashes
+                     and inconsistent verdicts, and every case with its
+                     findings (fuzz.go)
+
+An error that stops the run is its "error". Invalid flags are still
+reported on stderr, before anything runs.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+// clientResult is the object -json prints.
+type clientResult struct {
+	Target     string              `json:"target"`
+	Algorithm  string              `json:"algorithm"`
+	Profile    string              `json:"profile,omitempty"`
+	Padding    int                 `json:"padding"`
+	Family     string              `json:"address_family,omitempty"` // bind.go
+	Source     string              `json:"source_address,omitempty"`
+	Interface  string              `json:"interface,omitempty"`
+	DF         string              `json:"df_policy,omitempty"` // sockopt.go
+	MSS        int                 `json:"mss,omitempty"`
+	Mode       string              `json:"mode"`
+	OK         bool                `json:"ok"`
+	ExitCode   int                 `json:"exit_code"` // exitcode.go
+	Error      string              `json:"error,omitempty"`
+	Handshakes []handshakeResult   `json:"handshakes,omitempty"`
+	Batch      *batchSummary       `json:"batch,omitempty"`
+	Load       *loadSummary        `json:"load,omitempty"`
+	Sweep      *paddingSweepResult `json:"padding_sweep,omitempty"`
+	Suite      *suiteResult        `json:"suite,omitempty"`
+	Fuzz       *fuzzResult         `json:"fuzz,omitempty"`
+}
+
+// MarshalJSON spells out the latency in milliseconds and the error as text.
+func (r handshakeResult) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Outcome    string            `json:"outcome"`
+		Sent       int               `json:"client_hello_bytes"`
+		Received   int               `json:"server_hello_bytes"`
+		LatencyMs  float64           `json:"latency_ms"`
+		Budget     int               `json:"budget_bytes,omitempty"`
+		OverBudget bool              `json:"exceeds_budget"`
+		Status     string            `json:"proxy_status,omitempty"`
+		Confirmed  bool              `json:"key_confirmed"`
+		Error      string            `json:"error,omitempty"`
+		Algorithm  string            `json:"algorithm,omitempty"`
+		Attempts   []fallbackAttempt `json:"fallback,omitempty"`
+		Family     string            `json:"address_family,omitempty"`
+		Local      string            `json:"local_address,omitempty"`
+		Remote     string            `json:"remote_address,omitempty"`
+		MSS        int               `json:"mss,omitempty"`
+		Segments   int               `json:"client_hello_segments,omitempty"`
+		Phases     *handshakePhases  `json:"phases_ms,omitempty"`
+	}{r.Outcome, r.Sent, r.Received, durationMs(r.Latency), r.Budget, r.fragmented(),
+		r.ProxyStatus, r.Confirmed, "", r.Algorithm, r.Attempts,
+		familyName(addrFamily(r.Remote)), "", "", r.MSS, r.Segments, r.Phases}
+	if r.Err != nil {
+		out.Error = r.Err.Error()
+	}
+	if r.Remote != nil {
+		out.Local, out.Remote = r.Local.String(), r.Remote.String()
+	}
+	return json.Marshal(out)
+}
+
+// familyName is a family as the results name it: "ipv4", "ipv6" or "".
+func familyName(family string) string {
+	if family == "" {
+		return ""
+	}
+	return "ipv" + family
+}
+
+// finish prints the result with -json and exits with code.
+func (r *clientResult) finish(code int) {
+	r.ExitCode = code
+	if r.Family == "" && len(r.Handshakes) > 0 {
+		r.Family = familyName(addrFamily(r.Handshakes[0].Remote))
+	}
+	if *jsonOut {
+		r.print()
+	}
+	os.Exit(code)
+}
+
+// fail ends the run on err, logged as format or, with -json, as the
+// result's error.
+func (r *clientResult) fail(format string, err error) {
+	code := failCode(err)
+	if !*jsonOut {
+		log.Printf(format, err)
+		os.Exit(code)
+	}
+	r.OK, r.Error, r.ExitCode = false, err.Error(), code
+	r.print()
+	os.Exit(code)
+}
+
+func (r *clientResult) print() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(r)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,91 @@
+/*
+Key-Share Prediction
+====================
+With -key-share predict the client first sends a ClientHello
+carrying only a small X25519 key share. A PQ-preferring server answers with a
+HelloRetryRequest naming the ML-KEM group, and only then is the full
+1184-byte key share sent (proxy scenario "hrr").
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// hrrRandom is SHA-256("HelloRetryRequest"), the ServerHello.random of an HRR.
+var hrrRandom = []byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11, 0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e, 0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
+// sendPredictedHello sends the X25519-only first flight and waits for the
+// server's HelloRetryRequest. It returns the bytes sent and received.
+func sendPredictedHello(conn net.Conn, headerSize int) (int, int, error) {
+	share, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return 0, 0, err
+	}
+	hello := append(share.PublicKey().Bytes(), helloPadding(headerSize)...)
+
+	log.Println()
+	log.Printf("[PREDICT] Sending ClientHello with predicted X25519 share (%d bytes)...", len(hello))
+	if _, err := conn.Write(hello); err != nil {
+		return 0, 0, err
+	}
+
+	buffer := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(*ioTimeout))
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return len(hello), 0, fmt.Errorf("no response to predicted hello: %w", err)
+	}
+	if !isHelloRetryRequest(buffer[:n]) {
+		return len(hello), n, fmt.Errorf("expected HelloRetryRequest, got %d bytes", n)
+	}
+	group := selectedGroup(buffer[:n])
+	log.Printf("[PREDICT] HelloRetryRequest (%d bytes): server wants group 0x%04x, retrying with full share", n, group)
+	return len(hello), n, nil
+}
+
+func isHelloRetryRequest(record []byte) bool {
+	return len(record) >= 5+4+2+32 && record[0] == 0x16 &&
+		record[5] == 0x02 && bytes.Equal(record[11:43], hrrRandom)
+}
+
+// selectedGroup extracts the key_share selected_group from an HRR.
+func selectedGroup(record []byte) uint16 {
+	body := record[9:]
+	pos := 34
+	if pos >= len(body) {
+		return 0
+	}
+	pos += 1 + int(body[pos]) + 3 + 2 // session_id, cipher, compression, ext length
+	for pos+4 <= len(body) {
+		extType := binary.BigEndian.Uint16(body[pos:])
+		extLen := int(binary.BigEndian.Uint16(body[pos+2:]))
+		if extType == 0x0033 && extLen == 2 && pos+6 <= len(body) {
+			return binary.BigEndian.Uint16(body[pos+4:])
+		}
+		pos += 4 + extLen
+	}
+	return 0
+}
+
+func logStrategyComparison(predictSent, predictRecv, fullSent, fullRecv int) {
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│        KEY-SHARE STRATEGY COMPARISON        │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ predict (used): %-27s │\n", fmt.Sprintf("%d↑ %d↓ bytes, 2 RTT", predictSent+fullSent, predictRecv+fullRecv))
+	log.Printf("│ full:           %-27s │\n", fmt.Sprintf("%d↑ %d↓ bytes, 1 RTT", fullSent, fullRecv))
+	log.Printf("│ First flight:   %-27s │\n", fmt.Sprintf("%d vs %d bytes", predictSent, fullSent))
+	log.Println("└─────────────────────────────────────────────┘")
+}
@@ -0,0 +1,352 @@
+/*
+Load Mode
+=========
+-rate R holds the proxy at R handshakes per second for -duration, to see
+what PQC key exchanges cost once there are many of them:
+
+  go run ./client -rate 200 -duration 2m -ramp 30s -proxy-status http://127.0.0.1:9090/status
+
+The handshakes are started on a schedule (open loop): a slow proxy does not
+slow the client down, it piles up handshakes in flight. -ramp raises the
+rate linearly from zero first. A handshake due while -max-inflight are
+still running is not started and counts as missed, which is the sign the
+proxy (or the client host) has stopped keeping up.
+
+Every few seconds a progress line shows the target and achieved rate; the
+summary at the end is the batch summary (batch.go) plus the throughput at
+full rate. With -proxy-status the client also polls the proxy's /status
+(bearer token from $SENTINEL_API_TOKEN when the API asks for one) and
+reports the proxy's CPU time per handshake, its heap, goroutines and
+active connections at their peak, and the garbage collections of the run.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	LOAD_TICK     = 10 * time.Millisecond // how often due handshakes are started
+	LOAD_PROGRESS = 5 * time.Second       // between progress lines
+	LOAD_SAMPLE   = time.Second           // between polls of -proxy-status
+)
+
+// proxyStatus is the part of the proxy's /status a load run follows.
+type proxyStatus struct {
+	Connections struct {
+		Active  int64 `json:"active"`
+		Handled int64 `json:"handled"`
+	} `json:"connections"`
+	Process struct {
+		Goroutines int     `json:"goroutines"`
+		HeapBytes  uint64  `json:"heap_bytes"`
+		GCCycles   uint32  `json:"gc_cycles"`
+		GCPauseMs  float64 `json:"gc_pause_ms"`
+		CPUSeconds float64 `json:"cpu_seconds"`
+	} `json:"process"`
+}
+
+// proxyWatch polls the proxy's /status during a load run and keeps the
+// first and last answers and the peaks in between.
+type proxyWatch struct {
+	url    string
+	client *http.Client
+
+	mu            sync.Mutex
+	first, last   *proxyStatus
+	maxHeap       uint64
+	maxGoroutines int
+	maxActive     int64
+	failures      int
+	lastErr       error
+}
+
+func (p *proxyWatch) fetch() (*proxyStatus, error) {
+	var st proxyStatus
+	if err := proxyGet(p.client, p.url, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// proxyGet decodes the JSON answer of the proxy's API at url into v, with
+// the bearer token from $SENTINEL_API_TOKEN when there is one.
+func proxyGet(client *http.Client, url string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	proxyAuth(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// A 503 from /status only means a report sink is failing; the document is the same
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	return nil
+}
+
+// proxyAuth adds the bearer token from $SENTINEL_API_TOKEN to req when
+// there is one.
+func proxyAuth(req *http.Request) {
+	if token := os.Getenv("SENTINEL_API_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// sample polls the proxy once and records the answer.
+func (p *proxyWatch) sample() {
+	st, err := p.fetch()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.failures++
+		p.lastErr = err
+		return
+	}
+	if p.first == nil {
+		p.first = st
+	}
+	p.last = st
+	p.maxHeap = max(p.maxHeap, st.Process.HeapBytes)
+	p.maxGoroutines = max(p.maxGoroutines, st.Process.Goroutines)
+	p.maxActive = max(p.maxActive, st.Connections.Active)
+}
+
+// watch samples the proxy until stop is closed.
+func (p *proxyWatch) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(LOAD_SAMPLE)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.sample()
+		}
+	}
+}
+
+// progress is the proxy's part of a progress line.
+func (p *proxyWatch) progress() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last == nil {
+		return ""
+	}
+	return fmt.Sprintf("  proxy: %d active, %d goroutines, heap %.1f MB", p.last.Connections.Active, p.last.Process.Goroutines, mb(p.last.Process.HeapBytes))
+}
+
+// proxyCost is what a load run cost the proxy.
+type proxyCost struct {
+	Handled        int64   `json:"connections_handled"`
+	CPUSeconds     float64 `json:"cpu_seconds"`
+	CPUPerConnMs   float64 `json:"cpu_ms_per_connection,omitempty"`
+	HeapStartBytes uint64  `json:"heap_start_bytes"`
+	HeapMaxBytes   uint64  `json:"heap_max_bytes"`
+	HeapEndBytes   uint64  `json:"heap_end_bytes"`
+	MaxGoroutines  int     `json:"max_goroutines"`
+	MaxActive      int64   `json:"max_active_connections"`
+	GCCycles       uint32  `json:"gc_cycles"`
+	GCPauseMs      float64 `json:"gc_pause_ms"`
+	PollFailures   int     `json:"poll_failures,omitempty"`
+}
+
+// summary logs and returns what the run cost the proxy, nil without
+// enough answers to compare.
+func (p *proxyWatch) summary() *proxyCost {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		log.Printf("[LOAD] ⚠️  %d polls of %s failed, the last: %v", p.failures, p.url, p.lastErr)
+	}
+	if p.first == nil || p.last == p.first {
+		log.Printf("[LOAD] Proxy:    not enough answers from %s to compare", p.url)
+		return nil
+	}
+	c := &proxyCost{
+		Handled:        p.last.Connections.Handled - p.first.Connections.Handled,
+		CPUSeconds:     p.last.Process.CPUSeconds - p.first.Process.CPUSeconds,
+		HeapStartBytes: p.first.Process.HeapBytes,
+		HeapMaxBytes:   p.maxHeap,
+		HeapEndBytes:   p.last.Process.HeapBytes,
+		MaxGoroutines:  p.maxGoroutines,
+		MaxActive:      p.maxActive,
+		GCCycles:       p.last.Process.GCCycles - p.first.Process.GCCycles,
+		GCPauseMs:      p.last.Process.GCPauseMs - p.first.Process.GCPauseMs,
+		PollFailures:   p.failures,
+	}
+	perHandshake := "-"
+	if c.Handled > 0 {
+		c.CPUPerConnMs = c.CPUSeconds / float64(c.Handled) * 1000
+		perHandshake = fmt.Sprintf("%.2f ms", c.CPUPerConnMs)
+	}
+	log.Printf("[LOAD] Proxy:    %d connections handled, CPU %.2f s (%s per connection)", c.Handled, c.CPUSeconds, perHandshake)
+	log.Printf("[LOAD] Proxy:    heap %.1f → max %.1f → %.1f MB, max %d goroutines, max %d active connections",
+		mb(c.HeapStartBytes), mb(c.HeapMaxBytes), mb(c.HeapEndBytes), c.MaxGoroutines, c.MaxActive)
+	log.Printf("[LOAD] Proxy:    %d GC cycles, %.1f ms paused", c.GCCycles, c.GCPauseMs)
+	return c
+}
+
+func mb(b uint64) float64 { return float64(b) / (1 << 20) }
+
+// loadSchedule is how many handshakes are due after elapsed, at rate per
+// second reached linearly over ramp.
+func loadSchedule(rate float64, ramp, elapsed time.Duration) int {
+	t := elapsed.Seconds()
+	r := ramp.Seconds()
+	if t < r {
+		return int(rate * t * t / (2 * r))
+	}
+	return int(rate*r/2 + rate*(t-r))
+}
+
+// loadSummary is the batch summary of a load run and what it adds.
+type loadSummary struct {
+	batchSummary
+	TargetRate float64    `json:"target_rate"`
+	FullRate   float64    `json:"full_rate_completed_per_second,omitempty"` // after the ramp
+	Missed     int        `json:"missed"`
+	Proxy      *proxyCost `json:"proxy,omitempty"`
+}
+
+// runLoad starts handshakes at rate per second for duration, the first ramp
+// of it ramping up, and logs and returns their summary.
+func runLoad(scheme kem.Scheme, rate float64, duration, ramp time.Duration, maxInFlight int, statusURL string) loadSummary {
+	log.Println()
+	log.Printf("[LOAD] %.1f handshakes/s for %v (ramp-up %v), at most %d in flight...", rate, duration, ramp, maxInFlight)
+
+	var watch *proxyWatch
+	stop := make(chan struct{})
+	if statusURL != "" {
+		watch = &proxyWatch{url: statusURL, client: &http.Client{Timeout: *ioTimeout}}
+		watch.sample()
+		if watch.first == nil {
+			log.Printf("[LOAD] ⚠️  %v; going on without the proxy's figures", watch.lastErr)
+			watch = nil
+		} else {
+			go watch.watch(stop)
+		}
+	}
+
+	type finished struct {
+		at  time.Duration // since the start
+		res handshakeResult
+	}
+	var (
+		mu       sync.Mutex
+		done     []finished
+		wg       sync.WaitGroup
+		inFlight = make(chan struct{}, maxInFlight)
+		started  int
+		missed   int
+	)
+
+	// Progress bypasses the log: the handshakes' own output is discarded
+	out := log.Writer()
+	progress := log.New(out, log.Prefix(), log.Flags())
+	log.SetOutput(io.Discard)
+	start := time.Now()
+	tick := time.NewTicker(LOAD_TICK)
+	report := time.NewTicker(LOAD_PROGRESS)
+	lastReport, lastDone := time.Duration(0), 0
+	for elapsed := time.Duration(0); elapsed < duration; elapsed = time.Since(start) {
+		select {
+		case <-tick.C:
+			due := loadSchedule(rate, ramp, min(time.Since(start), duration))
+			for started+missed < due {
+				select {
+				case inFlight <- struct{}{}:
+					started++
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						res := runHandshake(scheme)
+						mu.Lock()
+						done = append(done, finished{time.Since(start), res})
+						mu.Unlock()
+						<-inFlight
+					}()
+				default:
+					missed++
+				}
+			}
+		case <-report.C:
+			elapsed = time.Since(start)
+			mu.Lock()
+			n := len(done)
+			var latency []time.Duration
+			for _, f := range done[lastDone:] {
+				if f.res.Latency > 0 {
+					latency = append(latency, f.res.Latency)
+				}
+			}
+			mu.Unlock()
+			p95 := "-"
+			if len(latency) > 0 {
+				slices.Sort(latency)
+				p95 = fmt.Sprintf("%.1f ms", float64(rank(latency, 95))/float64(time.Millisecond))
+			}
+			target := rate
+			if elapsed < ramp {
+				target = rate * elapsed.Seconds() / ramp.Seconds()
+			}
+			line := fmt.Sprintf("[LOAD] %3.0f%%  target %.1f/s  done %.1f/s  p95 %s  in flight %d  missed %d",
+				elapsed.Seconds()/duration.Seconds()*100, target, float64(n-lastDone)/(elapsed-lastReport).Seconds(), p95, len(inFlight), missed)
+			if watch != nil {
+				line += watch.progress()
+			}
+			progress.Print(line)
+			lastReport, lastDone = elapsed, n
+		}
+	}
+	tick.Stop()
+	report.Stop()
+	progress.Printf("[LOAD] waiting for %d handshakes in flight...", len(inFlight))
+	wg.Wait()
+	elapsed := time.Since(start)
+	close(stop)
+	if watch != nil {
+		watch.sample()
+	}
+	log.SetOutput(out)
+
+	results := make([]handshakeResult, len(done))
+	fullRate := 0
+	for i, f := range done {
+		results[i] = f.res
+		if f.at >= ramp && f.at < duration && f.res.Outcome == OUTCOME_COMPLETED {
+			fullRate++
+		}
+	}
+	s := loadSummary{batchSummary: summarize(results, elapsed), TargetRate: rate, Missed: missed}
+	s.log("LOAD", fmt.Sprintf("%.1f/s target", rate))
+	if steady := duration - ramp; steady > 0 {
+		s.FullRate = float64(fullRate) / steady.Seconds()
+		log.Printf("[LOAD] Throughput at full rate: %.1f completed/s of %.1f/s", s.FullRate, rate)
+	}
+	if missed > 0 {
+		log.Printf("[LOAD] ❌ %d handshakes not started: %d already in flight (-max-inflight)", missed, maxInFlight)
+	}
+	if watch != nil {
+		s.Proxy = watch.summary()
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+/*
+Middlebox Interference Probe
+============================
+With -middlebox the client (proxy scenario "middlebox") sends a real
+TLS 1.3 ClientHello with the ML-KEM key share and a probe extension holding
+its announced MSS, the TCP options its SYN offered and a SHA-256 of the whole
+record. If that hello gets no answer, the same-size hello is retried without
+TLS 1.3 in supported_versions: if only that one passes, a middlebox is
+TLS-version intolerant rather than dropping large packets.
+
+The proxy's answer carries the hash of what it received plus a known
+pattern, so modifications are attributed to the upstream or downstream path.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+const (
+	MIDDLEBOX_PROBE_EXTENSION = 0xfd5c
+	MIDDLEBOX_ECHO_SIZE       = 256
+	TLS_GROUP_MLKEM           = 0x0201
+
+	PROBE_VARIANT_TLS13 = 1
+	PROBE_VARIANT_TLS12 = 2
+)
+
+// runMiddleboxProbe runs the TLS 1.3 probe and, if it fails, the fallback.
+func runMiddleboxProbe(pkBytes []byte, address string) error {
+	log.Println()
+	log.Println("[MIDDLEBOX] Probing for middlebox interference...")
+
+	err13 := middleboxAttempt(pkBytes, address, PROBE_VARIANT_TLS13)
+	if err13 == nil {
+		return nil
+	}
+	log.Printf("⚠️  [MIDDLEBOX] TLS 1.3 hello failed (%v), retrying the same size without TLS 1.3", err13)
+
+	if err := middleboxAttempt(pkBytes, address, PROBE_VARIANT_TLS12); err != nil {
+		return fmt.Errorf("both hellos failed (%v / %v): size or path problem, not version intolerance", err13, err)
+	}
+	log.Println()
+	log.Println("╔═══════════════════════════════════════════════════════════════════╗")
+	log.Println("║              🧱 TLS VERSION INTOLERANCE DETECTED                  ║")
+	log.Println("╠═══════════════════════════════════════════════════════════════════╣")
+	log.Println("║  A same-size hello without TLS 1.3 passed where the TLS 1.3 one   ║")
+	log.Println("║  failed: a middlebox, not fragmentation, breaks the handshake.    ║")
+	log.Println("╚═══════════════════════════════════════════════════════════════════╝")
+	return nil
+}
+
+func middleboxAttempt(pkBytes []byte, address string, variant byte) error {
+	conn, err := dialProxy("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	advmss, options := localTCPOffer(conn)
+	hello := buildProbeHello(pkBytes, variant, advmss, options)
+	sent := sha256.Sum256(hello)
+	copy(hello[len(hello)-sha256.Size:], sent[:])
+
+	log.Printf("[SEND] Probe ClientHello (variant %d, %d bytes, advmss %d, options 0x%02x)", variant, len(hello), advmss, options)
+	if _, err := conn.Write(hello); err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(*ioTimeout))
+	answer := make([]byte, 5+sha256.Size+MIDDLEBOX_ECHO_SIZE+sha256.Size)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return fmt.Errorf("no probe answer: %w", err)
+	}
+	content := answer[5:]
+
+	// Upstream: did the proxy see what we sent?
+	if bytes.Equal(content[:sha256.Size], sent[:]) {
+		log.Println("[MIDDLEBOX] ✅ Upstream: ClientHello arrived unmodified")
+	} else {
+		log.Println("[MIDDLEBOX] ⚠️  Upstream: ClientHello was modified in transit")
+	}
+
+	// Downstream: did the known pattern arrive intact?
+	pattern := content[sha256.Size : sha256.Size+MIDDLEBOX_ECHO_SIZE]
+	patternHash := sha256.Sum256(pattern)
+	intact := bytes.Equal(patternHash[:], content[sha256.Size+MIDDLEBOX_ECHO_SIZE:])
+	for i, b := range pattern {
+		intact = intact && b == byte(i)
+	}
+	if intact {
+		log.Println("[MIDDLEBOX] ✅ Downstream: answer arrived unmodified")
+	} else {
+		log.Println("[MIDDLEBOX] ⚠️  Downstream: answer was modified in transit")
+	}
+	log.Println("[MIDDLEBOX] See the proxy's report for TCP option and MSS findings")
+	return nil
+}
+
+// buildProbeHello builds a TLS ClientHello record with the ML-KEM key share
+// and the probe extension last; its hash field is left zero.
+func buildProbeHello(pkBytes []byte, variant byte, advmss int, options byte) []byte {
+	var ext []byte
+	if variant == PROBE_VARIANT_TLS13 {
+		ext = binary.BigEndian.AppendUint16(ext, 0x002b) // supported_versions
+		ext = binary.BigEndian.AppendUint16(ext, 7)
+		ext = append(ext, 6, 0x0a, 0x0a, 0x03, 0x04, 0x03, 0x03) // GREASE, 1.3, 1.2
+	} else {
+		ext = binary.BigEndian.AppendUint16(ext, 0x0015) // padding, same size
+		ext = binary.BigEndian.AppendUint16(ext, 7)
+		ext = append(ext, make([]byte, 7)...)
+	}
+	ext = binary.BigEndian.AppendUint16(ext, 0x000a) // supported_groups
+	ext = binary.BigEndian.AppendUint16(ext, 4)
+	ext = binary.BigEndian.AppendUint16(ext, 2)
+	ext = binary.BigEndian.AppendUint16(ext, TLS_GROUP_MLKEM)
+	ext = binary.BigEndian.AppendUint16(ext, 0x0033) // key_share
+	ext = binary.BigEndian.AppendUint16(ext, uint16(2+4+len(pkBytes)))
+	ext = binary.BigEndian.AppendUint16(ext, uint16(4+len(pkBytes)))
+	ext = binary.BigEndian.AppendUint16(ext, TLS_GROUP_MLKEM)
+	ext = binary.BigEndian.AppendUint16(ext, uint16(len(pkBytes)))
+	ext = append(ext, pkBytes...)
+	ext = binary.BigEndian.AppendUint16(ext, MIDDLEBOX_PROBE_EXTENSION)
+	ext = binary.BigEndian.AppendUint16(ext, 1+2+1+sha256.Size)
+	ext = append(ext, variant)
+	ext = binary.BigEndian.AppendUint16(ext, uint16(advmss))
+	ext = append(ext, options)
+	ext = append(ext, make([]byte, sha256.Size)...)
+
+	random := make([]byte, 32)
+	sessionID := make([]byte, 32)
+	rand.Read(random)
+	rand.Read(sessionID)
+
+	body := binary.BigEndian.AppendUint16(nil, 0x0303)
+	body = append(body, random...)
+	body = append(body, byte(len(sessionID)))
+	body = append(body, sessionID...)
+	body = append(body, 0, 6, 0x13, 0x01, 0x13, 0x02, 0xc0, 0x2f) // cipher suites
+	body = append(body, 1, 0)                                     // null compression
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ext)))
+	body = append(body, ext...)
+
+	handshake := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	record := []byte{0x16, 0x03, 0x01}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
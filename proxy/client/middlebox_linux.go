@@ -0,0 +1,40 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// localTCPOffer returns the MSS and TCP options (timestamps, SACK, window
+// scaling bits) this host put into its SYN.
+func localTCPOffer(conn net.Conn) (int, byte) {
+	advmss := 0
+	if sc, ok := conn.(syscall.Conn); ok {
+		if raw, err := sc.SyscallConn(); err == nil {
+			raw.Control(func(fd uintptr) {
+				if info, err := unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO); err == nil {
+					advmss = int(info.Advmss)
+				}
+			})
+		}
+	}
+
+	var options byte
+	for sysctl, bit := range map[string]byte{
+		"tcp_timestamps":     1 << 0,
+		"tcp_sack":           1 << 1,
+		"tcp_window_scaling": 1 << 2,
+	} {
+		v, err := os.ReadFile("/proc/sys/net/ipv4/" + sysctl)
+		if err == nil && strings.TrimSpace(string(v)) != "0" {
+			options |= bit
+		}
+	}
+	return advmss, options
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "net"
+
+// localTCPOffer cannot read the SYN options here; 0xff tells the proxy to
+// skip the TCP option check.
+func localTCPOffer(conn net.Conn) (int, byte) {
+	return 0, 0xff
+}
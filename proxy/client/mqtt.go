@@ -0,0 +1,44 @@
+/*
+MQTT Device Session
+===================
+After the PQC key exchange, a constrained device opens its MQTT session.
+Used with the proxy's "mqtt" scenario, which prices the handshake on
+NB-IoT / LTE-M / 6LoWPAN links.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// mqttConnect sends an MQTT 3.1.1 CONNECT over the established session and
+// waits for CONNACK, as a device would right after its TLS handshake.
+func mqttConnect(conn net.Conn) error {
+	clientID := "sentinel-pqc-device"
+
+	variable := []byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0x02, 0, 60} // level 4, clean session, keepalive 60s
+	payload := append([]byte{0, byte(len(clientID))}, clientID...)
+	connect := append([]byte{0x10, byte(len(variable) + len(payload))}, variable...)
+	connect = append(connect, payload...)
+
+	log.Println()
+	log.Printf("[MQTT] Sending CONNECT (%d bytes)...", len(connect))
+	if _, err := conn.Write(connect); err != nil {
+		return err
+	}
+
+	connack := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(*ioTimeout))
+	if _, err := conn.Read(connack); err != nil {
+		return err
+	}
+	if connack[0] != 0x20 || connack[3] != 0 {
+		return fmt.Errorf("connection refused (CONNACK %x)", connack)
+	}
+	log.Printf("[MQTT] ✅ CONNACK received, session established")
+	return nil
+}
@@ -0,0 +1,323 @@
+/*
+Padding Sweep
+=============
+-sweep-padding MIN:MAX finds the padding at which things change instead
+of trying -padding values by hand. Each trial is a fresh handshake with the
+public key plus that much padding (the same hello as -padding sends), and
+the client reports three thresholds:
+
+  - where the proxy starts flagging the handshake (its report's status is
+    no longer SAFE), read from the proxy's API with -proxy-status
+  - where the hello no longer leaves in one TCP segment (TCP_INFO, Linux)
+  - where the network starts misbehaving: no or a short answer, a failed
+    decapsulation or retransmissions
+
+  go run ./client -sweep-padding 0:8000 -proxy-status http://127.0.0.1:9090/status
+  go run ./client -sweep-padding 0:1000:50 -target 10.0.0.5:4433
+
+With MIN:MAX each threshold is binary-searched to the byte, assuming that
+once something happens at a size it happens at every larger one;
+MIN:MAX:STEP tries every STEP bytes instead and prints a row per size,
+which shows a path that is not that simple. The proxy matches a trial to
+its report by the client address, so -anonymize on the proxy leaves the
+verdicts out.
+*/
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	PADDING_VERDICT_WAIT = 2 * time.Second // for the proxy to store a trial's report
+	PADDING_VERDICT_POLL = 100 * time.Millisecond
+)
+
+// paddingRange is -sweep-padding: MIN:MAX, or MIN:MAX:STEP for a linear sweep.
+type paddingRange struct {
+	Min, Max, Step int // Step 0 = binary search
+}
+
+func parsePaddingRange(s string) (paddingRange, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return paddingRange{}, fmt.Errorf("-sweep-padding %q: want MIN:MAX or MIN:MAX:STEP", s)
+	}
+	var n [3]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return paddingRange{}, fmt.Errorf("-sweep-padding %q: %q is not a byte count", s, p)
+		}
+		n[i] = v
+	}
+	r := paddingRange{Min: n[0], Max: n[1], Step: n[2]}
+	if r.Max <= r.Min || len(parts) == 3 && r.Step == 0 {
+		return paddingRange{}, fmt.Errorf("-sweep-padding %q: MAX must be above MIN and STEP positive", s)
+	}
+	return r, nil
+}
+
+// paddingTrial is one padding size and what came of it.
+type paddingTrial struct {
+	sweepTrial
+	Padding int    `json:"padding"`
+	Status  string `json:"proxy_status,omitempty"` // the proxy's verdict, "" when unknown
+}
+
+// paddingSweepResult is what a sweep found: the padding at which each
+// threshold was crossed, nil when it was not up to the maximum.
+type paddingSweepResult struct {
+	KeyShare     int            `json:"key_share_bytes"`
+	Min          int            `json:"min_padding"`
+	Max          int            `json:"max_padding"`
+	Step         int            `json:"step,omitempty"` // 0 = binary search
+	ProxyFlags   *int           `json:"proxy_flags,omitempty"`
+	LastSafe     *int           `json:"last_safe,omitempty"`
+	Segmented    *int           `json:"segmented"`
+	NetworkFails *int           `json:"network_fails"`
+	Trials       []paddingTrial `json:"trials"` // by padding
+}
+
+func (t paddingTrial) flagged() bool   { return t.Status != "" && t.Status != "SAFE" }
+func (t paddingTrial) segmented() bool { return t.Segments > 1 }
+func (t paddingTrial) misbehaved() bool {
+	return !t.Delivered || t.Retrans > 0
+}
+
+// paddingSweep runs the trials of a sweep, each padding at most once.
+type paddingSweep struct {
+	scheme  kem.Scheme
+	pkBytes []byte
+	sk      kem.PrivateKey
+	reports string // the proxy's /api/reports, "" for no verdicts
+	client  *http.Client
+	trials  map[int]paddingTrial
+}
+
+// try runs the handshake with padding bytes of padding, or recalls it.
+func (s *paddingSweep) try(padding int) paddingTrial {
+	if t, ok := s.trials[padding]; ok {
+		return t
+	}
+	t := paddingTrial{sweepTrial: sweepOnce(s.scheme, s.pkBytes, s.sk, *targetAddr, len(s.pkBytes)+padding), Padding: padding}
+	if s.reports != "" && t.Local != "" {
+		t.Status = s.verdict(t.Local)
+	}
+	s.trials[padding] = t
+
+	verdict := t.Status
+	if verdict == "" {
+		verdict = "-"
+	}
+	note := "one segment"
+	switch {
+	case t.Reason != "":
+		note = t.Reason
+	case t.Segments == 0:
+		note = "delivered"
+	}
+	mark := "✅"
+	if t.misbehaved() {
+		mark = "❌"
+	} else if t.flagged() || t.segmented() {
+		mark = "⚠️ "
+	}
+	log.Printf("[PADDING] %5d padding = %5d bytes: %s %-14s %s", padding, t.Size, mark, verdict, note)
+	return t
+}
+
+// verdict is the status of the proxy's report on the connection from local.
+func (s *paddingSweep) verdict(local string) string {
+	v, err := fetchVerdict(s.client, s.reports, local)
+	if err != nil {
+		log.Printf("[PADDING] ⚠️  %v", err)
+	}
+	if v == nil {
+		return ""
+	}
+	return v.Status
+}
+
+// proxyVerdict is how the proxy judged one connection.
+type proxyVerdict struct {
+	ClientIP string `json:"client_ip"`
+	Status   string `json:"status"`
+	Severity string `json:"severity,omitempty"`
+
+	HandshakeSize int `json:"handshake_size_bytes"`
+	MTUBudget     int `json:"mtu_budget_bytes,omitempty"`
+}
+
+// fetchVerdict reads the proxy's report on the connection from local from
+// its /api/reports at reports, waiting for the proxy to store it; nil when
+// it did not in time.
+func fetchVerdict(client *http.Client, reports, local string) (*proxyVerdict, error) {
+	host, _, err := net.SplitHostPort(local)
+	if err != nil {
+		return nil, err
+	}
+	var page struct {
+		Reports []proxyVerdict `json:"reports"`
+		Error   string         `json:"error"` // e.g. no report history
+	}
+	for deadline := time.Now().Add(PADDING_VERDICT_WAIT); time.Now().Before(deadline); time.Sleep(PADDING_VERDICT_POLL) {
+		if err := proxyGet(client, reports+"?limit=20&client="+url.QueryEscape(host), &page); err != nil {
+			return nil, err
+		}
+		if page.Error != "" {
+			return nil, fmt.Errorf("%s: %s", reports, page.Error)
+		}
+		for _, r := range page.Reports {
+			if r.ClientIP == local {
+				return &r, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// first is the smallest padding of the sweep for which happened holds, or
+// -1 when none does.
+func (s *paddingSweep) first(r paddingRange, happened func(paddingTrial) bool) int {
+	if r.Step > 0 {
+		for p := r.Min; p <= r.Max; p += r.Step {
+			if happened(s.try(p)) {
+				return p
+			}
+		}
+		return -1
+	}
+	if happened(s.try(r.Min)) {
+		return r.Min
+	}
+	if !happened(s.try(r.Max)) {
+		return -1
+	}
+	lo, hi := r.Min, r.Max // nothing at lo, happened at hi
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if happened(s.try(mid)) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
+
+// runPaddingSweep sweeps the padding over r and logs and returns the
+// thresholds found.
+func runPaddingSweep(scheme kem.Scheme, r paddingRange, statusURL string) (paddingSweepResult, error) {
+	pk, sk, err := scheme.GenerateKeyPair()
+	if err != nil {
+		return paddingSweepResult{}, fmt.Errorf("KeyGen failed: %w", err)
+	}
+	pkBytes, err := pk.MarshalBinary()
+	if err != nil {
+		return paddingSweepResult{}, fmt.Errorf("Failed to marshal public key: %w", err)
+	}
+	s := &paddingSweep{scheme: scheme, pkBytes: pkBytes, sk: sk, client: &http.Client{Timeout: *ioTimeout}, trials: make(map[int]paddingTrial)}
+	if statusURL != "" {
+		u, err := url.Parse(statusURL)
+		if err != nil {
+			return paddingSweepResult{}, fmt.Errorf("-proxy-status: %w", err)
+		}
+		u.Path, u.RawQuery = "/api/reports", ""
+		s.reports = u.String()
+	}
+
+	log.Println()
+	how := "binary search"
+	if r.Step > 0 {
+		how = fmt.Sprintf("every %d bytes", r.Step)
+	}
+	log.Printf("[PADDING] Sweeping %d-%d bytes of padding after a %d-byte key share (%s)...", r.Min, r.Max, len(pkBytes), how)
+	if s.reports == "" {
+		log.Printf("[PADDING] No -proxy-status: the proxy's verdicts are left out")
+	}
+
+	// A target that cannot be reached has no thresholds
+	if t := s.try(r.Min); t.dialErr != nil {
+		return paddingSweepResult{}, fmt.Errorf("%s: %w", *targetAddr, t.dialErr)
+	}
+
+	// A linear sweep tries every size, the thresholds are then looked up
+	for p := r.Min; r.Step > 0 && p <= r.Max; p += r.Step {
+		s.try(p)
+	}
+	flagged := -1
+	if s.reports != "" {
+		flagged = s.first(r, paddingTrial.flagged)
+	}
+	segmented := s.first(r, paddingTrial.segmented)
+	failed := s.first(r, paddingTrial.misbehaved)
+
+	size := func(padding int) string {
+		if padding < 0 {
+			return fmt.Sprintf("none up to %d", r.Max)
+		}
+		return fmt.Sprintf("%d (%d bytes)", padding, len(pkBytes)+padding)
+	}
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│            PADDING SWEEP RESULT             │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Key Share:      %-27s │\n", fmt.Sprintf("%d bytes (%s)", len(pkBytes), scheme.Name()))
+	res := paddingSweepResult{KeyShare: len(pkBytes), Min: r.Min, Max: r.Max, Step: r.Step,
+		Segmented: found(segmented), NetworkFails: found(failed)}
+	if s.reports != "" {
+		log.Printf("│ Proxy Flags:    %-27s │\n", size(flagged))
+		res.ProxyFlags = found(flagged)
+		if last, ok := s.trials[s.lastBelow(r, flagged)]; ok && flagged > r.Min && last.Status == "SAFE" {
+			log.Printf("│ Last SAFE:      %-27s │\n", size(last.Padding))
+			res.LastSafe = found(last.Padding)
+		}
+	}
+	log.Printf("│ Segmented:      %-27s │\n", size(segmented))
+	log.Printf("│ Network Fails:  %-27s │\n", size(failed))
+	log.Printf("│ Trials:         %-27d │\n", len(s.trials))
+	log.Println("└─────────────────────────────────────────────┘")
+	if flagged >= 0 {
+		log.Printf("[PADDING] At %d bytes of padding the proxy reports %s", flagged, s.trials[flagged].Status)
+	}
+	if failed >= 0 {
+		log.Printf("[PADDING] ❌ At %d bytes of padding: %s", failed, s.trials[failed].Reason)
+	}
+	if segmented >= 0 && s.reports != "" && (flagged < 0 || segmented < flagged) {
+		log.Printf("⚠️  The hello is segmented at %d bytes of padding but the proxy does not flag it there: its budget is above this path's", segmented)
+	}
+	for _, t := range s.trials {
+		res.Trials = append(res.Trials, t)
+	}
+	slices.SortFunc(res.Trials, func(a, b paddingTrial) int { return cmp.Compare(a.Padding, b.Padding) })
+	return res, nil
+}
+
+// found is a threshold's padding, nil for none (-1).
+func found(padding int) *int {
+	if padding < 0 {
+		return nil
+	}
+	return &padding
+}
+
+// lastBelow is the padding tried just before the first flagged one.
+func (s *paddingSweep) lastBelow(r paddingRange, flagged int) int {
+	if r.Step > 0 {
+		return flagged - r.Step
+	}
+	return flagged - 1
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParsePaddingRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    paddingRange
+		wantErr bool
+	}{
+		{in: "0:1200", want: paddingRange{Min: 0, Max: 1200}},
+		{in: "100:900:50", want: paddingRange{Min: 100, Max: 900, Step: 50}},
+		{in: "0:1", want: paddingRange{Min: 0, Max: 1}},
+		{in: "1200", wantErr: true},
+		{in: "0:100:10:1", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "a:100", wantErr: true},
+		{in: "0:", wantErr: true},
+		{in: "-10:100", wantErr: true},
+		{in: "100:100", wantErr: true},
+		{in: "200:100", wantErr: true},
+		{in: "0:100:0", wantErr: true},
+		{in: "0:100:-5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parsePaddingRange(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePaddingRange(%q) = %+v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("parsePaddingRange(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
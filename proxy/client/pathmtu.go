@@ -0,0 +1,134 @@
+/*
+Client Path MTU Probe
+=====================
+With -probe-mtu the client measures the path to the proxy before it
+sends the key share, instead of trusting the 1400-byte rule of thumb:
+
+  1. DF-flagged UDP probes to a closed port on the proxy host, binary
+     search on ICMP Port Unreachable (as the proxy's -pmtud, no root needed)
+  2. The MSS of the TCP connection itself (TCP_MAXSEG)
+
+The result rides in the ClientHello padding as a private extension, so the
+proxy's report shows it next to the threshold it judged the hello by:
+
+  type 0xfd5d(2) length(2) path MTU(2) TCP MSS(2) flags(1)
+  flags: 1 = probes were answered (verified)
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	PATH_MTU_EXTENSION = 0xfd5d
+	PATH_MTU_VERIFIED  = 1
+
+	PROBE_PORT     = 33434 // traceroute base port, normally closed
+	PROBE_TIMEOUT  = 300 * time.Millisecond
+	PROBE_MIN_IPV4 = 576
+	PROBE_MIN_IPV6 = 1280
+)
+
+// pathProbe is what the client learned about the path before the hello.
+type pathProbe struct {
+	MTU      int
+	RouteMTU int
+	Probes   int
+	Verified bool
+	MSS      int
+	IPHeader int
+}
+
+// budget is the TCP payload that fits in one packet on the probed path.
+func (p pathProbe) budget() int {
+	if p.MSS > 0 && (p.MTU == 0 || p.MSS < p.MTU-p.IPHeader-20) {
+		return p.MSS
+	}
+	return p.MTU - p.IPHeader - 20
+}
+
+// extension encodes the probe for the proxy.
+func (p pathProbe) extension() []byte {
+	ext := binary.BigEndian.AppendUint16(nil, PATH_MTU_EXTENSION)
+	ext = binary.BigEndian.AppendUint16(ext, 5)
+	ext = binary.BigEndian.AppendUint16(ext, uint16(p.MTU))
+	ext = binary.BigEndian.AppendUint16(ext, uint16(p.MSS))
+	flags := byte(0)
+	if p.Verified {
+		flags |= PATH_MTU_VERIFIED
+	}
+	return append(ext, flags)
+}
+
+// probePath measures the path to the proxy behind conn.
+func probePath(conn net.Conn) pathProbe {
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	probe := pathProbe{IPHeader: 20}
+	minMTU := PROBE_MIN_IPV4
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		probe.IPHeader, minMTU = 40, PROBE_MIN_IPV6
+	}
+
+	if err := probeUDP(&probe, host, minMTU); err != nil {
+		log.Printf("[PMTU] UDP probes to %s failed: %v", host, err)
+	}
+	if mss, err := connMSS(conn); err == nil {
+		probe.MSS = mss
+	} else {
+		log.Printf("[PMTU] Cannot read the TCP MSS: %v", err)
+	}
+	return probe
+}
+
+// searchMTU binary-searches the largest size for which send succeeds.
+func searchMTU(probe *pathProbe, minMTU int, send func(size int) bool) {
+	probe.MTU = probe.RouteMTU
+	probe.Probes++
+	if !send(minMTU) {
+		return // ICMP filtered: only the route MTU is known
+	}
+	probe.Verified = true
+	probe.Probes++
+	if send(probe.RouteMTU) {
+		return
+	}
+	lo, hi := minMTU, probe.RouteMTU
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		probe.Probes++
+		if send(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	probe.MTU = lo
+}
+
+func logPathProbe(p pathProbe, helloSize int) {
+	verified := "verified"
+	if !p.Verified {
+		verified = "unverified (no ICMP)"
+	}
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│            PATH MTU TO THE PROXY            │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Path MTU:       %-27s │\n", fmt.Sprintf("%d bytes (%s)", p.MTU, verified))
+	log.Printf("│ Route MTU:      %-27s │\n", fmt.Sprintf("%d bytes", p.RouteMTU))
+	if p.MSS > 0 {
+		log.Printf("│ TCP MSS:        %-27s │\n", fmt.Sprintf("%d bytes", p.MSS))
+	}
+	log.Printf("│ Probes:         %-27d │\n", p.Probes)
+	log.Printf("│ Payload Budget: %-27s │\n", fmt.Sprintf("%d bytes (static 1400)", p.budget()))
+	log.Println("└─────────────────────────────────────────────┘")
+	if helloSize > p.budget() {
+		log.Printf("⚠️  ClientHello (%d bytes) exceeds the measured budget: it will be segmented on this path", helloSize)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// probeUDP runs DF-flagged UDP probes towards host.
+func probeUDP(probe *pathProbe, host string, minMTU int) error {
+	conn, err := dialProxy("udp", net.JoinHostPort(host, strconv.Itoa(PROBE_PORT)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	level, discover, probeMode, mtuOpt := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE, unix.IP_MTU
+	if probe.IPHeader == 40 {
+		level, discover, probeMode, mtuOpt = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE, unix.IPV6_MTU
+	}
+	raw, err := conn.(syscall.Conn).SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	raw.Control(func(fd uintptr) {
+		if sockErr = unix.SetsockoptInt(int(fd), level, discover, probeMode); sockErr != nil {
+			return
+		}
+		probe.RouteMTU, sockErr = unix.GetsockoptInt(int(fd), level, mtuOpt)
+	})
+	if sockErr != nil {
+		return fmt.Errorf("socket options: %w", sockErr)
+	}
+
+	headers := probe.IPHeader + 8
+	reply := make([]byte, 64)
+	searchMTU(probe, minMTU, func(size int) bool {
+		if _, err := conn.Write(make([]byte, size-headers)); err != nil {
+			return false // EMSGSIZE: larger than the local interface
+		}
+		conn.SetReadDeadline(time.Now().Add(PROBE_TIMEOUT))
+		_, err := conn.Read(reply)
+		return err == nil || errors.Is(err, syscall.ECONNREFUSED)
+	})
+	return nil
+}
+
+// connMSS is the send MSS of a TCP connection.
+func connMSS(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("not a socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var mss int
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		mss, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG)
+	}); err != nil {
+		return 0, err
+	}
+	return mss, sockErr
+}
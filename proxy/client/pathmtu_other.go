@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func probeUDP(probe *pathProbe, host string, minMTU int) error {
+	return errors.New("DF probes are only supported on Linux")
+}
+
+func connMSS(conn net.Conn) (int, error) {
+	return 0, errors.New("TCP_MAXSEG is only read on Linux")
+}
@@ -0,0 +1,130 @@
+/*
+Latency Breakdown
+=================
+A slower handshake can be the KEM or the path. The client times each phase
+of the ClientHello simulation on its own:
+
+  keygen       generating the keypair (crypto)
+  connect      TCP connect to the proxy (network)
+  send         writing the ClientHello into the socket (network)
+  wait         from the write to the ServerHello, which includes the
+               proxy's encapsulation and any retransmissions (network)
+  decapsulate  deriving the shared secret (crypto)
+  confirm      the key confirmation round trip (network)
+
+A handshake logs the phases and their crypto and network sums, -json
+reports them per handshake as "phases_ms", and batch and load runs report
+percentiles per phase, so a regression shows up in the phase that caused it.
+The modes that run their own exchange (-noise, -quic, ...) are not broken
+down.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// handshakePhases is how long each phase of a handshake took; a phase the
+// handshake did not reach is 0.
+type handshakePhases struct {
+	Keygen      time.Duration
+	Connect     time.Duration
+	Send        time.Duration
+	Wait        time.Duration
+	Decapsulate time.Duration
+	Confirm     time.Duration
+}
+
+// PHASES names the phases in order and says which are crypto.
+var PHASES = []struct {
+	name   string
+	crypto bool
+	of     func(p handshakePhases) time.Duration
+}{
+	{"keygen", true, func(p handshakePhases) time.Duration { return p.Keygen }},
+	{"connect", false, func(p handshakePhases) time.Duration { return p.Connect }},
+	{"send", false, func(p handshakePhases) time.Duration { return p.Send }},
+	{"wait", false, func(p handshakePhases) time.Duration { return p.Wait }},
+	{"decapsulate", true, func(p handshakePhases) time.Duration { return p.Decapsulate }},
+	{"confirm", false, func(p handshakePhases) time.Duration { return p.Confirm }},
+}
+
+// split sums the crypto and the network phases.
+func (p handshakePhases) split() (crypto, network time.Duration) {
+	for _, ph := range PHASES {
+		if ph.crypto {
+			crypto += ph.of(p)
+		} else {
+			network += ph.of(p)
+		}
+	}
+	return crypto, network
+}
+
+func (p handshakePhases) MarshalJSON() ([]byte, error) {
+	crypto, network := p.split()
+	return json.Marshal(struct {
+		Keygen      float64 `json:"keygen"`
+		Connect     float64 `json:"connect"`
+		Send        float64 `json:"send"`
+		Wait        float64 `json:"wait"`
+		Decapsulate float64 `json:"decapsulate"`
+		Confirm     float64 `json:"confirm"`
+		Crypto      float64 `json:"crypto"`
+		Network     float64 `json:"network"`
+	}{durationMs(p.Keygen), durationMs(p.Connect), durationMs(p.Send), durationMs(p.Wait),
+		durationMs(p.Decapsulate), durationMs(p.Confirm), durationMs(crypto), durationMs(network)})
+}
+
+// log logs the breakdown of a handshake.
+func (p handshakePhases) log() {
+	crypto, network := p.split()
+	total := crypto + network
+	row := func(label string, d time.Duration) {
+		share := 0.0
+		if total > 0 {
+			share = float64(d) / float64(total) * 100
+		}
+		log.Printf("│ %-15s %-27s │\n", label+":", fmt.Sprintf("%8.3f ms %5.1f%%", durationMs(d), share))
+	}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│              LATENCY BREAKDOWN              │")
+	log.Println("├─────────────────────────────────────────────┤")
+	for _, ph := range PHASES {
+		row(ph.name, ph.of(p))
+	}
+	log.Println("├─────────────────────────────────────────────┤")
+	row("crypto", crypto)
+	row("network", network)
+	log.Println("└─────────────────────────────────────────────┘")
+}
+
+// phasePercentiles are the percentiles of each phase over the handshakes
+// that reached it, in ms; nil without broken-down handshakes.
+func phasePercentiles(results []handshakeResult) map[string]*percentiles {
+	values := make(map[string][]float64)
+	for _, r := range results {
+		if r.Phases == nil {
+			continue
+		}
+		for _, ph := range PHASES {
+			if d := ph.of(*r.Phases); d > 0 {
+				values[ph.name] = append(values[ph.name], durationMs(d))
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	out := make(map[string]*percentiles, len(values))
+	for name, v := range values {
+		out[name] = percentilesOf(v)
+	}
+	return out
+}
@@ -0,0 +1,488 @@
+/*
+QUIC Initial Simulation
+=======================
+-quic sends the ClientHello the way a QUIC client does, to the proxy's
+"quic" scenario: in CRYPTO frames of Initial packets (RFC 9001 packet
+protection), every datagram padded to 1200 bytes. A ClientHello with an
+ML-KEM key share does not fit in one Initial, so it takes several
+datagrams, none of them IP-fragmented, and each one can be lost on its own.
+
+The proxy acknowledges every packet. On silence the client retransmits the
+CRYPTO data of the packets not acknowledged yet, under new packet numbers,
+with the wait doubling as in datagram.go; once everything is acknowledged it
+sends a probe instead, so the proxy resends a lost ServerHello. The result
+box shows the datagrams sent and lost, the server's datagrams against the
+anti-amplification limit (three times the client's bytes, RFC 9000 8.1) and
+the time to the ServerHello.
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	QUIC_DATAGRAM_SIZE = 1200 // RFC 9000 14.1: Initial datagrams are padded to at least this
+	QUIC_CID_SIZE      = 8
+	QUIC_TAG_SIZE      = 16
+	QUIC_AMPLIFY       = 3
+)
+
+// RFC 9001 5.2
+var quicInitialSalt = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+
+// quicChunk is the part of the ClientHello one datagram carries.
+type quicChunk struct {
+	offset int
+	data   []byte
+	acked  bool
+}
+
+// runQUICHandshake sends the ClientHello in Initial datagrams and
+// decapsulates the ServerHello that comes back in the proxy's.
+func runQUICHandshake(scheme kem.Scheme, address string) error {
+	pk, sk, err := scheme.GenerateKeyPair()
+	if err != nil {
+		return err
+	}
+	pkBytes, _ := pk.MarshalBinary()
+	body := append(pkBytes, helloPadding(*paddingSize)...)
+	n := len(body)
+	hello := append([]byte{1, byte(n >> 16), byte(n >> 8), byte(n)}, body...)
+
+	dcid, scid := make([]byte, QUIC_CID_SIZE), make([]byte, QUIC_CID_SIZE)
+	rand.Read(dcid)
+	rand.Read(scid)
+	client, server := quicInitialKeys(dcid, "client in"), quicInitialKeys(dcid, "server in")
+
+	// CRYPTO frame header: type, 4-byte offset and 2-byte length at most
+	room := QUIC_DATAGRAM_SIZE - quicInitialOverhead(len(dcid), len(scid)) - 1 - 4 - 2
+	var chunks []*quicChunk
+	for offset := 0; offset < len(hello); offset += room {
+		end := min(offset+room, len(hello))
+		chunks = append(chunks, &quicChunk{offset: offset, data: hello[offset:end]})
+	}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│           QUIC INITIAL SIMULATION           │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Key Share:      %-27s │\n",
+		fmt.Sprintf("%d bytes (%s)", len(pkBytes), scheme.Name()))
+	log.Printf("│ ClientHello:    %-27s │\n", fmt.Sprintf("%d bytes", len(hello)))
+	log.Printf("│ Datagrams:      %-27s │\n",
+		fmt.Sprintf("%d × %d bytes", len(chunks), QUIC_DATAGRAM_SIZE))
+	log.Println("└─────────────────────────────────────────────┘")
+
+	conn, err := dialProxy("udp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var nextPN uint64
+	var serverPNs []uint64
+	inFlight := make(map[uint64]*quicChunk) // unacknowledged packet number → its chunk
+	serverData := make(map[uint64][]byte)   // CRYPTO frames by offset
+	var sent, sentBytes, retransmitted, received, receivedBytes int
+	send := func(frames []byte, chunk *quicChunk) error {
+		datagram := sealQUICInitial(client, dcid, scid, nextPN, frames)
+		if _, err := conn.Write(datagram); err != nil {
+			return err
+		}
+		if chunk != nil {
+			inFlight[nextPN] = chunk
+		}
+		nextPN++
+		sent++
+		sentBytes += len(datagram)
+		return nil
+	}
+	sendChunk := func(c *quicChunk) error {
+		return send(quicCryptoFrame(uint64(c.offset), c.data), c)
+	}
+
+	start := time.Now()
+	log.Println()
+	log.Printf("[SEND] Sending ClientHello in %d Initial datagrams...", len(chunks))
+	for _, c := range chunks {
+		if err := sendChunk(c); err != nil {
+			return err
+		}
+	}
+
+	buffer := make([]byte, DATAGRAM_RESPONSE_LIMIT)
+	wait, timeouts := DATAGRAM_INITIAL_WAIT, 0
+	var serverHello []byte
+	for serverHello == nil {
+		conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := conn.Read(buffer)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			if timeouts++; timeouts > DATAGRAM_RETRANSMITS {
+				return fmt.Errorf("no ServerHello after %d retransmissions (Initials dropped?)",
+					DATAGRAM_RETRANSMITS)
+			}
+			var lost []*quicChunk
+			for _, c := range chunks {
+				if !c.acked {
+					lost = append(lost, c)
+				}
+			}
+			if len(lost) == 0 {
+				// All acknowledged: the ServerHello went missing
+				log.Printf("[RETRY] No ServerHello after %s, probing", wait)
+				frames := []byte{0x01} // PING
+				if len(serverPNs) > 0 {
+					frames = append(quicAckFrame(serverPNs), frames...)
+				}
+				if err := send(frames, nil); err != nil {
+					return err
+				}
+			} else {
+				log.Printf("[RETRY] %d datagrams unacknowledged after %s, retransmitting",
+					len(lost), wait)
+				for _, c := range lost {
+					if err := sendChunk(c); err != nil {
+						return err
+					}
+					retransmitted++
+				}
+			}
+			wait *= 2
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		pn, frames, err := openQUICInitial(server, buffer[:n])
+		if err != nil {
+			log.Printf("[RECV] ⚠️  Dropping undecryptable datagram (%d bytes): %v", n, err)
+			continue
+		}
+		received++
+		receivedBytes += n
+		serverPNs = append(serverPNs, pn)
+		if err := readQUICFrames(frames, inFlight, serverData); err != nil {
+			return err
+		}
+		serverHello = quicServerHello(serverData)
+	}
+	elapsed := time.Since(start)
+	log.Printf("[RECV] ✅ ServerHello: %d bytes in %d datagrams (%s)",
+		len(serverHello), received, elapsed.Round(time.Millisecond))
+
+	ss, err := scheme.Decapsulate(sk, serverHello[4:])
+	if err != nil {
+		return fmt.Errorf("decapsulation failed: %w", err)
+	}
+	log.Printf("[CRYPTO] ✅ ML-KEM shared secret derived: %d bytes", len(ss))
+
+	// The ServerHello acknowledges every packet the proxy received
+	lost := len(inFlight)
+	amplification := float64(receivedBytes) / float64(sentBytes)
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│              QUIC INITIAL RESULT            │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Sent:           %-27s │\n",
+		fmt.Sprintf("%d datagrams, %d bytes", sent, sentBytes))
+	log.Printf("│ Retransmitted:  %-27s │\n", fmt.Sprintf("%d datagrams", retransmitted))
+	log.Printf("│ Lost:           %-27s │\n", fmt.Sprintf("%d datagrams", lost))
+	log.Printf("│ Received:       %-27s │\n",
+		fmt.Sprintf("%d datagrams, %d bytes", received, receivedBytes))
+	log.Printf("│ Amplification:  %-27s │\n",
+		fmt.Sprintf("%.2f× (limit %d×)", amplification, QUIC_AMPLIFY))
+	log.Printf("│ ServerHello:    %-27s │\n",
+		fmt.Sprintf("after %s", elapsed.Round(time.Millisecond)))
+	log.Println("└─────────────────────────────────────────────┘")
+	if lost > 0 {
+		log.Printf("⚠️  %d of %d Initial datagrams were lost on this path and resent",
+			lost, sent)
+	}
+	if amplification > QUIC_AMPLIFY {
+		log.Printf("⚠️  The server sent over %d× the client's bytes before validating its address",
+			QUIC_AMPLIFY)
+	}
+	return nil
+}
+
+// readQUICFrames takes the frames of a server Initial: ACKs take the
+// acknowledged packets out of inFlight and mark their chunks, CRYPTO frames
+// are collected by offset.
+func readQUICFrames(data []byte, inFlight map[uint64]*quicChunk, crypto map[uint64][]byte) error {
+	for len(data) > 0 {
+		switch data[0] {
+		case 0x00, 0x01: // PADDING, PING
+			data = data[1:]
+		case 0x02: // ACK
+			var fields [4]uint64
+			rest := data[1:]
+			for i := range fields {
+				v, n := readVarint(rest)
+				if n == 0 {
+					return errors.New("truncated ACK frame")
+				}
+				fields[i], rest = v, rest[n:]
+			}
+			// Largest, delay, range count, first range; then gap and range pairs
+			if fields[3] > fields[0] {
+				return errors.New("invalid ACK range")
+			}
+			hi, lo := fields[0], fields[0]-fields[3]
+			for {
+				for pn := lo; pn <= hi; pn++ {
+					if c, ok := inFlight[pn]; ok {
+						c.acked = true
+						delete(inFlight, pn)
+					}
+				}
+				if fields[2] == 0 {
+					break
+				}
+				gap, n1 := readVarint(rest)
+				length, n2 := readVarint(rest[n1:])
+				if n1 == 0 || n2 == 0 {
+					return errors.New("truncated ACK frame")
+				}
+				rest = rest[n1+n2:]
+				if gap+2+length > lo {
+					return errors.New("invalid ACK range")
+				}
+				hi = lo - gap - 2
+				lo = hi - length
+				fields[2]--
+			}
+			data = rest
+		case 0x06: // CRYPTO
+			offset, n1 := readVarint(data[1:])
+			length, n2 := readVarint(data[1+n1:])
+			start := 1 + n1 + n2
+			if n1 == 0 || n2 == 0 || uint64(len(data)-start) < length {
+				return errors.New("truncated CRYPTO frame")
+			}
+			crypto[offset] = slices.Clone(data[start : start+int(length)])
+			data = data[start+int(length):]
+		default:
+			return fmt.Errorf("unexpected frame type 0x%02x in Initial", data[0])
+		}
+	}
+	return nil
+}
+
+// quicServerHello is the complete ServerHello (type 2, uint24 length, the
+// ciphertext), or nil while parts are missing.
+func quicServerHello(crypto map[uint64][]byte) []byte {
+	var stream []byte
+	for {
+		data, ok := crypto[uint64(len(stream))]
+		if !ok {
+			break
+		}
+		stream = append(stream, data...)
+	}
+	if len(stream) < 4 || stream[0] != 2 {
+		return nil
+	}
+	length := int(stream[1])<<16 | int(stream[2])<<8 | int(stream[3])
+	if len(stream) < 4+length {
+		return nil
+	}
+	return stream[:4+length]
+}
+
+// ============================================================================
+// PACKET PROTECTION (RFC 9001 5)
+// ============================================================================
+
+type quicKeys struct {
+	aead cipher.AEAD
+	iv   []byte
+	hp   cipher.Block
+}
+
+func quicInitialKeys(dcid []byte, label string) quicKeys {
+	secret := hkdfExpandLabel(hkdf.Extract(sha256.New, dcid, quicInitialSalt), label, 32)
+	block, _ := aes.NewCipher(hkdfExpandLabel(secret, "quic key", 16))
+	aead, _ := cipher.NewGCM(block)
+	hp, _ := aes.NewCipher(hkdfExpandLabel(secret, "quic hp", 16))
+	return quicKeys{aead: aead, iv: hkdfExpandLabel(secret, "quic iv", 12), hp: hp}
+}
+
+// hkdfExpandLabel is HKDF-Expand-Label of TLS 1.3 with an empty context.
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	full := "tls13 " + label
+	info := binary.BigEndian.AppendUint16(nil, uint16(length))
+	info = append(info, byte(len(full)))
+	info = append(info, full...)
+	info = append(info, 0)
+	out := make([]byte, length)
+	hkdf.Expand(sha256.New, secret, info).Read(out)
+	return out
+}
+
+func (k quicKeys) nonce(pn uint64) []byte {
+	nonce := slices.Clone(k.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+	return nonce
+}
+
+func (k quicKeys) mask(sample []byte) []byte {
+	mask := make([]byte, aes.BlockSize)
+	k.hp.Encrypt(mask, sample)
+	return mask
+}
+
+// quicInitialOverhead is an Initial's bytes besides its frames: first byte,
+// version, CIDs, empty token, 2-byte length, 4-byte packet number and tag.
+func quicInitialOverhead(dcidLen, scidLen int) int {
+	return 1 + 4 + 1 + dcidLen + 1 + scidLen + 1 + 2 + 4 + QUIC_TAG_SIZE
+}
+
+// sealQUICInitial builds a protected Initial padded to QUIC_DATAGRAM_SIZE.
+func sealQUICInitial(k quicKeys, dcid, scid []byte, pn uint64, frames []byte) []byte {
+	overhead := quicInitialOverhead(len(dcid), len(scid))
+	if pad := QUIC_DATAGRAM_SIZE - overhead - len(frames); pad > 0 {
+		frames = append(slices.Clone(frames), make([]byte, pad)...) // PADDING frames
+	}
+	b := []byte{0xc3} // Initial, 4-byte packet number
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = append(b, byte(len(dcid)))
+	b = append(b, dcid...)
+	b = append(b, byte(len(scid)))
+	b = append(b, scid...)
+	b = append(b, 0) // no token
+	b = binary.BigEndian.AppendUint16(b, 0x4000|uint16(4+len(frames)+QUIC_TAG_SIZE))
+	pnOffset := len(b)
+	b = binary.BigEndian.AppendUint32(b, uint32(pn))
+	b = k.aead.Seal(b, k.nonce(pn), frames, b)
+
+	mask := k.mask(b[pnOffset+4 : pnOffset+4+16])
+	b[0] ^= mask[0] & 0x0f
+	for i := 0; i < 4; i++ {
+		b[pnOffset+i] ^= mask[1+i]
+	}
+	return b
+}
+
+// openQUICInitial removes the protection of an Initial and returns its
+// packet number and frames.
+func openQUICInitial(k quicKeys, d []byte) (uint64, []byte, error) {
+	if len(d) < 7 || d[0]&0xf0 != 0xc0 {
+		return 0, nil, errors.New("not a QUIC Initial packet")
+	}
+	pos := 5
+	for i := 0; i < 2; i++ { // DCID, SCID
+		if pos >= len(d) {
+			return 0, nil, errors.New("truncated packet")
+		}
+		pos += 1 + int(d[pos])
+	}
+	token, n := readVarint(d[min(pos, len(d)):])
+	pos += n + int(token)
+	length, n := readVarint(d[min(pos, len(d)):])
+	pos += n
+	if n == 0 || length < 4+QUIC_TAG_SIZE || pos+int(length) > len(d) {
+		return 0, nil, errors.New("truncated packet")
+	}
+	packet := slices.Clone(d[:pos+int(length)])
+	mask := k.mask(packet[pos+4 : pos+4+16])
+	packet[0] ^= mask[0] & 0x0f
+	pnLen := int(packet[0]&0x03) + 1
+	var pn uint64
+	for i := 0; i < pnLen; i++ {
+		packet[pos+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(packet[pos+i])
+	}
+	frames, err := k.aead.Open(nil, k.nonce(pn), packet[pos+pnLen:], packet[:pos+pnLen])
+	if err != nil {
+		return 0, nil, err
+	}
+	return pn, frames, nil
+}
+
+// ============================================================================
+// FRAMES
+// ============================================================================
+
+// quicAckFrame acknowledges the packet numbers received, in ranges.
+func quicAckFrame(pns []uint64) []byte {
+	sorted := slices.Clone(pns)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	slices.Reverse(sorted)
+
+	type span struct{ hi, lo uint64 }
+	var spans []span
+	for _, pn := range sorted {
+		if n := len(spans); n > 0 && spans[n-1].lo == pn+1 {
+			spans[n-1].lo = pn
+			continue
+		}
+		spans = append(spans, span{pn, pn})
+	}
+	f := []byte{0x02}
+	f = appendVarint(f, spans[0].hi)
+	f = appendVarint(f, 0)
+	f = appendVarint(f, uint64(len(spans)-1))
+	f = appendVarint(f, spans[0].hi-spans[0].lo)
+	for i := 1; i < len(spans); i++ {
+		f = appendVarint(f, spans[i-1].lo-spans[i].hi-2)
+		f = appendVarint(f, spans[i].hi-spans[i].lo)
+	}
+	return f
+}
+
+func quicCryptoFrame(offset uint64, data []byte) []byte {
+	f := appendVarint([]byte{0x06}, offset)
+	f = appendVarint(f, uint64(len(data)))
+	return append(f, data...)
+}
+
+// appendVarint appends v as a QUIC variable-length integer (RFC 9000 16).
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return binary.BigEndian.AppendUint16(b, uint16(v)|0x4000)
+	case v < 1<<30:
+		return binary.BigEndian.AppendUint32(b, uint32(v)|0x80000000)
+	default:
+		return binary.BigEndian.AppendUint64(b, v|0xc000000000000000)
+	}
+}
+
+// readVarint reads a QUIC variable-length integer; n is 0 when b is short.
+func readVarint(b []byte) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, 0
+	}
+	v = uint64(b[0] & 0x3f)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
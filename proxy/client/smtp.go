@@ -0,0 +1,74 @@
+/*
+SMTP STARTTLS Preamble
+======================
+Drives the proxy's "smtp" scenario the way a sending MTA would: read the
+banner, EHLO, check STARTTLS is offered, issue STARTTLS. The PQC ClientHello
+follows on the same connection.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+)
+
+// smtpStartTLS negotiates STARTTLS on a fresh SMTP connection.
+func smtpStartTLS(conn net.Conn) error {
+	br := bufio.NewReader(conn)
+
+	code, lines, err := readSMTPReply(br)
+	if err != nil || code != 220 {
+		return fmt.Errorf("bad SMTP banner (%d): %v", code, err)
+	}
+	log.Printf("[SMTP] S: %s", lines[0])
+
+	io.WriteString(conn, "EHLO sentinel-client.local\r\n")
+	code, lines, err = readSMTPReply(br)
+	if err != nil || code != 250 {
+		return fmt.Errorf("EHLO rejected (%d): %v", code, err)
+	}
+	offered := false
+	for _, l := range lines {
+		if strings.EqualFold(strings.TrimSpace(l), "STARTTLS") {
+			offered = true
+		}
+	}
+	if !offered {
+		return fmt.Errorf("server does not offer STARTTLS")
+	}
+
+	io.WriteString(conn, "STARTTLS\r\n")
+	code, lines, err = readSMTPReply(br)
+	if err != nil || code != 220 {
+		return fmt.Errorf("STARTTLS rejected (%d): %v", code, err)
+	}
+	log.Printf("[SMTP] S: %s", lines[0])
+	log.Printf("[SMTP] ✅ STARTTLS accepted, switching to TLS")
+	return nil
+}
+
+// readSMTPReply reads a (possibly multi-line) SMTP reply.
+func readSMTPReply(br *bufio.Reader) (int, []string, error) {
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return 0, lines, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 {
+			return 0, lines, fmt.Errorf("malformed SMTP reply %q", line)
+		}
+		var code int
+		fmt.Sscanf(line[:3], "%d", &code)
+		lines = append(lines, line[4:])
+		if line[3] == ' ' {
+			return code, lines, nil
+		}
+	}
+}
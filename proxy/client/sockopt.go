@@ -0,0 +1,69 @@
+/*
+DF Bit & MSS Controls
+=====================
+Whether the ClientHello fragments is decided by the OS as much as by the
+path. -df sets the socket's path MTU discovery policy (IP_MTU_DISCOVER /
+IPV6_MTU_DISCOVER, Linux), so fragmentation can be forced or forbidden:
+
+  do     DF on every packet and PMTU discovery: TCP segments shrink to a
+         learned path MTU, a UDP datagram above it fails with EMSGSIZE
+  probe  DF on every packet, ignoring the learned path MTU: nothing is
+         fragmented, an oversized packet is dropped on the path
+  dont   DF never set: routers fragment what does not fit, and the kernel
+         fragments oversized UDP datagrams itself
+  want   the kernel default: DF set, local fragmentation when the learned
+         path MTU is smaller
+
+-mss sets TCP_MAXSEG before connecting, which clamps the MSS the client
+announces and the segments it sends: -mss 536 splits a 1484-byte
+ClientHello into three segments, and a large -mss with -df dont lets a
+router fragment them instead. With either flag the client logs the MSS the
+connection got and how many segments the ClientHello took (TCP_INFO), and
+-json reports both for every handshake.
+
+  go run ./client -df dont -mss 1460 -padding 300
+  go run ./client -df probe -alg Kyber1024
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DF_POLICIES are the -df values.
+var DF_POLICIES = []string{"do", "probe", "dont", "want"}
+
+const (
+	MSS_MIN = 88 // the kernel's TCP_MIN_MSS
+	MSS_MAX = 65495
+)
+
+// checkSocketOptions validates -df and -mss.
+func checkSocketOptions() error {
+	if *dfPolicy != "" && !slices.Contains(DF_POLICIES, *dfPolicy) {
+		return fmt.Errorf("unknown -df %q (want do, probe, dont or want)", *dfPolicy)
+	}
+	if *tcpMSS != 0 && (*tcpMSS < MSS_MIN || *tcpMSS > MSS_MAX) {
+		return fmt.Errorf("-mss must be between %d and %d", MSS_MIN, MSS_MAX)
+	}
+	return nil
+}
+
+// socketOptionsSet reports whether -df or -mss changed the OS defaults.
+func socketOptionsSet() bool {
+	return *dfPolicy != "" || *tcpMSS != 0
+}
+
+// socketLabel describes the socket options for logs.
+func socketLabel() string {
+	df, mss := *dfPolicy, "default"
+	if df == "" {
+		df = "default"
+	}
+	if *tcpMSS != 0 {
+		mss = fmt.Sprint(*tcpMSS)
+	}
+	return fmt.Sprintf("DF %s, MSS %s", df, mss)
+}
@@ -0,0 +1,45 @@
+//go:build linux
+
+package main
+
+import (
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var dfModes = map[string]int{
+	"do":    unix.IP_PMTUDISC_DO,
+	"probe": unix.IP_PMTUDISC_PROBE,
+	"dont":  unix.IP_PMTUDISC_DONT,
+	"want":  unix.IP_PMTUDISC_WANT,
+}
+
+// setDF sets a socket's path MTU discovery policy (the IPv6 values are the
+// same).
+func setDF(c syscall.RawConn, network, policy string) error {
+	mode := dfModes[policy]
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		if strings.HasSuffix(network, "6") {
+			sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, mode)
+			return
+		}
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, mode)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// setMSS clamps a TCP socket's MSS before it connects.
+func setMSS(c syscall.RawConn, mss int) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG, mss)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setDF(c syscall.RawConn, network, policy string) error {
+	return errors.New("the DF policy is only set on Linux")
+}
+
+func setMSS(c syscall.RawConn, mss int) error {
+	return errors.New("the MSS is only set on Linux")
+}
@@ -0,0 +1,210 @@
+/*
+SSH Hybrid KEX Simulation
+=========================
+Drives the proxy's "ssh" scenario the way OpenSSH would: version exchange,
+KEXINIT, then SSH_MSG_KEX_ECDH_INIT carrying the hybrid public key.
+
+  sntrup761x25519-sha512@openssh.com  Q_C = 1158 + 32 bytes (simulated)
+  mlkem768x25519-sha256               Q_C = 1184 + 32 bytes (real Kyber-768)
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	SSH_CLIENT_VERSION = "SSH-2.0-SentinelPQC_Client_1.0"
+
+	SSH_MSG_KEXINIT        = 20
+	SSH_MSG_NEWKEYS        = 21
+	SSH_MSG_KEX_ECDH_INIT  = 30
+	SSH_MSG_KEX_ECDH_REPLY = 31
+)
+
+// runSSHHandshake performs the SSH key exchange over an open connection.
+func runSSHHandshake(conn net.Conn, scheme kem.Scheme, kexName string) error {
+	br := bufio.NewReader(conn)
+
+	// 1. Version exchange
+	if _, err := io.WriteString(conn, SSH_CLIENT_VERSION+"\r\n"); err != nil {
+		return err
+	}
+	serverVersion, err := br.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no server version: %w", err)
+	}
+	log.Printf("[SSH] Server version: %s", strings.TrimSpace(serverVersion))
+
+	// 2. KEXINIT both ways
+	if _, err := conn.Write(sshPacket(sshKexInit(kexName))); err != nil {
+		return err
+	}
+	if _, err := readSSHPacket(br); err != nil {
+		return fmt.Errorf("no server KEXINIT: %w", err)
+	}
+
+	// 3. KEX_ECDH_INIT with Q_C = PQ public key || X25519 public key
+	var pqPub []byte
+	var sk kem.PrivateKey
+	switch kexName {
+	case "mlkem768x25519-sha256":
+		pk, priv, err := scheme.GenerateKeyPair()
+		if err != nil {
+			return err
+		}
+		pqPub, _ = pk.MarshalBinary()
+		sk = priv
+	case "sntrup761x25519-sha512@openssh.com":
+		pqPub = make([]byte, 1158)
+		rand.Read(pqPub)
+	default:
+		return fmt.Errorf("unsupported KEX %q", kexName)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	qc := append(pqPub, ephemeral.PublicKey().Bytes()...)
+
+	initPacket := sshPacket(appendSSHString([]byte{SSH_MSG_KEX_ECDH_INIT}, qc))
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│          SSH KEX_ECDH_INIT SIMULATION       │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ KEX:            %-27s │\n", strings.SplitN(kexName, "@", 2)[0])
+	log.Printf("│ Q_C:            %-27s │\n", fmt.Sprintf("%d bytes", len(qc)))
+	log.Printf("│ Packet:         %-27s │\n", fmt.Sprintf("%d bytes", len(initPacket)))
+	log.Println("└─────────────────────────────────────────────┘")
+
+	if _, err := conn.Write(initPacket); err != nil {
+		return fmt.Errorf("send KEX_ECDH_INIT: %w", err)
+	}
+
+	// 4. KEX_ECDH_REPLY + NEWKEYS
+	reply, err := readSSHPacket(br)
+	if err != nil {
+		return fmt.Errorf("no KEX_ECDH_REPLY: %w", err)
+	}
+	payload := sshPayload(reply)
+	if payload[0] != SSH_MSG_KEX_ECDH_REPLY {
+		return fmt.Errorf("unexpected SSH message %d", payload[0])
+	}
+	_, rest, ok := sshString(payload[1:]) // K_S
+	if !ok {
+		return fmt.Errorf("malformed KEX_ECDH_REPLY")
+	}
+	qs, _, ok := sshString(rest)
+	if !ok {
+		return fmt.Errorf("malformed KEX_ECDH_REPLY")
+	}
+	log.Printf("[RECV] ✅ KEX_ECDH_REPLY: %d bytes (Q_S = %d bytes)", len(reply), len(qs))
+
+	newKeys, err := readSSHPacket(br)
+	if err != nil || sshPayload(newKeys)[0] != SSH_MSG_NEWKEYS {
+		return fmt.Errorf("no SSH_MSG_NEWKEYS")
+	}
+
+	if sk != nil {
+		ss, err := scheme.Decapsulate(sk, qs[:len(qs)-32])
+		if err != nil {
+			return fmt.Errorf("decapsulation failed: %w", err)
+		}
+		log.Printf("[CRYPTO] ✅ ML-KEM shared secret derived: %d bytes", len(ss))
+	}
+	log.Printf("[SSH] ✅ Key exchange complete (%d bytes sent, %d bytes received)",
+		len(initPacket), len(reply)+len(newKeys))
+	return nil
+}
+
+// ============================================================================
+// BINARY PACKET PROTOCOL
+// ============================================================================
+
+func readSSHPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < 5 || length > 35000 {
+		return nil, fmt.Errorf("invalid SSH packet length %d", length)
+	}
+	packet := make([]byte, 4+length)
+	copy(packet, header)
+	if _, err := io.ReadFull(r, packet[4:]); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+func sshPacket(payload []byte) []byte {
+	padding := 8 - (5+len(payload))%8
+	if padding < 4 {
+		padding += 8
+	}
+	packet := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padding))
+	packet = append(packet, byte(padding))
+	packet = append(packet, payload...)
+	pad := make([]byte, padding)
+	rand.Read(pad)
+	return append(packet, pad...)
+}
+
+func sshPayload(packet []byte) []byte {
+	padding := int(packet[4])
+	end := len(packet) - padding
+	if end <= 5 {
+		return []byte{0}
+	}
+	return packet[5:end]
+}
+
+func sshKexInit(kexName string) []byte {
+	payload := []byte{SSH_MSG_KEXINIT}
+	cookie := make([]byte, 16)
+	rand.Read(cookie)
+	payload = append(payload, cookie...)
+
+	nameLists := []string{
+		kexName,
+		"ssh-ed25519",
+		"chacha20-poly1305@openssh.com", "chacha20-poly1305@openssh.com",
+		"umac-128-etm@openssh.com", "umac-128-etm@openssh.com",
+		"none", "none",
+		"", "",
+	}
+	for _, list := range nameLists {
+		payload = appendSSHString(payload, []byte(list))
+	}
+	payload = append(payload, 0)
+	return append(payload, 0, 0, 0, 0)
+}
+
+func appendSSHString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func sshString(b []byte) (s, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
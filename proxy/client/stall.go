@@ -0,0 +1,32 @@
+/*
+NAT / Keepalive Mode
+====================
+With -nat the client (proxy scenario "stall") keeps waiting while the
+proxy idles between and inside its flights, reads the complete ciphertext
+and answers with a Finished, its key confirmation (confirm.go). Whether the
+Finished arrives tells the proxy if a NAT or firewall on the path dropped
+the idle connection.
+*/
+
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+const NAT_IDLE_WAIT = 10 * time.Minute // longer than any sensible -stall
+
+// readStalledFlight reads the whole server flight into buf, however long the
+// proxy stalls.
+func readStalledFlight(conn net.Conn, buf []byte) (int, error) {
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(NAT_IDLE_WAIT))
+	n, err := io.ReadFull(conn, buf)
+	if err == nil {
+		log.Printf("[NAT] Server flight complete after %s", time.Since(start).Round(time.Millisecond))
+	}
+	return n, err
+}
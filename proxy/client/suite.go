@@ -0,0 +1,419 @@
+/*
+Scenario Suites
+===============
+-suite FILE runs the tests of a YAML file one after another and reports
+which passed, so a network change can be checked against the same
+expectations every time:
+
+  go run ./client -suite regression.yaml
+  go run ./client -suite regression.yaml -json | jq '.suite.failed'
+
+  # regression.yaml
+  proxy: http://127.0.0.1:9090   # the proxy's -api, for verdicts and impairments
+  listener: ":4433"              # its -listen address (default: the target)
+  tests:
+    - name: Kyber768 fits
+      alg: Kyber768
+      padding: 150
+      expect: {status: SAFE, fragmented: false}
+    - name: Kyber1024 is flagged
+      alg: Kyber1024
+      profile: chrome
+      expect: {status: CRITICAL_RISK, severity: [FRAGMENTED, MULTI_SEGMENT]}
+    - name: satellite delay
+      impairment: {delay: 600ms, jitter: 50ms}
+      repeat: 3
+      expect: {max_latency_ms: 2500}
+    - name: black hole
+      impairment: {blackhole: true}
+      expect: {outcome: no_server_hello, exit_code: 4}
+
+target is the file's -target, which the command line's overrides. A test
+takes:
+
+  alg, padding, profile   as -alg, -padding and -profile (default: the
+                          command line's)
+  repeat                  handshakes, one after another (default 1)
+  mtu, impairment         the listener's payload budget and delay, jitter,
+                          bandwidth_kbps and blackhole
+  expect                  what every handshake of the test must show
+
+and expects:
+
+  outcome         the -json outcome: completed, no_server_hello, ...
+                  (default completed, or none with exit_code)
+  exit_code       the exit code the handshake alone gives (exitcode.go)
+  status          the proxy report's status, one or a list
+  severity        the proxy report's severity, one or a list
+  fragmented      whether the ClientHello exceeded the payload budget
+  max_latency_ms  the slowest handshake allowed
+
+With proxy, each test sets the listener's algorithms to its alg, and its
+mtu and impairment, through the control API (operator role, the token
+from $SENTINEL_API_TOKEN) and puts the listener back to its -listen
+options afterwards; the verdicts are read from /api/reports as
+-sweep-padding does, so the proxy needs a report history and no
+-anonymize. Without proxy, -proxy-status names the API, and a suite
+without either can only expect what the client sees. The suite exits 0
+when every test passed and 5 when one failed (exitcode.go).
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/circl/kem/schemes"
+	"gopkg.in/yaml.v3"
+)
+
+// suiteFile is a -suite file.
+type suiteFile struct {
+	Target   string      `yaml:"target"`
+	Proxy    string      `yaml:"proxy"`
+	Listener string      `yaml:"listener"`
+	Tests    []suiteTest `yaml:"tests"`
+}
+
+// suiteTest is one test of a suite.
+type suiteTest struct {
+	Name       string           `yaml:"name"`
+	Alg        string           `yaml:"alg"`
+	Padding    *int             `yaml:"padding"`
+	Profile    string           `yaml:"profile"`
+	Repeat     int              `yaml:"repeat"`
+	MTU        *int             `yaml:"mtu"`
+	Impairment *suiteImpairment `yaml:"impairment"`
+	Expect     suiteExpect      `yaml:"expect"`
+}
+
+// suiteImpairment is a listener impairment as the proxy's control API
+// takes it.
+type suiteImpairment struct {
+	Delay         string `yaml:"delay" json:"delay,omitempty"`
+	Jitter        string `yaml:"jitter" json:"jitter,omitempty"`
+	BandwidthKbps int    `yaml:"bandwidth_kbps" json:"bandwidth_kbps,omitempty"`
+	Blackhole     bool   `yaml:"blackhole" json:"blackhole,omitempty"`
+}
+
+// suiteExpect is what every handshake of a test must show; unset fields
+// are not checked.
+type suiteExpect struct {
+	Outcome      string    `yaml:"outcome" json:"outcome,omitempty"`
+	ExitCode     *int      `yaml:"exit_code" json:"exit_code,omitempty"`
+	Status       oneOrMore `yaml:"status" json:"status,omitempty"`
+	Severity     oneOrMore `yaml:"severity" json:"severity,omitempty"`
+	Fragmented   *bool     `yaml:"fragmented" json:"fragmented,omitempty"`
+	MaxLatencyMs float64   `yaml:"max_latency_ms" json:"max_latency_ms,omitempty"`
+}
+
+// oneOrMore is a YAML value or a list of them.
+type oneOrMore []string
+
+func (o *oneOrMore) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*o = oneOrMore{n.Value}
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*o = list
+	return nil
+}
+
+// SUITE_OUTCOMES are the outcomes a test can expect.
+var SUITE_OUTCOMES = []string{OUTCOME_COMPLETED, OUTCOME_NO_REPLY, OUTCOME_DOWNGRADED, OUTCOME_DECAPSULATION,
+	OUTCOME_UNCONFIRMED, OUTCOME_FAILED, OUTCOME_ABORTED}
+
+// loadSuite reads and checks a -suite file, with the command line's target
+// and API where the file has none.
+func loadSuite(path string) (suiteFile, error) {
+	var s suiteFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(s.Tests) == 0 {
+		return s, fmt.Errorf("%s: no tests", path)
+	}
+	if s.Target != "" {
+		target := false
+		flag.Visit(func(f *flag.Flag) { target = target || f.Name == "target" })
+		if !target {
+			*targetAddr = s.Target
+		}
+	}
+	if s.Proxy == "" && *statusURL != "" {
+		u, err := url.Parse(*statusURL)
+		if err != nil {
+			return s, fmt.Errorf("-proxy-status: %w", err)
+		}
+		s.Proxy = u.Scheme + "://" + u.Host
+	}
+	s.Proxy = strings.TrimSuffix(s.Proxy, "/")
+	if s.Listener == "" {
+		s.Listener = *targetAddr
+	}
+	for i := range s.Tests {
+		t := &s.Tests[i]
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("test %d", i+1)
+		}
+		if err := s.check(t); err != nil {
+			return s, fmt.Errorf("%s: %s: %w", path, t.Name, err)
+		}
+	}
+	return s, nil
+}
+
+func (s suiteFile) check(t *suiteTest) error {
+	switch {
+	case t.Alg != "" && schemes.ByName(t.Alg) == nil:
+		return fmt.Errorf("unknown alg %q", t.Alg)
+	case t.Profile != "" && HELLO_PROFILES[t.Profile].extensions == nil:
+		return fmt.Errorf("unknown profile %q (want %s)", t.Profile, strings.Join(profileNames(), ", "))
+	case t.Padding != nil && *t.Padding < 0:
+		return errors.New("padding must not be negative")
+	case t.Repeat < 0:
+		return errors.New("repeat must not be negative")
+	case t.Expect.Outcome != "" && !slices.Contains(SUITE_OUTCOMES, t.Expect.Outcome):
+		return fmt.Errorf("unknown outcome %q (want %s)", t.Expect.Outcome, strings.Join(SUITE_OUTCOMES, ", "))
+	case t.Expect.ExitCode != nil && (*t.Expect.ExitCode < EXIT_OK || *t.Expect.ExitCode > EXIT_NETWORK):
+		return fmt.Errorf("exit_code %d: a handshake exits %d to %d", *t.Expect.ExitCode, EXIT_OK, EXIT_NETWORK)
+	case (t.MTU != nil || t.Impairment != nil || t.Expect.Status != nil || t.Expect.Severity != nil) && s.Proxy == "":
+		return errors.New("mtu, impairment, status and severity need the proxy's API: set proxy or -proxy-status")
+	}
+	if t.Repeat == 0 {
+		t.Repeat = 1
+	}
+	if t.Expect.Outcome == "" && t.Expect.ExitCode == nil {
+		t.Expect.Outcome = OUTCOME_COMPLETED
+	}
+	return nil
+}
+
+// suiteResult is how a suite went.
+type suiteResult struct {
+	File   string            `json:"file"`
+	Passed int               `json:"passed"`
+	Failed int               `json:"failed"`
+	Tests  []suiteTestResult `json:"tests"`
+}
+
+// suiteTestResult is how one test went.
+type suiteTestResult struct {
+	Name       string           `json:"name"`
+	Algorithm  string           `json:"algorithm"`
+	Padding    int              `json:"padding"`
+	Expect     suiteExpect      `json:"expect"`
+	Passed     bool             `json:"passed"`
+	Failures   []string         `json:"failures,omitempty"`
+	Handshakes []suiteHandshake `json:"handshakes,omitempty"`
+}
+
+// suiteHandshake is one handshake of a test and the proxy's verdict on it.
+type suiteHandshake struct {
+	Handshake handshakeResult `json:"handshake"`
+	Proxy     *proxyVerdict   `json:"proxy,omitempty"`
+}
+
+// suiteRun runs the tests of a suite.
+type suiteRun struct {
+	suiteFile
+	client *http.Client
+}
+
+// runSuite runs the suite in path and logs and returns which tests passed.
+func runSuite(path string) (suiteResult, error) {
+	s, err := loadSuite(path)
+	if err != nil {
+		return suiteResult{}, err
+	}
+	run := suiteRun{suiteFile: s, client: &http.Client{Timeout: *ioTimeout}}
+
+	log.Println()
+	log.Printf("[SUITE] %d tests from %s against %s", len(s.Tests), path, *targetAddr)
+	if s.Proxy == "" {
+		log.Printf("[SUITE] No proxy API: the proxy's verdicts are left out")
+	}
+	res := suiteResult{File: path}
+	start := time.Now()
+	for _, t := range s.Tests {
+		r := run.test(t)
+		if r.Passed {
+			res.Passed++
+			log.Printf("[SUITE] ✅ %s: %d handshake(s) as expected", r.Name, len(r.Handshakes))
+		} else {
+			res.Failed++
+			log.Printf("[SUITE] ❌ %s", r.Name)
+			for _, f := range r.Failures {
+				log.Printf("[SUITE]      %s", f)
+			}
+		}
+		res.Tests = append(res.Tests, r)
+	}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│                SUITE RESULT                 │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Tests:          %-27d │\n", len(res.Tests))
+	log.Printf("│ Passed:         %-27d │\n", res.Passed)
+	log.Printf("│ Failed:         %-27d │\n", res.Failed)
+	log.Printf("│ Took:           %-27s │\n", time.Since(start).Round(time.Millisecond))
+	log.Println("└─────────────────────────────────────────────┘")
+	if res.Failed > 0 {
+		log.Printf("❌ %d of %d tests failed", res.Failed, len(res.Tests))
+	} else {
+		log.Printf("✅ All %d tests passed", len(res.Tests))
+	}
+	return res, nil
+}
+
+// test runs one test with its settings, on the client and on the proxy's
+// listener, and puts both back afterwards.
+func (s suiteRun) test(t suiteTest) suiteTestResult {
+	padding, profile := *paddingSize, *helloProfile
+	defer func() { *paddingSize, *helloProfile = padding, profile }()
+	if t.Profile != "" {
+		*helloProfile = t.Profile
+		*paddingSize = HELLO_PROFILES[t.Profile].size(helloServerName())
+	}
+	if t.Padding != nil {
+		*paddingSize = *t.Padding
+	}
+	scheme := schemes.ByName(*kemName)
+	if t.Alg != "" {
+		scheme = schemes.ByName(t.Alg)
+	}
+	r := suiteTestResult{Name: t.Name, Algorithm: scheme.Name(), Padding: *paddingSize, Expect: t.Expect}
+
+	if s.Proxy != "" {
+		settings := struct {
+			MTU        *int             `json:"mtu,omitempty"`
+			Algorithms []string         `json:"algorithms"`
+			Impairment *suiteImpairment `json:"impairment,omitempty"`
+		}{t.MTU, []string{scheme.Name()}, t.Impairment}
+		if err := s.control(http.MethodPatch, settings); err != nil {
+			r.Failures = append(r.Failures, fmt.Sprintf("configuring the listener: %v", err))
+			return r
+		}
+		defer func() {
+			if err := s.control(http.MethodDelete, nil); err != nil {
+				log.Printf("[SUITE] ⚠️  Resetting the listener after %s: %v", t.Name, err)
+			}
+		}()
+	}
+
+	// The handshakes' own output is left out; the test's line says how they went
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	for range t.Repeat {
+		h := suiteHandshake{Handshake: runHandshake(scheme)}
+		if s.Proxy != "" && h.Handshake.Local != nil {
+			v, err := fetchVerdict(s.client, s.Proxy+"/api/reports", h.Handshake.Local.String())
+			if err != nil {
+				r.Failures = append(r.Failures, fmt.Sprintf("reading the verdict: %v", err))
+			}
+			h.Proxy = v
+		}
+		r.Handshakes = append(r.Handshakes, h)
+	}
+	log.SetOutput(out)
+
+	for i, h := range r.Handshakes {
+		for _, f := range t.Expect.check(h) {
+			if len(r.Handshakes) > 1 {
+				f = fmt.Sprintf("handshake %d: %s", i+1, f)
+			}
+			r.Failures = append(r.Failures, f)
+		}
+	}
+	r.Passed = len(r.Failures) == 0
+	return r
+}
+
+// control changes the suite's listener on the proxy (PATCH) or puts it
+// back to its -listen options (DELETE).
+func (s suiteRun) control(method string, body any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	u := s.Proxy + "/api/control/listeners/" + url.PathEscape(s.Listener)
+	req, err := http.NewRequest(method, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	proxyAuth(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s %s: %s %s", method, u, resp.Status, e.Error)
+	}
+	return nil
+}
+
+// check lists where a handshake differs from the expectation.
+func (e suiteExpect) check(h suiteHandshake) []string {
+	var failures []string
+	res := h.Handshake
+	if e.Outcome != "" && res.Outcome != e.Outcome {
+		f := fmt.Sprintf("outcome %s, want %s", res.Outcome, e.Outcome)
+		if res.Err != nil {
+			f += fmt.Sprintf(" (%v)", res.Err)
+		}
+		failures = append(failures, f)
+	}
+	if e.ExitCode != nil && res.exitCode() != *e.ExitCode {
+		failures = append(failures, fmt.Sprintf("exit code %d, want %d", res.exitCode(), *e.ExitCode))
+	}
+	if e.Fragmented != nil && res.fragmented() != *e.Fragmented {
+		failures = append(failures, fmt.Sprintf("fragmented %t, want %t (%d bytes, budget %d)", res.fragmented(), *e.Fragmented, res.Sent, res.Budget))
+	}
+	if e.MaxLatencyMs > 0 && durationMs(res.Latency) > e.MaxLatencyMs {
+		failures = append(failures, fmt.Sprintf("latency %.1f ms, want at most %g", durationMs(res.Latency), e.MaxLatencyMs))
+	}
+	if e.Status != nil || e.Severity != nil {
+		switch {
+		case h.Proxy == nil:
+			failures = append(failures, "no proxy report on the connection")
+		case e.Status != nil && !slices.Contains(e.Status, h.Proxy.Status):
+			failures = append(failures, fmt.Sprintf("status %s, want %s", h.Proxy.Status, strings.Join(e.Status, " or ")))
+		}
+		if h.Proxy != nil && e.Severity != nil && !slices.Contains(e.Severity, h.Proxy.Severity) {
+			failures = append(failures, fmt.Sprintf("severity %s, want %s", h.Proxy.Severity, strings.Join(e.Severity, " or ")))
+		}
+	}
+	return failures
+}
@@ -0,0 +1,139 @@
+/*
+MTU Sweep
+=========
+-sweep-mtu replaces the single handshake with a binary search over hello
+sizes: each trial is a fresh connection carrying the public key plus
+padding, and it passes only if the proxy's ciphertext comes back, the
+hello left in one TCP segment and nothing had to be retransmitted
+(TCP_INFO, Linux). The result is the largest handshake that crosses the
+actual path whole, measured instead of assumed from the 1400-byte rule.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	SWEEP_MAX_SIZE = 9000            // largest hello tried (jumbo frame)
+	SWEEP_TIMEOUT  = 3 * time.Second // per trial
+)
+
+// sweepTrial is the outcome of one hello size.
+type sweepTrial struct {
+	Size      int    `json:"hello_bytes"`
+	Passed    bool   `json:"passed"`
+	Delivered bool   `json:"delivered"` // the ciphertext came back and decapsulated
+	Segments  int    `json:"segments"`  // data segments the hello and trailing bytes took (0 = unknown)
+	Retrans   int    `json:"retransmits"`
+	Reason    string `json:"reason,omitempty"`
+	Local     string `json:"local_address,omitempty"` // the connection's local address, as the proxy reports it
+
+	dialErr error // why the target could not be reached
+}
+
+// runSweep binary-searches the largest hello that completes unsegmented and
+// without loss.
+func runSweep(scheme kem.Scheme, pkBytes []byte, sk kem.PrivateKey, address string) error {
+	trials := 0
+	try := func(size int) sweepTrial {
+		trials++
+		t := sweepOnce(scheme, pkBytes, sk, address, size)
+		if t.Passed {
+			log.Printf("[SWEEP] %5d bytes: ✅ one segment, no loss", size)
+		} else {
+			log.Printf("[SWEEP] %5d bytes: ❌ %s", size, t.Reason)
+		}
+		return t
+	}
+
+	lo, hi := len(pkBytes), SWEEP_MAX_SIZE
+	first := try(lo)
+	if !first.Passed {
+		return fmt.Errorf("even a bare %d-byte key share failed: %s", lo, first.Reason)
+	}
+	var failed sweepTrial
+	if last := try(hi); last.Passed {
+		lo = hi
+	} else {
+		failed = last
+		for hi-lo > 1 {
+			mid := (lo + hi) / 2
+			if t := try(mid); t.Passed {
+				lo = mid
+			} else {
+				hi, failed = mid, t
+			}
+		}
+	}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│               MTU SWEEP RESULT              │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Safe Handshake: %-27s │\n", fmt.Sprintf("%d bytes", lo))
+	if failed.Size > 0 {
+		log.Printf("│ First Failure:  %-27s │\n", fmt.Sprintf("%d bytes", failed.Size))
+		log.Printf("│ Failure:        %-27s │\n", failed.Reason)
+	} else {
+		log.Printf("│ First Failure:  %-27s │\n", fmt.Sprintf("none up to %d bytes", SWEEP_MAX_SIZE))
+	}
+	log.Printf("│ Static Budget:  %-27s │\n", "1400 bytes")
+	log.Printf("│ Trials:         %-27d │\n", trials)
+	log.Println("└─────────────────────────────────────────────┘")
+	if lo < 1400 {
+		log.Printf("⚠️  The path carries less than the 1400-byte rule of thumb: budget %d bytes", lo)
+	}
+	return nil
+}
+
+// sweepOnce runs one handshake with a hello of the given size.
+func sweepOnce(scheme kem.Scheme, pkBytes []byte, sk kem.PrivateKey, address string, size int) sweepTrial {
+	t := sweepTrial{Size: size}
+	conn, err := dialProxy("tcp", address)
+	if err != nil {
+		t.Reason, t.dialErr = "connect: "+err.Error(), err
+		return t
+	}
+	defer conn.Close()
+	t.Local = conn.LocalAddr().String()
+
+	hello := append(slices.Clone(pkBytes), helloPadding(max(size-len(pkBytes), 0))...)[:size]
+	if _, err := conn.Write(hello); err != nil {
+		t.Reason = "send: " + err.Error()
+		return t
+	}
+
+	ct := make([]byte, scheme.CiphertextSize())
+	conn.SetReadDeadline(time.Now().Add(SWEEP_TIMEOUT))
+	n, err := conn.Read(ct)
+	t.Segments, t.Retrans = segmentsSent(conn)
+	switch {
+	case err != nil:
+		t.Reason = "no response (lost or black-holed)"
+		return t
+	case n < len(ct):
+		t.Reason = fmt.Sprintf("short response (%d bytes)", n)
+		return t
+	}
+	if _, err := scheme.Decapsulate(sk, ct); err != nil {
+		t.Reason = "decapsulation failed"
+		return t
+	}
+	t.Delivered = true
+	switch {
+	case t.Retrans > 0:
+		t.Reason = fmt.Sprintf("%d retransmission(s)", t.Retrans)
+	case t.Segments > 1:
+		t.Reason = fmt.Sprintf("segmented into %d packets", t.Segments)
+	default:
+		t.Passed = true
+	}
+	return t
+}
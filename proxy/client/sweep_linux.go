@@ -0,0 +1,30 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// segmentsSent returns the data segments and retransmissions of conn so far.
+func segmentsSent(conn net.Conn) (int, int) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, 0
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, 0
+	}
+	var info *unix.TCPInfo
+	raw.Control(func(fd uintptr) {
+		info, _ = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if info == nil {
+		return 0, 0
+	}
+	return int(info.Data_segs_out), int(info.Total_retrans)
+}
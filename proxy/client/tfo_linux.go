@@ -0,0 +1,46 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const TCPI_OPT_SYN_DATA = 0x20
+
+// fastOpenConnect is a net.Dialer Control hook enabling client TFO.
+func fastOpenConnect(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// fastOpenResult returns whether the proxy acknowledged our SYN data and
+// the route MTU the SYN was sized for.
+func fastOpenResult(conn net.Conn) (bool, int, bool) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false, 0, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, 0, false
+	}
+	var info *unix.TCPInfo
+	var mtu int
+	raw.Control(func(fd uintptr) {
+		info, _ = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+		mtu, _ = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU)
+	})
+	if info == nil || mtu == 0 {
+		return false, 0, false
+	}
+	return info.Options&TCPI_OPT_SYN_DATA != 0, mtu, true
+}
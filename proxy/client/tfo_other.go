@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// fastOpenConnect leaves the socket alone: TCP_FASTOPEN_CONNECT is Linux-only.
+func fastOpenConnect(network, address string, c syscall.RawConn) error {
+	return nil
+}
+
+func fastOpenResult(conn net.Conn) (bool, int, bool) {
+	return false, 0, false
+}
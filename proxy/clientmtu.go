@@ -0,0 +1,72 @@
+/*
+Sentinel-PQC Proxy - Client-Measured Path MTU
+=============================================
+A client with -probe-mtu measures the path to the proxy before it sends
+its key share (DF probes and the TCP MSS) and carries the result in the
+ClientHello padding as a private extension:
+
+  type 0xfd5d(2) length(2) path MTU(2) TCP MSS(2) flags(1)
+
+The proxy records it under "client_path_mtu" next to the static budget the
+hello was judged by, and notes when the two disagree about fragmentation.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	CLIENT_PATH_MTU_EXTENSION = 0xfd5d
+	CLIENT_PATH_MTU_VERIFIED  = 1
+)
+
+// ClientPathMTU is the path the client measured towards the proxy.
+type ClientPathMTU struct {
+	MTU          int  `json:"mtu"`
+	TCPMSS       int  `json:"tcp_mss,omitempty"`
+	Verified     bool `json:"verified"`
+	Budget       int  `json:"budget_bytes"`
+	StaticBudget int  `json:"static_budget_bytes"`
+	Fragments    bool `json:"fragments_on_path"`
+}
+
+// attachClientPathMTU reads the client's probe extension after the public
+// key, if there is one.
+func attachClientPathMTU(report *GhostReport, clientData []byte, scheme kem.Scheme) {
+	pad := clientData[min(scheme.PublicKeySize(), len(clientData)):]
+	header := binary.BigEndian.AppendUint16(nil, CLIENT_PATH_MTU_EXTENSION)
+	header = binary.BigEndian.AppendUint16(header, 5)
+	if len(pad) < len(header)+5 || !bytes.Equal(pad[:len(header)], header) {
+		return
+	}
+	body := pad[len(header):]
+	pm := &ClientPathMTU{
+		MTU:          int(binary.BigEndian.Uint16(body)),
+		TCPMSS:       int(binary.BigEndian.Uint16(body[2:])),
+		Verified:     body[4]&CLIENT_PATH_MTU_VERIFIED != 0,
+		StaticBudget: report.MTUBudget,
+	}
+	host, _, _ := net.SplitHostPort(report.ClientIP)
+	pm.Budget = pm.MTU - ipHeaderSize(host) - TCP_HEADER_SIZE
+	if pm.TCPMSS > 0 && pm.TCPMSS < pm.Budget {
+		pm.Budget = pm.TCPMSS
+	}
+	pm.Fragments = report.HandshakeSize > pm.Budget
+	report.ClientMTU = pm
+
+	report.logger().Info("client-measured path MTU", "mtu", pm.MTU, "mss", pm.TCPMSS, "verified", pm.Verified,
+		"budget", pm.Budget, "static_budget", pm.StaticBudget)
+	switch {
+	case pm.Fragments && !report.Fragmentation:
+		report.addNote(fmt.Sprintf("Judged safe against %d bytes, but the client measured a %d-byte budget: this hello is segmented on its path.", pm.StaticBudget, pm.Budget))
+	case !pm.Fragments && report.Fragmentation:
+		report.addNote(fmt.Sprintf("The client's path carries %d bytes per packet: the %d-byte threshold is conservative here.", pm.Budget, pm.StaticBudget))
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Sentinel-PQC Proxy - Client Risk
+================================
+Repeated connections from the same client IP are folded into a risk score,
+so operators can see which client populations keep fragmenting instead of
+reading reports one by one:
+
+  risk_score   0-100, the share of the client's handshakes in the last 24h
+               that fragmented, black-holed or timed out on fragments
+  trend        rising / falling / steady: the last 6h against the 18h before
+               (new when there is nothing to compare with)
+
+With -api:
+
+  GET /api/clients              riskiest clients first (?limit=, default 50)
+  GET /api/clients/{ip}         one client with its hourly history
+
+Clients are kept in memory; beyond CLIENT_MAX the one seen longest ago is
+forgotten.
+*/
+
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	CLIENT_BUCKET       = time.Hour
+	CLIENT_BUCKETS      = 24 // hourly buckets behind the score and trend
+	CLIENT_RECENT       = 6  // buckets the trend compares with the rest
+	CLIENT_TREND_POINTS = 10 // score change that counts as a trend
+	CLIENT_MAX          = 10000
+	CLIENT_LIST_LIMIT   = 50
+)
+
+// clientBucket counts one hour of a client's handshakes.
+type clientBucket struct {
+	start      time.Time
+	handshakes int
+	risky      int
+}
+
+type clientTrack struct {
+	first, last time.Time
+	handshakes  int
+	risky       int
+	lastStatus  string
+	buckets     [CLIENT_BUCKETS]clientBucket // ring indexed by hour
+}
+
+var clients struct {
+	mu     sync.Mutex
+	byHost map[string]*clientTrack
+}
+
+// ClientRisk is the API view of one client.
+type ClientRisk struct {
+	Client     string       `json:"client"`
+	Score      int          `json:"risk_score"`
+	Trend      string       `json:"trend"`
+	Recent     int          `json:"handshakes_24h"`
+	Handshakes int          `json:"handshakes"` // since first seen
+	Risky      int          `json:"fragmented"`
+	FirstSeen  string       `json:"first_seen"`
+	LastSeen   string       `json:"last_seen"`
+	LastStatus string       `json:"last_status"`
+	Hourly     []ClientHour `json:"hourly,omitempty"` // oldest first, /api/clients/{ip} only
+}
+
+// ClientHour is one hour of a client's history.
+type ClientHour struct {
+	Hour       string `json:"hour"`
+	Handshakes int    `json:"handshakes"`
+	Fragmented int    `json:"fragmented"`
+}
+
+// recordClientRisk folds a report into its client's tally.
+func recordClientRisk(report GhostReport) {
+	host := clientHost(report.ClientIP)
+	now := time.Now()
+	risky := report.fragmentationTrouble()
+
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	if clients.byHost == nil {
+		clients.byHost = make(map[string]*clientTrack)
+	}
+	c := clients.byHost[host]
+	if c == nil {
+		if len(clients.byHost) >= CLIENT_MAX {
+			forgetOldestClient()
+		}
+		c = &clientTrack{first: now}
+		clients.byHost[host] = c
+	}
+	c.last = now
+	c.lastStatus = report.Status
+	c.handshakes++
+
+	hour := now.Truncate(CLIENT_BUCKET)
+	b := &c.buckets[hour.Unix()/int64(CLIENT_BUCKET/time.Second)%CLIENT_BUCKETS]
+	if !b.start.Equal(hour) {
+		*b = clientBucket{start: hour}
+	}
+	b.handshakes++
+	if risky {
+		c.risky++
+		b.risky++
+	}
+}
+
+// forgetOldestClient drops the client seen longest ago; clients.mu is held.
+func forgetOldestClient() {
+	var oldest string
+	for host, c := range clients.byHost {
+		if oldest == "" || c.last.Before(clients.byHost[oldest].last) {
+			oldest = host
+		}
+	}
+	delete(clients.byHost, oldest)
+}
+
+// risk computes the API view of a client; clients.mu is held.
+func (c *clientTrack) risk(host string, hourly bool) ClientRisk {
+	now := time.Now().Truncate(CLIENT_BUCKET)
+	r := ClientRisk{
+		Client:     host,
+		Handshakes: c.handshakes,
+		Risky:      c.risky,
+		FirstSeen:  c.first.UTC().Format(time.RFC3339),
+		LastSeen:   c.last.UTC().Format(time.RFC3339),
+		LastStatus: c.lastStatus,
+	}
+	var total, risky, recent, recentRisky int
+	for i := CLIENT_BUCKETS - 1; i >= 0; i-- {
+		hour := now.Add(-time.Duration(i) * CLIENT_BUCKET)
+		var b clientBucket
+		if s := c.buckets[hour.Unix()/int64(CLIENT_BUCKET/time.Second)%CLIENT_BUCKETS]; s.start.Equal(hour) {
+			b = s
+		}
+		total += b.handshakes
+		risky += b.risky
+		if i < CLIENT_RECENT {
+			recent += b.handshakes
+			recentRisky += b.risky
+		}
+		if hourly {
+			r.Hourly = append(r.Hourly, ClientHour{Hour: hour.UTC().Format(time.RFC3339), Handshakes: b.handshakes, Fragmented: b.risky})
+		}
+	}
+	r.Recent = total
+	r.Score = percent(risky, total)
+
+	earlier, earlierRisky := total-recent, risky-recentRisky
+	switch delta := percent(recentRisky, recent) - percent(earlierRisky, earlier); {
+	case recent == 0 || earlier == 0:
+		r.Trend = "new"
+	case delta >= CLIENT_TREND_POINTS:
+		r.Trend = "rising"
+	case delta <= -CLIENT_TREND_POINTS:
+		r.Trend = "falling"
+	default:
+		r.Trend = "steady"
+	}
+	return r
+}
+
+func percent(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return int(math.Round(100 * float64(n) / float64(total)))
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func serveClients(w http.ResponseWriter, r *http.Request) {
+	limit := CLIENT_LIST_LIMIT
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit: must be a positive number")
+			return
+		}
+		limit = n
+	}
+	clients.mu.Lock()
+	list := make([]ClientRisk, 0, len(clients.byHost))
+	for host, c := range clients.byHost {
+		list = append(list, c.risk(host, false))
+	}
+	clients.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Recent != b.Recent {
+			return a.Recent > b.Recent
+		}
+		return a.Client < b.Client
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func serveClient(w http.ResponseWriter, r *http.Request) {
+	host := r.PathValue("ip")
+	clients.mu.Lock()
+	c := clients.byHost[host]
+	var risk ClientRisk
+	if c != nil {
+		risk = c.risk(host, true)
+	}
+	clients.mu.Unlock()
+	if c == nil {
+		writeError(w, http.StatusNotFound, "no handshakes from "+host)
+		return
+	}
+	writeJSON(w, http.StatusOK, risk)
+}
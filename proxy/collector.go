@@ -0,0 +1,492 @@
+/*
+Sentinel-PQC Proxy - Fleet Collector
+====================================
+Organizations run probes in several datacenters. The collector command
+receives the reports of all of them and serves one fleet-wide API and
+dashboard; it takes the proxy's own flags for storage, sinks and the API:
+
+  go run . collector -api :9090 -grpc :50051 -sqlite fleet.db -api-tokens tokens.txt
+
+  # on every probe; the token needs the ingest scope (auth.go)
+  SENTINEL_COLLECTOR_TOKEN=... go run . -collector https://collector:9090 -site fra1
+  SENTINEL_COLLECTOR_TOKEN=... go run . -collector grpc://collector:50051 -probe-id edge-7
+
+Probes tag their reports with -probe-id (default the host name) and -site
+before signing them, and push them in batches of up to COLLECTOR_BATCH
+every COLLECTOR_FLUSH, over HTTP (POST /api/ingest) or gRPC (PushReports in
+reportpb/reports.proto):
+
+  POST /api/ingest
+  {"probe":"edge-7","site":"fra1","reports":[{...},{...}]}
+  -> {"accepted":2,"rejected":0}
+
+With credentials configured, a push comes from the probe its token is
+named after, so each probe needs its own ingest token named like its
+-probe-id: a push naming another probe is refused (403, PermissionDenied)
+and reports tagged with another probe are rejected.
+
+While the collector is unreachable the reports are kept, at most
+COLLECTOR_MAX_PENDING of them, and sent once it answers again. The
+collector upgrades every report to the current schema, files it like a
+report of its own (history, sinks, statistics, /api/live) and keeps track of
+its probes at GET /api/probes; the dashboard then shows the fleet and filters
+by probe and site. A probe that sent nothing for COLLECTOR_STALE is marked
+stale.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"sentinel-pqc-proxy/reportpb"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	COLLECTOR_INGEST_PATH = "/api/ingest"
+	COLLECTOR_TOKEN_ENV   = "SENTINEL_COLLECTOR_TOKEN"
+	COLLECTOR_BATCH       = 100
+	COLLECTOR_FLUSH       = 2 * time.Second
+	COLLECTOR_MAX_PENDING = 10000 // oldest reports go first beyond this
+	COLLECTOR_TIMEOUT     = 10 * time.Second
+	COLLECTOR_MAX_BODY    = 32 << 20
+	COLLECTOR_STALE       = 5 * time.Minute
+)
+
+// probe is how this proxy tags its reports.
+var probe struct {
+	id   string
+	site string
+}
+
+// collectorMode is set when the process runs as a collector.
+var collectorMode bool
+
+// setupProbe sets the probe tags; pushing to a collector needs a probe ID,
+// so it defaults to the host name.
+func setupProbe(id, site, collector string) error {
+	if id == "" && collector != "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("-collector needs -probe-id: %v", err)
+		}
+		id = host
+	}
+	if id == "" && site != "" {
+		return fmt.Errorf("-site needs -probe-id or -collector")
+	}
+	probe.id, probe.site = id, site
+	return nil
+}
+
+// ============================================================================
+// PROBE SIDE
+// ============================================================================
+
+type collectorSink struct {
+	target string
+	push   func(batch []json.RawMessage) error
+
+	mu      sync.Mutex
+	pending []json.RawMessage
+	dropped int
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	closer  io.Closer // gRPC connection
+}
+
+func openCollectorSink(target string) (*collectorSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("collector: %q is not a URL like https://host:9090 or grpc://host:50051", target)
+	}
+	s := &collectorSink{
+		target: u.Host,
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	token := os.Getenv(COLLECTOR_TOKEN_ENV)
+	switch u.Scheme {
+	case "http", "https":
+		s.push = httpPusher(strings.TrimSuffix(target, "/")+COLLECTOR_INGEST_PATH, token)
+	case "grpc", "grpcs":
+		creds := insecure.NewCredentials()
+		if u.Scheme == "grpcs" {
+			creds = credentials.NewTLS(&tls.Config{ServerName: u.Hostname()})
+		}
+		conn, err := grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("collector: %v", err)
+		}
+		s.push = grpcPusher(reportpb.NewReportServiceClient(conn), token)
+		s.closer = conn
+	default:
+		return nil, fmt.Errorf("collector: unknown scheme %q (http, https, grpc or grpcs)", u.Scheme)
+	}
+	if token == "" {
+		slog.Warn("collector: pushing without a token; set $" + COLLECTOR_TOKEN_ENV + " if the collector asks for one")
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *collectorSink) Name() string { return "collector:" + s.target }
+
+// Write queues a report for the next push; it never blocks the handshake.
+func (s *collectorSink) Write(r GhostReport) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.pending = append(s.pending, data)
+	full := len(s.pending) >= COLLECTOR_BATCH
+	s.trim()
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// trim drops the oldest reports beyond COLLECTOR_MAX_PENDING; s.mu must be
+// held.
+func (s *collectorSink) trim() {
+	if over := len(s.pending) - COLLECTOR_MAX_PENDING; over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+		slog.Warn("collector: queue full, oldest reports dropped", "collector", s.target, "dropped", s.dropped)
+	}
+}
+
+func (s *collectorSink) run() {
+	defer close(s.done)
+	tick := time.NewTicker(COLLECTOR_FLUSH)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+		case <-s.kick:
+		case <-s.stop:
+			s.flush()
+			return
+		}
+		s.flush()
+	}
+}
+
+// flush pushes the pending reports batch by batch; a failed batch goes back
+// to the front of the queue for the next attempt.
+func (s *collectorSink) flush() {
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), COLLECTOR_BATCH)
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if err := s.push(batch); err != nil {
+			slog.Warn("collector: push failed, will retry", "collector", s.target, "reports", n, "err", err)
+			s.mu.Lock()
+			s.pending = append(batch, s.pending...)
+			s.trim()
+			s.mu.Unlock()
+			return
+		}
+		slog.Debug("collector: reports pushed", "collector", s.target, "reports", n)
+	}
+}
+
+// Close pushes what is still pending.
+func (s *collectorSink) Close() error {
+	close(s.stop)
+	<-s.done
+	if s.closer != nil {
+		s.closer.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) > 0 {
+		return fmt.Errorf("%d report(s) not delivered", len(s.pending))
+	}
+	return nil
+}
+
+// ingestRequest is the body of POST /api/ingest.
+type ingestRequest struct {
+	Probe   string            `json:"probe"`
+	Site    string            `json:"site,omitempty"`
+	Reports []json.RawMessage `json:"reports"`
+}
+
+// ingestResult is the answer to a push.
+type ingestResult struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+}
+
+func httpPusher(endpoint, token string) func([]json.RawMessage) error {
+	client := &http.Client{Timeout: COLLECTOR_TIMEOUT}
+	return func(batch []json.RawMessage) error {
+		body, err := json.Marshal(ingestRequest{Probe: probe.id, Site: probe.site, Reports: batch})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "sentinel-pqc-proxy")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			return fmt.Errorf("HTTP %s", resp.Status)
+		}
+		var result ingestResult
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err == nil && result.Rejected > 0 {
+			slog.Warn("collector: reports rejected", "rejected", result.Rejected)
+		}
+		return nil
+	}
+}
+
+func grpcPusher(client reportpb.ReportServiceClient, token string) func([]json.RawMessage) error {
+	return func(batch []json.RawMessage) error {
+		ctx, cancel := context.WithTimeout(context.Background(), COLLECTOR_TIMEOUT)
+		defer cancel()
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		}
+		req := &reportpb.PushReportsRequest{Probe: probe.id, Site: probe.site}
+		for _, r := range batch {
+			req.ReportsJson = append(req.ReportsJson, string(r))
+		}
+		resp, err := client.PushReports(ctx, req)
+		if err != nil {
+			return err
+		}
+		if rejected := len(batch) - int(resp.GetAccepted()); rejected > 0 {
+			slog.Warn("collector: reports rejected", "rejected", rejected)
+		}
+		return nil
+	}
+}
+
+// ============================================================================
+// COLLECTOR SIDE
+// ============================================================================
+
+// ProbeStatus is one probe in /api/probes.
+type ProbeStatus struct {
+	Probe      string `json:"probe"`
+	Site       string `json:"site,omitempty"`
+	FirstSeen  string `json:"first_seen"`
+	LastSeen   string `json:"last_seen"`
+	Reports    int    `json:"reports"`
+	Detections int    `json:"detections"`
+	LastStatus string `json:"last_status"`
+	Stale      bool   `json:"stale"` // nothing received for COLLECTOR_STALE
+
+	lastSeen time.Time
+}
+
+var fleet struct {
+	mu     sync.Mutex
+	probes map[string]*ProbeStatus // by probe and site
+}
+
+func runCollector(args []string) {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: collector -api ADDR and/or -grpc ADDR [storage, sink and API flags of the proxy]")
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+	if err := setupLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *apiAddr == "" && *grpcAddr == "" {
+		fatal("the collector needs -api and/or -grpc to receive reports")
+	}
+	collectorMode = true
+	fleet.probes = make(map[string]*ProbeStatus)
+	noteRuntimeConfig("collector", "", nil)
+	startReportServices()
+	slog.Info("collector waiting for probe reports")
+	select {}
+}
+
+func registerCollector(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+COLLECTOR_INGEST_PATH, serveIngest)
+	mux.HandleFunc("GET /api/probes", serveProbes)
+}
+
+// pushProbe is the probe a push comes from. With credentials configured it
+// is the name of the push's token, and a push naming another probe is
+// refused; without, it is the probe the push names, or the address that
+// sent it.
+func pushProbe(id apiIdentity, authed bool, claimed, remote string) (string, error) {
+	switch {
+	case authed && claimed != "" && claimed != id.Name:
+		return "", fmt.Errorf("credential %q cannot push the reports of probe %q", id.Name, claimed)
+	case authed:
+		return id.Name, nil
+	case claimed != "":
+		return claimed, nil
+	}
+	return remote, nil
+}
+
+// ingestReports files the reports of one push; reports without probe tags
+// get those of the push. With credentials (authed), a report tagged with
+// another probe than the push's is rejected.
+func ingestReports(probeID, site string, authed bool, reports [][]byte) ingestResult {
+	var result ingestResult
+	for _, data := range reports {
+		r, err := decodeReport(data)
+		if err != nil || r.Timestamp == "" {
+			result.Rejected++
+			continue
+		}
+		if r.Probe == "" {
+			r.Probe, r.Site = probeID, site
+		} else if authed && r.Probe != probeID {
+			result.Rejected++
+			continue
+		}
+		recordProbe(r)
+		storeReport(r)
+		result.Accepted++
+	}
+	if result.Rejected > 0 {
+		slog.Warn("collector: invalid reports rejected", "probe", probeID, "rejected", result.Rejected)
+	}
+	return result
+}
+
+func serveIngest(w http.ResponseWriter, r *http.Request) {
+	var req ingestRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, COLLECTOR_MAX_BODY)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "body: "+err.Error())
+		return
+	}
+	reports := make([][]byte, len(req.Reports))
+	for i, raw := range req.Reports {
+		reports[i] = raw
+	}
+	id, authed := requestIdentity(r)
+	probeID, err := pushProbe(id, authed, req.Probe, clientHost(r.RemoteAddr))
+	if err != nil {
+		slog.Warn("collector: push refused", "remote", r.RemoteAddr, "err", err)
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, ingestReports(probeID, req.Site, authed, reports))
+}
+
+func (reportService) PushReports(ctx context.Context, req *reportpb.PushReportsRequest) (*reportpb.PushReportsResponse, error) {
+	if !collectorMode {
+		return nil, status.Error(codes.FailedPrecondition, "only a collector (go run . collector) accepts reports")
+	}
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = clientHost(p.Addr.String())
+	}
+	reports := make([][]byte, len(req.GetReportsJson()))
+	for i, raw := range req.GetReportsJson() {
+		reports[i] = []byte(raw)
+	}
+	id, authed := contextIdentity(ctx)
+	probeID, err := pushProbe(id, authed, req.GetProbe(), remote)
+	if err != nil {
+		slog.Warn("collector: push refused", "remote", remote, "err", err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	result := ingestReports(probeID, req.GetSite(), authed, reports)
+	return &reportpb.PushReportsResponse{Accepted: int32(result.Accepted)}, nil
+}
+
+// recordProbe counts a report towards its probe.
+func recordProbe(r GhostReport) {
+	now := time.Now()
+	key := r.Probe + "\x00" + r.Site
+	fleet.mu.Lock()
+	defer fleet.mu.Unlock()
+	p := fleet.probes[key]
+	if p == nil {
+		p = &ProbeStatus{Probe: r.Probe, Site: r.Site, FirstSeen: now.UTC().Format(time.RFC3339)}
+		fleet.probes[key] = p
+		slog.Info("collector: new probe", "probe", r.Probe, "site", r.Site)
+	}
+	p.lastSeen = now
+	p.Reports++
+	if r.Status != "SAFE" {
+		p.Detections++
+	}
+	p.LastStatus = r.Status
+}
+
+// fleetStatus lists the probes by site and name.
+func fleetStatus() []ProbeStatus {
+	fleet.mu.Lock()
+	defer fleet.mu.Unlock()
+	list := []ProbeStatus{}
+	for _, p := range fleet.probes {
+		s := *p
+		s.LastSeen = p.lastSeen.UTC().Format(time.RFC3339)
+		s.Stale = time.Since(p.lastSeen) > COLLECTOR_STALE
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Site != list[j].Site {
+			return list[i].Site < list[j].Site
+		}
+		return list[i].Probe < list[j].Probe
+	})
+	return list
+}
+
+func serveProbes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, fleetStatus())
+}
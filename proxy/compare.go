@@ -0,0 +1,120 @@
+/*
+Sentinel-PQC Proxy - Listener Comparison
+========================================
+With more than one listener, every report is also folded into a per-listener
+tally, so an A/B run (1500 vs 1400 vs 1280, Kyber512 vs Kyber768, with and
+without latency) can be read side by side. The tally is rewritten to
+listener_comparison.json after each report and printed as a table:
+
+  listener  budget  algorithms         impairments  runs  frag  avg ms  last status
+  :4433       1460  Kyber768           -               3     0     1.2  SAFE
+  :4434       1280  Kyber512+Kyber768  delay=300ms     3     1   301.5  CRITICAL_RISK
+
+The average handshake time leaves out black-holed connections.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const COMPARISON_FILE = "listener_comparison.json"
+
+// ListenerResult is the tally of one listener.
+type ListenerResult struct {
+	Listener       string  `json:"listener"`
+	MTUBudget      int     `json:"mtu_budget_bytes"`
+	Algorithms     string  `json:"algorithms"`
+	Impairments    string  `json:"impairments,omitempty"`
+	Handshakes     int     `json:"handshakes"`
+	Fragmented     int     `json:"fragmentation_risk"`
+	AvgHandshakeMs float64 `json:"avg_handshake_ms"`
+	LastStatus     string  `json:"last_status,omitempty"`
+	LastAlgorithm  string  `json:"last_algorithm,omitempty"`
+
+	completed int // handshakes with a server flight (not black-holed)
+	totalMs   float64
+}
+
+// ListenerComparison is what listener_comparison.json holds.
+type ListenerComparison struct {
+	Updated   string            `json:"updated"`
+	Listeners []*ListenerResult `json:"listeners"`
+}
+
+var comparison struct {
+	mu      sync.Mutex
+	results []*ListenerResult
+	byAddr  map[string]*ListenerResult
+}
+
+// registerListeners sets up the tally when there is something to compare.
+func registerListeners(listeners []listenerProfile, defaultAlgorithm string) {
+	if len(listeners) < 2 {
+		return
+	}
+	comparison.byAddr = make(map[string]*ListenerResult)
+	for _, p := range listeners {
+		r := &ListenerResult{
+			Listener:    p.Addr,
+			MTUBudget:   p.budget(),
+			Algorithms:  p.algorithms(),
+			Impairments: p.impairment().String(),
+		}
+		if r.Algorithms == "" {
+			r.Algorithms = defaultAlgorithm
+		}
+		comparison.results = append(comparison.results, r)
+		comparison.byAddr[p.Addr] = r
+	}
+}
+
+// recordComparison folds one report into its listener's tally.
+func recordComparison(report GhostReport) {
+	comparison.mu.Lock()
+	defer comparison.mu.Unlock()
+
+	r := comparison.byAddr[report.Listener]
+	if r == nil {
+		return
+	}
+	r.Handshakes++
+	if report.Fragmentation {
+		r.Fragmented++
+	}
+	if report.HandshakeMs > 0 {
+		r.completed++
+		r.totalMs += report.HandshakeMs
+		r.AvgHandshakeMs = r.totalMs / float64(r.completed)
+	}
+	r.LastStatus, r.LastAlgorithm = report.Status, report.Algorithm
+
+	file, err := json.MarshalIndent(ListenerComparison{
+		Updated:   time.Now().Format(time.RFC3339),
+		Listeners: comparison.results,
+	}, "", "  ")
+	if err == nil {
+		err = os.WriteFile(COMPARISON_FILE, file, 0644)
+	}
+	if err != nil {
+		slog.Error("failed to write listener comparison", "file", COMPARISON_FILE, "err", err)
+		return
+	}
+	logComparison()
+}
+
+func logComparison() {
+	for _, r := range comparison.results {
+		imp := r.Impairments
+		if imp == "" {
+			imp = "-"
+		}
+		slog.Debug("listener comparison", "listener", r.Listener, "budget", r.MTUBudget, "algorithms", r.Algorithms,
+			"impairments", imp, "runs", r.Handshakes, "fragmented", r.Fragmented, "avg_ms", r.AvgHandshakeMs, "last_status", r.LastStatus)
+	}
+}
@@ -0,0 +1,681 @@
+/*
+Sentinel-PQC Proxy - Runtime Control Plane
+==========================================
+With -api, the settings an experiment is usually restarted for can be
+changed while the proxy runs, from the dashboard or with curl (operator
+role when -api-tokens or -api-users are set):
+
+  curl -s localhost:9090/api/control | jq .
+  curl -X PATCH localhost:9090/api/control -H 'Authorization: Bearer ...' \
+       --json '{"mtu": 1280, "impairment": {"delay": "300ms", "jitter": "50ms"}}'
+  curl -X PATCH localhost:9090/api/control/listeners/:4434 -H 'Authorization: Bearer ...' \
+       --json '{"algorithms": ["Kyber512", "Kyber768"], "margin": 10}'
+
+  GET    /api/control                    the settings in effect
+  PATCH  /api/control                    change the defaults
+  DELETE /api/control                    back to the command line
+  PATCH  /api/control/listeners/{addr}   change one listener
+  DELETE /api/control/listeners/{addr}   back to its -listen options
+  GET    /api/control/audit              who changed what, newest first
+
+The defaults are what the flags set for every listener without its own
+option:
+
+  mtu            -mtu, the payload budget (SAFE_MTU)
+  algorithms     the KEMs of listeners without alg=
+  impairment     -delay, -jitter, -bandwidth (as bandwidth_kbps), -blackhole
+  marginal       -marginal, severity thresholds (severity.go)
+  multi_segment  -multi-segment
+  webhook_on     -webhook-on, the statuses that fire the webhook
+
+A listener takes mtu, algorithms, impairment, margin and segments like its
+-listen options. Fields left out of a PATCH keep their value; an impairment
+replaces the whole impairment. New settings apply to the next connection;
+-frag-loss and socket options stay as started.
+
+Every change, and every change of an alert rule (alerts.go), is appended to
+-audit-log (control_audit.jsonl) with the credential that made it and the
+settings before and after. Runtime changes themselves are not saved: a
+restart goes back to the command line.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/schemes"
+)
+
+const (
+	CONTROL_MAX_BODY   = 16 << 10
+	CONTROL_AUDIT_KEEP = 1000 // entries kept in memory for /api/control/audit
+)
+
+// ImpairmentSettings is an impairment as the control plane shows and takes it.
+type ImpairmentSettings struct {
+	Delay         string `json:"delay,omitempty"`  // duration, e.g. 300ms
+	Jitter        string `json:"jitter,omitempty"` // duration
+	BandwidthKbps int    `json:"bandwidth_kbps,omitempty"`
+	Blackhole     bool   `json:"blackhole,omitempty"`
+}
+
+// ControlSettings are the defaults; in a PATCH, nil fields stay as they are.
+type ControlSettings struct {
+	MTU          *int                `json:"mtu,omitempty"`
+	Algorithms   []string            `json:"algorithms,omitempty"`
+	Impairment   *ImpairmentSettings `json:"impairment,omitempty"`
+	Marginal     *float64            `json:"marginal,omitempty"`
+	MultiSegment *int                `json:"multi_segment,omitempty"`
+	WebhookOn    []string            `json:"webhook_on,omitempty"`
+}
+
+// ListenerSettings are one listener's; in a PATCH, nil fields stay as they are.
+type ListenerSettings struct {
+	Addr       string              `json:"addr,omitempty"` // ignored in a PATCH
+	MTU        *int                `json:"mtu,omitempty"`
+	Algorithms []string            `json:"algorithms,omitempty"`
+	Impairment *ImpairmentSettings `json:"impairment,omitempty"`
+	Margin     *float64            `json:"margin,omitempty"`
+	Segments   *int                `json:"segments,omitempty"`
+	Changed    bool                `json:"changed,omitempty"` // differs from -listen
+}
+
+// ControlState is the answer of GET /api/control.
+type ControlState struct {
+	ControlSettings
+	Changed   bool               `json:"changed"` // the defaults differ from the command line
+	Listeners []ListenerSettings `json:"listeners"`
+}
+
+// AuditEntry is one change in the audit trail.
+type AuditEntry struct {
+	Time   string `json:"time"`
+	Actor  string `json:"actor"` // credential name, "anonymous" without auth
+	Remote string `json:"remote"`
+	Action string `json:"action"` // e.g. control.update, alert_rule.delete
+	Target string `json:"target,omitempty"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// listenerControl overrides one listener; nil fields keep its -listen options.
+type listenerControl struct {
+	mtu      *int
+	schemes  []kem.Scheme
+	impair   *impairment
+	margin   *float64
+	segments *int
+}
+
+var control struct {
+	mu        sync.Mutex
+	mtu       *int
+	schemes   []kem.Scheme
+	impair    *impairment
+	marginal  *float64
+	segments  *int
+	webhookOn map[string]bool
+	listeners map[string]*listenerControl // by address
+
+	auditPath string
+	audit     []AuditEntry // oldest first
+}
+
+// ============================================================================
+// LIVE SETTINGS
+// ============================================================================
+
+// defaultBudget is the budget of listeners without their own: -mtu, or
+// what the control plane set.
+func defaultBudget() int {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	if control.mtu != nil {
+		return *control.mtu
+	}
+	return *defaultMTU
+}
+
+// defaultImpairment is the impairment of listeners without their own.
+func defaultImpairment() impairment {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	if control.impair != nil {
+		return *control.impair
+	}
+	return flagImpairment()
+}
+
+// defaultSeverityThresholds are -marginal and -multi-segment, or what the
+// control plane set.
+func defaultSeverityThresholds() severityThresholds {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	t := severityThresholds{MarginPct: *marginalPct, Segments: *multiSegment}
+	if control.marginal != nil {
+		t.MarginPct = *control.marginal
+	}
+	if control.segments != nil {
+		t.Segments = *control.segments
+	}
+	return t
+}
+
+// webhookTrigger is the statuses that fire the webhook when the control
+// plane set them, else nil.
+func webhookTrigger() map[string]bool {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	return control.webhookOn
+}
+
+// live returns the listener with the control plane's settings applied.
+// Handlers call it once per connection.
+func (p listenerProfile) live() listenerProfile {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	return p.withControl()
+}
+
+// withControl applies the control plane's settings; control.mu must be held.
+func (p listenerProfile) withControl() listenerProfile {
+	if len(p.Schemes) == 0 && len(control.schemes) > 0 {
+		p.Schemes = control.schemes
+	}
+	c := control.listeners[p.Addr]
+	if c == nil {
+		return p
+	}
+	if c.mtu != nil {
+		p.MTU, p.Jumbo = *c.mtu, false
+	}
+	if c.schemes != nil {
+		p.Schemes = c.schemes
+	}
+	if c.impair != nil {
+		imp := *c.impair
+		imp.FragLoss = *fragLoss
+		if p.Impair != nil {
+			imp.FragLoss = p.Impair.FragLoss
+		}
+		p.Impair = &imp
+	}
+	if c.margin != nil {
+		p.Margin = c.margin
+	}
+	if c.segments != nil {
+		p.Segments = *c.segments
+	}
+	return p
+}
+
+// ============================================================================
+// SETTINGS
+// ============================================================================
+
+func parseSchemes(names []string) ([]kem.Scheme, error) {
+	var list []kem.Scheme
+	for _, name := range names {
+		s := schemes.ByName(name)
+		if s == nil {
+			return nil, fmt.Errorf("algorithms: unknown algorithm %q", name)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func schemeNames(list []kem.Scheme) []string {
+	names := make([]string, len(list))
+	for i, s := range list {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+func checkMTU(mtu *int) error {
+	if mtu != nil && (*mtu < 68 || *mtu > JUMBO_MTU) {
+		return fmt.Errorf("mtu: must be from 68 to %d", JUMBO_MTU)
+	}
+	return nil
+}
+
+func checkThresholds(margin *float64, segments *int) error {
+	if margin != nil && (*margin < 0 || *margin >= 100) {
+		return errors.New("margin: must be a percentage below 100")
+	}
+	if segments != nil && *segments < 2 {
+		return errors.New("segments: must be 2 or more")
+	}
+	return nil
+}
+
+func (s *ImpairmentSettings) impairment() (impairment, error) {
+	var imp impairment
+	var err error
+	if s.Delay != "" {
+		if imp.Delay, err = time.ParseDuration(s.Delay); err != nil || imp.Delay < 0 {
+			return imp, fmt.Errorf("impairment.delay: %q is not a duration like 300ms", s.Delay)
+		}
+	}
+	if s.Jitter != "" {
+		if imp.Jitter, err = time.ParseDuration(s.Jitter); err != nil || imp.Jitter < 0 {
+			return imp, fmt.Errorf("impairment.jitter: %q is not a duration like 50ms", s.Jitter)
+		}
+	}
+	if s.BandwidthKbps < 0 {
+		return imp, errors.New("impairment.bandwidth_kbps: must not be negative")
+	}
+	imp.BandwidthKbps, imp.Blackhole = s.BandwidthKbps, s.Blackhole
+	return imp, nil
+}
+
+func impairmentSettings(imp impairment) *ImpairmentSettings {
+	s := &ImpairmentSettings{BandwidthKbps: imp.BandwidthKbps, Blackhole: imp.Blackhole}
+	if imp.Delay > 0 {
+		s.Delay = imp.Delay.String()
+	}
+	if imp.Jitter > 0 {
+		s.Jitter = imp.Jitter.String()
+	}
+	return s
+}
+
+// runtimeListeners are the listeners and default algorithm the proxy
+// started with. Take them before control.mu: /api/config holds
+// runtimeConfig.mu while budgets take control.mu.
+func runtimeListeners() (string, []listenerProfile) {
+	runtimeConfig.mu.Lock()
+	defer runtimeConfig.mu.Unlock()
+	return runtimeConfig.algorithm, runtimeConfig.listeners
+}
+
+// controlState renders the settings in effect; control.mu must be held.
+func controlState(algorithm string, listeners []listenerProfile) ControlState {
+	st := ControlState{Listeners: []ListenerSettings{}}
+	mtu, imp := *defaultMTU, flagImpairment()
+	marginal, segments := *marginalPct, *multiSegment
+	if control.mtu != nil {
+		mtu = *control.mtu
+	}
+	if control.impair != nil {
+		imp = *control.impair
+	}
+	if control.marginal != nil {
+		marginal = *control.marginal
+	}
+	if control.segments != nil {
+		segments = *control.segments
+	}
+	st.MTU, st.Marginal, st.MultiSegment = &mtu, &marginal, &segments
+	st.Impairment = impairmentSettings(imp)
+	st.Changed = control.mtu != nil || control.schemes != nil || control.impair != nil ||
+		control.marginal != nil || control.segments != nil || control.webhookOn != nil
+
+	st.Algorithms = schemeNames(control.schemes)
+	if len(st.Algorithms) == 0 && algorithm != "" {
+		st.Algorithms = []string{algorithm}
+	}
+	if control.webhookOn != nil {
+		for s := range control.webhookOn {
+			st.WebhookOn = append(st.WebhookOn, s)
+		}
+	} else if *webhookOn != "" {
+		st.WebhookOn = strings.Split(*webhookOn, ",")
+	}
+	slices.Sort(st.WebhookOn)
+
+	for _, p := range listeners {
+		p = p.withControl()
+		l := ListenerSettings{Addr: p.Addr, Algorithms: schemeNames(p.Schemes), Changed: control.listeners[p.Addr] != nil}
+		if len(l.Algorithms) == 0 {
+			l.Algorithms = st.Algorithms
+		}
+		budget := mtu
+		if p.MTU > 0 {
+			budget = p.MTU
+		}
+		l.MTU = &budget
+		lImp := imp
+		if p.Impair != nil {
+			lImp = *p.Impair
+		}
+		l.Impairment = impairmentSettings(lImp)
+		margin, segs := marginal, segments
+		if p.Margin != nil {
+			margin = *p.Margin
+		}
+		if p.Segments > 0 {
+			segs = p.Segments
+		}
+		l.Margin, l.Segments = &margin, &segs
+		st.Listeners = append(st.Listeners, l)
+	}
+	return st
+}
+
+// ============================================================================
+// AUDIT TRAIL
+// ============================================================================
+
+// loadAudit reads the tail of the audit log, so /api/control/audit survives
+// restarts.
+func loadAudit(path string) error {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	control.auditPath = path
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			control.audit = append(control.audit, e)
+		}
+	}
+	if n := len(control.audit) - CONTROL_AUDIT_KEEP; n > 0 {
+		control.audit = control.audit[n:]
+	}
+	return sc.Err()
+}
+
+// recordAudit appends a change to the audit trail; control.mu must not be
+// held.
+func recordAudit(r *http.Request, action, target string, before, after any) {
+	e := AuditEntry{
+		Time:   time.Now().UTC().Format(time.RFC3339),
+		Actor:  "anonymous",
+		Remote: r.RemoteAddr,
+		Action: action,
+		Target: target,
+		Before: before,
+		After:  after,
+	}
+	if id, ok := requestIdentity(r); ok {
+		e.Actor = id.Name
+	}
+	slog.Info("runtime change", "action", action, "target", target, "actor", e.Actor, "remote", e.Remote)
+
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	control.audit = append(control.audit, e)
+	if n := len(control.audit) - CONTROL_AUDIT_KEEP; n > 0 {
+		control.audit = control.audit[n:]
+	}
+	if control.auditPath == "" {
+		return
+	}
+	line, _ := json.Marshal(e)
+	f, err := os.OpenFile(control.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		slog.Error("cannot write audit log", "file", control.auditPath, "err", err)
+	}
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func registerControl(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/control", serveControl)
+	mux.HandleFunc("PATCH /api/control", serveUpdateControl)
+	mux.HandleFunc("DELETE /api/control", serveResetControl)
+	mux.HandleFunc("PATCH /api/control/listeners/{addr}", serveUpdateListenerControl)
+	mux.HandleFunc("DELETE /api/control/listeners/{addr}", serveResetListenerControl)
+	mux.HandleFunc("GET /api/control/audit", serveAudit)
+}
+
+func serveControl(w http.ResponseWriter, r *http.Request) {
+	algorithm, listeners := runtimeListeners()
+	control.mu.Lock()
+	st := controlState(algorithm, listeners)
+	control.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+// decodeControl reads a PATCH body into v.
+func decodeControl(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, CONTROL_MAX_BODY))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func serveUpdateControl(w http.ResponseWriter, r *http.Request) {
+	var s ControlSettings
+	if !decodeControl(w, r, &s) {
+		return
+	}
+	err := checkMTU(s.MTU)
+	if err == nil {
+		err = checkThresholds(s.Marginal, s.MultiSegment)
+	}
+	var list []kem.Scheme
+	if err == nil && s.Algorithms != nil {
+		if list, err = parseSchemes(s.Algorithms); err == nil && len(list) == 0 {
+			err = errors.New("algorithms: name at least one")
+		}
+	}
+	var imp impairment
+	if err == nil && s.Impairment != nil {
+		imp, err = s.Impairment.impairment()
+	}
+	var on map[string]bool
+	if err == nil && s.WebhookOn != nil {
+		on = make(map[string]bool)
+		for _, st := range s.WebhookOn {
+			if st = strings.ToUpper(strings.TrimSpace(st)); st != "" {
+				on[st] = true
+			}
+		}
+		if len(on) == 0 {
+			err = errors.New("webhook_on: name at least one status")
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	algorithm, listeners := runtimeListeners()
+	control.mu.Lock()
+	before := controlState(algorithm, listeners).ControlSettings
+	if s.MTU != nil {
+		control.mtu = s.MTU
+	}
+	if list != nil {
+		control.schemes = list
+	}
+	if s.Impairment != nil {
+		imp.FragLoss = *fragLoss
+		control.impair = &imp
+	}
+	if s.Marginal != nil {
+		control.marginal = s.Marginal
+	}
+	if s.MultiSegment != nil {
+		control.segments = s.MultiSegment
+	}
+	if on != nil {
+		control.webhookOn = on
+	}
+	st := controlState(algorithm, listeners)
+	control.mu.Unlock()
+	recordAudit(r, "control.update", "defaults", before, st.ControlSettings)
+	writeJSON(w, http.StatusOK, st)
+}
+
+func serveResetControl(w http.ResponseWriter, r *http.Request) {
+	algorithm, listeners := runtimeListeners()
+	control.mu.Lock()
+	before := controlState(algorithm, listeners)
+	control.mtu, control.schemes, control.impair = nil, nil, nil
+	control.marginal, control.segments, control.webhookOn = nil, nil, nil
+	control.listeners = nil
+	st := controlState(algorithm, listeners)
+	control.mu.Unlock()
+	recordAudit(r, "control.reset", "all", before, st)
+	writeJSON(w, http.StatusOK, st)
+}
+
+// listener finds a listener's settings in a state.
+func (st ControlState) listener(addr string) (ListenerSettings, bool) {
+	i := slices.IndexFunc(st.Listeners, func(l ListenerSettings) bool { return l.Addr == addr })
+	if i < 0 {
+		return ListenerSettings{}, false
+	}
+	return st.Listeners[i], true
+}
+
+func serveUpdateListenerControl(w http.ResponseWriter, r *http.Request) {
+	addr := r.PathValue("addr")
+	var s ListenerSettings
+	if !decodeControl(w, r, &s) {
+		return
+	}
+	err := checkMTU(s.MTU)
+	if err == nil {
+		err = checkThresholds(s.Margin, s.Segments)
+	}
+	var list []kem.Scheme
+	if err == nil && s.Algorithms != nil {
+		if list, err = parseSchemes(s.Algorithms); err == nil && len(list) == 0 {
+			err = errors.New("algorithms: name at least one")
+		}
+	}
+	var imp impairment
+	if err == nil && s.Impairment != nil {
+		imp, err = s.Impairment.impairment()
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	algorithm, listeners := runtimeListeners()
+	control.mu.Lock()
+	before, ok := controlState(algorithm, listeners).listener(addr)
+	if !ok {
+		control.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no listener on "+strconv.Quote(addr))
+		return
+	}
+	if control.listeners == nil {
+		control.listeners = make(map[string]*listenerControl)
+	}
+	c := control.listeners[addr]
+	if c == nil {
+		c = &listenerControl{}
+		control.listeners[addr] = c
+	}
+	if s.MTU != nil {
+		c.mtu = s.MTU
+	}
+	if list != nil {
+		c.schemes = list
+	}
+	if s.Impairment != nil {
+		c.impair = &imp
+	}
+	if s.Margin != nil {
+		c.margin = s.Margin
+	}
+	if s.Segments != nil {
+		c.segments = s.Segments
+	}
+	after, _ := controlState(algorithm, listeners).listener(addr)
+	control.mu.Unlock()
+	recordAudit(r, "control.listener.update", addr, before, after)
+	writeJSON(w, http.StatusOK, after)
+}
+
+func serveResetListenerControl(w http.ResponseWriter, r *http.Request) {
+	addr := r.PathValue("addr")
+	algorithm, listeners := runtimeListeners()
+	control.mu.Lock()
+	before, ok := controlState(algorithm, listeners).listener(addr)
+	if !ok {
+		control.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no listener on "+strconv.Quote(addr))
+		return
+	}
+	delete(control.listeners, addr)
+	after, _ := controlState(algorithm, listeners).listener(addr)
+	control.mu.Unlock()
+	recordAudit(r, "control.listener.reset", addr, before, after)
+	writeJSON(w, http.StatusOK, after)
+}
+
+func serveAudit(w http.ResponseWriter, r *http.Request) {
+	limit := QUERY_DEFAULT_LIMIT
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > CONTROL_AUDIT_KEEP {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit: must be between 1 and %d", CONTROL_AUDIT_KEEP))
+			return
+		}
+		limit = n
+	}
+	control.mu.Lock()
+	entries := make([]AuditEntry, 0, min(limit, len(control.audit)))
+	for i := len(control.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, control.audit[i])
+	}
+	control.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"entries": entries})
+}
+
+// ============================================================================
+// IDENTITY
+// ============================================================================
+
+type identityKey struct{}
+
+// withIdentity remembers the credential a request was let in with.
+func withIdentity(r *http.Request, id apiIdentity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+}
+
+// requestIdentity is the credential of a request, if the API asked for one.
+func requestIdentity(r *http.Request) (apiIdentity, bool) {
+	return contextIdentity(r.Context())
+}
+
+// contextIdentity is the credential of a request or gRPC call.
+func contextIdentity(ctx context.Context) (apiIdentity, bool) {
+	id, ok := ctx.Value(identityKey{}).(apiIdentity)
+	return id, ok
+}
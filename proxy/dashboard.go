@@ -0,0 +1,129 @@
+/*
+Sentinel-PQC Proxy - Embedded Dashboard
+=======================================
+With -api the proxy serves its own dashboard at /, so a single binary shows
+the results without deploying Module C (dashboard/):
+
+  go run . -api localhost:9090      # then open http://localhost:9090/
+
+It shows the rolling statistics (/api/stats), the latest detections
+(/api/reports) and the running configuration (/api/config) and refreshes
+every few seconds. A live chart plots the handshake sizes of the last ten
+minutes against each listener's MTU budget and the IPv6 minimum, fed by the
+/api/live WebSocket, so a new client population that pushes sizes up shows
+as a spike right away. The page is plain HTML, CSS and JavaScript in web/,
+embedded into the binary at build time; there is no build step. The
+Control tab changes the runtime settings through /api/control (control.go)
+and silences and acknowledges alerts (silences.go).
+
+GET /api/config lists the scenario, listeners, sinks and the flags set on
+the command line. Flags holding URLs with tokens, DSNs or secrets are
+shown as [redacted].
+*/
+
+package main
+
+import (
+	"embed"
+	"flag"
+	"io/fs"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// CONFIG_SECRET_FLAGS are never shown by /api/config.
+var CONFIG_SECRET_FLAGS = map[string]bool{
+	"postgres":       true,
+	"webhook":        true,
+	"webhook-secret": true,
+	"slack":          true,
+	"teams":          true,
+	"smtp-user":      true,
+	"nats":           true,
+}
+
+var runtimeConfig struct {
+	mu        sync.Mutex
+	started   time.Time
+	scenario  string
+	algorithm string // of listeners without alg=
+	listeners []listenerProfile
+}
+
+// noteRuntimeConfig records what /api/config reports once the listeners
+// are configured.
+func noteRuntimeConfig(scenario, algorithm string, listeners []listenerProfile) {
+	runtimeConfig.mu.Lock()
+	defer runtimeConfig.mu.Unlock()
+	runtimeConfig.started = time.Now()
+	runtimeConfig.scenario = scenario
+	runtimeConfig.algorithm = algorithm
+	runtimeConfig.listeners = listeners
+}
+
+func registerDashboard(mux *http.ServeMux) {
+	web, _ := fs.Sub(webFiles, "web")
+	mux.Handle("GET /", http.FileServerFS(web))
+	mux.HandleFunc("GET /api/config", serveConfig)
+}
+
+// listenerConfig is one listener in /api/config.
+type listenerConfig struct {
+	Addr       string `json:"addr"`
+	Budget     int    `json:"budget"`
+	Algorithms string `json:"algorithms"`
+	Options    string `json:"options"`
+}
+
+func serveConfig(w http.ResponseWriter, r *http.Request) {
+	runtimeConfig.mu.Lock()
+	cfg := map[string]any{
+		"started":        runtimeConfig.started.UTC().Format(time.RFC3339),
+		"scenario":       runtimeConfig.scenario,
+		"schema_version": REPORT_SCHEMA_VERSION,
+		"mode":           "proxy",
+	}
+	if collectorMode {
+		cfg["mode"] = "collector"
+	}
+	if probe.id != "" {
+		cfg["probe"], cfg["site"] = probe.id, probe.site
+	}
+	listeners := []listenerConfig{}
+	for _, p := range runtimeConfig.listeners {
+		p = p.live()
+		l := listenerConfig{Addr: p.Addr, Budget: p.budget(), Algorithms: p.algorithms(), Options: p.options()}
+		if l.Algorithms == "" {
+			l.Algorithms = runtimeConfig.algorithm
+		}
+		listeners = append(listeners, l)
+	}
+	runtimeConfig.mu.Unlock()
+	cfg["listeners"] = listeners
+
+	sinks := []string{}
+	reportSinksMu.Lock()
+	for _, s := range reportSinks {
+		sinks = append(sinks, s.Name())
+	}
+	reportSinksMu.Unlock()
+	cfg["sinks"] = sinks
+	_, cfg["history_store"] = historyStore()
+	if signer.keyID != "" {
+		cfg["signing_key_id"] = signer.keyID
+	}
+
+	flags := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+		if CONFIG_SECRET_FLAGS[f.Name] {
+			flags[f.Name] = "[redacted]"
+		}
+	})
+	cfg["flags"] = flags
+	writeJSON(w, http.StatusOK, cfg)
+}
@@ -0,0 +1,322 @@
+/*
+Sentinel-PQC Proxy - Before/After Comparison
+============================================
+After a change (certificate compression, a new default key share, a higher
+MSS clamp) the question is what it did. With -api, GET /api/compare folds
+the reports of two time windows and returns both with their differences:
+
+  curl -s 'localhost:9090/api/compare?at=2025-03-04T14:00:00Z&window=24h' | jq .delta
+  curl -s 'localhost:9090/api/compare?annotation=3f9c0a1b2d4e&subnet=10.20.0.0/16'
+  curl -s 'localhost:9090/api/compare?before_from=2025-03-01T00:00:00Z&before_to=2025-03-02T00:00:00Z&after_from=2025-03-08T00:00:00Z&after_to=2025-03-09T00:00:00Z'
+
+The windows are given one of three ways:
+
+  at, window            window (default 24h) before and after at
+  annotation            around an annotation (annotations.go): before its
+                        time and after its end, window long each
+  before_from ... to    the four bounds, RFC 3339
+
+Each window gets its reports, detection rate, statuses, size and latency
+percentiles and algorithm mix (each algorithm's share of the handshakes).
+delta is after minus before: detection rate and shares in fractions, sizes
+in bytes, latency in milliseconds. The other filters of /api/reports
+(client, status, algorithm, subnet, probe, site) apply to both windows; each
+window reads up to ALGORITHMS_MAX_REPORTS reports.
+*/
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+)
+
+const COMPARE_DEFAULT_WINDOW = 24 * time.Hour
+
+// WindowSummary is one side of a comparison.
+type WindowSummary struct {
+	From          string         `json:"from"`
+	To            string         `json:"to"`
+	Reports       int            `json:"reports"`
+	Truncated     bool           `json:"truncated"`
+	Detections    int            `json:"detections"`
+	DetectionRate float64        `json:"detection_rate"`
+	IPv6Risk      int            `json:"ipv6_risk"`
+	Statuses      map[string]int `json:"statuses"`
+	Size          SizeStats      `json:"size"`
+	Latency       LatencyStats   `json:"latency"`
+	Algorithms    []AlgorithmMix `json:"algorithms"`
+}
+
+// AlgorithmMix is one algorithm's part of a window.
+type AlgorithmMix struct {
+	Algorithm     string  `json:"algorithm"`
+	Family        string  `json:"family"`
+	Reports       int     `json:"reports"`
+	Share         float64 `json:"share"` // of the window's handshakes
+	DetectionRate float64 `json:"detection_rate"`
+}
+
+// MixDelta is how one algorithm's part changed; the detection rate only
+// changes for an algorithm seen in both windows.
+type MixDelta struct {
+	Algorithm     string  `json:"algorithm"`
+	Family        string  `json:"family"`
+	ReportsBefore int     `json:"reports_before"`
+	ReportsAfter  int     `json:"reports_after"`
+	ShareBefore   float64 `json:"share_before"`
+	ShareAfter    float64 `json:"share_after"`
+	Share         float64 `json:"share"`
+	DetectionRate float64 `json:"detection_rate"`
+}
+
+// CompareDelta is after minus before.
+type CompareDelta struct {
+	Reports       int            `json:"reports"`
+	Detections    int            `json:"detections"`
+	DetectionRate float64        `json:"detection_rate"`
+	IPv6Risk      int            `json:"ipv6_risk"`
+	Statuses      map[string]int `json:"statuses"`
+	Size          SizeStats      `json:"size"`
+	LatencyP50    float64        `json:"latency_p50_ms"`
+	LatencyP95    float64        `json:"latency_p95_ms"`
+	Algorithms    []MixDelta     `json:"algorithms"`
+}
+
+// CompareResponse is the answer of GET /api/compare.
+type CompareResponse struct {
+	Generated   string        `json:"generated"`
+	Store       string        `json:"store"`
+	Filters     string        `json:"filters,omitempty"`
+	Before      WindowSummary `json:"before"`
+	After       WindowSummary `json:"after"`
+	Delta       CompareDelta  `json:"delta"`
+	Annotations []Annotation  `json:"annotations"` // from the start of before to the end of after
+}
+
+// summarizeWindow folds the reports of one window.
+func summarizeWindow(reports []GhostReport, from, to time.Time, limit int) WindowSummary {
+	s := WindowSummary{
+		From:      from.UTC().Format(time.RFC3339),
+		To:        to.UTC().Format(time.RFC3339),
+		Reports:   len(reports),
+		Truncated: len(reports) == limit,
+		Statuses:  make(map[string]int),
+	}
+	var sizes []int
+	var latency []float64
+	type alg struct{ reports, detections int }
+	algs := make(map[string]*alg)
+	for _, r := range reports {
+		s.Statuses[r.Status]++
+		a := algs[r.Algorithm]
+		if a == nil {
+			a = &alg{}
+			algs[r.Algorithm] = a
+		}
+		a.reports++
+		if r.Status != "SAFE" {
+			s.Detections++
+			a.detections++
+		}
+		if r.IPv6Risk {
+			s.IPv6Risk++
+		}
+		sizes = append(sizes, r.HandshakeSize)
+		if r.HandshakeMs > 0 {
+			latency = append(latency, r.HandshakeMs)
+		}
+	}
+	s.DetectionRate = detectionRate(s.Detections, s.Reports)
+	s.Size = sizeStats(sizes)
+	s.Latency = latencyStats(latency)
+	s.Algorithms = []AlgorithmMix{}
+	for name, a := range algs {
+		s.Algorithms = append(s.Algorithms, AlgorithmMix{
+			Algorithm:     name,
+			Family:        algorithmFamily(name),
+			Reports:       a.reports,
+			Share:         detectionRate(a.reports, s.Reports),
+			DetectionRate: detectionRate(a.detections, a.reports),
+		})
+	}
+	sort.Slice(s.Algorithms, func(i, j int) bool {
+		if s.Algorithms[i].Reports != s.Algorithms[j].Reports {
+			return s.Algorithms[i].Reports > s.Algorithms[j].Reports
+		}
+		return s.Algorithms[i].Algorithm < s.Algorithms[j].Algorithm
+	})
+	return s
+}
+
+// compareWindows computes after minus before.
+func compareWindows(before, after WindowSummary) CompareDelta {
+	round := func(v float64) float64 { return math.Round(v*10000) / 10000 }
+	d := CompareDelta{
+		Reports:       after.Reports - before.Reports,
+		Detections:    after.Detections - before.Detections,
+		DetectionRate: round(after.DetectionRate - before.DetectionRate),
+		IPv6Risk:      after.IPv6Risk - before.IPv6Risk,
+		Statuses:      make(map[string]int),
+		Size: SizeStats{
+			P50: after.Size.P50 - before.Size.P50,
+			P95: after.Size.P95 - before.Size.P95,
+			P99: after.Size.P99 - before.Size.P99,
+			Max: after.Size.Max - before.Size.Max,
+		},
+		LatencyP50: round(after.Latency.P50 - before.Latency.P50),
+		LatencyP95: round(after.Latency.P95 - before.Latency.P95),
+		Algorithms: []MixDelta{},
+	}
+	for status, n := range before.Statuses {
+		d.Statuses[status] -= n
+	}
+	for status, n := range after.Statuses {
+		d.Statuses[status] += n
+	}
+	mix := make(map[string]*MixDelta)
+	var order []string
+	for _, side := range []struct {
+		list  []AlgorithmMix
+		after bool
+	}{{before.Algorithms, false}, {after.Algorithms, true}} {
+		for _, a := range side.list {
+			m := mix[a.Algorithm]
+			if m == nil {
+				m = &MixDelta{Algorithm: a.Algorithm, Family: a.Family}
+				mix[a.Algorithm] = m
+				order = append(order, a.Algorithm)
+			}
+			if side.after {
+				m.ReportsAfter, m.ShareAfter = a.Reports, a.Share
+				m.DetectionRate += a.DetectionRate
+			} else {
+				m.ReportsBefore, m.ShareBefore = a.Reports, a.Share
+				m.DetectionRate -= a.DetectionRate
+			}
+		}
+	}
+	for _, name := range order {
+		m := mix[name]
+		m.Share = round(m.ShareAfter - m.ShareBefore)
+		m.DetectionRate = round(m.DetectionRate)
+		if m.ReportsBefore == 0 || m.ReportsAfter == 0 {
+			m.DetectionRate = 0
+		}
+		d.Algorithms = append(d.Algorithms, *m)
+	}
+	// The largest shifts in the mix first
+	slices.SortStableFunc(d.Algorithms, func(a, b MixDelta) int {
+		return cmp.Compare(math.Abs(b.Share), math.Abs(a.Share))
+	})
+	return d
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+// compareBounds reads the two windows from a request.
+func compareBounds(r *http.Request) (before, after [2]time.Time, err error) {
+	q := r.URL.Query()
+	window := COMPARE_DEFAULT_WINDOW
+	if v := q.Get("window"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 {
+			return before, after, fmt.Errorf("window: %q is not a positive duration like 24h", v)
+		}
+	}
+	bounds := []string{"before_from", "before_to", "after_from", "after_to"}
+	explicit := slices.ContainsFunc(bounds, func(k string) bool { return q.Get(k) != "" })
+	switch {
+	case explicit:
+		for i, key := range bounds {
+			t := &before[i%2]
+			if i >= 2 {
+				t = &after[i%2]
+			}
+			if *t, err = time.Parse(time.RFC3339, q.Get(key)); err != nil {
+				return before, after, fmt.Errorf("%s: %q is not an RFC 3339 time (give all four bounds)", key, q.Get(key))
+			}
+		}
+		if !before[0].Before(before[1]) || !after[0].Before(after[1]) {
+			return before, after, fmt.Errorf("before_from/after_from: must be earlier than before_to/after_to")
+		}
+	case q.Get("annotation") != "":
+		id := q.Get("annotation")
+		annotations.mu.Lock()
+		i := annotationIndex(annotations.list, id)
+		var a Annotation
+		if i >= 0 {
+			a = annotations.list[i]
+		}
+		annotations.mu.Unlock()
+		if i < 0 {
+			return before, after, fmt.Errorf("annotation: no annotation %q", id)
+		}
+		before = [2]time.Time{a.start.Add(-window), a.start}
+		after = [2]time.Time{a.end, a.end.Add(window)}
+	case q.Get("at") != "":
+		at, perr := time.Parse(time.RFC3339, q.Get("at"))
+		if perr != nil {
+			return before, after, fmt.Errorf("at: %q is not an RFC 3339 time", q.Get("at"))
+		}
+		before = [2]time.Time{at.Add(-window), at}
+		after = [2]time.Time{at, at.Add(window)}
+	default:
+		return before, after, fmt.Errorf("give at (and window), annotation, or before_from, before_to, after_from and after_to")
+	}
+	return before, after, nil
+}
+
+func serveCompare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("since") != "" || q.Get("from") != "" || q.Get("to") != "" {
+		writeError(w, http.StatusBadRequest, "since/from/to: the windows are set with at, annotation or before_from ... after_to")
+		return
+	}
+	before, after, err := compareBounds(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q.Get("limit") == "" {
+		f.Limit = ALGORITHMS_MAX_REPORTS
+	}
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+	var sides [2]WindowSummary
+	for i, bounds := range [][2]time.Time{before, after} {
+		f.From, f.To = bounds[0], bounds[1]
+		reports, err := queryReports(store, f)
+		if err != nil {
+			slog.Error("comparison query failed", "store", name, "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		sides[i] = summarizeWindow(reports, bounds[0], bounds[1], f.Limit)
+	}
+	f.From, f.To = time.Time{}, time.Time{}
+	writeJSON(w, http.StatusOK, CompareResponse{
+		Generated:   time.Now().UTC().Format(time.RFC3339),
+		Store:       name,
+		Filters:     f.describe(),
+		Before:      sides[0],
+		After:       sides[1],
+		Delta:       compareWindows(sides[0], sides[1]),
+		Annotations: annotationsIn(before[0], after[1], f.Subnet),
+	})
+}
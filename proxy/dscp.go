@@ -0,0 +1,63 @@
+/*
+Sentinel-PQC Proxy - DSCP Marking
+=================================
+QoS policies key on the DSCP field, and some shapers police or drop large
+packets of a marked class. -dscp marks the proxy's sockets (the server
+flight, SYN-ACK included) on every listener; a listener can pick its own
+class for side-by-side runs:
+
+  go run . -listen :4433 -listen :4434,dscp=ef -listen :4435,dscp=af41
+
+Classes are given as a number (0-63) or name (cs0-cs7, af11-af43, ef, le).
+The client marks its connections with -dscp. With -capture the DSCP
+values that actually arrived are listed under wire.dscp_in, so a network
+that bleaches or rewrites the client's mark shows up in the report.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var dscpNames = map[string]int{
+	"cs0": 0, "cs1": 8, "cs2": 16, "cs3": 24, "cs4": 32, "cs5": 40, "cs6": 48, "cs7": 56,
+	"af11": 10, "af12": 12, "af13": 14, "af21": 18, "af22": 20, "af23": 22,
+	"af31": 26, "af32": 28, "af33": 30, "af41": 34, "af42": 36, "af43": 38,
+	"ef": 46, "le": 1,
+}
+
+// parseDSCP accepts a DSCP value or class name.
+func parseDSCP(value string) (int, error) {
+	if n, ok := dscpNames[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 63 {
+		return 0, fmt.Errorf("invalid DSCP %q (want 0-63, cs0-cs7, af11-af43, ef or le)", value)
+	}
+	return n, nil
+}
+
+// dscpName renders a DSCP value with its class name where there is one.
+func dscpName(dscp int) string {
+	for name, n := range dscpNames {
+		if n == dscp && name != "cs0" {
+			return fmt.Sprintf("%d (%s)", dscp, strings.ToUpper(name))
+		}
+	}
+	return strconv.Itoa(dscp)
+}
+
+// dscpFlag is the -dscp flag value.
+type dscpFlag int
+
+func (f *dscpFlag) String() string { return strconv.Itoa(int(*f)) }
+
+func (f *dscpFlag) Set(value string) error {
+	n, err := parseDSCP(value)
+	*f = dscpFlag(n)
+	return err
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package main
+
+import (
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDSCP marks a socket's packets (IPv4 TOS / IPv6 traffic class).
+func setDSCP(c syscall.RawConn, network string, dscp int) error {
+	tos := dscp << 2 // the two ECN bits stay clear
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		if strings.HasSuffix(network, "6") {
+			// Dual-stack sockets also send IPv4-mapped traffic
+			unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+			sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+			return
+		}
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
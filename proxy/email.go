@@ -0,0 +1,368 @@
+/*
+Sentinel-PQC Proxy - Email Alerts
+=================================
+-email-to mails GHOST detections (every report that is not SAFE) to
+operators without a chat integration, one mail per detection or as a digest:
+
+  SENTINEL_SMTP_PASSWORD=... go run . -smtp mail.example:587 -smtp-user alerts \
+      -email-from sentinel@example -email-to noc@example,pki@example -email-digest hourly
+
+  Subject: [Sentinel-PQC] 14 ghost detection(s) (hourly digest)
+
+  By verdict:   CRITICAL_RISK 12, SUSPECTED_BLACKHOLE 2
+  By algorithm: Kyber768 14
+  Top clients:  10.0.0.7 (9), 10.0.3.2 (5)
+
+  2026-10-16T16:01:40Z  10.0.0.7  Kyber768  1484 B / 1400  CRITICAL_RISK
+  ...
+
+-email-digest is immediate (default), hourly or daily; digests go out at the
+top of the hour or at midnight UTC, and whatever is pending is mailed when
+the proxy shuts down. Port 465 uses implicit TLS, other ports STARTTLS when
+the server offers it. Authentication (PLAIN) is only attempted over TLS.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	EMAIL_QUEUE_SIZE     = 256
+	EMAIL_DIGEST_MAX     = 200 // detections listed per digest; the rest are counted
+	EMAIL_TIMEOUT        = 30 * time.Second
+	EMAIL_PASSWORD_ENV   = "SENTINEL_SMTP_PASSWORD"
+	EMAIL_SUBJECT_PREFIX = "[Sentinel-PQC]"
+)
+
+type emailSink struct {
+	server   string
+	host     string
+	user     string
+	password string
+	from     string
+	to       []string
+	digest   time.Duration // 0 = immediate
+
+	queue chan GhostReport // immediate mode
+	done  chan struct{}
+
+	mu      sync.Mutex
+	pending []GhostReport // digest mode
+	closed  bool          // guarded by mu like every send to queue
+	stop    chan struct{}
+}
+
+// ============================================================================
+// SETUP
+// ============================================================================
+
+func openEmailSink(server, user, from, to, mode string) (*emailSink, error) {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, fmt.Errorf("email: -smtp %q needs host:port", server)
+	}
+	s := &emailSink{
+		server:   server,
+		host:     host,
+		user:     user,
+		password: os.Getenv(EMAIL_PASSWORD_ENV),
+		from:     from,
+		done:     make(chan struct{}),
+	}
+	for _, addr := range strings.Split(to, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			s.to = append(s.to, addr)
+		}
+	}
+	if len(s.to) == 0 {
+		return nil, fmt.Errorf("email: -email-to lists no recipient")
+	}
+	if s.from == "" {
+		return nil, fmt.Errorf("email: -email-from is required")
+	}
+	if s.user != "" && s.password == "" {
+		return nil, fmt.Errorf("email: -smtp-user needs the password in $%s", EMAIL_PASSWORD_ENV)
+	}
+
+	switch mode {
+	case "immediate":
+		s.queue = make(chan GhostReport, EMAIL_QUEUE_SIZE)
+		go s.runImmediate()
+	case "hourly":
+		s.digest = time.Hour
+	case "daily":
+		s.digest = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("email: unknown -email-digest %q (immediate, hourly or daily)", mode)
+	}
+	if s.digest > 0 {
+		s.stop = make(chan struct{})
+		go s.runDigest()
+	}
+	return s, nil
+}
+
+// ============================================================================
+// SINK
+// ============================================================================
+
+func (s *emailSink) Name() string { return "email:" + s.server }
+
+func (s *emailSink) notifier() string { return "email" }
+
+// Write queues a detection for the next mail; it never blocks the handshake.
+func (s *emailSink) Write(r GhostReport) error {
+	if r.Status == "SAFE" {
+		return nil
+	}
+	return s.alert(r)
+}
+
+func (s *emailSink) alert(r GhostReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	if s.digest > 0 {
+		s.pending = append(s.pending, r)
+		return nil
+	}
+	select {
+	case s.queue <- r:
+		return nil
+	default:
+		return fmt.Errorf("queue full, detection from %s not mailed", r.ClientIP)
+	}
+}
+
+func (s *emailSink) runImmediate() {
+	defer close(s.done)
+	for r := range s.queue {
+		subject := fmt.Sprintf("%s %s: %s %d bytes from %s", EMAIL_SUBJECT_PREFIX, r.Status, r.Algorithm, r.HandshakeSize, clientHost(r.ClientIP))
+		if err := s.send(subject, emailDetection(r)); err != nil {
+			r.logger().Error("email: send failed", "err", err)
+		}
+	}
+}
+
+// runDigest mails the pending detections at every hour or day boundary.
+func (s *emailSink) runDigest() {
+	defer close(s.done)
+	for {
+		next := time.Now().UTC().Truncate(s.digest).Add(s.digest)
+		select {
+		case <-time.After(time.Until(next)):
+			s.sendDigest()
+		case <-s.stop:
+			s.sendDigest()
+			return
+		}
+	}
+}
+
+func (s *emailSink) sendDigest() {
+	s.mu.Lock()
+	reports := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(reports) == 0 {
+		return
+	}
+	period := "hourly"
+	if s.digest >= 24*time.Hour {
+		period = "daily"
+	}
+	subject := fmt.Sprintf("%s %d ghost detection(s) (%s digest)", EMAIL_SUBJECT_PREFIX, len(reports), period)
+	if err := s.send(subject, emailDigest(reports)); err != nil {
+		slog.Error("email: digest not sent, keeping it for the next one", "detections", len(reports), "err", err)
+		s.mu.Lock()
+		s.pending = append(reports, s.pending...)
+		s.mu.Unlock()
+		return
+	}
+	slog.Info("email: digest sent", "detections", len(reports))
+}
+
+// Close mails what is still pending.
+func (s *emailSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	if s.digest > 0 {
+		close(s.stop)
+	} else {
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// ============================================================================
+// SMTP
+// ============================================================================
+
+func (s *emailSink) send(subject, body string) error {
+	msg := s.message(subject, body)
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: EMAIL_TIMEOUT}
+	if strings.HasSuffix(s.server, ":465") {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.server, &tls.Config{ServerName: s.host})
+	} else {
+		conn, err = dialer.Dial("tcp", s.server)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(EMAIL_TIMEOUT))
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.user != "" {
+		// smtp.PlainAuth refuses to send credentials without TLS
+		if err := c.Auth(smtp.PlainAuth("", s.user, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds a plain-text RFC 5322 message with CRLF line endings.
+func (s *emailSink) message(subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// ============================================================================
+// BODIES
+// ============================================================================
+
+func emailDetection(r GhostReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Verdict:    %s\n", r.Status)
+	fmt.Fprintf(&b, "Algorithm:  %s\n", r.Algorithm)
+	fmt.Fprintf(&b, "Size:       %d bytes (budget %d)\n", r.HandshakeSize, r.MTUBudget)
+	if r.ServerHelloSize > 0 {
+		fmt.Fprintf(&b, "Server:     %d bytes\n", r.ServerHelloSize)
+	}
+	fmt.Fprintf(&b, "Client:     %s\n", r.ClientIP)
+	if r.Listener != "" {
+		fmt.Fprintf(&b, "Listener:   %s\n", r.Listener)
+	}
+	fmt.Fprintf(&b, "Time:       %s\n", r.Timestamp)
+	if r.ConnID != "" {
+		fmt.Fprintf(&b, "Connection: %s\n", r.ConnID)
+	}
+	if r.alertRule != "" {
+		fmt.Fprintf(&b, "Rule:       %s\n", r.alertRule)
+	}
+	fmt.Fprintf(&b, "\n%s\n", r.Message)
+	return b.String()
+}
+
+func emailDigest(reports []GhostReport) string {
+	byStatus := map[string]int{}
+	byAlgorithm := map[string]int{}
+	byClient := map[string]int{}
+	byRule := map[string]int{}
+	for _, r := range reports {
+		byStatus[r.Status]++
+		byAlgorithm[r.Algorithm]++
+		byClient[clientHost(r.ClientIP)]++
+		if r.alertRule != "" {
+			byRule[r.alertRule]++
+		}
+	}
+
+	var b strings.Builder
+	if len(byRule) > 0 {
+		fmt.Fprintf(&b, "By rule:      %s\n", emailCounts(byRule, 0, ", %s %d"))
+	}
+	fmt.Fprintf(&b, "By verdict:   %s\n", emailCounts(byStatus, 0, ", %s %d"))
+	fmt.Fprintf(&b, "By algorithm: %s\n", emailCounts(byAlgorithm, 0, ", %s %d"))
+	fmt.Fprintf(&b, "Top clients:  %s\n\n", emailCounts(byClient, 10, ", %s (%d)"))
+	for i, r := range reports {
+		if i == EMAIL_DIGEST_MAX {
+			fmt.Fprintf(&b, "... and %d more\n", len(reports)-i)
+			break
+		}
+		fmt.Fprintf(&b, "%-25s  %-15s  %-10s  %5d B / %-5d  %s\n",
+			r.Timestamp, clientHost(r.ClientIP), r.Algorithm, r.HandshakeSize, r.MTUBudget, r.Status)
+	}
+	return b.String()
+}
+
+// emailCounts lists counts in descending order, at most limit (0 = all).
+func emailCounts(counts map[string]int, limit int, format string) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, format, k, counts[k])
+	}
+	return strings.TrimPrefix(b.String(), ", ")
+}
@@ -0,0 +1,165 @@
+/*
+Sentinel-PQC Proxy - Server-Sent Events
+=======================================
+With -api, GET /api/events is the live feed for clients without a WebSocket
+library: a text/event-stream that EventSource and curl understand as is.
+
+  curl -N localhost:9090/api/events
+  curl -N 'localhost:9090/api/events?events=report&status=CRITICAL_RISK'
+  curl -N 'localhost:9090/api/events?events=status'
+
+It carries three events, each with JSON data:
+
+  report   a report the moment it is saved, as on /api/live
+  status   the /status document, on connect and whenever the proxy turns
+           ok/degraded or a sink turns healthy/unhealthy
+  dropped  {"skipped": N} when the client fell behind and missed reports
+
+?events= picks some of them and ?status= limits reports to some statuses.
+A comment line every EVENTS_HEARTBEAT keeps proxies from closing an idle
+stream. Browsers cannot set headers on an EventSource, so with -api-tokens
+pass ?token= (auth.go).
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	EVENTS_HEARTBEAT    = 15 * time.Second
+	EVENTS_STATUS_CHECK = 5 * time.Second
+	EVENTS_RETRY_MS     = 3000
+)
+
+func serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	q := r.URL.Query()
+	kinds := make(map[string]bool)
+	for _, k := range strings.Split(q.Get("events"), ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k == "" {
+			continue
+		}
+		if k != "report" && k != "status" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown event %q: want report or status", k))
+			return
+		}
+		kinds[k] = true
+	}
+	if len(kinds) == 0 {
+		kinds["report"], kinds["status"] = true, true
+	}
+	want := make(map[string]bool)
+	for _, st := range strings.Split(q.Get("status"), ",") {
+		if st = strings.ToUpper(strings.TrimSpace(st)); st != "" {
+			want[st] = true
+		}
+	}
+
+	var sub *feedSubscriber
+	if kinds["report"] {
+		sub = subscribeReports()
+		defer unsubscribeReports(sub)
+	} else {
+		// a nil channel never delivers
+		sub = &feedSubscriber{}
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", EVENTS_RETRY_MS)
+	flusher.Flush()
+
+	remote := r.RemoteAddr
+	slog.Info("event stream client connected", "client", remote)
+	defer slog.Info("event stream client disconnected", "client", remote)
+
+	send := func(event string, data any) bool {
+		msg, err := json.Marshal(data)
+		if err != nil {
+			return true
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, msg); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	var lastHealth string
+	checkStatus := func() bool {
+		status, _ := statusDocument()
+		if health := statusHealthKey(status); health != lastHealth {
+			lastHealth = health
+			return send("status", status)
+		}
+		return true
+	}
+	if kinds["status"] && !checkStatus() {
+		return
+	}
+
+	heartbeat := time.NewTicker(EVENTS_HEARTBEAT)
+	defer heartbeat.Stop()
+	var statusTick <-chan time.Time
+	if kinds["status"] {
+		t := time.NewTicker(EVENTS_STATUS_CHECK)
+		defer t.Stop()
+		statusTick = t.C
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case report := <-sub.ch:
+			if n := sub.takeDropped(); n > 0 {
+				slog.Warn("event stream client too slow, reports skipped", "client", remote, "skipped", n)
+				if !send("dropped", map[string]int{"skipped": n}) {
+					return
+				}
+			}
+			if len(want) > 0 && !want[report.Status] {
+				continue
+			}
+			if !send("report", report) {
+				return
+			}
+		case <-statusTick:
+			if !checkStatus() {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// statusHealthKey sums up what a status event is sent for: the overall
+// status and which sinks are unhealthy, not the counters that always move.
+func statusHealthKey(status map[string]any) string {
+	key := fmt.Sprint(status["status"])
+	if sinks, ok := status["sinks"].([]SinkHealth); ok {
+		for _, s := range sinks {
+			if !s.Healthy {
+				key += " " + s.Name
+			}
+		}
+	}
+	return key
+}
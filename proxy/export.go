@@ -0,0 +1,334 @@
+/*
+Sentinel-PQC Proxy - Compliance Export
+======================================
+The export command turns stored reports into findings for GRC and
+compliance tooling, as a SARIF 2.1.0 log (the format code scanners use, so
+most audit platforms already import it):
+
+  go run . export -since 168h -o findings.sarif                  # report log
+  go run . export -db reports.db -status CRITICAL_RISK -o findings.sarif
+  curl 'localhost:9090/api/export/sarif?since=24h' > findings.sarif
+
+Every report that is not SAFE becomes a result of one rule, and a handshake
+over the 1280-byte IPv6 minimum MTU is flagged even when it passed:
+
+  SENTINEL-FRAG-001  error    handshake exceeds the MTU budget (CRITICAL_RISK)
+  SENTINEL-FRAG-002  warning  handshake exceeds the IPv6 minimum MTU
+  SENTINEL-FRAG-003  error    fragments lost or timed out (FRAGMENT_TIMEOUT)
+  SENTINEL-PATH-001  error    path black-holes large packets (SUSPECTED_BLACKHOLE)
+  SENTINEL-PATH-002  warning  idle connection dropped (NAT_TIMEOUT)
+  SENTINEL-MBOX-001  error    middlebox interferes with the handshake
+  SENTINEL-PQC-001   error    client cannot negotiate PQC (PQC_IMPOSSIBLE)
+  SENTINEL-GEN-001   note     any other status
+
+Results carry the rule's level and a security-severity score, the client as
+their location, the report's key facts as properties, and a fingerprint of
+rule, client and algorithm so repeated findings collapse into one issue.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+const (
+	SARIF_VERSION = "2.1.0"
+	SARIF_SCHEMA  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// complianceRule is one kind of finding.
+type complianceRule struct {
+	ID       string
+	Name     string
+	Level    string // SARIF level: error, warning, note
+	Severity string // security-severity, 0.0-10.0
+	Short    string
+	Full     string
+}
+
+var complianceRules = []complianceRule{
+	{"SENTINEL-FRAG-001", "HandshakeExceedsMTU", "error", "8.0",
+		"Post-quantum handshake exceeds the MTU budget",
+		"The key exchange is larger than the path's payload budget, so the handshake fragments and is dropped by paths that discard fragments."},
+	{"SENTINEL-FRAG-002", "HandshakeExceedsIPv6MinimumMTU", "warning", "5.0",
+		"Post-quantum handshake exceeds the IPv6 minimum MTU",
+		"The handshake is larger than 1280 bytes, the only MTU every IPv6 path guarantees."},
+	{"SENTINEL-FRAG-003", "FragmentsTimedOut", "error", "7.5",
+		"Handshake fragments were lost or timed out",
+		"The datagram handshake was split into fragments that did not all arrive before reassembly timed out."},
+	{"SENTINEL-PATH-001", "SuspectedBlackHole", "error", "8.5",
+		"Path silently drops large packets",
+		"The transfer of the handshake went silent mid-way, the signature of a PMTU black hole."},
+	{"SENTINEL-PATH-002", "IdleConnectionDropped", "warning", "4.0",
+		"Idle connection was dropped",
+		"A NAT or firewall dropped the connection while it was idle between handshake messages."},
+	{"SENTINEL-MBOX-001", "MiddleboxInterference", "error", "7.0",
+		"Middlebox interferes with the handshake",
+		"A device between client and proxy altered, delayed or truncated the handshake."},
+	{"SENTINEL-PQC-001", "PQCImpossible", "error", "9.0",
+		"Client cannot negotiate post-quantum key exchange",
+		"The client offered a protocol version without post-quantum key exchange."},
+	{"SENTINEL-GEN-001", "OtherDetection", "note", "3.0",
+		"Other Sentinel-PQC detection",
+		"The report was not SAFE for a reason without a dedicated rule."},
+}
+
+// complianceRuleFor maps a report status to its rule ID.
+var complianceRuleFor = map[string]string{
+	"CRITICAL_RISK":          "SENTINEL-FRAG-001",
+	"FRAGMENT_TIMEOUT":       "SENTINEL-FRAG-003",
+	"SUSPECTED_BLACKHOLE":    "SENTINEL-PATH-001",
+	"NAT_TIMEOUT":            "SENTINEL-PATH-002",
+	"MIDDLEBOX_INTERFERENCE": "SENTINEL-MBOX-001",
+	"PQC_IMPOSSIBLE":         "SENTINEL-PQC-001",
+}
+
+// ============================================================================
+// SARIF
+// ============================================================================
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	ShortDescription sarifText         `json:"shortDescription"`
+	FullDescription  sarifText         `json:"fullDescription"`
+	Default          map[string]string `json:"defaultConfiguration"`
+	Properties       map[string]any    `json:"properties"`
+}
+
+type sarifResult struct {
+	RuleID       string            `json:"ruleId"`
+	RuleIndex    int               `json:"ruleIndex"`
+	Level        string            `json:"level"`
+	Message      sarifText         `json:"message"`
+	Locations    []sarifLocation   `json:"locations"`
+	Fingerprints map[string]string `json:"partialFingerprints"`
+	Properties   map[string]any    `json:"properties"`
+}
+
+type sarifLocation struct {
+	Logical []sarifLogical `json:"logicalLocations"`
+}
+
+type sarifLogical struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName,omitempty"`
+	Kind               string `json:"kind"`
+}
+
+// complianceFindings returns the rule IDs a report violates.
+func complianceFindings(r GhostReport) []string {
+	var ids []string
+	if r.Status != "SAFE" && r.Status != "" {
+		id, ok := complianceRuleFor[r.Status]
+		if !ok {
+			id = "SENTINEL-GEN-001"
+		}
+		ids = append(ids, id)
+	}
+	// CRITICAL_RISK already covers the smaller IPv6 budget
+	if r.IPv6Risk && r.Status != "CRITICAL_RISK" {
+		ids = append(ids, "SENTINEL-FRAG-002")
+	}
+	return ids
+}
+
+// sarifExport renders the findings of reports as a SARIF log.
+func sarifExport(reports []GhostReport) sarifLog {
+	driver := sarifDriver{Name: "Sentinel-PQC"}
+	index := make(map[string]int)
+	for i, rule := range complianceRules {
+		index[rule.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               rule.ID,
+			Name:             rule.Name,
+			ShortDescription: sarifText{rule.Short},
+			FullDescription:  sarifText{rule.Full},
+			Default:          map[string]string{"level": rule.Level},
+			Properties:       map[string]any{"security-severity": rule.Severity, "tags": []string{"security", "post-quantum"}},
+		})
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	for _, r := range reports {
+		for _, id := range complianceFindings(r) {
+			rule := complianceRules[index[id]]
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    id,
+				RuleIndex: index[id],
+				Level:     rule.Level,
+				Message:   sarifText{fmt.Sprintf("%s: %s", rule.Short, r.Message)},
+				Locations: []sarifLocation{{Logical: []sarifLogical{{
+					Name:               clientHost(r.ClientIP),
+					FullyQualifiedName: sarifEndpoint(r),
+					Kind:               "endpoint",
+				}}}},
+				Fingerprints: map[string]string{"sentinelFinding/v1": complianceFingerprint(id, r)},
+				Properties:   sarifProperties(r, rule),
+			})
+		}
+	}
+	sort.SliceStable(run.Results, func(i, j int) bool { return run.Results[i].RuleIndex < run.Results[j].RuleIndex })
+	return sarifLog{Schema: SARIF_SCHEMA, Version: SARIF_VERSION, Runs: []sarifRun{run}}
+}
+
+// sarifEndpoint names the client together with the listener it reached.
+func sarifEndpoint(r GhostReport) string {
+	if r.Listener == "" {
+		return clientHost(r.ClientIP)
+	}
+	return clientHost(r.ClientIP) + " -> " + r.Listener
+}
+
+func complianceFingerprint(id string, r GhostReport) string {
+	sum := sha256.Sum256([]byte(id + "|" + clientHost(r.ClientIP) + "|" + r.Algorithm + "|" + r.Listener))
+	return hex.EncodeToString(sum[:16])
+}
+
+func sarifProperties(r GhostReport, rule complianceRule) map[string]any {
+	props := map[string]any{
+		"security-severity":    rule.Severity,
+		"timestamp":            r.Timestamp,
+		"status":               r.Status,
+		"severity":             r.Severity,
+		"algorithm":            r.Algorithm,
+		"handshake_size_bytes": r.HandshakeSize,
+	}
+	if r.MTUBudget > 0 {
+		props["mtu_budget_bytes"] = r.MTUBudget
+	}
+	if r.ConnID != "" {
+		props["conn_id"] = r.ConnID
+	}
+	if r.Geo != nil {
+		props["country"] = r.Geo.Country
+		props["asn"] = r.Geo.ASN
+	}
+	return props
+}
+
+// ============================================================================
+// COMMAND AND API
+// ============================================================================
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	db := fs.String("db", "", "Read the SQLite store written with -sqlite instead of the report log")
+	logFile := fs.String("log", "ghost_reports.jsonl", "Report log to read (rotated .N and .N.gz files are not included)")
+	out := fs.String("o", "", "Write the findings to this file instead of stdout")
+	var f historyFilter
+	fs.StringVar(&f.Client, "client", "", "Only reports from this client IP")
+	fs.StringVar(&f.Status, "status", "", "Only reports with this status (e.g. CRITICAL_RISK)")
+	fs.StringVar(&f.Algorithm, "algorithm", "", "Only reports for this algorithm (e.g. Kyber768)")
+	fs.Func("subnet", "Only reports from clients in this CIDR prefix (e.g. 10.0.0.0/24)", func(v string) error {
+		prefix, err := netip.ParsePrefix(v)
+		f.Subnet = prefix.Masked()
+		return err
+	})
+	fs.DurationVar(&f.Since, "since", 0, "Only reports from the last D (e.g. 168h)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: export [-db FILE | -log FILE] [-client IP] [-status S] [-algorithm A] [-subnet CIDR] [-since D] [-o FILE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	reports, _, err := readStoredReports(*db, *logFile, f)
+	if err != nil {
+		log.Fatalf("[EXPORT] %v", err)
+	}
+
+	findings := sarifExport(reports)
+	data, _ := json.MarshalIndent(findings, "", "  ")
+	if *out == "" {
+		fmt.Println(string(data))
+		return
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+		log.Fatalf("[EXPORT] %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d report(s), %d finding(s) written to %s\n", len(reports), len(findings.Runs[0].Results), *out)
+}
+
+// readStoredReports reads every report the filter selects from a SQLite
+// store, or else from the report log; source names where they came from.
+func readStoredReports(db, logFile string, f historyFilter) (reports []GhostReport, source string, err error) {
+	if db != "" {
+		if _, err := os.Stat(db); err != nil {
+			return nil, "", err
+		}
+		store, err := openSQLiteStore(db)
+		if err != nil {
+			return nil, "", err
+		}
+		defer store.Close()
+		f.Limit = -1
+		reports, err = queryReports(store, f)
+		return reports, "sqlite:" + db, err
+	}
+	reports, err = scanReportLog(logFile, f)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("%w (use -db for a SQLite store)", err)
+	}
+	return reports, "report-log:" + logFile, err
+}
+
+// serveSARIF exports the reports /api/reports would return, as SARIF.
+func serveSARIF(w http.ResponseWriter, r *http.Request) {
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		f.Limit = QUERY_MAX_LIMIT
+	}
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+	reports, err := queryReports(store, f)
+	if err != nil {
+		slog.Error("export query failed", "store", name, "err", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="sentinel-findings.sarif"`)
+	writeJSON(w, http.StatusOK, sarifExport(reports))
+}
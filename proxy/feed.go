@@ -0,0 +1,66 @@
+/*
+Sentinel-PQC Proxy - Live Report Feed
+=====================================
+Streaming APIs (-grpc, the /api/live WebSocket, /api/events) subscribe here to receive every report as soon as
+saveReport has written it. Each subscriber gets a buffered channel; a
+subscriber that falls FEED_BUFFER reports behind misses reports (counted)
+instead of slowing down the handshakes.
+*/
+
+package main
+
+import "sync"
+
+const FEED_BUFFER = 64
+
+// feedSubscriber is one open stream.
+type feedSubscriber struct {
+	ch      chan GhostReport
+	dropped int
+}
+
+var feed struct {
+	mu   sync.Mutex
+	subs map[*feedSubscriber]bool
+}
+
+// subscribeReports returns a subscriber for new reports; call
+// unsubscribeReports when the stream ends.
+func subscribeReports() *feedSubscriber {
+	s := &feedSubscriber{ch: make(chan GhostReport, FEED_BUFFER)}
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	if feed.subs == nil {
+		feed.subs = make(map[*feedSubscriber]bool)
+	}
+	feed.subs[s] = true
+	return s
+}
+
+func unsubscribeReports(s *feedSubscriber) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	delete(feed.subs, s)
+}
+
+// publishReport hands a report to every subscriber without blocking.
+func publishReport(report GhostReport) {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	for s := range feed.subs {
+		select {
+		case s.ch <- report:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// takeDropped returns and resets the reports the subscriber missed.
+func (s *feedSubscriber) takeDropped() int {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
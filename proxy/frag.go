@@ -0,0 +1,225 @@
+/*
+Sentinel-PQC Proxy - Fragment Reassembly Emulation
+==================================================
+A UDP key share that needs IP fragments does not fail with a clean error: a
+router that drops or rate-limits fragments leaves the receiver holding a
+partial datagram until its reassembly timer fires, and the client
+retransmits the whole datagram, fragments and all. Datagram listeners can
+emulate such a path for client->proxy datagrams:
+
+  go run . -scenario ikev2 -frag-loss 0.3            # lose 30% of fragments
+  go run . -scenario noise -frag-loss 1              # routers drop all fragments
+  go run . -udp -listen :4433@1280,fragloss=0.5 -frag-timeout 5s
+
+Every fragment of a datagram above the listener's UDP budget is lost with
+probability -frag-loss; the datagram is only delivered if all of them
+arrive. The proxy keeps per-client state across retransmissions and reports
+it under "fragments": attempts, partial reassemblies, fragments and bytes
+wasted, and the time until delivery. Partial datagrams are held for
+-frag-timeout (Linux ipfrag_time is 30s); a client that stays quiet for
+another 20s after that has given up and is reported as FRAGMENT_TIMEOUT.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	FRAG_RETRANSMIT_STORM = 3                // retransmissions that count as a storm
+	FRAG_GIVE_UP_WINDOW   = 20 * time.Second // quiet after the last timeout = client gave up
+)
+
+// FragmentReport is what a lossy fragment path did to one client's datagrams.
+type FragmentReport struct {
+	FragmentsPerDatagram int     `json:"fragments_per_datagram"`
+	LossRate             float64 `json:"fragment_loss_rate"`
+	Attempts             int     `json:"attempts"`
+	Retransmits          int     `json:"retransmits"`
+	FragmentsSent        int     `json:"fragments_sent"`
+	FragmentsLost        int     `json:"fragments_lost"`
+	PartialReassemblies  int     `json:"partial_reassemblies"` // some, not all, fragments arrived
+	WastedBytes          int     `json:"wasted_bytes"`         // arrived, then discarded on timeout
+	ReassemblyTimeoutMs  float64 `json:"reassembly_timeout_ms"`
+	ElapsedMs            float64 `json:"elapsed_ms"`
+	Delivered            bool    `json:"delivered"`
+}
+
+// fragmentState follows one client's fragmented datagrams until one is
+// delivered or the client goes quiet.
+type fragmentState struct {
+	report *FragmentReport
+	first  time.Time
+	size   int
+	timer  *time.Timer
+	log    connLog // correlates the retransmissions with the final report
+}
+
+// fragmentPath emulates the fragment-dropping routers in front of one
+// datagram listener.
+type fragmentPath struct {
+	mu      sync.Mutex
+	clients map[string]*fragmentState
+
+	profile listenerProfile
+	scheme  kem.Scheme
+}
+
+func newFragmentPath(profile listenerProfile, scheme kem.Scheme) *fragmentPath {
+	return &fragmentPath{clients: make(map[string]*fragmentState), profile: profile, scheme: scheme}
+}
+
+// ============================================================================
+// EMULATION
+// ============================================================================
+
+// ipFragments splits a UDP payload into the IP payload sizes of its
+// fragments on a path with the given UDP budget.
+func ipFragments(size, udpBudget int, ipHeader int) []int {
+	perFragment := udpBudget + UDP_HEADER_SIZE
+	if ipHeader == IPV6_HEADER_SIZE {
+		perFragment -= 8 // fragment extension header
+	}
+	perFragment &^= 7
+	var fragments []int
+	for left := size + UDP_HEADER_SIZE; left > 0; left -= perFragment {
+		fragments = append(fragments, min(left, perFragment))
+	}
+	return fragments
+}
+
+// deliver decides whether a client datagram survives the lossy fragment
+// path. Delivered fragmented datagrams come with the client's history, and
+// every delivered datagram with the logger of its exchange.
+func (f *fragmentPath) deliver(addr net.Addr, datagram []byte) (bool, *FragmentReport, connLog) {
+	imp := f.profile.impairment()
+	peer := addr.String()
+	host, _, _ := net.SplitHostPort(peer)
+	if imp.FragLoss <= 0 {
+		return true, nil, newConnLog()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, ok := f.clients[peer]
+	lg := newConnLog()
+	if ok {
+		lg = st.log
+	}
+	budget := f.profile.budgetFor(peer, lg.Logger) + TCP_HEADER_SIZE - UDP_HEADER_SIZE
+	if len(datagram) <= budget {
+		return true, nil, lg
+	}
+	fragments := ipFragments(len(datagram), budget, ipHeaderSize(host))
+	if !ok {
+		st = &fragmentState{first: time.Now(), size: len(datagram), log: lg, report: &FragmentReport{
+			FragmentsPerDatagram: len(fragments),
+			LossRate:             imp.FragLoss,
+			ReassemblyTimeoutMs:  durationMs(*fragTimeout),
+		}}
+		f.clients[peer] = st
+	}
+	r := st.report
+	r.Attempts++
+	r.Retransmits = r.Attempts - 1
+	r.FragmentsSent += len(fragments)
+
+	arrived, arrivedBytes := 0, 0
+	for _, size := range fragments {
+		if rand.Float64() >= imp.FragLoss {
+			arrived++
+			arrivedBytes += size
+		}
+	}
+	r.FragmentsLost += len(fragments) - arrived
+	r.ElapsedMs = durationMs(time.Since(st.first))
+
+	if arrived == len(fragments) {
+		r.Delivered = true
+		if st.timer != nil {
+			st.timer.Stop()
+		}
+		delete(f.clients, peer)
+		lg.Info("datagram reassembled", "client", peer, "attempt", r.Attempts, "fragments", len(fragments))
+		return true, r, lg
+	}
+
+	if arrived > 0 {
+		r.PartialReassemblies++
+		r.WastedBytes += arrivedBytes
+	}
+	lg.Warn("fragments lost, reassembly pending", "client", peer, "attempt", r.Attempts,
+		"arrived", arrived, "fragments", len(fragments), "timeout", *fragTimeout)
+	giveUp := *fragTimeout + FRAG_GIVE_UP_WINDOW
+	if st.timer == nil {
+		st.timer = time.AfterFunc(giveUp, func() { f.expire(peer) })
+	} else {
+		st.timer.Reset(giveUp)
+	}
+	return false, nil, lg
+}
+
+// expire reports a client that stopped retransmitting before any datagram
+// was reassembled.
+func (f *fragmentPath) expire(peer string) {
+	f.mu.Lock()
+	st, ok := f.clients[peer]
+	delete(f.clients, peer)
+	f.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	r := st.report
+	scheme := f.profile.schemeFor(f.scheme, st.size)
+	report := assessDatagram(peer, scheme, st.size, f.profile, st.log)
+	report.Fragments = r
+	report.Status = "FRAGMENT_TIMEOUT"
+	report.Message = fmt.Sprintf("No datagram reassembled: %d attempt(s), %d of %d fragments lost", r.Attempts, r.FragmentsLost, r.FragmentsSent)
+	st.log.Warn("client gave up on fragmented datagram", "client", peer, "attempts", r.Attempts,
+		"elapsed_ms", r.ElapsedMs, "partial_reassemblies", r.PartialReassemblies)
+	noteFragments(&report)
+
+	saveReport(report)
+	logReportSummary(report)
+}
+
+// noteFragments explains the failure signature in the report's notes.
+func noteFragments(report *GhostReport) {
+	r := report.Fragments
+	if r == nil {
+		return
+	}
+	if r.Retransmits >= FRAG_RETRANSMIT_STORM {
+		report.addNote(fmt.Sprintf("Retransmit storm: the client resent the %d-fragment datagram %d times (%d fragments, %d wasted bytes).",
+			r.FragmentsPerDatagram, r.Retransmits, r.FragmentsSent, r.WastedBytes))
+	}
+	if r.PartialReassemblies > 0 {
+		report.addNote(fmt.Sprintf("%d partial reassemblies held receiver memory until the %.0f ms reassembly timeout.",
+			r.PartialReassemblies, r.ReassemblyTimeoutMs))
+	}
+}
+
+// parseFragLoss parses a fragment loss probability.
+func parseFragLoss(value string) (float64, error) {
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p < 0 || p > 1 {
+		return 0, fmt.Errorf("fragment loss %g is not between 0 and 1", p)
+	}
+	return p, nil
+}
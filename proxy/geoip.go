@@ -0,0 +1,94 @@
+/*
+Sentinel-PQC Proxy - GeoIP / ASN Enrichment
+===========================================
+-geoip looks every client address up in local MaxMind-format databases
+(GeoLite2 / GeoIP2 Country or City, and ASN; DB-IP and IPinfo MMDBs use the
+same layout) and adds the result to the report:
+
+  go run . -geoip GeoLite2-Country.mmdb,GeoLite2-ASN.mmdb
+
+  "geo": {"country": "DE", "asn": 3320, "as_org": "Deutsche Telekom AG"}
+
+Each file's database type decides what it answers. Lookups are local; no
+address leaves the machine. Private, loopback and other non-public
+addresses are not looked up. The statistics (/api/stats) then also break
+reports and detections down by country and network.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoInfo is where a client address is registered.
+type GeoInfo struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"`
+}
+
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoDBs are the open databases, empty without -geoip.
+var geoDBs []*maxminddb.Reader
+
+// openGeoIP opens the comma-separated -geoip databases.
+func openGeoIP(paths string) error {
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		db, err := maxminddb.Open(path)
+		if err != nil {
+			return fmt.Errorf("geoip: %w", err)
+		}
+		geoDBs = append(geoDBs, db)
+		slog.Info("GeoIP database loaded", "file", path, "type", db.Metadata.DatabaseType)
+	}
+	return nil
+}
+
+// enrichGeo adds the country and network of the client to the report.
+func enrichGeo(report *GhostReport) {
+	if len(geoDBs) == 0 {
+		return
+	}
+	ip, err := netip.ParseAddr(clientHost(report.ClientIP))
+	if err != nil {
+		return
+	}
+	ip = ip.Unmap()
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return
+	}
+	var geo GeoInfo
+	for _, db := range geoDBs {
+		var rec geoRecord
+		if err := db.Lookup(net.IP(ip.AsSlice()), &rec); err != nil {
+			report.logger().Debug("GeoIP lookup failed", "type", db.Metadata.DatabaseType, "err", err)
+			continue
+		}
+		if geo.Country == "" {
+			geo.Country = rec.Country.ISOCode
+		}
+		if geo.ASN == 0 {
+			geo.ASN, geo.ASOrg = rec.ASN, rec.ASOrg
+		}
+	}
+	if geo != (GeoInfo{}) {
+		report.Geo = &geo
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Sentinel-PQC Proxy - Grafana Datasource
+=======================================
+With -api the proxy also answers the JSON datasource protocol (the
+simpod-json-datasource plugin, or the older SimpleJSON), so Grafana panels
+can query it directly without a Prometheus in between. Add a "JSON" data
+source with the URL http://proxy:9090/grafana and pick a metric:
+
+  handshakes       reports per interval
+  detections       reports that are not SAFE, per interval
+  detection_rate   detections / handshakes per interval
+  size_p50, size_p95, size_p99, size_max   handshake size in bytes
+
+A target's payload (or a "metric?key=value" target for SimpleJSON) narrows it
+to one algorithm, status or client: {"algorithm": "Kyber768"}.
+
+  GET  /grafana/         connection test
+  POST /grafana/metrics  metric list (simpod); /grafana/search for SimpleJSON
+  POST /grafana/query    time series, bucketed by the panel's interval
+
+Series come from the report history (-sqlite, -postgres or the report log),
+at most GRAFANA_MAX_REPORTS per query.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	GRAFANA_MAX_REPORTS = 100000
+	GRAFANA_MIN_BUCKET  = time.Second
+	GRAFANA_MAX_POINTS  = 10000 // per series, whatever the panel asks for
+)
+
+var grafanaMetrics = []string{"handshakes", "detections", "detection_rate", "size_p50", "size_p95", "size_p99", "size_max"}
+
+// grafanaQuery is the body of POST /grafana/query.
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64 `json:"intervalMs"`
+	MaxDataPoints int   `json:"maxDataPoints"`
+	Targets       []struct {
+		Target  string            `json:"target"`
+		RefID   string            `json:"refId"`
+		Hide    bool              `json:"hide"`
+		Payload map[string]string `json:"payload"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is one time series: datapoints are [value, unix ms].
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+func registerGrafana(mux *http.ServeMux) {
+	mux.HandleFunc("GET /grafana/{$}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /grafana/metrics", func(w http.ResponseWriter, r *http.Request) {
+		list := []map[string]string{}
+		for _, m := range grafanaMetrics {
+			list = append(list, map[string]string{"label": m, "value": m})
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("POST /grafana/search", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, grafanaMetrics)
+	})
+	mux.HandleFunc("POST /grafana/query", serveGrafanaQuery)
+}
+
+func serveGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var q grafanaQuery
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&q); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid query: "+err.Error())
+		return
+	}
+	if q.Range.From.IsZero() || !q.Range.To.After(q.Range.From) {
+		writeError(w, http.StatusBadRequest, "range.from must be before range.to")
+		return
+	}
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+
+	bucket := time.Duration(q.IntervalMs) * time.Millisecond
+	if q.MaxDataPoints > 0 {
+		bucket = max(bucket, q.Range.To.Sub(q.Range.From)/time.Duration(q.MaxDataPoints))
+	}
+	bucket = max(bucket, q.Range.To.Sub(q.Range.From)/GRAFANA_MAX_POINTS, GRAFANA_MIN_BUCKET)
+
+	series := []grafanaSeries{}
+	for _, t := range q.Targets {
+		if t.Hide || t.Target == "" {
+			continue
+		}
+		metric, f := grafanaTarget(t.Target, t.Payload)
+		if !grafanaKnown(metric) {
+			writeError(w, http.StatusBadRequest, "unknown metric "+metric)
+			return
+		}
+		f.From, f.To, f.Limit = q.Range.From, q.Range.To, GRAFANA_MAX_REPORTS
+		reports, err := store.query(f)
+		if err != nil {
+			slog.Error("grafana query failed", "store", name, "err", err)
+			writeError(w, http.StatusInternalServerError, "query failed")
+			return
+		}
+		series = append(series, grafanaSeries{
+			Target:     t.Target,
+			Datapoints: grafanaPoints(metric, reports, q.Range.From, q.Range.To, bucket),
+		})
+	}
+	writeJSON(w, http.StatusOK, series)
+}
+
+// grafanaTarget splits "metric?algorithm=Kyber768" and merges the payload.
+func grafanaTarget(target string, payload map[string]string) (string, historyFilter) {
+	metric, query, _ := strings.Cut(target, "?")
+	params, _ := url.ParseQuery(query)
+	get := func(key string) string {
+		if v := payload[key]; v != "" {
+			return v
+		}
+		return params.Get(key)
+	}
+	return metric, historyFilter{Client: get("client"), Status: get("status"), Algorithm: get("algorithm")}
+}
+
+func grafanaKnown(metric string) bool {
+	for _, m := range grafanaMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// grafanaPoints buckets the reports and computes one metric per bucket,
+// oldest first. Size metrics skip empty buckets; counts report zero.
+func grafanaPoints(metric string, reports []GhostReport, from, to time.Time, bucket time.Duration) [][2]float64 {
+	start := from.Truncate(bucket)
+	n := int((to.Sub(start) + bucket - 1) / bucket)
+	sizes := make([][]int, n)
+	detections := make([]int, n)
+	for _, r := range reports {
+		ts, err := time.Parse(time.RFC3339, r.Timestamp)
+		if err != nil {
+			continue
+		}
+		i := int(ts.Sub(start) / bucket)
+		if i < 0 || i >= n {
+			continue
+		}
+		sizes[i] = append(sizes[i], r.HandshakeSize)
+		if r.Status != "SAFE" {
+			detections[i]++
+		}
+	}
+
+	points := [][2]float64{}
+	for i := range n {
+		at := float64(start.Add(time.Duration(i) * bucket).UnixMilli())
+		total := len(sizes[i])
+		var v float64
+		switch metric {
+		case "handshakes":
+			v = float64(total)
+		case "detections":
+			v = float64(detections[i])
+		case "detection_rate":
+			v = detectionRate(detections[i], total)
+		default:
+			if total == 0 {
+				continue
+			}
+			s := sizeStats(sizes[i])
+			v = float64(map[string]int{"size_p50": s.P50, "size_p95": s.P95, "size_p99": s.P99, "size_max": s.Max}[metric])
+		}
+		points = append(points, [2]float64{v, at})
+	}
+	return points
+}
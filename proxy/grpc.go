@@ -0,0 +1,136 @@
+/*
+Sentinel-PQC Proxy - gRPC Report API
+====================================
+-grpc serves the ReportService from reportpb/reports.proto, for typed
+integrations in any language protoc supports:
+
+  go run . -grpc :50051 -sqlite reports.db
+  grpcurl -plaintext -proto reportpb/reports.proto \
+      -d '{"statuses":["CRITICAL_RISK"]}' localhost:50051 sentinel.pqc.v1.ReportService/StreamReports
+
+  StreamReports   every new report, optionally only some statuses
+  QueryReports    stored reports by client, status, algorithm and age (query.go)
+  PushReports     reports of other proxies, collector only (collector.go)
+
+The messages carry the commonly filtered fields; report_json holds the full
+report. Like -api, the service asks for a token when -api-tokens is set
+(auth.go).
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sentinel-pqc-proxy/reportpb"
+)
+
+type reportService struct {
+	reportpb.UnimplementedReportServiceServer
+}
+
+// startGRPC serves the ReportService on addr in the background.
+func startGRPC(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(grpcAuthOptions()...)
+	reportpb.RegisterReportServiceServer(srv, &reportService{})
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			slog.Error("gRPC server stopped", "err", err)
+		}
+	}()
+	slog.Info("gRPC listening", "addr", ln.Addr().String())
+	return nil
+}
+
+func (reportService) StreamReports(req *reportpb.StreamReportsRequest, stream reportpb.ReportService_StreamReportsServer) error {
+	want := make(map[string]bool)
+	for _, st := range req.GetStatuses() {
+		want[st] = true
+	}
+	sub := subscribeReports()
+	defer unsubscribeReports(sub)
+	ctx := stream.Context()
+	slog.Debug("gRPC stream opened", "statuses", req.GetStatuses())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case r := <-sub.ch:
+			if n := sub.takeDropped(); n > 0 {
+				slog.Warn("gRPC stream too slow, reports skipped", "skipped", n)
+			}
+			if len(want) > 0 && !want[r.Status] {
+				continue
+			}
+			if err := stream.Send(toProtoReport(r)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (reportService) QueryReports(_ context.Context, req *reportpb.QueryReportsRequest) (*reportpb.QueryReportsResponse, error) {
+	store, _ := historyStore()
+	if store == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+	}
+	f := historyFilter{
+		Client:    req.GetClientIp(),
+		Status:    req.GetStatus(),
+		Algorithm: req.GetAlgorithm(),
+		Since:     time.Duration(req.GetSinceSeconds()) * time.Second,
+		Limit:     int(req.GetLimit()),
+		Offset:    max(int(req.GetOffset()), 0),
+	}
+	if f.Limit <= 0 {
+		f.Limit = QUERY_DEFAULT_LIMIT
+	}
+	f.Limit = min(f.Limit, QUERY_MAX_LIMIT)
+	reports, err := store.query(f)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &reportpb.QueryReportsResponse{}
+	for _, r := range reports {
+		resp.Reports = append(resp.Reports, toProtoReport(r))
+	}
+	return resp, nil
+}
+
+func toProtoReport(r GhostReport) *reportpb.GhostReport {
+	full, _ := json.Marshal(r)
+	return &reportpb.GhostReport{
+		Timestamp:             r.Timestamp,
+		ConnId:                r.ConnID,
+		ClientIp:              r.ClientIP,
+		Algorithm:             r.Algorithm,
+		PublicKeySize:         int32(r.PublicKeySize),
+		HandshakeSizeBytes:    int32(r.HandshakeSize),
+		FragmentationRisk:     r.Fragmentation,
+		Ipv6FragmentationRisk: r.IPv6Risk,
+		Status:                r.Status,
+		Message:               r.Message,
+		MtuBudgetBytes:        int32(r.MTUBudget),
+		Listener:              r.Listener,
+		Transport:             r.Transport,
+		HandshakeDurationMs:   r.HandshakeMs,
+		ServerHelloSizeBytes:  int32(r.ServerHelloSize),
+		ReportJson:            string(full),
+		SchemaVersion:         int32(r.SchemaVersion),
+		Severity:              r.Severity,
+		Probe:                 r.Probe,
+		Site:                  r.Site,
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Sentinel-PQC Proxy - Report History
+===================================
+Queries the -sqlite report store from the command line:
+
+  go run . history -db reports.db                      # last 20 reports
+  go run . history -db reports.db -status CRITICAL_RISK -since 24h
+  go run . history -db reports.db -client 192.0.2.10 -limit 100 -json
+  go run . history -db reports.db -subnet 10.0.0.0/24 -algorithm Kyber768
+
+-json prints the full reports as a JSON array, e.g. for the dashboard's
+public/data folder.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+)
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	db := fs.String("db", "reports.db", "SQLite report store written with -sqlite")
+	var f historyFilter
+	fs.StringVar(&f.Client, "client", "", "Only reports from this client IP")
+	fs.StringVar(&f.Status, "status", "", "Only reports with this status (e.g. CRITICAL_RISK)")
+	fs.StringVar(&f.Algorithm, "algorithm", "", "Only reports for this algorithm (e.g. Kyber768)")
+	fs.Func("subnet", "Only reports from clients in this CIDR prefix (e.g. 10.0.0.0/24)", func(v string) error {
+		prefix, err := netip.ParsePrefix(v)
+		f.Subnet = prefix.Masked()
+		return err
+	})
+	fs.DurationVar(&f.Since, "since", 0, "Only reports from the last D (e.g. 24h)")
+	fs.IntVar(&f.Limit, "limit", 20, "Maximum number of reports")
+	asJSON := fs.Bool("json", false, "Print the full reports as a JSON array")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: history [-db FILE] [-client IP] [-status S] [-algorithm A] [-subnet CIDR] [-since D] [-limit N] [-json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if _, err := os.Stat(*db); err != nil {
+		log.Fatalf("[HISTORY] %v", err)
+	}
+	store, err := openSQLiteStore(*db)
+	if err != nil {
+		log.Fatalf("[HISTORY] %v", err)
+	}
+	defer store.Close()
+	reports, err := queryReports(store, f)
+	if err != nil {
+		log.Fatalf("[HISTORY] %v", err)
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(reports, "", "  ")
+		if reports == nil {
+			out = []byte("[]")
+		}
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("%-25s  %-21s  %-10s  %6s  %6s  %s\n", "timestamp", "client", "algorithm", "size", "budget", "status")
+	for _, r := range reports {
+		fmt.Printf("%-25s  %-21s  %-10s  %6d  %6d  %s\n", r.Timestamp, r.ClientIP, r.Algorithm, r.HandshakeSize, r.MTUBudget, r.Status)
+	}
+	fmt.Printf("%d report(s)\n", len(reports))
+}
@@ -0,0 +1,201 @@
+/*
+Sentinel-PQC Proxy - ICMP Black-Hole Detection
+==============================================
+An oversized flight is only half the story. If a router on the path answers
+with ICMP "Fragmentation Needed" (IPv4) or "Packet Too Big" (IPv6), the
+sender shrinks its packets and the handshake survives. If those messages are
+filtered, the oversized packets vanish silently: a PMTU black hole.
+
+With -icmp the proxy opens raw ICMP sockets (needs root / CAP_NET_RAW) and
+matches every PTB message to the connection whose packet triggered it, via
+the original headers quoted inside the ICMP payload. Each report then gets a
+path verdict:
+
+  fits             every flight is within the MTU budget
+  will_fragment    a flight is oversized, and the path says so via ICMP
+  black_hole_risk  a flight is oversized, and no ICMP came back
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	ICMP_FRAG_NEEDED_TYPE = 3 // Destination Unreachable
+	ICMP_FRAG_NEEDED_CODE = 4 // Fragmentation needed and DF set
+	ICMPV6_PACKET_TOO_BIG = 2
+
+	// How long to wait for late PTB messages after the server flight
+	ICMP_GRACE = 250 * time.Millisecond
+)
+
+// ICMPEvent is one PTB message matched to a connection.
+type ICMPEvent struct {
+	Kind   string `json:"kind"`
+	Router string `json:"router"`
+	MTU    int    `json:"mtu"`
+	Time   string `json:"time"`
+}
+
+// icmpWatch collects PTB messages for one peer while its handshake runs.
+type icmpWatch struct {
+	peer   string
+	log    *slog.Logger
+	mu     sync.Mutex
+	events []ICMPEvent
+}
+
+var (
+	icmpActive    bool
+	icmpWatchesMu sync.Mutex
+	icmpWatches   = make(map[string]*icmpWatch)
+)
+
+// ============================================================================
+// LISTENER
+// ============================================================================
+
+// startICMPListener opens the raw ICMP sockets it is allowed to and reports
+// whether at least one is listening.
+func startICMPListener() bool {
+	active := false
+	for _, l := range []struct {
+		network string
+		parse   func([]byte) (string, int, string, bool)
+	}{
+		{"ip4:icmp", parseFragNeeded},
+		{"ip6:ipv6-icmp", parsePacketTooBig},
+	} {
+		pc, err := net.ListenPacket(l.network, "")
+		if err != nil {
+			slog.Warn("cannot listen for ICMP; black holes will not be detected there", "network", l.network, "err", err)
+			continue
+		}
+		slog.Info("listening for ICMP PTB messages", "network", l.network)
+		go readICMP(pc, l.parse)
+		active = true
+	}
+	return active
+}
+
+func readICMP(pc net.PacketConn, parse func([]byte) (string, int, string, bool)) {
+	buffer := make([]byte, 1500)
+	for {
+		n, from, err := pc.ReadFrom(buffer)
+		if err != nil {
+			slog.Error("ICMP listener stopped", "err", err)
+			return
+		}
+		peer, mtu, kind, ok := parse(buffer[:n])
+		if !ok {
+			continue
+		}
+
+		icmpWatchesMu.Lock()
+		w := icmpWatches[peer]
+		icmpWatchesMu.Unlock()
+		if w == nil {
+			continue
+		}
+
+		w.log.Info("ICMP "+kind, "from", from.String(), "next_hop_mtu", mtu)
+		w.mu.Lock()
+		w.events = append(w.events, ICMPEvent{
+			Kind:   kind,
+			Router: from.String(),
+			MTU:    mtu,
+			Time:   time.Now().Format(time.RFC3339Nano),
+		})
+		w.mu.Unlock()
+	}
+}
+
+// parseFragNeeded decodes an ICMPv4 Fragmentation Needed message and returns
+// the destination of the quoted packet (our peer).
+func parseFragNeeded(msg []byte) (string, int, string, bool) {
+	if len(msg) < 8+20 || msg[0] != ICMP_FRAG_NEEDED_TYPE || msg[1] != ICMP_FRAG_NEEDED_CODE {
+		return "", 0, "", false
+	}
+	mtu := int(binary.BigEndian.Uint16(msg[6:8]))
+	inner := msg[8:]
+	ihl := int(inner[0]&0x0f) * 4
+	if len(inner) < ihl+4 {
+		return "", 0, "", false
+	}
+	dst := net.IP(inner[16:20])
+	port := binary.BigEndian.Uint16(inner[ihl+2:])
+	return net.JoinHostPort(dst.String(), strconv.Itoa(int(port))), mtu, "frag_needed", true
+}
+
+// parsePacketTooBig decodes an ICMPv6 Packet Too Big message.
+func parsePacketTooBig(msg []byte) (string, int, string, bool) {
+	if len(msg) < 8+40+4 || msg[0] != ICMPV6_PACKET_TOO_BIG {
+		return "", 0, "", false
+	}
+	mtu := int(binary.BigEndian.Uint32(msg[4:8]))
+	inner := msg[8:]
+	dst := net.IP(inner[24:40])
+	port := binary.BigEndian.Uint16(inner[40+2:])
+	return net.JoinHostPort(dst.String(), strconv.Itoa(int(port))), mtu, "packet_too_big", true
+}
+
+// ============================================================================
+// CORRELATION
+// ============================================================================
+
+// watchICMP starts collecting PTB messages quoting packets sent to peer.
+func watchICMP(peer string, lg *slog.Logger) *icmpWatch {
+	w := &icmpWatch{peer: peer, log: lg}
+	icmpWatchesMu.Lock()
+	icmpWatches[peer] = w
+	icmpWatchesMu.Unlock()
+	return w
+}
+
+func (w *icmpWatch) stop() {
+	icmpWatchesMu.Lock()
+	delete(icmpWatches, w.peer)
+	icmpWatchesMu.Unlock()
+}
+
+// collect waits ICMP_GRACE for late messages and returns what arrived.
+func (w *icmpWatch) collect() []ICMPEvent {
+	time.Sleep(ICMP_GRACE)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]ICMPEvent(nil), w.events...)
+}
+
+// classifyPath tells "will fragment" apart from "will be black-holed".
+func classifyPath(report *GhostReport, events []ICMPEvent) {
+	report.ICMPEvents = events
+
+	oversized := report.Fragmentation
+	for _, f := range report.Flights {
+		oversized = oversized || !f.Fits
+	}
+
+	switch {
+	case len(events) > 0:
+		report.PathVerdict = "will_fragment"
+		report.addNote(fmt.Sprintf("Path signalled MTU %d via ICMP; senders can adapt.", events[0].MTU))
+	case oversized:
+		report.PathVerdict = "black_hole_risk"
+		report.addNote("No ICMP Packet Too Big seen for the oversized flight; a filtered path would black-hole it.")
+	default:
+		report.PathVerdict = "fits"
+	}
+	report.logger().Info("path verdict", "verdict", report.PathVerdict, "ptb_messages", len(events))
+}
@@ -0,0 +1,205 @@
+/*
+Sentinel-PQC Proxy - Interface MTU Detection
+============================================
+With -auto-mtu the proxy reads the MTU of its local interfaces at startup and
+derives each listener's budget from the interface it is bound to, instead of
+assuming SAFE_MTU:
+
+  -listen 10.0.0.5:4433   interface owning 10.0.0.5
+  -listen :4433           smallest MTU of the non-loopback interfaces that
+                          are up (any of them may carry a client)
+
+The budget is the interface MTU minus IP and TCP headers. Listeners with an
+explicit @mtu keep it.
+
+Datacenter listeners (-listen :4433@jumbo) assume 9000-byte frames, but only
+for clients on a directly connected segment whose interface really runs a
+jumbo MTU. East-west traffic that never leaves the fabric is judged against
+the jumbo budget; anything routed in from outside falls back to -mtu.
+*/
+
+package main
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+)
+
+const (
+	JUMBO_MTU_THRESHOLD = 1500 // Anything above standard Ethernet is a jumbo frame
+	JUMBO_MTU           = 9000 // Datacenter jumbo frame
+)
+
+// interfaceMTU is one local interface as seen at startup.
+type interfaceMTU struct {
+	Name     string
+	MTU      int
+	Loopback bool
+	Addrs    []*net.IPNet
+}
+
+func (i interfaceMTU) jumbo() bool { return i.MTU > JUMBO_MTU_THRESHOLD }
+
+var (
+	localInterfacesOnce sync.Once
+	localInterfacesList []interfaceMTU
+)
+
+// localInterfaces returns the interfaces detected at first use.
+func localInterfaces() []interfaceMTU {
+	localInterfacesOnce.Do(func() { localInterfacesList = detectInterfaceMTUs() })
+	return localInterfacesList
+}
+
+// detectInterfaceMTUs lists the local interfaces that are up.
+func detectInterfaceMTUs() []interfaceMTU {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		slog.Warn("cannot enumerate interfaces", "err", err)
+		return nil
+	}
+	var found []interfaceMTU
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		im := interfaceMTU{Name: iface.Name, MTU: iface.MTU, Loopback: iface.Flags&net.FlagLoopback != 0}
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				im.Addrs = append(im.Addrs, ipnet)
+			}
+		}
+		found = append(found, im)
+		slog.Debug("interface", "name", im.Name, "mtu", im.MTU, "jumbo", im.jumbo())
+	}
+	return found
+}
+
+// linkMTU returns the MTU of the interface that owns local, or 0.
+func linkMTU(local net.IP) int {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0
+	}
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.Equal(local) {
+				return iface.MTU
+			}
+		}
+	}
+	return 0
+}
+
+// interfaceNamed returns the dev= interface of a listener. VRF devices
+// (64K MTU, not loopback) carry no link MTU and are skipped.
+func interfaceNamed(name string, ifaces []interfaceMTU) (interfaceMTU, bool) {
+	for _, im := range ifaces {
+		if name != "" && im.Name == name && (im.MTU <= 65535 || im.Loopback) {
+			return im, true
+		}
+	}
+	return interfaceMTU{}, false
+}
+
+// interfaceFor picks the interface a listener address is bound to.
+func interfaceFor(addr string, ifaces []interfaceMTU) (interfaceMTU, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return interfaceMTU{}, false
+	}
+	ip := net.ParseIP(host)
+
+	// Specific address: the interface that owns it
+	if ip != nil && !ip.IsUnspecified() {
+		for _, im := range ifaces {
+			for _, a := range im.Addrs {
+				if a.IP.Equal(ip) {
+					return im, true
+				}
+			}
+		}
+		return interfaceMTU{}, false
+	}
+
+	// Wildcard: the most constrained interface a client could arrive on
+	var best interfaceMTU
+	ok := false
+	for _, im := range ifaces {
+		if im.Loopback || len(im.Addrs) == 0 {
+			continue
+		}
+		if !ok || im.MTU < best.MTU {
+			best, ok = im, true
+		}
+	}
+	return best, ok
+}
+
+// applyInterfaceMTUs sets the budget of every listener without an explicit
+// @mtu from the MTU of the interface it is bound to.
+func applyInterfaceMTUs(listeners []listenerProfile) {
+	ifaces := localInterfaces()
+	for i, p := range listeners {
+		if p.MTU > 0 {
+			slog.Info("listener keeps its explicit budget", "listener", p.Addr, "budget", p.MTU)
+			continue
+		}
+		im, ok := interfaceFor(p.Addr, ifaces)
+		if dev, found := interfaceNamed(p.Device, ifaces); found {
+			im, ok = dev, true
+		}
+		if !ok {
+			slog.Warn("no interface found for listener, keeping budget", "listener", p.Addr, "budget", p.budget())
+			continue
+		}
+		host, _, _ := net.SplitHostPort(p.Addr)
+		listeners[i].MTU = im.MTU - ipHeaderSize(host) - TCP_HEADER_SIZE
+		listeners[i].Interface = im.Name
+		slog.Info("listener budget from interface", "listener", p.Addr, "iface", im.Name, "mtu", im.MTU, "budget", listeners[i].MTU)
+	}
+}
+
+// ============================================================================
+// JUMBO FRAMES
+// ============================================================================
+
+// checkJumboSupport warns when a jumbo listener has no jumbo-capable
+// segment to serve.
+func checkJumboSupport(p listenerProfile) {
+	for _, im := range localInterfaces() {
+		if !im.Loopback && im.MTU >= JUMBO_MTU {
+			slog.Info("jumbo listener segment", "listener", p.Addr, "iface", im.Name, "mtu", im.MTU)
+			return
+		}
+	}
+	slog.Warn("jumbo listener has no jumbo-capable interface; every client will be judged against the default budget",
+		"listener", p.Addr, "jumbo_mtu", JUMBO_MTU, "budget", *defaultMTU)
+}
+
+// jumboSegment returns the jumbo-capable interface peer is directly
+// connected to, if any.
+func jumboSegment(peer string) (interfaceMTU, bool) {
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return interfaceMTU{}, false
+	}
+	for _, im := range localInterfaces() {
+		if im.Loopback || im.MTU < JUMBO_MTU {
+			continue
+		}
+		for _, a := range im.Addrs {
+			if a.Contains(ip) {
+				return im, true
+			}
+		}
+	}
+	return interfaceMTU{}, false
+}
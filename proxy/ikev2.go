@@ -0,0 +1,192 @@
+/*
+Sentinel-PQC Proxy - IKEv2 Scenario
+===================================
+IPsec VPNs negotiate keys with IKEv2 over UDP. Putting an ML-KEM-768 public
+key into the IKE_SA_INIT Key Exchange payload makes the message ~1.3 KB, and
+IKE's own fragmentation (RFC 7383) cannot help: it only applies to encrypted
+messages, never to IKE_SA_INIT (RFC 7383 2.5.3). The datagram is therefore
+IP-fragmented, which many firewalls and NATs drop.
+
+RFC 9242 / RFC 9370 move additional key exchanges into IKE_INTERMEDIATE,
+which IS encrypted and can be IKE-fragmented. The report shows both:
+  - IP fragments of the IKE_SA_INIT as sent
+  - RFC 7383 fragments the same KE payload would need in IKE_INTERMEDIATE
+
+Message layout (IKE_SA_INIT request):
+  IKE header 28 | SA | KE (group 36 = ML-KEM-768) | Nonce | N(FRAGMENTATION_SUPPORTED)
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	IKE_HEADER_SIZE = 28
+	IKE_SA_INIT     = 34
+	IKE_FLAG_INIT   = 0x08
+	IKE_FLAG_RESP   = 0x20
+
+	IKE_PAYLOAD_NONE   = 0
+	IKE_PAYLOAD_SA     = 33
+	IKE_PAYLOAD_KE     = 34
+	IKE_PAYLOAD_NONCE  = 40
+	IKE_PAYLOAD_NOTIFY = 41
+
+	IKE_GROUP_MLKEM768          = 36    // draft-ietf-ipsecme-ikev2-mlkem
+	IKE_NOTIFY_FRAGMENT_SUPPORT = 16430 // IKEV2_FRAGMENTATION_SUPPORTED
+
+	// RFC 7383 Encrypted Fragment overhead with AES-GCM-16:
+	// SKF header(4) + fragment number/total(4) + IV(8) + pad length(1) + ICV(16)
+	IKE_SKF_OVERHEAD = 4 + 4 + 8 + 1 + 16
+)
+
+// ============================================================================
+// RESPONDER
+// ============================================================================
+
+// respondIKEv2 parses an IKE_SA_INIT request, encapsulates to its ML-KEM key
+// and answers with an IKE_SA_INIT response carrying the ciphertext.
+func respondIKEv2(pc net.PacketConn, addr net.Addr, scheme kem.Scheme, datagram []byte, report *GhostReport) error {
+	if len(datagram) < IKE_HEADER_SIZE || datagram[18] != IKE_SA_INIT {
+		return errors.New("not an IKE_SA_INIT request")
+	}
+	spiI := datagram[0:8]
+
+	keData, group, err := findIKEKeyExchange(datagram)
+	if err != nil {
+		return err
+	}
+	if group != IKE_GROUP_MLKEM768 {
+		return fmt.Errorf("unsupported KE group %d", group)
+	}
+
+	pk, err := scheme.UnmarshalBinaryPublicKey(keData)
+	if err != nil {
+		return fmt.Errorf("invalid ML-KEM public key: %w", err)
+	}
+	ct, _, err := scheme.Encapsulate(pk)
+	if err != nil {
+		return fmt.Errorf("encapsulation failed: %w", err)
+	}
+	lg := report.logger()
+	lg.Info("IKE_SA_INIT with ML-KEM-768 KE, encapsulated", "ke_bytes", len(keData))
+
+	spiR := make([]byte, 8)
+	rand.Read(spiR)
+	response := buildIKESAInit(spiI, spiR, IKE_FLAG_RESP, ct)
+	if _, err := pc.WriteTo(response, addr); err != nil {
+		return fmt.Errorf("failed to send IKE_SA_INIT response: %w", err)
+	}
+	lg.Info("sent IKE_SA_INIT response", "bytes", len(response))
+
+	report.Algorithm = "IKEv2 ML-KEM-768"
+	report.PublicKeySize = len(keData)
+	report.PathFits = fitPaths(len(datagram), vpnPaths)
+	logPathFits(report.PathFits, report.logger())
+
+	// Same KE payload carried in an encrypted IKE_INTERMEDIATE exchange
+	inner := 4 + 4 + len(keData) // KE payload header + group/reserved + data
+	report.IKEFragments = make(map[string]int)
+	for _, f := range report.PathFits {
+		capacity := f.Budget - IKE_HEADER_SIZE - IKE_SKF_OVERHEAD
+		report.IKEFragments[f.Path] = (inner + capacity - 1) / capacity
+		lg.Debug("IKE_INTERMEDIATE fragments", "path", f.Path, "fragments", report.IKEFragments[f.Path])
+	}
+
+	for _, f := range report.PathFits {
+		if !f.Fits {
+			report.addNote(fmt.Sprintf("IKE_SA_INIT cannot use IKE fragmentation and becomes %d IP fragments on %s;"+
+				" move ML-KEM to IKE_INTERMEDIATE (RFC 9370).", f.Fragments, f.Path))
+			break
+		}
+	}
+	return nil
+}
+
+// ============================================================================
+// MESSAGE ENCODING
+// ============================================================================
+
+// findIKEKeyExchange walks the payload chain and returns the KE key data.
+func findIKEKeyExchange(msg []byte) ([]byte, uint16, error) {
+	next := msg[16]
+	pos := IKE_HEADER_SIZE
+	for next != IKE_PAYLOAD_NONE {
+		if pos+4 > len(msg) {
+			return nil, 0, errors.New("truncated IKE payload chain")
+		}
+		length := int(binary.BigEndian.Uint16(msg[pos+2:]))
+		if length < 4 || pos+length > len(msg) {
+			return nil, 0, errors.New("invalid IKE payload length")
+		}
+		if next == IKE_PAYLOAD_KE && length >= 8 {
+			return msg[pos+8 : pos+length], binary.BigEndian.Uint16(msg[pos+4:]), nil
+		}
+		next = msg[pos]
+		pos += length
+	}
+	return nil, 0, errors.New("no KE payload in IKE_SA_INIT")
+}
+
+// buildIKESAInit encodes an IKE_SA_INIT message with SA, KE, Nonce and the
+// IKEV2_FRAGMENTATION_SUPPORTED notification.
+func buildIKESAInit(spiI, spiR []byte, flags byte, keData []byte) []byte {
+	// SA: one IKE proposal (ENCR_AES_GCM_16/256, PRF_HMAC_SHA2_256, KE 36)
+	transforms := [][]byte{
+		{3, 0, 0, 12, 1, 0, 0, 20, 0x80, 0x0e, 0x01, 0x00}, // ENCR 20, keylen 256
+		{3, 0, 0, 8, 2, 0, 0, 5},                           // PRF 5
+		{0, 0, 0, 8, 4, 0, 0, IKE_GROUP_MLKEM768},          // KE 36
+	}
+	proposal := []byte{0, 0, 0, 0, 1, 1, 0, byte(len(transforms))}
+	for _, t := range transforms {
+		proposal = append(proposal, t...)
+	}
+	binary.BigEndian.PutUint16(proposal[2:], uint16(len(proposal)))
+
+	ke := binary.BigEndian.AppendUint16(nil, IKE_GROUP_MLKEM768)
+	ke = append(ke, 0, 0)
+	ke = append(ke, keData...)
+
+	nonce := make([]byte, 32)
+	rand.Read(nonce)
+
+	notify := []byte{0, 0}
+	notify = binary.BigEndian.AppendUint16(notify, IKE_NOTIFY_FRAGMENT_SUPPORT)
+
+	payloads := []struct {
+		kind byte
+		body []byte
+	}{
+		{IKE_PAYLOAD_SA, proposal},
+		{IKE_PAYLOAD_KE, ke},
+		{IKE_PAYLOAD_NONCE, nonce},
+		{IKE_PAYLOAD_NOTIFY, notify},
+	}
+
+	msg := append(append([]byte{}, spiI...), spiR...)
+	msg = append(msg, payloads[0].kind, 0x20, IKE_SA_INIT, flags)
+	msg = append(msg, 0, 0, 0, 0, 0, 0, 0, 0) // message ID, length
+	for i, p := range payloads {
+		next := byte(IKE_PAYLOAD_NONE)
+		if i+1 < len(payloads) {
+			next = payloads[i+1].kind
+		}
+		msg = append(msg, next, 0)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(4+len(p.body)))
+		msg = append(msg, p.body...)
+	}
+	binary.BigEndian.PutUint32(msg[24:], uint32(len(msg)))
+	return msg
+}
@@ -0,0 +1,209 @@
+/*
+Sentinel-PQC Proxy - Network Impairments
+========================================
+Chaos modes that make the proxy behave like a bad network instead of a
+polite server, so client software and retry logic can be tested against
+the symptoms PQC handshakes run into in the field.
+
+  -blackhole   A client flight larger than the MTU budget is swallowed:
+               no reply, no error, the connection just hangs (the PMTUD
+               black-hole symptom). The client has to time out.
+
+  -delay D     Every response is held back D (e.g. 300ms for satellite),
+  -jitter J    +/- a uniformly random J, before it is sent.
+
+  -bandwidth K  Reads and writes are paced by a token bucket at K kbit/s in
+               each direction (one-MTU burst), emulating IoT or rural links.
+
+  -frag-loss P Datagram listeners lose each IP fragment with probability P
+               and time out partial reassemblies (see frag.go).
+
+The flags set the impairments of every listener; a listener can bring its
+own instead (-listen :4434@1280,delay=600ms,bandwidth=64,blackhole).
+
+The report's handshake_duration_ms is the time from accepting the client to
+the end of the server flight, impairments included.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	BLACKHOLE_HOLD = 2 * time.Minute // How long a black-holed connection is kept open
+	THROTTLE_BURST = 1500            // Token bucket depth in bytes (one Ethernet MTU)
+)
+
+// impairment is the chaos configuration of one listener.
+type impairment struct {
+	Blackhole     bool
+	Delay         time.Duration
+	Jitter        time.Duration
+	BandwidthKbps int
+	FragLoss      float64 // per-fragment loss on datagram listeners (frag.go)
+}
+
+// flagImpairment is the impairment set by the command-line flags.
+func flagImpairment() impairment {
+	return impairment{Blackhole: *blackholeMode, Delay: *delayFlag, Jitter: *jitterFlag, BandwidthKbps: *bandwidthKbps, FragLoss: *fragLoss}
+}
+
+func (i impairment) String() string {
+	var parts []string
+	if i.Blackhole {
+		parts = append(parts, "blackhole")
+	}
+	if i.Delay > 0 {
+		parts = append(parts, "delay="+i.Delay.String())
+	}
+	if i.Jitter > 0 {
+		parts = append(parts, "jitter="+i.Jitter.String())
+	}
+	if i.BandwidthKbps > 0 {
+		parts = append(parts, fmt.Sprintf("bandwidth=%d", i.BandwidthKbps))
+	}
+	if i.FragLoss > 0 {
+		parts = append(parts, fmt.Sprintf("fragloss=%g", i.FragLoss))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ============================================================================
+// BLACK HOLE
+// ============================================================================
+
+// blackHoled reports whether an oversized flight should be swallowed.
+func blackHoled(report *GhostReport, imp impairment) bool {
+	if !imp.Blackhole || !report.Fragmentation {
+		return false
+	}
+	report.logger().Warn("black hole: dropping oversized flight, never replying",
+		"bytes", report.HandshakeSize, "budget", report.MTUBudget)
+	report.PathVerdict = "black_holed"
+	report.addNote("Black-hole emulation: the oversized flight was dropped without a reply.")
+	return true
+}
+
+// swallow keeps a black-holed connection open, discarding everything the
+// client retries, until it gives up or BLACKHOLE_HOLD expires.
+func swallow(conn net.Conn, lg *slog.Logger) {
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(BLACKHOLE_HOLD))
+	n, _ := io.Copy(io.Discard, conn)
+	lg.Info("black-holed client gave up", "after", time.Since(start).Round(time.Millisecond), "swallowed_bytes", n)
+}
+
+// ============================================================================
+// LATENCY & JITTER
+// ============================================================================
+
+// responseDelay draws one delay from delay +/- jitter.
+func (i impairment) responseDelay() time.Duration {
+	d := i.Delay
+	if i.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(2*i.Jitter))) - i.Jitter
+	}
+	return max(d, 0)
+}
+
+func (i impairment) enabled() bool {
+	return i.Delay > 0 || i.Jitter > 0 || i.BandwidthKbps > 0
+}
+
+// impairedConn delays and paces the traffic of one client connection.
+type impairedConn struct {
+	net.Conn
+	imp      impairment
+	up, down *tokenBucket
+}
+
+func newImpairedConn(conn net.Conn, imp impairment) *impairedConn {
+	return &impairedConn{Conn: conn, imp: imp, up: newTokenBucket(imp.BandwidthKbps), down: newTokenBucket(imp.BandwidthKbps)}
+}
+
+func (c *impairedConn) NetConn() net.Conn { return c.Conn }
+
+func (c *impairedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.up.wait(n)
+	return n, err
+}
+
+func (c *impairedConn) Write(p []byte) (int, error) {
+	time.Sleep(c.imp.responseDelay())
+	c.down.wait(len(p))
+	return c.Conn.Write(p)
+}
+
+// impairedPacketConn delays and paces the datagrams sent back to one peer.
+type impairedPacketConn struct {
+	net.PacketConn
+	imp  impairment
+	down *tokenBucket
+}
+
+func newImpairedPacketConn(pc net.PacketConn, imp impairment) *impairedPacketConn {
+	return &impairedPacketConn{PacketConn: pc, imp: imp, down: newTokenBucket(imp.BandwidthKbps)}
+}
+
+func (c *impairedPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	time.Sleep(c.imp.responseDelay())
+	c.down.wait(len(p))
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// ============================================================================
+// BANDWIDTH THROTTLE
+// ============================================================================
+
+// tokenBucket paces bytes to a fixed rate; a nil bucket does not throttle.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(kbps int) *tokenBucket {
+	if kbps <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: float64(kbps) * 1000 / 8}
+}
+
+// wait blocks until n bytes worth of tokens have accumulated. The bucket
+// starts empty on first use, so every flight pays its serialization time.
+func (b *tokenBucket) wait(n int) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.last.IsZero() {
+		b.last = now
+	}
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*b.rate, THROTTLE_BURST)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens < 0 {
+		debt := time.Duration(-b.tokens / b.rate * float64(time.Second))
+		time.Sleep(debt)
+		b.tokens = 0
+		b.last = time.Now()
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Sentinel-PQC Proxy - Key Confirmation
+=====================================
+A ciphertext on its way back only shows the proxy encapsulated; it does not
+show the client ended up with the same shared secret. After decapsulating,
+the client proves it has the secret, and the proxy proves it back, as the
+two Finished messages of TLS do:
+
+  client -> proxy  HMAC-SHA256(ss, "sentinel-pqc client confirm" || pk || ct)
+  proxy -> client  HMAC-SHA256(ss, "sentinel-pqc server confirm" || pk || ct)
+
+The proxy only answers a MAC that matches, and the report records
+key_confirmation: "confirmed", "mismatch" (the secrets differ: a ciphertext
+or key share changed on the path, or the two ends disagree on the KEM) or
+"missing" (the client closed or went quiet without one, e.g. an older
+client). The stall scenario waits for the client's MAC as its Finished.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	KEY_CONFIRM_SIZE = sha256.Size
+	KEY_CONFIRM_WAIT = 5 * time.Second // for the client's MAC after the ciphertext
+
+	KEY_CONFIRMED        = "confirmed"
+	KEY_CONFIRM_MISMATCH = "mismatch"
+	KEY_CONFIRM_MISSING  = "missing"
+)
+
+// keyConfirmation is the MAC one side sends over the exchange.
+func keyConfirmation(ss, pk, ct []byte, label string) []byte {
+	mac := hmac.New(sha256.New, ss)
+	mac.Write([]byte(label))
+	mac.Write(pk)
+	mac.Write(ct)
+	return mac.Sum(nil)
+}
+
+// confirmKey reads the client's key confirmation until wait runs out,
+// answers it when it matches and records the outcome in the report. The
+// error is the read's, nil once a MAC arrived.
+func confirmKey(conn net.Conn, ss, pk, ct []byte, wait time.Duration, report *GhostReport) error {
+	lg := report.logger()
+	conn.SetReadDeadline(time.Now().Add(wait))
+	got := make([]byte, KEY_CONFIRM_SIZE)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		report.KeyConfirmation = KEY_CONFIRM_MISSING
+		lg.Info("no key confirmation from the client", "err", err)
+		return err
+	}
+	if !hmac.Equal(got, keyConfirmation(ss, pk, ct, "sentinel-pqc client confirm")) {
+		report.KeyConfirmation = KEY_CONFIRM_MISMATCH
+		report.addNote("The client's key confirmation does not match: client and proxy derived different shared secrets.")
+		lg.Warn("key confirmation mismatch: shared secrets differ")
+		return nil
+	}
+	report.KeyConfirmation = KEY_CONFIRMED
+	if _, err := conn.Write(keyConfirmation(ss, pk, ct, "sentinel-pqc server confirm")); err != nil {
+		lg.Info("could not answer the key confirmation", "err", err)
+		return nil
+	}
+	lg.Info("key confirmed: both sides hold the same shared secret")
+	return nil
+}
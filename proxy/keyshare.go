@@ -0,0 +1,132 @@
+/*
+Sentinel-PQC Proxy - Key-Share Prediction Scenario
+==================================================
+Clients do not have to put a 1.2 KB ML-KEM key share into their first
+ClientHello. draft-ietf-tls-key-share-prediction lets them send a small
+predicted share (X25519) and fall back to the full PQ share only when the
+server asks for it with a HelloRetryRequest (HRR):
+
+  predict:  CH(X25519) → HRR(group) → CH(ML-KEM) → SH(ct)   2 round trips
+  full:     CH(ML-KEM) → SH(ct)                              1 round trip
+
+The "hrr" scenario plays a server that insists on the PQ group, and the
+report compares the bytes and round trips of both strategies.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	TLS_VERSION_13  = 0x0304
+	TLS_GROUP_MLKEM = 0x0201 // MLKEM768
+)
+
+// hrrRandom is the special ServerHello.random marking a HelloRetryRequest
+// (RFC 8446 4.1.3): SHA-256("HelloRetryRequest").
+var hrrRandom = []byte{
+	0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11, 0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+	0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e, 0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+}
+
+// respondKeyShareRetry completes the exchange directly when the client sent
+// the full key share, and otherwise retries via HelloRetryRequest.
+func respondKeyShareRetry(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error {
+	pkSize, ctSize := scheme.PublicKeySize(), scheme.CiphertextSize()
+	lg := report.logger()
+
+	if len(clientData) >= pkSize {
+		lg.Info("full key share in first flight, no retry needed", "algorithm", scheme.Name())
+		if err := completeKeyExchange(conn, scheme, clientData, report); err != nil {
+			return err
+		}
+		report.KeyShareStrategies = compareKeyShareStrategies(len(clientData)-pkSize+X25519_SHARE_SIZE, len(clientData), ctSize, "full")
+		return nil
+	}
+
+	// Predicted classical share only: ask for the PQ group
+	firstFlight := len(clientData)
+	hrr := buildHelloRetryRequest(TLS_GROUP_MLKEM)
+	if _, err := conn.Write(hrr); err != nil {
+		return fmt.Errorf("failed to send HelloRetryRequest: %w", err)
+	}
+	lg.Info("predicted share only, sent HelloRetryRequest", "first_flight_bytes", firstFlight, "hrr_bytes", len(hrr))
+
+	secondHello, err := readClientHello(conn, lg)
+	if err != nil {
+		return fmt.Errorf("no ClientHello after HRR: %w", err)
+	}
+	lg.Info("received retried ClientHello", "bytes", len(secondHello))
+
+	// The retried hello is the one that carries the PQ key share
+	*report = assessHandshake(report.ClientIP, scheme, len(secondHello), report.profile, report.MSS, report.log)
+	if err := completeKeyExchange(conn, scheme, secondHello, report); err != nil {
+		return err
+	}
+	report.KeyShareStrategies = compareKeyShareStrategies(firstFlight, len(secondHello), ctSize, "predict")
+	for _, s := range report.KeyShareStrategies {
+		lg.Debug("key share strategy", "strategy", s.Strategy, "client_bytes", s.ClientBytes, "server_bytes", s.ServerBytes, "round_trips", s.RoundTrips)
+	}
+	report.addNote(fmt.Sprintf("Prediction kept the first flight at %d bytes at the cost of an extra round trip.", firstFlight))
+	return nil
+}
+
+// compareKeyShareStrategies prices both strategies from the hello sizes.
+func compareKeyShareStrategies(predictedHello, fullHello, ctSize int, used string) []StrategyCost {
+	hrrSize := len(buildHelloRetryRequest(TLS_GROUP_MLKEM))
+	return []StrategyCost{
+		{
+			Strategy:    "full",
+			ClientBytes: fullHello,
+			ServerBytes: ctSize,
+			RoundTrips:  1,
+			Used:        used == "full",
+		},
+		{
+			Strategy:    "predict",
+			ClientBytes: predictedHello + fullHello,
+			ServerBytes: hrrSize + ctSize,
+			RoundTrips:  2,
+			Used:        used == "predict",
+		},
+	}
+}
+
+// buildHelloRetryRequest encodes an HRR selecting the given key-share group.
+func buildHelloRetryRequest(group uint16) []byte {
+	var ext []byte
+	ext = binary.BigEndian.AppendUint16(ext, 0x002b) // supported_versions
+	ext = binary.BigEndian.AppendUint16(ext, 2)
+	ext = binary.BigEndian.AppendUint16(ext, TLS_VERSION_13)
+	ext = binary.BigEndian.AppendUint16(ext, 0x0033) // key_share (selected_group)
+	ext = binary.BigEndian.AppendUint16(ext, 2)
+	ext = binary.BigEndian.AppendUint16(ext, group)
+
+	sessionID := make([]byte, 32)
+	rand.Read(sessionID)
+
+	body := binary.BigEndian.AppendUint16(nil, TLS_VERSION_12)
+	body = append(body, hrrRandom...)
+	body = append(body, byte(len(sessionID)))
+	body = append(body, sessionID...)
+	body = binary.BigEndian.AppendUint16(body, 0x1301) // TLS_AES_128_GCM_SHA256
+	body = append(body, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ext)))
+	body = append(body, ext...)
+
+	handshake := []byte{TLS_HANDSHAKE_SHELLO, 0, 0, 0}
+	binary.BigEndian.PutUint16(handshake[2:], uint16(len(body)))
+	handshake = append(handshake, body...)
+
+	record := []byte{TLS_RECORD_HANDSHAKE}
+	record = binary.BigEndian.AppendUint16(record, TLS_VERSION_12)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
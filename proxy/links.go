@@ -0,0 +1,101 @@
+/*
+Sentinel-PQC Proxy - Cellular & Satellite Link Presets
+======================================================
+Field teams rarely know the right combination of -mtu, -delay, -jitter and
+-bandwidth for the transport they care about. A link preset bundles the
+carrier-typical MTU with the latency of the access network:
+
+  go run . -link geo                      # every listener behind a GEO satellite
+  go run . -listen :4433@lte -listen :4434@geo -listen :4435@nbiot
+
+  preset   MTU    RTT           bandwidth   models
+  5g       1400    20ms +/- 5ms        -    5G NR, GTP-U tunnelled
+  lte      1350    50ms +/- 15ms       -    LTE, conservative GTP-U path MTU
+  3g       1350   150ms +/- 50ms  384kbit   UMTS/HSPA fallback
+  nbiot    1280   800ms +/- 400ms  20kbit   NB-IoT / LTE-M sensors
+  leo      1500    40ms +/- 20ms       -    LEO constellation (Starlink-like)
+  geo      1500   600ms +/- 20ms  2000kbit  GEO satellite without a PEP
+
+The RTT is applied as the response delay (see impair.go); the MTU sets the
+listener's payload budget after IPv4 and TCP headers. Options after the
+preset replace single values (-listen :4434@lte,jitter=80ms). Reports name
+the preset under "link_preset".
+*/
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+// linkPreset is the MTU and latency profile of one access network.
+type linkPreset struct {
+	MTU           int
+	RTT           time.Duration
+	Jitter        time.Duration
+	BandwidthKbps int
+}
+
+var linkPresets = map[string]linkPreset{
+	"5g":    {MTU: 1400, RTT: 20 * time.Millisecond, Jitter: 5 * time.Millisecond},
+	"lte":   {MTU: 1350, RTT: 50 * time.Millisecond, Jitter: 15 * time.Millisecond},
+	"3g":    {MTU: 1350, RTT: 150 * time.Millisecond, Jitter: 50 * time.Millisecond, BandwidthKbps: 384},
+	"nbiot": {MTU: 1280, RTT: 800 * time.Millisecond, Jitter: 400 * time.Millisecond, BandwidthKbps: 20},
+	"leo":   {MTU: 1500, RTT: 40 * time.Millisecond, Jitter: 20 * time.Millisecond},
+	"geo":   {MTU: 1500, RTT: 600 * time.Millisecond, Jitter: 20 * time.Millisecond, BandwidthKbps: 2000},
+}
+
+// linkPresetNames lists the presets for error messages.
+func linkPresetNames() string {
+	names := make([]string, 0, len(linkPresets))
+	for name := range linkPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyLink gives a listener a link preset's budget and impairments on top
+// of the impairment flags. Values the listener sets itself are kept.
+func (p *listenerProfile) applyLink(name string) error {
+	link, ok := linkPresets[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown link preset %q (want %s)", name, linkPresetNames())
+	}
+	p.Link = strings.ToLower(name)
+	if p.MTU == 0 {
+		p.MTU = link.MTU - IPV4_HEADER_SIZE - TCP_HEADER_SIZE
+	}
+	if p.Impair == nil {
+		imp := flagImpairment()
+		p.Impair = &imp
+	}
+	if p.Impair.Delay == 0 {
+		p.Impair.Delay = link.RTT
+	}
+	if p.Impair.Jitter == 0 {
+		p.Impair.Jitter = link.Jitter
+	}
+	if p.Impair.BandwidthKbps == 0 {
+		p.Impair.BandwidthKbps = link.BandwidthKbps
+	}
+	return nil
+}
+
+// logLink describes a listener's link preset at startup.
+func logLink(p listenerProfile) {
+	imp := p.impairment()
+	slog.Info("listener link preset", "listener", p.Addr, "link", p.Link, "budget", p.MTU,
+		"delay", imp.Delay, "jitter", imp.Jitter, "bandwidth", bandwidthLabel(imp.BandwidthKbps))
+}
+
+func bandwidthLabel(kbps int) string {
+	if kbps == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d kbit/s", kbps)
+}
@@ -0,0 +1,427 @@
+/*
+Sentinel-PQC Proxy - Listeners & MTU Profiles
+=============================================
+Every listener carries its own payload budget, so one proxy can judge a VPN,
+a PPPoE and a plain Ethernet path differently:
+
+  go run . -mtu 1400                       # one listener on :4433, 1400 bytes
+  go run . -listen :4433 -listen :4434@1280 -listen :4435@1372
+  go run . -listen :4433@jumbo             # datacenter fabric, 9000-byte frames
+  go run . -listen :4433@pppoe -listen :4434@pppoe+wireguard
+  go run . -listen :4433@lte -listen :4434@geo   # link presets (links.go)
+
+Options after a comma give a listener its own algorithm set and impairments,
+for side-by-side A/B runs (results are compared in listener_comparison.json):
+
+  go run . -listen :4433@1500 -listen :4434@1400,delay=300ms \
+           -listen :4435@1280,alg=Kyber512+Kyber768,bandwidth=64,blackhole
+
+  alg=A+B      KEMs the listener accepts; with several, a client's key share
+               is matched to the largest public key that fits its payload
+  dev=IFACE    pin the listener to an interface or VRF (Linux), so a
+               multi-homed host can be tested per uplink
+  dscp=CLASS   DSCP mark for the server flight instead of -dscp (see dscp.go)
+  margin=PCT, segments=N
+               severity thresholds instead of -marginal and -multi-segment
+               (see severity.go)
+  delay, jitter, bandwidth, fragloss, blackhole
+               replace the global impairment flags for this listener
+
+Encapsulation presets subtract their headers from a 1500-byte Ethernet MTU
+and can be stacked with "+":
+
+  pppoe      8 bytes   (MTU 1492)
+  gre       24 bytes   (MTU 1476)
+  6in4      20 bytes   (MTU 1480, carries IPv6 inside IPv4)
+  wireguard 80 bytes   (MTU 1420, IPv6 outer header worst case)
+
+A listener without "@mtu" uses the -mtu default (SAFE_MTU unless set), or
+with -auto-mtu the MTU of the interface it is bound to (see ifmtu.go).
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/schemes"
+)
+
+const ETHERNET_MTU = 1500
+
+// encapsulation is the per-packet overhead of one tunnel or link layer.
+type encapsulation struct {
+	Overhead  int
+	InnerIPv6 bool // the tunnel only carries IPv6
+}
+
+var encapsulationPresets = map[string]encapsulation{
+	"pppoe":     {Overhead: 8},
+	"gre":       {Overhead: 24},
+	"6in4":      {Overhead: 20, InnerIPv6: true},
+	"wireguard": {Overhead: 80},
+}
+
+// listenerProfile is one listening address with its own MTU budget.
+type listenerProfile struct {
+	Addr      string
+	MTU       int      // 0 = use the -mtu default
+	Interface string   // set by -auto-mtu
+	Jumbo     bool     // @jumbo: 9000-byte budget for peers on a jumbo segment
+	Preset    string   // encapsulation preset(s), e.g. "pppoe+wireguard"
+	Link      string   // cellular/satellite link preset, e.g. "lte" (links.go)
+	Device    string   // dev=: interface or VRF the sockets are bound to
+	DSCP      *int     // dscp=: own mark instead of -dscp
+	Margin    *float64 // margin=: own -marginal percentage
+	Segments  int      // segments=: own -multi-segment
+
+	Schemes []kem.Scheme // alg=: KEMs this listener accepts (default Kyber768)
+	Impair  *impairment  // own impairments instead of the global flags
+}
+
+// budget is the payload budget handshakes on this listener are judged by.
+func (p listenerProfile) budget() int {
+	if p.MTU > 0 {
+		return p.MTU
+	}
+	return defaultBudget()
+}
+
+// budgetFor is the budget for one peer. Jumbo listeners only grant the jumbo
+// budget to peers on a directly connected jumbo-capable segment.
+func (p listenerProfile) budgetFor(peer string, lg *slog.Logger) int {
+	if !p.Jumbo {
+		return p.budget()
+	}
+	if seg, ok := jumboSegment(peer); ok {
+		host, _, _ := net.SplitHostPort(peer)
+		lg.Info("peer is on a jumbo segment", "segment", seg.Name, "mtu", seg.MTU)
+		return seg.MTU - ipHeaderSize(host) - TCP_HEADER_SIZE
+	}
+	budget := defaultBudget()
+	lg.Info("peer is outside the jumbo fabric", "budget", budget)
+	return budget
+}
+
+// impairment is the listener's own impairment, or the global flags' (as
+// the control plane may have changed them).
+func (p listenerProfile) impairment() impairment {
+	if p.Impair != nil {
+		return *p.Impair
+	}
+	return defaultImpairment()
+}
+
+// schemeFor picks the KEM for a client payload: the listener's only
+// algorithm or, with several, the largest whose public key fits the payload
+// (clients pad after the key).
+func (p listenerProfile) schemeFor(def kem.Scheme, payload int) kem.Scheme {
+	if len(p.Schemes) == 0 {
+		return def
+	}
+	best := p.Schemes[0]
+	for _, s := range p.Schemes[1:] {
+		size := s.PublicKeySize()
+		if size <= payload && (best.PublicKeySize() > payload || size > best.PublicKeySize()) {
+			best = s
+		}
+	}
+	return best
+}
+
+// dscp is the DSCP mark of the listener's sockets (0 = unmarked).
+func (p listenerProfile) dscp() int {
+	if p.DSCP != nil {
+		return *p.DSCP
+	}
+	return int(dscpDefault)
+}
+
+// minKeySize is the smallest public key the listener accepts.
+func (p listenerProfile) minKeySize(def kem.Scheme) int {
+	if len(p.Schemes) == 0 {
+		return def.PublicKeySize()
+	}
+	size := p.Schemes[0].PublicKeySize()
+	for _, s := range p.Schemes[1:] {
+		size = min(size, s.PublicKeySize())
+	}
+	return size
+}
+
+// algorithms names the listener's KEMs ("" for the default).
+func (p listenerProfile) algorithms() string {
+	names := make([]string, len(p.Schemes))
+	for i, s := range p.Schemes {
+		names[i] = s.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// options renders the per-listener options as given to -listen.
+func (p listenerProfile) options() string {
+	var opts []string
+	if algs := p.algorithms(); algs != "" {
+		opts = append(opts, "alg="+algs)
+	}
+	if p.Device != "" {
+		opts = append(opts, "dev="+p.Device)
+	}
+	if p.DSCP != nil {
+		opts = append(opts, "dscp="+strconv.Itoa(*p.DSCP))
+	}
+	if p.Margin != nil {
+		opts = append(opts, "margin="+strconv.FormatFloat(*p.Margin, 'g', -1, 64))
+	}
+	if p.Segments > 0 {
+		opts = append(opts, "segments="+strconv.Itoa(p.Segments))
+	}
+	if p.Impair != nil {
+		if imp := p.Impair.String(); imp != "" {
+			opts = append(opts, imp)
+		}
+	}
+	if len(opts) == 0 {
+		return ""
+	}
+	return "," + strings.Join(opts, ",")
+}
+
+// listenFlag collects repeated -listen addr[@mtu][,option...] flags.
+type listenFlag []listenerProfile
+
+func (f *listenFlag) String() string {
+	parts := make([]string, len(*f))
+	for i, p := range *f {
+		parts[i] = p.Addr
+		if p.Jumbo {
+			parts[i] += "@jumbo"
+		} else if p.Link != "" {
+			parts[i] += "@" + p.Link
+		} else if p.Preset != "" {
+			parts[i] += "@" + p.Preset
+		} else if p.MTU > 0 {
+			parts[i] += "@" + strconv.Itoa(p.MTU)
+		}
+		parts[i] += p.options()
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f *listenFlag) Set(value string) error {
+	spec, options, hasOptions := strings.Cut(value, ",")
+	addr, mtu, hasMTU := strings.Cut(spec, "@")
+	p := listenerProfile{Addr: addr}
+	if mtu == "jumbo" {
+		p.MTU = JUMBO_MTU - IPV4_HEADER_SIZE - TCP_HEADER_SIZE
+		p.Jumbo = true
+	} else if n, err := strconv.Atoi(mtu); hasMTU && err == nil {
+		if n < 68 {
+			return fmt.Errorf("invalid MTU %q in %q", mtu, value)
+		}
+		p.MTU = n
+	} else if _, ok := linkPresets[strings.ToLower(mtu)]; ok {
+		p.Link = strings.ToLower(mtu)
+	} else if hasMTU {
+		budget, err := presetBudget(mtu)
+		if err != nil {
+			return fmt.Errorf("invalid MTU %q in %q: %w", mtu, value, err)
+		}
+		p.MTU = budget
+		p.Preset = mtu
+	}
+	if hasOptions {
+		if err := p.setOptions(options); err != nil {
+			return fmt.Errorf("invalid listener %q: %w", value, err)
+		}
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+// setOptions parses a listener's comma-separated options.
+func (p *listenerProfile) setOptions(options string) error {
+	for _, opt := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+		if key == "alg" {
+			for _, name := range strings.Split(value, "+") {
+				s := schemes.ByName(name)
+				if s == nil {
+					return fmt.Errorf("unknown algorithm %q", name)
+				}
+				p.Schemes = append(p.Schemes, s)
+			}
+			continue
+		}
+		if key == "dev" {
+			if value == "" {
+				return errors.New("dev needs an interface name")
+			}
+			p.Device = value
+			continue
+		}
+		if key == "dscp" {
+			dscp, err := parseDSCP(value)
+			if err != nil {
+				return err
+			}
+			p.DSCP = &dscp
+			continue
+		}
+		if key == "margin" {
+			margin, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil || margin < 0 || margin >= 100 {
+				return fmt.Errorf("margin %q is not a percentage below 100", value)
+			}
+			p.Margin = &margin
+			continue
+		}
+		if key == "segments" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 2 {
+				return fmt.Errorf("segments %q is not a packet count of 2 or more", value)
+			}
+			p.Segments = n
+			continue
+		}
+
+		if p.Impair == nil {
+			p.Impair = &impairment{}
+		}
+		var err error
+		switch key {
+		case "delay":
+			p.Impair.Delay, err = time.ParseDuration(value)
+		case "jitter":
+			p.Impair.Jitter, err = time.ParseDuration(value)
+		case "bandwidth":
+			p.Impair.BandwidthKbps, err = strconv.Atoi(value)
+		case "blackhole":
+			p.Impair.Blackhole = true
+		case "fragloss":
+			p.Impair.FragLoss, err = parseFragLoss(value)
+		default:
+			return fmt.Errorf("unknown option %q (want alg, dev, dscp, margin, segments, delay, jitter, bandwidth, fragloss or blackhole)", key)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// presetBudget turns stacked encapsulation presets ("pppoe+wireguard") into
+// a TCP payload budget on a 1500-byte Ethernet link.
+func presetBudget(presets string) (int, error) {
+	mtu, inner := ETHERNET_MTU, IPV4_HEADER_SIZE
+	for _, name := range strings.Split(presets, "+") {
+		e, ok := encapsulationPresets[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown preset %q (want a number, jumbo, pppoe, gre, 6in4, wireguard or a link preset: %s)", name, linkPresetNames())
+		}
+		mtu -= e.Overhead
+		if e.InnerIPv6 {
+			inner = IPV6_HEADER_SIZE
+		}
+	}
+	return mtu - inner - TCP_HEADER_SIZE, nil
+}
+
+// configuredListeners returns the -listen profiles, or the default one.
+func configuredListeners() []listenerProfile {
+	listeners := []listenerProfile(listenAddrs)
+	if len(listeners) == 0 {
+		listeners = []listenerProfile{{Addr: PROXY_PORT}}
+	}
+	for i := range listeners {
+		link := listeners[i].Link
+		if link == "" && listeners[i].MTU == 0 {
+			link = *linkFlag
+		}
+		if link != "" {
+			if err := listeners[i].applyLink(link); err != nil {
+				fatal(err.Error())
+			}
+			logLink(listeners[i])
+		}
+	}
+	if *autoMTU {
+		applyInterfaceMTUs(listeners)
+	}
+	for _, p := range listeners {
+		if p.Jumbo {
+			checkJumboSupport(p)
+		}
+		if p.Preset != "" {
+			slog.Info("listener encapsulation", "listener", p.Addr, "preset", p.Preset, "budget", p.MTU)
+		}
+	}
+	return listeners
+}
+
+// listenConfig applies the listener's socket options (dev=, dscp=, -tfo,
+// -rcvbuf/-sndbuf).
+// Accepted connections inherit them from the listening socket.
+func (p listenerProfile) listenConfig() *net.ListenConfig {
+	return &net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		if p.Device != "" {
+			if err := bindToDevice(c, p.Device); err != nil {
+				return fmt.Errorf("bind to %s: %w", p.Device, err)
+			}
+		}
+		if dscp := p.dscp(); dscp > 0 {
+			if err := setDSCP(c, network, dscp); err != nil {
+				return fmt.Errorf("DSCP %d: %w", dscp, err)
+			}
+		}
+		if *rcvBuf > 0 || *sndBuf > 0 {
+			if err := setSocketBuffers(c, *rcvBuf, *sndBuf); err != nil {
+				return fmt.Errorf("socket buffers: %w", err)
+			}
+		}
+		if *tfoEnabled && strings.HasPrefix(network, "tcp") {
+			return enableFastOpen(network, address, c)
+		}
+		return nil
+	}}
+}
+
+// serveStream accepts TCP connections on one listener.
+func serveStream(profile listenerProfile, scheme kem.Scheme, sc scenario) {
+	listener, err := profile.listenConfig().Listen(context.Background(), "tcp", profile.Addr)
+	if err != nil {
+		fatal("cannot start proxy listener", "addr", profile.Addr, "err", err)
+	}
+	defer listener.Close()
+
+	slog.Info("ghost proxy listening", "addr", profile.Addr, "options", profile.options(), "mtu_budget", profile.budget())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			slog.Error("connection accept failed", "err", err)
+			continue
+		}
+		if *transcriptDir != "" {
+			label := *scenarioName
+			if *connectMode {
+				label = "connect"
+			}
+			conn = &transcriptConn{Conn: conn, t: newTranscript(conn.RemoteAddr().String(), label, "tcp")}
+		}
+		if *connectMode {
+			go handleTunnel(conn, profile)
+		} else {
+			go handleConnection(conn, scheme, sc, profile)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Sentinel-PQC Proxy - WebSocket Live Feed
+========================================
+With -api, GET /api/live upgrades to a WebSocket that pushes every report as
+one JSON text message the moment it is saved, so dashboards and CLIs can
+tail detections without polling ghost_report.json:
+
+  websocat ws://localhost:9090/api/live
+  websocat 'ws://localhost:9090/api/live?status=CRITICAL_RISK,SUSPECTED_BLACKHOLE'
+
+?status= limits the feed to some statuses. A client that cannot keep up
+misses reports rather than slowing the proxy down (see feed.go). Browsers
+may only connect from the dashboard's own origin.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const LIVE_WRITE_TIMEOUT = 10 * time.Second
+
+func serveLive(w http.ResponseWriter, r *http.Request) {
+	want := make(map[string]bool)
+	for _, st := range strings.Split(r.URL.Query().Get("status"), ",") {
+		if st = strings.ToUpper(strings.TrimSpace(st)); st != "" {
+			want[st] = true
+		}
+	}
+	websocket.Server{Handshake: liveOrigin, Handler: func(ws *websocket.Conn) { streamLive(ws, want) }}.ServeHTTP(w, r)
+}
+
+// liveOrigin turns away browsers on other sites: they may open WebSockets
+// anywhere, with the dashboard's cookies and basic auth. Tools like websocat
+// send no Origin, -top its own API URL.
+func liveOrigin(_ *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		slog.Warn("live feed refused a cross-origin client", "origin", origin, "client", r.RemoteAddr)
+		return fmt.Errorf("origin %q not allowed", origin)
+	}
+	return nil
+}
+
+// streamLive sends reports until the client goes away.
+func streamLive(ws *websocket.Conn, want map[string]bool) {
+	defer ws.Close()
+	remote := ws.Request().RemoteAddr
+	sub := subscribeReports()
+	defer unsubscribeReports(sub)
+	slog.Info("live feed client connected", "client", remote)
+	defer slog.Info("live feed client disconnected", "client", remote)
+
+	// Clients only send close frames; reading notices them
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(gone)
+	}()
+	for {
+		select {
+		case <-gone:
+			return
+		case report := <-sub.ch:
+			if n := sub.takeDropped(); n > 0 {
+				slog.Warn("live feed client too slow, reports skipped", "client", remote, "skipped", n)
+			}
+			if len(want) > 0 && !want[report.Status] {
+				continue
+			}
+			msg, err := json.Marshal(report)
+			if err != nil {
+				continue
+			}
+			ws.SetWriteDeadline(time.Now().Add(LIVE_WRITE_TIMEOUT))
+			if err := websocket.Message.Send(ws, string(msg)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Sentinel-PQC Proxy - Logging
+============================
+The proxy logs through log/slog. -log-format picks the output:
+
+  pretty   aligned, human-readable lines and the summary box (terminals)
+  text     logfmt key=value lines
+  json     one JSON object per line for log shippers
+  auto     pretty on a terminal, json otherwise (default)
+
+  go run . -log-format json -log-level debug
+
+Every line logged while handling a connection or datagram carries its
+correlation ID (conn=3f9a0c1e), which is also stored in the report as
+conn_id, so a report can be joined with the lines that led to it.
+-log-level debug adds the per-flight, per-path and per-probe detail.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	logLevel  = flag.String("log-level", "info", "Minimum log level (debug, info, warn, error)")
+	logFormat = flag.String("log-format", "auto", "Log output (pretty, text, json, or auto: pretty on a terminal, json otherwise)")
+)
+
+// setupLogging installs the default slog logger selected by the flags.
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return fmt.Errorf("-log-level: %w", err)
+	}
+	if *logFormat == "auto" {
+		*logFormat = "json"
+		if isTerminal(os.Stderr) {
+			*logFormat = "pretty"
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch *logFormat {
+	case "pretty":
+		h = newPrettyHandler(os.Stderr, opts)
+	case "text":
+		h = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown -log-format %q (pretty, text, json or auto)", *logFormat)
+	}
+	// errorTracker keeps the last errors for /status (status.go)
+	slog.SetDefault(slog.New(errorTracker{h}))
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// prettyLogs reports whether output is meant for a person at a terminal.
+func prettyLogs() bool { return *logFormat == "pretty" }
+
+// fatal logs at error level and exits, like log.Fatalf.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ============================================================================
+// CORRELATION IDS
+// ============================================================================
+
+// connLog is the logger of one connection or datagram exchange.
+type connLog struct {
+	*slog.Logger
+	id string
+}
+
+// newConnLog starts a logger whose lines all carry a fresh correlation ID.
+func newConnLog() connLog {
+	b := make([]byte, 4)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	return connLog{Logger: slog.Default().With("conn", id), id: id}
+}
+
+// logger returns the connection logger of the report, or the default one for
+// reports built outside a connection.
+func (r *GhostReport) logger() *slog.Logger {
+	if r.log.Logger == nil {
+		return slog.Default()
+	}
+	return r.log.Logger
+}
+
+// ============================================================================
+// PRETTY OUTPUT
+// ============================================================================
+
+// prettyHandler writes "15:04:05.000 WARN  [conn] message key=value" lines.
+type prettyHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	opts  slog.HandlerOptions
+	conn  string
+	attrs string // preformatted With() attributes
+	group string
+}
+
+func newPrettyHandler(w io.Writer, opts *slog.HandlerOptions) *prettyHandler {
+	return &prettyHandler{mu: &sync.Mutex{}, w: w, opts: *opts}
+}
+
+func (h *prettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+func (h *prettyHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	buf.WriteString(r.Time.Format("15:04:05.000"))
+	fmt.Fprintf(&buf, " %-5s ", r.Level)
+	if h.conn != "" {
+		buf.WriteString("[" + h.conn + "] ")
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+		buf.WriteString("❌ ")
+	case r.Level >= slog.LevelWarn:
+		buf.WriteString("⚠️  ")
+	}
+	buf.WriteString(r.Message)
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writePrettyAttr(&buf, h.group, a)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	var buf bytes.Buffer
+	for _, a := range attrs {
+		// The correlation ID goes up front instead of into the attributes
+		if a.Key == "conn" && h.group == "" {
+			h2.conn = a.Value.String()
+			continue
+		}
+		writePrettyAttr(&buf, h.group, a)
+	}
+	h2.attrs += buf.String()
+	return &h2
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+func writePrettyAttr(buf *bytes.Buffer, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writePrettyAttr(buf, group+a.Key+".", ga)
+		}
+		return
+	}
+	var v string
+	switch a.Value.Kind() {
+	case slog.KindDuration:
+		v = a.Value.Duration().String()
+	case slog.KindTime:
+		v = a.Value.Time().Format(time.RFC3339)
+	default:
+		v = a.Value.String()
+	}
+	if v == "" || strings.ContainsAny(v, " \"=") {
+		v = strconv.Quote(v)
+	}
+	fmt.Fprintf(buf, " %s%s=%s", group, a.Key, v)
+}
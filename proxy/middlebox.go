@@ -0,0 +1,224 @@
+/*
+Sentinel-PQC Proxy - Middlebox Interference Scenario
+====================================================
+During PQC rollouts, failures that look like fragmentation are often caused
+by middleboxes instead. The "middlebox" scenario pairs with the client's
+-middlebox, which sends a real TLS 1.3 ClientHello carrying a probe
+extension (0xfd5c):
+
+  variant(1) client advmss(2) offered TCP options(1) SHA-256 of the record(32)
+
+and the proxy checks three things separately from the size assessment:
+
+  TCP option stripping   options both ends offer but the SYNs did not agree
+                         on, or an MSS clamped below what both ends announced
+  TLS version intolerance the TLS 1.3 hello never arrived, only the client's
+                         same-size TLS 1.2 fallback did
+  Payload modification   the record hash does not match what arrived
+
+The answer echoes the hash of what arrived plus a known pattern, so the
+client can tell upstream from downstream modification.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	MIDDLEBOX_PROBE_EXTENSION = 0xfd5c
+	MIDDLEBOX_PROBE_SIZE      = 1 + 2 + 1 + sha256.Size
+	MIDDLEBOX_ECHO_SIZE       = 256
+	MIDDLEBOX_FALLBACK_WINDOW = 30 * time.Second // TLS 1.3 probe seen this recently -> not intolerance
+
+	PROBE_VARIANT_TLS13   = 1
+	PROBE_VARIANT_TLS12   = 2
+	PROBE_OPTIONS_UNKNOWN = 0xff // client could not read its own SYN options
+)
+
+// MiddleboxReport is what the probe found between client and proxy.
+type MiddleboxReport struct {
+	HelloVariant       string   `json:"hello_variant"`
+	VersionIntolerance bool     `json:"tls_version_intolerance"`
+	PayloadIntact      bool     `json:"payload_intact"`
+	OptionsOffered     []string `json:"tcp_options_offered,omitempty"`
+	OptionsNegotiated  []string `json:"tcp_options_negotiated,omitempty"`
+	OptionsStripped    []string `json:"tcp_options_stripped,omitempty"`
+	ClientAdvMSS       int      `json:"client_advmss,omitempty"`
+	NegotiatedMSS      int      `json:"negotiated_mss,omitempty"`
+	MSSClamped         bool     `json:"mss_clamped"`
+	Findings           []string `json:"findings,omitempty"`
+}
+
+var (
+	tls13ProbesMu sync.Mutex
+	tls13Probes   = make(map[string]time.Time) // client host -> last TLS 1.3 probe
+)
+
+// ============================================================================
+// SCENARIO
+// ============================================================================
+
+// readTLSHello reads until the first handshake message is complete and
+// returns the raw records, however the network segmented them.
+func readTLSHello(conn net.Conn, _ *slog.Logger) ([]byte, error) {
+	var stream []byte
+	buffer := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buffer)
+		stream = append(stream, buffer[:n]...)
+		if c, complete := firstHandshakeMessage(stream); complete {
+			if c.Err != nil {
+				return nil, c.Err
+			}
+			return stream[:c.WireSize], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(stream) > MAX_HELLO_BUFFER {
+			return nil, errors.New("no ClientHello within the hello buffer")
+		}
+	}
+}
+
+func respondMiddlebox(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error {
+	capture, _ := firstHandshakeMessage(clientData)
+	info := parseClientHello(capture.Message)
+	mb := &MiddleboxReport{HelloVariant: "unknown"}
+	report.Middlebox = mb
+	lg := report.logger()
+
+	host, _, _ := net.SplitHostPort(report.ClientIP)
+	var hash []byte
+	if len(info.Probe) < MIDDLEBOX_PROBE_SIZE {
+		mb.Findings = append(mb.Findings, "probe extension missing or truncated: the ClientHello was rewritten")
+	} else {
+		variant := info.Probe[0]
+		clientMSS := int(binary.BigEndian.Uint16(info.Probe[1:3]))
+		offered := info.Probe[3]
+		hash = info.Probe[4:MIDDLEBOX_PROBE_SIZE]
+
+		sum := sha256.Sum256(zeroHash(clientData, hash))
+		mb.PayloadIntact = bytes.Equal(sum[:], hash)
+		if !mb.PayloadIntact {
+			mb.Findings = append(mb.Findings, "ClientHello bytes changed in transit (record hash mismatch)")
+		}
+
+		checkVersionTolerance(mb, host, variant, info.SupportedVersions)
+		if offered != PROBE_OPTIONS_UNKNOWN {
+			checkTCPOptions(mb, conn, offered, clientMSS, lg)
+		}
+	}
+
+	// Hash of what arrived + a known pattern with its hash
+	pattern := make([]byte, MIDDLEBOX_ECHO_SIZE)
+	for i := range pattern {
+		pattern[i] = byte(i)
+	}
+	received := sha256.Sum256(zeroHash(clientData, hash))
+	patternHash := sha256.Sum256(pattern)
+	content := append(append(received[:], pattern...), patternHash[:]...)
+	record := []byte{TLS_RECORD_HANDSHAKE}
+	record = binary.BigEndian.AppendUint16(record, TLS_VERSION_12)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(content)))
+	record = append(record, content...)
+	if _, err := conn.Write(record); err != nil {
+		return fmt.Errorf("failed to send probe answer: %w", err)
+	}
+	report.ServerHelloSize = len(record)
+
+	for _, f := range mb.Findings {
+		lg.Warn("middlebox interference", "finding", f)
+	}
+	if len(mb.Findings) == 0 {
+		lg.Info("no middlebox interference", "hello_variant", mb.HelloVariant, "tcp_options", mb.OptionsNegotiated)
+		return nil
+	}
+	report.Status = "MIDDLEBOX_INTERFERENCE"
+	report.addNote("Middlebox interference detected; failures on this path are not (only) caused by fragmentation.")
+	return nil
+}
+
+// ============================================================================
+// CHECKS
+// ============================================================================
+
+// zeroHash returns a copy of the record with the probe hash zeroed, as the
+// client hashed it.
+func zeroHash(record, hash []byte) []byte {
+	out := append([]byte(nil), record...)
+	if len(hash) == 0 {
+		return out
+	}
+	if i := bytes.Index(out, hash); i >= 0 {
+		copy(out[i:i+len(hash)], make([]byte, len(hash)))
+	}
+	return out
+}
+
+// checkVersionTolerance tells a lost TLS 1.3 hello apart from a lost answer.
+func checkVersionTolerance(mb *MiddleboxReport, host string, variant byte, versions []uint16) {
+	tls13ProbesMu.Lock()
+	defer tls13ProbesMu.Unlock()
+
+	switch variant {
+	case PROBE_VARIANT_TLS13:
+		mb.HelloVariant = "tls13"
+		tls13Probes[host] = time.Now()
+		hasTLS13 := false
+		for _, v := range versions {
+			hasTLS13 = hasTLS13 || v == TLS_VERSION_13
+		}
+		if !hasTLS13 {
+			mb.Findings = append(mb.Findings, "TLS 1.3 removed from supported_versions in transit")
+		}
+	case PROBE_VARIANT_TLS12:
+		mb.HelloVariant = "tls12-fallback"
+		if seen, ok := tls13Probes[host]; ok && time.Since(seen) < MIDDLEBOX_FALLBACK_WINDOW {
+			mb.Findings = append(mb.Findings, "client fell back although its TLS 1.3 hello arrived: the answer was lost on the way back")
+			return
+		}
+		mb.VersionIntolerance = true
+		mb.Findings = append(mb.Findings, "TLS version intolerance: the TLS 1.3 hello was dropped, the same-size TLS 1.2 hello passed")
+	}
+}
+
+// checkTCPOptions compares what both ends offer with what the SYNs agreed.
+func checkTCPOptions(mb *MiddleboxReport, conn net.Conn, offered byte, clientMSS int, lg *slog.Logger) {
+	opts, err := connTCPOptions(conn)
+	if err != nil {
+		lg.Info("TCP option check skipped", "err", err)
+		return
+	}
+	mb.OptionsOffered = tcpOptionNames(offered)
+	mb.OptionsNegotiated = tcpOptionNames(opts.Negotiated)
+	if stripped := offered & opts.Local &^ opts.Negotiated; stripped != 0 {
+		mb.OptionsStripped = tcpOptionNames(stripped)
+		mb.Findings = append(mb.Findings, fmt.Sprintf("TCP options stripped from the SYNs: %v", mb.OptionsStripped))
+	}
+
+	// The kernel also caps snd_mss at half the peer's window, which only
+	// bites above Ethernet sizes (loopback), where no middlebox clamps.
+	mss := opts.SendMSS
+	mb.ClientAdvMSS, mb.NegotiatedMSS = clientMSS, mss
+	if clientMSS > 0 && mss < min(clientMSS, opts.AdvMSS) && mss <= ETHERNET_MTU {
+		mb.MSSClamped = true
+		mb.Findings = append(mb.Findings, fmt.Sprintf("MSS clamped to %d (client announced %d, proxy %d)", mss, clientMSS, opts.AdvMSS))
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Sentinel-PQC Proxy - MQTT-over-TLS Device Scenario
+==================================================
+IoT devices talk MQTT over TLS on links far tighter than Ethernet: NB-IoT and
+LTE-M carriers commonly run 1280-1358 byte MTUs, and 6LoWPAN splits every
+IPv6 packet into ~100-byte radio frames. On these links the cost of PQC is
+airtime (and battery), not just fragmentation.
+
+Flow:
+  1. TLS ClientHello with the Kyber-768 key share   <-- measured
+  2. Kyber-768 ciphertext back (as in the default scenario)
+  3. MQTT CONNECT -> CONNACK over the established session
+
+For each constrained link the report lists the TCP segments and radio frames
+the key-exchange flights need and the airtime they cost compared with a
+classical X25519 key share (32 bytes each way).
+
+Link figures are ballpark uplink/downlink rates and per-frame scheduling
+costs, good enough to compare algorithms, not to plan a radio network.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	MQTT_CONNECT = 0x10
+	MQTT_CONNACK = 0x20
+
+	X25519_SHARE_SIZE = 32 // Classical baseline key share
+)
+
+// constrainedLink models the radio/transport costs of a device uplink.
+type constrainedLink struct {
+	Name         string
+	MTU          int     // IP MTU
+	FramePayload int     // Bytes per radio frame / transport block
+	UplinkKbps   float64 // Effective uplink throughput
+	DownlinkKbps float64 // Effective downlink throughput
+	PerFrameMs   float64 // Scheduling / MAC cost per frame
+}
+
+var deviceLinks = []constrainedLink{
+	{"NB-IoT", 1280, 125, 20, 25, 10},
+	{"LTE-M (Cat-M1)", 1358, 125, 375, 300, 3},
+	{"6LoWPAN (802.15.4)", 1280, 96, 250, 250, 4},
+}
+
+// ============================================================================
+// SERVER FLIGHT
+// ============================================================================
+
+// respondMQTT completes the key exchange, prices it on constrained links and
+// then accepts the device's MQTT CONNECT.
+func respondMQTT(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error {
+	if err := completeKeyExchange(conn, scheme, clientData, report); err != nil {
+		return err
+	}
+
+	clientFlight, serverFlight := len(clientData), scheme.CiphertextSize()
+	classicalClient := clientFlight - scheme.PublicKeySize() + X25519_SHARE_SIZE
+	report.LinkCosts = priceLinks(clientFlight, serverFlight, classicalClient, X25519_SHARE_SIZE)
+	lg := report.logger()
+	for _, c := range report.LinkCosts {
+		lg.Debug("device link cost", "link", c.Link, "mtu", c.MTU, "segments", c.Segments,
+			"frames", c.Frames, "airtime_ms", c.AirtimeMs, "overhead_ms", c.OverheadMs)
+	}
+	worst := report.LinkCosts[0]
+	report.addNote(fmt.Sprintf("On %s the PQC key exchange costs %.0f ms extra airtime.", worst.Link, worst.OverheadMs))
+
+	// MQTT CONNECT over the (simulated) TLS session
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	packet := make([]byte, 512)
+	n, err := conn.Read(packet)
+	if err != nil || n == 0 || packet[0]&0xf0 != MQTT_CONNECT {
+		lg.Info("no MQTT CONNECT received after key exchange")
+		return nil
+	}
+	if _, err := conn.Write([]byte{MQTT_CONNACK, 0x02, 0x00, 0x00}); err != nil {
+		return fmt.Errorf("failed to send CONNACK: %w", err)
+	}
+	lg.Info("MQTT CONNECT accepted, CONNACK sent", "bytes", n)
+	return nil
+}
+
+// ============================================================================
+// LINK PRICING
+// ============================================================================
+
+// priceLinks computes segments, frames and airtime of the PQC flights on each
+// device link, with the overhead relative to the classical flights.
+func priceLinks(clientFlight, serverFlight, classicalClient, classicalServer int) []LinkCost {
+	costs := make([]LinkCost, 0, len(deviceLinks))
+	for _, l := range deviceLinks {
+		pq := l.airtime(clientFlight, l.UplinkKbps) + l.airtime(serverFlight, l.DownlinkKbps)
+		classical := l.airtime(classicalClient, l.UplinkKbps) + l.airtime(classicalServer, l.DownlinkKbps)
+
+		costs = append(costs, LinkCost{
+			Link:       l.Name,
+			MTU:        l.MTU,
+			Segments:   l.segments(clientFlight),
+			Frames:     l.frames(clientFlight) + l.frames(serverFlight),
+			AirtimeMs:  pq,
+			OverheadMs: pq - classical,
+		})
+	}
+	return costs
+}
+
+// segments counts TCP segments over IPv6 (40) + TCP (20) headers.
+func (l constrainedLink) segments(size int) int {
+	mss := l.MTU - 60
+	return (size + mss - 1) / mss
+}
+
+// frames counts radio frames for the segments, headers included.
+func (l constrainedLink) frames(size int) int {
+	onAir := size + l.segments(size)*60
+	return (onAir + l.FramePayload - 1) / l.FramePayload
+}
+
+func (l constrainedLink) airtime(size int, kbps float64) float64 {
+	onAir := size + l.segments(size)*60
+	return float64(onAir*8)/kbps + float64(l.frames(size))*l.PerFrameMs
+}
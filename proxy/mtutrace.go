@@ -0,0 +1,204 @@
+/*
+Sentinel-PQC Proxy - Per-Hop MTU Trace
+======================================
+Path MTU discovery says how small the path is; mtutrace says where it gets
+small. Like tracepath, it sends DF-flagged UDP probes with increasing TTLs
+and finds, per hop, the largest probe that still reaches it:
+
+  go run . mtutrace 192.0.2.10
+  go run . mtutrace -hops 20 example.com
+
+The first hop whose largest probe is smaller than the hop before it sits
+behind the bottleneck; the router in front of it is the limiting hop. With
+-pmtud, fragmentation reports carry that hop whenever the measured path MTU
+is below the local route MTU.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	MTUTRACE_MAX_HOPS     = 30
+	MTUTRACE_MAX_SILENT   = 3 // Consecutive silent hops before giving up
+	MTUTRACE_LOCAL_ROUTER = "local"
+	MTUTRACE_RETRY_WAIT   = time.Second // Routers refill ICMP rate limits about once a second
+)
+
+// MTUHop is one hop of an MTU trace.
+type MTUHop struct {
+	TTL    int    `json:"ttl"`
+	Router string `json:"router"`       // "*" if the hop never answered
+	MTU    int    `json:"max_probe"`    // largest probe that reached this hop
+	PTB    int    `json:"ptb_mtu"`      // next-hop MTU the hop announced, if any
+	Limits bool   `json:"limiting_hop"` // the link behind this hop is the bottleneck
+}
+
+// MTUTrace is the result of tracing the MTU towards one destination.
+type MTUTrace struct {
+	Destination string   `json:"destination"`
+	RouteMTU    int      `json:"route_mtu"`
+	PathMTU     int      `json:"path_mtu"`
+	Reached     bool     `json:"reached"`
+	Hops        []MTUHop `json:"hops"`
+	LimitingHop *MTUHop  `json:"limiting_hop,omitempty"`
+}
+
+// probeResult is what a single TTL-limited probe ran into.
+type probeResult struct {
+	Router      string // who answered
+	Reached     bool   // the probe got as far as the TTL allowed
+	Destination bool   // ... and that was the destination itself
+	PTB         int    // Fragmentation Needed / Packet Too Big MTU
+}
+
+// traceMTU walks the hops towards host. probe sends one DF probe of size
+// bytes with the given TTL; ok is false when nothing came back.
+//
+// Routers rate-limit ICMP, so each hop is first tried at the MTU the previous
+// hop allowed and at any Packet Too Big value it triggers; the binary search
+// only runs when the path stays silent, and a silent probe is retried once.
+func traceMTU(host string, minMTU, routeMTU, maxHops int, sendProbe func(size, ttl int) (probeResult, bool)) MTUTrace {
+	probe := func(size, ttl int) (probeResult, bool) {
+		if r, ok := sendProbe(size, ttl); ok {
+			return r, ok
+		}
+		time.Sleep(MTUTRACE_RETRY_WAIT)
+		return sendProbe(size, ttl)
+	}
+
+	trace := MTUTrace{Destination: host, RouteMTU: routeMTU, PathMTU: routeMTU}
+	ceiling, silent := routeMTU, 0
+	ptbRouter := ""
+
+	for ttl := 1; ttl <= maxHops && silent < MTUTRACE_MAX_SILENT; ttl++ {
+		hop := MTUHop{TTL: ttl, Router: "*"}
+		var reached probeResult
+
+		size := ceiling
+		for {
+			r, ok := probe(size, ttl)
+			if ok && r.Reached {
+				reached, hop.MTU = r, size
+				break
+			}
+			if ok && r.PTB > 0 && r.PTB < size {
+				ptbRouter = r.Router
+				size = r.PTB
+				continue
+			}
+
+			// Silent: is the hop there at all?
+			first, ok := probe(minMTU, ttl)
+			if !ok || !first.Reached {
+				break
+			}
+			lo, hi := minMTU, size
+			for hi-lo > 1 {
+				mid := (lo + hi) / 2
+				if r, ok := probe(mid, ttl); ok && r.Reached {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			reached, hop.MTU = first, lo
+			break
+		}
+
+		if hop.MTU == 0 {
+			silent++
+			trace.Hops = append(trace.Hops, hop)
+			log.Printf("[MTUTRACE] %2d  %-40s", ttl, "*")
+			continue
+		}
+		silent = 0
+		hop.Router = reached.Router
+		trace.Hops = append(trace.Hops, hop)
+
+		// A drop means the link in front of this hop is narrower; the
+		// router that sent Packet Too Big names it, else the hop before
+		if hop.MTU < ceiling && trace.LimitingHop == nil {
+			limiting := MTUHop{TTL: 0, Router: MTUTRACE_LOCAL_ROUTER, MTU: ceiling}
+			for i := len(trace.Hops) - 2; i >= 0; i-- {
+				if trace.Hops[i].Router == ptbRouter || ptbRouter == "" {
+					trace.Hops[i].Limits = true
+					trace.Hops[i].PTB = hop.MTU
+					limiting = trace.Hops[i]
+					break
+				}
+			}
+			limiting.PTB = hop.MTU
+			trace.LimitingHop = &limiting
+		}
+		ceiling = hop.MTU
+
+		log.Printf("[MTUTRACE] %2d  %-40s max probe %5d bytes", ttl, hop.Router, hop.MTU)
+		if reached.Destination {
+			trace.Reached = true
+			break
+		}
+	}
+	trace.PathMTU = ceiling
+	return trace
+}
+
+// ============================================================================
+// SUBCOMMAND
+// ============================================================================
+
+// runMTUTrace implements "mtutrace [-hops N] <target>".
+func runMTUTrace(args []string) {
+	fs := flag.NewFlagSet("mtutrace", flag.ExitOnError)
+	hops := fs.Int("hops", MTUTRACE_MAX_HOPS, "Maximum number of hops to probe")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: mtutrace [-hops N] <host>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	addr, err := net.ResolveIPAddr("ip", fs.Arg(0))
+	if err != nil {
+		log.Fatalf("[MTUTRACE] Cannot resolve %s: %v", fs.Arg(0), err)
+	}
+	log.Printf("[MTUTRACE] Tracing MTU to %s (%s), max %d hops", fs.Arg(0), addr.IP, *hops)
+
+	trace, err := probeMTUTrace(addr.IP.String(), *hops)
+	if err != nil {
+		log.Fatalf("[MTUTRACE] %v", err)
+	}
+	logMTUTrace(trace)
+}
+
+func logMTUTrace(t MTUTrace) {
+	reached := "yes"
+	if !t.Reached {
+		reached = "no"
+	}
+	limiting := "none (path MTU = route MTU)"
+	if t.LimitingHop != nil {
+		limiting = fmt.Sprintf("hop %d %s -> %d", t.LimitingHop.TTL, t.LimitingHop.Router, t.LimitingHop.PTB)
+	}
+
+	log.Println()
+	log.Println("┌─────────────────────────────────────────────┐")
+	log.Println("│               MTU TRACE SUMMARY             │")
+	log.Println("├─────────────────────────────────────────────┤")
+	log.Printf("│ Destination:    %-27s │\n", t.Destination)
+	log.Printf("│ Reached:        %-27s │\n", reached)
+	log.Printf("│ Route MTU:      %-27s │\n", fmt.Sprintf("%d bytes", t.RouteMTU))
+	log.Printf("│ Path MTU:       %-27s │\n", fmt.Sprintf("%d bytes", t.PathMTU))
+	log.Printf("│ Limiting Hop:   %-27s │\n", limiting)
+	log.Println("└─────────────────────────────────────────────┘")
+}
@@ -0,0 +1,153 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// probeMTUTrace traces the MTU towards host with TTL-limited DF probes.
+// ICMP errors are read from the socket's error queue (IP_RECVERR), so no
+// raw socket is needed.
+func probeMTUTrace(host string, maxHops int) (MTUTrace, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return MTUTrace{}, fmt.Errorf("not an IP address: %q", host)
+	}
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: PMTUD_PROBE_PORT})
+	if err != nil {
+		return MTUTrace{}, err
+	}
+	defer conn.Close()
+
+	v6 := ip.To4() == nil
+	level, discover, probeMode, mtuOpt, recvErr, ttlOpt := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE, unix.IP_MTU, unix.IP_RECVERR, unix.IP_TTL
+	minMTU := PMTUD_MIN_IPV4
+	if v6 {
+		level, discover, probeMode, mtuOpt, recvErr, ttlOpt = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE, unix.IPV6_MTU, unix.IPV6_RECVERR, unix.IPV6_UNICAST_HOPS
+		minMTU = PMTUD_MIN_IPV6
+	}
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return MTUTrace{}, err
+	}
+	var routeMTU int
+	var sockErr error
+	raw.Control(func(fd uintptr) {
+		if sockErr = unix.SetsockoptInt(int(fd), level, discover, probeMode); sockErr != nil {
+			return
+		}
+		if sockErr = unix.SetsockoptInt(int(fd), level, recvErr, 1); sockErr != nil {
+			return
+		}
+		routeMTU, sockErr = unix.GetsockoptInt(int(fd), level, mtuOpt)
+	})
+	if sockErr != nil {
+		return MTUTrace{}, fmt.Errorf("socket options: %w", sockErr)
+	}
+	// The route MTU may already hold a PMTU learnt from earlier probes;
+	// start from the outgoing link so the drop is seen again
+	if mtu := linkMTU(conn.LocalAddr().(*net.UDPAddr).IP); mtu > routeMTU {
+		routeMTU = mtu
+	}
+	routeMTU = min(routeMTU, 65535) // largest IP packet (loopback runs 65536)
+
+	headers := ipHeaderSize(host) + UDP_HEADER_SIZE
+	probe := func(size, ttl int) (probeResult, bool) {
+		raw.Control(func(fd uintptr) {
+			unix.SetsockoptInt(int(fd), level, ttlOpt, ttl)
+			for {
+				if _, ok := readErrQueue(int(fd), ip); !ok {
+					break // drain answers to earlier probes
+				}
+			}
+		})
+		if _, err := conn.Write(make([]byte, size-headers)); err != nil && !errors.Is(err, syscall.ECONNREFUSED) {
+			return probeResult{Router: MTUTRACE_LOCAL_ROUTER}, false // EMSGSIZE: larger than the local interface
+		}
+
+		deadline := time.Now().Add(PMTUD_PROBE_TIMEOUT)
+		for time.Now().Before(deadline) {
+			var r probeResult
+			var ok bool
+			raw.Control(func(fd uintptr) { r, ok = readErrQueue(int(fd), ip) })
+			if ok {
+				return r, true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return probeResult{}, false
+	}
+
+	return traceMTU(host, minMTU, routeMTU, maxHops, probe), nil
+}
+
+// readErrQueue reads one queued ICMP error (non-blocking) and classifies it.
+func readErrQueue(fd int, dst net.IP) (probeResult, bool) {
+	buf := make([]byte, 64)
+	oob := make([]byte, 512)
+	_, oobn, _, _, err := unix.Recvmsg(fd, buf, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+	if err != nil {
+		return probeResult{}, false
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return probeResult{}, false
+	}
+	for _, m := range msgs {
+		if !(m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_RECVERR) &&
+			!(m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_RECVERR) {
+			continue
+		}
+		if len(m.Data) < 16 {
+			continue
+		}
+		origin, typ, code := m.Data[4], m.Data[5], m.Data[6]
+		info := int(binary.NativeEndian.Uint32(m.Data[8:12]))
+		router := offender(m.Data[16:])
+		if router == "" {
+			router = dst.String()
+		}
+
+		switch {
+		case origin == unix.SO_EE_ORIGIN_LOCAL:
+			return probeResult{Router: MTUTRACE_LOCAL_ROUTER, PTB: info}, true
+		case origin == unix.SO_EE_ORIGIN_ICMP && typ == 11, origin == unix.SO_EE_ORIGIN_ICMP6 && typ == 3:
+			return probeResult{Router: router, Reached: true}, true // time exceeded
+		case origin == unix.SO_EE_ORIGIN_ICMP && typ == 3 && code == 3, origin == unix.SO_EE_ORIGIN_ICMP6 && typ == 1 && code == 4:
+			return probeResult{Router: router, Reached: true, Destination: true}, true // port unreachable
+		case origin == unix.SO_EE_ORIGIN_ICMP && typ == ICMP_FRAG_NEEDED_TYPE && code == ICMP_FRAG_NEEDED_CODE,
+			origin == unix.SO_EE_ORIGIN_ICMP6 && typ == ICMPV6_PACKET_TOO_BIG:
+			return probeResult{Router: router, PTB: info}, true
+		default:
+			return probeResult{Router: router}, true
+		}
+	}
+	return probeResult{}, false
+}
+
+// offender decodes the sockaddr following sock_extended_err.
+func offender(sa []byte) string {
+	if len(sa) < 2 {
+		return ""
+	}
+	switch binary.NativeEndian.Uint16(sa[0:2]) {
+	case unix.AF_INET:
+		if len(sa) >= 8 {
+			return net.IP(sa[4:8]).String()
+		}
+	case unix.AF_INET6:
+		if len(sa) >= 24 {
+			return net.IP(sa[8:24]).String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func probeMTUTrace(host string, maxHops int) (MTUTrace, error) {
+	return MTUTrace{}, errors.New("MTU tracing is only supported on Linux")
+}
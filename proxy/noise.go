@@ -0,0 +1,123 @@
+/*
+Sentinel-PQC Proxy - Noise IK / PQ-WireGuard Scenario
+=====================================================
+WireGuard's Noise IK handshake fits its 148-byte initiation in any datagram.
+Post-quantum WireGuard proposals add ML-KEM to the same two-message pattern:
+the initiator sends an ephemeral KEM public key plus a ciphertext
+encapsulated to the responder's static KEM key, and the response carries a
+ciphertext encapsulated to that ephemeral key.
+
+Handshake Initiation (type 1)              Handshake Response (type 2)
+  header + sender index        8             header + indices          12
+  ephemeral X25519            32             ephemeral X25519          32
+  encrypted static (AEAD)     48             ML-KEM ct (ephemeral)   1088
+  ML-KEM ephemeral pk       1184             encrypted nothing (AEAD)  16
+  ML-KEM ct (static)        1088             mac1 + mac2               32
+  encrypted timestamp (AEAD)  28                                     ----
+  mac1 + mac2                 32                                     1180
+                            ----
+                            2420
+
+WireGuard never relies on IP fragmentation, so the question is whether the
+initiation fits one UDP datagram under the VPN's underlay MTU.
+
+The responder's static KEM key is derived from a fixed seed that both client
+and proxy know, standing in for the out-of-band peer configuration. AEAD
+fields are random bytes of the correct length; only the sizes matter.
+*/
+
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	NOISE_MSG_INITIATION = 1
+	NOISE_MSG_RESPONSE   = 2
+
+	// Seed for the responder's static KEM key pair (peer config stand-in)
+	NOISE_STATIC_SEED = "sentinel-pqc noise responder static"
+)
+
+// noiseStaticKey derives the responder's static KEM key pair.
+func noiseStaticKey(scheme kem.Scheme) (kem.PublicKey, kem.PrivateKey) {
+	seed := sha512.Sum512([]byte(NOISE_STATIC_SEED))
+	return scheme.DeriveKeyPair(seed[:scheme.SeedSize()])
+}
+
+// ============================================================================
+// RESPONDER
+// ============================================================================
+
+// respondNoise validates a PQ handshake initiation and sends the response.
+func respondNoise(pc net.PacketConn, addr net.Addr, scheme kem.Scheme, datagram []byte, report *GhostReport) error {
+	pkSize, ctSize := scheme.PublicKeySize(), scheme.CiphertextSize()
+	want := 8 + 32 + 48 + pkSize + ctSize + 28 + 32
+	if len(datagram) != want || datagram[0] != NOISE_MSG_INITIATION {
+		return fmt.Errorf("not a PQ handshake initiation (%d bytes, want %d)", len(datagram), want)
+	}
+	senderIndex := binary.LittleEndian.Uint32(datagram[4:8])
+
+	pos := 8 + 32 + 48
+	ephemeralPub := datagram[pos : pos+pkSize]
+	staticCt := datagram[pos+pkSize : pos+pkSize+ctSize]
+
+	// Decapsulate the initiator's ciphertext with our static key
+	_, staticSk := noiseStaticKey(scheme)
+	if _, err := scheme.Decapsulate(staticSk, staticCt); err != nil {
+		return fmt.Errorf("static decapsulation failed: %w", err)
+	}
+
+	// Encapsulate to the initiator's ephemeral KEM key
+	epk, err := scheme.UnmarshalBinaryPublicKey(ephemeralPub)
+	if err != nil {
+		return fmt.Errorf("invalid ephemeral KEM key: %w", err)
+	}
+	ct, _, err := scheme.Encapsulate(epk)
+	if err != nil {
+		return fmt.Errorf("encapsulation failed: %w", err)
+	}
+	report.logger().Info("Noise initiation: static ct decapsulated, ephemeral encapsulated", "sender", senderIndex)
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	response := []byte{NOISE_MSG_RESPONSE, 0, 0, 0}
+	response = binary.LittleEndian.AppendUint32(response, senderIndex^0x5e17)
+	response = binary.LittleEndian.AppendUint32(response, senderIndex)
+	response = append(response, ephemeral.PublicKey().Bytes()...)
+	response = append(response, ct...)
+	tail := make([]byte, 16+32) // encrypted nothing + mac1 + mac2
+	rand.Read(tail)
+	response = append(response, tail...)
+
+	if _, err := pc.WriteTo(response, addr); err != nil {
+		return fmt.Errorf("failed to send handshake response: %w", err)
+	}
+	report.logger().Info("sent Noise handshake response", "bytes", len(response))
+
+	report.Algorithm = "Noise_IK+" + scheme.Name()
+	report.PathFits = fitPaths(len(datagram), vpnPaths)
+	logPathFits(report.PathFits, report.logger())
+	for _, f := range report.PathFits {
+		if !f.Fits {
+			report.addNote(fmt.Sprintf("Initiation exceeds one datagram on %s (%d fragments).", f.Path, f.Fragments))
+			break
+		}
+	}
+	return nil
+}
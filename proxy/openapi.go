@@ -0,0 +1,466 @@
+/*
+Sentinel-PQC Proxy - OpenAPI Document
+=====================================
+The HTTP API is described by an OpenAPI 3 document built into the binary,
+so integrators can generate typed clients instead of reading JSON by hand:
+
+  curl -s localhost:9090/api/openapi.json > sentinel.json
+  go run . openapi -o sentinel.json -server https://probe-host:9090
+  npx @openapitools/openapi-generator-cli generate -i sentinel.json -g python -o client/
+
+/api/openapi.json needs no credentials: it describes the API, not data.
+The schemas are generated from the Go types the handlers encode and
+decode, so a field added to GhostReport shows up without editing anything
+here; the operations, their parameters and roles are listed in
+API_OPERATIONS, which has to follow the routes registered in startAPI.
+x-required-role names the role (auth.go) an operation needs once the API
+asks for credentials; the token scheme is ?token=, for WebSocket and
+EventSource clients that cannot set headers.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const OPENAPI_PATH = "/api/openapi.json"
+
+// apiOperation is one route of the API as the document describes it.
+type apiOperation struct {
+	Method, Path string
+	Tag          string
+	Summary      string
+	Scope        authScope
+	Query        []apiParam
+	Body         any    // a value of the JSON request body's type
+	Response     any    // a value of the JSON response's type; nil for none
+	Status       int    // of a success; 200 when zero
+	Media        string // a response that is not JSON
+}
+
+// apiParam is a query parameter.
+type apiParam struct {
+	Name, Type, Description string
+}
+
+var (
+	REPORT_FILTER_PARAMS = []apiParam{
+		{"client", "string", "Client IP address"},
+		{"subnet", "string", "Client CIDR prefix, e.g. 10.0.0.0/24"},
+		{"status", "string", "Report status, e.g. CRITICAL_RISK"},
+		{"algorithm", "string", "Negotiated algorithm, e.g. Kyber768"},
+		{"probe", "string", "Probe that sent the report (collector)"},
+		{"site", "string", "Site of that probe (collector)"},
+	}
+	REPORT_WINDOW_PARAMS = []apiParam{
+		{"since", "duration", "Only the last D, e.g. 24h; ignored when from is set"},
+		{"from", "date-time", "Inclusive start, RFC 3339"},
+		{"to", "date-time", "Exclusive end, RFC 3339"},
+	}
+	REPORT_PAGE_PARAMS = []apiParam{
+		{"limit", "integer", "Reports per page"},
+		{"offset", "integer", "Reports to skip"},
+	}
+	REPORT_QUERY_PARAMS = concatParams(REPORT_FILTER_PARAMS, REPORT_WINDOW_PARAMS, REPORT_PAGE_PARAMS)
+)
+
+// API_OPERATIONS lists the routes of startAPI in the order of the document.
+var API_OPERATIONS = []apiOperation{
+	{Method: "GET", Path: "/api/stats", Tag: "reports", Summary: "Rolling handshake statistics", Scope: scopeRead, Response: StatsSummary{}},
+	{Method: "GET", Path: "/api/reports", Tag: "reports", Summary: "Stored reports, newest first", Scope: scopeRead, Query: REPORT_QUERY_PARAMS, Response: reportPage{}},
+	{Method: "GET", Path: "/api/live", Tag: "reports", Summary: "WebSocket feed of new reports, one JSON report per message", Scope: scopeRead,
+		Query: []apiParam{{"status", "string", "Only these statuses, comma-separated"}}, Status: http.StatusSwitchingProtocols},
+	{Method: "GET", Path: "/api/events", Tag: "reports", Summary: "Server-sent events: report, status and dropped", Scope: scopeRead,
+		Query: []apiParam{{"events", "string", "Event types, comma-separated (report, status)"}, {"status", "string", "Only reports with these statuses, comma-separated"}}, Media: "text/event-stream"},
+	{Method: "GET", Path: "/api/clients", Tag: "analysis", Summary: "Clients ranked by risk score", Scope: scopeRead,
+		Query: []apiParam{{"limit", "integer", "Clients listed"}}, Response: []ClientRisk{}},
+	{Method: "GET", Path: "/api/clients/{ip}", Tag: "analysis", Summary: "One client's risk and trend", Scope: scopeRead, Response: ClientRisk{}},
+	{Method: "GET", Path: "/api/algorithms", Tag: "analysis", Summary: "Detections, sizes and latency per algorithm and family", Scope: scopeRead, Query: REPORT_QUERY_PARAMS, Response: AlgorithmsResponse{}},
+	{Method: "GET", Path: "/api/subnets", Tag: "analysis", Summary: "Client networks ranked by risk", Scope: scopeRead,
+		Query: concatParams(REPORT_QUERY_PARAMS, []apiParam{
+			{"ipv4", "integer", "IPv4 prefix length, 8 to 32"},
+			{"ipv6", "integer", "IPv6 prefix length, 16 to 128"},
+			{"top", "integer", "Networks listed"},
+			{"min_reports", "integer", "Leave out networks with fewer reports"},
+		}), Response: SubnetsResponse{}},
+	{Method: "GET", Path: "/api/compare", Tag: "analysis", Summary: "Two time windows and their deltas", Scope: scopeRead,
+		Query: concatParams(REPORT_FILTER_PARAMS, []apiParam{
+			{"at", "date-time", "The change; window before and after it"},
+			{"window", "duration", "Length of each window, default 24h"},
+			{"annotation", "string", "Compare around this annotation"},
+			{"before_from", "date-time", "Start of the first window"},
+			{"before_to", "date-time", "End of the first window"},
+			{"after_from", "date-time", "Start of the second window"},
+			{"after_to", "date-time", "End of the second window"},
+		}), Response: CompareResponse{}},
+	{Method: "GET", Path: "/api/export/sarif", Tag: "export", Summary: "Compliance findings as SARIF 2.1.0", Scope: scopeRead, Query: REPORT_QUERY_PARAMS, Response: sarifLog{}},
+	{Method: "GET", Path: "/api/export/assessment", Tag: "export", Summary: "Readiness assessment document", Scope: scopeRead,
+		Query: concatParams([]apiParam{{"format", "string", "html (default) or pdf"}}, REPORT_QUERY_PARAMS), Media: "text/html"},
+	{Method: "GET", Path: "/api/config", Tag: "admin", Summary: "Running configuration", Scope: scopeRead, Response: configDocument{}},
+	{Method: "GET", Path: "/status", Tag: "admin", Summary: "Health; 503 while a sink is failing", Scope: scopeRead, Response: statusResponse{}},
+
+	{Method: "GET", Path: "/api/alerts/rules", Tag: "alerts", Summary: "Alert rules", Scope: scopeRead, Response: []AlertRule{}},
+	{Method: "POST", Path: "/api/alerts/rules", Tag: "alerts", Summary: "Add an alert rule", Scope: scopeAdmin, Body: AlertRule{}, Response: AlertRule{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/alerts/rules/{id}", Tag: "alerts", Summary: "One alert rule", Scope: scopeRead, Response: AlertRule{}},
+	{Method: "PUT", Path: "/api/alerts/rules/{id}", Tag: "alerts", Summary: "Replace an alert rule", Scope: scopeAdmin, Body: AlertRule{}, Response: AlertRule{}},
+	{Method: "DELETE", Path: "/api/alerts/rules/{id}", Tag: "alerts", Summary: "Remove an alert rule", Scope: scopeAdmin, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/alerts/silences", Tag: "alerts", Summary: "Silences in force", Scope: scopeRead, Response: struct {
+		Silences []Silence `json:"silences"`
+	}{}},
+	{Method: "POST", Path: "/api/alerts/silences", Tag: "alerts", Summary: "Silence a rule, a network or both", Scope: scopeOperator, Body: Silence{}, Response: Silence{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/api/alerts/silences/{id}", Tag: "alerts", Summary: "Replace a silence", Scope: scopeOperator, Body: Silence{}, Response: Silence{}},
+	{Method: "DELETE", Path: "/api/alerts/silences/{id}", Tag: "alerts", Summary: "Lift a silence", Scope: scopeOperator, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/alerts/fired", Tag: "alerts", Summary: "Latest fired alerts, newest first", Scope: scopeRead,
+		Query: []apiParam{{"limit", "integer", "Alerts listed"}}, Response: struct {
+			Alerts []FiredAlert `json:"alerts"`
+		}{}},
+	{Method: "POST", Path: "/api/alerts/fired/{id}/ack", Tag: "alerts", Summary: "Acknowledge an alert: silence its rule for its client's network", Scope: scopeOperator, Body: Silence{}, Response: Silence{}, Status: http.StatusCreated},
+
+	{Method: "GET", Path: "/api/control", Tag: "control", Summary: "Runtime settings in effect", Scope: scopeRead, Response: ControlState{}},
+	{Method: "PATCH", Path: "/api/control", Tag: "control", Summary: "Change the default settings", Scope: scopeOperator, Body: ControlSettings{}, Response: ControlState{}},
+	{Method: "DELETE", Path: "/api/control", Tag: "control", Summary: "Go back to the command line", Scope: scopeOperator, Response: ControlState{}},
+	{Method: "PATCH", Path: "/api/control/listeners/{addr}", Tag: "control", Summary: "Change one listener", Scope: scopeOperator, Body: ListenerSettings{}, Response: ListenerSettings{}},
+	{Method: "DELETE", Path: "/api/control/listeners/{addr}", Tag: "control", Summary: "Put a listener back to its -listen options", Scope: scopeOperator, Response: ListenerSettings{}},
+	{Method: "GET", Path: "/api/control/audit", Tag: "control", Summary: "Audit trail, newest first", Scope: scopeRead,
+		Query: []apiParam{{"limit", "integer", "Entries listed"}}, Response: struct {
+			Entries []AuditEntry `json:"entries"`
+		}{}},
+
+	{Method: "GET", Path: "/api/annotations", Tag: "annotations", Summary: "Annotations overlapping the window", Scope: scopeRead,
+		Query: concatParams([]apiParam{{"subnet", "string", "Annotations on overlapping networks and those without one"}, {"conn_id", "string", "Annotations of one report"}}, REPORT_WINDOW_PARAMS),
+		Response: struct {
+			Annotations []Annotation `json:"annotations"`
+		}{}},
+	{Method: "POST", Path: "/api/annotations", Tag: "annotations", Summary: "Add an annotation", Scope: scopeOperator, Body: Annotation{}, Response: Annotation{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/api/annotations/{id}", Tag: "annotations", Summary: "Replace an annotation", Scope: scopeOperator, Body: Annotation{}, Response: Annotation{}},
+	{Method: "DELETE", Path: "/api/annotations/{id}", Tag: "annotations", Summary: "Remove an annotation", Scope: scopeOperator, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/whoami", Tag: "users", Summary: "The caller's name and role", Scope: scopeRead, Response: struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+		Auth bool   `json:"auth"`
+	}{}},
+	{Method: "GET", Path: "/api/users", Tag: "users", Summary: "API users and token names", Scope: scopeAdmin, Response: struct {
+		File   string    `json:"file"`
+		Users  []APIUser `json:"users"`
+		Tokens []APIUser `json:"tokens"`
+	}{}},
+	{Method: "PUT", Path: "/api/users/{name}", Tag: "users", Summary: "Add a user or change its role or password", Scope: scopeAdmin, Body: UserChange{}, Response: APIUser{}},
+	{Method: "DELETE", Path: "/api/users/{name}", Tag: "users", Summary: "Remove a user", Scope: scopeAdmin, Status: http.StatusNoContent},
+
+	{Method: "GET", Path: "/api/probes", Tag: "collector", Summary: "Probes reporting to a collector (collector only)", Scope: scopeRead, Response: []ProbeStatus{}},
+	{Method: "POST", Path: COLLECTOR_INGEST_PATH, Tag: "collector", Summary: "Reports pushed by a probe (collector only)", Scope: scopeIngest, Body: ingestRequest{}, Response: ingestResult{}},
+
+	{Method: "GET", Path: "/grafana/", Tag: "grafana", Summary: "Datasource connection test", Scope: scopeRead},
+	{Method: "POST", Path: "/grafana/metrics", Tag: "grafana", Summary: "Metric names and labels", Scope: scopeRead, Response: []map[string]string{}},
+	{Method: "POST", Path: "/grafana/search", Tag: "grafana", Summary: "Metric names", Scope: scopeRead, Response: []string{}},
+	{Method: "POST", Path: "/grafana/query", Tag: "grafana", Summary: "Time series per target", Scope: scopeRead, Body: grafanaQuery{}, Response: []grafanaSeries{}},
+
+	{Method: "GET", Path: OPENAPI_PATH, Tag: "meta", Summary: "This document", Scope: scopePublic, Media: "application/json"},
+}
+
+// configDocument and statusResponse describe the maps /api/config and /status
+// are built from.
+type configDocument struct {
+	Started       string            `json:"started"`
+	Scenario      string            `json:"scenario"`
+	SchemaVersion int               `json:"schema_version"`
+	Mode          string            `json:"mode"`
+	Probe         string            `json:"probe,omitempty"`
+	Site          string            `json:"site,omitempty"`
+	Listeners     []listenerConfig  `json:"listeners"`
+	Sinks         []string          `json:"sinks"`
+	HistoryStore  string            `json:"history_store"`
+	SigningKeyID  string            `json:"signing_key_id,omitempty"`
+	Flags         map[string]string `json:"flags"`
+}
+
+type statusResponse struct {
+	Status        string `json:"status"`
+	Mode          string `json:"mode"`
+	Started       string `json:"started"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Connections   struct {
+		Active  int64 `json:"active"`
+		Handled int64 `json:"handled"`
+	} `json:"connections"`
+	Reports   int64        `json:"reports"`
+	Listeners int          `json:"listeners"`
+	Schemes   []string     `json:"schemes"`
+	Sinks     []SinkHealth `json:"sinks"`
+	Errors    struct {
+		Count int          `json:"count"`
+		Last  *StatusError `json:"last,omitempty"`
+	} `json:"errors"`
+	Process ProcessStatus `json:"process"`
+}
+
+func concatParams(lists ...[]apiParam) []apiParam {
+	var all []apiParam
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// ============================================================================
+// DOCUMENT
+// ============================================================================
+
+var pathParam = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// openAPIDocument builds the document; server is the base URL clients use.
+func openAPIDocument(server string) map[string]any {
+	g := schemaGen{schemas: map[string]any{
+		"Error": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"error": map[string]any{"type": "string"}},
+			"required":   []string{"error"},
+		},
+	}}
+	paths := make(map[string]any)
+	for _, op := range API_OPERATIONS {
+		item, _ := paths[op.Path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = g.operation(op)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Sentinel-PQC Proxy API",
+			"version":     fmt.Sprint(REPORT_SCHEMA_VERSION),
+			"description": "Post-quantum handshake fragmentation reports, analysis and runtime control. The version is the report schema version (schema.go).",
+		},
+		"servers": []any{map[string]any{"url": server}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"basic":  map[string]any{"type": "http", "scheme": "basic"},
+				"token":  map[string]any{"type": "apiKey", "in": "query", "name": "token"},
+			},
+		},
+	}
+}
+
+func (g *schemaGen) operation(op apiOperation) map[string]any {
+	o := map[string]any{
+		"operationId": operationID(op),
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+	}
+	var params []any
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	for _, p := range op.Query {
+		params = append(params, map[string]any{"name": p.Name, "in": "query", "description": p.Description, "schema": paramSchema(p.Type)})
+	}
+	if params != nil {
+		o["parameters"] = params
+	}
+	if op.Body != nil {
+		o["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.Response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.Response))}}
+	case op.Media != "":
+		success["content"] = map[string]any{op.Media: map[string]any{"schema": map[string]any{"type": "string"}}}
+	}
+	o["responses"] = map[string]any{
+		fmt.Sprint(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}},
+		},
+	}
+	if op.Scope != scopePublic {
+		o["security"] = []any{map[string]any{"bearer": []string{}}, map[string]any{"basic": []string{}}, map[string]any{"token": []string{}}}
+		o["x-required-role"] = op.Scope.role()
+	}
+	return o
+}
+
+// operationID is e.g. getClientsByIp for GET /api/clients/{ip}.
+func operationID(op apiOperation) string {
+	id := strings.ToLower(op.Method)
+	for _, part := range strings.FieldsFunc(strings.TrimPrefix(op.Path, "/api"), func(r rune) bool { return r == '/' || r == '.' || r == '_' }) {
+		if name, ok := strings.CutPrefix(part, "{"); ok {
+			part = "By" + exportedName(strings.TrimSuffix(name, "}"))
+		}
+		id += exportedName(part)
+	}
+	return id
+}
+
+func paramSchema(typ string) map[string]any {
+	switch typ {
+	case "integer":
+		return map[string]any{"type": "integer"}
+	case "date-time":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "duration":
+		return map[string]any{"type": "string", "pattern": `^[0-9.]+(ns|us|ms|s|m|h)`}
+	}
+	return map[string]any{"type": "string"}
+}
+
+func exportedName(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// ============================================================================
+// SCHEMAS
+// ============================================================================
+
+// schemaGen turns Go types into JSON schemas the way encoding/json encodes
+// them; named structs become components.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := exportedName(t.Name())
+		if _, done := g.schemas[name]; !done {
+			g.schemas[name] = map[string]any{} // recursion stops here
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{} // any
+}
+
+// object describes a struct's JSON fields, embedded structs inlined.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					add(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = g.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+	}
+	add(t)
+	s := map[string]any{"type": "object", "properties": props}
+	if required != nil {
+		s["required"] = required
+	}
+	return s
+}
+
+// ============================================================================
+// COMMAND AND API
+// ============================================================================
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument("/"))
+}
+
+func runOpenAPI(args []string) {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	out := fs.String("o", "", "Write the document to this file instead of stdout")
+	server := fs.String("server", "http://localhost:9090", "Base URL of the proxy's -api in the document")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: openapi [-server URL] [-o FILE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	data, _ := json.MarshalIndent(openAPIDocument(*server), "", "  ")
+	data = append(data, '\n')
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		log.Fatalf("[OPENAPI] %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d operations written to %s\n", len(API_OPERATIONS), *out)
+}
@@ -0,0 +1,414 @@
+/*
+Sentinel-PQC Proxy - PDF Writer
+===============================
+Just enough PDF 1.4 for the assessment document (assessment.go): A4 pages
+of Helvetica text, filled rectangles and lines. The standard fonts need not
+be embedded, so the writer has no dependencies and the files stay small.
+Text is Latin-1 (WinAnsiEncoding); other characters print as "?".
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	PDF_PAGE_W = 595.0 // A4, in points
+	PDF_PAGE_H = 842.0
+	PDF_MARGIN = 48.0
+	PDF_ROW    = 15.0 // table row height
+)
+
+// pdfColor is an RGB fill or stroke colour, "r g b" in 0..1.
+type pdfColor string
+
+const (
+	pdfText  pdfColor = "0.09 0.09 0.11"
+	pdfMuted pdfColor = "0.44 0.44 0.48"
+	pdfRule  pdfColor = "0.89 0.89 0.91"
+	pdfWhite pdfColor = "1 1 1"
+	pdfSafe  pdfColor = "0.02 0.59 0.41"
+	pdfWarn  pdfColor = "0.85 0.47 0.02"
+	pdfRisk  pdfColor = "0.86 0.15 0.15"
+)
+
+// pdfDoc collects the content streams of its pages; y is the layout cursor,
+// in points from the top of the current page.
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newPDF() *pdfDoc {
+	d := &pdfDoc{}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = PDF_MARGIN
+}
+
+func (d *pdfDoc) page() *bytes.Buffer { return d.pages[len(d.pages)-1] }
+
+// need starts a new page unless h more points fit on this one.
+func (d *pdfDoc) need(h float64) {
+	if d.y+h > PDF_PAGE_H-PDF_MARGIN {
+		d.newPage()
+	}
+}
+
+// text writes s with its baseline y points from the top of the page.
+func (d *pdfDoc) text(x, y, size float64, bold bool, color pdfColor, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %s rg %.2f %.2f Td (%s) Tj ET\n", font, size, color, x, PDF_PAGE_H-y, pdfEscape(s))
+}
+
+// textRight ends s at x; widths are estimated from Helvetica's digits.
+func (d *pdfDoc) textRight(x, y, size float64, bold bool, color pdfColor, s string) {
+	d.text(x-pdfWidth(s, size), y, size, bold, color, s)
+}
+
+// rect fills a rectangle whose top left corner is y points from the top.
+func (d *pdfDoc) rect(x, y, w, h float64, color pdfColor) {
+	fmt.Fprintf(d.page(), "%s rg %.2f %.2f %.2f %.2f re f\n", color, x, PDF_PAGE_H-y-h, w, h)
+}
+
+func (d *pdfDoc) line(x1, y1, x2, y2 float64, color pdfColor, dashed bool) {
+	dash := "[] 0 d"
+	if dashed {
+		dash = "[3 2] 0 d"
+	}
+	fmt.Fprintf(d.page(), "q %s RG %s 0.8 w %.2f %.2f m %.2f %.2f l S Q\n", color, dash, x1, PDF_PAGE_H-y1, x2, PDF_PAGE_H-y2)
+}
+
+// paragraph wraps s to width and advances the cursor past it.
+func (d *pdfDoc) paragraph(x, width, size float64, color pdfColor, s string) {
+	perLine := int(width / (size * 0.5))
+	var line string
+	flush := func() {
+		d.need(size * 1.4)
+		d.y += size * 1.4
+		d.text(x, d.y, size, false, color, line)
+		line = ""
+	}
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > perLine {
+			flush()
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		flush()
+	}
+}
+
+// pdfColumn is where a table column starts, or ends when right-aligned.
+type pdfColumn struct {
+	x     float64
+	right bool
+}
+
+// table draws rows under a header, repeating the header on a new page;
+// colors, when given, colour the cells of a row.
+func (d *pdfDoc) table(cols []pdfColumn, header []string, rows [][]string, colors [][]pdfColor) {
+	drawHeader := func() {
+		d.y += PDF_ROW
+		for i, c := range cols {
+			if c.right {
+				d.textRight(c.x, d.y, 8, false, pdfMuted, header[i])
+			} else {
+				d.text(c.x, d.y, 8, false, pdfMuted, header[i])
+			}
+		}
+		d.line(PDF_MARGIN, d.y+4, PDF_PAGE_W-PDF_MARGIN, d.y+4, pdfRule, false)
+	}
+	d.need(2 * PDF_ROW)
+	drawHeader()
+	for r, row := range rows {
+		if d.y+PDF_ROW > PDF_PAGE_H-PDF_MARGIN {
+			d.newPage()
+			drawHeader()
+		}
+		d.y += PDF_ROW
+		for i, c := range cols {
+			color := pdfText
+			if colors != nil && colors[r][i] != "" {
+				color = colors[r][i]
+			}
+			if c.right {
+				d.textRight(c.x, d.y, 9, false, color, row[i])
+			} else {
+				d.text(c.x, d.y, 9, false, color, row[i])
+			}
+		}
+	}
+}
+
+// bytes assembles the document: catalog, page tree, the two fonts, then a
+// page and its content stream for every page.
+func (d *pdfDoc) bytes(title string) []byte {
+	var b bytes.Buffer
+	var offsets []int
+	obj := func(format string, a ...any) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&b, format, a...)
+		b.WriteString("\nendobj\n")
+	}
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj("<< /Title (%s) /Producer (Sentinel-PQC) >>", pdfEscape(title))
+	for i, p := range d.pages {
+		obj("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PDF_PAGE_W, PDF_PAGE_H, firstPage+2*i+1)
+		obj("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.Bytes())
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.Bytes()
+}
+
+// pdfEscape encodes s as Latin-1 for a PDF string literal.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 32:
+		case r > 255:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// pdfWidth estimates the width of s in points; digits are 0.556 em wide in
+// Helvetica, which is what right-aligned columns hold.
+func pdfWidth(s string, size float64) float64 {
+	return float64(len(s)) * 0.556 * size
+}
+
+// ============================================================================
+// ASSESSMENT
+// ============================================================================
+
+// assessmentPDF lays out an assessment like its HTML rendering.
+func assessmentPDF(a Assessment) []byte {
+	d := newPDF()
+	left, right := PDF_MARGIN, PDF_PAGE_W-PDF_MARGIN
+	width := right - left
+
+	d.y += 20
+	d.text(left, d.y, 20, true, pdfText, "Post-quantum readiness assessment")
+	meta := fmt.Sprintf("Generated %s from %s · %s", a.Generated, a.Source, a.Filters)
+	if a.From != "" {
+		meta += fmt.Sprintf(" · reports from %s to %s", a.From, a.To)
+	}
+	d.y += 4
+	d.paragraph(left, width, 9, pdfMuted, meta)
+
+	d.y += 12
+	vw := pdfWidth(a.Verdict, 11) + 16
+	d.rect(left, d.y, vw, 20, pdfVerdictColor(a.Verdict))
+	d.text(left+8, d.y+14, 11, true, pdfWhite, a.Verdict)
+	d.y += 22
+	d.paragraph(left, width, 10, pdfText, a.Summary)
+
+	cards := [][2]string{
+		{"Handshakes", fmt.Sprint(a.Reports)},
+		{"Detections", fmt.Sprint(a.Detections)},
+		{"Detection rate", fmt.Sprintf("%.1f%%", a.DetectionRate*100)},
+		{"Over IPv6 minimum", fmt.Sprint(a.IPv6Risk)},
+		{"Size p50 / p95", fmt.Sprintf("%d / %d B", a.Size.P50, a.Size.P95)},
+		{"Size max", fmt.Sprintf("%d B", a.Size.Max)},
+	}
+	d.y += 10
+	cardW := width / 3
+	for i, c := range cards {
+		x, y := left+float64(i%3)*cardW, d.y+float64(i/3)*36
+		d.text(x, y+12, 8, false, pdfMuted, c[0])
+		d.text(x, y+28, 14, true, pdfText, c[1])
+	}
+	d.y += 72
+	if a.Reports == 0 {
+		return d.finish()
+	}
+
+	d.section("Verdicts")
+	top := 1
+	for _, c := range a.Statuses {
+		top = max(top, c.Count)
+	}
+	for _, c := range a.Statuses {
+		d.need(16)
+		d.y += 16
+		d.text(left, d.y, 9, false, pdfText, c.Status)
+		w := max(1, float64(c.Count)*(width-200)/float64(top))
+		d.rect(left+150, d.y-9, w, 10, pdfStatusColor(c.Status))
+		d.text(left+150+w+6, d.y, 9, false, pdfMuted, fmt.Sprint(c.Count))
+	}
+
+	d.section("Handshake sizes")
+	d.sizeChart(a.Sizes, a.Budget, left, width)
+
+	if len(a.Annotations) > 0 {
+		// Notes are free text, so they wrap instead of filling a table
+		d.section("Annotations")
+		for _, n := range a.Annotations {
+			when := n.Time
+			if n.End != "" {
+				when += " to " + n.End
+			}
+			if n.Subnet != "" {
+				when += " · " + n.Subnet
+			}
+			d.y += 6
+			d.paragraph(left, width, 8, pdfMuted, when+" · "+n.Author)
+			d.paragraph(left, width, 10, pdfText, n.Text)
+		}
+	}
+
+	d.section("Algorithms")
+	cols := []pdfColumn{{left, false}, {left + 150, true}, {left + 205, true}, {left + 250, true}, {left + 310, true}, {left + 375, true}, {left + 415, true}, {left + 455, true}, {right, true}}
+	var rows [][]string
+	for _, alg := range a.Algorithms {
+		rows = append(rows, []string{alg.Algorithm, fmt.Sprint(alg.Reports), fmt.Sprint(alg.Detections), fmt.Sprintf("%.1f%%", alg.DetectionRate*100),
+			fmt.Sprint(alg.OverBudget), fmt.Sprint(alg.IPv6Risk), fmt.Sprint(alg.Size.P50), fmt.Sprint(alg.Size.P95), fmt.Sprint(alg.Size.Max)})
+	}
+	d.table(cols, []string{"Algorithm", "Handshakes", "Detections", "Rate", "Over budget", "Over IPv6", "p50", "p95", "Max"}, rows, nil)
+
+	d.section("Findings")
+	if len(a.Findings) == 0 {
+		d.paragraph(left, width, 10, pdfText, "No compliance findings.")
+	} else {
+		var findings [][]string
+		var colors [][]pdfColor
+		for _, f := range a.Findings {
+			findings = append(findings, []string{f.ID, f.Level, f.Short, fmt.Sprint(f.Count), fmt.Sprint(f.Clients)})
+			colors = append(colors, []pdfColor{"", pdfLevelColor(f.Level), "", "", ""})
+		}
+		cols := []pdfColumn{{left, false}, {left + 100, false}, {left + 150, false}, {right - 50, true}, {right, true}}
+		d.table(cols, []string{"Rule", "Level", "Finding", "Reports", "Clients"}, findings, colors)
+	}
+
+	d.section("Subnets with the most detections")
+	rows = nil
+	for _, s := range a.Subnets {
+		rows = append(rows, []string{s.Subnet, fmt.Sprint(s.Reports), fmt.Sprint(s.Detections)})
+	}
+	d.table([]pdfColumn{{left, false}, {left + 300, true}, {right, true}}, []string{"Subnet", "Handshakes", "Detections"}, rows, nil)
+	return d.finish()
+}
+
+// section starts a titled section, on a new page if little room is left.
+func (d *pdfDoc) section(title string) {
+	d.need(60)
+	d.y += 28
+	d.text(PDF_MARGIN, d.y, 13, true, pdfText, title)
+	d.line(PDF_MARGIN, d.y+5, PDF_PAGE_W-PDF_MARGIN, d.y+5, pdfRule, false)
+	d.y += 6
+}
+
+// sizeChart draws the histogram of assessment.go's sizeChartSVG.
+func (d *pdfDoc) sizeChart(bins []AssessmentBin, budget int, left, width float64) {
+	const height = 120.0
+	if len(bins) == 0 {
+		return
+	}
+	d.need(height + 30)
+	d.y += 10
+	base := d.y + height
+	top := 1
+	for _, b := range bins {
+		top = max(top, b.Safe+b.Detections)
+	}
+	lo, hi := bins[0].From, bins[len(bins)-1].To
+	barW := width / float64(len(bins))
+	for i, b := range bins {
+		x := left + float64(i)*barW
+		safeH := float64(b.Safe) * height / float64(top)
+		detH := float64(b.Detections) * height / float64(top)
+		d.rect(x+1, base-safeH, barW-2, safeH, pdfSafe)
+		d.rect(x+1, base-safeH-detH, barW-2, detH, pdfRisk)
+		if i%max(1, len(bins)/8) == 0 {
+			d.text(x+1, base+11, 7, false, pdfMuted, fmt.Sprint(b.From))
+		}
+	}
+	for _, l := range []struct {
+		bytes int
+		color pdfColor
+		label string
+	}{{budget, pdfRisk, "MTU budget"}, {IPV6_MIN_BUDGET, pdfWarn, "IPv6 minimum"}} {
+		if l.bytes > lo && l.bytes < hi {
+			x := left + float64(l.bytes-lo)*width/float64(hi-lo)
+			d.line(x, d.y, x, base, l.color, true)
+			d.text(x+3, d.y+8, 7, false, l.color, fmt.Sprintf("%s %d B", l.label, l.bytes))
+		}
+	}
+	d.y = base + 16
+}
+
+// finish numbers the pages and renders the document.
+func (d *pdfDoc) finish() []byte {
+	for i, p := range d.pages {
+		fmt.Fprintf(p, "BT /F1 8 Tf %s rg %.2f %.2f Td (%s) Tj ET\n", pdfMuted, PDF_MARGIN, PDF_MARGIN/2,
+			pdfEscape(fmt.Sprintf("Sentinel-PQC readiness assessment · page %d of %d", i+1, len(d.pages))))
+	}
+	return d.bytes("Sentinel-PQC readiness assessment")
+}
+
+func pdfVerdictColor(v string) pdfColor {
+	switch v {
+	case "READY":
+		return pdfSafe
+	case "NOT READY":
+		return pdfRisk
+	}
+	return pdfWarn
+}
+
+func pdfStatusColor(s string) pdfColor {
+	if s == "SAFE" {
+		return pdfSafe
+	}
+	return pdfRisk
+}
+
+func pdfLevelColor(level string) pdfColor {
+	switch level {
+	case "error":
+		return pdfRisk
+	case "warning":
+		return pdfWarn
+	}
+	return ""
+}
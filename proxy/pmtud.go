@@ -0,0 +1,169 @@
+/*
+Sentinel-PQC Proxy - Path MTU Discovery
+=======================================
+SAFE_MTU (1400) is an assumption. With -pmtud the proxy measures the real
+path MTU back to each client before judging its handshake:
+
+  1. UDP probes with the Don't Fragment bit set are sent to an unused port
+     on the client (as traceroute/tracepath do)
+  2. A probe that arrives triggers ICMP Port Unreachable -> size delivered
+     A probe that is too big is dropped (or refused locally) -> no answer
+  3. Binary search between the protocol minimum and the route MTU
+
+The payload budget for the connection is then the path MTU minus IP and TCP
+headers instead of the fixed SAFE_MTU. Results are cached per destination.
+If the client never answers probes (firewalled ICMP), the kernel's route MTU
+is used and the result is marked unverified.
+*/
+
+package main
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	PMTUD_PROBE_PORT    = 33434 // traceroute base port, normally closed
+	PMTUD_PROBE_TIMEOUT = 300 * time.Millisecond
+	PMTUD_MIN_IPV4      = 576
+	PMTUD_MIN_IPV6      = 1280
+	PMTUD_CACHE_TTL     = 10 * time.Minute
+
+	IPV4_HEADER_SIZE = 20
+	IPV6_HEADER_SIZE = 40
+	TCP_HEADER_SIZE  = 20
+	UDP_HEADER_SIZE  = 8
+)
+
+// PathMTU is the measured MTU towards one destination.
+type PathMTU struct {
+	Destination string  `json:"destination"`
+	MTU         int     `json:"mtu"`
+	RouteMTU    int     `json:"route_mtu"`
+	Probes      int     `json:"probes"`
+	Verified    bool    `json:"verified"`
+	LimitingHop *MTUHop `json:"limiting_hop,omitempty"` // traced when MTU < RouteMTU
+	measuredAt  time.Time
+}
+
+var (
+	pathMTUCache   = make(map[string]PathMTU)
+	pathMTUCacheMu sync.Mutex
+	pathMTUProbes  singleflight.Group // one probe per host at a time
+)
+
+// ============================================================================
+// BUDGET
+// ============================================================================
+
+// mtuBudget returns the TCP payload budget towards peer: the listener's
+// base budget, or the measured path MTU minus headers when -pmtud is on.
+func mtuBudget(peer string, base int, lg *slog.Logger) (int, *PathMTU) {
+	if !*pmtudEnabled {
+		return base, nil
+	}
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	}
+	pm, err := discoverPathMTU(host, lg)
+	if err != nil {
+		lg.Warn("path MTU probe failed, using listener budget", "host", host, "budget", base, "err", err)
+		return base, nil
+	}
+	return pm.MTU - ipHeaderSize(host) - TCP_HEADER_SIZE, &pm
+}
+
+// discoverPathMTU returns the cached result for host or probes it. The
+// cache is only locked to look up and store: a probe takes seconds when
+// probes are lost, and handshakes from other hosts must not wait for it;
+// handshakes from the same host wait for the one probe in flight.
+func discoverPathMTU(host string, lg *slog.Logger) (PathMTU, error) {
+	if pm, ok := cachedPathMTU(host); ok {
+		return pm, nil
+	}
+	v, err, _ := pathMTUProbes.Do(host, func() (any, error) {
+		// A probe that just finished may have filled the cache
+		if pm, ok := cachedPathMTU(host); ok {
+			return pm, nil
+		}
+		pm, err := probePathMTU(host)
+		if err != nil {
+			return PathMTU{}, err
+		}
+		if pm.Verified && pm.MTU < pm.RouteMTU {
+			if trace, err := probeMTUTrace(host, MTUTRACE_MAX_HOPS); err == nil {
+				pm.LimitingHop = trace.LimitingHop
+			}
+		}
+		pm.measuredAt = time.Now()
+		pathMTUCacheMu.Lock()
+		pathMTUCache[host] = pm
+		pathMTUCacheMu.Unlock()
+
+		lg.Info("path MTU measured", "host", host, "mtu", pm.MTU, "route_mtu", pm.RouteMTU,
+			"probes", pm.Probes, "verified", pm.Verified)
+		if pm.LimitingHop != nil {
+			lg.Info("path MTU limiting hop", "ttl", pm.LimitingHop.TTL, "router", pm.LimitingHop.Router, "next_hop_mtu", pm.LimitingHop.PTB)
+		}
+		return pm, nil
+	})
+	if err != nil {
+		return PathMTU{}, err
+	}
+	return v.(PathMTU), nil
+}
+
+// cachedPathMTU is the measurement for host that has not expired yet.
+func cachedPathMTU(host string) (PathMTU, bool) {
+	pathMTUCacheMu.Lock()
+	defer pathMTUCacheMu.Unlock()
+	pm, ok := pathMTUCache[host]
+	return pm, ok && time.Since(pm.measuredAt) < PMTUD_CACHE_TTL
+}
+
+func ipHeaderSize(host string) int {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return IPV6_HEADER_SIZE
+	}
+	return IPV4_HEADER_SIZE
+}
+
+// searchPathMTU binary-searches the largest size for which probe succeeds.
+func searchPathMTU(host string, minMTU, routeMTU int, probe func(size int) bool) PathMTU {
+	pm := PathMTU{Destination: host, MTU: routeMTU, RouteMTU: routeMTU}
+
+	pm.Probes++
+	if !probe(minMTU) {
+		// Nothing comes back even at the minimum: ICMP is filtered
+		return pm
+	}
+	pm.Verified = true
+
+	pm.Probes++
+	if probe(routeMTU) {
+		return pm
+	}
+
+	lo, hi := minMTU, routeMTU // lo delivered, hi not
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		pm.Probes++
+		if probe(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	pm.MTU = lo
+	return pm
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// probePathMTU runs DF-flagged UDP probes towards host (Linux).
+func probePathMTU(host string) (PathMTU, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return PathMTU{}, fmt.Errorf("not an IP address: %q", host)
+	}
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: PMTUD_PROBE_PORT})
+	if err != nil {
+		return PathMTU{}, err
+	}
+	defer conn.Close()
+
+	level, discover, probeMode, mtuOpt := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE, syscall.IP_MTU
+	minMTU := PMTUD_MIN_IPV4
+	if ip.To4() == nil {
+		level, discover, probeMode, mtuOpt = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE, syscall.IPV6_MTU
+		minMTU = PMTUD_MIN_IPV6
+	}
+
+	// PMTUDISC_PROBE: always set DF, never fall back to the cached PMTU
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PathMTU{}, err
+	}
+	var routeMTU int
+	var sockErr error
+	raw.Control(func(fd uintptr) {
+		if sockErr = syscall.SetsockoptInt(int(fd), level, discover, probeMode); sockErr != nil {
+			return
+		}
+		routeMTU, sockErr = syscall.GetsockoptInt(int(fd), level, mtuOpt)
+	})
+	if sockErr != nil {
+		return PathMTU{}, fmt.Errorf("socket options: %w", sockErr)
+	}
+
+	headers := ipHeaderSize(host) + UDP_HEADER_SIZE
+	reply := make([]byte, 64)
+	probe := func(size int) bool {
+		if _, err := conn.Write(make([]byte, size-headers)); err != nil {
+			return false // EMSGSIZE: larger than the local interface
+		}
+		conn.SetReadDeadline(time.Now().Add(PMTUD_PROBE_TIMEOUT))
+		_, err := conn.Read(reply)
+		// Port Unreachable (or an actual answer) means the probe arrived
+		return err == nil || errors.Is(err, syscall.ECONNREFUSED)
+	}
+
+	return searchPathMTU(host, minMTU, routeMTU, probe), nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func probePathMTU(host string) (PathMTU, error) {
+	return PathMTU{}, errors.New("path MTU probing is only supported on Linux")
+}
@@ -0,0 +1,169 @@
+/*
+Sentinel-PQC Proxy - Redaction and IP Anonymization
+===================================================
+Deployments under privacy rules can keep collecting fragmentation telemetry
+without storing who sent it:
+
+  SENTINEL_ANON_KEY=... go run . -anonymize-ip hash -redact sni,origin,geo.as_org
+  go run . -anonymize-ip truncate
+
+  -anonymize-ip hash       client_ip becomes "anon-3f9a1c0e5b7d2a64", a keyed
+                           HMAC-SHA256 of the address: stable per client,
+                           useless without the key
+  -anonymize-ip truncate   client_ip keeps its /24 (IPv4) or /48 (IPv6),
+                           e.g. "10.0.7.0", so subnet statistics still work
+
+Both drop the client port. -redact removes fields by their JSON name, with
+dots for nested ones (geo.as_org, path_fits.path); fields that are always
+present come back empty rather than missing. schema_version, timestamp,
+status and signature cannot be redacted.
+
+Reports are redacted right after GeoIP enrichment and before anything
+stores, sends, signs or counts them, so the snapshot, the report log, every
+sink, the statistics and the APIs only ever see the redacted report. The
+connection's log lines still name the client; keep -log-level at warn or
+above where that matters. Without SENTINEL_ANON_KEY the hash key is random
+per run, so hashed clients cannot be correlated across restarts.
+*/
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const ANON_KEY_ENV = "SENTINEL_ANON_KEY"
+
+// REDACT_PROTECTED are the fields every reader of a report relies on.
+var REDACT_PROTECTED = map[string]bool{"schema_version": true, "timestamp": true, "status": true, "signature": true}
+
+var privacy struct {
+	ipMode string
+	key    []byte
+	fields [][]string // JSON paths to delete
+}
+
+// setupPrivacy checks -anonymize-ip and -redact.
+func setupPrivacy(ipMode, redact string) error {
+	switch ipMode {
+	case "", "truncate":
+	case "hash":
+		privacy.key = []byte(os.Getenv(ANON_KEY_ENV))
+		if len(privacy.key) == 0 {
+			privacy.key = make([]byte, 32)
+			rand.Read(privacy.key)
+			slog.Warn("hashing client IPs with a random key; set " + ANON_KEY_ENV + " to keep hashes stable across restarts")
+		}
+	default:
+		return fmt.Errorf("unknown -anonymize-ip %q (hash, truncate)", ipMode)
+	}
+	privacy.ipMode = ipMode
+
+	known := reportJSONFields()
+	for _, name := range strings.Split(redact, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		path := strings.Split(name, ".")
+		if !known[path[0]] {
+			return fmt.Errorf("-redact: reports have no field %q", path[0])
+		}
+		if REDACT_PROTECTED[name] {
+			return fmt.Errorf("-redact: %s cannot be redacted", name)
+		}
+		privacy.fields = append(privacy.fields, path)
+	}
+	if ipMode != "" || len(privacy.fields) > 0 {
+		slog.Info("redacting reports", "anonymize_ip", ipMode, "redact", redact)
+	}
+	return nil
+}
+
+// reportJSONFields lists the top-level JSON names of a report.
+func reportJSONFields() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(GhostReport{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// redactReport anonymizes the client and drops the -redact fields.
+func redactReport(report *GhostReport) {
+	if privacy.ipMode != "" {
+		report.ClientIP = anonymizeIP(report.ClientIP)
+	}
+	if len(privacy.fields) == 0 {
+		return
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	var m map[string]any
+	if json.Unmarshal(data, &m) != nil {
+		return
+	}
+	for _, path := range privacy.fields {
+		deleteJSONPath(m, path)
+	}
+	data, _ = json.Marshal(m)
+	var redacted GhostReport
+	if err := json.Unmarshal(data, &redacted); err != nil {
+		report.logger().Error("cannot redact report", "err", err)
+		return
+	}
+	redacted.profile, redacted.log = report.profile, report.log
+	*report = redacted
+}
+
+// deleteJSONPath removes a.b.c from a decoded object, looking into every
+// element of arrays on the way (flights.payload_bytes).
+func deleteJSONPath(v any, path []string) {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			delete(v, path[0])
+			return
+		}
+		deleteJSONPath(v[path[0]], path[1:])
+	case []any:
+		for _, e := range v {
+			deleteJSONPath(e, path)
+		}
+	}
+}
+
+// anonymizeIP hashes or truncates the host of addr and drops the port.
+func anonymizeIP(addr string) string {
+	host := clientHost(addr)
+	if privacy.ipMode == "hash" {
+		mac := hmac.New(sha256.New, privacy.key)
+		mac.Write([]byte(host))
+		return "anon-" + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return "redacted"
+	}
+	ip = ip.Unmap()
+	bits := 24
+	if ip.Is6() {
+		bits = 48
+	}
+	prefix, _ := ip.Prefix(bits)
+	return prefix.Addr().String()
+}
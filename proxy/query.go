@@ -0,0 +1,225 @@
+/*
+Sentinel-PQC Proxy - Report Queries
+===================================
+History queries (the history command, GET /api/reports and the gRPC
+QueryReports call) go to whichever store the proxy writes, in this order:
+
+  -sqlite       indexed SQLite store
+  -postgres     central PostgreSQL database
+  -report-log   the JSONL report log and its rotations, scanned newest first
+
+so a proxy started without a database still answers from its report log.
+All stores return reports newest first; Limit and Offset page through them.
+
+The databases index client, status, algorithm and time but know nothing of
+subnets, probes or sites, so those filters page through their results and
+match the reports here (queryReports).
+*/
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	QUERY_DEFAULT_LIMIT = 100
+	QUERY_MAX_LIMIT     = 1000 // per page for the APIs
+	QUERY_SCAN_BATCH    = 1000 // reports per store query when matching subnets
+)
+
+// historyFilter selects reports from a store.
+type historyFilter struct {
+	Client    string
+	Status    string
+	Algorithm string
+	Subnet    netip.Prefix // client address within; zero matches all
+	Probe     string       // collector.go
+	Site      string
+	Since     time.Duration // the last D; ignored when From is set
+	From      time.Time     // inclusive
+	To        time.Time     // exclusive
+	Limit     int
+	Offset    int
+}
+
+// window is the time range the filter selects; zero times are unbounded.
+func (f historyFilter) window() (from, to time.Time) {
+	from = f.From
+	if from.IsZero() && f.Since > 0 {
+		from = time.Now().Add(-f.Since)
+	}
+	return from, f.To
+}
+
+// matches applies the filter to one report, for stores without an index.
+func (f historyFilter) matches(r GhostReport) bool {
+	if f.Client != "" && clientHost(r.ClientIP) != f.Client {
+		return false
+	}
+	if f.Status != "" && r.Status != f.Status {
+		return false
+	}
+	if f.Algorithm != "" && r.Algorithm != f.Algorithm {
+		return false
+	}
+	if f.Subnet.IsValid() && !inSubnet(r.ClientIP, f.Subnet) {
+		return false
+	}
+	if f.Probe != "" && r.Probe != f.Probe || f.Site != "" && r.Site != f.Site {
+		return false
+	}
+	from, to := f.window()
+	if from.IsZero() && to.IsZero() {
+		return true
+	}
+	ts, err := time.Parse(time.RFC3339, r.Timestamp)
+	if err != nil {
+		return false
+	}
+	return (from.IsZero() || !ts.Before(from)) && (to.IsZero() || ts.Before(to))
+}
+
+// inSubnet reports whether a client address lies in the prefix; anonymized
+// addresses never do.
+func inSubnet(addr string, subnet netip.Prefix) bool {
+	ip, err := netip.ParseAddr(clientHost(addr))
+	return err == nil && subnet.Contains(ip.Unmap())
+}
+
+// reportQuerier is a store that can answer history queries.
+type reportQuerier interface {
+	query(f historyFilter) ([]GhostReport, error)
+}
+
+// queryReports queries a store, matching the subnet, probe and site
+// filters itself for the stores that cannot.
+func queryReports(store reportQuerier, f historyFilter) ([]GhostReport, error) {
+	if _, scans := store.(reportLogQuerier); scans || !f.Subnet.IsValid() && f.Probe == "" && f.Site == "" {
+		return store.query(f)
+	}
+	batch := f
+	batch.Offset, batch.Limit = 0, QUERY_SCAN_BATCH
+	skip := f.Offset
+	var reports []GhostReport
+	for {
+		page, err := store.query(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page {
+			if !f.matches(r) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			reports = append(reports, r)
+			if len(reports) == f.Limit {
+				return reports, nil
+			}
+		}
+		if len(page) < batch.Limit {
+			return reports, nil
+		}
+		batch.Offset += len(page)
+	}
+}
+
+// historyStore returns the store history queries go to and its name, or nil
+// if the proxy keeps no history.
+func historyStore() (reportQuerier, string) {
+	reportSinksMu.Lock()
+	defer reportSinksMu.Unlock()
+	for _, s := range reportSinks {
+		if q, ok := s.(*sqliteStore); ok {
+			return q, s.Name()
+		}
+	}
+	for _, s := range reportSinks {
+		if q, ok := s.(*postgresSink); ok {
+			return q, s.Name()
+		}
+	}
+	if *reportLogFile != "" {
+		return reportLogQuerier{}, "report-log:" + *reportLogFile
+	}
+	return nil, ""
+}
+
+// ============================================================================
+// REPORT LOG
+// ============================================================================
+
+// reportLogQuerier scans the report log and its rotations.
+type reportLogQuerier struct{}
+
+func (reportLogQuerier) query(f historyFilter) ([]GhostReport, error) {
+	files := []string{*reportLogFile}
+	for i := 1; i <= max(*reportLogKeep, 1); i++ {
+		if name := rotatedLogName(i); name != "" {
+			files = append(files, name)
+		}
+	}
+	var reports []GhostReport
+	skip := f.Offset
+	for _, name := range files {
+		matched, err := scanReportLog(name, f)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := len(matched) - 1; i >= 0; i-- {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			reports = append(reports, matched[i])
+			if len(reports) == f.Limit {
+				return reports, nil
+			}
+		}
+	}
+	return reports, nil
+}
+
+// scanReportLog returns the matching reports of one log file, oldest first.
+func scanReportLog(name string, f historyFilter) ([]GhostReport, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var matched []GhostReport
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 4<<20)
+	for sc.Scan() {
+		report, err := decodeReport(sc.Bytes())
+		if err != nil {
+			continue // a line cut short by a crash
+		}
+		if f.matches(report) {
+			matched = append(matched, report)
+		}
+	}
+	return matched, sc.Err()
+}
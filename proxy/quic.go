@@ -0,0 +1,538 @@
+/*
+Sentinel-PQC Proxy - QUIC Initial Scenario
+==========================================
+QUIC carries the ClientHello in CRYPTO frames of Initial packets, and a
+client pads every datagram with an Initial to at least 1200 bytes (RFC 9000
+14.1). A PQC ClientHello no longer fits in one: instead of leaning on IP
+fragmentation, QUIC splits it across datagrams, each of which can be lost
+on its own. The "quic" scenario is the server side of the client's
+-quic:
+
+  - it removes the Initial packet protection (RFC 9001 5.2, keys derived
+    from the client's Destination Connection ID)
+  - it reassembles the CRYPTO stream across datagrams and acknowledges
+    every packet in an Initial of its own, so the client sees which
+    datagrams were lost and retransmits their data; the gaps in the
+    client's packet numbers count the lost ones
+  - once the ClientHello is complete it encapsulates to the ML-KEM key at
+    its start and returns the ciphertext in a ServerHello, again in
+    Initials, resent if the client retransmits after it
+
+The handshake messages are the simulation's: the ClientHello is the key
+share and the client's padding behind a handshake header, the ServerHello
+the ciphertext. The report of the datagram that completes the ClientHello
+carries a "quic" section: the client's datagrams and bytes, the lost
+and duplicated ones, and the server's datagrams against the
+anti-amplification limit (three times what the client sent before its
+address is validated, RFC 9000 8.1).
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	QUIC_VERSION_1    = 0x00000001
+	QUIC_MIN_DATAGRAM = 1200
+	QUIC_CID_SIZE     = 8
+	QUIC_PN_SIZE      = 4 // packet numbers are always sent in 4 bytes
+	QUIC_TAG_SIZE     = 16
+	QUIC_AMPLIFY      = 3 // RFC 9000 8.1
+
+	QUIC_FRAME_PADDING = 0x00
+	QUIC_FRAME_PING    = 0x01
+	QUIC_FRAME_ACK     = 0x02
+	QUIC_FRAME_CRYPTO  = 0x06
+
+	QUIC_CLIENT_HELLO = 1
+	QUIC_SERVER_HELLO = 2
+
+	QUIC_STATE_TIMEOUT = 30 * time.Second // a connection's reassembly state
+)
+
+// RFC 9001 5.2
+var quicInitialSalt = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+	0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+
+// errDatagramPending is a datagram scenario's answer to a datagram that did
+// not complete the exchange: no report yet.
+var errDatagramPending = errors.New("exchange continues in later datagrams")
+
+// QUICReport is how the ClientHello crossed the path in Initial datagrams.
+type QUICReport struct {
+	ClientHelloBytes int     `json:"client_hello_bytes"`
+	Datagrams        int     `json:"client_datagrams"`
+	DatagramBytes    int     `json:"client_datagram_bytes"`
+	LargestDatagram  int     `json:"largest_datagram_bytes"`
+	Lost             int     `json:"lost_datagrams"`      // packet numbers never received
+	Duplicates       int     `json:"duplicate_datagrams"` // CRYPTO data only seen before
+	ServerDatagrams  int     `json:"server_datagrams"`
+	ServerBytes      int     `json:"server_datagram_bytes"`
+	Amplification    float64 `json:"amplification"` // server bytes per client byte
+	WithinLimit      bool    `json:"within_amplification_limit"`
+}
+
+// ============================================================================
+// CONNECTION STATE
+// ============================================================================
+
+// quicConn is the server side of one client's Initial exchange.
+type quicConn struct {
+	mu       sync.Mutex
+	seen     time.Time
+	client   quicKeys // to open the client's packets
+	server   quicKeys // to seal ours
+	peerCID  []byte   // the client's Source Connection ID
+	localCID []byte
+	nextPN   uint32
+
+	crypto   map[uint64][]byte // CRYPTO frames by offset
+	acked    []uint64          // packet numbers received
+	response [][]byte          // the ServerHello datagrams, once sent
+	report   QUICReport
+}
+
+var (
+	quicMu    sync.Mutex
+	quicConns = make(map[string]*quicConn)
+)
+
+// quicConnFor is the state of the client at addr using dcid, created on its
+// first datagram; expired states are dropped on the way.
+func quicConnFor(addr net.Addr, dcid, scid []byte) *quicConn {
+	quicMu.Lock()
+	defer quicMu.Unlock()
+	now := time.Now()
+	for key, c := range quicConns {
+		if now.Sub(c.seen) > QUIC_STATE_TIMEOUT {
+			delete(quicConns, key)
+		}
+	}
+	key := addr.String() + "/" + string(dcid)
+	c, ok := quicConns[key]
+	if !ok {
+		c = &quicConn{
+			client:   quicInitialKeys(dcid, "client in"),
+			server:   quicInitialKeys(dcid, "server in"),
+			peerCID:  slices.Clone(scid),
+			localCID: make([]byte, QUIC_CID_SIZE),
+			crypto:   make(map[uint64][]byte),
+		}
+		rand.Read(c.localCID)
+		quicConns[key] = c
+	}
+	c.seen = now
+	return c
+}
+
+// cryptoStream is the CRYPTO data received contiguously from offset 0.
+func (c *quicConn) cryptoStream() []byte {
+	offsets := make([]uint64, 0, len(c.crypto))
+	for off := range c.crypto {
+		offsets = append(offsets, off)
+	}
+	slices.Sort(offsets)
+	var stream []byte
+	for _, off := range offsets {
+		if off > uint64(len(stream)) {
+			break
+		}
+		if end := off + uint64(len(c.crypto[off])); end > uint64(len(stream)) {
+			stream = append(stream, c.crypto[off][uint64(len(stream))-off:]...)
+		}
+	}
+	return stream
+}
+
+// send seals frames into one Initial datagram to the client.
+func (c *quicConn) send(pc net.PacketConn, addr net.Addr, frames []byte) ([]byte, error) {
+	datagram := sealQUICInitial(c.server, c.peerCID, c.localCID, c.nextPN, frames)
+	c.nextPN++
+	if _, err := pc.WriteTo(datagram, addr); err != nil {
+		return nil, err
+	}
+	c.report.ServerDatagrams++
+	c.report.ServerBytes += len(datagram)
+	return datagram, nil
+}
+
+// ============================================================================
+// RESPONDER
+// ============================================================================
+
+// respondQUIC takes one Initial datagram: it acknowledges the packet and,
+// once the ClientHello is complete, answers it with the ServerHello.
+func respondQUIC(pc net.PacketConn, addr net.Addr, scheme kem.Scheme, datagram []byte, report *GhostReport) error {
+	dcid, scid, err := parseQUICInitialHeader(datagram)
+	if err != nil {
+		return err
+	}
+	c := quicConnFor(addr, dcid, scid)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pn, payload, err := openQUICInitial(c.client, datagram)
+	if err != nil {
+		return fmt.Errorf("cannot open Initial packet: %w", err)
+	}
+	lg := report.logger()
+	c.report.Datagrams++
+	c.report.DatagramBytes += len(datagram)
+	c.report.LargestDatagram = max(c.report.LargestDatagram, len(datagram))
+	c.acked = append(c.acked, uint64(pn))
+
+	duplicate, err := c.readFrames(payload)
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		c.report.Duplicates++
+	}
+	lg.Debug("QUIC Initial", "pn", pn, "bytes", len(datagram), "duplicate", duplicate)
+
+	// A retransmission after the ServerHello: it was lost, send it again
+	if c.response != nil {
+		for _, d := range c.response {
+			pc.WriteTo(d, addr)
+		}
+		return errDatagramPending
+	}
+
+	stream := c.cryptoStream()
+	if len(stream) < 4 || stream[0] != QUIC_CLIENT_HELLO || len(stream) < 4+int(uint24(stream[1:])) {
+		if _, err := c.send(pc, addr, quicAckFrame(c.acked)); err != nil {
+			return fmt.Errorf("failed to send ACK: %w", err)
+		}
+		return errDatagramPending
+	}
+	hello := stream[4 : 4+uint24(stream[1:])]
+
+	pkSize := scheme.PublicKeySize()
+	if len(hello) < pkSize {
+		return fmt.Errorf("ClientHello too small (%d bytes) for a %s key (%d bytes required)", len(hello), scheme.Name(), pkSize)
+	}
+	pk, err := scheme.UnmarshalBinaryPublicKey(hello[:pkSize])
+	if err != nil {
+		return fmt.Errorf("invalid %s public key: %w", scheme.Name(), err)
+	}
+	ct, _, err := scheme.Encapsulate(pk)
+	if err != nil {
+		return fmt.Errorf("encapsulation failed: %w", err)
+	}
+	serverHello := append([]byte{QUIC_SERVER_HELLO, byte(len(ct) >> 16), byte(len(ct) >> 8), byte(len(ct))}, ct...)
+
+	// The first datagram acknowledges; CRYPTO frames fill them up. Like the
+	// client's, they are padded to QUIC_MIN_DATAGRAM (RFC 9000 14.1).
+	ack := quicAckFrame(c.acked)
+	for offset := 0; offset < len(serverHello); {
+		frames := ack
+		// CRYPTO frame header: type, 4-byte offset and 2-byte length at most
+		room := QUIC_MIN_DATAGRAM - quicInitialOverhead(len(c.peerCID), len(c.localCID)) - len(frames) - 1 - 4 - 2
+		n := min(room, len(serverHello)-offset)
+		frames = append(frames, quicCryptoFrame(uint64(offset), serverHello[offset:offset+n])...)
+		frames = append(frames, make([]byte, QUIC_MIN_DATAGRAM-quicInitialOverhead(len(c.peerCID), len(c.localCID))-len(frames))...) // PADDING
+		d, err := c.send(pc, addr, frames)
+		if err != nil {
+			return fmt.Errorf("failed to send ServerHello: %w", err)
+		}
+		c.response = append(c.response, d)
+		offset += n
+		ack = nil
+	}
+	lg.Info("sent QUIC ServerHello", "ciphertext_bytes", len(ct), "datagrams", len(c.response))
+
+	q := c.report
+	q.ClientHelloBytes = len(hello) + 4
+	q.Lost = quicLost(c.acked)
+	q.Amplification = float64(q.ServerBytes) / float64(q.DatagramBytes)
+	q.WithinLimit = q.ServerBytes <= QUIC_AMPLIFY*q.DatagramBytes
+	report.QUIC = &q
+	report.PublicKeySize = pkSize
+	report.ServerHelloSize = len(serverHello)
+	report.PathFits = fitPaths(q.LargestDatagram, vpnPaths)
+	logPathFits(report.PathFits, lg)
+
+	report.addNote(fmt.Sprintf("The %d-byte ClientHello took %d Initial datagrams of up to %d bytes.", q.ClientHelloBytes, q.Datagrams, q.LargestDatagram))
+	if q.Lost > 0 {
+		report.addNote(fmt.Sprintf("%d of the client's Initial datagrams were lost on the path and retransmitted.", q.Lost))
+	}
+	if !q.WithinLimit {
+		report.addNote(fmt.Sprintf("The server's %d bytes exceed %d× the client's %d before address validation.", q.ServerBytes, QUIC_AMPLIFY, q.DatagramBytes))
+	}
+	return nil
+}
+
+// readFrames takes the frames of a client Initial and reports whether it was
+// a duplicate: CRYPTO data, none of it new. A probe without CRYPTO frames is
+// not one.
+func (c *quicConn) readFrames(payload []byte) (bool, error) {
+	carried, fresh := false, false
+	for len(payload) > 0 {
+		switch payload[0] {
+		case QUIC_FRAME_PADDING, QUIC_FRAME_PING:
+			payload = payload[1:]
+		case QUIC_FRAME_ACK:
+			// Acknowledgements of our packets: nothing is retransmitted
+			rest, err := skipQUICAck(payload[1:])
+			if err != nil {
+				return false, err
+			}
+			payload = rest
+		case QUIC_FRAME_CRYPTO:
+			offset, n1 := readVarint(payload[1:])
+			length, n2 := readVarint(payload[1+n1:])
+			start := 1 + n1 + n2
+			if n1 == 0 || n2 == 0 || uint64(len(payload)-start) < length {
+				return false, errors.New("truncated CRYPTO frame")
+			}
+			carried = true
+			if _, ok := c.crypto[offset]; !ok {
+				c.crypto[offset] = slices.Clone(payload[start : start+int(length)])
+				fresh = true
+			}
+			payload = payload[start+int(length):]
+		default:
+			return false, fmt.Errorf("unexpected frame type 0x%02x in Initial", payload[0])
+		}
+	}
+	return carried && !fresh, nil
+}
+
+// ============================================================================
+// PACKET PROTECTION (RFC 9001 5)
+// ============================================================================
+
+type quicKeys struct {
+	aead cipher.AEAD
+	iv   []byte
+	hp   cipher.Block
+}
+
+func quicInitialKeys(dcid []byte, label string) quicKeys {
+	secret := hkdfExpandLabel(hkdf.Extract(sha256.New, dcid, quicInitialSalt), label, 32)
+	block, _ := aes.NewCipher(hkdfExpandLabel(secret, "quic key", 16))
+	aead, _ := cipher.NewGCM(block)
+	hp, _ := aes.NewCipher(hkdfExpandLabel(secret, "quic hp", 16))
+	return quicKeys{aead: aead, iv: hkdfExpandLabel(secret, "quic iv", 12), hp: hp}
+}
+
+// hkdfExpandLabel is HKDF-Expand-Label of TLS 1.3 with an empty context.
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	full := "tls13 " + label
+	info := binary.BigEndian.AppendUint16(nil, uint16(length))
+	info = append(info, byte(len(full)))
+	info = append(info, full...)
+	info = append(info, 0)
+	out := make([]byte, length)
+	hkdf.Expand(sha256.New, secret, info).Read(out)
+	return out
+}
+
+func (k quicKeys) nonce(pn uint32) []byte {
+	nonce := slices.Clone(k.iv)
+	for i := 0; i < 4; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+	return nonce
+}
+
+// mask is the header protection mask for the sample after the packet number.
+func (k quicKeys) mask(sample []byte) []byte {
+	mask := make([]byte, aes.BlockSize)
+	k.hp.Encrypt(mask, sample)
+	return mask
+}
+
+// quicInitialOverhead is an Initial's bytes besides its frames.
+func quicInitialOverhead(dcidLen, scidLen int) int {
+	// first byte, version, CIDs, token length, 2-byte length, packet number, tag
+	return 1 + 4 + 1 + dcidLen + 1 + scidLen + 1 + 2 + QUIC_PN_SIZE + QUIC_TAG_SIZE
+}
+
+// sealQUICInitial builds a protected Initial packet carrying frames.
+func sealQUICInitial(k quicKeys, dcid, scid []byte, pn uint32, frames []byte) []byte {
+	b := []byte{0xc0 | (QUIC_PN_SIZE - 1)}
+	b = binary.BigEndian.AppendUint32(b, QUIC_VERSION_1)
+	b = append(b, byte(len(dcid)))
+	b = append(b, dcid...)
+	b = append(b, byte(len(scid)))
+	b = append(b, scid...)
+	b = append(b, 0)                                                                            // no token
+	b = binary.BigEndian.AppendUint16(b, 0x4000|uint16(QUIC_PN_SIZE+len(frames)+QUIC_TAG_SIZE)) // 2-byte varint
+	pnOffset := len(b)
+	b = binary.BigEndian.AppendUint32(b, pn)
+	b = k.aead.Seal(b, k.nonce(pn), frames, b)
+
+	mask := k.mask(b[pnOffset+4 : pnOffset+4+16])
+	b[0] ^= mask[0] & 0x0f
+	for i := 0; i < QUIC_PN_SIZE; i++ {
+		b[pnOffset+i] ^= mask[1+i]
+	}
+	return b
+}
+
+// parseQUICInitialHeader returns the connection IDs of an Initial.
+func parseQUICInitialHeader(d []byte) (dcid, scid []byte, err error) {
+	if len(d) < 7 || d[0]&0xf0 != 0xc0 {
+		return nil, nil, errors.New("not a QUIC Initial packet")
+	}
+	if v := binary.BigEndian.Uint32(d[1:]); v != QUIC_VERSION_1 {
+		return nil, nil, fmt.Errorf("unsupported QUIC version 0x%08x", v)
+	}
+	pos := 5
+	for _, cid := range []*[]byte{&dcid, &scid} {
+		if pos >= len(d) || pos+1+int(d[pos]) > len(d) {
+			return nil, nil, errors.New("truncated QUIC header")
+		}
+		*cid = d[pos+1 : pos+1+int(d[pos])]
+		pos += 1 + int(d[pos])
+	}
+	return dcid, scid, nil
+}
+
+// openQUICInitial removes the protection of the first packet of an Initial
+// datagram and returns its packet number and frames.
+func openQUICInitial(k quicKeys, d []byte) (uint32, []byte, error) {
+	pos := 5
+	pos += 1 + int(d[pos])
+	pos += 1 + int(d[pos])
+	token, n := readVarint(d[pos:])
+	pos += n + int(token)
+	length, n := readVarint(d[min(pos, len(d)):])
+	pos += n
+	if n == 0 || length < QUIC_PN_SIZE+QUIC_TAG_SIZE || pos+int(length) > len(d) || pos+4+16 > len(d) {
+		return 0, nil, errors.New("truncated packet")
+	}
+	packet := slices.Clone(d[:pos+int(length)])
+	mask := k.mask(packet[pos+4 : pos+4+16])
+	packet[0] ^= mask[0] & 0x0f
+	pnLen := int(packet[0]&0x03) + 1
+	var pn uint32
+	for i := 0; i < pnLen; i++ {
+		packet[pos+i] ^= mask[1+i]
+		pn = pn<<8 | uint32(packet[pos+i])
+	}
+	header := packet[:pos+pnLen]
+	frames, err := k.aead.Open(nil, k.nonce(pn), packet[pos+pnLen:], header)
+	if err != nil {
+		return 0, nil, err
+	}
+	return pn, frames, nil
+}
+
+// ============================================================================
+// FRAMES
+// ============================================================================
+
+// quicAckFrame acknowledges the packet numbers received, in ranges.
+func quicAckFrame(pns []uint64) []byte {
+	sorted := slices.Clone(pns)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	slices.Reverse(sorted)
+
+	// Ranges of consecutive numbers, largest first
+	type span struct{ hi, lo uint64 }
+	var spans []span
+	for _, pn := range sorted {
+		if n := len(spans); n > 0 && spans[n-1].lo == pn+1 {
+			spans[n-1].lo = pn
+			continue
+		}
+		spans = append(spans, span{pn, pn})
+	}
+	f := []byte{QUIC_FRAME_ACK}
+	f = appendVarint(f, spans[0].hi)
+	f = appendVarint(f, 0) // ack delay
+	f = appendVarint(f, uint64(len(spans)-1))
+	f = appendVarint(f, spans[0].hi-spans[0].lo)
+	for i := 1; i < len(spans); i++ {
+		f = appendVarint(f, spans[i-1].lo-spans[i].hi-2) // gap
+		f = appendVarint(f, spans[i].hi-spans[i].lo)
+	}
+	return f
+}
+
+// quicLost counts the packet numbers below the largest received that never
+// arrived: the client numbers its packets without gaps.
+func quicLost(pns []uint64) int {
+	received := slices.Clone(pns)
+	slices.Sort(received)
+	received = slices.Compact(received)
+	return int(received[len(received)-1]) + 1 - len(received)
+}
+
+// skipQUICAck returns what follows an ACK frame's body.
+func skipQUICAck(b []byte) ([]byte, error) {
+	var fields [4]uint64
+	for i := range fields {
+		v, n := readVarint(b)
+		if n == 0 {
+			return nil, errors.New("truncated ACK frame")
+		}
+		fields[i], b = v, b[n:]
+	}
+	for i := uint64(0); i < 2*fields[2]; i++ {
+		_, n := readVarint(b)
+		if n == 0 {
+			return nil, errors.New("truncated ACK frame")
+		}
+		b = b[n:]
+	}
+	return b, nil
+}
+
+func quicCryptoFrame(offset uint64, data []byte) []byte {
+	f := appendVarint([]byte{QUIC_FRAME_CRYPTO}, offset)
+	f = appendVarint(f, uint64(len(data)))
+	return append(f, data...)
+}
+
+// appendVarint appends v as a QUIC variable-length integer (RFC 9000 16).
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return binary.BigEndian.AppendUint16(b, uint16(v)|0x4000)
+	case v < 1<<30:
+		return binary.BigEndian.AppendUint32(b, uint32(v)|0x80000000)
+	default:
+		return binary.BigEndian.AppendUint64(b, v|0xc000000000000000)
+	}
+}
+
+// readVarint reads a QUIC variable-length integer; n is 0 when b is short.
+func readVarint(b []byte) (v uint64, n int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, 0
+	}
+	v = uint64(b[0] & 0x3f)
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+func uint24(b []byte) int {
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+}
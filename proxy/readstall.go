@@ -0,0 +1,134 @@
+/*
+Sentinel-PQC Proxy - Read Stall Detection
+=========================================
+On the server side a PMTUD black hole looks like a ClientHello that starts
+arriving and then stops: whatever fits the path gets through, the full-size
+segments are dropped without an ICMP and the client retransmits into the
+void. A plain read timeout hides that. The proxy counts every byte received
+while the hello is read and, when the read times out after a partial flight,
+saves a SUSPECTED_BLACKHOLE report with the bytes received before the stall,
+the length of the silence and the kernel's out-of-order counter (segments
+that arrived beyond the missing one). Out-of-order segments alone count too:
+when the very first segment is dropped nothing reaches the reader at all.
+
+Unframed key-share scenarios are read until a whole public key is in;
+framed ones (TLS records, SSH, SMTP) know their own length.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const READ_STALL_SILENCE = 3 * time.Second // no bytes for this long after a partial flight
+
+// ReadStall is what arrived before a hello went silent.
+type ReadStall struct {
+	BytesReceived int     `json:"bytes_received"`
+	ExpectedBytes int     `json:"expected_bytes,omitempty"`
+	SilenceMs     float64 `json:"silence_ms"`
+	OutOfOrder    int     `json:"out_of_order_segments"`
+}
+
+// countingConn records what arrives while the hello is read.
+type countingConn struct {
+	net.Conn
+	received int
+	first    time.Time // first byte, for the time to first byte
+	last     time.Time
+}
+
+func (c *countingConn) NetConn() net.Conn { return c.Conn }
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.received += n
+		c.last = time.Now()
+		if c.first.IsZero() {
+			c.first = c.last
+		}
+	}
+	return n, err
+}
+
+// readKeyShare keeps reading an unframed hello until it holds a whole
+// public key, allowing READ_STALL_SILENCE between segments.
+func readKeyShare(conn net.Conn, data []byte, keySize int) ([]byte, error) {
+	if len(data) >= keySize {
+		return data, nil
+	}
+	defer conn.SetReadDeadline(time.Now().Add(10 * time.Second)) // back to the hello timeout
+
+	buffer := make([]byte, 4096)
+	for len(data) < keySize {
+		conn.SetReadDeadline(time.Now().Add(READ_STALL_SILENCE))
+		n, err := conn.Read(buffer)
+		data = append(data, buffer[:n]...)
+		if err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// readStalled reports whether a hello read went silent after a partial
+// flight, or after segments that could not be delivered for a missing one.
+func readStalled(err error, conn *countingConn) bool {
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		return false
+	}
+	if conn.received > 0 {
+		return true
+	}
+	stats, err := connTCPStats(conn)
+	return err == nil && stats.OutOfOrder > 0
+}
+
+// reportReadStall saves the report for a hello that stalled mid-transfer.
+func reportReadStall(conn *countingConn, scheme kem.Scheme, expected int, profile listenerProfile, lg connLog) {
+	clientIP := conn.RemoteAddr().String()
+	stall := &ReadStall{
+		BytesReceived: conn.received,
+		ExpectedBytes: expected,
+	}
+	if !conn.last.IsZero() {
+		stall.SilenceMs = durationMs(time.Since(conn.last))
+	}
+	report := GhostReport{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		ConnID:        lg.id,
+		ClientIP:      clientIP,
+		Algorithm:     scheme.Name(),
+		PublicKeySize: scheme.PublicKeySize(),
+		HandshakeSize: conn.received,
+		Status:        "SUSPECTED_BLACKHOLE",
+		Message:       fmt.Sprintf("Handshake stalled after %d bytes: the rest of the flight never arrived (PMTUD black hole suspected)", conn.received),
+		MTUBudget:     profile.budgetFor(clientIP, lg.Logger),
+		Listener:      profile.Addr,
+		Link:          profile.Link,
+		ReadStall:     stall,
+		profile:       profile,
+		log:           lg,
+	}
+	lg.Warn("hello stalled mid-transfer", "bytes", conn.received, "silence_ms", stall.SilenceMs)
+
+	attachTCPStats(&report, conn)
+	if report.TCPStats != nil {
+		stall.OutOfOrder = report.TCPStats.OutOfOrder
+		if stall.OutOfOrder > 0 {
+			report.addNote(fmt.Sprintf("%d later segment(s) arrived beyond the gap, so the client kept sending while earlier ones were lost.", stall.OutOfOrder))
+		}
+	}
+	attachSocketBuffers(&report, netConn(conn))
+	attachWireStats(&report)
+	saveReport(report)
+	logReportSummary(report)
+}
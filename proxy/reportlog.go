@@ -0,0 +1,171 @@
+/*
+Sentinel-PQC Proxy - Report Log
+===============================
+ghost_report.json only ever holds the latest report, so concurrent clients
+and long runs overwrite each other. Every report is therefore also appended
+as one JSON line to an append-only log:
+
+  ghost_reports.jsonl      current log, one GhostReport per line
+  ghost_reports.jsonl.1    previous log after rotation (up to -report-log-keep)
+  ghost_reports.jsonl.2.gz older logs, with -report-log-compress
+
+The log rotates once it would grow past -report-log-max MB, or once it has
+been written to for -report-log-age; -retention deletes rotated logs older
+than that (retention.go). The dashboard or `tail -f ghost_reports.jsonl | jq`
+can follow the log as a stream. An empty -report-log disables it;
+ghost_report.json is still written as a snapshot.
+*/
+
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const REPORT_SNAPSHOT_FILE = "ghost_report.json"
+
+// reportLog is the append-only JSONL log shared by all connections.
+var reportLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// appendReportLog writes one report as a JSON line, rotating first if the
+// line would push the log past its size limit.
+func appendReportLog(report GhostReport) error {
+	if *reportLogFile == "" {
+		return nil
+	}
+	line, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	reportLog.mu.Lock()
+	defer reportLog.mu.Unlock()
+	if reportLog.file == nil {
+		if err := openReportLog(); err != nil {
+			return err
+		}
+	}
+	limit := int64(*reportLogMax) << 20
+	full := limit > 0 && reportLog.size+int64(len(line)) > limit
+	old := *reportLogAge > 0 && time.Since(reportLog.opened) > *reportLogAge
+	if reportLog.size > 0 && (full || old) {
+		if err := rotateReportLog(); err != nil {
+			return err
+		}
+	}
+	n, err := reportLog.file.Write(line)
+	reportLog.size += int64(n)
+	return err
+}
+
+func openReportLog() error {
+	f, err := os.OpenFile(*reportLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	reportLog.file, reportLog.size, reportLog.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// rotateReportLog shifts log.N-1 -> log.N ... log -> log.1 and starts a new
+// log, dropping the oldest beyond -report-log-keep.
+func rotateReportLog() error {
+	reportLog.file.Close()
+	reportLog.file = nil
+	keep := max(*reportLogKeep, 1)
+	removeRotatedLog(keep)
+	for i := keep - 1; i >= 1; i-- {
+		if name := rotatedLogName(i); name != "" {
+			os.Rename(name, rotatedLogPath(i+1, name))
+		}
+	}
+	if err := os.Rename(*reportLogFile, *reportLogFile+".1"); err != nil {
+		return err
+	}
+	if *reportLogGzip {
+		if err := gzipFile(*reportLogFile + ".1"); err != nil {
+			slog.Error("failed to compress rotated report log", "file", *reportLogFile+".1", "err", err)
+		}
+	}
+	slog.Info("report log rotated", "file", *reportLogFile, "kept", keep, "compressed", *reportLogGzip)
+	return openReportLog()
+}
+
+// rotatedLogName returns the existing file of rotation i (log.i or
+// log.i.gz), or "" if there is none.
+func rotatedLogName(i int) string {
+	for _, name := range []string{fmt.Sprintf("%s.%d", *reportLogFile, i), fmt.Sprintf("%s.%d.gz", *reportLogFile, i)} {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// rotatedLogPath names rotation i in the same format as an existing file.
+func rotatedLogPath(i int, existing string) string {
+	name := fmt.Sprintf("%s.%d", *reportLogFile, i)
+	if strings.HasSuffix(existing, ".gz") {
+		name += ".gz"
+	}
+	return name
+}
+
+func removeRotatedLog(i int) {
+	os.Remove(fmt.Sprintf("%s.%d", *reportLogFile, i))
+	os.Remove(fmt.Sprintf("%s.%d.gz", *reportLogFile, i))
+}
+
+// gzipFile replaces name with name.gz, keeping its modification time so
+// -retention still ages it by its last report.
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	os.Chtimes(name+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
@@ -0,0 +1,759 @@
+// Sentinel-PQC report API
+//
+// The proxy serves this with -grpc. Regenerate the Go code after editing:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative reports.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.27.2
+// source: reports.proto
+
+package reportpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StreamReportsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Only reports with one of these statuses; empty streams all.
+	Statuses []string `protobuf:"bytes,1,rep,name=statuses,proto3" json:"statuses,omitempty"`
+}
+
+func (x *StreamReportsRequest) Reset() {
+	*x = StreamReportsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_reports_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamReportsRequest) ProtoMessage() {}
+
+func (x *StreamReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reports_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamReportsRequest.ProtoReflect.Descriptor instead.
+func (*StreamReportsRequest) Descriptor() ([]byte, []int) {
+	return file_reports_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamReportsRequest) GetStatuses() []string {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+type QueryReportsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientIp     string `protobuf:"bytes,1,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	Status       string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	SinceSeconds int64  `protobuf:"varint,3,opt,name=since_seconds,json=sinceSeconds,proto3" json:"since_seconds,omitempty"` // only reports from the last N seconds
+	Limit        int32  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`                                   // default 100
+	Algorithm    string `protobuf:"bytes,5,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Offset       int32  `protobuf:"varint,6,opt,name=offset,proto3" json:"offset,omitempty"` // reports to skip, for paging
+}
+
+func (x *QueryReportsRequest) Reset() {
+	*x = QueryReportsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_reports_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QueryReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryReportsRequest) ProtoMessage() {}
+
+func (x *QueryReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reports_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryReportsRequest.ProtoReflect.Descriptor instead.
+func (*QueryReportsRequest) Descriptor() ([]byte, []int) {
+	return file_reports_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *QueryReportsRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *QueryReportsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *QueryReportsRequest) GetSinceSeconds() int64 {
+	if x != nil {
+		return x.SinceSeconds
+	}
+	return 0
+}
+
+func (x *QueryReportsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *QueryReportsRequest) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *QueryReportsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type QueryReportsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reports []*GhostReport `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+}
+
+func (x *QueryReportsResponse) Reset() {
+	*x = QueryReportsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_reports_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QueryReportsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryReportsResponse) ProtoMessage() {}
+
+func (x *QueryReportsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_reports_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryReportsResponse.ProtoReflect.Descriptor instead.
+func (*QueryReportsResponse) Descriptor() ([]byte, []int) {
+	return file_reports_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *QueryReportsResponse) GetReports() []*GhostReport {
+	if x != nil {
+		return x.Reports
+	}
+	return nil
+}
+
+type PushReportsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Probe       string   `protobuf:"bytes,1,opt,name=probe,proto3" json:"probe,omitempty"` // for reports without a probe of their own
+	Site        string   `protobuf:"bytes,2,opt,name=site,proto3" json:"site,omitempty"`
+	ReportsJson []string `protobuf:"bytes,3,rep,name=reports_json,json=reportsJson,proto3" json:"reports_json,omitempty"` // complete reports, as in report_json
+}
+
+func (x *PushReportsRequest) Reset() {
+	*x = PushReportsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_reports_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PushReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushReportsRequest) ProtoMessage() {}
+
+func (x *PushReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_reports_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushReportsRequest.ProtoReflect.Descriptor instead.
+func (*PushReportsRequest) Descriptor() ([]byte, []int) {
+	return file_reports_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PushReportsRequest) GetProbe() string {
+	if x != nil {
+		return x.Probe
+	}
+	return ""
+}
+
+func (x *PushReportsRequest) GetSite() string {
+	if x != nil {
+		return x.Site
+	}
+	return ""
+}
+
+func (x *PushReportsRequest) GetReportsJson() []string {
+	if x != nil {
+		return x.ReportsJson
+	}
+	return nil
+}
+
+type PushReportsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Accepted int32 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+}
+
+func (x *PushReportsResponse) Reset() {
+	*x = PushReportsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_reports_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PushReportsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushReportsResponse) ProtoMessage() {}
+
+func (x *PushReportsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_reports_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushReportsResponse.ProtoReflect.Descriptor instead.
+func (*PushReportsResponse) Descriptor() ([]byte, []int) {
+	return file_reports_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PushReportsResponse) GetAccepted() int32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+// GhostReport carries the fields most integrations filter on; report_json
+// is the complete report as written to ghost_reports.jsonl.
+type GhostReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timestamp             string  `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // RFC 3339
+	ConnId                string  `protobuf:"bytes,2,opt,name=conn_id,json=connId,proto3" json:"conn_id,omitempty"`
+	ClientIp              string  `protobuf:"bytes,3,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	Algorithm             string  `protobuf:"bytes,4,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	PublicKeySize         int32   `protobuf:"varint,5,opt,name=public_key_size,json=publicKeySize,proto3" json:"public_key_size,omitempty"`
+	HandshakeSizeBytes    int32   `protobuf:"varint,6,opt,name=handshake_size_bytes,json=handshakeSizeBytes,proto3" json:"handshake_size_bytes,omitempty"`
+	FragmentationRisk     bool    `protobuf:"varint,7,opt,name=fragmentation_risk,json=fragmentationRisk,proto3" json:"fragmentation_risk,omitempty"`
+	Ipv6FragmentationRisk bool    `protobuf:"varint,8,opt,name=ipv6_fragmentation_risk,json=ipv6FragmentationRisk,proto3" json:"ipv6_fragmentation_risk,omitempty"`
+	Status                string  `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`
+	Message               string  `protobuf:"bytes,10,opt,name=message,proto3" json:"message,omitempty"`
+	MtuBudgetBytes        int32   `protobuf:"varint,11,opt,name=mtu_budget_bytes,json=mtuBudgetBytes,proto3" json:"mtu_budget_bytes,omitempty"`
+	Listener              string  `protobuf:"bytes,12,opt,name=listener,proto3" json:"listener,omitempty"`
+	Transport             string  `protobuf:"bytes,13,opt,name=transport,proto3" json:"transport,omitempty"`
+	HandshakeDurationMs   float64 `protobuf:"fixed64,14,opt,name=handshake_duration_ms,json=handshakeDurationMs,proto3" json:"handshake_duration_ms,omitempty"`
+	ServerHelloSizeBytes  int32   `protobuf:"varint,15,opt,name=server_hello_size_bytes,json=serverHelloSizeBytes,proto3" json:"server_hello_size_bytes,omitempty"`
+	ReportJson            string  `protobuf:"bytes,16,opt,name=report_json,json=reportJson,proto3" json:"report_json,omitempty"`
+	SchemaVersion         int32   `protobuf:"varint,17,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"` // of report_json, see schema.go
+	Severity              string  `protobuf:"bytes,18,opt,name=severity,proto3" json:"severity,omitempty"`                                 // SAFE ... BLACKHOLE_SUSPECTED, see severity.go
+	Probe                 string  `protobuf:"bytes,19,opt,name=probe,proto3" json:"probe,omitempty"`                                       // proxy that saw the handshake, see collector.go
+	Site                  string  `protobuf:"bytes,20,opt,name=site,proto3" json:"site,omitempty"`
+}
+
+func (x *GhostReport) Reset() {
+	*x = GhostReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_reports_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GhostReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GhostReport) ProtoMessage() {}
+
+func (x *GhostReport) ProtoReflect() protoreflect.Message {
+	mi := &file_reports_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GhostReport.ProtoReflect.Descriptor instead.
+func (*GhostReport) Descriptor() ([]byte, []int) {
+	return file_reports_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GhostReport) GetTimestamp() string {
+	if x != nil {
+		return x.Timestamp
+	}
+	return ""
+}
+
+func (x *GhostReport) GetConnId() string {
+	if x != nil {
+		return x.ConnId
+	}
+	return ""
+}
+
+func (x *GhostReport) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *GhostReport) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *GhostReport) GetPublicKeySize() int32 {
+	if x != nil {
+		return x.PublicKeySize
+	}
+	return 0
+}
+
+func (x *GhostReport) GetHandshakeSizeBytes() int32 {
+	if x != nil {
+		return x.HandshakeSizeBytes
+	}
+	return 0
+}
+
+func (x *GhostReport) GetFragmentationRisk() bool {
+	if x != nil {
+		return x.FragmentationRisk
+	}
+	return false
+}
+
+func (x *GhostReport) GetIpv6FragmentationRisk() bool {
+	if x != nil {
+		return x.Ipv6FragmentationRisk
+	}
+	return false
+}
+
+func (x *GhostReport) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *GhostReport) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *GhostReport) GetMtuBudgetBytes() int32 {
+	if x != nil {
+		return x.MtuBudgetBytes
+	}
+	return 0
+}
+
+func (x *GhostReport) GetListener() string {
+	if x != nil {
+		return x.Listener
+	}
+	return ""
+}
+
+func (x *GhostReport) GetTransport() string {
+	if x != nil {
+		return x.Transport
+	}
+	return ""
+}
+
+func (x *GhostReport) GetHandshakeDurationMs() float64 {
+	if x != nil {
+		return x.HandshakeDurationMs
+	}
+	return 0
+}
+
+func (x *GhostReport) GetServerHelloSizeBytes() int32 {
+	if x != nil {
+		return x.ServerHelloSizeBytes
+	}
+	return 0
+}
+
+func (x *GhostReport) GetReportJson() string {
+	if x != nil {
+		return x.ReportJson
+	}
+	return ""
+}
+
+func (x *GhostReport) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *GhostReport) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *GhostReport) GetProbe() string {
+	if x != nil {
+		return x.Probe
+	}
+	return ""
+}
+
+func (x *GhostReport) GetSite() string {
+	if x != nil {
+		return x.Site
+	}
+	return ""
+}
+
+var File_reports_proto protoreflect.FileDescriptor
+
+var file_reports_proto_rawDesc = []byte{
+	0x0a, 0x0d, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x0f, 0x73, 0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e, 0x70, 0x71, 0x63, 0x2e, 0x76, 0x31,
+	0x22, 0x32, 0x0a, 0x14, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x65, 0x73, 0x22, 0xbb, 0x01, 0x0a, 0x13, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65,
+	0x70, 0x6f, 0x72, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09,
+	0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1c, 0x0a, 0x09,
+	0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66,
+	0x66, 0x73, 0x65, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x22, 0x4e, 0x0a, 0x14, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x70, 0x6f, 0x72,
+	0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a, 0x07, 0x72, 0x65,
+	0x70, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x73, 0x65,
+	0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e, 0x70, 0x71, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x68,
+	0x6f, 0x73, 0x74, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x07, 0x72, 0x65, 0x70, 0x6f, 0x72,
+	0x74, 0x73, 0x22, 0x61, 0x0a, 0x12, 0x50, 0x75, 0x73, 0x68, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x62,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x73, 0x69, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x69,
+	0x74, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x5f, 0x6a, 0x73,
+	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74,
+	0x73, 0x4a, 0x73, 0x6f, 0x6e, 0x22, 0x31, 0x0a, 0x13, 0x50, 0x75, 0x73, 0x68, 0x52, 0x65, 0x70,
+	0x6f, 0x72, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08,
+	0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08,
+	0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x22, 0xcf, 0x05, 0x0a, 0x0b, 0x47, 0x68, 0x6f,
+	0x73, 0x74, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x17, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x6e, 0x5f, 0x69,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x6f, 0x6e, 0x6e, 0x49, 0x64, 0x12,
+	0x1b, 0x0a, 0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x70, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x70, 0x12, 0x1c, 0x0a, 0x09,
+	0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x26, 0x0a, 0x0f, 0x70, 0x75,
+	0x62, 0x6c, 0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0d, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x53, 0x69,
+	0x7a, 0x65, 0x12, 0x30, 0x0a, 0x14, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x5f,
+	0x73, 0x69, 0x7a, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x12, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x42,
+	0x79, 0x74, 0x65, 0x73, 0x12, 0x2d, 0x0a, 0x12, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x69, 0x73, 0x6b, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x11, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x69, 0x73, 0x6b, 0x12, 0x36, 0x0a, 0x17, 0x69, 0x70, 0x76, 0x36, 0x5f, 0x66, 0x72, 0x61, 0x67,
+	0x6d, 0x65, 0x6e, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x69, 0x73, 0x6b, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x15, 0x69, 0x70, 0x76, 0x36, 0x46, 0x72, 0x61, 0x67, 0x6d, 0x65,
+	0x6e, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x69, 0x73, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x0a,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x28, 0x0a,
+	0x10, 0x6d, 0x74, 0x75, 0x5f, 0x62, 0x75, 0x64, 0x67, 0x65, 0x74, 0x5f, 0x62, 0x79, 0x74, 0x65,
+	0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x6d, 0x74, 0x75, 0x42, 0x75, 0x64, 0x67,
+	0x65, 0x74, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x65, 0x72, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x65, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72,
+	0x74, 0x12, 0x32, 0x0a, 0x15, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x5f, 0x64,
+	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6d, 0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x13, 0x68, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x44, 0x75, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x4d, 0x73, 0x12, 0x35, 0x0a, 0x17, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x5f,
+	0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73,
+	0x18, 0x0f, 0x20, 0x01, 0x28, 0x05, 0x52, 0x14, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x48, 0x65,
+	0x6c, 0x6c, 0x6f, 0x53, 0x69, 0x7a, 0x65, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b,
+	0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x6a, 0x73, 0x6f, 0x6e, 0x18, 0x10, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x4a, 0x73, 0x6f, 0x6e, 0x12, 0x25, 0x0a,
+	0x0e, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
+	0x11, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79,
+	0x18, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79,
+	0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x18, 0x13, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x70, 0x72, 0x6f, 0x62, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x74, 0x65, 0x18, 0x14,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x73, 0x69, 0x74, 0x65, 0x32, 0x9e, 0x02, 0x0a, 0x0d, 0x52,
+	0x65, 0x70, 0x6f, 0x72, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x56, 0x0a, 0x0d,
+	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x12, 0x25, 0x2e,
+	0x73, 0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e, 0x70, 0x71, 0x63, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x73, 0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e,
+	0x70, 0x71, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x68, 0x6f, 0x73, 0x74, 0x52, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x30, 0x01, 0x12, 0x5b, 0x0a, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x70,
+	0x6f, 0x72, 0x74, 0x73, 0x12, 0x24, 0x2e, 0x73, 0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e,
+	0x70, 0x71, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x73, 0x65, 0x6e,
+	0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e, 0x70, 0x71, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x51, 0x75, 0x65,
+	0x72, 0x79, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x58, 0x0a, 0x0b, 0x50, 0x75, 0x73, 0x68, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73,
+	0x12, 0x23, 0x2e, 0x73, 0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2e, 0x70, 0x71, 0x63, 0x2e,
+	0x76, 0x31, 0x2e, 0x50, 0x75, 0x73, 0x68, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x73, 0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c,
+	0x2e, 0x70, 0x71, 0x63, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x75, 0x73, 0x68, 0x52, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x1d, 0x5a, 0x1b, 0x73,
+	0x65, 0x6e, 0x74, 0x69, 0x6e, 0x65, 0x6c, 0x2d, 0x70, 0x71, 0x63, 0x2d, 0x70, 0x72, 0x6f, 0x78,
+	0x79, 0x2f, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
+}
+
+var (
+	file_reports_proto_rawDescOnce sync.Once
+	file_reports_proto_rawDescData = file_reports_proto_rawDesc
+)
+
+func file_reports_proto_rawDescGZIP() []byte {
+	file_reports_proto_rawDescOnce.Do(func() {
+		file_reports_proto_rawDescData = protoimpl.X.CompressGZIP(file_reports_proto_rawDescData)
+	})
+	return file_reports_proto_rawDescData
+}
+
+var file_reports_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_reports_proto_goTypes = []any{
+	(*StreamReportsRequest)(nil), // 0: sentinel.pqc.v1.StreamReportsRequest
+	(*QueryReportsRequest)(nil),  // 1: sentinel.pqc.v1.QueryReportsRequest
+	(*QueryReportsResponse)(nil), // 2: sentinel.pqc.v1.QueryReportsResponse
+	(*PushReportsRequest)(nil),   // 3: sentinel.pqc.v1.PushReportsRequest
+	(*PushReportsResponse)(nil),  // 4: sentinel.pqc.v1.PushReportsResponse
+	(*GhostReport)(nil),          // 5: sentinel.pqc.v1.GhostReport
+}
+var file_reports_proto_depIdxs = []int32{
+	5, // 0: sentinel.pqc.v1.QueryReportsResponse.reports:type_name -> sentinel.pqc.v1.GhostReport
+	0, // 1: sentinel.pqc.v1.ReportService.StreamReports:input_type -> sentinel.pqc.v1.StreamReportsRequest
+	1, // 2: sentinel.pqc.v1.ReportService.QueryReports:input_type -> sentinel.pqc.v1.QueryReportsRequest
+	3, // 3: sentinel.pqc.v1.ReportService.PushReports:input_type -> sentinel.pqc.v1.PushReportsRequest
+	5, // 4: sentinel.pqc.v1.ReportService.StreamReports:output_type -> sentinel.pqc.v1.GhostReport
+	2, // 5: sentinel.pqc.v1.ReportService.QueryReports:output_type -> sentinel.pqc.v1.QueryReportsResponse
+	4, // 6: sentinel.pqc.v1.ReportService.PushReports:output_type -> sentinel.pqc.v1.PushReportsResponse
+	4, // [4:7] is the sub-list for method output_type
+	1, // [1:4] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_reports_proto_init() }
+func file_reports_proto_init() {
+	if File_reports_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_reports_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*StreamReportsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_reports_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*QueryReportsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_reports_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*QueryReportsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_reports_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*PushReportsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_reports_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*PushReportsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_reports_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*GhostReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_reports_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_reports_proto_goTypes,
+		DependencyIndexes: file_reports_proto_depIdxs,
+		MessageInfos:      file_reports_proto_msgTypes,
+	}.Build()
+	File_reports_proto = out.File
+	file_reports_proto_rawDesc = nil
+	file_reports_proto_goTypes = nil
+	file_reports_proto_depIdxs = nil
+}
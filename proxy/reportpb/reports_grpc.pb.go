@@ -0,0 +1,231 @@
+// Sentinel-PQC report API
+//
+// The proxy serves this with -grpc. Regenerate the Go code after editing:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative reports.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.2
+// source: reports.proto
+
+package reportpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	ReportService_StreamReports_FullMethodName = "/sentinel.pqc.v1.ReportService/StreamReports"
+	ReportService_QueryReports_FullMethodName  = "/sentinel.pqc.v1.ReportService/QueryReports"
+	ReportService_PushReports_FullMethodName   = "/sentinel.pqc.v1.ReportService/PushReports"
+)
+
+// ReportServiceClient is the client API for ReportService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReportServiceClient interface {
+	// StreamReports sends every new report as the proxy produces it.
+	StreamReports(ctx context.Context, in *StreamReportsRequest, opts ...grpc.CallOption) (ReportService_StreamReportsClient, error)
+	// QueryReports returns stored reports, newest first, from the -sqlite or
+	// -postgres store or else the report log.
+	QueryReports(ctx context.Context, in *QueryReportsRequest, opts ...grpc.CallOption) (*QueryReportsResponse, error)
+	// PushReports stores reports of other proxies; only a collector
+	// (go run . collector) accepts them.
+	PushReports(ctx context.Context, in *PushReportsRequest, opts ...grpc.CallOption) (*PushReportsResponse, error)
+}
+
+type reportServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReportServiceClient(cc grpc.ClientConnInterface) ReportServiceClient {
+	return &reportServiceClient{cc}
+}
+
+func (c *reportServiceClient) StreamReports(ctx context.Context, in *StreamReportsRequest, opts ...grpc.CallOption) (ReportService_StreamReportsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReportService_ServiceDesc.Streams[0], ReportService_StreamReports_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &reportServiceStreamReportsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ReportService_StreamReportsClient interface {
+	Recv() (*GhostReport, error)
+	grpc.ClientStream
+}
+
+type reportServiceStreamReportsClient struct {
+	grpc.ClientStream
+}
+
+func (x *reportServiceStreamReportsClient) Recv() (*GhostReport, error) {
+	m := new(GhostReport)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *reportServiceClient) QueryReports(ctx context.Context, in *QueryReportsRequest, opts ...grpc.CallOption) (*QueryReportsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryReportsResponse)
+	err := c.cc.Invoke(ctx, ReportService_QueryReports_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reportServiceClient) PushReports(ctx context.Context, in *PushReportsRequest, opts ...grpc.CallOption) (*PushReportsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PushReportsResponse)
+	err := c.cc.Invoke(ctx, ReportService_PushReports_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReportServiceServer is the server API for ReportService service.
+// All implementations must embed UnimplementedReportServiceServer
+// for forward compatibility
+type ReportServiceServer interface {
+	// StreamReports sends every new report as the proxy produces it.
+	StreamReports(*StreamReportsRequest, ReportService_StreamReportsServer) error
+	// QueryReports returns stored reports, newest first, from the -sqlite or
+	// -postgres store or else the report log.
+	QueryReports(context.Context, *QueryReportsRequest) (*QueryReportsResponse, error)
+	// PushReports stores reports of other proxies; only a collector
+	// (go run . collector) accepts them.
+	PushReports(context.Context, *PushReportsRequest) (*PushReportsResponse, error)
+	mustEmbedUnimplementedReportServiceServer()
+}
+
+// UnimplementedReportServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedReportServiceServer struct {
+}
+
+func (UnimplementedReportServiceServer) StreamReports(*StreamReportsRequest, ReportService_StreamReportsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReports not implemented")
+}
+func (UnimplementedReportServiceServer) QueryReports(context.Context, *QueryReportsRequest) (*QueryReportsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryReports not implemented")
+}
+func (UnimplementedReportServiceServer) PushReports(context.Context, *PushReportsRequest) (*PushReportsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushReports not implemented")
+}
+func (UnimplementedReportServiceServer) mustEmbedUnimplementedReportServiceServer() {}
+
+// UnsafeReportServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReportServiceServer will
+// result in compilation errors.
+type UnsafeReportServiceServer interface {
+	mustEmbedUnimplementedReportServiceServer()
+}
+
+func RegisterReportServiceServer(s grpc.ServiceRegistrar, srv ReportServiceServer) {
+	s.RegisterService(&ReportService_ServiceDesc, srv)
+}
+
+func _ReportService_StreamReports_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamReportsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReportServiceServer).StreamReports(m, &reportServiceStreamReportsServer{ServerStream: stream})
+}
+
+type ReportService_StreamReportsServer interface {
+	Send(*GhostReport) error
+	grpc.ServerStream
+}
+
+type reportServiceStreamReportsServer struct {
+	grpc.ServerStream
+}
+
+func (x *reportServiceStreamReportsServer) Send(m *GhostReport) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ReportService_QueryReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReportServiceServer).QueryReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReportService_QueryReports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReportServiceServer).QueryReports(ctx, req.(*QueryReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReportService_PushReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReportServiceServer).PushReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReportService_PushReports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReportServiceServer).PushReports(ctx, req.(*PushReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ReportService_ServiceDesc is the grpc.ServiceDesc for ReportService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReportService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sentinel.pqc.v1.ReportService",
+	HandlerType: (*ReportServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueryReports",
+			Handler:    _ReportService_QueryReports_Handler,
+		},
+		{
+			MethodName: "PushReports",
+			Handler:    _ReportService_PushReports_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReports",
+			Handler:       _ReportService_StreamReports_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "reports.proto",
+}
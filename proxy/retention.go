@@ -0,0 +1,74 @@
+/*
+Sentinel-PQC Proxy - Retention
+==============================
+Long-running proxies keep their history bounded with -retention:
+
+  go run . -sqlite reports.db -retention 720h -report-log-age 24h -report-log-compress
+
+Every RETENTION_INTERVAL (and once at startup) rotated report logs whose last
+report is older than -retention are deleted, and the -sqlite and -postgres
+sinks delete their reports older than that. The current report log is never
+deleted; rotate it by age with -report-log-age. -report-log-keep still caps
+the number of rotated logs on top of the age limit.
+*/
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+const RETENTION_INTERVAL = time.Hour
+
+// prunableSink is a sink that can delete reports older than a cutoff.
+type prunableSink interface {
+	reportSink
+	Prune(before time.Time) (int64, error)
+}
+
+// runRetention enforces -retention until the proxy exits.
+func runRetention(keep time.Duration) {
+	slog.Info("retention enabled", "keep", keep)
+	for {
+		enforceRetention(time.Now().Add(-keep))
+		time.Sleep(RETENTION_INTERVAL)
+	}
+}
+
+func enforceRetention(before time.Time) {
+	if *reportLogFile != "" {
+		reportLog.mu.Lock()
+		for i := 1; i <= max(*reportLogKeep, 1); i++ {
+			name := rotatedLogName(i)
+			if name == "" {
+				continue
+			}
+			if info, err := os.Stat(name); err == nil && info.ModTime().Before(before) {
+				if err := os.Remove(name); err != nil {
+					slog.Error("retention: cannot delete report log", "file", name, "err", err)
+				} else {
+					slog.Info("retention: deleted report log", "file", name, "last_report", info.ModTime().Format(time.RFC3339))
+				}
+			}
+		}
+		reportLog.mu.Unlock()
+	}
+
+	reportSinksMu.Lock()
+	sinks := append([]reportSink(nil), reportSinks...)
+	reportSinksMu.Unlock()
+	for _, s := range sinks {
+		p, ok := s.(prunableSink)
+		if !ok {
+			continue
+		}
+		n, err := p.Prune(before)
+		if err != nil {
+			slog.Error("retention: pruning failed", "sink", s.Name(), "err", err)
+		} else if n > 0 {
+			slog.Info("retention: deleted old reports", "sink", s.Name(), "reports", n)
+		}
+	}
+}
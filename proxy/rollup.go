@@ -0,0 +1,298 @@
+/*
+Sentinel-PQC Proxy - Scheduled Rollups
+======================================
+-rollup writes a summary of the previous day and/or week, for the people who
+want one number a morning rather than a dashboard:
+
+  go run . -sqlite reports.db -rollup daily,weekly -rollup-notify email,webhook
+
+  rollups/daily-2026-10-15.json     written just after midnight UTC
+  rollups/weekly-2026-W42.json      written just after midnight on Monday
+
+  {"period":"daily","from":"2026-10-15T00:00:00Z","to":"2026-10-16T00:00:00Z",
+   "handshakes":9812,"detections":1337,"detection_rate":0.1363,
+   "algorithms":[{"name":"Kyber768","reports":9812,"detections":1337}],
+   "subnets":[{"name":"10.0.0.0/24","reports":4100,"detections":1201}, ...],
+   "size":{"p50":1216,...},"size_histogram":[{"range":"1280-1399","count":812}, ...]}
+
+Rollups are built from the report history (-sqlite, -postgres or the report
+log), so they cover the whole period even across restarts. Algorithms and
+subnets are ranked by detections. -rollup-notify also mails the rollup
+through the -email-to settings and/or POSTs it to -webhook as event ROLLUP.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ROLLUP_MAX_REPORTS = 1000000 // per period; the rollup is marked truncated beyond
+	ROLLUP_TOP         = 10
+)
+
+// rollupBins are the upper bounds of the size histogram, at the MTUs that
+// matter: the IPv6 minimum, typical tunnel and VPN budgets, Ethernet.
+var rollupBins = []int{1024, 1280, 1400, 1500, 2048, 4096}
+
+// HistogramBin counts handshakes in one size range.
+type HistogramBin struct {
+	Range string `json:"range"` // "1280-1399", "4096+"
+	Count int    `json:"count"`
+}
+
+// Rollup summarises one day or week of reports.
+type Rollup struct {
+	Period        string         `json:"period"` // daily or weekly
+	From          string         `json:"from"`
+	To            string         `json:"to"`
+	Generated     string         `json:"generated"`
+	Store         string         `json:"store"`
+	Handshakes    int            `json:"handshakes"`
+	Detections    int            `json:"detections"`
+	DetectionRate float64        `json:"detection_rate"`
+	Statuses      map[string]int `json:"statuses"`
+	Algorithms    []GroupStats   `json:"algorithms"` // most detections first
+	Subnets       []GroupStats   `json:"subnets"`    // most detections first, at most ROLLUP_TOP
+	Size          SizeStats      `json:"size"`
+	SizeHistogram []HistogramBin `json:"size_histogram"`
+	Truncated     bool           `json:"truncated,omitempty"` // more than ROLLUP_MAX_REPORTS
+}
+
+// ============================================================================
+// SCHEDULER
+// ============================================================================
+
+// startRollups checks -rollup and -rollup-notify and schedules the rollups.
+func startRollups(periods, notify string) error {
+	if store, _ := historyStore(); store == nil {
+		return fmt.Errorf("-rollup needs a report history (-sqlite, -postgres or -report-log)")
+	}
+	for _, n := range strings.Split(notify, ",") {
+		switch n = strings.TrimSpace(n); n {
+		case "":
+		case "email":
+			if rollupEmail() == nil {
+				return fmt.Errorf("-rollup-notify email needs -smtp and -email-to")
+			}
+		case "webhook":
+			if rollupWebhook() == nil {
+				return fmt.Errorf("-rollup-notify webhook needs -webhook")
+			}
+		default:
+			return fmt.Errorf("unknown -rollup-notify %q (email, webhook)", n)
+		}
+	}
+	var scheduled []string
+	for _, p := range strings.Split(periods, ",") {
+		switch p = strings.TrimSpace(p); p {
+		case "daily", "weekly":
+			go runRollups(p, notify)
+			scheduled = append(scheduled, p)
+		case "":
+		default:
+			return fmt.Errorf("unknown -rollup %q (daily, weekly)", p)
+		}
+	}
+	slog.Info("rollups scheduled", "periods", scheduled, "dir", *rollupDir, "notify", notify)
+	return nil
+}
+
+func runRollups(period, notify string) {
+	for {
+		end := rollupBoundary(period, time.Now().UTC())
+		time.Sleep(time.Until(end))
+		start := end.AddDate(0, 0, -1)
+		if period == "weekly" {
+			start = end.AddDate(0, 0, -7)
+		}
+		rollup, err := buildRollup(period, start, end)
+		if err != nil {
+			slog.Error("rollup failed", "period", period, "err", err)
+			continue
+		}
+		publishRollup(rollup, notify)
+	}
+}
+
+// rollupBoundary is the next midnight UTC, or the next Monday midnight.
+func rollupBoundary(period string, now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if period == "weekly" {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// ============================================================================
+// BUILDING
+// ============================================================================
+
+func buildRollup(period string, from, to time.Time) (Rollup, error) {
+	store, name := historyStore()
+	reports, err := store.query(historyFilter{From: from, To: to, Limit: ROLLUP_MAX_REPORTS})
+	if err != nil {
+		return Rollup{}, err
+	}
+	r := Rollup{
+		Period:     period,
+		From:       from.Format(time.RFC3339),
+		To:         to.Format(time.RFC3339),
+		Generated:  time.Now().UTC().Format(time.RFC3339),
+		Store:      name,
+		Handshakes: len(reports),
+		Statuses:   make(map[string]int),
+		Truncated:  len(reports) == ROLLUP_MAX_REPORTS,
+	}
+	var sizes []int
+	algorithms := make(map[string]*GroupStats)
+	subnets := make(map[string]*GroupStats)
+	bins := make([]int, len(rollupBins)+1)
+	for _, rep := range reports {
+		detection := rep.Status != "SAFE"
+		if detection {
+			r.Detections++
+		}
+		r.Statuses[rep.Status]++
+		countGroup(algorithms, rep.Algorithm, detection)
+		countGroup(subnets, clientSubnet(rep.ClientIP), detection)
+		sizes = append(sizes, rep.HandshakeSize)
+		bins[sort.SearchInts(rollupBins, rep.HandshakeSize+1)]++
+	}
+	r.DetectionRate = detectionRate(r.Detections, r.Handshakes)
+	r.Size = sizeStats(sizes)
+	r.Algorithms = topOffenders(algorithms, 0)
+	r.Subnets = topOffenders(subnets, ROLLUP_TOP)
+	lower := 0
+	for i, n := range bins {
+		bin := HistogramBin{Range: fmt.Sprintf("%d+", lower), Count: n}
+		if i < len(rollupBins) {
+			bin.Range = fmt.Sprintf("%d-%d", lower, rollupBins[i]-1)
+			lower = rollupBins[i]
+		}
+		r.SizeHistogram = append(r.SizeHistogram, bin)
+	}
+	return r, nil
+}
+
+// topOffenders ranks groups by detections, then reports; limit 0 keeps all.
+func topOffenders(groups map[string]*GroupStats, limit int) []GroupStats {
+	list := []GroupStats{}
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Detections != list[j].Detections {
+			return list[i].Detections > list[j].Detections
+		}
+		if list[i].Reports != list[j].Reports {
+			return list[i].Reports > list[j].Reports
+		}
+		return list[i].Name < list[j].Name
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// ============================================================================
+// PUBLISHING
+// ============================================================================
+
+// rollupFile names a rollup after its first day or ISO week.
+func rollupFile(r Rollup) string {
+	from, _ := time.Parse(time.RFC3339, r.From)
+	name := "daily-" + from.Format("2006-01-02") + ".json"
+	if r.Period == "weekly" {
+		year, week := from.ISOWeek()
+		name = fmt.Sprintf("weekly-%d-W%02d.json", year, week)
+	}
+	return filepath.Join(*rollupDir, name)
+}
+
+func publishRollup(r Rollup, notify string) {
+	lg := slog.With("period", r.Period, "from", r.From)
+	data, _ := json.MarshalIndent(r, "", "  ")
+	file := rollupFile(r)
+	if err := os.MkdirAll(*rollupDir, 0755); err != nil {
+		lg.Error("cannot create rollup directory", "err", err)
+	} else if err := os.WriteFile(file, data, 0644); err != nil {
+		lg.Error("cannot write rollup", "file", file, "err", err)
+	}
+	lg.Info("rollup written", "file", file, "handshakes", r.Handshakes, "detections", r.Detections, "detection_rate", r.DetectionRate)
+
+	if strings.Contains(notify, "email") {
+		subject := fmt.Sprintf("%s %s rollup: %d handshakes, %d detection(s)", EMAIL_SUBJECT_PREFIX, r.Period, r.Handshakes, r.Detections)
+		if err := rollupEmail().send(subject, rollupText(r)); err != nil {
+			lg.Error("email: rollup not sent", "err", err)
+		}
+	}
+	if strings.Contains(notify, "webhook") {
+		id := make([]byte, 8)
+		rand.Read(id)
+		delivery := hex.EncodeToString(id)
+		body, _ := json.Marshal(map[string]any{"event": "ROLLUP", "delivery": delivery, "rollup": r})
+		rollupWebhook().deliverBody(lg, "ROLLUP", delivery, body)
+	}
+}
+
+// rollupText is the plain-text body of a rollup mail.
+func rollupText(r Rollup) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Period:       %s to %s (%s)\n", r.From, r.To, r.Period)
+	fmt.Fprintf(&b, "Handshakes:   %d\n", r.Handshakes)
+	fmt.Fprintf(&b, "Detections:   %d (%.2f%%)\n", r.Detections, r.DetectionRate*100)
+	fmt.Fprintf(&b, "By verdict:   %s\n", emailCounts(r.Statuses, 0, ", %s %d"))
+	fmt.Fprintf(&b, "Size:         p50 %d, p95 %d, p99 %d, max %d bytes\n\n", r.Size.P50, r.Size.P95, r.Size.P99, r.Size.Max)
+	b.WriteString("Algorithms (detections / handshakes):\n")
+	for _, g := range r.Algorithms {
+		fmt.Fprintf(&b, "  %-20s %6d / %d\n", g.Name, g.Detections, g.Reports)
+	}
+	b.WriteString("\nTop subnets (detections / handshakes):\n")
+	for _, g := range r.Subnets {
+		fmt.Fprintf(&b, "  %-20s %6d / %d\n", g.Name, g.Detections, g.Reports)
+	}
+	b.WriteString("\nHandshake sizes:\n")
+	for _, bin := range r.SizeHistogram {
+		fmt.Fprintf(&b, "  %-10s %6d\n", bin.Range, bin.Count)
+	}
+	if r.Truncated {
+		fmt.Fprintf(&b, "\nOnly the newest %d reports of the period were counted.\n", ROLLUP_MAX_REPORTS)
+	}
+	return b.String()
+}
+
+func rollupEmail() *emailSink {
+	reportSinksMu.Lock()
+	defer reportSinksMu.Unlock()
+	for _, s := range reportSinks {
+		if e, ok := s.(*emailSink); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func rollupWebhook() *webhookSink {
+	reportSinksMu.Lock()
+	defer reportSinksMu.Unlock()
+	for _, s := range reportSinks {
+		if w, ok := s.(*webhookSink); ok {
+			return w
+		}
+	}
+	return nil
+}
@@ -0,0 +1,500 @@
+/*
+Sentinel-PQC Proxy - PQC Readiness Scanner
+==========================================
+The scan subcommand turns the question around: instead of judging the
+handshakes clients send, it sends one to real TLS servers and reports
+whether each negotiates a post-quantum key exchange and what that costs on
+the wire:
+
+  go run . scan cloudflare.com pq.cloudflareresearch.com 10.0.0.5:8443
+  go run . scan -f hosts.txt -concurrency 20 -json > scan.json
+  go run . scan -groups X25519Kyber768Draft00,x25519 example.com
+
+  target                        version  group                  pqc  hrr  hello  server  flight     ms
+  cloudflare.com:443            TLS1.3   X25519MLKEM768         yes  -     1458    1215    4390     31
+  example.com:443               TLS1.3   x25519                 no   -     1453     127    3805     88
+
+The ClientHello offers the key shares of -groups (a browser's
+X25519MLKEM768 + x25519 by default) and supports the other groups below, so
+a server that wants one of those asks for it with a HelloRetryRequest,
+which the scanner answers. The handshake stops after the server's first
+flight: hello and server are the ClientHello and ServerHello on the wire,
+flight everything the server sent before going quiet (its certificate
+chain included). The ML-KEM shares are Kyber round 3 keys, which have the
+encapsulation key format of ML-KEM; the scanner never decapsulates.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/kem/schemes"
+)
+
+const (
+	SCAN_DEFAULT_PORT = "443"
+	SCAN_FLIGHT_IDLE  = 500 * time.Millisecond // the server's first flight has ended
+	SCAN_MAX_FLIGHT   = 256 * 1024
+
+	TLS_RECORD_CCS   = 0x14
+	TLS_RECORD_ALERT = 0x15
+)
+
+// SCAN_SUPPORTED_GROUPS follow the key shares in supported_groups.
+var SCAN_SUPPORTED_GROUPS = []uint16{0x11ec, 0x6399, 0x11eb, 0x001d, 0x0017, 0x0018}
+
+// pqGroups are the NamedGroups with a post-quantum KEM in them.
+var pqGroups = []uint16{0x0200, 0x0201, 0x0202, 0x11eb, 0x11ec, 0x11ed, 0x6399}
+
+// TLS alerts a scanned server is likely to answer with.
+var alertNames = map[byte]string{
+	40: "handshake_failure", 47: "illegal_parameter", 50: "decode_error",
+	70: "protocol_version", 71: "insufficient_security", 80: "internal_error", 112: "unrecognized_name",
+}
+
+// ScanResult is what one server answered.
+type ScanResult struct {
+	Target        string `json:"target"`
+	Address       string `json:"address,omitempty"` // the IP address connected to
+	SNI           string `json:"sni,omitempty"`
+	Version       string `json:"version,omitempty"`
+	Group         string `json:"group,omitempty"`
+	PQC           bool   `json:"pqc"`
+	RetryGroup    string `json:"hrr_group,omitempty"` // asked for with a HelloRetryRequest
+	HelloBytes    int    `json:"client_hello_bytes,omitempty"`
+	RetryBytes    int    `json:"second_client_hello_bytes,omitempty"`
+	ServerHello   int    `json:"server_hello_bytes,omitempty"`
+	KeyShareBytes int    `json:"server_key_share_bytes,omitempty"`
+	FlightBytes   int    `json:"server_flight_bytes,omitempty"`
+	FlightRecords int    `json:"server_flight_records,omitempty"`
+	Millis        int64  `json:"duration_ms"`
+	Error         string `json:"error,omitempty"`
+}
+
+// scanKeyShare makes a key share for a group, nil for one the scanner
+// cannot make.
+func scanKeyShare(group uint16) []byte {
+	ec := func(c ecdh.Curve) []byte {
+		k, err := c.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil
+		}
+		return k.PublicKey().Bytes()
+	}
+	kyber := func(name string) []byte {
+		pk, _, err := schemes.ByName(name).GenerateKeyPair()
+		if err != nil {
+			return nil
+		}
+		b, _ := pk.MarshalBinary()
+		return b
+	}
+	switch group {
+	case 0x001d:
+		return ec(ecdh.X25519())
+	case 0x0017:
+		return ec(ecdh.P256())
+	case 0x0018:
+		return ec(ecdh.P384())
+	case 0x0200:
+		return kyber("Kyber512")
+	case 0x0201:
+		return kyber("Kyber768")
+	case 0x0202:
+		return kyber("Kyber1024")
+	case 0x11ec: // ML-KEM first
+		return append(kyber("Kyber768"), ec(ecdh.X25519())...)
+	case 0x11eb:
+		return append(ec(ecdh.P256()), kyber("Kyber768")...)
+	case 0x11ed:
+		return append(ec(ecdh.P384()), kyber("Kyber1024")...)
+	case 0x6399: // X25519 first
+		return append(ec(ecdh.X25519()), kyber("Kyber768")...)
+	}
+	return nil
+}
+
+// parseGroups reads a comma-separated list of NamedGroup names.
+func parseGroups(list string) ([]uint16, error) {
+	var groups []uint16
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		var group uint16
+		for id, n := range groupNames {
+			if strings.EqualFold(n, name) {
+				group = id
+			}
+		}
+		if group == 0 || scanKeyShare(group) == nil {
+			return nil, fmt.Errorf("no key share for group %q", name)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// scanHello is the ClientHello of a scan. A second one after a
+// HelloRetryRequest keeps the random and session ID.
+type scanHello struct {
+	random, sessionID []byte
+	sni               string
+	supported         []uint16
+	sent              int
+}
+
+func newScanHello(sni string, shares []uint16) *scanHello {
+	h := &scanHello{random: make([]byte, 32), sessionID: make([]byte, 32), sni: sni}
+	rand.Read(h.random)
+	rand.Read(h.sessionID)
+	h.supported = slices.Clone(shares)
+	for _, g := range SCAN_SUPPORTED_GROUPS {
+		if !slices.Contains(h.supported, g) {
+			h.supported = append(h.supported, g)
+		}
+	}
+	return h
+}
+
+// record encodes the ClientHello with the key shares of groups.
+func (h *scanHello) record(groups []uint16, cookie []byte) []byte {
+	var ext []byte
+	add := func(typ uint16, data []byte) {
+		ext = binary.BigEndian.AppendUint16(ext, typ)
+		ext = binary.BigEndian.AppendUint16(ext, uint16(len(data)))
+		ext = append(ext, data...)
+	}
+	if h.sni != "" {
+		name := binary.BigEndian.AppendUint16(nil, uint16(len(h.sni)+3))
+		name = append(name, 0)
+		name = binary.BigEndian.AppendUint16(name, uint16(len(h.sni)))
+		add(0x0000, append(name, h.sni...)) // server_name
+	}
+	groupList := binary.BigEndian.AppendUint16(nil, uint16(2*len(h.supported)))
+	for _, g := range h.supported {
+		groupList = binary.BigEndian.AppendUint16(groupList, g)
+	}
+	add(0x000a, groupList) // supported_groups
+	sigAlgs := []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601, 0x0807}
+	sigList := binary.BigEndian.AppendUint16(nil, uint16(2*len(sigAlgs)))
+	for _, s := range sigAlgs {
+		sigList = binary.BigEndian.AppendUint16(sigList, s)
+	}
+	add(0x000d, sigList) // signature_algorithms
+	alpn := []byte{0, 12, 2, 'h', '2', 8, 'h', 't', 't', 'p', '/', '1', '.', '1'}
+	add(0x0010, alpn)
+	// supported_versions TLS 1.3 and 1.2, psk_key_exchange_modes psk_dhe_ke
+	add(0x002b, []byte{4, 0x03, 0x04, 0x03, 0x03})
+	add(0x002d, []byte{1, 1})
+	if cookie != nil {
+		add(0x002c, cookie)
+	}
+	var shares []byte
+	for _, g := range groups {
+		share := scanKeyShare(g)
+		shares = binary.BigEndian.AppendUint16(shares, g)
+		shares = binary.BigEndian.AppendUint16(shares, uint16(len(share)))
+		shares = append(shares, share...)
+	}
+	add(0x0033, append(binary.BigEndian.AppendUint16(nil, uint16(len(shares))), shares...)) // key_share
+
+	body := binary.BigEndian.AppendUint16(nil, TLS_VERSION_12)
+	body = append(body, h.random...)
+	body = append(body, byte(len(h.sessionID)))
+	body = append(body, h.sessionID...)
+	// The TLS 1.3 cipher suites, then ECDHE ones a TLS 1.2 server can pick
+	suites := []uint16{0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8}
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(suites)))
+	for _, c := range suites {
+		body = binary.BigEndian.AppendUint16(body, c)
+	}
+	// null compression
+	body = append(body, 1, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ext)))
+	body = append(body, ext...)
+
+	handshake := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+	// A first hello goes in a TLS 1.0 record for old middleboxes; after a
+	// HelloRetryRequest the record says TLS 1.2
+	version := uint16(TLS_VERSION_12)
+	if h.sent == 0 {
+		version = 0x0301
+	}
+	h.sent++
+	record := []byte{TLS_RECORD_HANDSHAKE}
+	record = binary.BigEndian.AppendUint16(record, version)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+// scanReader reads the server's TLS records and counts them.
+type scanReader struct {
+	r       *bufio.Reader
+	bytes   int
+	records int
+}
+
+// next reads one record.
+func (s *scanReader) next() (typ byte, body []byte, err error) {
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(s.r, hdr); err != nil {
+		return 0, nil, err
+	}
+	body = make([]byte, binary.BigEndian.Uint16(hdr[3:]))
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return 0, nil, err
+	}
+	s.bytes += 5 + len(body)
+	s.records++
+	return hdr[0], body, nil
+}
+
+// handshake reads the next handshake message, skipping ChangeCipherSpec,
+// and the bytes of its records.
+func (s *scanReader) handshake() (msg []byte, wire int, err error) {
+	for {
+		typ, body, err := s.next()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch typ {
+		case TLS_RECORD_CCS:
+			continue
+		case TLS_RECORD_ALERT:
+			if len(body) == 2 {
+				if name, ok := alertNames[body[1]]; ok {
+					return nil, 0, fmt.Errorf("alert %s (%d)", name, body[1])
+				}
+				return nil, 0, fmt.Errorf("alert %d", body[1])
+			}
+			return nil, 0, errors.New("alert")
+		case TLS_RECORD_HANDSHAKE:
+		default:
+			return nil, 0, fmt.Errorf("not a TLS handshake (record type 0x%02x)", typ)
+		}
+		msg = append(msg, body...)
+		wire += 5 + len(body)
+		if len(msg) >= 4 && len(msg) >= 4+(int(msg[1])<<16|int(msg[2])<<8|int(msg[3])) {
+			return msg, wire, nil
+		}
+	}
+}
+
+// scanTarget runs one handshake against target (host or host:port).
+func scanTarget(target string, shares []uint16, timeout time.Duration) (res ScanResult) {
+	res.Target = target
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, SCAN_DEFAULT_PORT
+	}
+	res.Target = net.JoinHostPort(host, port)
+	if net.ParseIP(host) == nil {
+		res.SNI = host
+	}
+
+	start := time.Now()
+	defer func() {
+		if res.Error != "" {
+			res.Millis = time.Since(start).Milliseconds()
+		}
+	}()
+	conn, err := net.DialTimeout("tcp", res.Target, timeout)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		res.Address = addr.IP.String()
+	}
+
+	hello := newScanHello(res.SNI, shares)
+	first := hello.record(shares, nil)
+	res.HelloBytes = len(first)
+	if _, err := conn.Write(first); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	rd := &scanReader{r: bufio.NewReader(conn)}
+	msg, wire, err := rd.handshake()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if len(msg) >= 4+34 && bytes.Equal(msg[6:38], hrrRandom) {
+		var cookie []byte
+		sh := parseServerHello(msg)
+		helloExtensions(serverHelloExtensions(msg), func(typ uint16, data []byte) {
+			if typ == 0x002c {
+				cookie = data
+			}
+		})
+		res.RetryGroup = groupName(sh.Group)
+		if scanKeyShare(sh.Group) == nil {
+			res.Error = "HelloRetryRequest for " + res.RetryGroup + ", which the scanner cannot offer"
+			return res
+		}
+		second := hello.record([]uint16{sh.Group}, cookie)
+		res.RetryBytes = len(second)
+		if _, err := conn.Write(second); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		rd.bytes, rd.records = 0, 0
+		if msg, wire, err = rd.handshake(); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+	}
+	if msg[0] != TLS_HANDSHAKE_SHELLO {
+		res.Error = fmt.Sprintf("handshake message %d instead of a ServerHello", msg[0])
+		return res
+	}
+	res.Millis = time.Since(start).Milliseconds()
+	sh := parseServerHello(msg)
+	res.ServerHello = wire
+	res.Version = "TLS1.2"
+	if sh.Version == TLS_VERSION_13 {
+		res.Version = "TLS1.3"
+		res.Group = groupName(sh.Group)
+		res.PQC = slices.Contains(pqGroups, sh.Group)
+		res.KeyShareBytes = sh.KeyShareSize
+	}
+
+	// The rest of the first flight, until the server waits for us
+	for rd.bytes < SCAN_MAX_FLIGHT {
+		conn.SetReadDeadline(time.Now().Add(SCAN_FLIGHT_IDLE))
+		typ, _, err := rd.next()
+		if err != nil || typ == TLS_RECORD_ALERT {
+			break
+		}
+	}
+	res.FlightBytes, res.FlightRecords = rd.bytes, rd.records
+	return res
+}
+
+// serverHelloExtensions is the extension block of a ServerHello message.
+func serverHelloExtensions(msg []byte) []byte {
+	pos := 4 + 34
+	if pos >= len(msg) {
+		return nil
+	}
+	pos += 1 + int(msg[pos]) + 3
+	if pos > len(msg) {
+		return nil
+	}
+	return msg[pos:]
+}
+
+// scanTargets reads the targets of -f, one per line; # starts a comment.
+func scanTargets(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	return targets, nil
+}
+
+func runScan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	groupList := fs.String("groups", "X25519MLKEM768,x25519", "Key shares of the ClientHello, comma-separated NamedGroups")
+	file := fs.String("f", "", "Read targets from this file, one host[:port] per line")
+	timeout := fs.Duration("timeout", 10*time.Second, "Per target, connect and handshake")
+	concurrency := fs.Int("concurrency", 8, "Targets scanned at a time")
+	asJSON := fs.Bool("json", false, "Print the results as a JSON array")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: scan [-groups G1,G2] [-f FILE] [-timeout D] [-concurrency N] [-json] [host[:port] ...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	shares, err := parseGroups(*groupList)
+	if err != nil {
+		log.Fatalf("[SCAN] -groups: %v", err)
+	}
+	targets := fs.Args()
+	if *file != "" {
+		more, err := scanTargets(*file)
+		if err != nil {
+			log.Fatalf("[SCAN] %v", err)
+		}
+		targets = append(targets, more...)
+	}
+	if len(targets) == 0 || *concurrency < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	results := make([]ScanResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(*concurrency, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = scanTarget(targets[i], shares, *timeout)
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	pqc, failed := 0, 0
+	fmt.Printf("%-28s  %-7s  %-21s  %-3s  %-3s  %5s  %6s  %6s  %5s\n", "target", "version", "group", "pqc", "hrr", "hello", "server", "flight", "ms")
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			fmt.Printf("%-28s  %s\n", r.Target, "error: "+r.Error)
+			continue
+		}
+		yes, hrr := "no", "-"
+		if r.PQC {
+			pqc++
+			yes = "yes"
+		}
+		if r.RetryGroup != "" {
+			hrr = "yes"
+		}
+		group := r.Group
+		if group == "" {
+			group = "-"
+		}
+		fmt.Printf("%-28s  %-7s  %-21s  %-3s  %-3s  %5d  %6d  %6d  %5d\n", r.Target, r.Version, group, yes, hrr, r.HelloBytes+r.RetryBytes, r.ServerHello, r.FlightBytes, r.Millis)
+	}
+	fmt.Printf("%d target(s): %d negotiate PQC, %d classical, %d failed\n", len(results), pqc, len(results)-pqc-failed, failed)
+}
@@ -0,0 +1,191 @@
+/*
+Sentinel-PQC Proxy - Scenarios
+==============================
+Each scenario decides how the proxy answers once the ClientHello has been
+read and measured. The default "kyber" scenario completes a real Kyber-768
+key exchange; the others model servers and middleboxes that behave
+differently on the wire.
+
+Select a scenario with:  go run . -scenario <name>
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// scenarioHandler writes the server flight for one simulated protocol and may
+// adjust the report (status, message) before it is saved. Returning an error
+// aborts the connection without saving a report.
+type scenarioHandler func(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error
+
+// scenarioReader runs any protocol preamble (banners, negotiation) and returns
+// the client message carrying the key share, which is what gets measured.
+type scenarioReader func(conn net.Conn, lg *slog.Logger) ([]byte, error)
+
+type scenario struct {
+	readHello scenarioReader // nil: the first read is the ClientHello
+	respond   scenarioHandler
+
+	// The first flight may legitimately be smaller than a public key
+	shortHello bool
+
+	// Datagram scenarios listen on UDP and use this instead of respond
+	respondDatagram datagramHandler
+}
+
+var scenarios = map[string]scenario{
+	"kyber": {respond: completeKeyExchange},
+	"tls12": {respond: downgradeToTLS12},
+	"hrr":   {respond: respondKeyShareRetry, shortHello: true},
+	"ssh":   {readHello: readSSHKexInit, respond: replySSHKex},
+	"mqtt":  {respond: respondMQTT},
+	"smtp":  {readHello: readSMTPStartTLS, respond: completeKeyExchange},
+
+	"middlebox": {readHello: readTLSHello, respond: respondMiddlebox},
+	"stall":     {respond: respondStalled},
+	"noise":     {respondDatagram: respondNoise},
+	"ikev2":     {respondDatagram: respondIKEv2},
+	"quic":      {respondDatagram: respondQUIC},
+}
+
+func scenarioNames() string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ============================================================================
+// KYBER (DEFAULT)
+// ============================================================================
+
+// completeKeyExchange extracts the client's public key, encapsulates against
+// it and sends the ciphertext back (simulating the ServerHello KeyShare).
+func completeKeyExchange(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error {
+	ct, ss, err := encapsulateKeyShare(scheme, clientData, report.logger())
+	if err != nil {
+		return err
+	}
+
+	// Send Ciphertext back (simulating ServerHello KeyShare)
+	if _, err := conn.Write(ct); err != nil {
+		return fmt.Errorf("failed to send ciphertext: %w", err)
+	}
+	report.logger().Info("sent ServerHello ciphertext", "bytes", len(ct))
+
+	// The ciphertext travels inside a ServerHello key_share extension
+	report.ServerHelloSize = len(ct) + SERVER_HELLO_OVERHEAD
+
+	confirmKey(conn, ss, clientData[:scheme.PublicKeySize()], ct, KEY_CONFIRM_WAIT, report)
+	if *certChain != "" {
+		return modelCertificateFlight(*certChain, report)
+	}
+	return nil
+}
+
+// encapsulateKeyShare extracts the client's public key and returns the
+// ciphertext and shared secret of a fresh encapsulation to it.
+func encapsulateKeyShare(scheme kem.Scheme, clientData []byte, lg *slog.Logger) (ct, ss []byte, err error) {
+	// Extract and validate the Public Key from client payload
+	pkSize := scheme.PublicKeySize()
+	if len(clientData) < pkSize {
+		return nil, nil, fmt.Errorf("payload too small (%d bytes) for %s key (%d bytes required)",
+			len(clientData), scheme.Name(), pkSize)
+	}
+
+	// Extract Public Key (at start of packet for simulation)
+	pkBytes := clientData[:pkSize]
+	pk, err := scheme.UnmarshalBinaryPublicKey(pkBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s public key: %w", scheme.Name(), err)
+	}
+
+	lg.Debug("valid public key received", "algorithm", scheme.Name())
+
+	// Encapsulate: Generate Shared Secret + Ciphertext
+	ct, ss, err = scheme.Encapsulate(pk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encapsulation failed: %w", err)
+	}
+
+	// The shared secret would be used for symmetric encryption; here it
+	// only confirms the exchange (keyconfirm.go)
+	lg.Debug("encapsulation complete, shared secret derived", "ciphertext_bytes", len(ct))
+	return ct, ss, nil
+}
+
+// ============================================================================
+// TLS 1.2 FALLBACK
+// ============================================================================
+
+// TLS wire constants used to build the legacy ServerHello.
+const (
+	TLS_RECORD_HANDSHAKE  = 0x16
+	TLS_HANDSHAKE_SHELLO  = 0x02
+	TLS_VERSION_12        = 0x0303
+	TLS_ECDHE_RSA_AES_GCM = 0xc02f // TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+
+	// TLS 1.3 ServerHello around the key share: record(5) + handshake(4) +
+	// version(2) + random(32) + session id(33) + cipher(2) + compression(1) +
+	// extensions length(2) + supported_versions(6) + key_share header(8)
+	SERVER_HELLO_OVERHEAD = 95
+)
+
+// downgradeToTLS12 pretends to be a server (or a middlebox in front of one)
+// that only speaks TLS 1.2. The ML-KEM key share is ignored and a classical
+// ServerHello without the supported_versions extension is returned, which is
+// exactly what a client sees when TLS 1.3 is silently stripped in transit.
+func downgradeToTLS12(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error {
+	lg := report.logger()
+	lg.Info("ignoring key share, negotiating TLS 1.2", "algorithm", scheme.Name())
+
+	serverHello := buildTLS12ServerHello()
+	if _, err := conn.Write(serverHello); err != nil {
+		return fmt.Errorf("failed to send TLS 1.2 ServerHello: %w", err)
+	}
+	lg.Info("sent TLS 1.2 ServerHello", "bytes", len(serverHello))
+
+	report.Status = "PQC_IMPOSSIBLE"
+	report.Message = fmt.Sprintf("Server negotiated TLS 1.2; %s key share discarded. %s",
+		scheme.Name(), report.Message)
+	lg.Warn("PQC IMPOSSIBLE: TLS 1.3 unavailable, no post-quantum key exchange possible")
+	return nil
+}
+
+// buildTLS12ServerHello encodes a minimal TLS 1.2 ServerHello record:
+// version, random, empty session id, an ECDHE cipher suite, null compression
+// and no extensions (notably no supported_versions).
+func buildTLS12ServerHello() []byte {
+	body := make([]byte, 0, 38)
+	body = binary.BigEndian.AppendUint16(body, TLS_VERSION_12)
+
+	random := make([]byte, 32)
+	rand.Read(random)
+	body = append(body, random...)
+
+	body = append(body, 0) // session_id length
+	body = binary.BigEndian.AppendUint16(body, TLS_ECDHE_RSA_AES_GCM)
+	body = append(body, 0) // compression_method: null
+
+	handshake := []byte{TLS_HANDSHAKE_SHELLO, 0, 0, 0}
+	handshake[1] = byte(len(body) >> 16)
+	binary.BigEndian.PutUint16(handshake[2:], uint16(len(body)))
+	handshake = append(handshake, body...)
+
+	record := []byte{TLS_RECORD_HANDSHAKE}
+	record = binary.BigEndian.AppendUint16(record, TLS_VERSION_12)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
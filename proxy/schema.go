@@ -0,0 +1,105 @@
+/*
+Sentinel-PQC Proxy - Report Schema
+==================================
+Every report carries schema_version. Stored reports (SQLite, PostgreSQL,
+the report log) are upgraded to the current version when they are read, so
+queries and the APIs always return current reports however old the data.
+
+Changelog
+---------
+  1  No schema_version field. timestamp, client_ip, algorithm,
+     public_key_size, handshake_size_bytes, fragmentation_risk, status and
+     message; later unversioned reports may also carry any field of version 2.
+  2  schema_version. mtu_budget_bytes and ipv6_fragmentation_risk are always
+     set (upgrade: the fixed 1400-byte budget of the time, and size > 1220).
+     Optional since before versioning: conn_id, dscp, path_mtu,
+     client_path_mtu, tcp_mss, tcp_stats, socket_buffers, middlebox,
+     listener, link_preset, transport, stall, read_stall, origin, sni, alpn,
+     server_hello_size_bytes, path_fits, fragments, ike_fragments,
+     link_costs, key_share_strategies, handshake_duration_ms, bandwidth_kbps,
+     flights, icmp_events, path_verdict, wire, tfo, cert_compression.
+  3  timings: ttfb_ms, read_ms, parse_ms, encapsulate_ms, respond_ms and
+     total_ms of completed handshakes (older reports have none).
+  4  geo: country, asn and as_org of public client addresses with -geoip.
+  5  signature: algorithm, key_id and value with -sign-key (signing.go).
+  6  severity: SAFE, MARGINAL, FRAGMENTED, MULTI_SEGMENT or
+     BLACKHOLE_SUSPECTED (severity.go; upgrade: graded with the defaults).
+  7  probe and site: the proxy that saw the handshake, with -probe-id or
+     -collector (collector.go).
+  8  key_confirmation: confirmed, mismatch or missing when the scenario
+     completes the key exchange (keyconfirm.go; older reports have none).
+  9  quic: Initial datagrams, retransmissions and amplification of the
+     quic scenario (quic.go; older reports have none).
+
+Fields are only ever added; a field that changes meaning gets a new name and
+a new version with an upgrade step below.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const REPORT_SCHEMA_VERSION = 9
+
+// reportUpgrades[v-1] upgrades a decoded version v report to v+1; never edit
+// one that has shipped.
+var reportUpgrades = []func(r map[string]any){
+	// 1 -> 2: budget and IPv6 verdict on every report
+	func(r map[string]any) {
+		if _, ok := r["mtu_budget_bytes"]; !ok {
+			r["mtu_budget_bytes"] = SAFE_MTU
+		}
+		if _, ok := r["ipv6_fragmentation_risk"]; !ok {
+			size, _ := r["handshake_size_bytes"].(float64)
+			r["ipv6_fragmentation_risk"] = int(size) > IPV6_MIN_BUDGET
+		}
+	},
+	// 2 -> 3: timings cannot be recovered; they stay absent
+	func(r map[string]any) {},
+	// 3 -> 4: nor can geo
+	func(r map[string]any) {},
+	// 4 -> 5: older reports are unsigned
+	func(r map[string]any) {},
+	// 5 -> 6: severity from the stored size, budget and status
+	upgradeSeverity,
+	// 6 -> 7: older reports came from a standalone proxy
+	func(r map[string]any) {},
+	// 7 -> 8: whether older key exchanges were confirmed is unknown
+	func(r map[string]any) {},
+	// 8 -> 9: no quic scenario before
+	func(r map[string]any) {},
+}
+
+// decodeReport parses a stored report of any schema version and upgrades it
+// to REPORT_SCHEMA_VERSION.
+func decodeReport(data []byte) (GhostReport, error) {
+	var r GhostReport
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return r, err
+	}
+	version := 1
+	if v, ok := raw["schema_version"].(float64); ok {
+		version = int(v)
+	}
+	if version > REPORT_SCHEMA_VERSION {
+		return r, fmt.Errorf("report schema version %d is newer than this proxy's %d", version, REPORT_SCHEMA_VERSION)
+	}
+	if version == REPORT_SCHEMA_VERSION {
+		err := json.Unmarshal(data, &r)
+		return r, err
+	}
+	for ; version < REPORT_SCHEMA_VERSION; version++ {
+		reportUpgrades[version-1](raw)
+	}
+	raw["schema_version"] = REPORT_SCHEMA_VERSION
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(upgraded, &r)
+	return r, err
+}
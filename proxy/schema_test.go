@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportUpgradesCoverEveryVersion(t *testing.T) {
+	if got, want := len(reportUpgrades), REPORT_SCHEMA_VERSION-1; got != want {
+		t.Fatalf("%d upgrade steps for schema version %d, want %d", got, REPORT_SCHEMA_VERSION, want)
+	}
+}
+
+func TestDecodeReport(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     string
+		budget     int
+		ipv6Risk   bool
+		severity   string
+		confirmed  string
+		wantErrMsg string
+	}{
+		{
+			name:     "v1 without a budget gets the fixed 1400 bytes",
+			stored:   `{"timestamp":"2024-01-01T00:00:00Z","client_ip":"10.0.0.1:5000","algorithm":"Kyber768","handshake_size_bytes":1484,"fragmentation_risk":true,"status":"CRITICAL_RISK"}`,
+			budget:   SAFE_MTU,
+			ipv6Risk: true,
+			severity: "FRAGMENTED",
+		},
+		{
+			name:     "v1 within the IPv6 budget",
+			stored:   `{"handshake_size_bytes":1000,"status":"SAFE"}`,
+			budget:   SAFE_MTU,
+			severity: "SAFE",
+		},
+		{
+			name:     "v1 keeps a budget it already had",
+			stored:   `{"handshake_size_bytes":1250,"mtu_budget_bytes":1280,"status":"SAFE"}`,
+			budget:   1280,
+			ipv6Risk: true,
+			severity: "MARGINAL",
+		},
+		{
+			name:     "v2 keeps its IPv6 verdict",
+			stored:   `{"schema_version":2,"handshake_size_bytes":1300,"mtu_budget_bytes":1400,"ipv6_fragmentation_risk":false,"status":"SAFE"}`,
+			budget:   1400,
+			severity: "SAFE",
+		},
+		{
+			name:     "v5 is graded with the default thresholds",
+			stored:   `{"schema_version":5,"handshake_size_bytes":4300,"mtu_budget_bytes":1400,"ipv6_fragmentation_risk":true,"status":"CRITICAL_RISK"}`,
+			budget:   1400,
+			ipv6Risk: true,
+			severity: "MULTI_SEGMENT",
+		},
+		{
+			name:     "v5 black hole",
+			stored:   `{"schema_version":5,"handshake_size_bytes":700,"mtu_budget_bytes":1400,"status":"SUSPECTED_BLACKHOLE"}`,
+			budget:   1400,
+			severity: "BLACKHOLE_SUSPECTED",
+		},
+		{
+			name:     "v6 keeps its stored severity",
+			stored:   `{"schema_version":6,"handshake_size_bytes":1484,"mtu_budget_bytes":1400,"ipv6_fragmentation_risk":true,"status":"CRITICAL_RISK","severity":"MULTI_SEGMENT"}`,
+			budget:   1400,
+			ipv6Risk: true,
+			severity: "MULTI_SEGMENT",
+		},
+		{
+			name:      "v8 keeps its key confirmation",
+			stored:    `{"schema_version":8,"handshake_size_bytes":1334,"mtu_budget_bytes":1400,"status":"SAFE","severity":"SAFE","key_confirmation":"confirmed"}`,
+			budget:    1400,
+			ipv6Risk:  false,
+			severity:  "SAFE",
+			confirmed: "confirmed",
+		},
+		{
+			name:     "current version is read as is",
+			stored:   `{"schema_version":9,"handshake_size_bytes":1334,"mtu_budget_bytes":1400,"status":"SAFE","severity":"MARGINAL"}`,
+			budget:   1400,
+			severity: "MARGINAL",
+		},
+		{
+			name:       "newer than the proxy",
+			stored:     `{"schema_version":99}`,
+			wantErrMsg: "newer than this proxy's",
+		},
+		{
+			name:       "not JSON",
+			stored:     `{"schema_version":`,
+			wantErrMsg: "unexpected end",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decodeReport([]byte(tt.stored))
+			if tt.wantErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.SchemaVersion != REPORT_SCHEMA_VERSION {
+				t.Errorf("schema_version = %d, want %d", r.SchemaVersion, REPORT_SCHEMA_VERSION)
+			}
+			if r.MTUBudget != tt.budget {
+				t.Errorf("mtu_budget_bytes = %d, want %d", r.MTUBudget, tt.budget)
+			}
+			if r.IPv6Risk != tt.ipv6Risk {
+				t.Errorf("ipv6_fragmentation_risk = %t, want %t", r.IPv6Risk, tt.ipv6Risk)
+			}
+			if r.Severity != tt.severity {
+				t.Errorf("severity = %q, want %q", r.Severity, tt.severity)
+			}
+			if r.KeyConfirmation != tt.confirmed {
+				t.Errorf("key_confirmation = %q, want %q", r.KeyConfirmation, tt.confirmed)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Sentinel-PQC Proxy - Severity Grades
+====================================
+status says what happened; severity grades how close the handshake came to
+breaking, from the size, the segments it needed and how the path behaved:
+
+  SAFE                 fits the budget with room to spare
+  MARGINAL             fits, but within -marginal percent (default 5%) of
+                       the budget: one more extension or tunnel header and
+                       it fragments
+  FRAGMENTED           a flight exceeds the budget and is split in two
+  MULTI_SEGMENT        a flight needs -multi-segment (default 3) or more
+                       packets, each one another chance to be dropped
+  BLACKHOLE_SUSPECTED  the path went silent or lost fragments
+                       (SUSPECTED_BLACKHOLE, FRAGMENT_TIMEOUT, a read stall
+                       or a black-holed impairment)
+
+The larger of the client and server flights counts. Listeners can set their
+own thresholds, e.g. a stricter margin on a tunnel:
+
+  go run . -listen :4433 -listen :4434@wireguard,margin=10,segments=2
+
+Reports from before schema version 6 are graded on read with the default
+thresholds.
+*/
+
+package main
+
+import "encoding/json"
+
+const (
+	MARGINAL_PCT          = 5.0
+	MULTI_SEGMENT_PACKETS = 3
+)
+
+// severityThresholds are the limits a listener grades by.
+type severityThresholds struct {
+	MarginPct float64 // MARGINAL within this percentage of the budget
+	Segments  int     // MULTI_SEGMENT from this many packets
+}
+
+// severityThresholds returns the listener's own thresholds or the flags'.
+func (p listenerProfile) severityThresholds() severityThresholds {
+	t := defaultSeverityThresholds()
+	if p.Margin != nil {
+		t.MarginPct = *p.Margin
+	}
+	if p.Segments > 0 {
+		t.Segments = p.Segments
+	}
+	return t
+}
+
+// grade computes the report's severity.
+func (r *GhostReport) grade(t severityThresholds) string {
+	if r.Status == "SUSPECTED_BLACKHOLE" || r.Status == "FRAGMENT_TIMEOUT" || r.ReadStall != nil || r.PathVerdict == "black_holed" {
+		return "BLACKHOLE_SUSPECTED"
+	}
+	if r.MTUBudget <= 0 {
+		return "SAFE"
+	}
+	size := max(r.HandshakeSize, r.ServerHelloSize)
+	segments := (size + r.MTUBudget - 1) / r.MTUBudget
+	for _, f := range r.Flights {
+		segments = max(segments, f.Segments)
+	}
+	switch {
+	case segments >= t.Segments && segments > 1:
+		return "MULTI_SEGMENT"
+	case size > r.MTUBudget || r.Fragmentation:
+		return "FRAGMENTED"
+	case float64(size) > float64(r.MTUBudget)*(1-t.MarginPct/100):
+		return "MARGINAL"
+	}
+	return "SAFE"
+}
+
+// upgradeSeverity grades a decoded pre-version-6 report.
+func upgradeSeverity(raw map[string]any) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+	var r GhostReport
+	if json.Unmarshal(data, &r) != nil {
+		return
+	}
+	raw["severity"] = r.grade(severityThresholds{MarginPct: MARGINAL_PCT, Segments: MULTI_SEGMENT_PACKETS})
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestGrade(t *testing.T) {
+	defaults := severityThresholds{MarginPct: MARGINAL_PCT, Segments: MULTI_SEGMENT_PACKETS}
+	tests := []struct {
+		name   string
+		report GhostReport
+		t      severityThresholds
+		want   string
+	}{
+		{"no budget", GhostReport{HandshakeSize: 9000}, defaults, "SAFE"},
+		{"well within", GhostReport{HandshakeSize: 1000, MTUBudget: 1400}, defaults, "SAFE"},
+		{"just below the margin", GhostReport{HandshakeSize: 1330, MTUBudget: 1400}, defaults, "SAFE"},
+		{"within the margin", GhostReport{HandshakeSize: 1331, MTUBudget: 1400}, defaults, "MARGINAL"},
+		{"exactly the budget", GhostReport{HandshakeSize: 1400, MTUBudget: 1400}, defaults, "MARGINAL"},
+		{"one byte over", GhostReport{HandshakeSize: 1401, MTUBudget: 1400}, defaults, "FRAGMENTED"},
+		{"server flight counts", GhostReport{HandshakeSize: 1000, ServerHelloSize: 1500, MTUBudget: 1400}, defaults, "FRAGMENTED"},
+		{"fragmentation flag", GhostReport{HandshakeSize: 1000, MTUBudget: 1400, Fragmentation: true}, defaults, "FRAGMENTED"},
+		{"three packets", GhostReport{HandshakeSize: 2801, MTUBudget: 1400}, defaults, "MULTI_SEGMENT"},
+		{"measured flight segments", GhostReport{HandshakeSize: 1500, MTUBudget: 1400, Flights: []Flight{{Segments: 4}}}, defaults, "MULTI_SEGMENT"},
+		{"listener segments", GhostReport{HandshakeSize: 1500, MTUBudget: 1400}, severityThresholds{MarginPct: 5, Segments: 2}, "MULTI_SEGMENT"},
+		{"listener margin", GhostReport{HandshakeSize: 1300, MTUBudget: 1400}, severityThresholds{MarginPct: 10, Segments: 3}, "MARGINAL"},
+		{"one packet never multi-segment", GhostReport{HandshakeSize: 1000, MTUBudget: 1400}, severityThresholds{MarginPct: 5, Segments: 1}, "SAFE"},
+		{"suspected black hole", GhostReport{Status: "SUSPECTED_BLACKHOLE", HandshakeSize: 100, MTUBudget: 1400}, defaults, "BLACKHOLE_SUSPECTED"},
+		{"fragment timeout", GhostReport{Status: "FRAGMENT_TIMEOUT", MTUBudget: 1400}, defaults, "BLACKHOLE_SUSPECTED"},
+		{"read stall", GhostReport{HandshakeSize: 600, MTUBudget: 1400, ReadStall: &ReadStall{BytesReceived: 600}}, defaults, "BLACKHOLE_SUSPECTED"},
+		{"black-holed path", GhostReport{HandshakeSize: 1000, MTUBudget: 1400, PathVerdict: "black_holed"}, defaults, "BLACKHOLE_SUSPECTED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.grade(tt.t); got != tt.want {
+				t.Errorf("grade() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
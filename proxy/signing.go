@@ -0,0 +1,265 @@
+/*
+Sentinel-PQC Proxy - Signed Reports
+===================================
+-sign-key signs every report with a post-quantum key held by the proxy, so
+audit teams can prove a report was not edited after the proxy wrote it:
+
+  go run . -sign-key sentinel.key      # creates sentinel.key and sentinel.key.pub
+  go run . verify -pub sentinel.key.pub ghost_reports.jsonl ghost_report.json
+
+  "signature": {"algorithm": "ML-DSA-65", "key_id": "9f2c41d07a6be813", "value": "<base64>"}
+
+The signature is the last field of the report and covers the compact JSON
+of everything before it, byte for byte. It holds wherever the report is
+kept verbatim: ghost_report.json, the report log (rotated and gzipped logs
+included), -sqlite and the API responses. PostgreSQL's JSONB reorders keys,
+so reports read back from -postgres cannot be checked.
+
+The scheme is ML-DSA-65 (FIPS 204) from circl, hedged and with an empty
+context string. The key file holds the 32-byte seed; keep it readable by
+the proxy only and hand out the .pub file. Keys and signatures from the
+round-3 Dilithium3 scheme earlier releases used are not accepted.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+)
+
+const (
+	SIGNATURE_ALGORITHM = "ML-DSA-65"
+	SIGNING_SEED_PEM    = "SENTINEL ML-DSA-65 SEED"
+	SIGNING_PUBLIC_PEM  = "SENTINEL ML-DSA-65 PUBLIC KEY"
+)
+
+// ReportSignature is the signature over the rest of the report.
+type ReportSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"` // first 8 bytes of SHA-256 of the public key, hex
+	Value     string `json:"value"`  // base64
+}
+
+var signer struct {
+	sk    *mldsa65.PrivateKey
+	keyID string
+}
+
+// ============================================================================
+// SIGNING
+// ============================================================================
+
+// loadSigningKey reads the seed from path, creating a new key pair if the
+// file does not exist yet.
+func loadSigningKey(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createSigningKey(path)
+	}
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != SIGNING_SEED_PEM || len(block.Bytes) != mldsa65.SeedSize {
+		return fmt.Errorf("%s is not a %s file", path, SIGNING_SEED_PEM)
+	}
+	var seed [mldsa65.SeedSize]byte
+	copy(seed[:], block.Bytes)
+	pk, sk := mldsa65.NewKeyFromSeed(&seed)
+	signer.sk, signer.keyID = sk, signingKeyID(pk)
+	slog.Info("signing reports", "algorithm", SIGNATURE_ALGORITHM, "key_id", signer.keyID)
+	return nil
+}
+
+func createSigningKey(path string) error {
+	var seed [mldsa65.SeedSize]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return err
+	}
+	pk, sk := mldsa65.NewKeyFromSeed(&seed)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: SIGNING_SEED_PEM, Bytes: seed[:]}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".pub", pem.EncodeToMemory(&pem.Block{Type: SIGNING_PUBLIC_PEM, Bytes: pk.Bytes()}), 0644); err != nil {
+		return err
+	}
+	signer.sk, signer.keyID = sk, signingKeyID(pk)
+	slog.Info("created signing key", "file", path, "public_key", path+".pub", "key_id", signer.keyID)
+	return nil
+}
+
+func signingKeyID(pk *mldsa65.PublicKey) string {
+	sum := sha256.Sum256(pk.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+// signReport signs the report as it will be written; call it last, after
+// every field is set.
+func signReport(report *GhostReport) {
+	if signer.sk == nil {
+		return
+	}
+	report.Signature = nil
+	payload, err := json.Marshal(report)
+	if err != nil {
+		report.logger().Error("cannot sign report", "err", err)
+		return
+	}
+	sig := make([]byte, mldsa65.SignatureSize)
+	if err := mldsa65.SignTo(signer.sk, payload, nil, true, sig); err != nil {
+		report.logger().Error("cannot sign report", "err", err)
+		return
+	}
+	report.Signature = &ReportSignature{
+		Algorithm: SIGNATURE_ALGORITHM,
+		KeyID:     signer.keyID,
+		Value:     base64.StdEncoding.EncodeToString(sig),
+	}
+}
+
+// ============================================================================
+// VERIFY COMMAND
+// ============================================================================
+
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	pubPath := fs.String("pub", "sentinel.key.pub", "Public key written next to the -sign-key file")
+	quiet := fs.Bool("q", false, "Only print reports that fail")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: verify [-pub FILE] [-q] REPORT_FILE...  (.json, .jsonl, .gz)")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*pubPath)
+	if err != nil {
+		fatal("cannot read public key", "err", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != SIGNING_PUBLIC_PEM || len(block.Bytes) != mldsa65.PublicKeySize {
+		fatal("not a public key file", "file", *pubPath, "want", SIGNING_PUBLIC_PEM)
+	}
+	var pk mldsa65.PublicKey
+	var packed [mldsa65.PublicKeySize]byte
+	copy(packed[:], block.Bytes)
+	pk.Unpack(&packed)
+
+	var ok, failed int
+	for _, name := range fs.Args() {
+		reports, err := readReportFile(name)
+		if err != nil {
+			fatal("cannot read reports", "file", name, "err", err)
+		}
+		for i, raw := range reports {
+			where := name
+			if len(reports) > 1 {
+				where = fmt.Sprintf("%s:%d", name, i+1)
+			}
+			if err := verifyReport(&pk, raw); err != nil {
+				fmt.Printf("FAIL  %s  %v\n", where, err)
+				failed++
+				continue
+			}
+			if !*quiet {
+				fmt.Printf("ok    %s\n", where)
+			}
+			ok++
+		}
+	}
+	fmt.Printf("%d verified, %d failed\n", ok, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readReportFile returns the compact JSON of every report in a snapshot,
+// JSONL log or gzipped log.
+func readReportFile(name string) ([][]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// An indented snapshot compacts to exactly what the proxy signed
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) == nil {
+		return [][]byte{buf.Bytes()}, nil
+	}
+	var reports [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 4<<20)
+	for sc.Scan() {
+		if line := bytes.TrimSpace(sc.Bytes()); len(line) > 0 {
+			reports = append(reports, append([]byte(nil), line...))
+		}
+	}
+	return reports, sc.Err()
+}
+
+// verifyReport checks the signature of one compact JSON report.
+func verifyReport(pk *mldsa65.PublicKey, raw []byte) error {
+	var r struct {
+		Signature *ReportSignature `json:"signature"`
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return fmt.Errorf("not a report: %w", err)
+	}
+	if r.Signature == nil {
+		return errors.New("unsigned")
+	}
+	if r.Signature.Algorithm != SIGNATURE_ALGORITHM {
+		return fmt.Errorf("unsupported algorithm %q", r.Signature.Algorithm)
+	}
+	if r.Signature.KeyID != signingKeyID(pk) {
+		return fmt.Errorf("signed by key %s, not this one", r.Signature.KeyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature.Value)
+	if err != nil || len(sig) != mldsa65.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	// The signature is the last field: the payload is the report up to it
+	i := bytes.LastIndex(raw, []byte(`,"signature":`))
+	if i < 0 {
+		return errors.New("signature is not the last field")
+	}
+	payload := append(raw[:i:i], '}')
+	if !mldsa65.Verify(pk, payload, nil, sig) {
+		return errors.New("TAMPERED: signature does not match the report")
+	}
+	return nil
+}
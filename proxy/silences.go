@@ -0,0 +1,463 @@
+/*
+Sentinel-PQC Proxy - Alert Silences
+===================================
+A known issue should not keep paging while it is being fixed. A silence
+holds back the alerts of one rule, of one client network, or of one rule for
+one network, until it expires:
+
+  curl -X POST localhost:9090/api/alerts/silences -H 'Authorization: Bearer ...' --json '{
+    "rule": "3f9c0a1b2d4e",
+    "subnet": "10.20.0.0/16",
+    "for": "48h",
+    "comment": "branch firewall drops fragments, change CHG-1042 on Thursday"
+  }'
+
+  GET    /api/alerts/silences            the silences in force
+  POST   /api/alerts/silences            add one (operator)
+  PUT    /api/alerts/silences/{id}       replace it, e.g. to extend it (operator)
+  DELETE /api/alerts/silences/{id}       lift it (operator)
+  GET    /api/alerts/fired               the latest alerts, newest first
+  POST   /api/alerts/fired/{id}/ack      acknowledge one (operator)
+
+rule is an alert rule's ID (alerts.go) and subnet a client CIDR prefix; a
+silence needs at least one of them, a comment, and either expires (RFC 3339)
+or for (a duration), at most ALERT_SILENCE_MAX ahead. Acknowledging a fired
+alert silences its rule for its client's network (/24 or /64, or the subnet
+given) for ALERT_ACK_DEFAULT unless for or expires say otherwise.
+
+Silences are applied before the notifiers (webhook, Slack, Teams, email), so
+all of them honor them alike. While no alert rule is enabled and the
+notifiers use their fixed triggers, the silences without a rule still hold
+back the alerts for their networks. Silences are kept in -alert-silences
+(alert_silences.json) and dropped once expired; the fired alerts, silenced
+or not, are kept in memory only. Every change is in the audit trail
+(control.go).
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ALERT_SILENCES_MAX_BODY = 16 << 10
+	ALERT_SILENCE_MAX       = 30 * 24 * time.Hour // a forgotten silence still ends
+	ALERT_ACK_DEFAULT       = 4 * time.Hour
+	ALERT_HISTORY           = 200 // fired alerts kept for /api/alerts/fired
+)
+
+// Silence holds back alerts until it expires.
+type Silence struct {
+	ID      string `json:"id"`
+	Rule    string `json:"rule,omitempty"`   // alert rule ID; empty for every rule
+	Subnet  string `json:"subnet,omitempty"` // client CIDR prefix; empty for every client
+	Comment string `json:"comment"`
+	Expires string `json:"expires"`         // RFC 3339
+	For     string `json:"for,omitempty"`   // instead of expires in a request, e.g. 4h
+	Alert   string `json:"alert,omitempty"` // the fired alert it acknowledges
+	Author  string `json:"author"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+
+	expires time.Time
+	subnet  netip.Prefix
+}
+
+// FiredAlert is an alert rule firing for one report.
+type FiredAlert struct {
+	ID       string `json:"id"`
+	Time     string `json:"time"`
+	Rule     string `json:"rule"`
+	RuleName string `json:"rule_name"`
+	ConnID   string `json:"conn_id,omitempty"`
+	Client   string `json:"client"`
+	Subnet   string `json:"subnet"` // the client's /24 or /64
+	Status   string `json:"status"`
+	Size     int    `json:"handshake_size"`
+	Silenced string `json:"silenced,omitempty"` // the silence that held it back
+}
+
+var silences struct {
+	mu    sync.Mutex
+	path  string
+	list  []Silence
+	fired []FiredAlert // oldest first, at most ALERT_HISTORY
+}
+
+// check validates a silence and prepares it for matching; now is when it
+// is written.
+func (s *Silence) check(now time.Time) error {
+	s.Comment = strings.TrimSpace(s.Comment)
+	if s.Comment == "" || len(s.Comment) > ANNOTATION_MAX_TEXT {
+		return fmt.Errorf("comment: required, at most %d characters", ANNOTATION_MAX_TEXT)
+	}
+	if s.Rule == "" && s.Subnet == "" {
+		return errors.New("rule/subnet: a silence needs a rule, a subnet or both")
+	}
+	s.subnet = netip.Prefix{}
+	if s.Subnet != "" {
+		prefix, err := netip.ParsePrefix(s.Subnet)
+		if err != nil {
+			return fmt.Errorf("subnet: %q is not a CIDR prefix like 10.0.0.0/24", s.Subnet)
+		}
+		s.subnet = prefix.Masked()
+		s.Subnet = s.subnet.String()
+	}
+	if s.For != "" {
+		d, err := time.ParseDuration(s.For)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("for: %q is not a positive duration like 4h", s.For)
+		}
+		s.Expires = now.Add(d).UTC().Format(time.RFC3339)
+		s.For = ""
+	}
+	var err error
+	if s.expires, err = time.Parse(time.RFC3339, s.Expires); err != nil {
+		return fmt.Errorf("expires: %q is not an RFC 3339 time (or give for)", s.Expires)
+	}
+	if s.expires.Sub(now) > ALERT_SILENCE_MAX {
+		return fmt.Errorf("expires: at most %v ahead", ALERT_SILENCE_MAX)
+	}
+	return nil
+}
+
+// holds reports whether the silence is in force for a rule and a client;
+// rule is "" for the notifiers' fixed triggers.
+func (s Silence) holds(rule, client string, now time.Time) bool {
+	if !now.Before(s.expires) || s.Rule != "" && s.Rule != rule {
+		return false
+	}
+	return !s.subnet.IsValid() || inSubnet(client, s.subnet)
+}
+
+// silencing finds the silence in force for a rule and a client, or "";
+// silences.mu must be held.
+func silencing(rule, client string, now time.Time) string {
+	for _, s := range silences.list {
+		if s.holds(rule, client, now) {
+			return s.ID
+		}
+	}
+	return ""
+}
+
+// silenceAlerts drops the fired rules a silence holds back and records the
+// alerts. Without rules, held tells the notifiers to skip a report whose
+// client a silence covers.
+func silenceAlerts(r GhostReport, fired []AlertRule, rules bool) (kept []AlertRule, held bool) {
+	now := time.Now()
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	if !rules {
+		if id := silencing("", r.ClientIP, now); id != "" {
+			r.logger().Debug("alert silenced", "silence", id)
+			return nil, true
+		}
+		return nil, false
+	}
+	for _, rule := range fired {
+		a := FiredAlert{
+			ID:       newAlertID(),
+			Time:     now.UTC().Format(time.RFC3339),
+			Rule:     rule.ID,
+			RuleName: rule.Name,
+			ConnID:   r.ConnID,
+			Client:   clientHost(r.ClientIP),
+			Subnet:   subnetOf(r.ClientIP, SUBNETS_IPV4_BITS, SUBNETS_IPV6_BITS),
+			Status:   r.Status,
+			Size:     r.HandshakeSize,
+			Silenced: silencing(rule.ID, r.ClientIP, now),
+		}
+		if len(silences.fired) == ALERT_HISTORY {
+			silences.fired = slices.Delete(silences.fired, 0, 1)
+		}
+		silences.fired = append(silences.fired, a)
+		if a.Silenced != "" {
+			r.logger().Debug("alert silenced", "rule", rule.Name, "silence", a.Silenced)
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	return kept, false
+}
+
+func newAlertID() string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ============================================================================
+// STORAGE
+// ============================================================================
+
+// loadSilences reads -alert-silences; a missing file holds none.
+func loadSilences(path string) error {
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	silences.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []Silence
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	now := time.Now()
+	for i := range list {
+		written, err := time.Parse(time.RFC3339, list[i].Updated)
+		if err != nil {
+			written = now
+		}
+		if err := list[i].check(written); err != nil {
+			return fmt.Errorf("%s: silence %s: %v", path, list[i].ID, err)
+		}
+	}
+	silences.list = slices.DeleteFunc(list, func(s Silence) bool { return !now.Before(s.expires) })
+	if len(silences.list) > 0 {
+		slog.Info("alert silences loaded", "file", path, "silences", len(silences.list))
+	}
+	return nil
+}
+
+// saveSilences writes the silences still in force through a temporary
+// file; silences.mu must be held.
+func saveSilences(list []Silence) error {
+	data, _ := json.MarshalIndent(list, "", "  ")
+	tmp := silences.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, silences.path)
+}
+
+// changeSilences saves the list edit makes of the silences in force and
+// answers with the error, if any.
+func changeSilences(w http.ResponseWriter, edit func([]Silence) ([]Silence, bool)) bool {
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	list, ok := edit(activeSilences(time.Now()))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such silence")
+		return false
+	}
+	if err := saveSilences(list); err != nil {
+		slog.Error("cannot save alert silences", "file", silences.path, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the silences")
+		return false
+	}
+	silences.list = list
+	return true
+}
+
+// activeSilences is a copy of the silences in force; silences.mu must be
+// held.
+func activeSilences(now time.Time) []Silence {
+	list := []Silence{}
+	for _, s := range silences.list {
+		if now.Before(s.expires) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func silenceIndex(list []Silence, id string) int {
+	return slices.IndexFunc(list, func(s Silence) bool { return s.ID == id })
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func registerSilences(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/alerts/silences", serveSilences)
+	mux.HandleFunc("POST /api/alerts/silences", serveCreateSilence)
+	mux.HandleFunc("PUT /api/alerts/silences/{id}", serveUpdateSilence)
+	mux.HandleFunc("DELETE /api/alerts/silences/{id}", serveDeleteSilence)
+	mux.HandleFunc("GET /api/alerts/fired", serveFiredAlerts)
+	mux.HandleFunc("POST /api/alerts/fired/{id}/ack", serveAckAlert)
+}
+
+func serveSilences(w http.ResponseWriter, r *http.Request) {
+	silences.mu.Lock()
+	list := activeSilences(time.Now())
+	silences.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"silences": list})
+}
+
+func serveFiredAlerts(w http.ResponseWriter, r *http.Request) {
+	limit := ALERT_HISTORY
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit: not a positive number")
+			return
+		}
+		limit = min(n, ALERT_HISTORY)
+	}
+	silences.mu.Lock()
+	alerts := []FiredAlert{}
+	for i := len(silences.fired) - 1; i >= 0 && len(alerts) < limit; i-- {
+		alerts = append(alerts, silences.fired[i])
+	}
+	silences.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"alerts": alerts})
+}
+
+// decodeSilence reads a silence from a request body; prepare fills in what
+// the route implies before it is checked.
+func decodeSilence(w http.ResponseWriter, r *http.Request, prepare func(*Silence)) (Silence, bool) {
+	var s Silence
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, ALERT_SILENCES_MAX_BODY))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		writeError(w, http.StatusBadRequest, "body: "+err.Error())
+		return s, false
+	}
+	if prepare != nil {
+		prepare(&s)
+	}
+	now := time.Now()
+	if err := s.check(now); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return s, false
+	}
+	if !now.Before(s.expires) {
+		writeError(w, http.StatusBadRequest, "expires: already past")
+		return s, false
+	}
+	if s.Rule != "" {
+		alertRules.mu.Lock()
+		known := alertRuleIndex(s.Rule) >= 0
+		alertRules.mu.Unlock()
+		if !known {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("rule: no alert rule %q", s.Rule))
+			return s, false
+		}
+	}
+	s.Author = "anonymous"
+	if id, ok := requestIdentity(r); ok {
+		s.Author = id.Name
+	}
+	s.Updated = now.UTC().Format(time.RFC3339)
+	s.Created = s.Updated
+	return s, true
+}
+
+// createSilence stores a new silence and answers with it; the audit entry
+// names target, or the silence.
+func createSilence(w http.ResponseWriter, r *http.Request, s Silence, action, target string) {
+	s.ID = newAlertID()
+	if target == "" {
+		target = s.ID
+	}
+	if !changeSilences(w, func(list []Silence) ([]Silence, bool) { return append(list, s), true }) {
+		return
+	}
+	slog.Info("alert silence added", "id", s.ID, "rule", s.Rule, "subnet", s.Subnet, "expires", s.Expires, "author", s.Author)
+	recordAudit(r, action, target, nil, s)
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func serveCreateSilence(w http.ResponseWriter, r *http.Request) {
+	s, ok := decodeSilence(w, r, nil)
+	if !ok {
+		return
+	}
+	s.Alert = ""
+	createSilence(w, r, s, "silence.create", "")
+}
+
+// serveAckAlert silences the rule of a fired alert for its client's network.
+func serveAckAlert(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	silences.mu.Lock()
+	i := slices.IndexFunc(silences.fired, func(a FiredAlert) bool { return a.ID == id })
+	var a FiredAlert
+	if i >= 0 {
+		a = silences.fired[i]
+	}
+	silences.mu.Unlock()
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "no such alert (only the latest are kept)")
+		return
+	}
+	s, ok := decodeSilence(w, r, func(s *Silence) {
+		s.Rule, s.Alert = a.Rule, a.ID
+		if s.Subnet == "" {
+			s.Subnet = a.Subnet
+		}
+		if s.Expires == "" && s.For == "" {
+			s.For = ALERT_ACK_DEFAULT.String()
+		}
+		if strings.TrimSpace(s.Comment) == "" {
+			s.Comment = "acknowledged " + a.RuleName
+		}
+	})
+	if !ok {
+		return
+	}
+	createSilence(w, r, s, "alert.ack", a.ID)
+}
+
+func serveUpdateSilence(w http.ResponseWriter, r *http.Request) {
+	s, ok := decodeSilence(w, r, nil)
+	if !ok {
+		return
+	}
+	var before Silence
+	s.ID = r.PathValue("id")
+	ok = changeSilences(w, func(list []Silence) ([]Silence, bool) {
+		i := silenceIndex(list, s.ID)
+		if i < 0 {
+			return nil, false
+		}
+		before = list[i]
+		s.Created, s.Alert = before.Created, before.Alert
+		list[i] = s
+		return list, true
+	})
+	if !ok {
+		return
+	}
+	recordAudit(r, "silence.update", s.ID, before, s)
+	writeJSON(w, http.StatusOK, s)
+}
+
+func serveDeleteSilence(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var before Silence
+	ok := changeSilences(w, func(list []Silence) ([]Silence, bool) {
+		i := silenceIndex(list, id)
+		if i < 0 {
+			return nil, false
+		}
+		before = list[i]
+		return slices.Delete(list, i, i+1), true
+	})
+	if !ok {
+		return
+	}
+	slog.Info("alert silence lifted", "id", id)
+	recordAudit(r, "silence.delete", id, before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
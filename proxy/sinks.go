@@ -0,0 +1,208 @@
+/*
+Sentinel-PQC Proxy - Report Sinks
+=================================
+Besides ghost_report.json and the JSONL log, every report can be written to
+durable stores for querying history:
+
+  -sqlite reports.db     embedded SQLite database (sqlite.go)
+  -postgres DSN          central PostgreSQL database, batched (postgres.go)
+  -otel URL              OpenTelemetry metrics over OTLP (telemetry.go)
+  -statsd HOST:PORT      StatsD / DogStatsD counters and timers (statsd.go)
+  -syslog URL            RFC 5424 syslog for detections (syslog.go)
+  -webhook URL           signed HTTP alerts for CRITICAL_RISK (webhook.go)
+  -slack / -teams URL    rate-limited channel notifications (chat.go)
+  -smtp HOST:PORT        email alerts, immediate or as digests (email.go)
+  -kafka / -nats         every report to a stream (stream.go)
+  -archive URL           gzipped batches in S3 or Cloud Storage (archive.go)
+  -collector URL         every report to a fleet collector (collector.go)
+
+Sinks are opened at startup and written after every report, on the
+connection's goroutine once the server flight is out. PostgreSQL, the
+webhook, Slack/Teams, email, Kafka, the archive and the collector only hand
+the report to a worker of their own; SQLite, OpenTelemetry, StatsD, syslog
+and NATS write it in place, so a slow one keeps the connection open longer.
+A failing sink is logged and the report still goes to the others. On
+SIGINT/SIGTERM the sinks are closed, so batched reports are flushed before
+the proxy exits.
+*/
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// reportSink is a durable destination for reports.
+type reportSink interface {
+	Name() string
+	Write(report GhostReport) error
+	Close() error
+}
+
+// errSinkClosed is what a queued sink's Write returns once it is closing:
+// handshakes still in flight at shutdown may write after Close.
+var errSinkClosed = errors.New("sink closed, report not written")
+
+var (
+	reportSinksMu sync.Mutex
+	reportSinks   []reportSink
+)
+
+// openReportSinks opens the sinks selected on the command line.
+func openReportSinks() error {
+	if *sqlitePath != "" {
+		s, err := openSQLiteStore(*sqlitePath)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *postgresDSN != "" {
+		s, err := openPostgresSink(*postgresDSN)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *otelEndpoint != "" {
+		s, err := openOTelSink(*otelEndpoint)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *statsdAddr != "" {
+		s, err := openStatsdSink(*statsdAddr, *statsdPrefix, *statsdTags)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *syslogTarget != "" {
+		s, err := openSyslogSink(*syslogTarget)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *webhookURL != "" {
+		s, err := openWebhookSink(*webhookURL, *webhookSecret, *webhookOn)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *slackURL != "" {
+		s, err := openChatSink("slack", *slackURL, *notifyInterval)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *teamsURL != "" {
+		s, err := openChatSink("teams", *teamsURL, *notifyInterval)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *smtpServer != "" {
+		s, err := openEmailSink(*smtpServer, *smtpUser, *emailFrom, *emailTo, *emailMode)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *kafkaBrokers != "" {
+		s, err := openKafkaSink(*kafkaBrokers, *kafkaTopic, *publishKeyMode)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *natsURL != "" {
+		s, err := openNATSSink(*natsURL, *natsSubject, *publishKeyMode)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *archiveTarget != "" {
+		s, err := openArchiveSink(*archiveTarget, *archiveURL, *archiveEvery)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if *collectorURL != "" {
+		s, err := openCollectorSink(*collectorURL)
+		if err != nil {
+			return err
+		}
+		addReportSink(s)
+	}
+	if len(reportSinks) > 0 {
+		go closeSinksOnSignal()
+	}
+	return nil
+}
+
+// closeSinksOnSignal closes every sink and exits on SIGINT or SIGTERM.
+func closeSinksOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	slog.Info("closing report sinks", "signal", sig.String())
+	reportSinksMu.Lock()
+	for _, s := range reportSinks {
+		if err := s.Close(); err != nil {
+			slog.Error("closing report sink failed", "sink", s.Name(), "err", err)
+		}
+	}
+	reportSinksMu.Unlock()
+	os.Exit(0)
+}
+
+func addReportSink(s reportSink) {
+	reportSinksMu.Lock()
+	defer reportSinksMu.Unlock()
+	reportSinks = append(reportSinks, s)
+	slog.Info("writing reports to sink", "sink", s.Name())
+}
+
+// writeReportSinks hands a report to every sink; notifiers only get it if
+// an alert rule says so, once there are rules (alerts.go), and no silence
+// holds it back (silences.go).
+func writeReportSinks(report GhostReport) {
+	reportSinksMu.Lock()
+	sinks := append([]reportSink(nil), reportSinks...)
+	reportSinksMu.Unlock()
+	fired, rules := firedAlertRules(report)
+	fired, held := silenceAlerts(report, fired, rules)
+	for _, s := range sinks {
+		var err error
+		if n, ok := s.(alertNotifier); ok && (rules || held) {
+			err = writeAlert(n, report, fired)
+		} else {
+			err = s.Write(report)
+		}
+		noteSinkWrite(s.Name(), err)
+		if err != nil {
+			report.logger().Error("failed to write report to sink", "sink", s.Name(), "err", err)
+		}
+	}
+}
+
+// clientHost strips the port from a report's client address.
+func clientHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
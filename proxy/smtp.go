@@ -0,0 +1,81 @@
+/*
+Sentinel-PQC Proxy - SMTP STARTTLS Scenario
+===========================================
+Mail servers upgrade plaintext SMTP sessions to TLS with STARTTLS, so the
+PQC key share only appears after an SMTP dialogue. This scenario speaks just
+enough ESMTP (RFC 5321 / RFC 3207) to reach that point:
+
+  S: 220 banner
+  C: EHLO client.example          S: 250-... 250 STARTTLS
+  C: STARTTLS                     S: 220 2.0.0 Ready to start TLS
+  C: ClientHello + key share      <-- measured
+  S: Kyber-768 ciphertext
+
+MTA-to-MTA paths are often the least-maintained part of a network, which is
+exactly where fragmented first flights go missing.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+const SMTP_HOSTNAME = "sentinel-pqc.local"
+
+// readSMTPStartTLS runs the SMTP dialogue up to STARTTLS and returns the
+// ClientHello sent once the client switches to TLS.
+func readSMTPStartTLS(conn net.Conn, lg *slog.Logger) ([]byte, error) {
+	br := bufio.NewReader(conn)
+	reply := func(lines ...string) error {
+		_, err := io.WriteString(conn, strings.Join(lines, "\r\n")+"\r\n")
+		return err
+	}
+
+	if err := reply("220 " + SMTP_HOSTNAME + " ESMTP Sentinel-PQC"); err != nil {
+		return nil, err
+	}
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		lg.Debug("SMTP command", "line", strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			err = reply("250-"+SMTP_HOSTNAME+" greets you", "250-SIZE 35882577", "250-8BITMIME", "250 STARTTLS")
+		case strings.HasPrefix(command, "HELO"):
+			err = reply("250 " + SMTP_HOSTNAME)
+		case command == "STARTTLS":
+			if err := reply("220 2.0.0 Ready to start TLS"); err != nil {
+				return nil, err
+			}
+			// bufio reads straight from the connection when its buffer is
+			// empty, so this returns the first TLS flight as it arrived.
+			buffer := make([]byte, 4096)
+			n, err := br.Read(buffer)
+			if err != nil {
+				return nil, err
+			}
+			return buffer[:n], nil
+		case command == "QUIT":
+			reply("221 2.0.0 Bye")
+			return nil, io.EOF
+		case command == "NOOP" || command == "RSET":
+			err = reply("250 2.0.0 OK")
+		default:
+			err = reply("530 5.7.0 Must issue a STARTTLS command first")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SMTP reply failed: %w", err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Sentinel-PQC Proxy - Socket Buffer Instrumentation
+==================================================
+Before a slow or broken handshake is blamed on the network, local buffering
+has to be ruled out. Every report carries the socket's buffer sizes and the
+kernel's per-socket counters (SO_MEMINFO, Linux) after the handshake:
+
+  rcvbuf / sndbuf   buffer sizes in effect (the kernel doubles what is set)
+  rcv_queued        bytes received but not yet read by the proxy
+  snd_queued        bytes written but not yet acknowledged
+  backlog           bytes waiting for the socket lock
+  drops             packets the kernel dropped at this socket
+
+-rcvbuf / -sndbuf fix the buffer sizes of every listener (accepted
+connections inherit them, which turns off receive autotuning). UDP
+listeners share one socket, so their counters cover the whole listener.
+*/
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// SocketBuffers is the kernel's view of one socket's buffers.
+type SocketBuffers struct {
+	RcvBuf    int `json:"rcvbuf"`
+	SndBuf    int `json:"sndbuf"`
+	RcvQueued int `json:"rcv_queued"`
+	SndQueued int `json:"snd_queued"`
+	Backlog   int `json:"backlog"`
+	Drops     int `json:"drops"`
+}
+
+// attachSocketBuffers records the buffers of the socket under conn.
+func attachSocketBuffers(report *GhostReport, conn any) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return
+	}
+	b, err := socketBuffers(sc)
+	if err != nil {
+		return
+	}
+	report.SocketBuffers = b
+	report.logger().Debug("socket buffers", "rcvbuf", b.RcvBuf, "sndbuf", b.SndBuf,
+		"rcv_queued", b.RcvQueued, "snd_queued", b.SndQueued, "backlog", b.Backlog, "drops", b.Drops)
+	if b.Drops > 0 {
+		report.addNote(fmt.Sprintf("The kernel dropped %d packet(s) at the proxy's socket: local buffering, not only the network, lost data.", b.Drops))
+	}
+}
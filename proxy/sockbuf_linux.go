@@ -0,0 +1,71 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// SO_MEMINFO fields (linux/sock_diag.h)
+const (
+	SK_MEMINFO_RMEM_ALLOC  = 0
+	SK_MEMINFO_RCVBUF      = 1
+	SK_MEMINFO_WMEM_ALLOC  = 2
+	SK_MEMINFO_SNDBUF      = 3
+	SK_MEMINFO_FWD_ALLOC   = 4
+	SK_MEMINFO_WMEM_QUEUED = 5
+	SK_MEMINFO_OPTMEM      = 6
+	SK_MEMINFO_BACKLOG     = 7
+	SK_MEMINFO_DROPS       = 8
+	SK_MEMINFO_VARS        = 9
+)
+
+// socketBuffers reads SO_MEMINFO of a socket.
+func socketBuffers(sc syscall.Conn) (*SocketBuffers, error) {
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var mem [SK_MEMINFO_VARS]uint32
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		size := uint32(unsafe.Sizeof(mem))
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, fd, unix.SOL_SOCKET, unix.SO_MEMINFO,
+			uintptr(unsafe.Pointer(&mem)), uintptr(unsafe.Pointer(&size)), 0)
+		if errno != 0 {
+			sockErr = errno
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+	return &SocketBuffers{
+		RcvBuf:    int(mem[SK_MEMINFO_RCVBUF]),
+		SndBuf:    int(mem[SK_MEMINFO_SNDBUF]),
+		RcvQueued: int(mem[SK_MEMINFO_RMEM_ALLOC]),
+		SndQueued: int(mem[SK_MEMINFO_WMEM_QUEUED]),
+		Backlog:   int(mem[SK_MEMINFO_BACKLOG]),
+		Drops:     int(mem[SK_MEMINFO_DROPS]),
+	}, nil
+}
+
+// setSocketBuffers fixes a socket's buffer sizes (0 leaves one alone).
+func setSocketBuffers(c syscall.RawConn, rcv, snd int) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		if rcv > 0 {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RCVBUF, rcv)
+		}
+		if snd > 0 && sockErr == nil {
+			sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_SNDBUF, snd)
+		}
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
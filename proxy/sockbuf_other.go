@@ -0,0 +1,16 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+func socketBuffers(sc syscall.Conn) (*SocketBuffers, error) {
+	return nil, errors.New("SO_MEMINFO is only supported on Linux")
+}
+
+func setSocketBuffers(c syscall.RawConn, rcv, snd int) error {
+	return errors.New("socket buffer sizes are only set on Linux")
+}
@@ -0,0 +1,173 @@
+/*
+Sentinel-PQC Proxy - SQLite Report Store
+========================================
+-sqlite reports.db keeps every report in an embedded SQLite database, so
+history can be queried without parsing files:
+
+  go run . -sqlite reports.db
+  go run . history -db reports.db -status CRITICAL_RISK -since 24h
+  go run . history -db reports.db -client 192.0.2.10 -json > reports.json
+
+The schema is versioned: migrations run in order at startup and record
+themselves in schema_migrations, so older databases are upgraded in place.
+The reports table keeps the columns worth filtering on (indexed on client
+IP, status and timestamp) plus the full report as JSON.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteMigrations are applied in order; never edit one that has shipped.
+var sqliteMigrations = []string{
+	// 1: reports with the filter columns and the full JSON
+	`CREATE TABLE reports (
+		id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp          TEXT    NOT NULL,
+		client_ip          TEXT    NOT NULL,
+		client_addr        TEXT    NOT NULL,
+		listener           TEXT,
+		algorithm          TEXT    NOT NULL,
+		handshake_size     INTEGER NOT NULL,
+		mtu_budget         INTEGER,
+		fragmentation_risk INTEGER NOT NULL,
+		status             TEXT    NOT NULL,
+		handshake_ms       REAL,
+		report             TEXT    NOT NULL
+	);
+	CREATE INDEX reports_client_ip ON reports(client_ip);
+	CREATE INDEX reports_status    ON reports(status);
+	CREATE INDEX reports_timestamp ON reports(timestamp);`,
+}
+
+// sqliteStore is the SQLite report sink.
+type sqliteStore struct {
+	db   *sql.DB
+	path string
+}
+
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // one writer; WAL lets readers in alongside
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sqliteStore{db: db, path: path}, nil
+}
+
+// migrateSQLite brings the schema up to the latest migration.
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	for v := version + 1; v <= len(sqliteMigrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", v, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, v, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Name() string { return "sqlite:" + s.path }
+
+func (s *sqliteStore) Write(r GhostReport) error {
+	full, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO reports
+		(timestamp, client_ip, client_addr, listener, algorithm, handshake_size, mtu_budget, fragmentation_risk, status, handshake_ms, report)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Timestamp, clientHost(r.ClientIP), r.ClientIP, r.Listener, r.Algorithm, r.HandshakeSize,
+		r.MTUBudget, r.Fragmentation, r.Status, r.HandshakeMs, string(full))
+	return err
+}
+
+// Prune deletes the reports older than before (-retention).
+func (s *sqliteStore) Prune(before time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM reports WHERE timestamp < ?`, before.Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *sqliteStore) Close() error { return s.db.Close() }
+
+// query returns the matching reports, newest first.
+func (s *sqliteStore) query(f historyFilter) ([]GhostReport, error) {
+	q := `SELECT report FROM reports WHERE 1=1`
+	var args []any
+	if f.Client != "" {
+		q += ` AND client_ip = ?`
+		args = append(args, f.Client)
+	}
+	if f.Status != "" {
+		q += ` AND status = ?`
+		args = append(args, f.Status)
+	}
+	if f.Algorithm != "" {
+		q += ` AND algorithm = ?`
+		args = append(args, f.Algorithm)
+	}
+	from, to := f.window()
+	if !from.IsZero() {
+		q += ` AND timestamp >= ?`
+		args = append(args, from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		q += ` AND timestamp < ?`
+		args = append(args, to.Format(time.RFC3339))
+	}
+	q += ` ORDER BY timestamp DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reports []GhostReport
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		r, err := decodeReport([]byte(raw))
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
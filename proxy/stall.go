@@ -0,0 +1,139 @@
+/*
+Sentinel-PQC Proxy - NAT / Keepalive Scenario
+=============================================
+A PQC handshake that trickles over a slow or lossy link can sit idle long
+enough for a NAT or stateful firewall to forget the connection. The "stall"
+scenario pauses the handshake at configurable points:
+
+  -stall 45s -stall-at before-response,mid-flight,after-response
+
+  before-response  after the ClientHello, before any server bytes
+  mid-flight       between the two halves of the server flight
+  after-response   after the server flight, before the client's Finished
+
+and then waits for the client's Finished, its key confirmation
+(keyconfirm.go, client -nat). Reports carry a
+"stall" section whose classification tells an idle-timeout kill apart from a
+reset or a client that gave up. -keepalive turns on TCP keepalives to check
+whether they keep the mapping alive.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+const (
+	STALL_BEFORE_RESPONSE = "before-response"
+	STALL_MID_FLIGHT      = "mid-flight"
+	STALL_AFTER_RESPONSE  = "after-response"
+
+	STALL_FINISHED_WAIT = 10 * time.Second // for the client's Finished after the last stall
+)
+
+// StallReport is how the connection fared across the stalls.
+type StallReport struct {
+	Points         []string `json:"stall_points"`
+	StallMs        float64  `json:"stall_ms"`
+	KeepaliveMs    float64  `json:"tcp_keepalive_ms,omitempty"`
+	Survived       bool     `json:"survived"`
+	FailedAt       string   `json:"failed_after,omitempty"`
+	Classification string   `json:"classification"`
+}
+
+// stallPoints parses and validates -stall-at.
+func stallPoints() ([]string, error) {
+	var points []string
+	for _, p := range strings.Split(*stallAt, ",") {
+		p = strings.TrimSpace(p)
+		switch p {
+		case STALL_BEFORE_RESPONSE, STALL_MID_FLIGHT, STALL_AFTER_RESPONSE:
+			points = append(points, p)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown stall point %q (want %s, %s or %s)", p, STALL_BEFORE_RESPONSE, STALL_MID_FLIGHT, STALL_AFTER_RESPONSE)
+		}
+	}
+	return points, nil
+}
+
+func respondStalled(conn net.Conn, scheme kem.Scheme, clientData []byte, report *GhostReport) error {
+	lg := report.logger()
+	ct, ss, err := encapsulateKeyShare(scheme, clientData, lg)
+	if err != nil {
+		return err
+	}
+	points, _ := stallPoints()
+	st := &StallReport{Points: points, StallMs: durationMs(*stallFor), Classification: "survived"}
+	report.Stall = st
+	report.ServerHelloSize = len(ct) + SERVER_HELLO_OVERHEAD
+
+	if *keepalive > 0 {
+		if tc, ok := netConn(conn).(*net.TCPConn); ok {
+			tc.SetKeepAlive(true)
+			tc.SetKeepAlivePeriod(*keepalive)
+			st.KeepaliveMs = durationMs(*keepalive)
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	stall := func(point string) {
+		for _, p := range points {
+			if p == point {
+				lg.Info("stalling", "point", point, "idle", *stallFor)
+				time.Sleep(*stallFor)
+				st.FailedAt = point
+			}
+		}
+	}
+
+	half := len(ct) / 2
+	stall(STALL_BEFORE_RESPONSE)
+	_, err = conn.Write(ct[:half])
+	if err == nil {
+		stall(STALL_MID_FLIGHT)
+		_, err = conn.Write(ct[half:])
+	}
+	if err == nil {
+		lg.Info("sent ServerHello ciphertext", "bytes", len(ct))
+		stall(STALL_AFTER_RESPONSE)
+
+		// The client proves it received everything by confirming the key
+		err = confirmKey(conn, ss, clientData[:scheme.PublicKeySize()], ct, STALL_FINISHED_WAIT, report)
+	}
+
+	if err == nil {
+		st.Survived, st.FailedAt = true, ""
+		lg.Info("connection survived stalls", "stalls", len(points), "idle", *stallFor)
+		return nil
+	}
+	st.Classification = classifyStallFailure(err)
+	lg.Warn("connection lost after stall", "point", st.FailedAt, "classification", st.Classification, "err", err)
+	report.Status = "NAT_TIMEOUT"
+	report.addNote(fmt.Sprintf("Connection did not survive a %s idle period at %s; NAT/firewall idle timeouts break slow PQC handshakes here.", *stallFor, st.FailedAt))
+	return nil
+}
+
+// classifyStallFailure maps the error after a stall to a failure class.
+func classifyStallFailure(err error) string {
+	switch {
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "reset_after_idle" // a middlebox forgot us and answered with RST
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return "idle_timeout" // the state was dropped silently
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "client_gave_up"
+	default:
+		return "connection_lost"
+	}
+}
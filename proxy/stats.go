@@ -0,0 +1,293 @@
+/*
+Sentinel-PQC Proxy - Statistics
+===============================
+Every report is folded into rolling statistics over the last -stats-window
+(default 1h), so "how big are the handshakes we see" or "which subnet keeps
+fragmenting" can be answered without exporting every report first:
+
+  handshake size   p50 / p95 / p99 / max, overall and per algorithm
+  detections       reports that are not SAFE, and their rate per algorithm
+  subnets          reports and detections per /24 (IPv4) or /48 (IPv6)
+  countries, ases  the same per country and network, with -geoip
+
+The statistics are served at GET /api/stats when -api is set, and every
+-stats-interval a summary is logged and written to stats_summary.json:
+
+  {"window":"1h0m0s","reports":412,"detections":37,"detection_rate":0.0898,
+   "size":{"p50":1216,"p95":1484,"p99":1484,"max":1484},
+   "algorithms":[{"algorithm":"Kyber768","reports":300,...}],
+   "subnets":[{"subnet":"10.0.0.0/24","reports":120,"detections":31}, ...]}
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STATS_FILE        = "stats_summary.json"
+	STATS_MAX_SAMPLES = 100000 // oldest samples go first beyond this
+	STATS_TOP_SUBNETS = 20
+)
+
+// statsSample is what the statistics keep of one report.
+type statsSample struct {
+	at        time.Time
+	size      int
+	algorithm string
+	status    string
+	severity  string
+	subnet    string
+	country   string
+	asn       string
+}
+
+var stats struct {
+	mu      sync.Mutex
+	samples []statsSample // oldest first
+}
+
+// SizeStats are handshake size percentiles in bytes.
+type SizeStats struct {
+	P50 int `json:"p50"`
+	P95 int `json:"p95"`
+	P99 int `json:"p99"`
+	Max int `json:"max"`
+}
+
+// AlgorithmStats are the statistics of one algorithm.
+type AlgorithmStats struct {
+	Algorithm     string    `json:"algorithm"`
+	Reports       int       `json:"reports"`
+	Detections    int       `json:"detections"`
+	DetectionRate float64   `json:"detection_rate"`
+	Size          SizeStats `json:"size"`
+}
+
+// SubnetStats counts the reports of one client subnet.
+type SubnetStats struct {
+	Subnet     string `json:"subnet"`
+	Reports    int    `json:"reports"`
+	Detections int    `json:"detections"`
+}
+
+// GroupStats counts the reports of one country or network.
+type GroupStats struct {
+	Name       string `json:"name"`
+	Reports    int    `json:"reports"`
+	Detections int    `json:"detections"`
+}
+
+// StatsSummary is what /api/stats and stats_summary.json hold.
+type StatsSummary struct {
+	Generated     string           `json:"generated"`
+	Window        string           `json:"window"`
+	Reports       int              `json:"reports"`
+	Detections    int              `json:"detections"`
+	DetectionRate float64          `json:"detection_rate"`
+	Size          SizeStats        `json:"size"`
+	Statuses      map[string]int   `json:"statuses"`
+	Severities    map[string]int   `json:"severities"`
+	Algorithms    []AlgorithmStats `json:"algorithms"`
+	Subnets       []SubnetStats    `json:"subnets"` // most reports first, at most STATS_TOP_SUBNETS
+	Countries     []GroupStats     `json:"countries,omitempty"`
+	ASes          []GroupStats     `json:"ases,omitempty"` // "AS3320 Deutsche Telekom AG"
+}
+
+// ============================================================================
+// RECORDING
+// ============================================================================
+
+// recordStats folds one report into the rolling statistics.
+func recordStats(report GhostReport) {
+	s := statsSample{
+		at:        time.Now(),
+		size:      report.HandshakeSize,
+		algorithm: report.Algorithm,
+		status:    report.Status,
+		severity:  report.Severity,
+		subnet:    clientSubnet(report.ClientIP),
+	}
+	if g := report.Geo; g != nil {
+		s.country = g.Country
+		if g.ASN != 0 {
+			s.asn = strings.TrimSpace(fmt.Sprintf("AS%d %s", g.ASN, g.ASOrg))
+		}
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	stats.samples = append(stats.samples, s)
+	pruneStats(s.at)
+}
+
+// pruneStats drops the samples that left the window; stats.mu must be held.
+func pruneStats(now time.Time) {
+	cut := max(len(stats.samples)-STATS_MAX_SAMPLES, 0)
+	for cut < len(stats.samples) && now.Sub(stats.samples[cut].at) > *statsWindow {
+		cut++
+	}
+	stats.samples = stats.samples[cut:]
+}
+
+// clientSubnet groups a client address into its /24 or /48.
+func clientSubnet(addr string) string {
+	ip, err := netip.ParseAddr(clientHost(addr))
+	if err != nil {
+		return clientHost(addr)
+	}
+	ip = ip.Unmap()
+	bits := 24
+	if ip.Is6() {
+		bits = 48
+	}
+	prefix, _ := ip.Prefix(bits)
+	return prefix.String()
+}
+
+// ============================================================================
+// SUMMARY
+// ============================================================================
+
+// statsSummary computes the statistics over the current window.
+func statsSummary() StatsSummary {
+	stats.mu.Lock()
+	pruneStats(time.Now())
+	samples := append([]statsSample(nil), stats.samples...)
+	stats.mu.Unlock()
+
+	sum := StatsSummary{
+		Generated:  time.Now().UTC().Format(time.RFC3339),
+		Window:     statsWindow.String(),
+		Reports:    len(samples),
+		Statuses:   make(map[string]int),
+		Severities: make(map[string]int),
+		Algorithms: []AlgorithmStats{},
+		Subnets:    []SubnetStats{},
+	}
+	var sizes []int
+	algSizes := make(map[string][]int)
+	algStats := make(map[string]*AlgorithmStats)
+	subnets := make(map[string]*GroupStats)
+	countries := make(map[string]*GroupStats)
+	ases := make(map[string]*GroupStats)
+	for _, s := range samples {
+		detection := s.status != "SAFE"
+		sum.Statuses[s.status]++
+		sum.Severities[s.severity]++
+		sizes = append(sizes, s.size)
+
+		a := algStats[s.algorithm]
+		if a == nil {
+			a = &AlgorithmStats{Algorithm: s.algorithm}
+			algStats[s.algorithm] = a
+		}
+		a.Reports++
+		algSizes[s.algorithm] = append(algSizes[s.algorithm], s.size)
+
+		if detection {
+			sum.Detections++
+			a.Detections++
+		}
+		countGroup(subnets, s.subnet, detection)
+		countGroup(countries, s.country, detection)
+		countGroup(ases, s.asn, detection)
+	}
+	sum.DetectionRate = detectionRate(sum.Detections, sum.Reports)
+	sum.Size = sizeStats(sizes)
+
+	for alg, a := range algStats {
+		a.DetectionRate = detectionRate(a.Detections, a.Reports)
+		a.Size = sizeStats(algSizes[alg])
+		sum.Algorithms = append(sum.Algorithms, *a)
+	}
+	sort.Slice(sum.Algorithms, func(i, j int) bool { return sum.Algorithms[i].Algorithm < sum.Algorithms[j].Algorithm })
+
+	for _, g := range topGroups(subnets) {
+		sum.Subnets = append(sum.Subnets, SubnetStats{Subnet: g.Name, Reports: g.Reports, Detections: g.Detections})
+	}
+	sum.Countries = topGroups(countries)
+	sum.ASes = topGroups(ases)
+	return sum
+}
+
+// countGroup counts a report towards its subnet, country or network.
+func countGroup(groups map[string]*GroupStats, key string, detection bool) {
+	if key == "" {
+		return
+	}
+	g := groups[key]
+	if g == nil {
+		g = &GroupStats{Name: key}
+		groups[key] = g
+	}
+	g.Reports++
+	if detection {
+		g.Detections++
+	}
+}
+
+// topGroups lists the groups with the most reports first.
+func topGroups(groups map[string]*GroupStats) []GroupStats {
+	var list []GroupStats
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Reports != list[j].Reports {
+			return list[i].Reports > list[j].Reports
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > STATS_TOP_SUBNETS {
+		list = list[:STATS_TOP_SUBNETS]
+	}
+	return list
+}
+
+// sizeStats computes nearest-rank percentiles; it sorts sizes in place.
+func sizeStats(sizes []int) SizeStats {
+	if len(sizes) == 0 {
+		return SizeStats{}
+	}
+	sort.Ints(sizes)
+	rank := func(p int) int { return sizes[(p*len(sizes)+99)/100-1] }
+	return SizeStats{P50: rank(50), P95: rank(95), P99: rank(99), Max: sizes[len(sizes)-1]}
+}
+
+func detectionRate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(n)/float64(total)*10000+0.5)) / 10000
+}
+
+// runStatsSummaries logs and saves a summary every -stats-interval.
+func runStatsSummaries(interval time.Duration) {
+	for range time.Tick(interval) {
+		sum := statsSummary()
+		if sum.Reports == 0 {
+			continue
+		}
+		slog.Info("statistics summary",
+			"window", sum.Window,
+			"reports", sum.Reports,
+			"detections", sum.Detections,
+			"detection_rate", sum.DetectionRate,
+			"size_p50", sum.Size.P50,
+			"size_p95", sum.Size.P95,
+			"size_p99", sum.Size.P99)
+		data, _ := json.MarshalIndent(sum, "", "  ")
+		if err := os.WriteFile(STATS_FILE, data, 0644); err != nil {
+			slog.Error("failed to write statistics", "file", STATS_FILE, "err", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Sentinel-PQC Proxy - StatsD
+===========================
+For shops on Datadog or a StatsD daemon rather than Prometheus, -statsd sends
+one UDP packet of metrics per report:
+
+  go run . -statsd 127.0.0.1:8125                 # plain StatsD
+  go run . -statsd 127.0.0.1:8125 -statsd-tags    # DogStatsD tags
+
+  sentinel_pqc.events.critical_risk:1|c    one counter per verdict
+  sentinel_pqc.handshake:12.4|ms           handshake time
+  sentinel_pqc.client_hello_bytes:1636|ms  hello size (timer = distribution)
+  sentinel_pqc.server_flight_bytes:1120|ms
+
+With -statsd-tags the verdict, algorithm and listener travel as tags
+(events:1|c|#status:critical_risk,...) instead of in the metric name.
+Sending is fire-and-forget; a missing daemon never slows a handshake.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// STATSD_MAX_PACKET keeps a report's metrics in one unfragmented datagram.
+const STATSD_MAX_PACKET = 1432
+
+type statsdSink struct {
+	addr   string
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+func openStatsdSink(addr, prefix string, tags bool) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &statsdSink{addr: addr, conn: conn, prefix: prefix, tags: tags}, nil
+}
+
+func (s *statsdSink) Name() string { return "statsd:" + s.addr }
+
+func (s *statsdSink) Write(r GhostReport) error {
+	status := statsdName(r.Status)
+	var lines []string
+	if s.tags {
+		tags := fmt.Sprintf("|#status:%s,algorithm:%s", status, statsdName(r.Algorithm))
+		if r.Listener != "" {
+			tags += ",listener:" + r.Listener
+		}
+		lines = append(lines, s.prefix+"events:1|c"+tags)
+		lines = append(lines, s.timings(r, tags)...)
+	} else {
+		lines = append(lines,
+			fmt.Sprintf("%sevents.%s:1|c", s.prefix, status),
+			fmt.Sprintf("%salgorithms.%s:1|c", s.prefix, statsdName(r.Algorithm)),
+		)
+		lines = append(lines, s.timings(r, "")...)
+	}
+	return s.send(lines)
+}
+
+// timings are the per-event measurements, sent as timers so the daemon
+// keeps percentiles of them.
+func (s *statsdSink) timings(r GhostReport, tags string) []string {
+	lines := []string{fmt.Sprintf("%sclient_hello_bytes:%d|ms%s", s.prefix, r.HandshakeSize, tags)}
+	if r.HandshakeMs > 0 {
+		lines = append(lines, fmt.Sprintf("%shandshake:%.3f|ms%s", s.prefix, r.HandshakeMs, tags))
+	}
+	if r.ServerHelloSize > 0 {
+		lines = append(lines, fmt.Sprintf("%sserver_flight_bytes:%d|ms%s", s.prefix, r.ServerHelloSize, tags))
+	}
+	return lines
+}
+
+// send packs lines into as few datagrams as fit under STATSD_MAX_PACKET.
+func (s *statsdSink) send(lines []string) error {
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > STATSD_MAX_PACKET {
+			if _, err := s.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	_, err := s.conn.Write(packet)
+	return err
+}
+
+func (s *statsdSink) Close() error { return s.conn.Close() }
+
+// statsdName lowercases a value and replaces the characters StatsD reserves.
+func statsdName(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', ' ', '.', '/':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(v))
+}
@@ -0,0 +1,296 @@
+/*
+Sentinel-PQC Proxy - Status & Health
+====================================
+With -api, GET /status is the one place to ask whether the proxy is well,
+for monitoring systems and the dashboard alike:
+
+  curl -s localhost:9090/status | jq .
+  curl -sf localhost:9090/status >/dev/null || echo degraded
+
+It reports the uptime, the connections being handled and handled since the
+start, the KEM schemes the listeners load, every report sink with its
+writes, failures and last error, the last error the proxy logged, and what
+the process uses: goroutines, heap, garbage collections and CPU time, so a
+load run (the client's -rate) can put a price on each handshake. The
+answer is 200 while everything is fine and 503 once a sink has failed within
+the last STATUS_SINK_GRACE, so a plain HTTP check can alert on it.
+
+Sink errors are picked up from the log: failures of writeReportSinks carry
+sink=NAME, and the batching sinks log theirs as "postgres: ...",
+"kafka: ...", so a batch dropped long after Write returned still shows.
+*/
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// STATUS_SINK_GRACE is how long a sink error keeps /status degraded.
+const STATUS_SINK_GRACE = 5 * time.Minute
+
+var runtimeStatus struct {
+	active  atomic.Int64 // connections and datagram exchanges in progress
+	handled atomic.Int64 // since the start
+	reports atomic.Int64 // stored
+
+	mu     sync.Mutex
+	sinks  map[string]*SinkHealth
+	errors int
+	last   *StatusError
+}
+
+// SinkHealth is one report sink in /status.
+type SinkHealth struct {
+	Name        string `json:"name"`
+	Healthy     bool   `json:"healthy"`
+	Writes      int    `json:"writes"`
+	Failures    int    `json:"failures"`
+	LastWrite   string `json:"last_write,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt string `json:"last_error_at,omitempty"`
+
+	lastErrorAt time.Time
+}
+
+// StatusError is the last error the proxy logged.
+type StatusError struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+	Err     string `json:"err,omitempty"`
+	Sink    string `json:"sink,omitempty"`
+}
+
+// ProcessStatus is what the proxy process uses, in /status.
+type ProcessStatus struct {
+	Goroutines int     `json:"goroutines"`
+	HeapBytes  uint64  `json:"heap_bytes"` // live and not yet collected objects
+	SysBytes   uint64  `json:"sys_bytes"`  // obtained from the OS
+	GCCycles   uint32  `json:"gc_cycles"`
+	GCPauseMs  float64 `json:"gc_pause_ms"` // total stop-the-world time
+	CPUSeconds float64 `json:"cpu_seconds"` // Go code and GC, estimated by the runtime
+}
+
+// CPU_METRICS add up to the CPU time the process spent, without idle time.
+var CPU_METRICS = []string{"/cpu/classes/user:cpu-seconds", "/cpu/classes/gc/total:cpu-seconds", "/cpu/classes/scavenge/total:cpu-seconds"}
+
+func processStatus() ProcessStatus {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	samples := make([]metrics.Sample, len(CPU_METRICS))
+	for i, name := range CPU_METRICS {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	var cpu float64
+	for _, s := range samples {
+		if s.Value.Kind() == metrics.KindFloat64 {
+			cpu += s.Value.Float64()
+		}
+	}
+	return ProcessStatus{
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  m.HeapAlloc,
+		SysBytes:   m.Sys,
+		GCCycles:   m.NumGC,
+		GCPauseMs:  float64(m.PauseTotalNs) / 1e6,
+		CPUSeconds: float64(int64(cpu*1000+0.5)) / 1000,
+	}
+}
+
+// trackConnection counts a connection or datagram exchange as active until
+// the returned func is called.
+func trackConnection() func() {
+	runtimeStatus.active.Add(1)
+	runtimeStatus.handled.Add(1)
+	return func() { runtimeStatus.active.Add(-1) }
+}
+
+// noteSinkWrite counts a write to a sink; its errors arrive through the log.
+func noteSinkWrite(name string, err error) {
+	runtimeStatus.mu.Lock()
+	defer runtimeStatus.mu.Unlock()
+	h := sinkHealth(name)
+	h.Writes++
+	if err != nil {
+		h.Failures++
+		return
+	}
+	h.LastWrite = time.Now().UTC().Format(time.RFC3339)
+}
+
+// sinkHealth returns the entry of a sink; runtimeStatus.mu must be held.
+func sinkHealth(name string) *SinkHealth {
+	if runtimeStatus.sinks == nil {
+		runtimeStatus.sinks = make(map[string]*SinkHealth)
+	}
+	h, ok := runtimeStatus.sinks[name]
+	if !ok {
+		h = &SinkHealth{Name: name}
+		runtimeStatus.sinks[name] = h
+	}
+	return h
+}
+
+// ============================================================================
+// ERROR TRACKING
+// ============================================================================
+
+// errorTracker remembers the last error logged, and the last warning or
+// error of each sink, before passing records on.
+type errorTracker struct {
+	slog.Handler
+}
+
+func (t errorTracker) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || t.Handler.Enabled(ctx, level)
+}
+
+func (t errorTracker) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		noteLoggedError(r)
+	}
+	if !t.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return t.Handler.Handle(ctx, r)
+}
+
+func (t errorTracker) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return errorTracker{t.Handler.WithAttrs(attrs)}
+}
+
+func (t errorTracker) WithGroup(name string) slog.Handler {
+	return errorTracker{t.Handler.WithGroup(name)}
+}
+
+func noteLoggedError(r slog.Record) {
+	e := StatusError{Time: r.Time.UTC().Format(time.RFC3339), Message: r.Message}
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "err":
+			e.Err = a.Value.String()
+		case "sink":
+			e.Sink = a.Value.String()
+		}
+		return true
+	})
+
+	runtimeStatus.mu.Lock()
+	defer runtimeStatus.mu.Unlock()
+	var sinks []*SinkHealth
+	if e.Sink != "" {
+		sinks = append(sinks, sinkHealth(e.Sink))
+	} else if kind, _, ok := strings.Cut(r.Message, ": "); ok && !strings.Contains(kind, " ") {
+		// "postgres: dropping reports" belongs to every postgres:... sink
+		for name, h := range runtimeStatus.sinks {
+			if strings.HasPrefix(name, kind+":") {
+				sinks = append(sinks, h)
+			}
+		}
+	}
+	for _, h := range sinks {
+		h.LastError = r.Message
+		if e.Err != "" {
+			h.LastError += ": " + e.Err
+		}
+		h.lastErrorAt = r.Time
+		h.LastErrorAt = e.Time
+	}
+	if r.Level >= slog.LevelError {
+		runtimeStatus.errors++
+		runtimeStatus.last = &e
+	}
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func registerStatus(mux *http.ServeMux) {
+	mux.HandleFunc("GET /status", serveStatus)
+}
+
+func serveStatus(w http.ResponseWriter, r *http.Request) {
+	status, healthy := statusDocument()
+	code := http.StatusOK
+	if !healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+// statusDocument is the /status answer; healthy is false when it is
+// degraded.
+func statusDocument() (status map[string]any, healthy bool) {
+	runtimeConfig.mu.Lock()
+	started := runtimeConfig.started
+	schemes := []string{}
+	if runtimeConfig.algorithm != "" {
+		schemes = append(schemes, runtimeConfig.algorithm)
+	}
+	for _, p := range runtimeConfig.listeners {
+		for _, s := range p.live().Schemes {
+			if !slices.Contains(schemes, s.Name()) {
+				schemes = append(schemes, s.Name())
+			}
+		}
+	}
+	listeners := len(runtimeConfig.listeners)
+	runtimeConfig.mu.Unlock()
+
+	reportSinksMu.Lock()
+	names := make([]string, len(reportSinks))
+	for i, s := range reportSinks {
+		names[i] = s.Name()
+	}
+	reportSinksMu.Unlock()
+
+	healthy = true
+	sinks := []SinkHealth{}
+	runtimeStatus.mu.Lock()
+	for _, name := range names {
+		h := *sinkHealth(name)
+		h.Healthy = h.lastErrorAt.IsZero() || time.Since(h.lastErrorAt) > STATUS_SINK_GRACE
+		healthy = healthy && h.Healthy
+		sinks = append(sinks, h)
+	}
+	errs := map[string]any{"count": runtimeStatus.errors}
+	if runtimeStatus.last != nil {
+		errs["last"] = *runtimeStatus.last
+	}
+	runtimeStatus.mu.Unlock()
+
+	status = map[string]any{
+		"status":         "ok",
+		"mode":           "proxy",
+		"started":        started.UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(started).Seconds()),
+		"connections": map[string]int64{
+			"active":  runtimeStatus.active.Load(),
+			"handled": runtimeStatus.handled.Load(),
+		},
+		"reports":   runtimeStatus.reports.Load(),
+		"listeners": listeners,
+		"schemes":   schemes,
+		"sinks":     sinks,
+		"errors":    errs,
+		"process":   processStatus(),
+	}
+	if collectorMode {
+		status["mode"] = "collector"
+	}
+	if !healthy {
+		status["status"] = "degraded"
+	}
+	return status, healthy
+}
@@ -0,0 +1,227 @@
+/*
+Sentinel-PQC Proxy - Kafka / NATS Publishing
+============================================
+-kafka and -nats publish every report as JSON to a stream, for pipelines that
+process Sentinel data with Kafka Streams, Flink, JetStream consumers, ...:
+
+  go run . -kafka broker1:9092,broker2:9092 -kafka-topic sentinel.reports
+  go run . -nats nats://nats:4222 -nats-subject sentinel.reports -publish-key algorithm
+
+-publish-key picks what keys a report, client (IP, the default) or
+algorithm. Kafka uses it as the message key, so one client's reports stay
+in order on one partition; NATS appends it to the subject
+(sentinel.reports.10_0_0_7), so consumers can subscribe to
+sentinel.reports.> or to one client. Both carry the status and algorithm as
+message headers (Sentinel-Status, Sentinel-Algorithm).
+
+The message is the report exactly as the report log holds it, so signed
+reports (-sign-key) verify. Publishing is asynchronous and batched; a broker
+outage is logged and never holds up the handshake, and queued messages are
+flushed when the proxy shuts down.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	STREAM_QUEUE_SIZE    = 1024
+	STREAM_BATCH_TIMEOUT = 100 * time.Millisecond
+	STREAM_CLOSE_TIMEOUT = 10 * time.Second
+)
+
+// publishKey returns the key -publish-key selects for a report.
+func publishKey(mode string, r GhostReport) string {
+	if mode == "algorithm" {
+		return r.Algorithm
+	}
+	return clientHost(r.ClientIP)
+}
+
+func checkPublishKey(mode string) error {
+	if mode != "client" && mode != "algorithm" {
+		return fmt.Errorf("unknown -publish-key %q (client, algorithm)", mode)
+	}
+	return nil
+}
+
+// ============================================================================
+// KAFKA
+// ============================================================================
+
+type kafkaSink struct {
+	topic  string
+	key    string
+	writer *kafka.Writer
+	queue  chan kafka.Message // the writer dials brokers inline
+	done   chan struct{}
+	closed bool // queue closed; guarded by mu like every send to it
+	mu     sync.Mutex
+}
+
+func openKafkaSink(brokers, topic, key string) (*kafkaSink, error) {
+	if err := checkPublishKey(key); err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, b := range strings.Split(brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			addrs = append(addrs, b)
+		}
+	}
+	if len(addrs) == 0 || topic == "" {
+		return nil, fmt.Errorf("kafka: -kafka needs brokers and -kafka-topic a topic")
+	}
+	s := &kafkaSink{
+		topic: topic,
+		key:   key,
+		queue: make(chan kafka.Message, STREAM_QUEUE_SIZE),
+		done:  make(chan struct{}),
+	}
+	s.writer = &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{}, // same key, same partition
+		BatchTimeout: STREAM_BATCH_TIMEOUT,
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				slog.Error("kafka: publish failed", "topic", topic, "messages", len(messages), "err", err)
+			}
+		},
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *kafkaSink) run() {
+	defer close(s.done)
+	for msg := range s.queue {
+		if err := s.writer.WriteMessages(context.Background(), msg); err != nil {
+			slog.Error("kafka: publish failed", "topic", s.topic, "err", err)
+		}
+	}
+}
+
+func (s *kafkaSink) Name() string { return "kafka:" + s.topic }
+
+func (s *kafkaSink) Write(r GhostReport) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	msg := kafka.Message{
+		Key:   []byte(publishKey(s.key, r)),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "Sentinel-Status", Value: []byte(r.Status)},
+			{Key: "Sentinel-Algorithm", Value: []byte(r.Algorithm)},
+		},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	select {
+	case s.queue <- msg:
+		return nil
+	default:
+		return fmt.Errorf("queue full, report not published")
+	}
+}
+
+// Close publishes what is queued and flushes the pending batch.
+func (s *kafkaSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	case <-time.After(STREAM_CLOSE_TIMEOUT):
+		return fmt.Errorf("%d report(s) still queued", len(s.queue))
+	}
+	return s.writer.Close()
+}
+
+// ============================================================================
+// NATS
+// ============================================================================
+
+type natsSink struct {
+	subject string
+	key     string
+	conn    *nats.Conn
+}
+
+func openNATSSink(url, subject, key string) (*natsSink, error) {
+	if err := checkPublishKey(key); err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("nats: -nats-subject is empty")
+	}
+	conn, err := nats.Connect(url,
+		nats.Name("sentinel-pqc-proxy"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("nats: disconnected", "err", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			slog.Info("nats: reconnected", "server", c.ConnectedUrlRedacted())
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	return &natsSink{subject: subject, key: key, conn: conn}, nil
+}
+
+func (s *natsSink) Name() string { return "nats:" + s.subject }
+
+func (s *natsSink) Write(r GhostReport) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.subject + "." + natsToken(publishKey(s.key, r)))
+	msg.Data = data
+	msg.Header.Set("Sentinel-Status", r.Status)
+	msg.Header.Set("Sentinel-Algorithm", r.Algorithm)
+	return s.conn.PublishMsg(msg)
+}
+
+// natsToken makes a key usable as one subject token: IPv4 dots and IPv6
+// colons become underscores.
+func natsToken(key string) string {
+	if key == "" {
+		return "unknown"
+	}
+	return strings.Map(func(c rune) rune {
+		switch c {
+		case '.', ':', '*', '>', ' ', '\t':
+			return '_'
+		}
+		return c
+	}, key)
+}
+
+// Close flushes what the client still buffers.
+func (s *natsSink) Close() error {
+	defer s.conn.Close()
+	return s.conn.FlushTimeout(STREAM_CLOSE_TIMEOUT)
+}
@@ -0,0 +1,211 @@
+/*
+Sentinel-PQC Proxy - Subnet Risk Heatmap
+========================================
+With -api, GET /api/subnets folds the stored reports into client networks,
+/24 for IPv4 and /64 for IPv6, and ranks them by how badly post-quantum
+handshakes fare there, which is what a heatmap of affected networks needs:
+
+  curl -s localhost:9090/api/subnets | jq '.subnets[:10]'
+  curl -s 'localhost:9090/api/subnets?since=168h&ipv4=16&min_reports=20'
+
+  risk_score   0-100, the share of the network's handshakes that
+               fragmented, black-holed or timed out on fragments, the same
+               measure as a client's risk score (clients.go)
+
+Networks are ranked by risk score, then by the handshakes in trouble, so
+among equally bad networks the busier one comes first; min_reports leaves
+out networks with too few handshakes to judge. ipv4= and ipv6= choose other
+prefix lengths and top= how many networks are listed (default SUBNETS_TOP).
+Like /api/algorithms it takes the filters of /api/reports and looks at the
+last 24h unless since, from or to say otherwise.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	SUBNETS_MAX_REPORTS = 100000 // per request
+	SUBNETS_TOP         = 100
+	SUBNETS_IPV4_BITS   = 24
+	SUBNETS_IPV6_BITS   = 64
+)
+
+// SubnetRisk is one client network of the heatmap.
+type SubnetRisk struct {
+	Subnet     string         `json:"subnet"`
+	Score      int            `json:"risk_score"`
+	Reports    int            `json:"handshakes"`
+	Fragmented int            `json:"fragmented"`
+	Detections int            `json:"detections"` // not SAFE, fragmented or not
+	Clients    int            `json:"clients"`
+	Statuses   map[string]int `json:"statuses"`
+	Size       SizeStats      `json:"size"`
+	LastSeen   string         `json:"last_seen"`
+}
+
+// SubnetsResponse is the answer of GET /api/subnets.
+type SubnetsResponse struct {
+	Generated string       `json:"generated"`
+	Store     string       `json:"store"`
+	Filters   string       `json:"filters,omitempty"`
+	IPv4Bits  int          `json:"ipv4_bits"`
+	IPv6Bits  int          `json:"ipv6_bits"`
+	Reports   int          `json:"reports"`
+	Truncated bool         `json:"truncated"` // more reports matched than were read
+	Networks  int          `json:"networks"`  // before min_reports and top
+	Subnets   []SubnetRisk `json:"subnets"`
+}
+
+// subnetOf masks a client address to its network, or returns "" when the
+// address does not parse.
+func subnetOf(addr string, bits4, bits6 int) string {
+	ip, err := netip.ParseAddr(clientHost(addr))
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+	bits := bits4
+	if ip.Is6() {
+		bits = bits6
+	}
+	prefix, _ := ip.WithZone("").Prefix(bits)
+	return prefix.String()
+}
+
+// subnetRisks ranks the networks of reports, riskiest first.
+func subnetRisks(reports []GhostReport, bits4, bits6 int) []SubnetRisk {
+	type network struct {
+		SubnetRisk
+		sizes   []int
+		clients map[string]bool
+	}
+	nets := make(map[string]*network)
+	for _, r := range reports {
+		key := subnetOf(r.ClientIP, bits4, bits6)
+		if key == "" {
+			continue
+		}
+		n := nets[key]
+		if n == nil {
+			n = &network{
+				SubnetRisk: SubnetRisk{Subnet: key, Statuses: make(map[string]int)},
+				clients:    make(map[string]bool),
+			}
+			nets[key] = n
+		}
+		n.Reports++
+		if r.fragmentationTrouble() {
+			n.Fragmented++
+		}
+		if r.Status != "SAFE" {
+			n.Detections++
+		}
+		n.Statuses[r.Status]++
+		n.clients[clientHost(r.ClientIP)] = true
+		n.sizes = append(n.sizes, r.HandshakeSize)
+		if r.Timestamp > n.LastSeen {
+			n.LastSeen = r.Timestamp
+		}
+	}
+
+	list := make([]SubnetRisk, 0, len(nets))
+	for _, n := range nets {
+		s := n.SubnetRisk
+		s.Score = percent(s.Fragmented, s.Reports)
+		s.Clients = len(n.clients)
+		s.Size = sizeStats(n.sizes)
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Fragmented != b.Fragmented {
+			return a.Fragmented > b.Fragmented
+		}
+		if a.Reports != b.Reports {
+			return a.Reports > b.Reports
+		}
+		return a.Subnet < b.Subnet
+	})
+	return list
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func serveSubnets(w http.ResponseWriter, r *http.Request) {
+	f, err := parseReportQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("limit") == "" {
+		f.Limit = SUBNETS_MAX_REPORTS
+	}
+	if f.Since == 0 && f.From.IsZero() && f.To.IsZero() {
+		f.Since = ALGORITHMS_DEFAULT_SINCE
+	}
+	bits4, bits6, top, minReports := SUBNETS_IPV4_BITS, SUBNETS_IPV6_BITS, SUBNETS_TOP, 1
+	for _, p := range []struct {
+		key      string
+		n        *int
+		min, max int
+	}{
+		{"ipv4", &bits4, 8, 32},
+		{"ipv6", &bits6, 16, 128},
+		{"top", &top, 1, SUBNETS_MAX_REPORTS},
+		{"min_reports", &minReports, 1, SUBNETS_MAX_REPORTS},
+	} {
+		if v := q.Get(p.key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < p.min || n > p.max {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("%s: must be a number from %d to %d", p.key, p.min, p.max))
+				return
+			}
+			*p.n = n
+		}
+	}
+
+	store, name := historyStore()
+	if store == nil {
+		writeError(w, http.StatusServiceUnavailable, "the proxy keeps no report history (-sqlite, -postgres or -report-log)")
+		return
+	}
+	reports, err := queryReports(store, f)
+	if err != nil {
+		slog.Error("subnet heatmap query failed", "store", name, "err", err)
+		writeError(w, http.StatusInternalServerError, "query failed")
+		return
+	}
+	all := subnetRisks(reports, bits4, bits6)
+	list := []SubnetRisk{}
+	for _, s := range all {
+		if s.Reports >= minReports && len(list) < top {
+			list = append(list, s)
+		}
+	}
+	writeJSON(w, http.StatusOK, SubnetsResponse{
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		Store:     name,
+		Filters:   f.describe(),
+		IPv4Bits:  bits4,
+		IPv6Bits:  bits6,
+		Reports:   len(reports),
+		Truncated: len(reports) == f.Limit,
+		Networks:  len(all),
+		Subnets:   list,
+	})
+}
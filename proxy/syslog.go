@@ -0,0 +1,175 @@
+/*
+Sentinel-PQC Proxy - Syslog
+===========================
+-syslog forwards GHOST detections (every report that is not SAFE) to the
+network team's existing syslog infrastructure as RFC 5424 messages:
+
+  go run . -syslog udp://loghost:514
+  go run . -syslog tcp://loghost:601      # octet-counted framing (RFC 6587)
+  go run . -syslog unix:///dev/log        # local daemon
+
+  <130>1 2026-10-16T16:01:40Z gw sentinel-pqc 4242 CRITICAL_RISK
+    [ghost@32473 client="10.0.0.7" algorithm="Kyber768" size="1484"
+    budget="1412" listener=":4433"] Packet size 1484 > MTU 1412. ...
+
+The MSGID is the report status; structured data carries the numbers a SIEM
+rule would match on. Facility is local0; CRITICAL_RISK and PQC_IMPOSSIBLE
+are sent at severity crit, every other detection at warning. A dropped TCP
+or unix stream connection is redialled on the next message.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SYSLOG_FACILITY     = 16 // local0
+	SYSLOG_SEV_CRIT     = 2
+	SYSLOG_SEV_WARNING  = 4
+	SYSLOG_APP_NAME     = "sentinel-pqc"
+	SYSLOG_SD_ID        = "ghost@32473" // enterprise number reserved for examples (RFC 5612)
+	SYSLOG_DIAL_TIMEOUT = 5 * time.Second
+)
+
+type syslogSink struct {
+	target   string
+	network  string
+	address  string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// openSyslogSink parses udp://, tcp:// or unix:// targets and dials once, so
+// a wrong address fails at startup rather than on the first detection.
+func openSyslogSink(target string) (*syslogSink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("syslog: %w", err)
+	}
+	s := &syslogSink{target: target, network: u.Scheme, address: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("syslog: %q needs a port (udp://host:514)", target)
+		}
+	case "unix":
+		// /dev/log and friends are datagram sockets; rsyslog also offers streams.
+		s.network, s.address = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("syslog: unsupported transport %q (udp, tcp or unix)", u.Scheme)
+	}
+	if s.hostname, err = os.Hostname(); err != nil || s.hostname == "" {
+		s.hostname = "-"
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *syslogSink) dial() error {
+	conn, err := net.DialTimeout(s.network, s.address, SYSLOG_DIAL_TIMEOUT)
+	if err != nil && s.network == "unixgram" {
+		s.network = "unix"
+		conn, err = net.DialTimeout(s.network, s.address, SYSLOG_DIAL_TIMEOUT)
+	}
+	if err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *syslogSink) Name() string { return "syslog:" + s.target }
+
+func (s *syslogSink) Write(r GhostReport) error {
+	if r.Status == "SAFE" {
+		return nil
+	}
+	msg := s.format(r)
+	if s.network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	} else if s.network == "unix" {
+		msg += "\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		// Streams die with the daemon; retry once on a fresh connection
+		s.conn.Close()
+		s.conn = nil
+		if err := s.dial(); err != nil {
+			return err
+		}
+		_, err = s.conn.Write([]byte(msg))
+		return err
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// format renders an RFC 5424 message for a detection.
+func (s *syslogSink) format(r GhostReport) string {
+	severity := SYSLOG_SEV_WARNING
+	if r.Status == "CRITICAL_RISK" || r.Status == "PQC_IMPOSSIBLE" {
+		severity = SYSLOG_SEV_CRIT
+	}
+	timestamp := r.Timestamp
+	if t, err := time.Parse(time.RFC3339Nano, r.Timestamp); err == nil {
+		timestamp = t.UTC().Format(time.RFC3339)
+	}
+
+	params := [][2]string{
+		{"client", clientHost(r.ClientIP)},
+		{"algorithm", r.Algorithm},
+		{"size", fmt.Sprint(r.HandshakeSize)},
+	}
+	if r.MTUBudget > 0 {
+		params = append(params, [2]string{"budget", fmt.Sprint(r.MTUBudget)})
+	}
+	if r.Listener != "" {
+		params = append(params, [2]string{"listener", r.Listener})
+	}
+	if r.Transport != "" {
+		params = append(params, [2]string{"transport", r.Transport})
+	}
+	var sd strings.Builder
+	sd.WriteString("[" + SYSLOG_SD_ID)
+	for _, p := range params {
+		fmt.Fprintf(&sd, ` %s="%s"`, p[0], syslogEscape(p[1]))
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		SYSLOG_FACILITY*8+severity, timestamp, s.hostname, SYSLOG_APP_NAME, os.Getpid(),
+		r.Status, sd.String(), r.Message)
+}
+
+// syslogEscape escapes the characters RFC 5424 reserves in PARAM-VALUE.
+func syslogEscape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
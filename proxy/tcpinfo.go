@@ -0,0 +1,91 @@
+/*
+Sentinel-PQC Proxy - Kernel TCP Statistics
+==========================================
+The MSS each side announced in its SYN is the real per-segment payload
+budget of a connection: it already accounts for the peer's link MTU and any
+MSS clamping on the way. With -mss the proxy reads it from the kernel
+(TCP_INFO on Linux) right after accept and judges the handshake against it
+instead of the listener's static budget.
+
+After every TCP handshake the kernel's segment and retransmission counters
+are attached as tcp_stats, so a fragmentation risk can be checked against
+what the connection actually did on the wire.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// TCPMSS is the MSS state of one accepted connection.
+type TCPMSS struct {
+	Send       int `json:"snd_mss"` // effective MSS for our segments (peer's SYN + our route)
+	Receive    int `json:"rcv_mss"` // kernel's estimate of the peer's segment size
+	Advertised int `json:"advmss"`  // MSS we announced in our SYN-ACK
+}
+
+// TCPStats are the kernel's counters for one connection after its handshake.
+type TCPStats struct {
+	SegmentsOut     int `json:"segs_out"`
+	SegmentsIn      int `json:"segs_in"`
+	DataSegmentsOut int `json:"data_segs_out"`
+	DataSegmentsIn  int `json:"data_segs_in"`
+	Retransmits     int `json:"total_retrans"`
+	BytesRetrans    int `json:"bytes_retrans"`
+	OutOfOrder      int `json:"rcv_ooopack"` // segments received beyond a gap
+}
+
+// TCP options as flagged in tcpi_options (and in the middlebox probe).
+const (
+	TCP_OPT_TIMESTAMPS = 1 << 0
+	TCP_OPT_SACK       = 1 << 1
+	TCP_OPT_WSCALE     = 1 << 2
+)
+
+// tcpOptions is what the handshake of one connection negotiated.
+type tcpOptions struct {
+	Negotiated byte // options both SYNs carried
+	Local      byte // options this host offers (sysctls)
+	SendMSS    int
+	AdvMSS     int
+}
+
+func tcpOptionNames(bits byte) []string {
+	names := []string{}
+	for _, o := range []struct {
+		bit  byte
+		name string
+	}{{TCP_OPT_TIMESTAMPS, "timestamps"}, {TCP_OPT_SACK, "sack"}, {TCP_OPT_WSCALE, "window_scaling"}} {
+		if bits&o.bit != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return names
+}
+
+// attachTCPStats records the connection's kernel counters in the report.
+func attachTCPStats(report *GhostReport, conn net.Conn) {
+	stats, err := connTCPStats(conn)
+	if err != nil {
+		return
+	}
+	report.TCPStats = stats
+	report.logger().Debug("TCP stats", "segments_out", stats.SegmentsOut, "data_out", stats.DataSegmentsOut,
+		"segments_in", stats.SegmentsIn, "data_in", stats.DataSegmentsIn, "retransmits", stats.Retransmits)
+	if stats.Retransmits > 0 {
+		report.addNote(fmt.Sprintf("Kernel retransmitted %d segment(s) (%d bytes) during the handshake.", stats.Retransmits, stats.BytesRetrans))
+	}
+}
+
+// netConn unwraps the proxy's connection wrappers down to the socket.
+func netConn(conn net.Conn) net.Conn {
+	for {
+		w, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return conn
+		}
+		conn = w.NetConn()
+	}
+}
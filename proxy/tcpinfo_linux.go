@@ -0,0 +1,93 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// readTCPInfo returns the kernel's TCP_INFO for a TCP connection.
+func readTCPInfo(conn net.Conn) (*unix.TCPInfo, error) {
+	sc, ok := netConn(conn).(syscall.Conn)
+	if !ok {
+		return nil, errors.New("not a socket connection")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var info *unix.TCPInfo
+	var infoErr error
+	if err := raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return nil, err
+	}
+	return info, infoErr
+}
+
+// connMSS reads the negotiated MSS of an accepted connection.
+func connMSS(conn net.Conn) (*TCPMSS, error) {
+	info, err := readTCPInfo(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &TCPMSS{
+		Send:       int(info.Snd_mss),
+		Receive:    int(info.Rcv_mss),
+		Advertised: int(info.Advmss),
+	}, nil
+}
+
+// connTCPStats reads the segment and retransmission counters of a connection.
+func connTCPStats(conn net.Conn) (*TCPStats, error) {
+	info, err := readTCPInfo(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &TCPStats{
+		SegmentsOut:     int(info.Segs_out),
+		SegmentsIn:      int(info.Segs_in),
+		DataSegmentsOut: int(info.Data_segs_out),
+		DataSegmentsIn:  int(info.Data_segs_in),
+		Retransmits:     int(info.Total_retrans),
+		BytesRetrans:    int(info.Bytes_retrans),
+		OutOfOrder:      int(info.Rcv_ooopack),
+	}, nil
+}
+
+// connTCPOptions reads the options and MSS the connection's SYNs agreed on.
+func connTCPOptions(conn net.Conn) (*tcpOptions, error) {
+	info, err := readTCPInfo(conn)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpOptions{
+		Negotiated: info.Options & (TCP_OPT_TIMESTAMPS | TCP_OPT_SACK | TCP_OPT_WSCALE),
+		Local:      localTCPOptions(),
+		SendMSS:    int(info.Snd_mss),
+		AdvMSS:     int(info.Advmss),
+	}, nil
+}
+
+// localTCPOptions returns the options this host puts in its SYNs.
+func localTCPOptions() byte {
+	var bits byte
+	for sysctl, bit := range map[string]byte{
+		"tcp_timestamps":     TCP_OPT_TIMESTAMPS,
+		"tcp_sack":           TCP_OPT_SACK,
+		"tcp_window_scaling": TCP_OPT_WSCALE,
+	} {
+		v, err := os.ReadFile("/proc/sys/net/ipv4/" + sysctl)
+		if err == nil && strings.TrimSpace(string(v)) != "0" {
+			bits |= bit
+		}
+	}
+	return bits
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func connMSS(conn net.Conn) (*TCPMSS, error) {
+	return nil, errors.New("TCP_INFO is only supported on Linux")
+}
+
+func connTCPStats(conn net.Conn) (*TCPStats, error) {
+	return nil, errors.New("TCP_INFO is only supported on Linux")
+}
+
+func connTCPOptions(conn net.Conn) (*tcpOptions, error) {
+	return nil, errors.New("TCP_INFO is only supported on Linux")
+}
@@ -0,0 +1,220 @@
+/*
+Sentinel-PQC Proxy - OpenTelemetry
+==================================
+-otel sends traces and metrics over OTLP/HTTP, so PQC handshake latency
+shows up in the tracing backend next to the application's own traces:
+
+  go run . -otel http://localhost:4318      # collector, Jaeger, Tempo, ...
+
+Every handshake is one trace (the phase durations also land in the report
+as timings, with or without -otel):
+
+  pqc.handshake            client, listener, transport, status, sizes
+  ├── pqc.read             reading the ClientHello / key share
+  ├── pqc.parse            judging it against the MTU budget
+  └── pqc.respond          the scenario's server flight
+      └── pqc.encapsulate  the KEM encapsulation itself
+
+Metrics: pqc.handshakes (count by status, algorithm, listener),
+pqc.handshake.duration (ms) and pqc.client_hello.size (bytes). Without -otel
+the spans go to OpenTelemetry's no-op provider and cost nothing.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	OTEL_SERVICE_NAME     = "sentinel-pqc-proxy"
+	OTEL_SHUTDOWN_TIMEOUT = 5 * time.Second
+)
+
+var tracer = otel.Tracer(OTEL_SERVICE_NAME)
+
+// ============================================================================
+// TRACES
+// ============================================================================
+
+// handshakeTrace is the span tree of one handshake; it also times the
+// phases for the report.
+type handshakeTrace struct {
+	ctx    context.Context
+	root   trace.Span
+	phases map[string]time.Duration
+	encap  time.Duration // spent in KEM encapsulation
+}
+
+func startHandshakeTrace(peer string, profile listenerProfile, transport string) *handshakeTrace {
+	ctx, span := tracer.Start(context.Background(), "pqc.handshake",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("net.peer.address", peer),
+			attribute.String("pqc.listener", profile.Addr),
+			attribute.String("pqc.transport", transport),
+			attribute.String("pqc.scenario", *scenarioName),
+		))
+	return &handshakeTrace{ctx: ctx, root: span, phases: make(map[string]time.Duration)}
+}
+
+// phase starts a child span; call done with the phase's error when it ends.
+func (t *handshakeTrace) phase(name string) (ctx context.Context, done func(error)) {
+	ctx, span := tracer.Start(t.ctx, "pqc."+name)
+	begin := time.Now()
+	return ctx, func(err error) {
+		t.phases[name] += time.Since(begin)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// annotate puts the report's verdict on the root span.
+func (t *handshakeTrace) annotate(r GhostReport) {
+	t.root.SetAttributes(
+		attribute.String("pqc.algorithm", r.Algorithm),
+		attribute.String("pqc.status", r.Status),
+		attribute.Int("pqc.client_hello.size", r.HandshakeSize),
+		attribute.Int("pqc.server_flight.size", r.ServerHelloSize),
+		attribute.Int("pqc.mtu_budget", r.MTUBudget),
+		attribute.Bool("pqc.fragmentation_risk", r.Fragmentation),
+	)
+}
+
+func (t *handshakeTrace) end() { t.root.End() }
+
+// timings breaks the handshake down for the report; hello is the stream the
+// client flight was read from, nil for datagrams.
+func (t *handshakeTrace) timings(start time.Time, hello *countingConn) *HandshakeTimings {
+	tm := &HandshakeTimings{
+		ParseMs:       durationMs(t.phases["parse"]),
+		EncapsulateMs: durationMs(t.encap),
+		RespondMs:     durationMs(t.phases["respond"]),
+		TotalMs:       durationMs(time.Since(start)),
+	}
+	if hello != nil && !hello.first.IsZero() {
+		tm.TTFBMs = durationMs(hello.first.Sub(start))
+		tm.ReadMs = durationMs(hello.last.Sub(start))
+	}
+	return tm
+}
+
+// tracedScheme wraps a KEM so its encapsulations become spans under ctx and
+// count towards the handshake's encapsulation time, whichever scenario
+// performs them.
+type tracedScheme struct {
+	kem.Scheme
+	ctx context.Context
+	tr  *handshakeTrace
+}
+
+func (s tracedScheme) Encapsulate(pk kem.PublicKey) ([]byte, []byte, error) {
+	_, span := tracer.Start(s.ctx, "pqc.encapsulate", trace.WithAttributes(attribute.String("pqc.algorithm", s.Name())))
+	defer span.End()
+	begin := time.Now()
+	defer func() { s.tr.encap += time.Since(begin) }()
+	return s.Scheme.Encapsulate(pk)
+}
+
+// ============================================================================
+// EXPORT & METRICS
+// ============================================================================
+
+// otelSink records metrics for every report and owns the OTLP exporters;
+// closing it flushes pending spans and metrics.
+type otelSink struct {
+	endpoint string
+	traces   *sdktrace.TracerProvider
+	metrics  *sdkmetric.MeterProvider
+
+	handshakes metric.Int64Counter
+	duration   metric.Float64Histogram
+	helloSize  metric.Int64Histogram
+}
+
+func openOTelSink(endpoint string) (*otelSink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("otel: endpoint %q is not a URL like http://localhost:4318", endpoint)
+	}
+	ctx := context.Background()
+	traceOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	metricOpts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(u.Host)}
+	if u.Scheme != "https" {
+		traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+		metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+	}
+	traceExporter, err := otlptracehttp.New(ctx, traceOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("otel: %w", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx, metricOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("otel: %w", err)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", OTEL_SERVICE_NAME))
+	s := &otelSink{
+		endpoint: endpoint,
+		traces:   sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res)),
+		metrics:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res)),
+	}
+	otel.SetTracerProvider(s.traces)
+	otel.SetMeterProvider(s.metrics)
+
+	meter := s.metrics.Meter(OTEL_SERVICE_NAME)
+	if s.handshakes, err = meter.Int64Counter("pqc.handshakes", metric.WithDescription("Handshakes by verdict")); err != nil {
+		return nil, err
+	}
+	if s.duration, err = meter.Float64Histogram("pqc.handshake.duration", metric.WithUnit("ms")); err != nil {
+		return nil, err
+	}
+	if s.helloSize, err = meter.Int64Histogram("pqc.client_hello.size", metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *otelSink) Name() string { return "otlp:" + s.endpoint }
+
+func (s *otelSink) Write(r GhostReport) error {
+	ctx := context.Background()
+	attrs := metric.WithAttributes(
+		attribute.String("status", r.Status),
+		attribute.String("algorithm", r.Algorithm),
+		attribute.String("listener", r.Listener),
+	)
+	s.handshakes.Add(ctx, 1, attrs)
+	s.helloSize.Record(ctx, int64(r.HandshakeSize), attrs)
+	if r.HandshakeMs > 0 {
+		s.duration.Record(ctx, r.HandshakeMs, attrs)
+	}
+	return nil
+}
+
+func (s *otelSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), OTEL_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := s.traces.Shutdown(ctx); err != nil {
+		return err
+	}
+	return s.metrics.Shutdown(ctx)
+}
@@ -0,0 +1,101 @@
+/*
+Sentinel-PQC Proxy - TCP Fast Open
+==================================
+TFO lets a returning client put data into its SYN, so the server can answer
+the ClientHello one round trip earlier. That only helps if the whole
+ClientHello fits into the SYN: the SYN has room for one MSS minus the TCP
+option space (TFO cookie, timestamps, SACK, window scaling), and whatever
+does not fit follows after the SYN-ACK, where it would have gone without
+TFO. With ML-KEM key shares the hello usually does not fit.
+
+With -tfo the listeners accept SYN data (Linux, net.ipv4.tcp_fastopen must
+include the server bit 2) and every report carries a "tfo" section: whether
+the kernel accepted data in the SYN, the SYN data window, and how many
+bytes of the hello spill out of it. With -capture the SYN payload actually
+seen on the wire is recorded too. Clients opt in with -tfo.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+const (
+	TFO_QUEUE_LEN     = 256 // pending TFO requests per listener
+	TFO_OPTION_SPACE  = 40  // maximum TCP option space a data SYN reserves
+	TCPI_OPT_SYN_DATA = 0x20
+
+	// The proxy accepts a TFO connection when the SYN arrives, so the first
+	// read may return only the SYN data; the rest follows after the SYN-ACK
+	TFO_GATHER_WINDOW = 300 * time.Millisecond
+)
+
+// TFOReport is how the ClientHello fared against the SYN data window.
+type TFOReport struct {
+	SYNDataAccepted bool `json:"syn_data_accepted"`        // kernel accepted data carried in the SYN
+	SYNDataBytes    int  `json:"syn_data_bytes,omitempty"` // SYN payload seen on the wire (-capture)
+	SYNWindow       int  `json:"syn_window_bytes"`
+	KeyShareFits    bool `json:"key_share_fits"`
+	SpillBytes      int  `json:"spill_bytes,omitempty"` // hello bytes sent after the SYN-ACK
+}
+
+// readFastOpenHello reads the ClientHello of a connection that may have
+// started with SYN data, gathering the segments sent after the SYN-ACK.
+func readFastOpenHello(conn net.Conn, lg *slog.Logger) ([]byte, error) {
+	hello, err := readClientHello(conn, lg)
+	if err != nil {
+		return nil, err
+	}
+	if synData, _ := connSYNData(conn); !synData {
+		return hello, nil
+	}
+	deadline, buffer := time.Now().Add(10*time.Second), make([]byte, 4096)
+	defer conn.SetReadDeadline(deadline)
+	for {
+		conn.SetReadDeadline(time.Now().Add(TFO_GATHER_WINDOW))
+		n, err := conn.Read(buffer)
+		hello = append(hello, buffer[:n]...)
+		if err != nil {
+			return hello, nil
+		}
+	}
+}
+
+// attachTFO records whether the ClientHello could ride in the SYN. Call it
+// after attachWireStats so captured SYN payloads are available.
+func attachTFO(report *GhostReport, conn net.Conn) {
+	if !*tfoEnabled {
+		return
+	}
+	accepted, err := connSYNData(conn)
+	if err != nil {
+		return
+	}
+	window := report.MTUBudget - TFO_OPTION_SPACE
+	tfo := &TFOReport{
+		SYNDataAccepted: accepted,
+		SYNWindow:       window,
+		KeyShareFits:    report.HandshakeSize <= window,
+		SpillBytes:      max(report.HandshakeSize-window, 0),
+	}
+	if report.Wire != nil && report.Wire.SYNDataIn > 0 {
+		// What the SYN really carried beats the model
+		tfo.SYNDataBytes = report.Wire.SYNDataIn
+		tfo.KeyShareFits = tfo.SYNDataBytes >= report.HandshakeSize
+		tfo.SpillBytes = max(report.HandshakeSize-tfo.SYNDataBytes, 0)
+	}
+	report.TFO = tfo
+
+	report.logger().Info("TCP Fast Open", "syn_data_accepted", accepted, "window", window,
+		"hello_bytes", report.HandshakeSize, "spill_bytes", tfo.SpillBytes)
+	switch {
+	case !tfo.KeyShareFits:
+		report.addNote(fmt.Sprintf("TCP Fast Open cannot save the round trip: %d bytes of the ClientHello did not fit into the SYN.", tfo.SpillBytes))
+	case accepted:
+		report.addNote("The ClientHello fit into the TFO SYN: the server flight left one round trip earlier.")
+	}
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableFastOpen is a net.ListenConfig Control hook turning on server TFO.
+func enableFastOpen(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN, TFO_QUEUE_LEN)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// checkFastOpen reports whether the kernel will accept SYN data at all.
+func checkFastOpen() error {
+	v, err := os.ReadFile("/proc/sys/net/ipv4/tcp_fastopen")
+	if err != nil {
+		return err
+	}
+	mode, err := strconv.Atoi(strings.TrimSpace(string(v)))
+	if err != nil {
+		return err
+	}
+	if mode&2 == 0 {
+		return errors.New("net.ipv4.tcp_fastopen lacks the server bit (2): SYN data will be ignored")
+	}
+	return nil
+}
+
+// connSYNData reports whether the connection's SYN carried accepted data.
+func connSYNData(conn net.Conn) (bool, error) {
+	info, err := readTCPInfo(conn)
+	if err != nil {
+		return false, err
+	}
+	return info.Options&TCPI_OPT_SYN_DATA != 0, nil
+}
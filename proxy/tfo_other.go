@@ -0,0 +1,21 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func enableFastOpen(network, address string, c syscall.RawConn) error {
+	return nil
+}
+
+func checkFastOpen() error {
+	return errors.New("TCP Fast Open measurement is only supported on Linux")
+}
+
+func connSYNData(conn net.Conn) (bool, error) {
+	return false, errors.New("TCP_INFO is only supported on Linux")
+}
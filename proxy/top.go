@@ -0,0 +1,446 @@
+/*
+Sentinel-PQC Proxy - Terminal Dashboard
+=======================================
+top follows a proxy's live feed in the terminal, for operators SSHed into a
+probe host without a browser:
+
+  go run . top                                  # -api on localhost:9090
+  go run . top -api http://10.0.0.7:9090 -status CRITICAL_RISK,SUSPECTED_BLACKHOLE
+
+The header shows the proxy's uptime, connections and sink health from
+/status, then this session's handshake sizes and verdicts; below are the
+latest handshakes from /api/live, newest first, with their size against the
+MTU budget. Keys: q quits, p freezes the list, d shows only detections, c
+clears the session. -token, or $SENTINEL_API_TOKEN, is sent when the API asks
+for credentials (read scope).
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	TOP_KEEP         = 500 // reports kept for the list
+	TOP_MAX_SIZES    = 10000
+	TOP_REDRAW       = time.Second
+	TOP_STATUS_EVERY = 5 * time.Second
+	TOP_RECONNECT    = 2 * time.Second
+	TOP_BAR          = 10 // cells of the size bar
+)
+
+const (
+	ansiReset      = "\033[0m"
+	ansiBold       = "\033[1m"
+	ansiDim        = "\033[2m"
+	ansiReverse    = "\033[7m"
+	ansiRed        = "\033[31m"
+	ansiGreen      = "\033[32m"
+	ansiYellow     = "\033[33m"
+	ansiClearLine  = "\033[K"
+	ansiHome       = "\033[H"
+	ansiClearDown  = "\033[J"
+	ansiAltScreen  = "\033[?1049h\033[?25l" // alternate screen, hidden cursor
+	ansiMainScreen = "\033[?25h\033[?1049l"
+)
+
+// topView is what the screen shows; the feed and status pollers fill it in.
+type topView struct {
+	mu      sync.Mutex
+	api     string
+	reports []GhostReport // newest first
+	sizes   []int
+	counts  map[string]int
+	total   int
+	started time.Time
+
+	status     *topStatus
+	statusErr  string
+	feedErr    string
+	paused     bool
+	detections bool
+	changed    chan struct{}
+}
+
+// topStatus is the part of /status the header shows.
+type topStatus struct {
+	Status      string           `json:"status"`
+	Mode        string           `json:"mode"`
+	Uptime      int64            `json:"uptime_seconds"`
+	Connections map[string]int64 `json:"connections"`
+	Reports     int64            `json:"reports"`
+	Schemes     []string         `json:"schemes"`
+	Sinks       []SinkHealth     `json:"sinks"`
+}
+
+func runTop(args []string) {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	apiURL := fs.String("api", "http://localhost:9090", "Base URL of the proxy's -api")
+	token := fs.String("token", os.Getenv(API_TOKEN_ENV), "API token with the read scope (default $"+API_TOKEN_ENV+")")
+	statuses := fs.String("status", "", "Only follow these statuses, comma-separated (e.g. CRITICAL_RISK)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: top [-api URL] [-token T] [-status S,...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if !strings.Contains(*apiURL, "://") {
+		*apiURL = "http://" + *apiURL
+	}
+	base, err := url.Parse(strings.TrimSuffix(*apiURL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		fmt.Fprintf(os.Stderr, "top: -api %q is not an http(s) URL\n", *apiURL)
+		os.Exit(2)
+	}
+
+	t := &topView{api: base.String(), counts: make(map[string]int), started: time.Now(), changed: make(chan struct{}, 1)}
+	go t.follow(base, *token, *statuses)
+	go t.pollStatus(base, *token)
+
+	restore := rawKeys()
+	fmt.Print(ansiAltScreen)
+	defer func() {
+		fmt.Print(ansiMainScreen)
+		restore()
+	}()
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if n, err := os.Stdin.Read(buf); err != nil {
+				return
+			} else if n == 1 {
+				keys <- buf[0]
+			}
+		}
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	tick := time.NewTicker(TOP_REDRAW)
+	defer tick.Stop()
+	for {
+		cols, rows := terminalSize()
+		fmt.Print(t.render(cols, rows))
+		select {
+		case <-signals:
+			return
+		case k := <-keys:
+			if !t.key(k) {
+				return
+			}
+		case <-t.changed:
+		case <-tick.C:
+		}
+	}
+}
+
+// key handles one key press; false quits.
+func (t *topView) key(k byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch k {
+	case 'q', 'Q':
+		return false
+	case 'p', 'P':
+		t.paused = !t.paused
+	case 'd', 'D':
+		t.detections = !t.detections
+	case 'c', 'C':
+		t.reports, t.sizes, t.total = nil, nil, 0
+		t.counts = make(map[string]int)
+		t.started = time.Now()
+	}
+	return true
+}
+
+// ============================================================================
+// FEEDS
+// ============================================================================
+
+// follow reads /api/live, reconnecting while the proxy is away.
+func (t *topView) follow(base *url.URL, token, statuses string) {
+	live := *base
+	live.Scheme = map[string]string{"http": "ws", "https": "wss"}[base.Scheme]
+	live.Path += "/api/live"
+	if statuses != "" {
+		live.RawQuery = url.Values{"status": {statuses}}.Encode()
+	}
+	for {
+		err := t.stream(live.String(), base.String(), token)
+		t.mu.Lock()
+		t.feedErr = err.Error()
+		t.mu.Unlock()
+		t.notify()
+		time.Sleep(TOP_RECONNECT)
+	}
+}
+
+func (t *topView) stream(live, origin, token string) error {
+	cfg, err := websocket.NewConfig(live, origin)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		cfg.Header.Set("Authorization", "Bearer "+token)
+	}
+	ws, err := websocket.DialConfig(cfg)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	t.mu.Lock()
+	t.feedErr = ""
+	t.mu.Unlock()
+	for {
+		var r GhostReport
+		if err := websocket.JSON.Receive(ws, &r); err != nil {
+			return fmt.Errorf("live feed: %w", err)
+		}
+		t.add(r)
+	}
+}
+
+func (t *topView) add(r GhostReport) {
+	t.mu.Lock()
+	t.total++
+	t.counts[r.Status]++
+	t.sizes = append(t.sizes, r.HandshakeSize)
+	if len(t.sizes) > TOP_MAX_SIZES {
+		t.sizes = t.sizes[len(t.sizes)-TOP_MAX_SIZES:]
+	}
+	if !t.paused {
+		t.reports = append([]GhostReport{r}, t.reports[:min(len(t.reports), TOP_KEEP-1)]...)
+	}
+	t.mu.Unlock()
+	t.notify()
+}
+
+// notify asks for a redraw without blocking.
+func (t *topView) notify() {
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// pollStatus refreshes the header from /status.
+func (t *topView) pollStatus(base *url.URL, token string) {
+	client := &http.Client{Timeout: TOP_STATUS_EVERY}
+	for {
+		st, err := fetchTopStatus(client, base.String()+"/status", token)
+		t.mu.Lock()
+		t.status, t.statusErr = st, ""
+		if err != nil {
+			t.statusErr = err.Error()
+		}
+		t.mu.Unlock()
+		t.notify()
+		time.Sleep(TOP_STATUS_EVERY)
+	}
+}
+
+func fetchTopStatus(client *http.Client, url, token string) (*topStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusServiceUnavailable: // 503: degraded
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("/status: authentication required (-token or $%s)", API_TOKEN_ENV)
+	default:
+		return nil, fmt.Errorf("/status: %s", resp.Status)
+	}
+	var st topStatus
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, fmt.Errorf("/status: %w", err)
+	}
+	return &st, nil
+}
+
+// ============================================================================
+// SCREEN
+// ============================================================================
+
+// topSegment is a run of text in one colour; a line is a list of them.
+type topSegment struct{ text, color string }
+
+// renderLine writes segments clipped to cols visible characters and clears
+// the rest of the line.
+func renderLine(b *strings.Builder, cols int, segs ...topSegment) {
+	for _, s := range segs {
+		if cols <= 0 {
+			break
+		}
+		text := s.text
+		if utf8.RuneCountInString(text) > cols {
+			text = string([]rune(text)[:cols])
+		}
+		cols -= utf8.RuneCountInString(text)
+		if s.color != "" {
+			text = s.color + text + ansiReset
+		}
+		b.WriteString(text)
+	}
+	b.WriteString(ansiClearLine)
+}
+
+func plain(format string, a ...any) topSegment { return topSegment{text: fmt.Sprintf(format, a...)} }
+
+// verdictColor is green for SAFE, yellow for MARGINAL and red otherwise.
+func verdictColor(status string) string {
+	switch status {
+	case "SAFE":
+		return ansiGreen
+	case "MARGINAL", "":
+		return ansiYellow
+	}
+	return ansiRed
+}
+
+func (t *topView) render(cols, rows int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	used := 0
+	line := func(segs ...topSegment) {
+		if used < rows-1 {
+			renderLine(&b, cols, segs...)
+			b.WriteString("\r\n")
+			used++
+		}
+	}
+
+	title := []topSegment{{" Sentinel-PQC top ", ansiBold + ansiReverse}, plain("  %s", t.api)}
+	if st := t.status; st != nil {
+		color := ansiGreen
+		if st.Status != "ok" {
+			color = ansiYellow
+		}
+		title = append(title, plain("  %s · up %s · ", st.Mode, time.Duration(st.Uptime)*time.Second), topSegment{st.Status, color})
+	}
+	line(title...)
+	switch st := t.status; {
+	case t.statusErr != "":
+		line(topSegment{" " + t.statusErr, ansiRed})
+	case st != nil:
+		healthy := 0
+		for _, s := range st.Sinks {
+			if s.Healthy {
+				healthy++
+			}
+		}
+		line(plain(" connections %d active, %d handled · reports %d · schemes %s · sinks %d/%d healthy",
+			st.Connections["active"], st.Connections["handled"], st.Reports, strings.Join(st.Schemes, ","), healthy, len(st.Sinks)))
+	default:
+		line(topSegment{" waiting for /status", ansiDim})
+	}
+
+	detections := t.total - t.counts["SAFE"]
+	size := sizeStats(slices.Clone(t.sizes))
+	rate := 0.0
+	if t.total > 0 {
+		rate = float64(detections) / float64(t.total) * 100
+	}
+	line(plain(" session %s · %d handshakes · %d detections (%.1f%%) · size p50 %d B  p95 %d B  max %d B",
+		time.Since(t.started).Truncate(time.Second), t.total, detections, rate, size.P50, size.P95, size.Max))
+	verdicts := []topSegment{plain(" verdicts ")}
+	names := make([]string, 0, len(t.counts))
+	for s := range t.counts {
+		names = append(names, s)
+	}
+	sort.Slice(names, func(i, j int) bool { return t.counts[names[i]] > t.counts[names[j]] })
+	for _, s := range names {
+		verdicts = append(verdicts, plain(" "), topSegment{s, verdictColor(s)}, plain(" %d", t.counts[s]))
+	}
+	line(verdicts...)
+	line()
+
+	line(topSegment{fmt.Sprintf(" %-8s  %-26s  %-10s  %-11s  %-*s  %-22s  %-19s  %s",
+		"TIME", "CLIENT", "ALGORITHM", "SIZE/BUDGET", TOP_BAR, "", "STATUS", "SEVERITY", "LISTENER") + strings.Repeat(" ", cols), ansiReverse})
+	for _, r := range t.reports {
+		if used >= rows-1 {
+			break
+		}
+		if t.detections && r.Status == "SAFE" {
+			continue
+		}
+		at := r.Timestamp
+		if ts, err := time.Parse(time.RFC3339Nano, r.Timestamp); err == nil {
+			at = ts.Local().Format("15:04:05")
+		}
+		client := clientHost(r.ClientIP)
+		if r.Probe != "" {
+			client = r.Probe + " " + client
+		}
+		budget := "?"
+		if r.MTUBudget > 0 {
+			budget = fmt.Sprint(r.MTUBudget)
+		}
+		line(plain(" %-8s  %-26s  %-10s  %-11s  ", at, client, r.Algorithm, fmt.Sprintf("%d/%s", r.HandshakeSize, budget)),
+			sizeBar(r.HandshakeSize, r.MTUBudget),
+			plain("  "), topSegment{fmt.Sprintf("%-22s", r.Status), verdictColor(r.Status)},
+			plain("  %-19s  %s", r.Severity, r.Listener))
+	}
+	if len(t.reports) == 0 {
+		line(topSegment{" waiting for handshakes…", ansiDim})
+	}
+	b.WriteString(ansiClearDown)
+
+	// Footer on the last row
+	fmt.Fprintf(&b, "\033[%d;1H", rows)
+	footer := []topSegment{plain(" q quit  p pause  d detections only  c clear")}
+	if t.paused {
+		footer = append(footer, topSegment{"  [paused]", ansiYellow})
+	}
+	if t.detections {
+		footer = append(footer, topSegment{"  [detections]", ansiYellow})
+	}
+	if t.feedErr != "" {
+		footer = append(footer, topSegment{"  reconnecting: " + t.feedErr, ansiRed})
+	} else {
+		footer = append(footer, topSegment{"  live", ansiGreen})
+	}
+	renderLine(&b, cols, footer...)
+	return b.String()
+}
+
+// sizeBar fills TOP_BAR cells in proportion to size/budget; red once the
+// handshake is over budget.
+func sizeBar(size, budget int) topSegment {
+	if budget <= 0 {
+		return topSegment{text: strings.Repeat(" ", TOP_BAR)}
+	}
+	filled := min(size*TOP_BAR/budget, TOP_BAR)
+	color := ansiGreen
+	if size > budget {
+		color = ansiRed
+	}
+	return topSegment{strings.Repeat("█", filled) + strings.Repeat("░", TOP_BAR-filled), color}
+}
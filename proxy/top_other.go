@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+func terminalSize() (cols, rows int) {
+	return 80, 24
+}
+
+// rawKeys leaves stdin line-buffered: keys take effect after Enter.
+func rawKeys() (restore func()) {
+	return func() {}
+}
@@ -0,0 +1,151 @@
+/*
+Sentinel-PQC Proxy - Handshake Transcripts
+==========================================
+With -transcripts DIR every connection (or datagram exchange) is recorded
+byte for byte into DIR/<time>_<peer>_<scenario>.json:
+
+  {
+    "peer": "127.0.0.1:53122", "scenario": "kyber", "transport": "tcp",
+    "started": "2026-01-01T12:00:00.000000001Z",
+    "events": [
+      {"elapsed_us": 112, "dir": "recv", "offset": 0, "len": 1484, "hex": "..."},
+      {"elapsed_us": 905, "dir": "sent", "offset": 0, "len": 1088, "hex": "..."}
+    ]
+  }
+
+Offsets count bytes per direction, so two runs can be diffed event by event
+to see exactly where the sizes two environments report start to differ, and
+the recv events can be replayed against the proxy.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TRANSCRIPT_RECV = "recv"
+	TRANSCRIPT_SENT = "sent"
+)
+
+type transcriptEvent struct {
+	ElapsedUs int64  `json:"elapsed_us"`
+	Direction string `json:"dir"`
+	Offset    int    `json:"offset"`
+	Length    int    `json:"len"`
+	Data      string `json:"hex"`
+}
+
+// transcript collects the bytes of one connection in both directions.
+type transcript struct {
+	Peer      string            `json:"peer"`
+	Scenario  string            `json:"scenario"`
+	Transport string            `json:"transport"`
+	Started   string            `json:"started"`
+	Events    []transcriptEvent `json:"events"`
+
+	mu      sync.Mutex
+	start   time.Time
+	offsets map[string]int
+	once    sync.Once
+}
+
+func newTranscript(peer, scenario, transport string) *transcript {
+	now := time.Now()
+	return &transcript{
+		Peer:      peer,
+		Scenario:  scenario,
+		Transport: transport,
+		Started:   now.UTC().Format(time.RFC3339Nano),
+		start:     now,
+		offsets:   make(map[string]int),
+	}
+}
+
+func (t *transcript) record(direction string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Events = append(t.Events, transcriptEvent{
+		ElapsedUs: time.Since(t.start).Microseconds(),
+		Direction: direction,
+		Offset:    t.offsets[direction],
+		Length:    len(data),
+		Data:      hex.EncodeToString(data),
+	})
+	t.offsets[direction] += len(data)
+}
+
+// save writes the transcript once, when the connection is done.
+func (t *transcript) save() {
+	t.once.Do(func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if err := os.MkdirAll(*transcriptDir, 0755); err != nil {
+			slog.Error("failed to create transcript directory", "err", err)
+			return
+		}
+		peer := strings.NewReplacer(":", "_", "[", "", "]", "").Replace(t.Peer)
+		name := fmt.Sprintf("%s_%s_%s.json", t.start.Format("20060102T150405.000"), peer, t.Scenario)
+		path := filepath.Join(*transcriptDir, name)
+
+		data, _ := json.MarshalIndent(t, "", "  ")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			slog.Error("failed to write transcript", "err", err)
+			return
+		}
+		slog.Info("transcript saved", "client", t.Peer, "events", len(t.Events),
+			"bytes_in", t.offsets[TRANSCRIPT_RECV], "bytes_out", t.offsets[TRANSCRIPT_SENT], "file", path)
+	})
+}
+
+// transcriptConn records everything read from and written to a stream.
+type transcriptConn struct {
+	net.Conn
+	t *transcript
+}
+
+func (c *transcriptConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.t.record(TRANSCRIPT_RECV, p[:n])
+	return n, err
+}
+
+func (c *transcriptConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.t.record(TRANSCRIPT_SENT, p[:n])
+	return n, err
+}
+
+func (c *transcriptConn) NetConn() net.Conn { return c.Conn }
+
+func (c *transcriptConn) Close() error {
+	err := c.Conn.Close()
+	c.t.save()
+	return err
+}
+
+// transcriptPacketConn records the datagrams sent back to one peer.
+type transcriptPacketConn struct {
+	net.PacketConn
+	t *transcript
+}
+
+func (c *transcriptPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	c.t.record(TRANSCRIPT_SENT, p[:n])
+	return n, err
+}
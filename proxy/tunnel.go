@@ -0,0 +1,508 @@
+/*
+Sentinel-PQC Proxy - CONNECT Tunnel Mode
+========================================
+Audits real PQC traffic instead of the simulated handshake.
+
+  1. Client (browser, curl, corporate egress) sends "CONNECT host:port"
+  2. Proxy dials the origin and answers "200 Connection Established"
+  3. Bytes are relayed untouched while the first TLS handshake message in
+     each direction (ClientHello / ServerHello) is captured
+  4. The report records the in-transit hello sizes, offered ALPN protocols,
+     SNI and the key-share group the origin selected
+
+Try it with:  curl -x http://127.0.0.1:4433 https://pq.cloudflareresearch.com
+
+A CONNECT proxy reachable by others is a way into every network it can
+reach, so tunnels only go to -connect-ports (443 by default) and -connect
+refuses listeners not bound to a loopback address, e.g. the default :4433,
+unless -connect-public says they are meant to be reachable:
+
+  go run . -connect -listen 127.0.0.1:4433
+  go run . -connect -listen :4433 -connect-public -connect-ports 443,8443
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	TUNNEL_DIAL_TIMEOUT  = 10 * time.Second
+	TUNNEL_HELLO_TIMEOUT = 15 * time.Second
+	MAX_HELLO_BUFFER     = 64 * 1024 // Stop sniffing if no hello fits in this
+)
+
+// TLS NamedGroup code points seen in PQC deployments.
+var groupNames = map[uint16]string{
+	0x0017: "secp256r1",
+	0x0018: "secp384r1",
+	0x001d: "x25519",
+	0x0200: "MLKEM512",
+	0x0201: "MLKEM768",
+	0x0202: "MLKEM1024",
+	0x11eb: "SecP256r1MLKEM768",
+	0x11ec: "X25519MLKEM768",
+	0x11ed: "SecP384r1MLKEM1024",
+	0x6399: "X25519Kyber768Draft00",
+}
+
+func groupName(id uint16) string {
+	if name, ok := groupNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", id)
+}
+
+// ============================================================================
+// ACCESS
+// ============================================================================
+
+// checkConnectListeners refuses to run an open relay: every listener must be
+// bound to a loopback address unless -connect-public, and -connect-ports
+// must parse.
+func checkConnectListeners(listeners []listenerProfile) error {
+	if _, err := parseConnectPorts(*connectPorts); err != nil {
+		return err
+	}
+	if *connectPublic {
+		return nil
+	}
+	for _, p := range listeners {
+		host, _, err := net.SplitHostPort(p.Addr)
+		if err != nil {
+			return fmt.Errorf("-listen %s: %w", p.Addr, err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("-connect on %s would relay anyone who reaches it to -connect-ports: listen on a loopback address, e.g. 127.0.0.1:4433, or set -connect-public", p.Addr)
+		}
+	}
+	return nil
+}
+
+// parseConnectPorts parses -connect-ports.
+func parseConnectPorts(s string) ([]int, error) {
+	var ports []int
+	for _, f := range strings.Split(s, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("-connect-ports %q: %q is not a port", s, f)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// connectAllowed reports whether a tunnel may go to origin (host:port).
+func connectAllowed(origin string) error {
+	_, portStr, err := net.SplitHostPort(origin)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("port %q is not a number", portStr)
+	}
+	ports, _ := parseConnectPorts(*connectPorts)
+	if !slices.Contains(ports, port) {
+		return fmt.Errorf("port %d is not in -connect-ports %s", port, *connectPorts)
+	}
+	return nil
+}
+
+// ============================================================================
+// TUNNEL HANDLER
+// ============================================================================
+
+func handleTunnel(conn net.Conn, profile listenerProfile) {
+	defer conn.Close()
+	defer trackConnection()()
+	profile = profile.live()
+	clientIP := conn.RemoteAddr().String()
+
+	lg := newConnLog()
+	lg.Info("new tunnel client", "client", clientIP, "listener", profile.Addr)
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		lg.Error("invalid CONNECT request", "err", err)
+		return
+	}
+	if req.Method != http.MethodConnect {
+		lg.Error("expected CONNECT", "method", req.Method)
+		io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\nConnection: close\r\n\r\n")
+		return
+	}
+
+	origin := req.Host
+	lg.Info("CONNECT", "origin", origin)
+	if err := connectAllowed(origin); err != nil {
+		lg.Warn("CONNECT refused", "origin", origin, "err", err)
+		io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\nConnection: close\r\n\r\n")
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", origin, TUNNEL_DIAL_TIMEOUT)
+	if err != nil {
+		lg.Error("dial failed", "origin", origin, "err", err)
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nConnection: close\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		lg.Error("failed to confirm tunnel", "err", err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// Relay both directions, teeing bytes into the hello sniffers.
+	// The bufio.Reader may already hold the start of the ClientHello.
+	clientSniff := newHelloSniffer()
+	serverSniff := newHelloSniffer()
+	clientSide := io.MultiReader(bytes.NewReader(peekBuffered(br)), conn)
+
+	closed := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, io.TeeReader(clientSide, clientSniff))
+		if tcp, ok := upstream.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		closed <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, io.TeeReader(upstream, serverSniff))
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		closed <- struct{}{}
+	}()
+
+	ch, sh, err := awaitHellos(clientSniff, serverSniff)
+	if err != nil {
+		lg.Info("no TLS handshake captured", "origin", origin, "err", err)
+	} else {
+		report := buildTunnelReport(clientIP, origin, ch, sh, profile, lg)
+		attachWireStats(&report)
+		saveReport(report)
+		logReportSummary(report)
+	}
+
+	<-closed
+	<-closed
+	lg.Info("tunnel closed", "origin", origin)
+}
+
+func peekBuffered(br *bufio.Reader) []byte {
+	buffered, _ := br.Peek(br.Buffered())
+	return buffered
+}
+
+func awaitHellos(client, server *helloSniffer) (helloCapture, helloCapture, error) {
+	timeout := time.After(TUNNEL_HELLO_TIMEOUT)
+
+	var ch, sh helloCapture
+	select {
+	case ch = <-client.result:
+	case <-timeout:
+		return ch, sh, errors.New("timed out waiting for ClientHello")
+	}
+	if ch.Err != nil {
+		return ch, sh, ch.Err
+	}
+
+	select {
+	case sh = <-server.result:
+	case <-timeout:
+		return ch, sh, errors.New("timed out waiting for ServerHello")
+	}
+	return ch, sh, sh.Err
+}
+
+func buildTunnelReport(clientIP, origin string, ch, sh helloCapture, profile listenerProfile, lg connLog) GhostReport {
+	chInfo := parseClientHello(ch.Message)
+	shInfo := parseServerHello(sh.Message)
+
+	// The key share that matters is the one the origin actually selected
+	algorithm := groupName(shInfo.Group)
+	pkSize := 0
+	for _, ks := range chInfo.KeyShares {
+		if ks.Group == shInfo.Group {
+			pkSize = ks.Size
+		}
+	}
+
+	lg.Info("ClientHello", "bytes", ch.WireSize, "records", ch.Records, "sni", chInfo.SNI, "alpn", describeALPN(chInfo.ALPN))
+	for _, ks := range chInfo.KeyShares {
+		lg.Debug("key_share", "group", groupName(ks.Group), "bytes", ks.Size)
+	}
+	lg.Info("ServerHello", "bytes", sh.WireSize, "group", algorithm, "key_share_bytes", shInfo.KeyShareSize)
+
+	budget, pathMTU := mtuBudget(clientIP, profile.budgetFor(clientIP, lg.Logger), lg.Logger)
+	isFragmented := ch.WireSize > budget
+	status, message := "SAFE", fmt.Sprintf("ClientHello to %s is %d bytes, fits within MTU %d", origin, ch.WireSize, budget)
+	if isFragmented {
+		status = "CRITICAL_RISK"
+		message = fmt.Sprintf("ClientHello to %s is %d bytes > MTU %d. WILL FRAGMENT on legacy networks!", origin, ch.WireSize, budget)
+		lg.Warn("GHOST DETECTED: ClientHello will fragment", "origin", origin, "bytes", ch.WireSize, "budget", budget)
+	} else {
+		lg.Info("ClientHello fits MTU", "origin", origin, "bytes", ch.WireSize, "budget", budget)
+	}
+
+	report := GhostReport{
+		Timestamp:       time.Now().Format(time.RFC3339),
+		ConnID:          lg.id,
+		ClientIP:        clientIP,
+		Algorithm:       algorithm,
+		PublicKeySize:   pkSize,
+		HandshakeSize:   ch.WireSize,
+		Fragmentation:   isFragmented,
+		IPv6Risk:        ch.WireSize > IPV6_MIN_BUDGET,
+		Status:          status,
+		Message:         message,
+		Origin:          origin,
+		SNI:             chInfo.SNI,
+		ALPN:            chInfo.ALPN,
+		ServerHelloSize: sh.WireSize,
+		MTUBudget:       budget,
+		PathMTU:         pathMTU,
+		Listener:        profile.Addr,
+		profile:         profile,
+		log:             lg,
+	}
+	measureFlights(&report)
+	return report
+}
+
+// ============================================================================
+// HELLO SNIFFER
+// ============================================================================
+
+// helloCapture is the first handshake message seen in one direction.
+type helloCapture struct {
+	Message  []byte // Reassembled handshake message (header included)
+	WireSize int    // Bytes on the wire, including TLS record headers
+	Records  int    // Number of TLS records the message spanned
+	Err      error
+}
+
+// helloSniffer is an io.Writer fed by a TeeReader. It buffers relayed bytes
+// until the first handshake message is complete, then goes quiet.
+type helloSniffer struct {
+	buf    []byte
+	done   bool
+	result chan helloCapture
+}
+
+func newHelloSniffer() *helloSniffer {
+	return &helloSniffer{result: make(chan helloCapture, 1)}
+}
+
+func (s *helloSniffer) Write(p []byte) (int, error) {
+	if s.done {
+		return len(p), nil
+	}
+	s.buf = append(s.buf, p...)
+
+	capture, complete := firstHandshakeMessage(s.buf)
+	if !complete && len(s.buf) > MAX_HELLO_BUFFER {
+		capture, complete = helloCapture{Err: errors.New("no handshake message within sniff buffer")}, true
+	}
+	if complete {
+		s.done = true
+		s.buf = nil
+		s.result <- capture
+	}
+	return len(p), nil
+}
+
+// firstHandshakeMessage reassembles the first handshake message from a stream
+// of TLS records. It reports complete=false while more bytes are needed.
+func firstHandshakeMessage(stream []byte) (helloCapture, bool) {
+	var c helloCapture
+	var msg []byte
+	pos := 0
+
+	for pos+5 <= len(stream) {
+		if stream[pos] != TLS_RECORD_HANDSHAKE {
+			c.Err = fmt.Errorf("not a TLS handshake (record type 0x%02x)", stream[pos])
+			return c, true
+		}
+		recLen := int(binary.BigEndian.Uint16(stream[pos+3 : pos+5]))
+		if pos+5+recLen > len(stream) {
+			return c, false
+		}
+		msg = append(msg, stream[pos+5:pos+5+recLen]...)
+		pos += 5 + recLen
+		c.Records++
+
+		if len(msg) >= 4 {
+			msgLen := 4 + (int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3]))
+			if len(msg) >= msgLen {
+				c.Message = msg[:msgLen]
+				c.WireSize = pos
+				return c, true
+			}
+		}
+	}
+	return c, false
+}
+
+// ============================================================================
+// HELLO PARSING
+// ============================================================================
+
+type keyShareEntry struct {
+	Group uint16
+	Size  int
+}
+
+type clientHelloInfo struct {
+	SNI               string
+	ALPN              []string
+	KeyShares         []keyShareEntry
+	SupportedVersions []uint16
+	Probe             []byte // Sentinel middlebox probe extension, if present
+}
+
+type serverHelloInfo struct {
+	Version      uint16
+	Group        uint16
+	KeyShareSize int
+}
+
+// helloExtensions walks the extension block of a Client/ServerHello body.
+func helloExtensions(exts []byte, visit func(extType uint16, data []byte)) {
+	if len(exts) < 2 {
+		return
+	}
+	end := 2 + int(binary.BigEndian.Uint16(exts))
+	if end > len(exts) {
+		end = len(exts)
+	}
+	for pos := 2; pos+4 <= end; {
+		extType := binary.BigEndian.Uint16(exts[pos:])
+		extLen := int(binary.BigEndian.Uint16(exts[pos+2:]))
+		if pos+4+extLen > end {
+			return
+		}
+		visit(extType, exts[pos+4:pos+4+extLen])
+		pos += 4 + extLen
+	}
+}
+
+func parseClientHello(msg []byte) clientHelloInfo {
+	var info clientHelloInfo
+	if len(msg) < 4+34+1 || msg[0] != 0x01 {
+		return info
+	}
+	body := msg[4:]
+
+	// version(2) random(32) session_id<1> cipher_suites<2> compression<1>
+	pos := 34
+	pos += 1 + int(body[pos])
+	if pos+2 > len(body) {
+		return info
+	}
+	pos += 2 + int(binary.BigEndian.Uint16(body[pos:]))
+	if pos+1 > len(body) {
+		return info
+	}
+	pos += 1 + int(body[pos])
+	if pos > len(body) {
+		return info
+	}
+
+	helloExtensions(body[pos:], func(extType uint16, data []byte) {
+		switch extType {
+		case 0x0000: // server_name
+			if len(data) >= 5 && data[2] == 0 {
+				n := int(binary.BigEndian.Uint16(data[3:]))
+				if 5+n <= len(data) {
+					info.SNI = string(data[5 : 5+n])
+				}
+			}
+		case 0x0010: // application_layer_protocol_negotiation
+			for p := 2; p < len(data); {
+				n := int(data[p])
+				if p+1+n > len(data) {
+					break
+				}
+				info.ALPN = append(info.ALPN, string(data[p+1:p+1+n]))
+				p += 1 + n
+			}
+		case 0x0033: // key_share
+			for p := 2; p+4 <= len(data); {
+				group := binary.BigEndian.Uint16(data[p:])
+				n := int(binary.BigEndian.Uint16(data[p+2:]))
+				info.KeyShares = append(info.KeyShares, keyShareEntry{Group: group, Size: n})
+				p += 4 + n
+			}
+		case 0x002b: // supported_versions
+			for p := 1; p+2 <= len(data); p += 2 {
+				info.SupportedVersions = append(info.SupportedVersions, binary.BigEndian.Uint16(data[p:]))
+			}
+		case MIDDLEBOX_PROBE_EXTENSION:
+			info.Probe = data
+		}
+	})
+	return info
+}
+
+func parseServerHello(msg []byte) serverHelloInfo {
+	var info serverHelloInfo
+	if len(msg) < 4+34+1 || msg[0] != TLS_HANDSHAKE_SHELLO {
+		return info
+	}
+	body := msg[4:]
+	info.Version = binary.BigEndian.Uint16(body)
+
+	// version(2) random(32) session_id<1> cipher_suite(2) compression(1)
+	pos := 34
+	pos += 1 + int(body[pos]) + 3
+	if pos > len(body) {
+		return info
+	}
+
+	helloExtensions(body[pos:], func(extType uint16, data []byte) {
+		switch extType {
+		case 0x002b: // supported_versions
+			if len(data) == 2 {
+				info.Version = binary.BigEndian.Uint16(data)
+			}
+		case 0x0033: // key_share
+			if len(data) >= 2 {
+				info.Group = binary.BigEndian.Uint16(data)
+			}
+			if len(data) >= 4 {
+				info.KeyShareSize = int(binary.BigEndian.Uint16(data[2:]))
+			}
+		}
+	})
+	return info
+}
+
+// describeALPN renders offered protocols for log lines.
+func describeALPN(protos []string) string {
+	if len(protos) == 0 {
+		return "none"
+	}
+	return strings.Join(protos, ",")
+}
@@ -0,0 +1,224 @@
+/*
+Sentinel-PQC Proxy - Datagram Listener
+======================================
+UDP protocols cannot lean on TCP segmentation: a key share that does not fit
+in one datagram is IP-fragmented (or dropped). Datagram scenarios receive each
+datagram whole and are judged against per-path payload budgets:
+
+  budget = MTU - IP header - UDP header(8)
+
+With -udp the same listeners also accept the plain key share of the stream
+scenarios as one UDP datagram (as DTLS or QUIC would carry it), so the proxy
+reports whether it fits unfragmented or needs IP fragmentation.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/cloudflare/circl/kem"
+)
+
+// datagramHandler answers one client datagram. Like scenarioHandler it may
+// adjust the report; returning an error drops the exchange without a report,
+// errDatagramPending quietly when the exchange needs more datagrams.
+type datagramHandler func(pc net.PacketConn, addr net.Addr, scheme kem.Scheme, datagram []byte, report *GhostReport) error
+
+// ============================================================================
+// PATH BUDGETS
+// ============================================================================
+
+// datagramPath is an underlay the datagram may have to cross.
+type datagramPath struct {
+	Name     string
+	MTU      int
+	IPHeader int // 20 for IPv4, 40 for IPv6
+}
+
+// Common VPN underlays (outer path MTUs seen by the tunnel's own datagrams).
+var vpnPaths = []datagramPath{
+	{"Ethernet IPv4", 1500, 20},
+	{"Ethernet IPv6", 1500, 40},
+	{"PPPoE IPv4", 1492, 20},
+	{"Nested in WireGuard (1420)", 1420, 20},
+	{"IPv6 minimum MTU", 1280, 40},
+}
+
+// fitPaths reports, for each path, whether a UDP payload of the given size
+// fits in one datagram and otherwise how many IP fragments it becomes.
+func fitPaths(size int, paths []datagramPath) []PathFit {
+	fits := make([]PathFit, 0, len(paths))
+	for _, p := range paths {
+		budget := p.MTU - p.IPHeader - 8
+
+		// Fragments carry multiples of 8 bytes; IPv6 adds an 8-byte
+		// fragment header to every fragment.
+		perFragment := p.MTU - p.IPHeader
+		if p.IPHeader == 40 {
+			perFragment -= 8
+		}
+		perFragment &^= 7
+		fragments := 1
+		if size > budget {
+			fragments = (size + 8 + perFragment - 1) / perFragment
+		}
+
+		fits = append(fits, PathFit{
+			Path:      p.Name,
+			MTU:       p.MTU,
+			Budget:    budget,
+			Fits:      size <= budget,
+			Fragments: fragments,
+		})
+	}
+	return fits
+}
+
+func logPathFits(fits []PathFit, lg *slog.Logger) {
+	for _, f := range fits {
+		lg.Debug("path fit", "path", f.Path, "mtu", f.MTU, "budget", f.Budget, "fits", f.Fits, "ip_fragments", f.Fragments)
+	}
+}
+
+// ============================================================================
+// UDP SERVER
+// ============================================================================
+
+func serveDatagrams(profile listenerProfile, scheme kem.Scheme, sc scenario) {
+	pc, err := profile.listenConfig().ListenPacket(context.Background(), "udp", profile.Addr)
+	if err != nil {
+		fatal("cannot start UDP listener", "addr", profile.Addr, "err", err)
+	}
+	defer pc.Close()
+
+	slog.Info("ghost proxy listening", "addr", profile.Addr+"/udp", "options", profile.options(), "mtu_budget", profile.budget())
+	frags := newFragmentPath(profile, scheme)
+
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(buffer)
+		if err != nil {
+			slog.Error("datagram read failed", "err", err)
+			continue
+		}
+		datagram := append([]byte(nil), buffer[:n]...)
+		delivered, history, lg := frags.deliver(addr, datagram)
+		if !delivered {
+			continue
+		}
+		go handleDatagram(pc, addr, scheme, sc, datagram, profile, history, lg)
+	}
+}
+
+func handleDatagram(pc net.PacketConn, addr net.Addr, scheme kem.Scheme, sc scenario, datagram []byte, profile listenerProfile, history *FragmentReport, lg connLog) {
+	defer trackConnection()()
+	profile = profile.live()
+	start, listener := time.Now(), pc
+	lg.Info("datagram received", "client", addr.String(), "listener", profile.Addr, "bytes", len(datagram))
+	tr := startHandshakeTrace(addr.String(), profile, "udp")
+	defer tr.end()
+
+	if *transcriptDir != "" {
+		t := newTranscript(addr.String(), *scenarioName, "udp")
+		t.record(TRANSCRIPT_RECV, datagram)
+		pc = &transcriptPacketConn{PacketConn: pc, t: t}
+		defer t.save()
+	}
+
+	scheme = profile.schemeFor(scheme, len(datagram))
+	imp := profile.impairment()
+	_, parseDone := tr.phase("parse")
+	report := assessDatagram(addr.String(), scheme, len(datagram), profile, lg)
+	report.Fragments = history
+	noteFragments(&report)
+	parseDone(nil)
+	if blackHoled(&report, imp) {
+		tr.annotate(report)
+		saveReport(report)
+		return
+	}
+
+	var watch *icmpWatch
+	if icmpActive {
+		watch = watchICMP(addr.String(), lg.Logger)
+		defer watch.stop()
+	}
+	if imp.enabled() {
+		newTokenBucket(imp.BandwidthKbps).wait(len(datagram)) // uplink time of the datagram
+		pc = newImpairedPacketConn(pc, imp)
+	}
+	respondCtx, respondDone := tr.phase("respond")
+	err := sc.respondDatagram(pc, addr, tracedScheme{Scheme: scheme, ctx: respondCtx, tr: tr}, datagram, &report)
+	respondDone(err)
+	if errors.Is(err, errDatagramPending) {
+		return
+	}
+	if err != nil {
+		lg.Error("handshake failed", "err", err)
+		return
+	}
+	report.Timings = tr.timings(start, nil)
+	report.HandshakeMs = report.Timings.TotalMs
+	report.BandwidthKbps = imp.BandwidthKbps
+	report.DSCP = profile.dscp()
+	if watch != nil {
+		classifyPath(&report, watch.collect())
+	}
+	attachSocketBuffers(&report, listener)
+	attachWireStats(&report)
+
+	tr.annotate(report)
+	saveReport(report)
+	logReportSummary(report)
+}
+
+// assessDatagram judges a datagram against the unfragmented UDP payload
+// budget of the listener (its TCP budget plus the smaller UDP header).
+func assessDatagram(clientIP string, scheme kem.Scheme, size int, profile listenerProfile, lg connLog) GhostReport {
+	budget, pathMTU := mtuBudget(clientIP, profile.budgetFor(clientIP, lg.Logger), lg.Logger)
+	budget += TCP_HEADER_SIZE - UDP_HEADER_SIZE
+	report := judgeSize(clientIP, scheme, size, budget, IPV6_MIN_DATAGRAM_BUDGET, profile, lg)
+	report.PathMTU = pathMTU
+	report.Transport = "udp"
+	return report
+}
+
+// respondKeyShareDatagram is the -udp counterpart of completeKeyExchange:
+// the public key leads the datagram and the ciphertext goes back in one.
+func respondKeyShareDatagram(pc net.PacketConn, addr net.Addr, scheme kem.Scheme, datagram []byte, report *GhostReport) error {
+	pkSize := scheme.PublicKeySize()
+	if len(datagram) < pkSize {
+		return fmt.Errorf("datagram too small (%d bytes) for a %s key (%d bytes required)", len(datagram), scheme.Name(), pkSize)
+	}
+	pk, err := scheme.UnmarshalBinaryPublicKey(datagram[:pkSize])
+	if err != nil {
+		return fmt.Errorf("invalid %s public key: %w", scheme.Name(), err)
+	}
+	ct, _, err := scheme.Encapsulate(pk)
+	if err != nil {
+		return fmt.Errorf("encapsulation failed: %w", err)
+	}
+	if _, err := pc.WriteTo(ct, addr); err != nil {
+		return fmt.Errorf("failed to send ciphertext: %w", err)
+	}
+	report.logger().Info("sent ciphertext datagram", "bytes", len(ct))
+	report.ServerHelloSize = len(ct)
+
+	report.PathFits = fitPaths(len(datagram), vpnPaths)
+	logPathFits(report.PathFits, report.logger())
+	for _, f := range report.PathFits {
+		if !f.Fits {
+			report.addNote(fmt.Sprintf("Key share datagram needs %d IP fragments on %s.", f.Fragments, f.Path))
+		}
+	}
+	if len(ct) > report.MTUBudget {
+		report.addNote(fmt.Sprintf("Ciphertext datagram of %d bytes exceeds the %d-byte budget as well.", len(ct), report.MTUBudget))
+	}
+	return nil
+}
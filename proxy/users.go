@@ -0,0 +1,344 @@
+/*
+Sentinel-PQC Proxy - API Users
+==============================
+The basic auth users of -api-users (auth.go) can be managed from the
+dashboard's Control tab or the API instead of editing the file. Each user
+has a role:
+
+  viewer    sees the dashboard, reports, statistics and settings (read)
+  operator  also runs the proxy: runtime settings (control.go), annotations
+  admin     also manages alert rules and the users
+
+  curl -u admin:secret localhost:9090/api/users
+  curl -u admin:secret -X PUT localhost:9090/api/users/oncall \
+       --json '{"role": "operator", "password": "correct horse battery"}'
+
+  GET    /api/whoami        the caller's name and role (any role)
+  GET    /api/users         the users, and the token names of -api-tokens
+  PUT    /api/users/{name}  add a user or change its role or password
+  DELETE /api/users/{name}  remove it
+
+A new user needs a password; changing the role of an existing one keeps it.
+The file is rewritten with bcrypt hashes, so it never holds a plain
+password again (comments are not kept). One credential must always be an
+admin, and removing the last admin cannot open the API again.
+
+An open API does not create users, or anyone who reaches it could make
+themselves admin. The first one is added on the proxy's host, with the
+password on stdin, before the proxy is (re)started:
+
+  go run . user -api-users users.txt -role admin admin < admin.pass
+
+or $SENTINEL_API_TOKEN gives an admin token that may then add users. Every
+change is recorded in the audit trail (control.go).
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	USERS_MAX_BODY     = 4 << 10
+	USERS_MIN_PASSWORD = 8
+)
+
+// APIUser is a user or token as /api/users shows it; secrets never leave
+// the proxy.
+type APIUser struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// UserChange is the body of PUT /api/users/{name}.
+type UserChange struct {
+	Role     string `json:"role"`
+	Password string `json:"password,omitempty"`
+}
+
+// role is the name of a scope as a user's role.
+func (s authScope) role() string {
+	if s == scopeRead {
+		return "viewer"
+	}
+	return s.String()
+}
+
+// hasUsers reports whether basic auth users are configured.
+func hasUsers() bool {
+	apiAuth.mu.RLock()
+	defer apiAuth.mu.RUnlock()
+	return len(apiAuth.users) > 0
+}
+
+// keepsAdmin reports whether users, together with the tokens, include an
+// admin; apiAuth.mu must be held.
+func keepsAdmin(users map[string]apiCredential) bool {
+	for _, c := range users {
+		if c.Scope == scopeAdmin {
+			return true
+		}
+	}
+	for _, c := range apiAuth.tokens {
+		if c.Scope == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// hashPassword is the bcrypt hash of a password.
+func hashPassword(pass string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(pass), PASSWORD_COST)
+}
+
+// saveUsers rewrites -api-users from users; apiAuth.mu must be held.
+func saveUsers(users map[string]apiCredential) error {
+	var b strings.Builder
+	b.WriteString("# Sentinel-PQC API users: user role password (managed by /api/users)\n")
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		c := users[name]
+		secret := string(c.hash)
+		if c.hash == nil {
+			secret = "sha256:" + hex.EncodeToString(c.digest) // not changed since an older version
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", name, c.Scope.role(), secret)
+	}
+	tmp := apiAuth.userFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, apiAuth.userFile)
+}
+
+// ============================================================================
+// API
+// ============================================================================
+
+func registerUsers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/whoami", serveWhoami)
+	mux.HandleFunc("GET /api/users", serveUsers)
+	mux.HandleFunc("PUT /api/users/{name}", serveUpdateUser)
+	mux.HandleFunc("DELETE /api/users/{name}", serveDeleteUser)
+}
+
+func serveWhoami(w http.ResponseWriter, r *http.Request) {
+	id, ok := requestIdentity(r)
+	if !ok {
+		// The API is open: everyone may do everything.
+		id = apiIdentity{Name: "anonymous", Scope: scopeAdmin}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"name": id.Name, "role": id.Scope.role(), "auth": ok})
+}
+
+func serveUsers(w http.ResponseWriter, r *http.Request) {
+	apiAuth.mu.RLock()
+	users, tokens := []APIUser{}, []APIUser{}
+	for _, c := range apiAuth.users {
+		users = append(users, APIUser{c.Name, c.Scope.role()})
+	}
+	for _, c := range apiAuth.tokens {
+		tokens = append(tokens, APIUser{c.Name, c.Scope.role()})
+	}
+	apiAuth.mu.RUnlock()
+	byName := func(a, b APIUser) int { return strings.Compare(a.Name, b.Name) }
+	slices.SortFunc(users, byName)
+	slices.SortFunc(tokens, byName)
+	writeJSON(w, http.StatusOK, map[string]any{"file": apiAuth.userFile, "users": users, "tokens": tokens})
+}
+
+// checkUserName rejects names basic auth or the users file cannot carry.
+func checkUserName(name string) error {
+	if name == "" || strings.ContainsAny(name, ":# \t\r\n") {
+		return fmt.Errorf("user name %q: must not be empty or contain spaces, ':' or '#'", name)
+	}
+	return nil
+}
+
+func serveUpdateUser(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var change UserChange
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, USERS_MAX_BODY))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&change); err != nil {
+		writeError(w, http.StatusBadRequest, "body: "+err.Error())
+		return
+	}
+	if err := checkUserName(name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scope, err := parseAuthScope(change.Role)
+	if err != nil || scope == scopeIngest {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("role: %q is not viewer, operator or admin", change.Role))
+		return
+	}
+	if change.Password != "" && len(change.Password) < USERS_MIN_PASSWORD {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("password: needs at least %d characters", USERS_MIN_PASSWORD))
+		return
+	}
+	var hash []byte
+	if change.Password != "" {
+		if hash, err = hashPassword(change.Password); err != nil {
+			writeError(w, http.StatusBadRequest, "password: "+err.Error())
+			return
+		}
+	}
+
+	apiAuth.mu.Lock()
+	if apiAuth.userFile == "" {
+		apiAuth.mu.Unlock()
+		writeError(w, http.StatusConflict, "start the proxy with -api-users to manage users")
+		return
+	}
+	if len(apiAuth.tokens) == 0 && len(apiAuth.users) == 0 {
+		apiAuth.mu.Unlock()
+		writeError(w, http.StatusForbidden, "the API is open: add the first admin with the user subcommand or set $"+API_TOKEN_ENV)
+		return
+	}
+	old, exists := apiAuth.users[name]
+	if !exists && change.Password == "" {
+		apiAuth.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "password: a new user needs one")
+		return
+	}
+	c := apiCredential{apiIdentity: apiIdentity{Name: name, Scope: scope}, digest: old.digest, hash: old.hash}
+	if hash != nil {
+		c.digest, c.hash = nil, hash
+	}
+	users := maps.Clone(apiAuth.users)
+	users[name] = c
+	if !keepsAdmin(users) {
+		apiAuth.mu.Unlock()
+		writeError(w, http.StatusConflict, "at least one admin must remain")
+		return
+	}
+	if err := saveUsers(users); err != nil {
+		apiAuth.mu.Unlock()
+		slog.Error("cannot save users", "file", apiAuth.userFile, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the users")
+		return
+	}
+	apiAuth.users = users
+	apiAuth.mu.Unlock()
+
+	after := APIUser{name, scope.role()}
+	if exists {
+		recordAudit(r, "user.update", name, APIUser{name, old.Scope.role()}, after)
+		writeJSON(w, http.StatusOK, after)
+		return
+	}
+	recordAudit(r, "user.create", name, nil, after)
+	writeJSON(w, http.StatusCreated, after)
+}
+
+func serveDeleteUser(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	apiAuth.mu.Lock()
+	old, exists := apiAuth.users[name]
+	if !exists {
+		apiAuth.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no such user")
+		return
+	}
+	users := maps.Clone(apiAuth.users)
+	delete(users, name)
+	if !keepsAdmin(users) {
+		apiAuth.mu.Unlock()
+		writeError(w, http.StatusConflict, "at least one admin must remain")
+		return
+	}
+	if err := saveUsers(users); err != nil {
+		apiAuth.mu.Unlock()
+		slog.Error("cannot save users", "file", apiAuth.userFile, "err", err)
+		writeError(w, http.StatusInternalServerError, "cannot save the users")
+		return
+	}
+	apiAuth.users = users
+	apiAuth.mu.Unlock()
+	recordAudit(r, "user.delete", name, APIUser{name, old.Scope.role()}, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ============================================================================
+// BOOTSTRAP
+// ============================================================================
+
+// runUser adds a user to the -api-users file, or changes its role and
+// password, without the API: the way to create the first admin.
+func runUser(args []string) {
+	fs := flag.NewFlagSet("user", flag.ExitOnError)
+	file := fs.String("api-users", "users.txt", "The proxy's -api-users file")
+	role := fs.String("role", "admin", "viewer, operator or admin")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: user [-api-users FILE] [-role ROLE] NAME < PASSWORD_FILE")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	name := fs.Arg(0)
+	if err := checkUserName(name); err != nil {
+		fatal("invalid user", "err", err)
+	}
+	scope, err := parseAuthScope(*role)
+	if err != nil || scope == scopeIngest {
+		fatal("invalid role, want viewer, operator or admin", "role", *role)
+	}
+
+	users := make(map[string]apiCredential)
+	err = readCredentials(*file, true, func(c apiCredential) error {
+		users[c.Name] = c
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fatal("cannot read users", "file", *file, "err", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", name)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		fatal("cannot read the password from stdin", "err", err)
+	}
+	pass := strings.TrimRight(line, "\r\n")
+	if len(pass) < USERS_MIN_PASSWORD {
+		fatal(fmt.Sprintf("the password needs at least %d characters", USERS_MIN_PASSWORD))
+	}
+	hash, err := hashPassword(pass)
+	if err != nil {
+		fatal("cannot hash the password", "err", err)
+	}
+
+	_, exists := users[name]
+	users[name] = apiCredential{apiIdentity: apiIdentity{Name: name, Scope: scope}, hash: hash}
+	apiAuth.userFile = *file
+	if err := saveUsers(users); err != nil {
+		fatal("cannot save users", "file", *file, "err", err)
+	}
+	verb := "added"
+	if exists {
+		verb = "updated"
+	}
+	fmt.Fprintf(os.Stderr, "\n%s %s (%s) in %s; restart the proxy to load it\n", verb, name, scope.role(), *file)
+}
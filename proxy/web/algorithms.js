@@ -0,0 +1,71 @@
+// Sentinel-PQC algorithm comparison: /api/algorithms per family and
+// algorithm, with size histograms on shared bins so the rows line up.
+'use strict';
+
+const FAMILY_LABELS = { pqc: 'Post-quantum', hybrid: 'Hybrid', classical: 'Classical', unknown: 'Unknown' };
+
+const pct = (rate) => `${(rate * 100).toFixed(1)}%`;
+const ms = (v, samples) => (samples ? `${v.toFixed(2)} ms` : '–');
+
+async function algorithmsLoad(params) {
+  const since = params.get('since') || '24h';
+  $('alg-since').value = since;
+  $('alg-error').textContent = '';
+  try {
+    const data = await api(`/api/algorithms?since=${encodeURIComponent(since)}`);
+    $('alg-store').textContent = `${data.reports} reports from ${data.store}${data.truncated ? ' (truncated)' : ''}`;
+    renderFamilies(data.families);
+    renderAlgorithms(data.algorithms);
+  } catch (err) {
+    $('alg-error').textContent = err.message;
+    $('alg-families').replaceChildren();
+    $('alg-table').replaceChildren();
+    $('alg-hist').replaceChildren();
+  }
+}
+
+function renderFamilies(families) {
+  if (families.length === 0) {
+    $('alg-families').replaceChildren(el('p', { class: 'muted' }, 'No handshakes in this window.'));
+    return;
+  }
+  $('alg-families').replaceChildren(...families.map((f) => el('div', { class: 'card' },
+    el('div', { class: 'label' }, `${FAMILY_LABELS[f.family] || f.family} · ${f.algorithms.join(', ')}`),
+    el('div', { class: 'value' }, pct(f.detection_rate)),
+    el('div', { class: 'muted' },
+      `${f.detections} of ${f.reports} detected · p95 ${f.size.p95} B · p50 ${ms(f.latency.p50_ms, f.latency.samples)}`))));
+}
+
+function renderAlgorithms(algorithms) {
+  if (algorithms.length === 0) {
+    $('alg-table').replaceChildren(el('tr', {}, el('td', { colspan: 9, class: 'muted' }, 'No handshakes in this window.')));
+    $('alg-hist').replaceChildren();
+    return;
+  }
+  $('alg-table').replaceChildren(...algorithms.map((a) => el('tr', {},
+    el('td', {}, a.algorithm),
+    el('td', {}, FAMILY_LABELS[a.family] || a.family),
+    el('td', {}, String(a.reports)),
+    el('td', { class: `status ${a.detections ? 'risk' : 'SAFE'}` }, pct(a.detection_rate)),
+    el('td', {}, `${a.size.p50} / ${a.size.p95} / ${a.size.max}`),
+    el('td', {}, String(a.over_budget)),
+    el('td', {}, ms(a.latency.p50_ms, a.latency.samples)),
+    el('td', {}, ms(a.latency.p95_ms, a.latency.samples)),
+    el('td', {}, a.encapsulate ? ms(a.encapsulate.p50_ms, a.encapsulate.samples) : '–'))));
+
+  const top = Math.max(1, ...algorithms.flatMap((a) => a.size_histogram.map((b) => b.safe + b.detections)));
+  $('alg-hist').replaceChildren(...algorithms.map((a) => el('div', { class: 'hist-row' },
+    el('span', {}, a.algorithm),
+    el('div', { class: 'hist' }, ...a.size_histogram.map((b) => el('div', {
+      class: 'bin',
+      title: `${b.from}–${b.to} B: ${b.safe} safe, ${b.detections} detections`,
+    },
+    el('div', { class: 'fill risk', style: `height:${(b.detections / top) * 100}%` }),
+    el('div', { class: 'fill SAFE', style: `height:${(b.safe / top) * 100}%` })))))));
+  const bins = algorithms[0].size_histogram;
+  $('alg-range').textContent = `${bins[0].from}–${bins[bins.length - 1].to} bytes`;
+}
+
+$('alg-since').addEventListener('change', (e) => {
+  location.hash = `#algorithms?since=${encodeURIComponent(e.target.value)}`;
+});
@@ -0,0 +1,67 @@
+// Sentinel-PQC annotations: notes from /api/annotations on the Timeline tab
+// and the live chart; adding and removing them needs the operator role.
+'use strict';
+
+const NOTE_FILTERS = ['from', 'to', 'subnet'];
+
+// notesFor fetches the annotations for the timeline filters; a proxy
+// without annotations simply has none.
+async function notesFor(params) {
+  const query = new URLSearchParams();
+  for (const key of NOTE_FILTERS) if (params.get(key)) query.set(key, params.get(key));
+  try {
+    return (await api(`/api/annotations?${query}`)).annotations;
+  } catch (err) {
+    console.error(err);
+    return [];
+  }
+}
+
+function renderNotes(notes) {
+  if (notes.length === 0) {
+    $('tl-notes').replaceChildren(el('li', { class: 'muted' }, 'No annotations in this range.'));
+    return;
+  }
+  $('tl-notes').replaceChildren(...notes.map((n) => el('li', {},
+    el('span', { class: 'muted' }, `${n.time}${n.end ? ` – ${n.end}` : ''}${n.subnet ? ` · ${n.subnet}` : ''} · ${n.author} `),
+    n.text, ' ',
+    el('a', { href: `#compare?annotation=${encodeURIComponent(n.id)}` }, 'compare'), ' ',
+    el('button', { 'data-note': n.id, title: 'Remove this annotation' }, '×'))));
+}
+
+// noteOn prepares the form for a note on one report.
+function noteOn(connID, time) {
+  const f = $('tl-note-form').elements;
+  f.conn_id.value = connID || '';
+  f.time.value = localInput(time);
+  f.text.focus();
+}
+
+async function noteSend(method, path, body) {
+  $('tl-error').textContent = '';
+  try {
+    await api(path, [], method, body);
+    showView();
+  } catch (err) {
+    $('tl-error').textContent = err.message;
+  }
+}
+
+$('tl-note-form').addEventListener('submit', (e) => {
+  e.preventDefault();
+  const f = e.target.elements;
+  const note = { text: f.text.value };
+  for (const key of ['time', 'end']) if (f[key].value) note[key] = fromLocalInput(f[key].value);
+  if (f.conn_id.value) note.conn_id = f.conn_id.value;
+  if (f.subnet.value.trim()) note.subnet = f.subnet.value.trim();
+  noteSend('POST', '/api/annotations', note);
+  e.target.reset();
+});
+$('tl-notes').addEventListener('click', (e) => {
+  const id = e.target.dataset && e.target.dataset.note;
+  if (id && window.confirm('Remove this annotation?')) noteSend('DELETE', `/api/annotations/${encodeURIComponent(id)}`);
+});
+$('tl-reports').addEventListener('click', (e) => {
+  const b = e.target.closest('button[data-conn]');
+  if (b) noteOn(b.dataset.conn, b.dataset.time);
+});
@@ -0,0 +1,204 @@
+// Sentinel-PQC embedded dashboard: polls the proxy's own JSON API.
+'use strict';
+
+const REFRESH_MS = 5000;
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs = {}, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs)) {
+    if (k === 'class') node.className = v;
+    else node.setAttribute(k, v);
+  }
+  for (const c of children) node.append(c instanceof Node ? c : document.createTextNode(c ?? ''));
+  return node;
+}
+
+// apiToken is sent as a bearer token once the proxy has asked for one
+// (-api-tokens); it lasts for the browser tab.
+let apiToken = sessionStorage.getItem('sentinel-token') || '';
+let tokenDeclined = false;
+
+function askToken() {
+  if (tokenDeclined) return false;
+  const token = (window.prompt('This proxy requires an API token:') || '').trim();
+  if (!token) {
+    tokenDeclined = true;
+    return false;
+  }
+  apiToken = token;
+  sessionStorage.setItem('sentinel-token', token);
+  return true;
+}
+
+// api fetches a JSON endpoint; statuses in accept are answers, not errors.
+// A body is sent as JSON.
+async function api(path, accept = [], method = 'GET', body = undefined) {
+  const headers = { Accept: 'application/json' };
+  const used = apiToken;
+  if (used) headers.Authorization = `Bearer ${used}`;
+  if (body !== undefined) headers['Content-Type'] = 'application/json';
+  const resp = await fetch(path, { method, headers, body: body === undefined ? undefined : JSON.stringify(body) });
+  // Retry with a token entered meanwhile by a parallel request, or ask for one
+  if (resp.status === 401 && (used !== apiToken || askToken())) return api(path, accept, method, body);
+  if (!resp.ok && !accept.includes(resp.status)) {
+    const answer = await resp.json().catch(() => ({}));
+    throw new Error(answer.error || `${path}: HTTP ${resp.status}`);
+  }
+  if (resp.status === 204) return null;
+  return resp.json();
+}
+
+// statusClass colours SAFE green, MARGINAL amber and everything else red.
+function statusClass(s) {
+  return s === 'SAFE' || s === 'MARGINAL' ? s : 'risk';
+}
+
+// probeName is where a collected report came from: "edge-7 @ fra1".
+function probeName(r) {
+  if (!r.probe) return '';
+  return r.site ? `${r.probe} @ ${r.site}` : r.probe;
+}
+
+// host strips the port from "1.2.3.4:5678" and "[::1]:5678".
+function host(addr = '') {
+  if (addr.startsWith('[')) return addr.slice(1, addr.indexOf(']'));
+  const parts = addr.split(':');
+  return parts.length === 2 ? parts[0] : addr;
+}
+
+function renderCards(stats) {
+  const cards = [
+    ['Handshakes', stats.reports],
+    ['Detections', stats.detections],
+    ['Detection rate', `${(stats.detection_rate * 100).toFixed(1)}%`],
+    ['Size p50', `${stats.size.p50} B`],
+    ['Size p95', `${stats.size.p95} B`],
+    ['Size max', `${stats.size.max} B`],
+  ];
+  $('cards').replaceChildren(...cards.map(([label, value]) =>
+    el('div', { class: 'card' }, el('div', { class: 'label' }, label), el('div', { class: 'value' }, String(value)))));
+}
+
+function renderStatuses(stats) {
+  $('window').textContent = `last ${stats.window}`;
+  const counts = Object.entries(stats.statuses || {}).sort((a, b) => b[1] - a[1]);
+  if (counts.length === 0) {
+    $('statuses').replaceChildren(el('p', { class: 'muted' }, 'No handshakes in the window yet.'));
+    return;
+  }
+  const top = counts[0][1];
+  $('statuses').replaceChildren(...counts.map(([status, n]) =>
+    el('div', { class: 'row' },
+      el('span', { class: `status ${statusClass(status)}` }, status),
+      el('div', { class: 'track' }, el('div', { class: `fill ${statusClass(status)}`, style: `width:${(n / top) * 100}%` })),
+      el('span', {}, String(n)))));
+}
+
+function renderDetections(page) {
+  const rows = page.reports.filter((r) => r.status !== 'SAFE').slice(0, 50);
+  if (rows.length === 0) {
+    $('detections').replaceChildren(el('tr', {}, el('td', { colspan: 8, class: 'muted' }, `No detections in the last ${page.count} reports.`)));
+    return;
+  }
+  $('detections').replaceChildren(...rows.map((r) => el('tr', {},
+    el('td', {}, r.timestamp),
+    el('td', { class: 'fleet-only' }, probeName(r)),
+    el('td', {}, host(r.client_ip)),
+    el('td', {}, r.algorithm),
+    el('td', {}, `${r.handshake_size_bytes} / ${r.mtu_budget_bytes || '?'}`),
+    el('td', { class: `status ${statusClass(r.status)}` }, r.status),
+    el('td', { class: `status ${statusClass(r.severity)}` }, r.severity || ''),
+    el('td', {}, r.listener || ''))));
+}
+
+// renderProbes lists the probes of a collector; stale ones are dimmed.
+function renderProbes(probes) {
+  $('probe-count').textContent = `${probes.filter((p) => !p.stale).length} of ${probes.length} reporting`;
+  if (probes.length === 0) {
+    $('probes').replaceChildren(el('tr', {}, el('td', { colspan: 6, class: 'muted' }, 'No probe has pushed reports yet.')));
+    return;
+  }
+  $('probes').replaceChildren(...probes.map((p) => el('tr', { class: p.stale ? 'stale' : '' },
+    el('td', {}, p.probe),
+    el('td', {}, p.site || ''),
+    el('td', {}, p.stale ? `${p.last_seen} (stale)` : p.last_seen),
+    el('td', {}, String(p.reports)),
+    el('td', {}, String(p.detections)),
+    el('td', { class: `status ${statusClass(p.last_status)}` }, p.last_status))));
+}
+
+// duration renders seconds as "3d 4h", "2h 5m" or "40s".
+function duration(s) {
+  const units = [['d', 86400], ['h', 3600], ['m', 60], ['s', 1]];
+  const parts = [];
+  for (const [u, n] of units) {
+    if (s >= n || (u === 's' && parts.length === 0)) parts.push(`${Math.floor(s / n)}${u}`);
+    s %= n;
+  }
+  return parts.slice(0, 2).join(' ');
+}
+
+// renderHealth shows /status; a degraded proxy turns the header pill amber.
+function renderHealth(st) {
+  $('uptime').textContent = `up ${duration(st.uptime_seconds)}`;
+  const rows = [
+    [{}, 'status', st.status],
+    [{}, 'connections', `${st.connections.active} active, ${st.connections.handled} handled`],
+    [{}, 'reports stored', st.reports],
+    [{}, 'schemes', st.schemes.join(', ') || 'none'],
+    ...st.sinks.map((s) => [s.healthy ? {} : { class: 'unhealthy' }, s.name,
+      `${s.writes} writes, ${s.failures} failed${s.last_error ? ` · ${s.last_error_at} ${s.last_error}` : ''}`]),
+    [{}, 'errors logged', st.errors.count],
+  ];
+  if (st.errors.last) rows.push([{}, 'last error', `${st.errors.last.time} ${st.errors.last.message}${st.errors.last.err ? `: ${st.errors.last.err}` : ''}`]);
+  $('health').replaceChildren(...rows.map(([attrs, k, v]) => el('div', attrs, el('span', {}, k), el('span', {}, String(v)))));
+  return st.status;
+}
+
+let fleetMode = false;
+
+function renderConfig(cfg) {
+  fleetMode = cfg.mode === 'collector';
+  document.body.classList.toggle('fleet', fleetMode);
+  if (fleetMode) refresh();
+  const rows = [
+    ['mode', cfg.mode],
+    ['scenario', cfg.scenario],
+    ['schema version', cfg.schema_version],
+    ['started', cfg.started],
+    ['report store', cfg.history_store || 'none'],
+    ...cfg.listeners.map((l) => [`listener ${l.addr}`, `${l.budget} B ${l.algorithms}${l.options}`]),
+    ...cfg.sinks.map((s) => ['sink', s]),
+    ...Object.entries(cfg.flags).map(([k, v]) => [`-${k}`, v]),
+  ];
+  if (cfg.probe) rows.push(['probe', probeName(cfg)]);
+  if (cfg.signing_key_id) rows.push(['signing key', cfg.signing_key_id]);
+  $('config').replaceChildren(...rows.map(([k, v]) => el('div', {}, el('span', {}, k), el('span', {}, String(v)))));
+}
+
+async function refresh() {
+  try {
+    const [stats, page, st] = await Promise.all([
+      api('/api/stats'),
+      api('/api/reports?limit=200').catch(() => ({ reports: [], count: 0 })),
+      api('/status', [503]),
+    ]);
+    renderCards(stats);
+    renderStatuses(stats);
+    renderDetections(page);
+    const health = renderHealth(st);
+    if (fleetMode) renderProbes(await api('/api/probes'));
+    $('conn').textContent = health === 'ok' ? 'live' : health;
+    $('conn').className = `pill ${health === 'ok' ? 'live' : 'degraded'}`;
+  } catch (err) {
+    $('conn').textContent = 'proxy unreachable';
+    $('conn').className = 'pill down';
+    console.error(err);
+  }
+}
+
+api('/api/config').then(renderConfig).catch(console.error);
+refresh();
+setInterval(refresh, REFRESH_MS);
@@ -0,0 +1,146 @@
+// Sentinel-PQC live size chart: handshake sizes against the MTU budget,
+// fed by the /api/live WebSocket and seeded from /api/reports.
+'use strict';
+
+const CHART_WINDOW_MS = 10 * 60 * 1000;
+const CHART_MAX_POINTS = 2000;
+const IPV6_MIN_BUDGET = 1220; // 1280 - IPv6 and TCP headers
+
+const CHART_NOTES_MS = 30000;
+
+const chart = {
+  points: [], // {t, size, budget, status}
+  notes: [], // annotations of the window
+  canvas: null,
+};
+
+function chartAdd(r) {
+  const t = Date.parse(r.timestamp);
+  if (Number.isNaN(t)) return;
+  chart.points.push({ t, size: r.handshake_size_bytes, budget: r.mtu_budget_bytes || 0, status: r.status });
+  if (chart.points.length > CHART_MAX_POINTS) chart.points.splice(0, chart.points.length - CHART_MAX_POINTS);
+}
+
+function chartDraw() {
+  const c = chart.canvas;
+  const dpr = window.devicePixelRatio || 1;
+  const w = c.clientWidth, h = c.clientHeight;
+  if (c.width !== w * dpr || c.height !== h * dpr) {
+    c.width = w * dpr;
+    c.height = h * dpr;
+  }
+  const g = c.getContext('2d');
+  g.setTransform(dpr, 0, 0, dpr, 0, 0);
+  g.clearRect(0, 0, w, h);
+
+  const now = Date.now();
+  const from = now - CHART_WINDOW_MS;
+  chart.points = chart.points.filter((p) => p.t >= from - 60000);
+  const visible = chart.points.filter((p) => p.t >= from);
+
+  const pad = { l: 48, r: 12, t: 10, b: 22 };
+  const top = Math.max(1600, ...visible.map((p) => Math.max(p.size, p.budget))) * 1.1;
+  const x = (t) => pad.l + ((t - from) / CHART_WINDOW_MS) * (w - pad.l - pad.r);
+  const y = (v) => h - pad.b - (v / top) * (h - pad.t - pad.b);
+
+  // Axes and grid
+  g.font = '11px JetBrains Mono, monospace';
+  g.fillStyle = '#71717a';
+  g.strokeStyle = '#18181b';
+  for (let v = 0; v <= top; v += 500) {
+    g.beginPath();
+    g.moveTo(pad.l, y(v));
+    g.lineTo(w - pad.r, y(v));
+    g.stroke();
+    g.fillText(String(v), 4, y(v) + 4);
+  }
+  for (let m = 10; m >= 0; m -= 2) {
+    g.fillText(m ? `-${m}m` : 'now', x(now - m * 60000) - 12, h - 6);
+  }
+
+  // Thresholds: the IPv6 minimum and each report's budget as a step line
+  const dashed = (color, pts) => {
+    g.save();
+    g.setLineDash([6, 4]);
+    g.strokeStyle = color;
+    g.beginPath();
+    pts.forEach(([t, v], i) => (i ? g.lineTo(x(t), y(v)) : g.moveTo(x(t), y(v))));
+    g.stroke();
+    g.restore();
+  };
+  dashed('#fbbf24', [[from, IPV6_MIN_BUDGET], [now, IPV6_MIN_BUDGET]]);
+  const steps = [];
+  let budget = visible.length ? visible[0].budget : 0;
+  if (budget) steps.push([from, budget]);
+  for (const p of visible) {
+    if (p.budget && p.budget !== budget) {
+      if (budget) steps.push([p.t, budget]);
+      budget = p.budget;
+      steps.push([p.t, budget]);
+    }
+  }
+  if (budget) {
+    steps.push([now, budget]);
+    dashed('#f87171', steps);
+  }
+
+  // Annotations as a line, or a band for a range, labelled at the top
+  g.save();
+  g.font = '10px system-ui, sans-serif';
+  for (const n of chart.notes) {
+    const start = Math.max(Date.parse(n.time), from);
+    const end = n.end ? Math.min(Date.parse(n.end), now) : start;
+    if (end < from || start > now) continue;
+    g.fillStyle = 'rgba(167, 139, 250, 0.12)';
+    if (end > start) g.fillRect(x(start), pad.t, x(end) - x(start), h - pad.t - pad.b);
+    g.strokeStyle = '#a78bfa';
+    g.beginPath();
+    g.moveTo(x(start), pad.t);
+    g.lineTo(x(start), h - pad.b);
+    g.stroke();
+    g.fillStyle = '#a78bfa';
+    g.fillText(n.text.length > 40 ? `${n.text.slice(0, 39)}…` : n.text, x(start) + 4, pad.t + 10);
+  }
+  g.restore();
+
+  // Handshakes
+  for (const p of visible) {
+    g.fillStyle = p.status === 'SAFE' ? '#34d399' : '#f87171';
+    g.beginPath();
+    g.arc(x(p.t), y(p.size), 3, 0, 2 * Math.PI);
+    g.fill();
+  }
+  document.getElementById('chart-count').textContent = `${visible.length} handshakes in the last 10 minutes`;
+}
+
+function chartConnect() {
+  const scheme = location.protocol === 'https:' ? 'wss' : 'ws';
+  // Browsers cannot set headers on a WebSocket, so the token goes in the URL
+  const query = apiToken ? `?token=${encodeURIComponent(apiToken)}` : '';
+  const ws = new WebSocket(`${scheme}://${location.host}/api/live${query}`);
+  ws.onmessage = (ev) => {
+    try {
+      chartAdd(JSON.parse(ev.data));
+    } catch (err) {
+      console.error(err);
+    }
+  };
+  ws.onclose = () => setTimeout(chartConnect, 3000);
+}
+
+function chartStart() {
+  chart.canvas = document.getElementById('chart');
+  api('/api/reports?since=10m&limit=1000')
+    .catch(() => ({ reports: [] }))
+    .then((page) => page.reports.reverse().forEach(chartAdd))
+    .catch(console.error)
+    .finally(chartConnect);
+  const notes = () => api('/api/annotations?since=10m')
+    .then((data) => { chart.notes = data.annotations; })
+    .catch(console.error);
+  notes();
+  setInterval(notes, CHART_NOTES_MS);
+  setInterval(chartDraw, 1000);
+}
+
+chartStart();
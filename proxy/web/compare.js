@@ -0,0 +1,75 @@
+// Sentinel-PQC before/after comparison: two windows around a change from
+// /api/compare, side by side with their deltas.
+'use strict';
+
+const COMPARE_FILTERS = ['at', 'window', 'annotation', 'subnet', 'algorithm'];
+
+async function compareLoad(params) {
+  const form = $('cmp-form');
+  for (const key of COMPARE_FILTERS) {
+    if (key === 'annotation') continue;
+    const v = params.get(key) || '';
+    form.elements[key].value = key === 'at' ? (v && localInput(v)) : v || (key === 'window' ? '24h' : '');
+  }
+  $('cmp-error').textContent = '';
+  if (!params.get('at') && !params.get('annotation')) {
+    $('cmp-result').hidden = true;
+    return;
+  }
+  try {
+    const data = await api(`/api/compare?${params}`);
+    $('cmp-store').textContent = `${data.before.from} – ${data.before.to} against ${data.after.from} – ${data.after.to}, from ${data.store}`;
+    renderCompare(data);
+    $('cmp-result').hidden = false;
+  } catch (err) {
+    $('cmp-error').textContent = err.message;
+    $('cmp-result').hidden = true;
+  }
+}
+
+// signed shows a delta with its sign; lower is better unless higherBetter.
+function signed(v, unit = '', higherBetter = false) {
+  const text = `${v > 0 ? '+' : ''}${Number.isInteger(v) ? v : v.toFixed(2)}${unit}`;
+  if (v === 0) return el('td', { class: 'muted' }, text);
+  return el('td', { class: (v < 0) !== higherBetter ? 'status SAFE' : 'status risk' }, text);
+}
+
+const pct = (f) => `${(f * 100).toFixed(1)}%`;
+
+function renderCompare(data) {
+  const b = data.before, a = data.after, d = data.delta;
+  const rows = [
+    ['Handshakes', b.reports, a.reports, el('td', {}, `${d.reports > 0 ? '+' : ''}${d.reports}`)],
+    ['Detections', b.detections, a.detections, signed(d.detections)],
+    ['Detection rate', pct(b.detection_rate), pct(a.detection_rate), signed(d.detection_rate * 100, ' pp')],
+    ['Over IPv6 minimum', b.ipv6_risk, a.ipv6_risk, signed(d.ipv6_risk)],
+    ['Size p50', b.size.p50, a.size.p50, signed(d.size.p50, ' B')],
+    ['Size p95', b.size.p95, a.size.p95, signed(d.size.p95, ' B')],
+    ['Size max', b.size.max, a.size.max, signed(d.size.max, ' B')],
+    ['Latency p50', `${b.latency.p50_ms} ms`, `${a.latency.p50_ms} ms`, signed(d.latency_p50_ms, ' ms')],
+    ['Latency p95', `${b.latency.p95_ms} ms`, `${a.latency.p95_ms} ms`, signed(d.latency_p95_ms, ' ms')],
+  ];
+  $('cmp-summary').replaceChildren(...rows.map(([name, before, after, delta]) => el('tr', {},
+    el('td', {}, name), el('td', {}, String(before)), el('td', {}, String(after)), delta)));
+
+  $('cmp-mix').replaceChildren(...d.algorithms.map((m) => el('tr', {},
+    el('td', {}, m.algorithm),
+    el('td', {}, m.family),
+    el('td', {}, `${pct(m.share_before)} (${m.reports_before})`),
+    el('td', {}, `${pct(m.share_after)} (${m.reports_after})`),
+    signed(m.share * 100, ' pp', true),
+    m.reports_before && m.reports_after ? signed(m.detection_rate * 100, ' pp') : el('td', { class: 'muted' }, 'n/a'))));
+
+  $('cmp-notes').replaceChildren(...(data.annotations.length
+    ? data.annotations.map((n) => el('li', {}, el('span', { class: 'muted' }, `${n.time} · ${n.author} `), n.text))
+    : [el('li', { class: 'muted' }, 'No annotations around this change.')]));
+}
+
+$('cmp-form').addEventListener('submit', (e) => {
+  e.preventDefault();
+  const f = e.target.elements;
+  const params = new URLSearchParams();
+  if (f.at.value) params.set('at', fromLocalInput(f.at.value));
+  for (const key of ['window', 'subnet', 'algorithm']) if (f[key].value.trim()) params.set(key, f[key].value.trim());
+  location.hash = `#compare?${params}`;
+});
@@ -0,0 +1,127 @@
+// Sentinel-PQC control plane: shows and changes the runtime settings through
+// /api/control (operator role), lists the audit trail and the alert silences
+// (silences.js) and lets admins manage the API users.
+'use strict';
+
+const ROLE_RANK = { viewer: 1, operator: 2, admin: 3 };
+
+async function controlLoad() {
+  $('ctl-error').textContent = '';
+  try {
+    const [me, state, audit] = await Promise.all([api('/api/whoami'), api('/api/control'), api('/api/control/audit?limit=50')]);
+    const rank = ROLE_RANK[me.role] || 0;
+    $('ctl-whoami').textContent = me.auth ? `signed in as ${me.name} (${me.role})` : '';
+    for (const input of $('ctl-form').elements) input.disabled = rank < ROLE_RANK.operator;
+    renderControl(state, rank >= ROLE_RANK.operator);
+    renderAudit(audit.entries);
+    await silencesLoad(rank >= ROLE_RANK.operator);
+    $('ctl-users-panel').hidden = rank < ROLE_RANK.admin;
+    if (rank >= ROLE_RANK.admin) renderUsers(await api('/api/users'));
+  } catch (err) {
+    $('ctl-error').textContent = err.message;
+  }
+}
+
+function renderControl(st, canChange) {
+  const form = $('ctl-form');
+  const imp = st.impairment || {};
+  form.elements.mtu.value = st.mtu;
+  form.elements.algorithms.value = (st.algorithms || []).join(', ');
+  form.elements.delay.value = imp.delay || '';
+  form.elements.jitter.value = imp.jitter || '';
+  form.elements.bandwidth.value = imp.bandwidth_kbps || '';
+  form.elements.blackhole.checked = !!imp.blackhole;
+  form.elements.marginal.value = st.marginal;
+  form.elements.multi_segment.value = st.multi_segment;
+  form.elements.webhook_on.value = (st.webhook_on || []).join(', ');
+  $('ctl-changed').textContent = st.changed ? 'changed at runtime' : 'as started';
+
+  $('ctl-listeners').replaceChildren(...st.listeners.map((l) => el('tr', {},
+    el('td', {}, l.addr),
+    el('td', {}, String(l.mtu)),
+    el('td', {}, (l.algorithms || []).join('+')),
+    el('td', {}, Object.entries(l.impairment || {}).map(([k, v]) => (v === true ? k : `${k}=${v}`)).join(', ')),
+    el('td', {}, `${l.margin}% / ${l.segments}`),
+    el('td', {}, l.changed && canChange ? el('button', { 'data-addr': l.addr }, 'Reset') : ''))));
+}
+
+function renderUsers(data) {
+  $('ctl-users-file').textContent = data.file ? `kept in ${data.file}` : 'start the proxy with -api-users to add users';
+  const rows = data.users.map((u) => el('tr', {},
+    el('td', {}, u.name),
+    el('td', {}, u.role),
+    el('td', {}, el('button', { 'data-user': u.name }, 'Remove'))));
+  for (const t of data.tokens) rows.push(el('tr', { class: 'muted' }, el('td', {}, `${t.name} (token)`), el('td', {}, t.role), el('td', {}, '')));
+  if (rows.length === 0) rows.push(el('tr', {}, el('td', { colspan: 3, class: 'muted' }, 'No credentials: the API is open.')));
+  $('ctl-users').replaceChildren(...rows);
+}
+
+function renderAudit(entries) {
+  if (entries.length === 0) {
+    $('ctl-audit').replaceChildren(el('tr', {}, el('td', { colspan: 5, class: 'muted' }, 'Nothing changed yet.')));
+    return;
+  }
+  $('ctl-audit').replaceChildren(...entries.map((e) => el('tr', {},
+    el('td', {}, e.time),
+    el('td', {}, e.actor),
+    el('td', {}, e.action),
+    el('td', {}, e.target || ''),
+    el('td', { class: 'muted' }, e.after ? JSON.stringify(e.after).slice(0, 120) : ''))));
+}
+
+const splitList = (v) => v.split(',').map((s) => s.trim()).filter(Boolean);
+
+// controlChange reads the form into a PATCH of the defaults.
+function controlChange() {
+  const f = $('ctl-form').elements;
+  const change = {
+    mtu: Number(f.mtu.value),
+    marginal: Number(f.marginal.value),
+    multi_segment: Number(f.multi_segment.value),
+    impairment: {
+      delay: f.delay.value.trim(),
+      jitter: f.jitter.value.trim(),
+      bandwidth_kbps: Number(f.bandwidth.value) || 0,
+      blackhole: f.blackhole.checked,
+    },
+  };
+  const algorithms = splitList(f.algorithms.value);
+  if (algorithms.length) change.algorithms = algorithms;
+  const statuses = splitList(f.webhook_on.value);
+  if (statuses.length) change.webhook_on = statuses;
+  return change;
+}
+
+async function controlSend(method, path, body) {
+  $('ctl-error').textContent = '';
+  try {
+    await api(path, [], method, body);
+    controlLoad();
+  } catch (err) {
+    $('ctl-error').textContent = err.message;
+  }
+}
+
+$('ctl-form').addEventListener('submit', (e) => {
+  e.preventDefault();
+  controlSend('PATCH', '/api/control', controlChange());
+});
+$('ctl-reset').addEventListener('click', () => {
+  if (window.confirm('Go back to the command-line settings for every listener?')) controlSend('DELETE', '/api/control');
+});
+$('ctl-user-form').addEventListener('submit', (e) => {
+  e.preventDefault();
+  const f = e.target.elements;
+  const change = { role: f.role.value };
+  if (f.password.value) change.password = f.password.value;
+  controlSend('PUT', `/api/users/${encodeURIComponent(f.name.value.trim())}`, change);
+  e.target.reset();
+});
+$('ctl-users').addEventListener('click', (e) => {
+  const user = e.target.dataset && e.target.dataset.user;
+  if (user && window.confirm(`Remove the user ${user}?`)) controlSend('DELETE', `/api/users/${encodeURIComponent(user)}`);
+});
+$('ctl-listeners').addEventListener('click', (e) => {
+  const addr = e.target.dataset && e.target.dataset.addr;
+  if (addr) controlSend('DELETE', `/api/control/listeners/${encodeURIComponent(addr)}`);
+});
@@ -0,0 +1,62 @@
+// Sentinel-PQC alert silences: the silences in force and the latest alerts
+// on the Control tab; silencing and acknowledging need the operator role.
+'use strict';
+
+async function silencesLoad(canChange) {
+  const [rules, silenced, fired] = await Promise.all([
+    api('/api/alerts/rules'), api('/api/alerts/silences'), api('/api/alerts/fired?limit=50')]);
+  const names = Object.fromEntries(rules.map((r) => [r.id, r.name]));
+  const form = $('ctl-silence-form');
+  form.elements.rule.replaceChildren(el('option', { value: '' }, 'every rule'),
+    ...rules.map((r) => el('option', { value: r.id }, r.name)));
+  for (const input of form.elements) input.disabled = !canChange;
+  renderSilences(silenced.silences, names, canChange);
+  renderFired(fired.alerts, canChange);
+}
+
+function renderSilences(list, names, canChange) {
+  if (list.length === 0) {
+    $('ctl-silences').replaceChildren(el('tr', {}, el('td', { colspan: 6, class: 'muted' }, 'Nothing is silenced.')));
+    return;
+  }
+  $('ctl-silences').replaceChildren(...list.map((s) => el('tr', {},
+    el('td', {}, s.rule ? names[s.rule] || s.rule : 'every rule'),
+    el('td', {}, s.subnet || 'every client'),
+    el('td', {}, s.expires),
+    el('td', {}, s.author),
+    el('td', {}, s.alert ? `${s.comment} (acknowledged)` : s.comment),
+    el('td', {}, canChange ? el('button', { 'data-silence': s.id }, 'Lift') : ''))));
+}
+
+function renderFired(alerts, canChange) {
+  if (alerts.length === 0) {
+    $('ctl-fired').replaceChildren(el('tr', {}, el('td', { colspan: 6, class: 'muted' }, 'No alert has fired since the proxy started.')));
+    return;
+  }
+  $('ctl-fired').replaceChildren(...alerts.map((a) => el('tr', { class: a.silenced ? 'muted' : '' },
+    el('td', {}, a.time),
+    el('td', {}, a.rule_name),
+    el('td', {}, a.client),
+    el('td', { class: `status ${a.status}` }, a.status),
+    el('td', {}, `${a.handshake_size} B`),
+    el('td', {}, a.silenced ? 'silenced'
+      : canChange ? el('button', { 'data-ack': a.id, title: `Silence this rule for ${a.subnet} for 4h` }, 'Ack') : ''))));
+}
+
+$('ctl-silence-form').addEventListener('submit', (e) => {
+  e.preventDefault();
+  const f = e.target.elements;
+  const silence = { comment: f.comment.value, for: f.for.value.trim() };
+  if (f.rule.value) silence.rule = f.rule.value;
+  if (f.subnet.value.trim()) silence.subnet = f.subnet.value.trim();
+  controlSend('POST', '/api/alerts/silences', silence);
+  e.target.reset();
+});
+$('ctl-silences').addEventListener('click', (e) => {
+  const id = e.target.dataset && e.target.dataset.silence;
+  if (id && window.confirm('Lift this silence?')) controlSend('DELETE', `/api/alerts/silences/${encodeURIComponent(id)}`);
+});
+$('ctl-fired').addEventListener('click', (e) => {
+  const id = e.target.dataset && e.target.dataset.ack;
+  if (id) controlSend('POST', `/api/alerts/fired/${encodeURIComponent(id)}/ack`, {});
+});
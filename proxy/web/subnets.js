@@ -0,0 +1,50 @@
+// Sentinel-PQC subnet heatmap: one tile per client network from /api/subnets,
+// riskiest first, shaded from green (no trouble) to red (every handshake).
+'use strict';
+
+async function subnetsLoad(params) {
+  const since = params.get('since') || '24h';
+  const min = params.get('min_reports') || '1';
+  $('sn-since').value = since;
+  $('sn-min').value = min;
+  $('sn-error').textContent = '';
+  try {
+    const data = await api(`/api/subnets?since=${encodeURIComponent(since)}&min_reports=${encodeURIComponent(min)}`);
+    $('sn-store').textContent = `${data.subnets.length} of ${data.networks} networks, ${data.reports} reports from ${data.store}${data.truncated ? ' (truncated)' : ''}`;
+    renderSubnets(data.subnets);
+  } catch (err) {
+    $('sn-error').textContent = err.message;
+    $('sn-heatmap').replaceChildren();
+  }
+}
+
+// riskColor blends from the SAFE green through amber to the risk red.
+function riskColor(score) {
+  const stops = [[52, 211, 153], [251, 191, 36], [248, 113, 113]];
+  const t = Math.min(score, 100) / 50;
+  const [a, b] = t <= 1 ? [stops[0], stops[1]] : [stops[1], stops[2]];
+  const f = t <= 1 ? t : t - 1;
+  return `rgb(${a.map((v, i) => Math.round(v + (b[i] - v) * f)).join(',')})`;
+}
+
+function renderSubnets(subnets) {
+  if (subnets.length === 0) {
+    $('sn-heatmap').replaceChildren(el('p', { class: 'muted' }, 'No handshakes in this window.'));
+    return;
+  }
+  $('sn-heatmap').replaceChildren(...subnets.map((s) => el('div', {
+    class: 'tile',
+    style: `background:${riskColor(s.risk_score)}`,
+    title: `${s.subnet}: ${s.fragmented} of ${s.handshakes} handshakes in trouble, ${s.clients} clients, last seen ${s.last_seen}`,
+  },
+  el('span', { class: 'subnet' }, s.subnet),
+  el('span', { class: 'score' }, `${s.risk_score}`),
+  el('span', {}, `${s.fragmented} / ${s.handshakes}`))));
+}
+
+for (const id of ['sn-since', 'sn-min']) {
+  $(id).addEventListener('change', () => {
+    const params = new URLSearchParams({ since: $('sn-since').value, min_reports: $('sn-min').value || '1' });
+    location.hash = `#subnets?${params}`;
+  });
+}
@@ -0,0 +1,113 @@
+// Sentinel-PQC timeline: pages through stored reports via /api/reports.
+// The view and its filters live in the URL hash (#timeline?status=...&offset=50)
+// so a filtered page can be bookmarked or shared.
+'use strict';
+
+const TIMELINE_FILTERS = ['from', 'to', 'algorithm', 'status', 'subnet', 'probe', 'site', 'limit'];
+
+const timeline = { offset: 0, limit: 50, more: false, count: 0 };
+
+const VIEWS = ['overview', 'timeline', 'algorithms', 'subnets', 'compare', 'control'];
+
+// showView switches between the views from the hash.
+function showView() {
+  const [view, query] = location.hash.slice(1).split('?');
+  const current = VIEWS.includes(view) ? view : 'overview';
+  for (const id of VIEWS) $(id).hidden = id !== current;
+  for (const a of document.querySelectorAll('nav a')) a.classList.toggle('active', a.dataset.view === current);
+  if (current === 'timeline') timelineLoad(new URLSearchParams(query));
+  if (current === 'algorithms') algorithmsLoad(new URLSearchParams(query));
+  if (current === 'subnets') subnetsLoad(new URLSearchParams(query));
+  if (current === 'compare') compareLoad(new URLSearchParams(query));
+  if (current === 'control') controlLoad();
+}
+
+// localInput turns an RFC 3339 time into a datetime-local value and back.
+function localInput(rfc3339) {
+  const d = new Date(rfc3339);
+  if (isNaN(d)) return '';
+  return new Date(d.getTime() - d.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+}
+
+function fromLocalInput(value) {
+  return value ? new Date(value).toISOString() : '';
+}
+
+// timelineParams reads the form into /api/reports parameters.
+function timelineParams(offset) {
+  const form = new FormData($('tl-filters'));
+  const params = new URLSearchParams();
+  for (const key of TIMELINE_FILTERS) {
+    let v = String(form.get(key) || '').trim();
+    if (key === 'from' || key === 'to') v = fromLocalInput(v);
+    if (v) params.set(key, v);
+  }
+  if (offset > 0) params.set('offset', offset);
+  return params;
+}
+
+// timelineGo records a page in the hash; showView then loads it.
+function timelineGo(offset) {
+  const query = timelineParams(offset).toString();
+  const hash = `#timeline${query ? '?' + query : ''}`;
+  if (location.hash === hash) showView();
+  else location.hash = hash;
+}
+
+async function timelineLoad(params) {
+  const form = $('tl-filters');
+  for (const key of TIMELINE_FILTERS) {
+    const v = params.get(key) || '';
+    if (key === 'limit') form.elements.limit.value = v || '50';
+    else form.elements[key].value = key === 'from' || key === 'to' ? (v && localInput(v)) : v;
+  }
+  if (!params.has('limit')) params.set('limit', form.elements.limit.value);
+
+  $('tl-error').textContent = '';
+  $('tl-reports').replaceChildren(el('tr', {}, el('td', { colspan: 9, class: 'muted' }, 'Loading…')));
+  try {
+    const [page, notes] = await Promise.all([api(`/api/reports?${params}`), notesFor(params)]);
+    Object.assign(timeline, { offset: page.offset, limit: page.limit, more: page.more, count: page.count });
+    $('tl-store').textContent = `from ${page.store}`;
+    renderNotes(notes);
+    renderTimeline(page, notes);
+  } catch (err) {
+    Object.assign(timeline, { offset: 0, more: false, count: 0 });
+    $('tl-error').textContent = err.message;
+    $('tl-reports').replaceChildren();
+  }
+  $('tl-prev').disabled = timeline.offset === 0;
+  $('tl-next').disabled = !timeline.more;
+  $('tl-range').textContent = timeline.count
+    ? `${timeline.offset + 1}–${timeline.offset + timeline.count}${timeline.more ? '' : ' (last page)'}`
+    : '';
+}
+
+function renderTimeline(page, notes) {
+  if (page.count === 0) {
+    $('tl-reports').replaceChildren(el('tr', {}, el('td', { colspan: 9, class: 'muted' }, 'No reports match these filters.')));
+    return;
+  }
+  const noted = new Map(notes.filter((n) => n.conn_id).map((n) => [n.conn_id, n.text]));
+  $('tl-reports').replaceChildren(...page.reports.map((r) => el('tr', noted.has(r.conn_id) ? { class: 'noted', title: noted.get(r.conn_id) } : {},
+    el('td', {}, r.timestamp),
+    el('td', { class: 'fleet-only' }, probeName(r)),
+    el('td', {}, host(r.client_ip)),
+    el('td', {}, r.algorithm),
+    el('td', {}, `${r.handshake_size_bytes} / ${r.mtu_budget_bytes || '?'}`),
+    el('td', { class: `status ${statusClass(r.status)}` }, r.status),
+    el('td', { class: `status ${statusClass(r.severity)}` }, r.severity || ''),
+    el('td', {}, r.listener || ''),
+    el('td', {}, el('button', { 'data-conn': r.conn_id || '', 'data-time': r.timestamp, title: 'Annotate this report' }, '✎')))));
+}
+
+$('tl-filters').addEventListener('submit', (e) => {
+  e.preventDefault();
+  timelineGo(0);
+});
+$('tl-filters').addEventListener('reset', () => setTimeout(() => timelineGo(0)));
+$('tl-prev').addEventListener('click', () => timelineGo(Math.max(timeline.offset - timeline.limit, 0)));
+$('tl-next').addEventListener('click', () => timelineGo(timeline.offset + timeline.count));
+
+window.addEventListener('hashchange', showView);
+showView();
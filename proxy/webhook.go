@@ -0,0 +1,259 @@
+/*
+Sentinel-PQC Proxy - Webhook Alerts
+===================================
+-webhook POSTs a JSON alert to your own automation whenever a report comes
+out CRITICAL_RISK (or any status listed in -webhook-on):
+
+  SENTINEL_WEBHOOK_SECRET=s3cret go run . -webhook https://hooks.example/pqc
+
+  POST /pqc
+  Content-Type: application/json
+  X-Sentinel-Event: CRITICAL_RISK
+  X-Sentinel-Delivery: 5f0c9e2a7b41d3c8      same on every retry
+  X-Sentinel-Timestamp: 1792166500
+  X-Sentinel-Signature: sha256=<hex HMAC-SHA256 of "timestamp.body">
+
+  {"event":"CRITICAL_RISK","delivery":"5f0c...","report":{...}}
+
+With a secret (-webhook-secret or SENTINEL_WEBHOOK_SECRET) receivers verify
+the signature and reject timestamps older than a few minutes. Alerts are
+queued and sent by a background worker: network errors, 429 and 5xx are
+retried with exponential backoff and jitter (Retry-After is honoured), other
+4xx answers are final.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+const (
+	WEBHOOK_QUEUE_SIZE    = 256
+	WEBHOOK_RETRIES       = 5
+	WEBHOOK_BACKOFF       = time.Second // doubled per retry
+	WEBHOOK_MAX_BACKOFF   = 30 * time.Second
+	WEBHOOK_TIMEOUT       = 10 * time.Second
+	WEBHOOK_CLOSE_TIMEOUT = 10 * time.Second // for queued alerts at shutdown
+	WEBHOOK_SECRET_ENV    = "SENTINEL_WEBHOOK_SECRET"
+)
+
+// webhookAlert is the JSON body of one delivery.
+type webhookAlert struct {
+	Event    string      `json:"event"`
+	Delivery string      `json:"delivery"`
+	Rule     string      `json:"rule,omitempty"` // alert rule that fired, see alerts.go
+	Report   GhostReport `json:"report"`
+}
+
+type webhookSink struct {
+	url    string
+	host   string
+	secret []byte
+	on     map[string]bool
+	client *http.Client
+
+	queue   chan webhookAlert
+	done    chan struct{}
+	dropped int
+	closed  bool // queue closed; guarded by mu like every send to it
+	mu      sync.Mutex
+}
+
+// ============================================================================
+// SETUP
+// ============================================================================
+
+func openWebhookSink(target, secret, statuses string) (*webhookSink, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook: %q is not an http(s) URL", target)
+	}
+	if secret == "" {
+		secret = os.Getenv(WEBHOOK_SECRET_ENV)
+	}
+	s := &webhookSink{
+		url:    target,
+		host:   u.Host,
+		secret: []byte(secret),
+		on:     make(map[string]bool),
+		client: &http.Client{Timeout: WEBHOOK_TIMEOUT},
+		queue:  make(chan webhookAlert, WEBHOOK_QUEUE_SIZE),
+		done:   make(chan struct{}),
+	}
+	for _, st := range strings.Split(statuses, ",") {
+		if st = strings.ToUpper(strings.TrimSpace(st)); st != "" {
+			s.on[st] = true
+		}
+	}
+	if len(s.on) == 0 {
+		return nil, fmt.Errorf("webhook: -webhook-on lists no status")
+	}
+	if len(s.secret) == 0 {
+		slog.Warn("webhook alerts are unsigned; set -webhook-secret or " + WEBHOOK_SECRET_ENV)
+	}
+	go s.run()
+	return s, nil
+}
+
+// ============================================================================
+// DELIVERY
+// ============================================================================
+
+func (s *webhookSink) Name() string { return "webhook:" + s.host }
+
+func (s *webhookSink) notifier() string { return "webhook" }
+
+// Write queues an alert for a matching report; it never blocks the handshake.
+func (s *webhookSink) Write(r GhostReport) error {
+	on := s.on
+	if live := webhookTrigger(); live != nil {
+		on = live // changed through /api/control
+	}
+	if !on[r.Status] {
+		return nil
+	}
+	return s.alert(r)
+}
+
+func (s *webhookSink) alert(r GhostReport) error {
+	id := make([]byte, 8)
+	rand.Read(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	select {
+	case s.queue <- webhookAlert{Event: r.Status, Delivery: hex.EncodeToString(id), Rule: r.alertRule, Report: r}:
+		return nil
+	default:
+		s.dropped++
+		return fmt.Errorf("queue full, alert dropped (%d so far)", s.dropped)
+	}
+}
+
+func (s *webhookSink) run() {
+	defer close(s.done)
+	for alert := range s.queue {
+		s.deliver(alert)
+	}
+}
+
+// deliver posts one alert, retrying transient failures with backoff.
+func (s *webhookSink) deliver(alert webhookAlert) {
+	lg := alert.Report.logger()
+	body, err := json.Marshal(alert)
+	if err != nil {
+		lg.Error("webhook: cannot encode alert", "err", err)
+		return
+	}
+	s.deliverBody(lg, alert.Event, alert.Delivery, body)
+}
+
+// deliverBody posts a JSON body, retrying transient failures with backoff.
+func (s *webhookSink) deliverBody(lg *slog.Logger, event, delivery string, body []byte) error {
+	wait := WEBHOOK_BACKOFF
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(event, delivery, body)
+		if err == nil {
+			lg.Info("webhook delivered", "delivery", delivery, "attempt", attempt)
+			return nil
+		}
+		if retry.wait < 0 || attempt > WEBHOOK_RETRIES {
+			lg.Error("webhook: giving up", "delivery", delivery, "attempts", attempt, "err", err)
+			return err
+		}
+		if retry.wait > 0 {
+			wait = retry.wait
+		}
+		// Jitter keeps a fleet of proxies from retrying in lockstep
+		sleep := wait/2 + time.Duration(mrand.Int63n(int64(wait/2)+1))
+		lg.Warn("webhook failed, retrying", "delivery", delivery, "attempt", attempt, "wait", sleep, "err", err)
+		time.Sleep(sleep)
+		wait = min(wait*2, WEBHOOK_MAX_BACKOFF)
+	}
+}
+
+// webhookRetry says how to follow up a failed post: wait < 0 means the
+// failure is final, wait > 0 is the receiver's Retry-After.
+type webhookRetry struct{ wait time.Duration }
+
+func (s *webhookSink) post(event, delivery string, body []byte) (webhookRetry, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return webhookRetry{wait: -1}, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sentinel-pqc-proxy")
+	req.Header.Set("X-Sentinel-Event", event)
+	req.Header.Set("X-Sentinel-Delivery", delivery)
+	req.Header.Set("X-Sentinel-Timestamp", timestamp)
+	if len(s.secret) > 0 {
+		req.Header.Set("X-Sentinel-Signature", "sha256="+webhookSignature(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return webhookRetry{}, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return webhookRetry{}, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		retry := webhookRetry{}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			retry.wait = min(time.Duration(secs)*time.Second, WEBHOOK_MAX_BACKOFF)
+		}
+		return retry, fmt.Errorf("HTTP %s", resp.Status)
+	default:
+		return webhookRetry{wait: -1}, fmt.Errorf("HTTP %s", resp.Status)
+	}
+}
+
+// webhookSignature is the hex HMAC-SHA256 of "timestamp.body"; binding the
+// timestamp lets receivers reject replayed deliveries.
+func webhookSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Close sends what is queued, giving up after WEBHOOK_CLOSE_TIMEOUT.
+func (s *webhookSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(WEBHOOK_CLOSE_TIMEOUT):
+		return fmt.Errorf("%d alert(s) still queued", len(s.queue))
+	}
+}